@@ -39,8 +39,15 @@ Target:     %v
 Difficulty: %v
 `, yesNo(cg.Synced), cg.CurrentBlock, cg.Height, cg.Target, cg.Difficulty)
 	} else {
-		estimatedHeight := estimatedHeightAt(time.Now(), cg)
-		estimatedProgress := float64(cg.Height) / float64(estimatedHeight) * 100
+		// Older versions of siad don't report the estimated height.
+		estimatedHeight := cg.EstimatedHeight
+		if estimatedHeight == 0 {
+			estimatedHeight = estimatedHeightAt(time.Now(), cg)
+		}
+		var estimatedProgress float64
+		if estimatedHeight > 0 {
+			estimatedProgress = float64(cg.Height) / float64(estimatedHeight) * 100
+		}
 		if estimatedProgress > 100 {
 			estimatedProgress = 99.9
 		}
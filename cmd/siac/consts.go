@@ -9,6 +9,10 @@ const (
 	// progress meter when displaying a continuous action like a download.
 	OutputRefreshRate = 250 * time.Millisecond
 
+	// RenterAwaitGroupRefreshRate is the rate at which the await-group command
+	// prints the progress of a download group.
+	RenterAwaitGroupRefreshRate = 5 * time.Second
+
 	// RenterDownloadTimeout is the amount of time that needs to elapse before
 	// the download command gives up on finding a download in the download list.
 	RenterDownloadTimeout = time.Minute
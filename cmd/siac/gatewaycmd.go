@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"sort"
 	"text/tabwriter"
 	"time"
 
@@ -81,6 +83,48 @@ For example: siac gateway blocklist set 123.123.123.123 111.222.111.222 mysiahos
 		Run: gatewayblocklistsetcmd,
 	}
 
+	// The blacklist commands are deprecated aliases of the blocklist commands
+	// kept for backwards compatibility.
+	gatewayBlacklistCmd = &cobra.Command{
+		Use:        "blacklist",
+		Short:      "View and manage the gateway's blocklisted peers",
+		Long:       "Display and manage the peers currently on the gateway blocklist.",
+		Run:        wrap(gatewayblocklistcmd),
+		Deprecated: "use 'siac gateway blocklist' instead.",
+	}
+
+	gatewayBlacklistAppendCmd = &cobra.Command{
+		Use:        "append [ip] [ip] [ip] [ip]...",
+		Short:      "Adds new ip address(es) to the gateway blocklist.",
+		Long:       "Adds new ip address(es) to the gateway blocklist.",
+		Run:        gatewayblocklistappendcmd,
+		Deprecated: "use 'siac gateway blocklist append' instead.",
+	}
+
+	gatewayBlacklistClearCmd = &cobra.Command{
+		Use:        "clear",
+		Short:      "Clear the blocklisted peers list",
+		Long:       "Clear the blocklisted peers list.",
+		Run:        gatewayblocklistclearcmd,
+		Deprecated: "use 'siac gateway blocklist clear' instead.",
+	}
+
+	gatewayBlacklistRemoveCmd = &cobra.Command{
+		Use:        "remove [ip] [ip] [ip] [ip]...",
+		Short:      "Remove ip address(es) from the gateway blocklist.",
+		Long:       "Remove ip address(es) from the gateway blocklist.",
+		Run:        gatewayblocklistremovecmd,
+		Deprecated: "use 'siac gateway blocklist remove' instead.",
+	}
+
+	gatewayBlacklistSetCmd = &cobra.Command{
+		Use:        "set [ip] [ip] [ip] [ip]...",
+		Short:      "Set the gateway's blocklist",
+		Long:       "Set the gateway's blocklist.",
+		Run:        gatewayblocklistsetcmd,
+		Deprecated: "use 'siac gateway blocklist set' instead.",
+	}
+
 	gatewayConnectCmd = &cobra.Command{
 		Use:   "connect [address]",
 		Short: "Connect to a peer",
@@ -102,6 +146,13 @@ For example: siac gateway blocklist set 123.123.123.123 111.222.111.222 mysiahos
 		Run:   wrap(gatewaylistcmd),
 	}
 
+	gatewayStatsCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "View the bandwidth used by each peer",
+		Long:  "View the number of bytes sent to and received from each connected peer.",
+		Run:   wrap(gatewaystatscmd),
+	}
+
 	gatewayRatelimitCmd = &cobra.Command{
 		Use:   "ratelimit [maxdownloadspeed] [maxuploadspeed]",
 		Short: "set maxdownloadspeed and maxuploadspeed",
@@ -117,7 +168,9 @@ Set them to 0 for no limit.`,
 // gatewayconnectcmd is the handler for the command `siac gateway add [address]`.
 // Adds a new peer to the peer list.
 func gatewayconnectcmd(addr string) {
-	err := httpClient.GatewayConnectPost(modules.NetAddress(addr))
+	ctx, cancel := context.WithTimeout(context.Background(), gatewayConnectTimeout)
+	defer cancel()
+	err := httpClient.GatewayConnectPostWithContext(ctx, modules.NetAddress(addr))
 	if err != nil {
 		die("Could not add peer:", err)
 	}
@@ -284,3 +337,34 @@ func gatewayratelimitcmd(downloadSpeedStr, uploadSpeedStr string) {
 	}
 	fmt.Println("Set gateway maxdownloadspeed to ", downloadSpeedInt, " and maxuploadspeed to ", uploadSpeedInt)
 }
+
+// gatewaystatscmd is the handler for the command `siac gateway stats`.
+// Prints the number of bytes sent to and received from each connected peer,
+// sorted by the total bandwidth used.
+func gatewaystatscmd() {
+	info, err := httpClient.GatewayGet()
+	if err != nil {
+		die("Could not get peer stats:", err)
+	}
+	if len(info.PeerStats) == 0 {
+		fmt.Println("No peers to show.")
+		return
+	}
+	stats := info.PeerStats
+	sort.Slice(stats, func(i, j int) bool {
+		totalI := stats[i].BytesSent + stats[i].BytesReceived
+		totalJ := stats[j].BytesSent + stats[j].BytesReceived
+		if totalI != totalJ {
+			return totalI > totalJ
+		}
+		return stats[i].NetAddress < stats[j].NetAddress
+	})
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Address\tSent\tReceived")
+	for _, ps := range stats {
+		fmt.Fprintf(w, "%v\t%v\t%v\n", ps.NetAddress, modules.FilesizeUnits(ps.BytesSent), modules.FilesizeUnits(ps.BytesReceived))
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// TestGatewayBlacklistAlias tests that the deprecated blacklist commands
+// produce the same output as the blocklist commands. The deprecation notice is
+// checked by TestRootSiacCmd since it is printed by the root command.
+func TestGatewayBlacklistAlias(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	// Create a test node for this test.
+	groupDir := siacTestDir(t.Name())
+	n, err := newTestNode(groupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := n.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Run the same sequence of commands through both commands. Both start
+	// with an empty blocklist and should leave it empty.
+	type cmdArgs struct {
+		blocklistCmd *cobra.Command
+		blacklistCmd *cobra.Command
+		args         []string
+	}
+	cmds := []cmdArgs{
+		{gatewayBlocklistAppendCmd, gatewayBlacklistAppendCmd, []string{"1.2.3.4", "5.6.7.8"}},
+		{gatewayBlocklistCmd, gatewayBlacklistCmd, nil},
+		{gatewayBlocklistRemoveCmd, gatewayBlacklistRemoveCmd, []string{"1.2.3.4"}},
+		{gatewayBlocklistCmd, gatewayBlacklistCmd, nil},
+		{gatewayBlocklistSetCmd, gatewayBlacklistSetCmd, []string{"9.9.9.9"}},
+		{gatewayBlocklistCmd, gatewayBlacklistCmd, nil},
+		{gatewayBlocklistClearCmd, gatewayBlacklistClearCmd, nil},
+		{gatewayBlocklistCmd, gatewayBlacklistCmd, nil},
+	}
+	var blocklistOutputs []string
+	for _, cmd := range cmds {
+		output := executeSiacHandler(t, n.Address, n.Password, cmd.blocklistCmd, cmd.args...)
+		blocklistOutputs = append(blocklistOutputs, output)
+	}
+	for i, cmd := range cmds {
+		output := executeSiacHandler(t, n.Address, n.Password, cmd.blacklistCmd, cmd.args...)
+		// The gateway doesn't return the blocklist in a fixed order.
+		if sortedLines(output) != sortedLines(blocklistOutputs[i]) {
+			t.Fatalf("outputs for %v %v don't match: %q != %q", cmd.blacklistCmd.Name(), cmd.args, blocklistOutputs[i], output)
+		}
+	}
+}
+
+// sortedLines returns the lines of s in sorted order.
+func sortedLines(s string) string {
+	lines := strings.Split(s, "\n")
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
@@ -92,10 +92,14 @@ func fileExists(filename string) bool {
 
 // estimatedHeightAt returns the estimated block height for the given time.
 // Block height is estimated by calculating the minutes since a known block in
-// the past and dividing by 10 minutes (the block time).
+// the past and dividing by 10 minutes (the block time). It is only used as a
+// fallback for daemons which don't report their estimated height.
 func estimatedHeightAt(t time.Time, cg api.ConsensusGET) types.BlockHeight {
 	gt := cg.GenesisTimestamp
 	bf := cg.BlockFrequency
+	if bf == 0 {
+		return 0
+	}
 	return types.BlockHeight(types.Timestamp(t.Unix())-gt) / bf
 }
 
@@ -83,6 +83,38 @@ func executeSiacCommandC(root *cobra.Command, args ...string) (c *cobra.Command,
 	return c, buf.String(), err
 }
 
+// executeSiacHandler runs the handler of a siac cobra command against the siad
+// at the given address and returns the siac output. Since it doesn't go
+// through a root command it can be used by tests other than the ones which
+// initialize the siac root command. It sets the global httpClient, so tests
+// using it must not run in parallel.
+func executeSiacHandler(t *testing.T, address, password string, cmd *cobra.Command, args ...string) string {
+	httpClient.Address = address
+	httpClient.Password = password
+	httpClient.UserAgent = "Sia-Agent"
+
+	c, err := newOutputCatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	func() {
+		// Recover from expected die() panic, rethrow any not expected panic
+		defer func() {
+			if rec := recover(); rec != nil {
+				if err, ok := rec.(error); !ok || err.Error() != errors.New("die panic for testing").Error() {
+					panic(rec)
+				}
+			}
+		}()
+		cmd.Run(cmd, args)
+	}()
+	output, err := c.stop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return output
+}
+
 // getRootCmdForSiacCmdsTests creates and initializes a new instance of siac Cobra
 // command
 func getRootCmdForSiacCmdsTests(dir string) *cobra.Command {
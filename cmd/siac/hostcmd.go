@@ -28,7 +28,12 @@ You can revert this by running:
 	siac host config acceptingcontracts false
 You may also supply a specific address to be announced, e.g.:
 	siac host announce my-host-domain.com:9001
-Doing so will override the standard connectivity checks.`,
+Doing so will override the standard connectivity checks.
+
+Before announcing, the host verifies that the address resolves, that the host
+is reachable at the address and whether the address matches the automatically
+detected one. Use --dry-run to only run these checks and --force to announce
+even if they fail.`,
 		Run: hostannouncecmd,
 	}
 
@@ -51,6 +56,7 @@ Available settings:
      maxrevisebatchsize:   bytes
      netaddress:           string
      windowsize:           blocks
+     maxcontractsize:      filesize
 
      collateral:       currency
      collateralbudget: currency
@@ -70,13 +76,21 @@ Available settings:
      registrysize:       filesize
      customregistrypath: string
 
+     readcachesize: filesize
+
+     orphanedsectordeletion:      boolean
+     orphanedsectordeletiondelay: seconds
+
+     maxpinnedpricetablevalidity: seconds
+
 Currency units can be specified, e.g. 10SC; run 'siac help wallet' for details.
 
 Durations (maxduration and windowsize) must be specified in either blocks (b),
 hours (h), days (d), or weeks (w). A block is approximately 10 minutes, so one
 hour is six blocks, a day is 144 blocks, and a week is 1008 blocks.
 
-Timeouts (ephemeralaccountexpiry) must be specified in either seconds (s),
+Timeouts (ephemeralaccountexpiry, orphanedsectordeletiondelay and
+maxpinnedpricetablevalidity) must be specified in either seconds (s),
 hours (h), days (d), or weeks (w). One hour is 3600 seconds, a day is 86400
 seconds, and a week is 604800 seconds.
 
@@ -131,6 +145,21 @@ other storage folders.`,
 		Run: wrap(hostfolderresizecmd),
 	}
 
+	hostStorageCmd = &cobra.Command{
+		Use:   "storage",
+		Short: "Manage the data stored by the host",
+		Long:  "Manage the data stored by the host.",
+	}
+
+	hostStorageMigrateCmd = &cobra.Command{
+		Use:   "migrate [src] [dst]",
+		Short: "Move all data of a storage folder to another storage folder",
+		Long: `Move all data stored in the storage folder at src to the storage folder at
+dst. Data is only removed from src once it has been written to dst. The source
+folder is not removed and can be removed afterwards using 'siac host folder remove'.`,
+		Run: wrap(hoststoragemigratecmd),
+	}
+
 	hostSectorCmd = &cobra.Command{
 		Use:   "sector",
 		Short: "Add or delete a sector (add not supported)",
@@ -219,6 +248,7 @@ Host Internal Settings:
 	maxrevisebatchsize:   %v
 	netaddress:           %v
 	windowsize:           %v Hours
+	maxcontractsize:      %v
 
 	collateral:       %v / TB / Month
 	collateralbudget: %v
@@ -238,6 +268,13 @@ Host Internal Settings:
 	registrysize:       %v
 	customregistrypath: %v
 
+	readcachesize: %v
+
+	orphanedsectordeletion:      %v
+	orphanedsectordeletiondelay: %vs
+
+	maxpinnedpricetablevalidity: %vs
+
 Host Financials:
 	Contract Count:               %v
 	Transaction Fee Compensation: %v
@@ -274,6 +311,7 @@ RPC Stats:
 			modules.FilesizeUnits(is.MaxReviseBatchSize),
 			netaddr,
 			is.WindowSize/6,
+			modules.FilesizeUnits(is.MaxContractSize),
 
 			currencyUnits(is.Collateral.Mul(modules.BlockBytesPerMonthTerabyte)),
 			currencyUnits(is.CollateralBudget),
@@ -292,6 +330,13 @@ RPC Stats:
 			modules.FilesizeUnits(is.RegistrySize),
 			is.CustomRegistryPath,
 
+			modules.FilesizeUnits(is.ReadCacheSize),
+
+			yesNo(is.OrphanedSectorDeletion),
+			is.OrphanedSectorDeletionDelay.Seconds(),
+
+			is.MaxPinnedPriceTableValidity.Seconds(),
+
 			fm.ContractCount, currencyUnits(fm.ContractCompensation),
 			currencyUnits(fm.PotentialContractCompensation),
 			currencyUnits(fm.TransactionFeeExpenses),
@@ -344,6 +389,29 @@ RPC Stats:
 		fmt.Println("\nWarning:\n	Your wallet is locked. You must unlock your wallet for the host to function properly.")
 	}
 
+	// display read cache info
+	if sg.ReadCache.Capacity > 0 {
+		fmt.Printf(`
+Read Cache:
+	Used:   %v / %v
+	Hits:   %v
+	Misses: %v
+`, modules.FilesizeUnits(sg.ReadCache.Size), modules.FilesizeUnits(sg.ReadCache.Capacity), sg.ReadCache.Hits, sg.ReadCache.Misses)
+	}
+
+	// display orphaned sector info
+	if sg.OrphanedSectors.Flagged > 0 || sg.OrphanedSectors.Deleted > 0 {
+		action := "reported only"
+		if is.OrphanedSectorDeletion {
+			action = fmt.Sprintf("deleted after %v", is.OrphanedSectorDeletionDelay)
+		}
+		fmt.Printf(`
+Orphaned Sectors (%v):
+	Flagged: %v
+	Deleted: %v
+`, action, sg.OrphanedSectors.Flagged, sg.OrphanedSectors.Deleted)
+	}
+
 	fmt.Println("\nStorage Folders:")
 
 	// display storage folder info
@@ -359,7 +427,11 @@ RPC Stats:
 	for _, folder := range sg.Folders {
 		curSize := int64(folder.Capacity - folder.CapacityRemaining)
 		pctUsed := 100 * (float64(curSize) / float64(folder.Capacity))
-		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", modules.FilesizeUnits(uint64(curSize)), modules.FilesizeUnits(folder.Capacity), pctUsed, folder.Path)
+		path := folder.Path
+		if folder.ReadOnly {
+			path += " (read-only)"
+		}
+		fmt.Fprintf(w, "\t%s\t%s\t%.2f\t%s\n", modules.FilesizeUnits(uint64(curSize)), modules.FilesizeUnits(folder.Capacity), pctUsed, path)
 	}
 	if err := w.Flush(); err != nil {
 		die("failed to flush writer")
@@ -399,7 +471,7 @@ func hostconfigcmd(param, value string) {
 		value = c.String()
 
 	// bool (allow "yes" and "no")
-	case "acceptingcontracts":
+	case "acceptingcontracts", "orphanedsectordeletion":
 		switch strings.ToLower(value) {
 		case "yes":
 			value = "true"
@@ -415,14 +487,14 @@ func hostconfigcmd(param, value string) {
 		}
 
 	// filesize (convert to bytes)
-	case "registrysize":
+	case "registrysize", "readcachesize", "maxcontractsize":
 		value, err = parseFilesize(value)
 		if err != nil {
 			die("Could not parse "+param+":", err)
 		}
 
 	// timeout (convert to seconds)
-	case "ephemeralaccountexpiry":
+	case "ephemeralaccountexpiry", "orphanedsectordeletiondelay", "maxpinnedpricetablevalidity":
 		value, err = parseTimeout(value)
 		if err != nil {
 			die("Could not parse "+param+":", err)
@@ -487,16 +559,48 @@ func hostcontractcmd() {
 // Announces yourself as a host to the network. Optionally takes an address to
 // announce as.
 func hostannouncecmd(cmd *cobra.Command, args []string) {
-	var err error
+	var addr modules.NetAddress
 	switch len(args) {
 	case 0:
-		err = httpClient.HostAnnouncePost()
 	case 1:
-		err = httpClient.HostAnnounceAddrPost(modules.NetAddress(args[0]))
+		addr = modules.NetAddress(args[0])
 	default:
 		_ = cmd.UsageFunc()(cmd)
 		os.Exit(exitCodeUsage)
 	}
+
+	// On a dry run only print the results of the checks.
+	if hostAnnounceDryRun {
+		checks, err := httpClient.HostAnnounceDryRunPost(addr)
+		if err != nil {
+			die("Could not run pre-announcement checks:", err)
+		}
+		fmt.Printf("Pre-announcement checks for %v:\n", checks.NetAddress)
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, check := range checks.Checks {
+			status := "passed"
+			if !check.Passed && check.Warning {
+				status = "warning"
+			} else if !check.Passed {
+				status = "failed"
+			}
+			fmt.Fprintf(w, "  %v\t%v\t%v\n", check.Name, status, check.Message)
+		}
+		if err := w.Flush(); err != nil {
+			die("failed to flush writer")
+		}
+		return
+	}
+
+	var err error
+	switch {
+	case hostAnnounceForce:
+		err = httpClient.HostAnnounceForcePost(addr)
+	case addr != "":
+		err = httpClient.HostAnnounceAddrPost(addr)
+	default:
+		err = httpClient.HostAnnouncePost()
+	}
 	if err != nil {
 		die("Could not announce host:", err)
 	}
@@ -550,6 +654,16 @@ func hostfolderremovecmd(path string) {
 	fmt.Println("Removed folder", path)
 }
 
+// hoststoragemigratecmd moves the data of a storage folder to another storage
+// folder.
+func hoststoragemigratecmd(src, dst string) {
+	err := httpClient.HostStorageMigratePost(abs(src), abs(dst))
+	if err != nil {
+		die("Could not migrate folder:", err)
+	}
+	fmt.Println("Migrated folder", src, "to", dst)
+}
+
 // hostfolderresizecmd resizes a folder in the host.
 func hostfolderresizecmd(path, newsize string) {
 	newsize, err := parseFilesize(newsize)
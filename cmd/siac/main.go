@@ -5,6 +5,7 @@ import (
 	"math"
 	"os"
 	"reflect"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -30,7 +31,12 @@ var (
 	daemonProfileDirectory string // The Directory where the profile logs are saved
 	daemonTraceProfile     bool   // Indicates that the Trace profile should be started
 
+	// Gateway Flags
+	gatewayConnectTimeout time.Duration // Timeout for connecting to a peer
+
 	// Host Flags
+	hostAnnounceDryRun     bool   // only run the pre-announcement checks
+	hostAnnounceForce      bool   // announce despite failed checks
 	hostContractOutputType string // output type for host contracts
 	hostFolderRemoveForce  bool   // force folder remove
 
@@ -38,15 +44,19 @@ var (
 	dataPieces                string // the number of data pieces a file should be uploaded with
 	parityPieces              string // the number of parity pieces a file should be uploaded with
 	renterAllContracts        bool   // Show all active and expired contracts
+	renterAwaitGroupFailFast  bool   // Stop waiting for a download group once a download failed.
 	renterBubbleAll           bool   // Bubble the entire directory tree
 	renterDeleteRoot          bool   // Delete path start from root instead of the UserFolder.
 	renterDownloadAsync       bool   // Downloads files asynchronously
+	renterDownloadGroup       string // Download group the downloads are added to.
 	renterDownloadRecursive   bool   // Downloads folders recursively.
 	renterDownloadRoot        bool   // Download path start from root instead of the UserFolder.
 	renterFuseMountAllowOther bool   // Mount fuse with 'AllowOther' set to true.
 	renterListRecursive       bool   // List files of folder recursively.
 	renterListRoot            bool   // List path start from root instead of the UserFolder.
 	renterRenameRoot          bool   // Rename files relative to root instead of the UserFolder.
+	renterRepairRoot          bool   // Repair a file relative to root instead of the UserFolder.
+	renterRepairRetryRoot     bool   // Retry the repair of a file relative to root instead of the UserFolder.
 	renterShowHistory         bool   // Show download history in addition to download queue.
 
 	// Renter Allowance Flags
@@ -76,6 +86,7 @@ var (
 
 	// Utils Flags
 	dictionaryLanguage string // dictionary for seed utils
+	utilsDumpJSON      bool   // print the output of the dump commands as json
 
 	// Wallet Flags
 	initForce            bool   // destroy and re-encrypt the wallet on init if it already exists
@@ -293,13 +304,18 @@ func initCmds() *cobra.Command {
 	root.AddCommand(jsonCmd)
 
 	root.AddCommand(gatewayCmd)
-	gatewayCmd.AddCommand(gatewayAddressCmd, gatewayBandwidthCmd, gatewayBlocklistCmd, gatewayConnectCmd, gatewayDisconnectCmd, gatewayListCmd, gatewayRatelimitCmd)
+	gatewayCmd.AddCommand(gatewayAddressCmd, gatewayBandwidthCmd, gatewayBlacklistCmd, gatewayBlocklistCmd, gatewayConnectCmd, gatewayDisconnectCmd, gatewayListCmd, gatewayRatelimitCmd, gatewayStatsCmd)
 	gatewayBlocklistCmd.AddCommand(gatewayBlocklistAppendCmd, gatewayBlocklistClearCmd, gatewayBlocklistRemoveCmd, gatewayBlocklistSetCmd)
+	gatewayBlacklistCmd.AddCommand(gatewayBlacklistAppendCmd, gatewayBlacklistClearCmd, gatewayBlacklistRemoveCmd, gatewayBlacklistSetCmd)
+	gatewayConnectCmd.Flags().DurationVar(&gatewayConnectTimeout, "timeout", 30*time.Second, "Time to wait for the connection to be established")
 
 	root.AddCommand(hostCmd)
-	hostCmd.AddCommand(hostAnnounceCmd, hostConfigCmd, hostContractCmd, hostFolderCmd, hostSectorCmd)
+	hostCmd.AddCommand(hostAnnounceCmd, hostConfigCmd, hostContractCmd, hostFolderCmd, hostSectorCmd, hostStorageCmd)
 	hostFolderCmd.AddCommand(hostFolderAddCmd, hostFolderRemoveCmd, hostFolderResizeCmd)
 	hostSectorCmd.AddCommand(hostSectorDeleteCmd)
+	hostStorageCmd.AddCommand(hostStorageMigrateCmd)
+	hostAnnounceCmd.Flags().BoolVar(&hostAnnounceDryRun, "dry-run", false, "Run the pre-announcement checks without announcing")
+	hostAnnounceCmd.Flags().BoolVarP(&hostAnnounceForce, "force", "f", false, "Announce even if the pre-announcement checks fail")
 	hostContractCmd.Flags().StringVarP(&hostContractOutputType, "type", "t", "value", "Select output type")
 	hostFolderRemoveCmd.Flags().BoolVarP(&hostFolderRemoveForce, "force", "f", false, "Force the removal of the folder and its data")
 
@@ -311,16 +327,18 @@ func initCmds() *cobra.Command {
 	minerCmd.AddCommand(minerStartCmd, minerStopCmd)
 
 	root.AddCommand(renterCmd)
-	renterCmd.AddCommand(renterAllowanceCmd, renterBubbleCmd, renterBackupCreateCmd, renterBackupListCmd, renterBackupLoadCmd,
+	renterCmd.AddCommand(renterAccountsCmd, renterAllowanceCmd, renterAwaitGroupCmd, renterBubbleCmd, renterBackupCreateCmd, renterBackupListCmd, renterBackupLoadCmd,
 		renterCleanCmd, renterContractsCmd, renterContractsRecoveryScanProgressCmd, renterDownloadCancelCmd,
 		renterDownloadsCmd, renterExportCmd, renterFilesDeleteCmd, renterFilesDownloadCmd,
 		renterFilesListCmd, renterFilesRenameCmd, renterFilesUnstuckCmd, renterFilesUploadCmd,
 		renterFuseCmd, renterLostCmd, renterPricesCmd, renterRatelimitCmd, renterSetAllowanceCmd,
-		renterSetLocalPathCmd, renterTriggerContractRecoveryScanCmd, renterUploadsCmd, renterWorkersCmd,
-		renterHealthSummaryCmd)
-	renterWorkersCmd.AddCommand(renterWorkersAccountsCmd, renterWorkersDownloadsCmd, renterWorkersPriceTableCmd, renterWorkersReadJobsCmd, renterWorkersHasSectorJobSCmd, renterWorkersUploadsCmd, renterWorkersReadRegistryCmd, renterWorkersUpdateRegistryCmd)
+		renterSetLocalPathCmd, renterSpeedCmd, renterTriggerContractRecoveryScanCmd, renterUploadsCmd, renterWorkersCmd,
+		renterHealthSummaryCmd, renterRepairCmd, renterRepairStatusCmd)
+	renterWorkersCmd.AddCommand(renterWorkersDisableCmd, renterWorkersEnableCmd, renterWorkersAccountsCmd, renterWorkersDownloadsCmd, renterWorkersPriceTableCmd, renterWorkersReadJobsCmd, renterWorkersHasSectorJobSCmd, renterWorkersUploadsCmd, renterWorkersReadRegistryCmd, renterWorkersUpdateRegistryCmd, renterWorkersSubscriptionsCmd)
 
+	renterAccountsCmd.AddCommand(renterAccountsListCmd, renterAccountsShowCmd)
 	renterAllowanceCmd.AddCommand(renterAllowanceCancelCmd)
+	renterAwaitGroupCmd.Flags().BoolVar(&renterAwaitGroupFailFast, "fail-fast", false, "Stop waiting as soon as any download of the group failed")
 	renterBubbleCmd.Flags().BoolVarP(&renterBubbleAll, "all", "A", false, "Bubble the entire directory tree")
 	renterContractsCmd.AddCommand(renterContractsViewCmd)
 	renterFilesUploadCmd.AddCommand(renterFilesUploadPauseCmd, renterFilesUploadResumeCmd)
@@ -331,12 +349,16 @@ func initCmds() *cobra.Command {
 	renterFilesDownloadCmd.Flags().BoolVarP(&renterDownloadAsync, "async", "A", false, "Download file asynchronously")
 	renterFilesDownloadCmd.Flags().BoolVarP(&renterDownloadRecursive, "recursive", "R", false, "Download folder recursively")
 	renterFilesDownloadCmd.Flags().BoolVar(&renterDownloadRoot, "root", false, "Download files and folders from root instead of from the user home directory")
+	renterFilesDownloadCmd.Flags().StringVar(&renterDownloadGroup, "group", "", "Add the downloads to a download group which can be awaited with 'siac renter await-group'")
 	renterFilesListCmd.Flags().BoolVarP(&renterListRecursive, "recursive", "R", false, "Recursively list files and folders")
 	renterFilesListCmd.Flags().BoolVar(&renterListRoot, "root", false, "List files and folders from root instead of from the user home directory")
 	renterFilesUploadCmd.Flags().StringVar(&dataPieces, "data-pieces", "", "the number of data pieces a files should be uploaded with")
 	renterFilesUploadCmd.Flags().StringVar(&parityPieces, "parity-pieces", "", "the number of parity pieces a files should be uploaded with")
 	renterExportCmd.AddCommand(renterExportContractTxnsCmd)
 	renterFilesRenameCmd.Flags().BoolVar(&renterRenameRoot, "root", false, "Rename files relative to root instead of the user homedir")
+	renterRepairCmd.AddCommand(renterRepairRetryCmd)
+	renterRepairCmd.Flags().BoolVar(&renterRepairRoot, "root", false, "Repair a file relative to root instead of the user homedir")
+	renterRepairRetryCmd.Flags().BoolVar(&renterRepairRetryRoot, "root", false, "Retry the repair of a file relative to root instead of the user homedir")
 
 	renterSetAllowanceCmd.Flags().StringVar(&allowanceFunds, "amount", "", "amount of money in allowance, specified in currency units")
 	renterSetAllowanceCmd.Flags().StringVar(&allowancePeriod, "period", "", "period of allowance in blocks (b), hours (h), days (d) or weeks (w)")
@@ -368,10 +390,12 @@ func initCmds() *cobra.Command {
 
 	root.AddCommand(utilsCmd)
 	utilsCmd.AddCommand(bashcomplCmd, mangenCmd, utilsBruteForceSeedCmd, utilsCheckSigCmd,
-		utilsDecodeRawTxnCmd, utilsDisplayAPIPasswordCmd, utilsEncodeRawTxnCmd, utilsHastingsCmd,
-		utilsSigHashCmd, utilsUploadedsizeCmd, utilsVerifySeedCmd)
+		utilsDecodeRawTxnCmd, utilsDisplayAPIPasswordCmd, utilsDumpSiaDirCmd, utilsDumpSiaFileCmd,
+		utilsEncodeRawTxnCmd, utilsHastingsCmd, utilsSigHashCmd, utilsUploadedsizeCmd, utilsVerifySeedCmd)
 
 	utilsVerifySeedCmd.Flags().StringVarP(&dictionaryLanguage, "language", "l", "english", "which dictionary you want to use")
+	utilsDumpSiaDirCmd.Flags().BoolVarP(&utilsDumpJSON, "json", "", false, "Print the metadata as json")
+	utilsDumpSiaFileCmd.Flags().BoolVarP(&utilsDumpJSON, "json", "", false, "Print the metadata and chunks as json")
 
 	root.AddCommand(walletCmd)
 	walletCmd.AddCommand(walletAddressCmd, walletAddressesCmd, walletBalanceCmd, walletBroadcastCmd, walletChangepasswordCmd,
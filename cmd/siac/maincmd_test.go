@@ -79,6 +79,7 @@ Renter Rate limits:
 
 	connectionRefusedPattern := `Could not get consensus status: \[failed to get reader response; GET request failed; Get "?http://localhost:5555/consensus"?: dial tcp (127\.0\.0\.1|\[::1\]):5555: connect: connection refused\]`
 	siaClientVersionPattern := "siac v" + escapeRegexChars(build.NodeVersion)
+	blacklistDeprecatedPattern := escapeRegexChars(`Command "blacklist" is deprecated, use 'siac gateway blocklist' instead.`)
 
 	// Define subtests
 	// We can't test siad on default address (port) when test node has
@@ -140,6 +141,13 @@ Renter Rate limits:
 			cmdStrs:            []string{"-h"},
 			expectedOutPattern: begin + siaClientVersionPattern + nl + nl + rootCmdUsagePattern + end,
 		},
+		{
+			name:               "TestGatewayBlacklistCmdDeprecated",
+			test:               testGenericSiacCmd,
+			cmd:                root,
+			cmdStrs:            []string{"gateway", "blacklist", "-a", IPv4Addr},
+			expectedOutPattern: begin + blacklistDeprecatedPattern + nl + end,
+		},
 	}
 
 	// run tests
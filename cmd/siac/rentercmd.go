@@ -42,6 +42,27 @@ const (
 )
 
 var (
+	renterAccountsCmd = &cobra.Command{
+		Use:   "accounts",
+		Short: "View the renter's ephemeral accounts",
+		Long:  "View the balance and spending details of the renter's ephemeral accounts with its hosts.",
+		Run:   wrap(renteraccountscmd),
+	}
+
+	renterAccountsListCmd = &cobra.Command{
+		Use:   "list",
+		Short: "List the renter's ephemeral accounts",
+		Long:  "List the balance and spending details of the renter's ephemeral accounts with its hosts.",
+		Run:   wrap(renteraccountscmd),
+	}
+
+	renterAccountsShowCmd = &cobra.Command{
+		Use:   "show [hostkey]",
+		Short: "Show the details of an ephemeral account",
+		Long:  "Show the balance and spending details of the renter's ephemeral account with the given host.",
+		Run:   wrap(renteraccountsshowcmd),
+	}
+
 	renterAllowanceCancelCmd = &cobra.Command{
 		Use:   "cancel",
 		Short: "Cancel the current allowance",
@@ -124,6 +145,16 @@ the command 'siac renter lost' to see the renter's lost files.`,
 		Run:   wrap(rentercontractsviewcmd),
 	}
 
+	renterAwaitGroupCmd = &cobra.Command{
+		Use:   "await-group [group]",
+		Short: "Wait for a download group to finish",
+		Long: `Wait until all downloads of a download group have completed and print the
+group's progress in the meantime. Downloads are added to a group using the
+--group flag of 'siac renter download'. If --fail-fast is set, the command
+returns as soon as any download of the group failed.`,
+		Run: wrap(renterawaitgroupcmd),
+	}
+
 	renterDownloadsCmd = &cobra.Command{
 		Use:   "downloads",
 		Short: "View the download queue",
@@ -274,6 +305,15 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run: rentersetallowancecmd,
 	}
 
+	renterSpeedCmd = &cobra.Command{
+		Use:   "speed",
+		Short: "Display the current upload and download speed",
+		Long: `Display the current upload and download speed of the renter's workers as
+well as the total amount of data transferred since siad was started. The output
+is refreshed in place until the command is interrupted.`,
+		Run: wrap(renterspeedcmd),
+	}
+
 	renterTriggerContractRecoveryScanCmd = &cobra.Command{
 		Use:   "triggerrecoveryscan",
 		Short: "Triggers a recovery scan.",
@@ -295,6 +335,22 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run:   wrap(renterworkerscmd),
 	}
 
+	renterWorkersDisableCmd = &cobra.Command{
+		Use:   "disable [hostkey]",
+		Short: "Disable the worker for a host",
+		Long: `Disable the worker for a host. The worker is killed and won't be recreated
+until it is enabled again. The host's entry in the hostdb and the contract with
+the host are not affected.`,
+		Run: wrap(renterworkersdisablecmd),
+	}
+
+	renterWorkersEnableCmd = &cobra.Command{
+		Use:   "enable [hostkey]",
+		Short: "Enable the worker for a host",
+		Long:  "Enable a previously disabled worker for a host.",
+		Run:   wrap(renterworkersenablecmd),
+	}
+
 	renterWorkersAccountsCmd = &cobra.Command{
 		Use:   "ea",
 		Short: "View the workers' ephemeral account",
@@ -351,11 +407,47 @@ have a reasonable number (>30) of hosts in your hostdb.`,
 		Run:   wrap(renterworkersupdateregistrycmd),
 	}
 
+	renterWorkersSubscriptionsCmd = &cobra.Command{
+		Use:   "sub",
+		Short: "View the workers' registry subscriptions",
+		Long:  "View detailed information of the workers' registry subscription sessions",
+		Run:   wrap(renterworkerssubscriptionscmd),
+	}
+
 	renterHealthSummaryCmd = &cobra.Command{
 		Use:   "health",
 		Short: "Display a health summary of uploaded files",
-		Long:  "Display a health summary of uploaded files",
-		Run:   wrap(renterhealthsummarycmd),
+		Long: `Display a health summary of uploaded files. The summary includes the
+distribution of the file health, the number of stuck chunks and an estimate
+of the time it takes to fully repair all files at the current repair rate.`,
+		Run: wrap(renterhealthsummarycmd),
+	}
+
+	renterRepairCmd = &cobra.Command{
+		Use:   "repair [path]",
+		Short: "Immediately repair a file",
+		Long: `Immediately add the chunks of the file at [path] which need repair to the
+upload heap with priority. Use the subcommands to manage the repair of the
+renter's files.`,
+		Run: wrap(renterrepaircmd),
+	}
+
+	renterRepairRetryCmd = &cobra.Command{
+		Use:   "retry [path]",
+		Short: "Retry the repair of a file's abandoned chunks",
+		Long: `Chunks which couldn't be repaired after too many attempts are abandoned and
+are no longer repaired by the renter. Retry marks the abandoned chunks of the
+file at [path] as stuck again and resets their failed repair attempts.`,
+		Run: wrap(renterrepairretrycmd),
+	}
+
+	renterRepairStatusCmd = &cobra.Command{
+		Use:   "repairstatus",
+		Short: "Display what the repair loop is currently doing",
+		Long: `Display the phase of the repair loop, the directories at the top of the
+directory heap, a summary of the upload heap and the chunks which are currently
+being repaired.`,
+		Run: wrap(renterrepairstatuscmd),
 	}
 
 	renterLostCmd = &cobra.Command{
@@ -414,6 +506,9 @@ func rentercmd() {
 	}
 
 	fmt.Println()
+	if !rg.SyncStatus.Synced {
+		fmt.Printf("Sync:           %v\n\n", rg.SyncStatus)
+	}
 	fmt.Printf(`Allowance:`)
 	if rg.Settings.Allowance.Funds.IsZero() {
 		fmt.Printf("      0 SC (No current allowance)\n")
@@ -500,7 +595,97 @@ func renterlostcmd() {
 func renterhealthsummarycmd() {
 	// Print out file health summary for the renter
 	dirs := getDir(modules.RootSiaPath(), true, true)
-	renterFileHealthSummary(dirs)
+	rg, err := httpClient.RenterGet()
+	if err != nil {
+		die("Could not get renter info:", err)
+	}
+	renterFileHealthSummary(dirs, rg.AvgRepairRate)
+}
+
+// renterrepaircmd is the handler for the command `siac renter repair [path]`.
+// It immediately adds the chunks of a file which need repair to the upload
+// heap.
+func renterrepaircmd(path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	err = httpClient.RenterRepairPost(siaPath, renterRepairRoot)
+	if err != nil {
+		die("Could not repair the file:", err)
+	}
+	fmt.Printf("Chunks of %s were added to the repair queue\n", path)
+}
+
+// renterrepairretrycmd is the handler for the command `siac renter repair
+// retry [path]`. It resets the abandoned chunks of a file.
+func renterrepairretrycmd(path string) {
+	siaPath, err := modules.NewSiaPath(path)
+	if err != nil {
+		die("Couldn't parse SiaPath:", err)
+	}
+	err = httpClient.RenterResetFileAbandonedPost(siaPath, renterRepairRetryRoot)
+	if err != nil {
+		die("Could not retry the repair of the file:", err)
+	}
+	fmt.Printf("Abandoned chunks of %s will be repaired again\n", path)
+}
+
+// renterrepairstatuscmd is the handler for the command `siac renter
+// repairstatus`. It prints the state of the renter's repair loop.
+func renterrepairstatuscmd() {
+	rs, err := httpClient.RenterRepairStatusGet()
+	if err != nil {
+		die("Could not get repair status:", err)
+	}
+
+	phase := string(rs.Phase)
+	if rs.BlockedReason != "" {
+		phase = fmt.Sprintf("%v (%v)", rs.Phase, rs.BlockedReason)
+	}
+	lastCycle := "never"
+	if !rs.LastCompletedCycle.IsZero() {
+		lastCycle = fmt.Sprintf("%v ago", time.Since(rs.LastCompletedCycle).Round(time.Second))
+	}
+	fmt.Println("Repair Loop")
+	fmt.Printf(`  Phase:                 %v
+  In Phase For:          %v
+  Last Completed Cycle:  %v
+
+`, phase, time.Since(rs.PhaseStart).Round(time.Second), lastCycle)
+
+	uh := rs.UploadHeap
+	fmt.Println("Upload Heap")
+	fmt.Printf(`  Queued Chunks:     %v
+  Stuck:             %v
+  Unstuck:           %v
+  Repairing Chunks:  %v
+
+`, uh.Len, uh.Stuck, uh.Unstuck, uh.Repairing)
+
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	if len(rs.Directories) == 0 {
+		fmt.Fprintln(w, "No directories in the directory heap.")
+	} else {
+		fmt.Fprintln(w, "Directory Heap")
+		fmt.Fprintln(w, "  Health\tRemote\tExplored\tSiaPath")
+		for _, d := range rs.Directories {
+			fmt.Fprintf(w, "  %.2f%%\t%v\t%v\t%v\n", modules.HealthPercentage(d.Health), d.Remote, d.Explored, d.SiaPath)
+		}
+	}
+	fmt.Fprintln(w)
+	if len(rs.RepairingChunks) == 0 {
+		fmt.Fprintln(w, "No chunks are being repaired.")
+	} else {
+		fmt.Fprintln(w, "Repairing Chunks")
+		fmt.Fprintln(w, "  Health\tStuck\tPieces\tChunk\tSiaPath")
+		for _, c := range rs.RepairingChunks {
+			fmt.Fprintf(w, "  %.2f%%\t%v\t%v/%v\t%v\t%v\n", modules.HealthPercentage(c.Health), c.Stuck, c.PiecesCompleted, c.PiecesNeeded, c.Index, c.SiaPath)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
 }
 
 // renteruploadscmd is the handler for the command `siac renter uploads`.
@@ -577,6 +762,115 @@ func renterdownloadscmd() {
 	}
 }
 
+// renteraccountscmd is the handler for the command `siac renter accounts`. It
+// prints the balance and spending details of the renter's ephemeral accounts.
+func renteraccountscmd() {
+	rag, err := httpClient.RenterAccountsGet()
+	if err != nil {
+		die("Could not get accounts:", err)
+	}
+	if len(rag.Accounts) == 0 {
+		fmt.Println("No accounts.")
+		return
+	}
+
+	// Sum up the balances and spending of all accounts.
+	var totalBalance, totalSpent types.Currency
+	for _, acc := range rag.Accounts {
+		totalBalance = totalBalance.Add(acc.AvailableBalance)
+		totalSpent = totalSpent.Add(acc.Spending.Total())
+	}
+	fmt.Println("Accounts Summary")
+	fmt.Printf(`  Number of Accounts:       %v
+  Total Available Balance:  %v
+  Total Spent:              %v
+
+`, len(rag.Accounts), currencyUnits(totalBalance), currencyUnits(totalSpent))
+
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Host PubKey\tAvailBal\tBalance\tNegBal\tPendingDeposits\tPendingWithdrawals\tDownloads\tUploads\tRegistry\tSnapshots\tSubscriptions\tSpent")
+	for _, acc := range rag.Accounts {
+		s := acc.Spending
+		fmt.Fprintf(w, "%v\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			acc.HostKey.String(),
+			currencyUnits(acc.AvailableBalance),
+			currencyUnits(acc.Balance),
+			currencyUnits(acc.NegativeBalance),
+			currencyUnits(acc.PendingDeposits),
+			currencyUnits(acc.PendingWithdrawals),
+			currencyUnits(s.Downloads.Add(s.RepairDownloads)),
+			currencyUnits(s.Uploads.Add(s.RepairUploads)),
+			currencyUnits(s.RegistryReads.Add(s.RegistryWrites)),
+			currencyUnits(s.SnapshotDownloads.Add(s.SnapshotUploads)),
+			currencyUnits(s.Subscriptions),
+			currencyUnits(s.Total()))
+	}
+	if err := w.Flush(); err != nil {
+		die("failed to flush writer:", err)
+	}
+}
+
+// renteraccountsshowcmd is the handler for the command `siac renter accounts
+// show [hostkey]`. It prints the details of the renter's ephemeral account
+// with the given host.
+func renteraccountsshowcmd(hostKey string) {
+	var spk types.SiaPublicKey
+	err := spk.LoadString(hostKey)
+	if err != nil {
+		die("Could not parse host key:", err)
+	}
+	acc, err := httpClient.RenterAccountGet(spk)
+	if err != nil {
+		die("Could not get account:", err)
+	}
+
+	s := acc.Spending
+	fmt.Printf(`Account:
+  Account ID:                     %v
+  Host PubKey:                    %v
+  First Seen:                     %v
+  Recovered From Dirty Shutdown:  %v
+
+Balance:
+  Available Balance:              %v
+  Balance:                        %v
+  Negative Balance:               %v
+  Pending Deposits:               %v
+  Pending Withdrawals:            %v
+  Positive Drift Per Day:         %v
+  Negative Drift Per Day:         %v
+
+Spending:
+  Downloads:                      %v
+  Repair Downloads:               %v
+  Uploads:                        %v
+  Repair Uploads:                 %v
+  Registry Reads:                 %v
+  Registry Writes:                %v
+  Snapshot Downloads:             %v
+  Snapshot Uploads:               %v
+  Subscriptions:                  %v
+  Total:                          %v
+`, acc.AccountID, acc.HostKey.String(), sanitizeTime(acc.FirstSeen, !acc.FirstSeen.IsZero()), acc.RecoveredFromDirtyShutdown,
+		currencyUnits(acc.AvailableBalance),
+		currencyUnits(acc.Balance),
+		currencyUnits(acc.NegativeBalance),
+		currencyUnits(acc.PendingDeposits),
+		currencyUnits(acc.PendingWithdrawals),
+		currencyUnits(acc.BalanceDriftPositivePerDay),
+		currencyUnits(acc.BalanceDriftNegativePerDay),
+		currencyUnits(s.Downloads),
+		currencyUnits(s.RepairDownloads),
+		currencyUnits(s.Uploads),
+		currencyUnits(s.RepairUploads),
+		currencyUnits(s.RegistryReads),
+		currencyUnits(s.RegistryWrites),
+		currencyUnits(s.SnapshotDownloads),
+		currencyUnits(s.SnapshotUploads),
+		currencyUnits(s.Subscriptions),
+		currencyUnits(s.Total()))
+}
+
 // renterallowancecmd is the handler for the command `siac renter allowance`.
 // displays the current allowance.
 func renterallowancecmd() {
@@ -1570,6 +1864,31 @@ func renterdirdownload(path, destination string) {
 	os.Exit(1)
 }
 
+// renterawaitgroupcmd is the handler for the command `siac renter await-group
+// [group]`. It waits for the download group to finish and exits with an error
+// if any of its downloads failed.
+func renterawaitgroupcmd(group string) {
+	for {
+		dgi, err := httpClient.RenterDownloadGroupAwaitGet(group, renterAwaitGroupFailFast, RenterAwaitGroupRefreshRate)
+		if err != nil {
+			die("Could not get download group:", err)
+		}
+		fmt.Printf("Downloaded %v/%v files (%v/%v), %v failed\n", dgi.FilesCompleted, dgi.FilesTotal,
+			modules.FilesizeUnits(dgi.BytesReceived), modules.FilesizeUnits(dgi.BytesTotal), dgi.FilesFailed)
+		if !dgi.Finished && !(renterAwaitGroupFailFast && dgi.FilesFailed > 0) {
+			continue
+		}
+		if dgi.FilesFailed > 0 {
+			for _, e := range dgi.Errors {
+				fmt.Println("Download failed:", e)
+			}
+			die(fmt.Sprintf("%v downloads of group '%v' failed", dgi.FilesFailed, group))
+		}
+		fmt.Printf("Download group '%v' finished\n", group)
+		return
+	}
+}
+
 // renterdownloadcancelcmd is the handler for the command `siac renter download cancel [cancelID]`
 // Cancels the ongoing download.
 func renterdownloadcancelcmd(cancelID modules.DownloadID) {
@@ -2191,6 +2510,30 @@ func renterratelimitcmd(downloadSpeedStr, uploadSpeedStr string) {
 	fmt.Println("Set renter maxdownloadspeed to ", downloadSpeedInt, " and maxuploadspeed to ", uploadSpeedInt)
 }
 
+// renterspeedcmd is the handler for the command `siac renter speed`. It
+// periodically prints the current upload and download speed of the renter,
+// overwriting the previous output.
+func renterspeedcmd() {
+	var lastLen int
+	for range time.Tick(OutputRefreshRate) {
+		rb, err := httpClient.RenterBandwidthGet()
+		if err != nil {
+			fmt.Println()
+			die("Could not get renter bandwidth:", err)
+		}
+		line := fmt.Sprintf("Upload: %v (%v total)  Download: %v (%v total)",
+			ratelimitUnits(int64(rb.UploadRate)), modules.FilesizeUnits(rb.TotalUploaded),
+			ratelimitUnits(int64(rb.DownloadRate)), modules.FilesizeUnits(rb.TotalDownloaded))
+		// Pad the line to overwrite any leftovers of a longer previous line.
+		padding := ""
+		if len(line) < lastLen {
+			padding = strings.Repeat(" ", lastLen-len(line))
+		}
+		lastLen = len(line)
+		fmt.Printf("\r%v%v", line, padding)
+	}
+}
+
 // renterworkerscmd is the handler for the command `siac renter workers`.
 // It lists the Renter's workers.
 func renterworkerscmd() {
@@ -2204,6 +2547,21 @@ func renterworkerscmd() {
 		return rw.Workers[i].HostPubKey.String() < rw.Workers[j].HostPubKey.String()
 	})
 
+	// Count the workers with a stale price table, an unaffordable host or an
+	// active subscription session.
+	var stalePriceTables, unaffordableHosts, activeSubscriptions int
+	for _, worker := range rw.Workers {
+		if worker.HostSettingsStatus.StalePriceTable {
+			stalePriceTables++
+		}
+		if !worker.HostSettingsStatus.Affordable {
+			unaffordableHosts++
+		}
+		if worker.SubscriptionStatus.Active {
+			activeSubscriptions++
+		}
+	}
+
 	// Print Worker Pool Summary
 	fmt.Println("Worker Pool Summary")
 	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
@@ -2211,6 +2569,10 @@ func renterworkerscmd() {
 	fmt.Fprintf(w, "  Workers On Download Cooldown:\t%v\n", rw.TotalDownloadCoolDown)
 	fmt.Fprintf(w, "  Workers On Upload Cooldown:\t%v\n", rw.TotalUploadCoolDown)
 	fmt.Fprintf(w, "  Workers On Maintenance Cooldown:\t%v\n", rw.TotalMaintenanceCoolDown)
+	fmt.Fprintf(w, "  Workers With Stale Price Table:\t%v\n", stalePriceTables)
+	fmt.Fprintf(w, "  Workers With Unaffordable Host:\t%v\n", unaffordableHosts)
+	fmt.Fprintf(w, "  Workers With Subscription Session:\t%v\n", activeSubscriptions)
+	fmt.Fprintf(w, "  Disabled Workers:\t%v\n", len(rw.DisabledWorkers))
 	if err := w.Flush(); err != nil {
 		die("failed to flush writer:", err)
 	}
@@ -2240,6 +2602,44 @@ func renterworkerscmd() {
 	} else {
 		writeWorkers(notGoodForUpload)
 	}
+
+	// List out disabled workers
+	if len(rw.DisabledWorkers) > 0 {
+		fmt.Println("\nDisabled Workers:")
+		for _, hostKey := range rw.DisabledWorkers {
+			fmt.Println("  " + hostKey.String())
+		}
+	}
+}
+
+// renterworkersdisablecmd is the handler for the command `siac renter workers
+// disable [hostkey]`. It disables the worker for a host.
+func renterworkersdisablecmd(hostKey string) {
+	var spk types.SiaPublicKey
+	err := spk.LoadString(hostKey)
+	if err != nil {
+		die("Could not parse host key:", err)
+	}
+	err = httpClient.RenterWorkersDisablePost(spk)
+	if err != nil {
+		die("Could not disable worker:", err)
+	}
+	fmt.Println("Disabled worker for host", spk.String())
+}
+
+// renterworkersenablecmd is the handler for the command `siac renter workers
+// enable [hostkey]`. It enables a previously disabled worker for a host.
+func renterworkersenablecmd(hostKey string) {
+	var spk types.SiaPublicKey
+	err := spk.LoadString(hostKey)
+	if err != nil {
+		die("Could not parse host key:", err)
+	}
+	err = httpClient.RenterWorkersEnablePost(spk)
+	if err != nil {
+		die("Could not enable worker:", err)
+	}
+	fmt.Println("Enabled worker for host", spk.String())
 }
 
 // renterworkerseacmd is the handler for the command `siac renter workers ea`.
@@ -2278,7 +2678,7 @@ func renterworkerseacmd() {
 
 	// print header
 	hostInfo := "Host PubKey"
-	accountInfo := "\tAvailBal\tNegBal\tTargetBal"
+	accountInfo := "\tAvailBal\tNegBal\tTargetBal\tRefillTarget\tRefillSize"
 	errorInfo := "\tSucceededAt\tErrorAt\tError"
 	header := hostInfo + accountInfo + errorInfo
 	fmt.Fprintln(w, "\nWorker Accounts Detail  \n\n"+header)
@@ -2291,10 +2691,12 @@ func renterworkerseacmd() {
 		fmt.Fprintf(w, "%v", worker.HostPubKey.String())
 
 		// Account Info
-		fmt.Fprintf(w, "\t%s\t%s\t%s",
+		fmt.Fprintf(w, "\t%s\t%s\t%s\t%s\t%s",
 			as.AvailableBalance.HumanString(),
 			as.NegativeBalance.HumanString(),
-			worker.AccountBalanceTarget.HumanString())
+			worker.AccountBalanceTarget.HumanString(),
+			as.RefillTarget.HumanString(),
+			as.RefillSize.HumanString())
 
 		// Error Info
 		fmt.Fprintf(w, "\t%v\t%v\t%v\n",
@@ -2511,16 +2913,18 @@ func writeWorkers(workers []modules.WorkerStatus) {
 	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
 	contractHeader := "Worker Contract\t \t \t "
 	contractInfo := "Host PubKey\tContract ID\tGood For Renew\tGood For Upload"
-	downloadHeader := "\tWorker Downloads\t "
-	downloadInfo := "\tOn Cooldown\tQueue"
-	uploadHeader := "\tWorker Uploads\t "
-	uploadInfo := "\tOn Cooldown\tQueue"
+	downloadHeader := "\tWorker Downloads\t \t "
+	downloadInfo := "\tOn Cooldown\tQueue\tSpeed"
+	uploadHeader := "\tWorker Uploads\t \t "
+	uploadInfo := "\tOn Cooldown\tQueue\tSpeed"
 	maintenanceHeader := "\tWorker Maintenance\t \t "
 	maintenanceInfo := "\tOn Cooldown\tCooldown Time\tLast Error"
+	hostHeader := "\tWorker Host\t \t "
+	hostInfo := "\tVersion\tStale Price Table\tAffordable"
 	jobHeader := "\tWorker Jobs\t \t "
 	jobInfo := "\tHas Sector\tRead Sector\tSnapshot UL\tSnapshot DL"
-	fmt.Fprintln(w, "\n  "+contractHeader+downloadHeader+uploadHeader+maintenanceHeader+jobHeader)
-	fmt.Fprintln(w, "  "+contractInfo+downloadInfo+uploadInfo+maintenanceInfo+jobInfo)
+	fmt.Fprintln(w, "\n  "+contractHeader+downloadHeader+uploadHeader+maintenanceHeader+hostHeader+jobHeader)
+	fmt.Fprintln(w, "  "+contractInfo+downloadInfo+uploadInfo+maintenanceInfo+hostInfo+jobInfo)
 
 	for _, worker := range workers {
 		// Contract Info
@@ -2531,14 +2935,16 @@ func writeWorkers(workers []modules.WorkerStatus) {
 			worker.ContractUtility.GoodForUpload)
 
 		// Download Info
-		fmt.Fprintf(w, "\t%v\t%v",
+		fmt.Fprintf(w, "\t%v\t%v\t%v",
 			worker.DownloadOnCoolDown,
-			worker.DownloadQueueSize)
+			worker.DownloadQueueSize,
+			bandwidthUnit(uint64(worker.DownloadSpeedBytesPerSec*8)))
 
 		// Upload Info
-		fmt.Fprintf(w, "\t%v\t%v",
+		fmt.Fprintf(w, "\t%v\t%v\t%v",
 			worker.UploadOnCoolDown,
-			worker.UploadQueueSize)
+			worker.UploadQueueSize,
+			bandwidthUnit(uint64(worker.UploadSpeedBytesPerSec*8)))
 
 		// Maintenance Info
 		fmt.Fprintf(w, "\t%t\t%v\t%v",
@@ -2546,6 +2952,12 @@ func writeWorkers(workers []modules.WorkerStatus) {
 			worker.MaintenanceCoolDownTime,
 			sanitizeErr(worker.MaintenanceCoolDownError))
 
+		// Host Info
+		fmt.Fprintf(w, "\t%v\t%t\t%t",
+			worker.Version,
+			worker.HostSettingsStatus.StalePriceTable,
+			worker.HostSettingsStatus.Affordable)
+
 		// Job Info
 		fmt.Fprintf(w, "\t%v\t%v\t%v\t%v\n",
 			worker.HasSectorJobsStatus.JobQueueSize,
@@ -2608,3 +3020,73 @@ func renterworkersupdateregistrycmd() {
 	// Write Upload Info
 	writeWorkerReadUpdateRegistryInfo(false, w, rw)
 }
+
+// renterworkerssubscriptionscmd is the handler for the command `siac renter
+// workers sub`. It lists the status of the registry subscription session of
+// every worker.
+func renterworkerssubscriptionscmd() {
+	rw, err := httpClient.RenterWorkersGet()
+	if err != nil {
+		die("Could not get worker statuses:", err)
+	}
+
+	// Sort workers by public key.
+	sort.Slice(rw.Workers, func(i, j int) bool {
+		return rw.Workers[i].HostPubKey.String() < rw.Workers[j].HostPubKey.String()
+	})
+
+	// Collect some overall subscription stats.
+	var activeSessions, numSubscriptions uint64
+	var spent types.Currency
+	for _, worker := range rw.Workers {
+		status := worker.SubscriptionStatus
+		if status.Active {
+			activeSessions++
+		}
+		numSubscriptions += status.NumSubscriptions
+		spent = spent.Add(status.Spent)
+	}
+	fmt.Println("Worker Subscriptions Summary")
+
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	defer func() {
+		err := w.Flush()
+		if err != nil {
+			die("Could not flush tabwriter:", err)
+		}
+	}()
+
+	// print summary
+	fmt.Fprintf(w, "Total Workers: \t%v\n", rw.NumWorkers)
+	fmt.Fprintf(w, "Active Sessions: \t%v\n", activeSessions)
+	fmt.Fprintf(w, "Active Subscriptions: \t%v\n", numSubscriptions)
+	fmt.Fprintf(w, "Spent In Active Sessions: \t%v\n", currencyUnits(spent))
+
+	// print header
+	hostInfo := "Host PubKey"
+	sessionInfo := "\tActive\tSubscriptions\tBudget\tSpent\tDeadline In"
+	errorInfo := "\tErrorAt\tError"
+	header := hostInfo + sessionInfo + errorInfo
+	fmt.Fprintln(w, "\nWorker Subscriptions Detail  \n\n"+header)
+
+	// print rows
+	for _, worker := range rw.Workers {
+		status := worker.SubscriptionStatus
+
+		// Host Info
+		fmt.Fprintf(w, "%v", worker.HostPubKey.String())
+
+		// Session Info
+		fmt.Fprintf(w, "\t%t\t%v\t%v\t%v\t%v",
+			status.Active,
+			status.NumSubscriptions,
+			currencyUnits(status.BudgetRemaining),
+			currencyUnits(status.Spent),
+			absDuration(status.TimeUntilDeadline))
+
+		// Error Info
+		fmt.Fprintf(w, "\t%v\t%v\n",
+			sanitizeTime(status.RecentErrTime, status.RecentErr != ""),
+			sanitizeErr(status.RecentErr))
+	}
+}
@@ -125,7 +125,7 @@ func downloadDir(siaPath modules.SiaPath, destination string) (tfs []trackedFile
 		}
 		// Download file.
 		totalSize += file.Filesize
-		_, err = httpClient.RenterDownloadFullGet(file.SiaPath, dst, true, true)
+		_, err = httpClient.RenterDownloadGroupGet(file.SiaPath, dst, renterDownloadGroup, true, true)
 		if err != nil {
 			err = errors.AddContext(err, "Failed to start download")
 			return
@@ -393,6 +393,7 @@ Contract %v
   Remaining Funds:      %v
 
   File Size: %v
+  Sectors:   %v / %v (%.2f%%)
 `, rc.ID, rc.NetAddress, rc.HostPublicKey.String(), rc.HostVersion, rc.StartHeight, rc.EndHeight,
 				currencyUnits(rc.TotalCost), currencyUnits(rc.Fees),
 				currencyUnits(fundsAllocated),
@@ -402,7 +403,11 @@ Contract %v
 				currencyUnits(rc.FundAccountSpending),
 				currencyUnits(rc.MaintenanceSpending.Sum()),
 				currencyUnits(rc.RenterFunds),
-				modules.FilesizeUnits(rc.Size))
+				modules.FilesizeUnits(rc.Size),
+				rc.NumRoots, rc.MaxRoots, rc.Utilization)
+			if rc.UtilizationWarning != "" {
+				fmt.Println("  Warning:", rc.UtilizationWarning)
+			}
 
 			printScoreBreakdown(&hostInfo)
 			return nil
@@ -527,7 +532,7 @@ func renterFilesDownload(path, destination string) {
 	// the call will return before the download has completed. The call is made
 	// as an async call.
 	start := time.Now()
-	cancelID, err := httpClient.RenterDownloadFullGet(siaPath, destination, true, true)
+	cancelID, err := httpClient.RenterDownloadGroupGet(siaPath, destination, renterDownloadGroup, true, true)
 	if err != nil {
 		die("Download could not be started:", err)
 	}
@@ -555,7 +560,7 @@ func renterFilesDownload(path, destination string) {
 
 // renterFileHealthSummary prints out a summary of the status of all the files
 // in the renter to track the progress of the files
-func renterFileHealthSummary(dirs []directoryInfo) {
+func renterFileHealthSummary(dirs []directoryInfo, repairRate uint64) {
 	percentages, numStuck, err := fileHealthBreakdown(dirs, false)
 	if err != nil {
 		die(err)
@@ -563,8 +568,25 @@ func renterFileHealthSummary(dirs []directoryInfo) {
 
 	percentages = parsePercentages(percentages)
 
+	// The first directory is the root of the tree and holds the aggregate
+	// values.
+	var numAbandoned, numFiles, numStuckChunks, repairSize uint64
+	if len(dirs) > 0 {
+		numAbandoned = dirs[0].dir.AggregateNumAbandonedChunks
+		numFiles = dirs[0].dir.AggregateNumFiles
+		numStuckChunks = dirs[0].dir.AggregateNumStuckChunks
+		repairSize = dirs[0].dir.AggregateRepairSize
+	}
+
+	// Estimate the time it takes to repair all files at the current rate.
+	repairTime := "N/A"
+	if d, ok := estimatedRepairTime(repairSize, repairRate); ok {
+		repairTime = d.String()
+	}
+
 	fmt.Println("File Health Summary")
 	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "  Total Files\t%v\n", numFiles)
 	fmt.Fprintf(w, "  %% At 100%%\t%v%%\n", percentages[0])
 	fmt.Fprintf(w, "  %% Between 75%% - 100%%\t%v%%\n", percentages[1])
 	fmt.Fprintf(w, "  %% Between 50%% - 75%%\t%v%%\n", percentages[2])
@@ -572,17 +594,40 @@ func renterFileHealthSummary(dirs []directoryInfo) {
 	fmt.Fprintf(w, "  %% Between 0%% - 25%%\t%v%%\n", percentages[4])
 	fmt.Fprintf(w, "  %% Unrecoverable\t%v%%\n", percentages[5])
 	fmt.Fprintf(w, "  Number of Stuck Files\t%v\n", numStuck)
+	fmt.Fprintf(w, "  Number of Abandoned Chunks\t%v\n", numAbandoned)
+	fmt.Fprintf(w, "  Number of Stuck Chunks\t%v\n", numStuckChunks)
+	fmt.Fprintf(w, "  Repair Size Remaining\t%v\n", modules.FilesizeUnits(repairSize))
+	fmt.Fprintf(w, "  Avg Repair Rate\t%v/s\n", modules.FilesizeUnits(repairRate))
+	fmt.Fprintf(w, "  Estimated Time to Full Repair\t%v\n", repairTime)
 	if err := w.Flush(); err != nil {
 		die("failed to flush writer:", err)
 	}
 }
 
+// estimatedRepairTime returns the estimated time it takes to repair repairSize
+// bytes at a rate of repairRate bytes per second. If there is nothing to
+// repair the estimate is 0. If there is data to repair but the rate is 0, no
+// estimate can be made and false is returned.
+func estimatedRepairTime(repairSize, repairRate uint64) (time.Duration, bool) {
+	if repairSize == 0 {
+		return 0, true
+	}
+	if repairRate == 0 {
+		return 0, false
+	}
+	secs := repairSize / repairRate
+	if repairSize%repairRate != 0 {
+		secs++
+	}
+	return time.Duration(secs) * time.Second, true
+}
+
 // writeContracts is a helper function to display contracts
 func writeContracts(contracts []api.RenterContract) {
 	fmt.Println("  Number of Contracts:", len(contracts))
 	sort.Sort(byValue(contracts))
 	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "  \nHost\tHost PubKey\tHost Version\tRemaining Funds\tSpent Funds\tSpent Fees\tData\tEnd Height\tContract ID\tGoodForUpload\tGoodForRenew\tBadContract")
+	fmt.Fprintln(w, "  \nHost\tHost PubKey\tHost Version\tRemaining Funds\tSpent Funds\tSpent Fees\tData\tUtilization\tEnd Height\tContract ID\tGoodForUpload\tGoodForRenew\tBadContract")
 	for _, c := range contracts {
 		address := c.NetAddress
 		hostVersion := c.HostVersion
@@ -597,7 +642,7 @@ func writeContracts(contracts []api.RenterContract) {
 		} else {
 			contractTotalSpent = c.TotalCost.Sub(c.RenterFunds).Sub(c.Fees)
 		}
-		fmt.Fprintf(w, "  %v\t%v\t%v\t%8s\t%8s\t%8s\t%v\t%v\t%v\t%v\t%v\t%v\n",
+		fmt.Fprintf(w, "  %v\t%v\t%v\t%8s\t%8s\t%8s\t%v\t%.2f%%\t%v\t%v\t%v\t%v\t%v\n",
 			address,
 			c.HostPublicKey.String(),
 			hostVersion,
@@ -605,6 +650,7 @@ func writeContracts(contracts []api.RenterContract) {
 			currencyUnits(contractTotalSpent),
 			currencyUnits(c.Fees),
 			modules.FilesizeUnits(c.Size),
+			c.Utilization,
 			c.EndHeight,
 			c.ID,
 			c.GoodForUpload,
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
+	"github.com/spf13/cobra"
+	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node/api"
+	"go.sia.tech/siad/siatest"
 	"go.sia.tech/siad/types"
 )
 
@@ -90,6 +96,28 @@ func TestContractInfo(t *testing.T) {
 	printContractInfo(contract.ID.String(), []api.RenterContract{contract})
 }
 
+// TestEstimatedRepairTime is a unit test for estimatedRepairTime.
+func TestEstimatedRepairTime(t *testing.T) {
+	tests := []struct {
+		size, rate uint64
+		d          time.Duration
+		ok         bool
+	}{
+		{0, 0, 0, true},
+		{0, 100, 0, true},
+		{100, 0, 0, false},
+		{100, 100, time.Second, true},
+		{101, 100, 2 * time.Second, true},
+		{modules.SectorSize * 60, modules.SectorSize, time.Minute, true},
+	}
+	for _, test := range tests {
+		d, ok := estimatedRepairTime(test.size, test.rate)
+		if d != test.d || ok != test.ok {
+			t.Errorf("estimatedRepairTime(%v, %v): expected (%v, %v) got (%v, %v)", test.size, test.rate, test.d, test.ok, d, ok)
+		}
+	}
+}
+
 // fileContractID is a helper function for generating a FileContractID for
 // testing
 func fileContractID() types.FileContractID {
@@ -99,3 +127,197 @@ func fileContractID() types.FileContractID {
 	h.Sum(id[:0])
 	return id
 }
+
+// TestRenterAccountsCmd tests the output of the `siac renter accounts`
+// commands both without accounts and with accounts.
+func TestRenterAccountsCmd(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	// Create a test node without contracts.
+	groupDir := siacTestDir(t.Name())
+	n, err := newTestNode(groupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := n.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a group with a renter that has accounts with its hosts.
+	gp := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(siatest.TestDir("cmd/siac", t.Name()+"Group"), gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := tg.Renters()[0]
+
+	// Wait for the accounts to be funded.
+	var hostKey types.SiaPublicKey
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rag, err := r.RenterAccountsGet()
+		if err != nil {
+			return err
+		}
+		if len(rag.Accounts) != gp.Hosts {
+			return fmt.Errorf("expected %v accounts but got %v", gp.Hosts, len(rag.Accounts))
+		}
+		for _, acc := range rag.Accounts {
+			if acc.AvailableBalance.IsZero() {
+				return errors.New("account not funded yet")
+			}
+		}
+		hostKey = rag.Accounts[0].HostKey
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// define test constants:
+	// Regular expressions to check siac output
+	begin := "^"
+	nl := `
+` // platform agnostic new line
+	end := "$"
+	currency := `\d+(\.\d+|) (pS|nS|uS|mS|SC|KS|MS|GS|TS|H)`
+	hostKeyPattern := `ed25519:[0-9a-f]{64}`
+
+	noAccountsPattern := "No accounts."
+	listPattern := `Accounts Summary
+  Number of Accounts:       2
+  Total Available Balance:  ` + currency + `
+  Total Spent:              ` + currency + `
+
+Host PubKey +AvailBal +Balance +NegBal +PendingDeposits +PendingWithdrawals +Downloads +Uploads +Registry +Snapshots +Subscriptions +Spent
+(` + hostKeyPattern + `( +` + currency + `){11}
+){2}`
+	showPattern := `Account:
+  Account ID:                     ` + hostKeyPattern + `
+  Host PubKey:                    ` + escapeRegexChars(hostKey.String()) + `
+  First Seen:                     \S+
+  Recovered From Dirty Shutdown:  false
+
+Balance:
+  Available Balance:              ` + currency + `
+  Balance:                        ` + currency + `
+  Negative Balance:               ` + currency + `
+  Pending Deposits:               ` + currency + `
+  Pending Withdrawals:            ` + currency + `
+  Positive Drift Per Day:         ` + currency + `
+  Negative Drift Per Day:         ` + currency + `
+
+Spending:
+  Downloads:                      ` + currency + `
+  Repair Downloads:               ` + currency + `
+  Uploads:                        ` + currency + `
+  Repair Uploads:                 ` + currency + `
+  Registry Reads:                 ` + currency + `
+  Registry Writes:                ` + currency + `
+  Snapshot Downloads:             ` + currency + `
+  Snapshot Uploads:               ` + currency + `
+  Subscriptions:                  ` + currency + `
+  Total:                          ` + currency
+
+	// Define subtests
+	subTests := []struct {
+		name               string
+		address            string
+		cmd                *cobra.Command
+		args               []string
+		expectedOutPattern string
+	}{
+		{"TestAccountsNoAccounts", n.Address, renterAccountsCmd, nil, begin + noAccountsPattern + nl + end},
+		{"TestAccountsListNoAccounts", n.Address, renterAccountsListCmd, nil, begin + noAccountsPattern + nl + end},
+		{"TestAccounts", r.Address, renterAccountsCmd, nil, begin + listPattern + end},
+		{"TestAccountsList", r.Address, renterAccountsListCmd, nil, begin + listPattern + end},
+		{"TestAccountsShow", r.Address, renterAccountsShowCmd, []string{hostKey.String()}, begin + showPattern + nl + end},
+	}
+
+	// run tests
+	for _, test := range subTests {
+		output := executeSiacHandler(t, test.address, "", test.cmd, test.args...)
+		if !regexp.MustCompile(test.expectedOutPattern).MatchString(output) {
+			t.Fatalf("%v: output doesn't match the expected pattern\noutput: %q\npattern: %q", test.name, output, test.expectedOutPattern)
+		}
+	}
+}
+
+// TestRenterRepairRetryCmd tests the output of the `siac renter repair retry`
+// command.
+func TestRenterRepairRetryCmd(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+
+	// Create a group with a renter that can upload a file.
+	groupDir := siacTestDir(t.Name())
+	gp := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(groupDir, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := tg.Renters()[0]
+
+	// Upload a file.
+	_, rf, err := r.UploadNewFile(100, 1, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	siaPath := rf.SiaPath().String()
+	rootSiaPath, err := modules.UserFolder.Join(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// define test constants:
+	// Regular expressions to check siac output
+	begin := "^"
+	nl := `
+` // platform agnostic new line
+	end := "$"
+
+	// Retry the repair of the file relative to the user folder.
+	output := executeSiacHandler(t, r.Address, r.Password, renterRepairRetryCmd, siaPath)
+	expected := begin + "Abandoned chunks of " + escapeRegexChars(siaPath) + " will be repaired again" + nl + end
+	if !regexp.MustCompile(expected).MatchString(output) {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	// Retry the repair of the file relative to root.
+	renterRepairRetryRoot = true
+	output = executeSiacHandler(t, r.Address, r.Password, renterRepairRetryCmd, rootSiaPath.String())
+	renterRepairRetryRoot = false
+	expected = begin + "Abandoned chunks of " + escapeRegexChars(rootSiaPath.String()) + " will be repaired again" + nl + end
+	if !regexp.MustCompile(expected).MatchString(output) {
+		t.Fatalf("unexpected output %q", output)
+	}
+
+	// Retrying the repair of an unknown file should fail.
+	output = executeSiacHandler(t, r.Address, r.Password, renterRepairRetryCmd, "unknown")
+	expected = begin + "Could not retry the repair of the file: .+" + nl + end
+	if !regexp.MustCompile(expected).MatchString(output) {
+		t.Fatalf("unexpected output %q", output)
+	}
+}
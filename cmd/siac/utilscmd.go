@@ -4,22 +4,28 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/cobra/doc"
 	mnemonics "gitlab.com/NebulousLabs/entropy-mnemonics"
 
 	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/siatest"
 	"go.sia.tech/siad/types"
 )
@@ -125,6 +131,28 @@ seed and returns a valid 28 or 29 word seed`,
 		Run: wrap(utilsbruteforceseedcmd),
 	}
 
+	utilsDumpSiaDirCmd = &cobra.Command{
+		Use:   "dump-siadir [path]",
+		Short: "print the metadata of a .siadir file",
+		Long: `Parse the .siadir file at the given path and print its metadata. The
+path may either point to the .siadir file or the directory containing it.
+The file is only read and never repaired, which makes this command safe to
+use while siad is running.`,
+		Run: wrap(utilsdumpsiadircmd),
+	}
+
+	utilsDumpSiaFileCmd = &cobra.Command{
+		Use:   "dump-siafile [path]",
+		Short: "print the metadata and chunks of a .sia file",
+		Long: `Parse the .sia file at the given path and print its header, host table
+and a summary of its chunks. Parse errors are printed together with the byte
+offset at which they occurred. The health of the chunks is computed assuming
+that all hosts are online and good for renew since the contracts of the
+renter are not known. Partial chunks are stored in a separate file and are
+therefore not parsed.`,
+		Run: wrap(utilsdumpsiafilecmd),
+	}
+
 	utilsUploadedsizeCmd = &cobra.Command{
 		Use:   "uploadedsize [path]",
 		Short: "calculate a folder's size on Sia",
@@ -397,3 +425,232 @@ Files: %v
 			modules.FilesizeUnits(calculateMedianUint64(fileSizes)))
 	}
 }
+
+// siaFileDump is the output of `siac utils dump-siafile`.
+type siaFileDump struct {
+	Path     string                 `json:"path"`
+	Metadata *siafile.Metadata      `json:"metadata,omitempty"`
+	HostKeys []string               `json:"hostkeys,omitempty"`
+	Chunks   []siafile.ChunkSummary `json:"chunks,omitempty"`
+	Errors   []string               `json:"errors,omitempty"`
+}
+
+// siaDirDump is the output of `siac utils dump-siadir`.
+type siaDirDump struct {
+	Path     string           `json:"path"`
+	Metadata *siadir.Metadata `json:"metadata,omitempty"`
+	Errors   []string         `json:"errors,omitempty"`
+}
+
+// utilsdumpsiafilecmd is the handler for the command `siac utils
+// dump-siafile`.
+// prints the header and a summary of the chunks of a siafile.
+func utilsdumpsiafilecmd(path string) {
+	if err := writeSiaFileDump(os.Stdout, path, utilsDumpJSON); err != nil {
+		die(err)
+	}
+}
+
+// utilsdumpsiadircmd is the handler for the command `siac utils
+// dump-siadir`.
+// prints the metadata of a siadir.
+func utilsdumpsiadircmd(path string) {
+	if err := writeSiaDirDump(os.Stdout, path, utilsDumpJSON); err != nil {
+		die(err)
+	}
+}
+
+// loadSiaFileDump parses the siafile at path in read-only mode. Errors are
+// added to the dump instead of being returned to allow for printing as much
+// information as possible about corrupted files.
+func loadSiaFileDump(path string) siaFileDump {
+	dump := siaFileDump{Path: path}
+	sf, err := siafile.LoadSiaFileReadonly(path)
+	if err != nil {
+		dump.Errors = append(dump.Errors, err.Error())
+		return dump
+	}
+	md := sf.Metadata()
+	// Never print the keys of the file.
+	md.StaticMasterKey = nil
+	md.StaticSharingKey = nil
+	dump.Metadata = &md
+	for _, spk := range sf.HostPublicKeys() {
+		dump.HostKeys = append(dump.HostKeys, spk.String())
+	}
+	dump.Chunks, err = sf.ChunkSummaries()
+	if err != nil {
+		dump.Errors = append(dump.Errors, err.Error())
+	}
+	for _, chunk := range dump.Chunks {
+		if chunk.Err != "" {
+			dump.Errors = append(dump.Errors, fmt.Sprintf("chunk %v: %v", chunk.Index, chunk.Err))
+		}
+	}
+	return dump
+}
+
+// writeSiaFileDump writes the dump of the siafile at path to w. If the file
+// couldn't be parsed completely, an error is returned after writing the dump.
+func writeSiaFileDump(w io.Writer, path string, asJSON bool) error {
+	dump := loadSiaFileDump(path)
+	if asJSON {
+		if err := writeDumpJSON(w, dump); err != nil {
+			return err
+		}
+	} else {
+		writeSiaFileDumpHuman(w, dump)
+	}
+	if len(dump.Errors) > 0 {
+		return fmt.Errorf("found %v error(s) while parsing %v", len(dump.Errors), path)
+	}
+	return nil
+}
+
+// writeSiaFileDumpHuman writes the human readable version of a siafile dump
+// to w.
+func writeSiaFileDumpHuman(w io.Writer, dump siaFileDump) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Path:\t%v\n", dump.Path)
+	if md := dump.Metadata; md != nil {
+		ecType := md.StaticErasureCodeType
+		ecParams := md.StaticErasureCodeParams
+		dataPieces := binary.LittleEndian.Uint32(ecParams[:4])
+		parityPieces := binary.LittleEndian.Uint32(ecParams[4:])
+		fmt.Fprintf(tw, "UID:\t%v\n", md.UniqueID)
+		fmt.Fprintf(tw, "File Size:\t%v bytes\n", md.FileSize)
+		fmt.Fprintf(tw, "Piece Size:\t%v bytes\n", md.StaticPieceSize)
+		fmt.Fprintf(tw, "Pages Per Chunk:\t%v\n", md.StaticPagesPerChunk)
+		fmt.Fprintf(tw, "Erasure Code:\t%x %v-of-%v\n", ecType[:], dataPieces, dataPieces+parityPieces)
+		fmt.Fprintf(tw, "Cipher Type:\t%v\n", md.StaticMasterKeyType)
+		fmt.Fprintf(tw, "Local Path:\t%v\n", md.LocalPath)
+		fmt.Fprintf(tw, "Mode:\t%v\n", md.Mode)
+		fmt.Fprintf(tw, "Create Time:\t%v\n", md.CreateTime)
+		fmt.Fprintf(tw, "Mod Time:\t%v\n", md.ModTime)
+		fmt.Fprintf(tw, "Change Time:\t%v\n", md.ChangeTime)
+		fmt.Fprintf(tw, "Access Time:\t%v\n", md.AccessTime)
+		fmt.Fprintf(tw, "Last Health Check:\t%v\n", md.LastHealthCheckTime)
+		fmt.Fprintf(tw, "Health:\t%v\n", md.Health)
+		fmt.Fprintf(tw, "Stuck Health:\t%v\n", md.StuckHealth)
+		fmt.Fprintf(tw, "Cached Health:\t%v\n", md.CachedHealth)
+		fmt.Fprintf(tw, "Cached Stuck Health:\t%v\n", md.CachedStuckHealth)
+		fmt.Fprintf(tw, "Cached Redundancy:\t%v\n", md.CachedRedundancy)
+		fmt.Fprintf(tw, "Cached Upload Progress:\t%.2f%%\n", md.CachedUploadProgress)
+		fmt.Fprintf(tw, "Stuck Chunks:\t%v\n", md.NumStuckChunks)
+		fmt.Fprintf(tw, "Partial Chunks:\t%v\n", len(md.PartialChunks))
+		fmt.Fprintf(tw, "PubKeyTable Offset:\t%v\n", md.PubKeyTableOffset)
+		fmt.Fprintf(tw, "Chunk Offset:\t%v\n", md.ChunkOffset)
+	}
+	if err := tw.Flush(); err != nil {
+		die("Could not flush tabwriter:", err)
+	}
+
+	if dump.Metadata != nil {
+		fmt.Fprintf(w, "\nHosts: %v\n", len(dump.HostKeys))
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		for i, key := range dump.HostKeys {
+			fmt.Fprintf(tw, "  %v\t%v\n", i, key)
+		}
+		if err := tw.Flush(); err != nil {
+			die("Could not flush tabwriter:", err)
+		}
+
+		fmt.Fprintf(w, "\nChunks: %v\n", len(dump.Chunks))
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintf(tw, "  Index\tOffset\tPieces\tUnique Pieces\tHealth\tStuck\tPartial\n")
+		for _, chunk := range dump.Chunks {
+			fmt.Fprintf(tw, "  %v\t%v\t%v\t%v\t%v\t%v\t%v\n", chunk.Index, chunk.Offset, chunk.NumPieces, chunk.UniquePieces, chunk.Health, chunk.Stuck, chunk.Partial)
+		}
+		if err := tw.Flush(); err != nil {
+			die("Could not flush tabwriter:", err)
+		}
+	}
+
+	if len(dump.Errors) > 0 {
+		fmt.Fprintf(w, "\nErrors: %v\n", len(dump.Errors))
+		for _, err := range dump.Errors {
+			fmt.Fprintf(w, "  %v\n", err)
+		}
+	}
+}
+
+// loadSiaDirDump parses the siadir metadata at path without trying to repair
+// it. The path can either be the .siadir file or the directory containing it.
+func loadSiaDirDump(path string) siaDirDump {
+	if filepath.Base(path) != modules.SiaDirExtension {
+		path = filepath.Join(path, modules.SiaDirExtension)
+	}
+	dump := siaDirDump{Path: path}
+	md, err := siadir.LoadSiaDirMetadata(path)
+	if err != nil {
+		dump.Errors = append(dump.Errors, err.Error())
+		return dump
+	}
+	dump.Metadata = &md
+	return dump
+}
+
+// writeSiaDirDump writes the dump of the siadir at path to w. If the metadata
+// couldn't be parsed, an error is returned after writing the dump.
+func writeSiaDirDump(w io.Writer, path string, asJSON bool) error {
+	dump := loadSiaDirDump(path)
+	if asJSON {
+		if err := writeDumpJSON(w, dump); err != nil {
+			return err
+		}
+	} else {
+		writeSiaDirDumpHuman(w, dump)
+	}
+	if len(dump.Errors) > 0 {
+		return fmt.Errorf("found %v error(s) while parsing %v", len(dump.Errors), dump.Path)
+	}
+	return nil
+}
+
+// writeSiaDirDumpHuman writes the human readable version of a siadir dump to
+// w.
+func writeSiaDirDumpHuman(w io.Writer, dump siaDirDump) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Path:\t%v\n", dump.Path)
+	if md := dump.Metadata; md != nil {
+		fmt.Fprintf(tw, "Version:\t%v\n", md.Version)
+		fmt.Fprintf(tw, "Mode:\t%v\n", md.Mode)
+		fmt.Fprintf(tw, "Mod Time:\t%v\n", md.ModTime)
+		fmt.Fprintf(tw, "Last Health Check:\t%v\n", md.LastHealthCheckTime)
+		fmt.Fprintf(tw, "Health:\t%v\n", md.Health)
+		fmt.Fprintf(tw, "Stuck Health:\t%v\n", md.StuckHealth)
+		fmt.Fprintf(tw, "Remote Health:\t%v\n", md.RemoteHealth)
+		fmt.Fprintf(tw, "Min Redundancy:\t%v\n", md.MinRedundancy)
+		fmt.Fprintf(tw, "Files:\t%v\n", md.NumFiles)
+		fmt.Fprintf(tw, "Sub Dirs:\t%v\n", md.NumSubDirs)
+		fmt.Fprintf(tw, "Size:\t%v bytes\n", md.Size)
+		fmt.Fprintf(tw, "Stuck Chunks:\t%v\n", md.NumStuckChunks)
+		fmt.Fprintf(tw, "Aggregate Health:\t%v\n", md.AggregateHealth)
+		fmt.Fprintf(tw, "Aggregate Stuck Health:\t%v\n", md.AggregateStuckHealth)
+		fmt.Fprintf(tw, "Aggregate Remote Health:\t%v\n", md.AggregateRemoteHealth)
+		fmt.Fprintf(tw, "Aggregate Min Redundancy:\t%v\n", md.AggregateMinRedundancy)
+		fmt.Fprintf(tw, "Aggregate Files:\t%v\n", md.AggregateNumFiles)
+		fmt.Fprintf(tw, "Aggregate Sub Dirs:\t%v\n", md.AggregateNumSubDirs)
+		fmt.Fprintf(tw, "Aggregate Size:\t%v bytes\n", md.AggregateSize)
+		fmt.Fprintf(tw, "Aggregate Stuck Chunks:\t%v\n", md.AggregateNumStuckChunks)
+	}
+	if err := tw.Flush(); err != nil {
+		die("Could not flush tabwriter:", err)
+	}
+	if len(dump.Errors) > 0 {
+		fmt.Fprintf(w, "\nErrors: %v\n", len(dump.Errors))
+		for _, err := range dump.Errors {
+			fmt.Fprintf(w, "  %v\n", err)
+		}
+	}
+}
+
+// writeDumpJSON writes the json encoding of a dump to w.
+func writeDumpJSON(w io.Writer, dump interface{}) error {
+	js, err := json.MarshalIndent(dump, "", "\t")
+	if err != nil {
+		return errors.AddContext(err, "could not marshal the json output")
+	}
+	_, err = fmt.Fprintln(w, string(js))
+	return err
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/writeaheadlog"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/types"
+)
+
+// dumpVariableFields matches the lines of the human readable dumps which
+// change from run to run.
+var dumpVariableFields = regexp.MustCompile(`(?m)^(Path|UID|Create Time|Mod Time|Change Time|Access Time|Last Health Check):(\s+).*$`)
+
+// updateGolden rewrites the golden files of the dump tests with the current
+// output instead of comparing the output against them.
+var updateGolden = flag.Bool("update", false, "update the golden files of the dump tests")
+
+// checkGolden compares the output of a dump with the golden file of the given
+// name in the testdata directory.
+func checkGolden(t *testing.T, name, output string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.MkdirAll("testdata", modules.DefaultDirPerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(output), modules.DefaultFilePerm); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output != string(expected) {
+		t.Fatalf("unexpected output\n%v\nexpected\n%v", output, string(expected))
+	}
+}
+
+// normalizeDump replaces the fields of a dump which change from run to run
+// with a placeholder.
+func normalizeDump(dump string) string {
+	return dumpVariableFields.ReplaceAllString(dump, "$1:$2<$1>")
+}
+
+// TestDumpSiaFile tests dumping a siafile created by the siafile package.
+func TestDumpSiaFile(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a siafile with 2 chunks.
+	dir := build.TempDir("siac", t.Name())
+	if err := os.MkdirAll(dir, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	_, wal, err := writeaheadlog.New(filepath.Join(dir, "wal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wal.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rc, err := modules.NewRSCode(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sk := crypto.GenerateSiaKey(crypto.TypeThreefish)
+	chunkSize := modules.SectorSize - sk.Type().Overhead()
+	path := filepath.Join(dir, "file"+modules.SiaFileExtension)
+	sf, err := siafile.New(path, "/local/file", wal, rc, sk, 2*chunkSize, 0600, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload all pieces of the first chunk and one piece of the second one
+	// which is marked as stuck.
+	for i := 0; i < 3; i++ {
+		spk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: bytes.Repeat([]byte{byte(i)}, crypto.PublicKeySize)}
+		if err := sf.AddPiece(spk, 0, uint64(i), crypto.Hash{byte(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	spk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: bytes.Repeat([]byte{0}, crypto.PublicKeySize)}
+	if err := sf.AddPiece(spk, 1, 0, crypto.Hash{3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.SetStuck(1, true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the human readable output.
+	var buf bytes.Buffer
+	if err := writeSiaFileDump(&buf, path, false); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "siafiledump", normalizeDump(buf.String()))
+
+	// Check the json output.
+	buf.Reset()
+	if err := writeSiaFileDump(&buf, path, true); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "masterkey\": \"") {
+		t.Fatal("json output contains the masterkey", buf.String())
+	}
+	var dump siaFileDump
+	if err := json.Unmarshal(buf.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump.Metadata == nil || dump.Metadata.UniqueID != sf.UID() {
+		t.Fatal("wrong metadata", dump.Metadata)
+	}
+	if len(dump.HostKeys) != 3 || len(dump.Chunks) != 2 || len(dump.Errors) != 0 {
+		t.Fatal("wrong dump", dump)
+	}
+	if c := dump.Chunks[1]; c.NumPieces != 1 || !c.Stuck || c.Pieces[0].MerkleRoot != (crypto.Hash{3}) {
+		t.Fatal("wrong chunk", c)
+	}
+
+	// Corrupt the first piece of the second chunk by setting its piece index
+	// to an invalid value. The first chunk should still be parsed.
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{255, 255, 255, 255}, 8192+19); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeSiaFileDump(&buf, path, false); err == nil {
+		t.Fatal("expected error")
+	}
+	checkGolden(t, "siafiledump_corruptchunk", normalizeDump(buf.String()))
+
+	// Corrupt the metadata.
+	if _, err := f.WriteAt([]byte("x"), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeSiaFileDump(&buf, path, false); err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(buf.String(), "failed to decode metadata at byte offset 2") {
+		t.Fatal("corruption wasn't reported", buf.String())
+	}
+}
+
+// TestDumpSiaDir tests dumping a siadir created by the siadir package.
+func TestDumpSiaDir(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	root := build.TempDir("siac", t.Name())
+	dir := filepath.Join(root, "dir")
+	if _, err := siadir.New(dir, root, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the human readable output.
+	var buf bytes.Buffer
+	if err := writeSiaDirDump(&buf, dir, false); err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "siadirdump", normalizeDump(buf.String()))
+
+	// Check the json output. Passing the path of the .siadir file should
+	// work as well.
+	mdPath := filepath.Join(dir, modules.SiaDirExtension)
+	buf.Reset()
+	if err := writeSiaDirDump(&buf, mdPath, true); err != nil {
+		t.Fatal(err)
+	}
+	var dump siaDirDump
+	if err := json.Unmarshal(buf.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump.Path != mdPath || dump.Metadata == nil || dump.Metadata.Version != "1.0" {
+		t.Fatal("wrong dump", dump)
+	}
+
+	// Corrupt the checksum.
+	f, err := os.OpenFile(mdPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{0}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeSiaDirDump(&buf, dir, false); err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.Contains(buf.String(), "checksum at byte offset 0") {
+		t.Fatal("corruption wasn't reported", buf.String())
+	}
+}
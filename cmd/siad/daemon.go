@@ -263,6 +263,19 @@ func startDaemonCmd(cmd *cobra.Command, _ []string) {
 		die(errors.AddContext(err, "failed to parse input parameter"))
 	}
 
+	// Run the self-test instead of starting siad if requested.
+	if config.Siad.SelfTest {
+		report, err := runSelfTest(config)
+		if err != nil {
+			die(errors.AddContext(err, "failed to run the self-test"))
+		}
+		printSelfTestReport(report)
+		if !report.Passed {
+			os.Exit(exitCodeGeneral)
+		}
+		return
+	}
+
 	// Parse profile flags
 	profileCPU := strings.Contains(config.Siad.Profile, "c")
 	profileMem := strings.Contains(config.Siad.Profile, "m")
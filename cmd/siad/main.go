@@ -45,6 +45,8 @@ type Config struct {
 		RequiredUserAgent string
 		AuthenticateAPI   bool
 		TempPassword      bool
+		SelfTest          bool
+		VerifyMetadata    bool
 
 		Profile    string
 		ProfileDir string
@@ -203,6 +205,8 @@ func main() {
 	root.Flags().StringVarP(&globalConfig.Siad.Modules, "modules", "M", "gctwrhfa", "enabled modules, see 'siad modules' for more info")
 	root.Flags().BoolVarP(&globalConfig.Siad.AuthenticateAPI, "authenticate-api", "", true, "enable API password protection")
 	root.Flags().BoolVarP(&globalConfig.Siad.TempPassword, "temp-password", "", false, "enter a temporary API password during startup")
+	root.Flags().BoolVarP(&globalConfig.Siad.SelfTest, "self-test", "", false, "check the environment siad would run in and exit")
+	root.Flags().BoolVarP(&globalConfig.Siad.VerifyMetadata, "verify-metadata", "", false, "verify the host's on-disk sector metadata during startup")
 	root.Flags().BoolVarP(&globalConfig.Siad.AllowAPIBind, "disable-api-security", "", false, "allow siad to listen on a non-localhost address (DANGEROUS)")
 
 	// If globalConfig.Siad.SiaDir is not set, use the environment variable provided.
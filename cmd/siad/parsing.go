@@ -45,6 +45,7 @@ func parseModules(config Config) node.NodeParams {
 	params.Bootstrap = !config.Siad.NoBootstrap
 	params.UseUPNP = config.Siad.UseUPNP
 	params.HostAddress = config.Siad.HostAddr
+	params.HostVerifyMetadata = config.Siad.VerifyMetadata
 	params.RPCAddress = config.Siad.RPCaddr
 	params.SiaMuxTCPAddress = config.Siad.SiaMuxTCPAddr
 	params.SiaMuxWSAddress = config.Siad.SiaMuxWSAddr
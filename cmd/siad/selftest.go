@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/node"
+)
+
+// runSelfTest checks the environment siad would run in with the provided
+// config. The directories, ports and clock are checked first. If none of
+// these checks fail, the modules are loaded to check their requirements, like
+// the disk space needed by the host's storage folders, before they are closed
+// again.
+func runSelfTest(config Config) (modules.SelfTestReport, error) {
+	params := parseModules(config)
+	params.Bootstrap = false
+	params.UseUPNP = false
+	addrs := append(params.SelfTestAddresses(), config.Siad.APIaddr)
+	report := node.SelfTest(params.SelfTestRequirements(), addrs, 0, false)
+	if !report.Passed {
+		return report, nil
+	}
+
+	fmt.Println("Loading the modules to check their requirements...")
+	n, errChan := node.New(params, time.Now())
+	if err := <-errChan; err != nil {
+		return modules.SelfTestReport{}, errors.AddContext(err, "unable to load the modules")
+	}
+	nodeReport := n.SelfTest()
+	if err := n.Close(); err != nil {
+		return modules.SelfTestReport{}, errors.AddContext(err, "unable to close the modules")
+	}
+
+	// The node checks the persist directories again and compares the clock to
+	// the blockchain so only the port checks of the first report are kept.
+	for _, result := range report.Results {
+		if result.Check == modules.SelfTestCheckPort {
+			nodeReport.Results = append(nodeReport.Results, result)
+		}
+	}
+	return nodeReport, nil
+}
+
+// printSelfTestReport prints the results of the self-test and the remediation
+// hints of the checks which didn't pass.
+func printSelfTestReport(report modules.SelfTestReport) {
+	w := tabwriter.NewWriter(os.Stdout, 2, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Status\tCheck\tTarget\tMessage")
+	for _, result := range report.Results {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\n", strings.ToUpper(string(result.Status)), result.Check, result.Target, result.Message)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Println("failed to flush writer:", err)
+	}
+
+	var hints []string
+	for _, result := range report.Results {
+		if result.Remediation != "" {
+			hints = append(hints, fmt.Sprintf("  %v (%v): %v", result.Check, result.Target, result.Remediation))
+		}
+	}
+	if len(hints) > 0 {
+		fmt.Println()
+		fmt.Println("Remediation:")
+		fmt.Println(strings.Join(hints, "\n"))
+	}
+	fmt.Println()
+	if report.Passed {
+		fmt.Println("Self-test passed.")
+	} else {
+		fmt.Println("Self-test failed.")
+	}
+}
@@ -2,6 +2,8 @@ package crypto
 
 import (
 	"bytes"
+	"errors"
+	"io"
 
 	"gitlab.com/NebulousLabs/merkletree/merkletree-blake"
 
@@ -18,6 +20,12 @@ const (
 	SegmentSize = 64
 )
 
+var (
+	// ErrInvalidProofRange is returned when a range proof is built or verified
+	// for an invalid range of segments.
+	ErrInvalidProofRange = errors.New("invalid proof range")
+)
+
 // MerkleTree wraps merkletree.Tree, changing some of the function definitions
 // to assume sia-specific constants and return sia-specific types.
 type MerkleTree struct {
@@ -167,24 +175,50 @@ func VerifySegment(base []byte, hashSet []Hash, numSegments, proofIndex uint64,
 //
 // MerkleRangeProof for a single segment is NOT equivalent to MerkleProof.
 func MerkleRangeProof(b []byte, start, end int) []Hash {
-	proof, _ := merkletree.BuildRangeProof(start, end, merkletree.NewReaderSubtreeHasher(bytes.NewReader(b), SegmentSize))
+	proof, _ := MerkleRangeProofFromReader(bytes.NewReader(b), start, end)
+	return proof
+}
+
+// MerkleRangeProofFromReader builds a Merkle proof for the segment range
+// [start,end) of the data read from r. The data is hashed one segment at a
+// time while it is read, so the memory used doesn't depend on the size of the
+// data.
+func MerkleRangeProofFromReader(r io.Reader, start, end int) ([]Hash, error) {
+	if start < 0 || start > end {
+		return nil, ErrInvalidProofRange
+	}
+	proof, err := merkletree.BuildRangeProof(start, end, merkletree.NewReaderSubtreeHasher(r, SegmentSize))
+	if err != nil {
+		return nil, err
+	}
 	proofHashes := make([]Hash, len(proof))
 	for i := range proofHashes {
 		proofHashes[i] = Hash(proof[i])
 	}
-	return proofHashes
+	return proofHashes, nil
 }
 
 // VerifyRangeProof verifies a proof produced by MerkleRangeProof.
 //
 // VerifyRangeProof for a single segment is NOT equivalent to VerifySegment.
 func VerifyRangeProof(segments []byte, proof []Hash, start, end int, root Hash) bool {
+	result, _ := VerifyRangeProofFromReader(bytes.NewReader(segments), proof, start, end, root)
+	return result
+}
+
+// VerifyRangeProofFromReader verifies a proof produced by MerkleRangeProof or
+// MerkleRangeProofFromReader. The segments of the range [start,end) are read
+// from r and hashed one at a time, so the memory used doesn't depend on the
+// size of the range.
+func VerifyRangeProofFromReader(r io.Reader, proof []Hash, start, end int, root Hash) (bool, error) {
+	if start < 0 || start > end {
+		return false, ErrInvalidProofRange
+	}
 	proofBytes := make([][32]byte, len(proof))
 	for i := range proof {
 		proofBytes[i] = [32]byte(proof[i])
 	}
-	result, _ := merkletree.VerifyRangeProof(merkletree.NewReaderLeafHasher(bytes.NewReader(segments), SegmentSize), start, end, proofBytes, [32]byte(root))
-	return result
+	return merkletree.VerifyRangeProof(merkletree.NewReaderLeafHasher(r, SegmentSize), start, end, proofBytes, [32]byte(root))
 }
 
 // MerkleSectorRangeProof builds a Merkle proof for the sector range [start,end).
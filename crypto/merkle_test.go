@@ -1,6 +1,9 @@
 package crypto
 
 import (
+	"bytes"
+	"errors"
+	"io"
 	"testing"
 
 	"gitlab.com/NebulousLabs/fastrand"
@@ -93,6 +96,181 @@ func TestRangeProof(t *testing.T) {
 	}
 }
 
+// TestRangeProofFromReader tests building and verifying range proofs over
+// readers for random ranges of random data.
+func TestRangeProofFromReader(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		numSegments := fastrand.Intn(1000) + 1
+		data := fastrand.Bytes(numSegments * SegmentSize)
+		root := MerkleRoot(data)
+		start := fastrand.Intn(numSegments)
+		end := start + fastrand.Intn(numSegments-start) + 1
+		proofData := data[start*SegmentSize : end*SegmentSize]
+
+		// The proof should match the one built from memory.
+		proof, err := MerkleRangeProofFromReader(bytes.NewReader(data), start, end)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := MerkleRangeProof(data, start, end)
+		if len(proof) != len(expected) {
+			t.Fatal("proofs have different lengths")
+		}
+		for j := range expected {
+			if proof[j] != expected[j] {
+				t.Fatal("proofs don't match")
+			}
+		}
+
+		// The proof should verify.
+		valid, err := VerifyRangeProofFromReader(bytes.NewReader(proofData), proof, start, end, root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !valid {
+			t.Fatalf("Proof %v-%v did not pass verification", start, end)
+		}
+	}
+}
+
+// TestRangeProofFromReaderAdversarial tests that manipulated range proofs
+// and data don't pass verification.
+func TestRangeProofFromReaderAdversarial(t *testing.T) {
+	numSegments := 64
+	data := fastrand.Bytes(numSegments * SegmentSize)
+	root := MerkleRoot(data)
+	start, end := 10, 20
+	proofData := data[start*SegmentSize : end*SegmentSize]
+	proof, err := MerkleRangeProofFromReader(bytes.NewReader(data), start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verify is a helper that returns whether a proof passes verification.
+	verify := func(segments []byte, proof []Hash, start, end int, root Hash) bool {
+		valid, err := VerifyRangeProofFromReader(bytes.NewReader(segments), proof, start, end, root)
+		return err == nil && valid
+	}
+	if !verify(proofData, proof, start, end, root) {
+		t.Fatal("valid proof should pass verification")
+	}
+
+	// Manipulate every hash of the proof.
+	for i := range proof {
+		badProof := append([]Hash{}, proof...)
+		fastrand.Read(badProof[i][:])
+		if verify(proofData, badProof, start, end, root) {
+			t.Fatal("proof with manipulated hash passed verification", i)
+		}
+	}
+
+	// Manipulate the data.
+	badData := append([]byte{}, proofData...)
+	badData[fastrand.Intn(len(badData))]++
+	if verify(badData, proof, start, end, root) {
+		t.Fatal("manipulated data passed verification")
+	}
+
+	// Drop the last segment of the data.
+	if verify(proofData[:len(proofData)-SegmentSize], proof, start, end, root) {
+		t.Fatal("truncated data passed verification")
+	}
+
+	// Add a hash to the proof and remove one from it.
+	if verify(proofData, append(proof, Hash{}), start, end, root) {
+		t.Fatal("proof with extra hash passed verification")
+	}
+	if verify(proofData, proof[:len(proof)-1], start, end, root) {
+		t.Fatal("proof with missing hash passed verification")
+	}
+
+	// Use a shifted range and a different root.
+	if verify(proofData, proof, start+1, end+1, root) {
+		t.Fatal("proof for shifted range passed verification")
+	}
+	if verify(proofData, proof, start, end, HashBytes(root[:])) {
+		t.Fatal("proof for different root passed verification")
+	}
+
+	// Invalid ranges should return an error.
+	_, err = MerkleRangeProofFromReader(bytes.NewReader(data), end, start)
+	if !errors.Is(err, ErrInvalidProofRange) {
+		t.Fatal("expected ErrInvalidProofRange but got", err)
+	}
+	_, err = VerifyRangeProofFromReader(bytes.NewReader(proofData), proof, -1, end, root)
+	if !errors.Is(err, ErrInvalidProofRange) {
+		t.Fatal("expected ErrInvalidProofRange but got", err)
+	}
+
+	// Building a proof for a range beyond the end of the data should fail.
+	_, err = MerkleRangeProofFromReader(bytes.NewReader(data), start, numSegments+1)
+	if err == nil {
+		t.Fatal("expected building a proof beyond the end of the data to fail")
+	}
+}
+
+// TestRangeProofFromReaderError tests that read errors are returned.
+func TestRangeProofFromReaderError(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(bytes.NewReader(fastrand.Bytes(SegmentSize)), &errReader{errRead})
+	_, err := MerkleRangeProofFromReader(r, 0, 2)
+	if !errors.Is(err, errRead) {
+		t.Fatal("expected read error but got", err)
+	}
+	r = io.MultiReader(bytes.NewReader(fastrand.Bytes(SegmentSize)), &errReader{errRead})
+	_, err = VerifyRangeProofFromReader(r, nil, 0, 2, Hash{})
+	if !errors.Is(err, errRead) {
+		t.Fatal("expected read error but got", err)
+	}
+}
+
+// errReader is a reader that always returns an error.
+type errReader struct {
+	err error
+}
+
+// Read implements io.Reader.
+func (r *errReader) Read([]byte) (int, error) {
+	return 0, r.err
+}
+
+// BenchmarkMerkleRangeProofFromReader benchmarks building a range proof for a
+// single segment of a 4 MiB sector.
+func BenchmarkMerkleRangeProofFromReader(b *testing.B) {
+	data := fastrand.Bytes(1 << 22)
+	numSegments := len(data) / SegmentSize
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := fastrand.Intn(numSegments)
+		_, err := MerkleRangeProofFromReader(bytes.NewReader(data), start, start+1)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkVerifyRangeProofFromReader benchmarks verifying a range proof for
+// half of a 4 MiB sector.
+func BenchmarkVerifyRangeProofFromReader(b *testing.B) {
+	data := fastrand.Bytes(1 << 22)
+	root := MerkleRoot(data)
+	start, end := 0, len(data)/SegmentSize/2
+	proofData := data[start*SegmentSize : end*SegmentSize]
+	proof, err := MerkleRangeProofFromReader(bytes.NewReader(data), start, end)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(int64(len(proofData)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		valid, err := VerifyRangeProofFromReader(bytes.NewReader(proofData), proof, start, end, root)
+		if err != nil || !valid {
+			b.Fatal("verification failed", err)
+		}
+	}
+}
+
 // TestCachedTree tests the cached tree functions of the package.
 func TestCachedTree(t *testing.T) {
 	if testing.Short() {
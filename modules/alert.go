@@ -56,6 +56,16 @@ const (
 	// registered if the host has insufficient collateral budget left to form or
 	// renew a contract
 	AlertIDHostInsufficientCollateral = "host-insufficient-collateral"
+	// AlertIDHostCollateralBudgetUsage is the id of the alert that is
+	// registered if the host has locked most of its collateral budget in
+	// storage obligations
+	AlertIDHostCollateralBudgetUsage = "host-collateral-budget-usage"
+	// AlertIDRenterFailedFileCloses is the id of the alert that is registered
+	// if the renter failed to close the file entries of chunks.
+	AlertIDRenterFailedFileCloses = "renter-failed-file-closes"
+	// AlertIDRenterSlowPersistDisk is the id of the alert that is registered
+	// if the writes to the renter's persist directory are slow.
+	AlertIDRenterSlowPersistDisk = "renter-slow-persist-disk"
 )
 
 // AlertIDSiafileLowRedundancy uses a Siafile's UID to create a unique AlertID
@@ -229,6 +229,10 @@ type (
 		// Height returns the current height of consensus.
 		Height() types.BlockHeight
 
+		// EstimatedHeight returns the estimated height of the blockchain based
+		// on the genesis timestamp and the block frequency.
+		EstimatedHeight() types.BlockHeight
+
 		// Synced returns true if the consensus set is synced with the network.
 		Synced() bool
 
@@ -185,6 +185,9 @@ func consensusSetAsyncStartup(cs *ConsensusSet, bootstrap bool) error {
 	}
 
 	// Mark that we are synced with the network.
+	if cs.staticDeps.Disrupt("ConsensusNotSynced") {
+		return nil
+	}
 	cs.mu.Lock()
 	cs.synced = true
 	cs.mu.Unlock()
@@ -346,6 +349,22 @@ func (cs *ConsensusSet) Height() (height types.BlockHeight) {
 	return height
 }
 
+// EstimatedHeight returns the estimated height of the blockchain. The estimate
+// is based on the time that passed since the genesis block and the target block
+// frequency. It is never lower than the current height of the consensus set.
+func (cs *ConsensusSet) EstimatedHeight() types.BlockHeight {
+	height := cs.Height()
+	now := types.CurrentTimestamp()
+	if now <= types.GenesisTimestamp {
+		return height
+	}
+	estimated := types.BlockHeight(now-types.GenesisTimestamp) / types.BlockFrequency
+	if estimated < height {
+		return height
+	}
+	return estimated
+}
+
 // InCurrentPath returns true if the block presented is in the current path,
 // false otherwise.
 func (cs *ConsensusSet) InCurrentPath(id types.BlockID) (inPath bool) {
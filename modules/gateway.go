@@ -114,6 +114,14 @@ type (
 		Version    string     `json:"version"`
 	}
 
+	// PeerBandwidth contains the number of bytes sent to and received from a
+	// peer since the connection to the peer was established.
+	PeerBandwidth struct {
+		NetAddress    NetAddress `json:"netaddress"`
+		BytesSent     uint64     `json:"bytessent"`
+		BytesReceived uint64     `json:"bytesreceived"`
+	}
+
 	// A PeerConn is the connection type used when communicating with peers during
 	// an RPC. It is identical to a net.Conn with the additional RPCAddr method.
 	// This method acts as an identifier for peers and is the address that the
@@ -184,6 +192,10 @@ type (
 		// to.
 		Peers() []Peer
 
+		// PeerStats returns the number of bytes sent to and received from
+		// each peer the Gateway is currently connected to.
+		PeerStats() []PeerBandwidth
+
 		// RegisterRPC registers a function to handle incoming connections that
 		// supply the given RPC ID.
 		RegisterRPC(string, RPCFunc)
@@ -237,7 +237,9 @@ func (g *Gateway) managedAcceptConnPeer(conn net.Conn, remoteVersion string) err
 	remoteAddr := modules.NetAddress(net.JoinHostPort(remoteIP, remotePort))
 	g.log.Debugln("Making connection with remote peer", remoteAddr)
 
-	// Accept the peer.
+	// Accept the peer. The bandwidth of the peer's session is monitored
+	// separately from the gateway's total bandwidth.
+	m := connmonitor.NewMonitor()
 	peer := &peer{
 		Peer: modules.Peer{
 			Inbound: true,
@@ -249,9 +251,9 @@ func (g *Gateway) managedAcceptConnPeer(conn net.Conn, remoteVersion string) err
 			NetAddress: remoteAddr,
 			Version:    remoteVersion,
 		},
-		m:    g.m,
+		m:    m,
 		rl:   rl,
-		sess: newServerStream(conn, remoteVersion),
+		sess: newServerStream(connmonitor.NewMonitoredConn(conn, m), remoteVersion),
 	}
 	g.mu.Lock()
 	g.acceptPeer(peer)
@@ -497,7 +499,9 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 	// connection to this peer.
 	conn.SetDeadline(time.Time{})
 
-	// Add the peer.
+	// Add the peer. The bandwidth of the peer's session is monitored separately
+	// from the gateway's total bandwidth.
+	m := connmonitor.NewMonitor()
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
@@ -508,9 +512,9 @@ func (g *Gateway) managedConnect(addr modules.NetAddress) error {
 			NetAddress: addr,
 			Version:    remoteVersion,
 		},
-		m:    g.m,
+		m:    m,
 		rl:   g.rl,
-		sess: newClientStream(conn, remoteVersion),
+		sess: newClientStream(connmonitor.NewMonitoredConn(conn, m), remoteVersion),
 	})
 	g.addNode(addr)
 	g.nodes[addr].WasOutboundPeer = true
@@ -634,3 +638,19 @@ func (g *Gateway) Peers() []modules.Peer {
 	}
 	return peers
 }
+
+// PeerStats returns the number of bytes sent to and received from each peer
+// the Gateway is currently connected to.
+func (g *Gateway) PeerStats() []modules.PeerBandwidth {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	var stats []modules.PeerBandwidth
+	for _, p := range g.peers {
+		pb := modules.PeerBandwidth{NetAddress: p.NetAddress}
+		if p.m != nil {
+			pb.BytesReceived, pb.BytesSent = p.m.Counts()
+		}
+		stats = append(stats, pb)
+	}
+	return stats
+}
@@ -1309,3 +1309,82 @@ func TestBuildPeerManagerNodeList(t *testing.T) {
 		t.Fatal("bad nodelist:", nodelist)
 	}
 }
+
+// TestPeerStats tests that the bandwidth used by a peer is tracked on both
+// ends of the connection.
+func TestPeerStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	g1 := newNamedTestingGateway(t, "1")
+	defer func() {
+		if err := g1.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	g2 := newNamedTestingGateway(t, "2")
+	defer func() {
+		if err := g2.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Connect the gateways.
+	err := g1.Connect(g2.Address())
+	if err != nil {
+		t.Fatal("failed to connect:", err)
+	}
+
+	// Register an RPC that reads a request and writes a larger response.
+	const requestSize, responseSize = 1000, 2000
+	g2.RegisterRPC("Foo", func(conn modules.PeerConn) error {
+		var req []byte
+		err := encoding.ReadObject(conn, &req, requestSize+8)
+		if err != nil {
+			return err
+		}
+		return encoding.WriteObject(conn, fastrand.Bytes(responseSize))
+	})
+	err = g1.RPC(g2.Address(), "Foo", func(conn modules.PeerConn) error {
+		err := encoding.WriteObject(conn, fastrand.Bytes(requestSize))
+		if err != nil {
+			return err
+		}
+		var resp []byte
+		return encoding.ReadObject(conn, &resp, responseSize+8)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both gateways should have tracked the bandwidth.
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		stats1, stats2 := g1.PeerStats(), g2.PeerStats()
+		if len(stats1) != 1 || len(stats2) != 1 {
+			return fmt.Errorf("expected 1 peer each but got %v and %v", len(stats1), len(stats2))
+		}
+		if stats1[0].NetAddress != g2.Address() {
+			return fmt.Errorf("wrong peer address %v", stats1[0].NetAddress)
+		}
+		if stats1[0].BytesSent < requestSize || stats1[0].BytesReceived < responseSize {
+			return fmt.Errorf("unexpected stats for g1: %+v", stats1[0])
+		}
+		if stats2[0].BytesSent < responseSize || stats2[0].BytesReceived < requestSize {
+			return fmt.Errorf("unexpected stats for g2: %+v", stats2[0])
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Disconnecting should remove the stats.
+	err = g1.Disconnect(g2.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g1.PeerStats()) != 0 {
+		t.Fatal("stats of disconnected peer should be removed")
+	}
+}
@@ -272,6 +272,10 @@ type (
 		NetAddress           NetAddress        `json:"netaddress"`
 		WindowSize           types.BlockHeight `json:"windowsize"`
 
+		// MaxContractSize is the maximum size in bytes the host allows a
+		// single contract to grow to. 0 means that there is no limit.
+		MaxContractSize uint64 `json:"maxcontractsize"`
+
 		Collateral       types.Currency `json:"collateral"`
 		CollateralBudget types.Currency `json:"collateralbudget"`
 		MaxCollateral    types.Currency `json:"maxcollateral"`
@@ -289,6 +293,51 @@ type (
 
 		CustomRegistryPath string `json:"customregistrypath"`
 		RegistrySize       uint64 `json:"registrysize"`
+
+		ReadCacheSize uint64 `json:"readcachesize"`
+
+		// OrphanedSectorDeletion enables the deletion of sectors which aren't
+		// referenced by any storage obligation. If it's disabled, orphaned
+		// sectors are only reported. OrphanedSectorDeletionDelay is the time
+		// a sector needs to remain orphaned before it is deleted.
+		OrphanedSectorDeletion      bool          `json:"orphanedsectordeletion"`
+		OrphanedSectorDeletionDelay time.Duration `json:"orphanedsectordeletiondelay"`
+
+		// MaxPinnedPriceTableValidity is the maximum validity of a price table
+		// which a renter pinned for a long running operation.
+		MaxPinnedPriceTableValidity time.Duration `json:"maxpinnedpricetablevalidity"`
+	}
+
+	// HostOrphanedSectors contains information about the sectors of the host
+	// which aren't referenced by any of its active storage obligations.
+	HostOrphanedSectors struct {
+		// LastCheck is the time of the last check for orphaned sectors.
+		LastCheck time.Time `json:"lastcheck"`
+
+		// Flagged is the number of sectors which were orphaned during the
+		// last check.
+		Flagged uint64 `json:"flagged"`
+
+		// Deleted is the number of orphaned sectors which were deleted since
+		// the host was started.
+		Deleted uint64 `json:"deleted"`
+	}
+
+	// HostAnnouncementCheck is the result of a single check that is performed
+	// before a host announcement. Failed checks prevent the announcement
+	// unless it is forced. Failed warnings never prevent the announcement.
+	HostAnnouncementCheck struct {
+		Name    string `json:"name"`
+		Passed  bool   `json:"passed"`
+		Warning bool   `json:"warning"`
+		Message string `json:"message"`
+	}
+
+	// HostAnnouncementChecks contains the results of the checks that are
+	// performed before announcing a certain address.
+	HostAnnouncementChecks struct {
+		NetAddress NetAddress              `json:"netaddress"`
+		Checks     []HostAnnouncementCheck `json:"checks"`
 	}
 
 	// HostNetworkMetrics reports the quantity of each type of RPC call that
@@ -343,6 +392,48 @@ type (
 		MissedProofOutputs []types.SiacoinOutput `json:"missedproofoutputs"`
 	}
 
+	// MDMProgramInfo contains information about a program which is currently
+	// executed by the host's MDM.
+	MDMProgramInfo struct {
+		ID              uint64               `json:"id"`
+		StartTime       time.Time            `json:"starttime"`
+		NumInstructions uint64               `json:"numinstructions"`
+		Budget          types.Currency       `json:"budget"`
+		ReadOnly        bool                 `json:"readonly"`
+		ContractID      types.FileContractID `json:"contractid"`
+	}
+
+	// HostRenterUsage contains the amount of data a renter stores on the host
+	// and the bandwidth the renter consumed within the periods retained by
+	// the host. BandwidthIn is the data received from the renter and
+	// BandwidthOut the data sent to the renter. Periods breaks the bandwidth
+	// down by period, starting with the most recent one.
+	HostRenterUsage struct {
+		RenterKey    string                  `json:"renterkey"`
+		Contracts    uint64                  `json:"contracts"`
+		StoredBytes  uint64                  `json:"storedbytes"`
+		BandwidthIn  uint64                  `json:"bandwidthin"`
+		BandwidthOut uint64                  `json:"bandwidthout"`
+		Periods      []HostRenterUsagePeriod `json:"periods"`
+	}
+
+	// HostRenterUsagePeriod contains the bandwidth a renter consumed within
+	// the period starting at PeriodStart.
+	HostRenterUsagePeriod struct {
+		PeriodStart  types.BlockHeight `json:"periodstart"`
+		BandwidthIn  uint64            `json:"bandwidthin"`
+		BandwidthOut uint64            `json:"bandwidthout"`
+	}
+
+	// MDMStats contains cumulative statistics about the programs the host's
+	// MDM executed since the host was started.
+	MDMStats struct {
+		ProgramsExecuted     uint64         `json:"programsexecuted"`
+		InstructionsExecuted uint64         `json:"instructionsexecuted"`
+		BudgetConsumed       types.Currency `json:"budgetconsumed"`
+		ProgramFailures      uint64         `json:"programfailures"`
+	}
+
 	// HostWorkingStatus reports the working state of a host. Can be one of
 	// "checking", "working", or "not working".
 	HostWorkingStatus string
@@ -371,6 +462,10 @@ type (
 		// root or not.
 		HasSector(crypto.Hash) bool
 
+		// HasSectors indicates for every provided root whether the host
+		// stores the corresponding sector.
+		HasSectors([]crypto.Hash) ([]bool, error)
+
 		// AddSectorBatch is a performance optimization over AddSector when
 		// adding a bunch of virtual sectors. It is necessary because otherwise
 		// potentially thousands or even tens-of-thousands of fsync calls would
@@ -384,11 +479,23 @@ type (
 		// running out of storage unexpectedly.
 		AddStorageFolder(path string, size uint64) error
 
-		// Announce submits a host announcement to the blockchain.
-		Announce() error
+		// Announce submits a host announcement to the blockchain. If force is
+		// true, the announcement is submitted even if some of the
+		// pre-announcement checks failed.
+		Announce(force bool) error
 
 		// AnnounceAddress submits an announcement using the given address.
-		AnnounceAddress(NetAddress) error
+		AnnounceAddress(addr NetAddress, force bool) error
+
+		// AnnouncementChecks performs the pre-announcement checks for the
+		// given address without announcing it. If the address is empty, the
+		// address that Announce would use is checked.
+		AnnouncementChecks(addr NetAddress) (HostAnnouncementChecks, error)
+
+		// Capacity returns the total and remaining storage of the host,
+		// accounting for sectors that are queued to be added or pending
+		// removal.
+		Capacity() StorageCapacity
 
 		// The host needs to be able to shut down.
 		Close() error
@@ -412,6 +519,10 @@ type (
 		// BandwidthCounters returns the Hosts's upload and download bandwidth
 		BandwidthCounters() (uint64, uint64, time.Time, error)
 
+		// CollateralBudgetUsage returns the fraction of the host's collateral
+		// budget that is locked in storage obligations.
+		CollateralBudgetUsage() float64
+
 		// FinancialMetrics returns the financial statistics of the host.
 		FinancialMetrics() HostFinancialMetrics
 
@@ -419,12 +530,34 @@ type (
 		// potentially private or sensitive information.
 		InternalSettings() HostInternalSettings
 
+		// MDMActivePrograms returns the programs which are currently executed
+		// by the host's MDM.
+		MDMActivePrograms() []MDMProgramInfo
+
+		// MDMStats returns the cumulative statistics of the host's MDM.
+		MDMStats() MDMStats
+
+		// RenterUsage returns the amount of data stored by each renter and
+		// the bandwidth each renter consumed, sorted by usage. If anonymize
+		// is set, the renters' keys are replaced by a hash.
+		RenterUsage(anonymize bool) ([]HostRenterUsage, error)
+
+		// MigrateStorageFolder moves all sectors of the storage folder at
+		// srcPath to the storage folder at dstPath. A sector is only removed
+		// from the source folder once it was written to the destination
+		// folder.
+		MigrateStorageFolder(srcPath, dstPath string) error
+
 		// NetworkMetrics returns information on the types of RPC calls that
 		// have been made to the host.
 		NetworkMetrics() HostNetworkMetrics
 
 		PaymentProcessor
 
+		// OrphanedSectors returns information about the host's sectors which
+		// aren't referenced by any of its active storage obligations.
+		OrphanedSectors() HostOrphanedSectors
+
 		// PriceTable returns the host's current price table.
 		PriceTable() RPCPriceTable
 
@@ -446,6 +579,16 @@ type (
 		// 'length' bytes at offset 'offset' that match the input sector root.
 		ReadPartialSector(sectorRoot crypto.Hash, offset, length uint64) ([]byte, error)
 
+		// ReadPartialSectorWithProof will read a sector from the storage
+		// manager, returning the 'length' bytes at offset 'offset' that match
+		// the input sector root together with a merkle range proof. The range
+		// needs to be segment aligned.
+		ReadPartialSectorWithProof(sectorRoot crypto.Hash, offset, length uint64) ([]byte, []crypto.Hash, error)
+
+		// ReadCacheStatus returns the status of the host's in-memory sector
+		// read cache.
+		ReadCacheStatus() ReadCacheStatus
+
 		// RemoveSector will remove a sector from the host. The height at which
 		// the sector expires should be provided, so that the auto-expiry
 		// information for that sector can be properly updated.
@@ -468,6 +611,10 @@ type (
 		// storage folder.
 		ResetStorageFolderHealth(index uint16) error
 
+		// SetStorageFolderReadOnly marks a storage folder as read-only or
+		// writable. No new sectors are placed in read-only storage folders.
+		SetStorageFolderReadOnly(index uint16, readOnly bool) error
+
 		// ResizeStorageFolder will grow or shrink a storage folder on the host.
 		// The host may not check that there is enough space on-disk to support
 		// growing the storage folder, but should gracefully handle running out
@@ -479,6 +626,10 @@ type (
 		// and the resize operation completed, meaning that data will be lost.
 		ResizeStorageFolder(index uint16, newSize uint64, force bool) error
 
+		// SectorCount returns the number of unique sectors stored by the host
+		// and the number of virtual sectors referencing them.
+		SectorCount() (physicalSectors, virtualSectors uint64)
+
 		// SetInternalSettings sets the hosting parameters of the host.
 		SetInternalSettings(HostInternalSettings) error
 
@@ -494,6 +645,10 @@ type (
 		// host.
 		StorageFolders() []StorageFolderMetadata
 
+		// StorageFolderStats returns the I/O statistics of the host's storage
+		// folders.
+		StorageFolderStats() []StorageFolderStats
+
 		// WorkingStatus returns the working state of the host, determined by if
 		// settings calls are increasing.
 		WorkingStatus() HostWorkingStatus
@@ -1,6 +1,10 @@
 package host
 
-import "go.sia.tech/siad/modules"
+import (
+	"fmt"
+
+	"go.sia.tech/siad/modules"
+)
 
 // Alerts implements the modules.Alerter interface for the host.
 func (h *Host) Alerts() (crit, err, warn, info []modules.Alert) {
@@ -23,3 +27,46 @@ func (h *Host) tryUnregisterInsufficientCollateralBudgetAlert() {
 		h.staticAlerter.UnregisterAlert(modules.AlertIDHostInsufficientCollateral)
 	}
 }
+
+// collateralBudgetUsage returns the fraction of the host's collateral budget
+// that is locked in storage obligations. A host without a collateral budget is
+// considered to have used it up.
+func (h *Host) collateralBudgetUsage() float64 {
+	locked := h.financialMetrics.LockedStorageCollateral
+	budget := h.settings.CollateralBudget
+	if budget.IsZero() {
+		if locked.IsZero() {
+			return 0
+		}
+		return 1
+	}
+	lockedF, _ := locked.Float64()
+	budgetF, _ := budget.Float64()
+	return lockedF / budgetF
+}
+
+// updateCollateralBudgetAlert will be called whenever the locked storage
+// collateral or the collateral budget changes. It registers a warning or a
+// critical alert if the fraction of the collateral budget that is locked
+// crosses the corresponding threshold and unregisters the alert once enough
+// collateral is released again.
+func (h *Host) updateCollateralBudgetAlert() {
+	usage := h.collateralBudgetUsage()
+	msg := fmt.Sprintf("%v (%.2f%% locked)", AlertMSGHostCollateralBudgetUsage, usage*100)
+	switch {
+	case usage >= collateralBudgetCriticalThreshold:
+		h.staticAlerter.RegisterAlert(modules.AlertIDHostCollateralBudgetUsage, msg, "", modules.SeverityCritical)
+	case usage >= collateralBudgetWarningThreshold:
+		h.staticAlerter.RegisterAlert(modules.AlertIDHostCollateralBudgetUsage, msg, "", modules.SeverityWarning)
+	default:
+		h.staticAlerter.UnregisterAlert(modules.AlertIDHostCollateralBudgetUsage)
+	}
+}
+
+// CollateralBudgetUsage returns the fraction of the host's collateral budget
+// that is locked in storage obligations.
+func (h *Host) CollateralBudgetUsage() float64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.collateralBudgetUsage()
+}
@@ -10,10 +10,29 @@ import (
 	"go.sia.tech/siad/modules"
 )
 
+const (
+	// announcementCheckAddress is the name of the check which verifies that
+	// the announced address is valid and resolves. It can't be bypassed by
+	// forcing the announcement.
+	announcementCheckAddress = "address"
+
+	// announcementCheckReachability is the name of the check which verifies
+	// that the host can be reached at the announced address.
+	announcementCheckReachability = "reachability"
+
+	// announcementCheckAutoAddress is the name of the check which warns if
+	// the announced address differs from the automatically detected one.
+	announcementCheckAutoAddress = "autoaddress"
+)
+
 var (
 	// errAnnWalletLocked is returned during a host announcement if the wallet
 	// is locked.
 	errAnnWalletLocked = errors.New("cannot announce the host while the wallet is locked")
+
+	// errAnnChecksFailed is returned during a host announcement if any of the
+	// pre-announcement checks failed and the announcement wasn't forced.
+	errAnnChecksFailed = errors.New("pre-announcement checks failed, use force to announce anyway")
 )
 
 // differentTypeIPs is a helper that returns true if two IPs are of a different
@@ -49,12 +68,154 @@ func (h *Host) staticVerifyAnnouncementAddress(addr modules.NetAddress) error {
 	return nil
 }
 
+// staticCheckReachability checks whether the host can be reached at the
+// provided address by dialing it. Since the host dials its own address, the
+// check also fails behind routers without NAT loopback support. Such hosts
+// need to force the announcement.
+func (h *Host) staticCheckReachability(addr modules.NetAddress) error {
+	dialer := &net.Dialer{
+		Cancel:  h.tg.StopChan(),
+		Timeout: announceReachabilityTimeout,
+	}
+	conn, err := dialer.Dial("tcp", string(addr))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// staticMatchesAutoAddress checks whether the provided address matches the
+// automatically detected address of the host. Addresses with different
+// hostnames still match if the address resolves to the auto address' IP.
+func (h *Host) staticMatchesAutoAddress(addr, autoAddr modules.NetAddress) bool {
+	if addr.Host() == autoAddr.Host() {
+		return addr.Port() == autoAddr.Port()
+	}
+	autoIP := net.ParseIP(autoAddr.Host())
+	if autoIP == nil {
+		return false
+	}
+	ips, err := h.dependencies.LookupIP(addr.Host())
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if ip.Equal(autoIP) {
+			return addr.Port() == autoAddr.Port()
+		}
+	}
+	return false
+}
+
+// managedAnnouncementChecks performs the pre-announcement checks for the
+// provided address.
+func (h *Host) managedAnnouncementChecks(addr modules.NetAddress) modules.HostAnnouncementChecks {
+	h.mu.RLock()
+	autoAddr := h.autoAddress
+	h.mu.RUnlock()
+
+	checks := modules.HostAnnouncementChecks{NetAddress: addr}
+
+	// Check that the address is valid and resolves. There is no point in
+	// checking the reachability of an invalid address.
+	addressCheck := modules.HostAnnouncementCheck{
+		Name:    announcementCheckAddress,
+		Passed:  true,
+		Message: "address is valid and resolves",
+	}
+	if err := h.staticVerifyAnnouncementAddress(addr); err != nil {
+		addressCheck.Passed = false
+		addressCheck.Message = err.Error()
+	}
+	checks.Checks = append(checks.Checks, addressCheck)
+
+	// Check that the host is reachable at the address.
+	reachabilityCheck := modules.HostAnnouncementCheck{
+		Name:    announcementCheckReachability,
+		Passed:  true,
+		Message: "host is reachable at the address",
+	}
+	if !addressCheck.Passed {
+		reachabilityCheck.Passed = false
+		reachabilityCheck.Message = "skipped due to invalid address"
+	} else if err := h.staticCheckReachability(addr); err != nil {
+		reachabilityCheck.Passed = false
+		reachabilityCheck.Message = "host is not reachable at the address: " + err.Error()
+	}
+	checks.Checks = append(checks.Checks, reachabilityCheck)
+
+	// Warn if the address differs from the automatically detected one.
+	autoAddressCheck := modules.HostAnnouncementCheck{
+		Name:    announcementCheckAutoAddress,
+		Passed:  true,
+		Warning: true,
+		Message: "address matches the automatically detected address",
+	}
+	if autoAddr == "" {
+		autoAddressCheck.Message = "address couldn't be detected automatically"
+	} else if !h.staticMatchesAutoAddress(addr, autoAddr) {
+		autoAddressCheck.Passed = false
+		autoAddressCheck.Message = fmt.Sprintf("address differs from the automatically detected address %v", autoAddr)
+	}
+	checks.Checks = append(checks.Checks, autoAddressCheck)
+	return checks
+}
+
+// announcementChecksErr returns an error for the failed checks that prevent an
+// announcement. Warnings never prevent an announcement and forcing the
+// announcement only ignores failed checks of a valid address.
+func announcementChecksErr(checks modules.HostAnnouncementChecks, force bool) error {
+	var errs []error
+	for _, check := range checks.Checks {
+		if check.Passed || check.Warning {
+			continue
+		}
+		if force && check.Name != announcementCheckAddress {
+			continue
+		}
+		errs = append(errs, fmt.Errorf("%v: %v", check.Name, check.Message))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errors.Compose(errAnnChecksFailed, errors.Compose(errs...))
+}
+
+// managedAnnouncementAddress returns the address the host announces if no
+// address is specified. The user-set address is preferred over the
+// automatically detected one.
+func (h *Host) managedAnnouncementAddress() (modules.NetAddress, error) {
+	// Grab the internal net address and internal auto address, and compare
+	// them.
+	h.mu.RLock()
+	userSet := h.settings.NetAddress
+	autoSet := h.autoAddress
+	h.mu.RUnlock()
+
+	// Check that we have at least one address to work with.
+	if userSet == "" && autoSet == "" {
+		return "", errors.New("cannot announce because address could not be determined")
+	}
+
+	// Prefer using the userSet address, otherwise use the automatic address.
+	if userSet != "" {
+		return userSet, nil
+	}
+	return autoSet, nil
+}
+
 // managedAnnounce creates an announcement transaction and submits it to the network.
-func (h *Host) managedAnnounce(addr modules.NetAddress) (err error) {
+func (h *Host) managedAnnounce(addr modules.NetAddress, force bool) (err error) {
 	// Verify address first.
-	if err := h.staticVerifyAnnouncementAddress(addr); err != nil {
+	checks := h.managedAnnouncementChecks(addr)
+	if err := announcementChecksErr(checks, force); err != nil {
 		return err
 	}
+	for _, check := range checks.Checks {
+		if !check.Passed {
+			h.log.Printf("WARN: announcing %v despite failed %v check: %v", addr, check.Name, check.Message)
+		}
+	}
 
 	// The wallet needs to be unlocked to add fees to the transaction, and the
 	// host needs to have an active unlock hash that renters can make payment
@@ -119,42 +280,46 @@ func (h *Host) managedAnnounce(addr modules.NetAddress) (err error) {
 	return nil
 }
 
-// Announce creates a host announcement transaction.
-func (h *Host) Announce() error {
+// Announce creates a host announcement transaction. If force is true, the
+// announcement is created even if the pre-announcement checks for a valid
+// address failed.
+func (h *Host) Announce(force bool) error {
 	err := h.tg.Add()
 	if err != nil {
 		return err
 	}
 	defer h.tg.Done()
 
-	// Grab the internal net address and internal auto address, and compare
-	// them.
-	h.mu.RLock()
-	userSet := h.settings.NetAddress
-	autoSet := h.autoAddress
-	h.mu.RUnlock()
-
-	// Check that we have at least one address to work with.
-	if userSet == "" && autoSet == "" {
-		return errors.New("cannot announce because address could not be determined")
+	annAddr, err := h.managedAnnouncementAddress()
+	if err != nil {
+		return err
 	}
+	return h.managedAnnounce(annAddr, force)
+}
 
-	// Prefer using the userSet address, otherwise use the automatic address.
-	var annAddr modules.NetAddress
-	if userSet != "" {
-		annAddr = userSet
-	} else {
-		annAddr = autoSet
+// AnnouncementChecks performs the pre-announcement checks for the provided
+// address without announcing it. If no address is provided, the address that
+// Announce would use is checked.
+func (h *Host) AnnouncementChecks(addr modules.NetAddress) (modules.HostAnnouncementChecks, error) {
+	err := h.tg.Add()
+	if err != nil {
+		return modules.HostAnnouncementChecks{}, err
 	}
+	defer h.tg.Done()
 
-	// Address has cleared inspection, perform the announcement.
-	return h.managedAnnounce(annAddr)
+	if addr == "" {
+		addr, err = h.managedAnnouncementAddress()
+		if err != nil {
+			return modules.HostAnnouncementChecks{}, err
+		}
+	}
+	return h.managedAnnouncementChecks(addr), nil
 }
 
 // AnnounceAddress submits a host announcement to the blockchain to announce a
 // specific address. If there is no error, the host's address will be updated
 // to the supplied address.
-func (h *Host) AnnounceAddress(addr modules.NetAddress) error {
+func (h *Host) AnnounceAddress(addr modules.NetAddress, force bool) error {
 	err := h.tg.Add()
 	if err != nil {
 		return err
@@ -162,9 +327,9 @@ func (h *Host) AnnounceAddress(addr modules.NetAddress) error {
 	defer h.tg.Done()
 
 	// Attempt the actual announcement.
-	err = h.managedAnnounce(addr)
+	err = h.managedAnnounce(addr, force)
 	if err != nil {
-		return build.ExtendErr("unable to perform manual host announcement", err)
+		return errors.AddContext(err, "unable to perform manual host announcement")
 	}
 
 	// Address is valid, update the host's internal net address to match the
@@ -4,6 +4,7 @@ import (
 	"net"
 	"testing"
 
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -84,7 +85,7 @@ func TestHostAnnounce(t *testing.T) {
 
 	// Create an announcement, then use the address finding module to scan the
 	// blockchain for the host's address.
-	err = ht.host.Announce()
+	err = ht.host.Announce(false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -133,9 +134,10 @@ func TestHostAnnounceAddress(t *testing.T) {
 	}()
 
 	// Create an announcement, then use the address finding module to scan the
-	// blockchain for the host's address.
+	// blockchain for the host's address. The host isn't reachable at the
+	// address so the announcement needs to be forced.
 	addr := modules.NetAddress("foo.com:1234")
-	err = ht.host.AnnounceAddress(addr)
+	err = ht.host.AnnounceAddress(addr, true)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -189,7 +191,7 @@ func TestHostAnnounceCheckUnlockHash(t *testing.T) {
 			t.Fatal(err)
 		}
 	}
-	err = ht.host.Announce()
+	err = ht.host.Announce(false)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -298,3 +300,109 @@ func TestHostVerifyAnnouncementAddress(t *testing.T) {
 		t.Error("Announcing host8 should have failed but didn't")
 	}
 }
+
+// TestHostAnnouncementChecks tests the pre-announcement checks and that a
+// failed check can be bypassed by forcing the announcement.
+func TestHostAnnouncementChecks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ht.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	af, err := newAnnouncementFinder(ht.cs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := af.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// checkResult is a helper to check the result of a single check.
+	checkResult := func(checks modules.HostAnnouncementChecks, name string, passed bool) {
+		t.Helper()
+		for _, check := range checks.Checks {
+			if check.Name != name {
+				continue
+			}
+			if check.Passed != passed {
+				t.Fatalf("expected %v check to have passed=%v: %v", name, passed, check.Message)
+			}
+			return
+		}
+		t.Fatal("check not found", name)
+	}
+
+	// All checks should pass for the host's default address.
+	checks, err := ht.host.AnnouncementChecks("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checks.NetAddress != ht.host.autoAddress {
+		t.Fatal("wrong address was checked", checks.NetAddress)
+	}
+	checkResult(checks, announcementCheckAddress, true)
+	checkResult(checks, announcementCheckReachability, true)
+	checkResult(checks, announcementCheckAutoAddress, true)
+
+	// Get an address that nobody is listening on.
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := modules.NetAddress(l.Addr().String())
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The unreachable address should be detected and the address should be
+	// reported as different from the auto address.
+	checks, err = ht.host.AnnouncementChecks(unreachable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkResult(checks, announcementCheckAddress, true)
+	checkResult(checks, announcementCheckReachability, false)
+	checkResult(checks, announcementCheckAutoAddress, false)
+
+	// Announcing the unreachable address should fail.
+	err = ht.host.AnnounceAddress(unreachable, false)
+	if !errors.Contains(err, errAnnChecksFailed) {
+		t.Fatal("expected announcement to fail", err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(af.netAddresses) != 0 {
+		t.Fatal("address shouldn't have been announced")
+	}
+
+	// An invalid address can't be announced even when forced.
+	err = ht.host.AnnounceAddress("invalid", true)
+	if !errors.Contains(err, errAnnChecksFailed) {
+		t.Fatal("expected announcement to fail", err)
+	}
+
+	// Forcing the announcement should work.
+	err = ht.host.AnnounceAddress(unreachable, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ht.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(af.netAddresses) != 1 || af.netAddresses[0] != unreachable {
+		t.Fatal("address wasn't announced", af.netAddresses)
+	}
+}
@@ -12,6 +12,19 @@ const (
 	// AlertMSGHostInsufficientCollateral indicates that a host has insufficient
 	// collateral budget remaining
 	AlertMSGHostInsufficientCollateral = "host has insufficient collateral budget"
+
+	// AlertMSGHostCollateralBudgetUsage indicates that a host has locked most
+	// of its collateral budget
+	AlertMSGHostCollateralBudgetUsage = "host has locked most of its collateral budget"
+
+	// collateralBudgetWarningThreshold is the fraction of the collateral
+	// budget that needs to be locked for the host to register a warning.
+	collateralBudgetWarningThreshold = 0.8
+
+	// collateralBudgetCriticalThreshold is the fraction of the collateral
+	// budget that needs to be locked for the host to register a critical
+	// alert.
+	collateralBudgetCriticalThreshold = 0.95
 )
 
 const (
@@ -45,6 +58,14 @@ var (
 		Testing:  time.Second * 3,
 	}).(time.Duration)
 
+	// orphanedSectorsCheckFrequency defines how often the host checks for
+	// sectors which aren't referenced by any storage obligation.
+	orphanedSectorsCheckFrequency = build.Select(build.Var{
+		Standard: time.Hour * 6,
+		Dev:      time.Minute * 10,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
 	// connectablityCheckFrequency defines how often the host's connectability
 	// check is run.
 	connectabilityCheckFrequency = build.Select(build.Var{
@@ -53,6 +74,15 @@ var (
 		Testing:  time.Second * 10,
 	}).(time.Duration)
 
+	// announceReachabilityTimeout defines how long the reachability check
+	// before an announcement will wait for the dial to the announced address
+	// to succeed.
+	announceReachabilityTimeout = build.Select(build.Var{
+		Standard: time.Second * 30,
+		Dev:      time.Second * 10,
+		Testing:  time.Second * 3,
+	}).(time.Duration)
+
 	// connectabilityCheckTimeout defines how long a connectability check's dial
 	// will be allowed to block before it times out.
 	connectabilityCheckTimeout = build.Select(build.Var{
@@ -67,6 +97,20 @@ var (
 	// furious for losing access to it for a few weeks.
 	defaultCollateralBudget = types.SiacoinPrecision.Mul64(100e3)
 
+	// defaultOrphanedSectorDeletionDelay is the default time a sector needs to
+	// remain orphaned before the host deletes it. It's deliberately long to
+	// give an operator time to notice the reported orphans before they are
+	// deleted.
+	defaultOrphanedSectorDeletionDelay = 72 * time.Hour
+
+	// defaultMaxPinnedPriceTableValidity is the default maximum validity of a
+	// price table pinned by a renter for a long running operation.
+	defaultMaxPinnedPriceTableValidity = build.Select(build.Var{
+		Standard: time.Hour,
+		Dev:      15 * time.Minute,
+		Testing:  5 * time.Minute,
+	}).(time.Duration)
+
 	// defaultMaxEphemeralAccountRisk is the maximum amount of money that the
 	// host is willing to risk to a power loss. If a user's withdrawal would put
 	// the host over the maxunsaveddelat, the host will wait to complete the
@@ -12,6 +12,10 @@ const (
 	// AlertMSGHostDiskTrouble indicates that one or multiple of a host's disks
 	// are encountering problems
 	AlertMSGHostDiskTrouble = "disk problem detected"
+
+	// AlertMSGHostReadOnlyFolder indicates that a storage folder was marked
+	// read-only because too many of its reads and writes failed.
+	AlertMSGHostReadOnlyFolder = "storage folder marked read-only due to disk errors"
 )
 
 const (
@@ -61,9 +65,34 @@ const (
 	// sector counters on disk in AddSectorBatch and RemoveSectorBatch.
 	maxSectorBatchThreads = 100
 
+	// maxSectorLocationLoadThreads is the maximum number of threads reading
+	// the sector metadata of storage folders in parallel at startup.
+	maxSectorLocationLoadThreads = 16
+
+	// walCompressedEntryHeaderSize is the size of the header which precedes
+	// the payload of a compressed WAL entry. It consists of the magic byte
+	// followed by the compressed and uncompressed size of the payload.
+	walCompressedEntryHeaderSize = 1 + 8 + 8
+
+	// walCompressedEntryMagic is the magic byte which starts the header of a
+	// compressed WAL entry. Uncompressed entries are JSON objects, which
+	// always start with '{', so the two can't be confused. Only WALs with the
+	// walMetadata version contain compressed entries.
+	walCompressedEntryMagic = 0xC7
+
+	// walCompressionThreshold is the number of changes a batch of WAL entries
+	// needs to exceed for it to be written as a single compressed entry.
+	walCompressionThreshold = 64
+
 	// sectorMetadataDiskSize defines the number of bytes it takes to store the
-	// metadata of a single sector on disk.
-	sectorMetadataDiskSize = 14
+	// metadata of a single sector on disk. The metadata consists of the
+	// 12 byte sector id, the 2 byte count and a 4 byte CRC32c checksum of
+	// both.
+	sectorMetadataDiskSize = 18
+
+	// legacySectorMetadataDiskSize is the size of the on-disk metadata of a
+	// single sector before checksums were added.
+	legacySectorMetadataDiskSize = 14
 
 	// storageFolderGranularity defines the number of sectors that a storage
 	// folder must cleanly divide into. 64 sectors is a requirement due to the
@@ -81,13 +110,34 @@ var (
 	// settingsMetadata is the header that is used when writing the contract
 	// manager's settings to disk.
 	settingsMetadata = persist.Metadata{
+		Header:  "Sia Contract Manager",
+		Version: "1.6.0",
+	}
+
+	// settingsMetadataV120 is the header of the settings written before
+	// checksums were added to the sector metadata. The metadata of all storage
+	// folders in these settings needs to be upgraded.
+	//
+	// COMPATV160
+	settingsMetadataV120 = persist.Metadata{
 		Header:  "Sia Contract Manager",
 		Version: "1.2.0",
 	}
 
 	// walMetadata is the header that is used when writing the write ahead log
-	// to disk, so that it may be identified at startup.
+	// to disk, so that it may be identified at startup. The version was bumped
+	// when compressed entries were added, which older versions can't read.
 	walMetadata = persist.Metadata{
+		Header:  "Sia Contract Manager WAL",
+		Version: "1.6.0",
+	}
+
+	// walMetadataV120 is the header of write ahead logs written before
+	// compressed entries were added. They only contain uncompressed entries
+	// and can still be recovered.
+	//
+	// COMPATV160
+	walMetadataV120 = persist.Metadata{
 		Header:  "Sia Contract Manager WAL",
 		Version: "1.2.0",
 	}
@@ -140,3 +190,36 @@ var (
 		Testing:  time.Second * 8,
 	}).(time.Duration)
 )
+
+var (
+	// folderHealthCheckInterval is the interval at which the contract manager
+	// samples the read and write statistics of the storage folders.
+	folderHealthCheckInterval = build.Select(build.Var{
+		Dev:      time.Second * 10,
+		Standard: time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// folderHealthWindow is the number of samples the failure rate of a
+	// storage folder is computed over.
+	folderHealthWindow = build.Select(build.Var{
+		Dev:      30,
+		Standard: 60,
+		Testing:  10,
+	}).(int)
+
+	// folderHealthMinOperations is the minimum number of reads and writes
+	// within the window before a storage folder can be marked read-only.
+	folderHealthMinOperations = build.Select(build.Var{
+		Dev:      uint64(20),
+		Standard: uint64(100),
+		Testing:  uint64(5),
+	}).(uint64)
+)
+
+const (
+	// folderHealthMaxFailureRate is the fraction of reads and writes within
+	// the window that need to fail for a storage folder to be marked
+	// read-only.
+	folderHealthMaxFailureRate = 0.1
+)
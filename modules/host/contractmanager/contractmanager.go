@@ -69,6 +69,13 @@ type ContractManager struct {
 	// folders should be tolerated gracefully. Threads should perform complete
 	// cleanup before returning, which can be achieved with threadgroups.
 
+	// atomicQueuedSectors is the number of physical sectors that are waiting
+	// to be assigned a slot in a storage folder. atomicPendingRemovals is the
+	// number of physical sectors that have been removed in the WAL but whose
+	// slot won't be freed up until the removal has been synced.
+	atomicQueuedSectors   uint64
+	atomicPendingRemovals uint64
+
 	// sectorSalt is a persistent security field that gets set the first time
 	// the contract manager is initiated and then never gets touched again.
 	// It's used to randomize the location on-disk that a sector gets stored,
@@ -114,6 +121,21 @@ type ContractManager struct {
 	sectorLocations map[sectorID]sectorLocation
 	storageFolders  map[uint16]*storageFolder
 
+	// staticReadCache is an optional in-memory cache of recently read
+	// sectors. It is disabled by default.
+	staticReadCache *readCache
+
+	// staticFolderHealth keeps track of the recent failure rate of the
+	// storage folders.
+	staticFolderHealth *folderHealthMonitor
+
+	// orphanedSectors contains the sectors which weren't referenced by the
+	// host during the last call to CollectOrphanedSectors. It is protected by
+	// the sectorMu and persisted with the settings. The orphanedSectorsMu
+	// prevents concurrent calls to CollectOrphanedSectors.
+	orphanedSectors   map[sectorID]orphanedSector
+	orphanedSectorsMu sync.Mutex
+
 	// Utilities.
 	dependencies  modules.Dependencies
 	staticAlerter *modules.GenericAlerter
@@ -135,7 +157,11 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 		storageFolders:  make(map[uint16]*storageFolder),
 		sectorLocations: make(map[sectorID]sectorLocation),
 
-		lockedSectors: make(map[sectorID]*sectorLock),
+		lockedSectors:   make(map[sectorID]*sectorLock),
+		orphanedSectors: make(map[sectorID]orphanedSector),
+
+		staticReadCache:    newReadCache(0),
+		staticFolderHealth: newFolderHealthMonitor(),
 
 		dependencies: dependencies,
 		persistDir:   persistDir,
@@ -226,17 +252,7 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 
 	// The sector location data is loaded last. Any corruption that happened
 	// during unclean shutdown has already been fixed by the WAL.
-	cm.sectorMu.Lock()
-	for _, sf := range cm.storageFolders {
-		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
-			// Metadata unavailable, just count the number of sectors instead of
-			// loading them.
-			sf.sectors = uint64(len(usageSectors(sf.usage)))
-			continue
-		}
-		cm.loadSectorLocations(sf)
-	}
-	cm.sectorMu.Unlock()
+	cm.loadAllSectorLocations()
 
 	// Launch the sync loop that periodically flushes changes from the WAL to
 	// disk.
@@ -250,6 +266,10 @@ func newContractManager(dependencies modules.Dependencies, persistDir string) (_
 	// and adds them if they are discovered.
 	go cm.threadedFolderRecheck()
 
+	// Spin up the thread that marks storage folders with too many failed
+	// reads and writes as read-only.
+	go cm.threadedFolderHealthMonitor()
+
 	// Simulate an error to make sure the cleanup code is triggered correctly.
 	if cm.dependencies.Disrupt("erroredStartup") {
 		err = errors.New("startup disrupted")
@@ -2,6 +2,9 @@ package contractmanager
 
 import (
 	"math"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
 
@@ -78,3 +81,103 @@ func BenchmarkStorageFolders(b *testing.B) {
 		randFreeSector(usage)
 	}
 }
+
+// BenchmarkLoadSectorLocations compares loading the sector locations of
+// multiple storage folders sequentially to loading them in parallel. Every
+// storage folder uses a synthetic metadata file with all of its sectors in
+// use.
+func BenchmarkLoadSectorLocations(b *testing.B) {
+	cmt, err := newContractManagerTester(b.Name())
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer cmt.panicClose()
+	cm := cmt.cm
+
+	// Create the synthetic storage folders.
+	numFolders := 8
+	numSectors := 1 << 18
+	for i := 0; i < numFolders; i++ {
+		dir := filepath.Join(cmt.persistDir, "storageFolder"+strconv.Itoa(i))
+		err := os.MkdirAll(dir, 0700)
+		if err != nil {
+			b.Fatal(err)
+		}
+		metadata := make([]byte, numSectors*sectorMetadataDiskSize)
+		for j := 0; j < numSectors; j++ {
+			var id sectorID
+			fastrand.Read(id[:])
+			encodeSectorMetadata(metadata[j*sectorMetadataDiskSize:], id, 1)
+		}
+		mf, err := os.Create(filepath.Join(dir, metadataFile))
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := mf.Write(metadata); err != nil {
+			b.Fatal(err)
+		}
+		sf, err := os.Create(filepath.Join(dir, sectorFile))
+		if err != nil {
+			b.Fatal(err)
+		}
+		usage := make([]uint64, numSectors/storageFolderGranularity)
+		for j := range usage {
+			usage[j] = math.MaxUint64
+		}
+		cm.sectorMu.Lock()
+		cm.storageFolders[uint16(i)] = &storageFolder{
+			index:            uint16(i),
+			path:             dir,
+			usage:            usage,
+			availableSectors: make(map[sectorID]uint32),
+			metadataFile:     mf,
+			sectorFile:       sf,
+		}
+		cm.sectorMu.Unlock()
+	}
+
+	b.Run("Sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cm.sectorMu.Lock()
+			cm.sectorLocations = make(map[sectorID]sectorLocation)
+			for _, sf := range cm.storageFolders {
+				cm.loadSectorLocations(sf)
+			}
+			cm.sectorMu.Unlock()
+		}
+	})
+	b.Run("Parallel", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cm.sectorMu.Lock()
+			cm.sectorLocations = make(map[sectorID]sectorLocation)
+			cm.sectorMu.Unlock()
+			cm.loadAllSectorLocations()
+		}
+	})
+
+	// Sanity check the number of loaded sectors.
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+	if len(cm.sectorLocations) != numFolders*numSectors {
+		b.Fatalf("expected %v sectors but got %v", numFolders*numSectors, len(cm.sectorLocations))
+	}
+}
+
+// BenchmarkAddSectors compares the number of WriteAt calls per sector when
+// adding a burst of sectors with AddSectors and with AddSector in a loop.
+func BenchmarkAddSectors(b *testing.B) {
+	numSectors := uint64(storageFolderGranularity)
+	for _, batch := range []bool{false, true} {
+		name := "AddSector"
+		if batch {
+			name = "AddSectors"
+		}
+		b.Run(name, func(b *testing.B) {
+			var writes uint64
+			for i := 0; i < b.N; i++ {
+				writes += countAddSectorWrites(b, b.Name()+strconv.Itoa(i), numSectors, batch)
+			}
+			b.ReportMetric(float64(writes)/float64(uint64(b.N)*numSectors), "writeat/sector")
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package contractmanager
+
+import (
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// errOrphanedSectorChanged is returned when an orphaned sector was
+	// modified since it was flagged.
+	errOrphanedSectorChanged = errors.New("orphaned sector was modified since it was flagged")
+)
+
+// orphanedSector contains information about a sector that isn't referenced by
+// the host anymore.
+type orphanedSector struct {
+	// flaggedAt is the time the sector was first found to be orphaned.
+	flaggedAt time.Time
+
+	// count is the virtual count of the sector at the time it was flagged. If
+	// the count changes, the sector was added or removed in the meantime and
+	// it is flagged again.
+	count uint64
+}
+
+// CollectOrphanedSectors compares the sectors stored by the contract manager
+// to the provided sector roots. Sectors that aren't referenced are flagged as
+// orphaned and sectors that are referenced again are no longer flagged. If
+// deleteOrphans is true, sectors that have been flagged for at least the
+// provided delay are deleted. Every deletion is logged.
+//
+// The referenced sector roots are usually collected from the host's storage
+// obligations before calling CollectOrphanedSectors. A sector that is added in
+// the meantime will therefore be flagged, which is why the delay should be
+// long enough for the host to reference all sectors it is currently receiving.
+func (cm *ContractManager) CollectOrphanedSectors(referenced []crypto.Hash, deleteOrphans bool, delay time.Duration) (report modules.OrphanedSectorsReport, err error) {
+	err = cm.tg.Add()
+	if err != nil {
+		return modules.OrphanedSectorsReport{}, err
+	}
+	defer cm.tg.Done()
+	cm.orphanedSectorsMu.Lock()
+	defer cm.orphanedSectorsMu.Unlock()
+
+	// Translate the roots to sector ids.
+	referencedIDs := make(map[sectorID]struct{}, len(referenced))
+	for _, root := range referenced {
+		referencedIDs[cm.managedSectorID(root)] = struct{}{}
+	}
+
+	// Flag the sectors which aren't referenced. Sectors on unavailable storage
+	// folders are ignored since they can't be deleted anyway.
+	now := time.Now()
+	orphans := make(map[sectorID]orphanedSector)
+	cm.sectorMu.Lock()
+	for id, location := range cm.sectorLocations {
+		if _, exists := referencedIDs[id]; exists {
+			continue
+		}
+		sf, exists := cm.storageFolders[location.storageFolder]
+		if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			continue
+		}
+		orphan, exists := cm.orphanedSectors[id]
+		if !exists || orphan.count != location.count {
+			orphan = orphanedSector{
+				flaggedAt: now,
+				count:     location.count,
+			}
+		}
+		orphans[id] = orphan
+	}
+	cm.orphanedSectors = orphans
+	cm.sectorMu.Unlock()
+
+	// Delete the orphaned sectors which have been flagged for long enough.
+	for id, orphan := range orphans {
+		if !deleteOrphans || now.Sub(orphan.flaggedAt) < delay {
+			continue
+		}
+		err := cm.managedDeleteOrphanedSector(id, orphan.count)
+		if errors.Contains(err, ErrSectorNotFound) || errors.Contains(err, errOrphanedSectorChanged) {
+			cm.managedUnflagOrphanedSector(id)
+			continue
+		} else if err != nil {
+			cm.log.Printf("WARN: failed to delete orphaned sector %x: %v", id, err)
+			continue
+		}
+		cm.log.Printf("Deleted orphaned sector %x with %v virtual sectors which was flagged at %v", id, orphan.count, orphan.flaggedAt)
+		cm.managedUnflagOrphanedSector(id)
+		report.Deleted++
+	}
+	cm.sectorMu.Lock()
+	report.Flagged = uint64(len(cm.orphanedSectors))
+	cm.sectorMu.Unlock()
+	return report, nil
+}
+
+// managedUnflagOrphanedSector removes a sector from the orphaned sectors.
+func (cm *ContractManager) managedUnflagOrphanedSector(id sectorID) {
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+	delete(cm.orphanedSectors, id)
+}
+
+// managedDeleteOrphanedSector deletes an orphaned sector if its virtual count
+// didn't change since it was flagged.
+func (cm *ContractManager) managedDeleteOrphanedSector(id sectorID, count uint64) error {
+	cm.wal.managedLockSector(id)
+	defer cm.wal.managedUnlockSector(id)
+
+	cm.sectorMu.Lock()
+	location, exists := cm.sectorLocations[id]
+	sf, sfExists := cm.storageFolders[location.storageFolder]
+	cm.sectorMu.Unlock()
+	if !exists {
+		return ErrSectorNotFound
+	}
+	if !sfExists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return errStorageFolderNotFound
+	}
+	if location.count != count {
+		return errOrphanedSectorChanged
+	}
+	return cm.wal.managedDeleteSector(id)
+}
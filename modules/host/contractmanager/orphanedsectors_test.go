@@ -0,0 +1,136 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestCollectOrphanedSectors checks that unreferenced sectors are flagged and
+// only deleted once the delay passed and if they didn't change in the
+// meantime.
+func TestCollectOrphanedSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+	cm := cmt.cm
+
+	// Add a storage folder.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a referenced sector and two orphans.
+	var roots []crypto.Hash
+	var datas [][]byte
+	for i := 0; i < 3; i++ {
+		root, data := randSector()
+		if err := cm.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		roots = append(roots, root)
+		datas = append(datas, data)
+	}
+	referenced := roots[:1]
+	orphan1, orphan2 := roots[1], roots[2]
+
+	// Collecting the orphans without deleting them should only flag them.
+	report, err := cm.CollectOrphanedSectors(referenced, false, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (modules.OrphanedSectorsReport{Flagged: 2}) {
+		t.Fatal("unexpected report", report)
+	}
+	for _, root := range roots {
+		if !cm.HasSector(root) {
+			t.Fatal("sector shouldn't be deleted")
+		}
+	}
+
+	// The orphans shouldn't be deleted before the delay passed.
+	report, err = cm.CollectOrphanedSectors(referenced, true, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (modules.OrphanedSectorsReport{Flagged: 2}) {
+		t.Fatal("unexpected report", report)
+	}
+
+	// Restart the contract manager. The orphans should still be flagged at
+	// the same time.
+	cm.sectorMu.Lock()
+	flagged := make(map[sectorID]orphanedSector)
+	for id, orphan := range cm.orphanedSectors {
+		flagged[id] = orphan
+	}
+	cm.sectorMu.Unlock()
+	if err := cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm = cm
+	cm.sectorMu.Lock()
+	if len(cm.orphanedSectors) != len(flagged) {
+		t.Fatalf("expected %v orphans after restart but got %v", len(flagged), len(cm.orphanedSectors))
+	}
+	for id, orphan := range cm.orphanedSectors {
+		if orphan.count != flagged[id].count || !orphan.flaggedAt.Equal(flagged[id].flaggedAt) {
+			t.Fatalf("orphan changed after restart: %v != %v", orphan, flagged[id])
+		}
+	}
+	cm.sectorMu.Unlock()
+
+	// Pretend the orphans were flagged an hour ago. Adding the first orphan
+	// again changes its virtual count which should protect it from deletion.
+	cm.sectorMu.Lock()
+	for id, orphan := range cm.orphanedSectors {
+		orphan.flaggedAt = orphan.flaggedAt.Add(-time.Hour)
+		cm.orphanedSectors[id] = orphan
+	}
+	cm.sectorMu.Unlock()
+	if err := cm.AddSector(orphan1, datas[1]); err != nil {
+		t.Fatal(err)
+	}
+	report, err = cm.CollectOrphanedSectors(referenced, true, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (modules.OrphanedSectorsReport{Flagged: 1, Deleted: 1}) {
+		t.Fatal("unexpected report", report)
+	}
+	if !cm.HasSector(orphan1) || cm.HasSector(orphan2) {
+		t.Fatal("only the unchanged orphan should be deleted")
+	}
+
+	// Referencing the remaining orphan unflags it.
+	report, err = cm.CollectOrphanedSectors(roots[:2], true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != (modules.OrphanedSectorsReport{}) {
+		t.Fatal("unexpected report", report)
+	}
+	if !cm.HasSector(orphan1) {
+		t.Fatal("referenced sector shouldn't be deleted")
+	}
+}
@@ -1,11 +1,14 @@
 package contractmanager
 
 import (
+	"bytes"
 	"encoding/binary"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
@@ -19,29 +22,52 @@ type (
 	// savedStorageFolder contains fields that are saved automatically to disk
 	// for each storage folder.
 	savedStorageFolder struct {
-		Index uint16
-		Path  string
-		Usage []uint64
+		Index    uint16
+		Path     string
+		Usage    []uint64
+		ReadOnly bool
+
+		// LegacyMetadata is set for folders which were unavailable when the
+		// settings were upgraded from v1.2.0 and which therefore still have
+		// sector metadata without checksums.
+		LegacyMetadata bool
+	}
+
+	// savedOrphanedSector contains fields that are saved to disk for each
+	// orphaned sector. This way the deletion delay of orphaned sectors doesn't
+	// start over after a restart.
+	savedOrphanedSector struct {
+		ID        sectorID
+		FlaggedAt time.Time
+		Count     uint64
 	}
 
 	// savedSettings contains fields that are saved atomically to disk inside
 	// of the contract manager directory, alongside the WAL and log.
 	savedSettings struct {
-		SectorSalt     crypto.Hash
-		StorageFolders []savedStorageFolder
+		SectorSalt      crypto.Hash
+		StorageFolders  []savedStorageFolder
+		OrphanedSectors []savedOrphanedSector
 	}
 )
 
 // equals tests if all settings are equal between two savedSettings.
 func (s *savedSettings) equals(sb savedSettings) bool {
-	if s.SectorSalt != sb.SectorSalt || len(s.StorageFolders) != len(sb.StorageFolders) {
+	if s.SectorSalt != sb.SectorSalt || len(s.StorageFolders) != len(sb.StorageFolders) || len(s.OrphanedSectors) != len(sb.OrphanedSectors) {
 		return false
 	}
 
+	for i, orphan := range s.OrphanedSectors {
+		orphanb := sb.OrphanedSectors[i]
+		if orphan.ID != orphanb.ID || orphan.Count != orphanb.Count || !orphan.FlaggedAt.Equal(orphanb.FlaggedAt) {
+			return false
+		}
+	}
+
 	for i, sf := range s.StorageFolders {
 		sfb := sb.StorageFolders[i]
 
-		if sf.Index != sfb.Index || sf.Path != sfb.Path || len(sf.Usage) != len(sfb.Usage) {
+		if sf.Index != sfb.Index || sf.Path != sfb.Path || sf.ReadOnly != sfb.ReadOnly || sf.LegacyMetadata != sfb.LegacyMetadata || len(sf.Usage) != len(sfb.Usage) {
 			return false
 		}
 
@@ -58,9 +84,11 @@ func (s *savedSettings) equals(sb savedSettings) bool {
 // savedStorageFolder returns the persistent version of the storage folder.
 func (sf *storageFolder) savedStorageFolder() savedStorageFolder {
 	ssf := savedStorageFolder{
-		Index: sf.index,
-		Path:  sf.path,
-		Usage: make([]uint64, len(sf.usage)),
+		Index:          sf.index,
+		Path:           sf.path,
+		Usage:          make([]uint64, len(sf.usage)),
+		ReadOnly:       atomic.LoadUint64(&sf.atomicReadOnly) == 1,
+		LegacyMetadata: sf.legacyMetadata,
 	}
 	copy(ssf.Usage, sf.usage)
 	return ssf
@@ -85,7 +113,14 @@ func (cm *ContractManager) initSettings() error {
 // loadSettings will load the contract manager settings.
 func (cm *ContractManager) loadSettings() error {
 	var ss savedSettings
+	var legacy bool
 	err := cm.dependencies.LoadFile(settingsMetadata, &ss, filepath.Join(cm.persistDir, settingsFile))
+	if errors.Contains(err, persist.ErrBadVersion) {
+		// COMPATV160 the settings were written before checksums were added to
+		// the sector metadata.
+		legacy = true
+		err = cm.dependencies.LoadFile(settingsMetadataV120, &ss, filepath.Join(cm.persistDir, settingsFile))
+	}
 	if os.IsNotExist(err) {
 		// There is no settings file, this must be the first time that the
 		// contract manager has been run. Initialize with default settings.
@@ -102,6 +137,10 @@ func (cm *ContractManager) loadSettings() error {
 		sf.index = ss.StorageFolders[i].Index
 		sf.path = ss.StorageFolders[i].Path
 		sf.usage = ss.StorageFolders[i].Usage
+		if ss.StorageFolders[i].ReadOnly {
+			atomic.StoreUint64(&sf.atomicReadOnly, 1)
+		}
+		sf.legacyMetadata = legacy || ss.StorageFolders[i].LegacyMetadata
 		sf.metadataFile, err = cm.dependencies.OpenFile(filepath.Join(ss.StorageFolders[i].Path, metadataFile), os.O_RDWR, 0700)
 		if err != nil {
 			// Mark the folder as unavailable and log an error.
@@ -117,33 +156,133 @@ func (cm *ContractManager) loadSettings() error {
 				sf.metadataFile.Close()
 			}
 		}
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 0 && sf.legacyMetadata {
+			err = cm.upgradeLegacySectorMetadata(sf)
+			if err != nil {
+				// Mark the folder as unavailable and log an error.
+				atomic.StoreUint64(&sf.atomicUnavailable, 1)
+				cm.log.Printf("ERROR: unable to upgrade the %v sector metadata file: %v\n", sf.path, err)
+				err = errors.Compose(sf.metadataFile.Close(), sf.sectorFile.Close())
+				if err != nil {
+					cm.log.Printf("ERROR: unable to close the %v storage folder files: %v\n", sf.path, err)
+				}
+			}
+		}
 		sf.availableSectors = make(map[sectorID]uint32)
 		cm.sectorMu.Lock()
 		cm.storageFolders[sf.index] = sf
 		cm.sectorMu.Unlock()
 	}
+
+	// Restore the orphaned sectors.
+	cm.sectorMu.Lock()
+	for _, orphan := range ss.OrphanedSectors {
+		cm.orphanedSectors[orphan.ID] = orphanedSector{
+			flaggedAt: orphan.FlaggedAt,
+			count:     orphan.Count,
+		}
+	}
+	cm.sectorMu.Unlock()
 	return nil
 }
 
-// loadSectorLocations will read the metadata portion of each storage folder
-// file and load the sector location information into memory.
+// loadAllSectorLocations will load the sector location information of all
+// available storage folders into memory. The metadata of the storage folders
+// is read in parallel and merged into the sector location map at the end. A
+// storage folder with unreadable metadata is marked as unavailable without
+// affecting the other storage folders.
+func (cm *ContractManager) loadAllSectorLocations() {
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+
+	var folders []*storageFolder
+	for _, sf := range cm.storageFolders {
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			// Metadata unavailable, just count the number of sectors instead of
+			// loading them.
+			sf.sectors = uint64(len(usageSectors(sf.usage)))
+			continue
+		}
+		folders = append(folders, sf)
+	}
+
+	// Read the metadata of each storage folder in a separate goroutine. Every
+	// goroutine only touches its own storage folder and its own entry in
+	// locations.
+	locations := make([]map[sectorID]sectorLocation, len(folders))
+	var wg sync.WaitGroup
+	// Ensure only 'maxSectorLocationLoadThreads' goroutines are running at a
+	// time.
+	semaphore := make(chan struct{}, maxSectorLocationLoadThreads)
+	for i := range folders {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			locations[i] = cm.readSectorLocations(folders[i])
+		}(i)
+	}
+	wg.Wait()
+
+	// Merge the sector locations of all storage folders.
+	for _, folderLocations := range locations {
+		for id, sl := range folderLocations {
+			cm.sectorLocations[id] = sl
+		}
+	}
+}
+
+// loadSectorLocations will read the metadata portion of a storage folder file
+// and load the sector location information into memory.
 func (cm *ContractManager) loadSectorLocations(sf *storageFolder) {
+	for id, sl := range cm.readSectorLocations(sf) {
+		cm.sectorLocations[id] = sl
+	}
+}
+
+// readSectorLocations will read the metadata portion of a storage folder file
+// and return the locations of the sectors within the storage folder. Apart
+// from the storage folder itself, no state of the contract manager is
+// modified, which allows for reading multiple storage folders in parallel. If
+// the metadata can't be read, the storage folder is marked as unavailable and
+// nil is returned.
+func (cm *ContractManager) readSectorLocations(sf *storageFolder) map[sectorID]sectorLocation {
 	// Read the sector lookup table for this storage folder into memory.
-	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
+	sectorLookupBytes, corrupt, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
 	if err != nil {
 		atomic.AddUint64(&sf.atomicFailedReads, 1)
 		atomic.StoreUint64(&sf.atomicUnavailable, 1)
 		err = build.ComposeErrors(err, sf.metadataFile.Close())
 		err = build.ComposeErrors(err, sf.sectorFile.Close())
 		cm.log.Printf("ERROR: unable to read sector metadata for folder %v: %v\n", sf.path, err)
-		return
+		return nil
 	}
 	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	corruptIndices := make(map[uint32]struct{}, len(corrupt))
+	for _, sectorIndex := range corrupt {
+		corruptIndices[sectorIndex] = struct{}{}
+	}
+	if len(corrupt) > 0 {
+		cm.log.Printf("ERROR: %v sector metadata entries of folder %v have a bad checksum\n", len(corrupt), sf.path)
+	}
 
 	// Iterate through the sectors that are in-use and read their storage
 	// locations into memory.
+	usedSectors := usageSectors(sf.usage)
+	locations := make(map[sectorID]sectorLocation, len(usedSectors))
 	sf.sectors = 0 // may be non-zero from WAL operations - they will be double counted here if not reset.
-	for _, sectorIndex := range usageSectors(sf.usage) {
+	for _, sectorIndex := range usedSectors {
+		// A sector with corrupted metadata can't be located. Its slot stays
+		// marked as used to make sure that the sector data isn't overwritten
+		// before it can be recovered manually.
+		if _, exists := corruptIndices[sectorIndex]; exists {
+			cm.log.Printf("ERROR: sector at index %v of folder %v is unavailable due to corrupted metadata\n", sectorIndex, sf.path)
+			sf.sectors++
+			continue
+		}
 		readHead := sectorMetadataDiskSize * sectorIndex
 		var id sectorID
 		copy(id[:], sectorLookupBytes[readHead:readHead+12])
@@ -162,10 +301,11 @@ func (cm *ContractManager) loadSectorLocations(sf *storageFolder) {
 		}
 
 		// Add the sector to the sector location map.
-		cm.sectorLocations[id] = sl
+		locations[id] = sl
 		sf.sectors++
 	}
 	atomic.StoreUint64(&sf.atomicUnavailable, 0)
+	return locations
 }
 
 // savedSettings returns the settings of the contract manager in an
@@ -189,6 +329,13 @@ func (cm *ContractManager) savedSettings() savedSettings {
 			sf.setUsage(sectorIndex)
 		}
 	}
+	for id, orphan := range cm.orphanedSectors {
+		ss.OrphanedSectors = append(ss.OrphanedSectors, savedOrphanedSector{
+			ID:        id,
+			FlaggedAt: orphan.flaggedAt,
+			Count:     orphan.count,
+		})
+	}
 	cm.sectorMu.Unlock()
 
 	// canonicalize storage folder ordering; otherwise savedSettings.equals
@@ -196,5 +343,8 @@ func (cm *ContractManager) savedSettings() savedSettings {
 	sort.Slice(ss.StorageFolders, func(i, j int) bool {
 		return ss.StorageFolders[i].Index < ss.StorageFolders[j].Index
 	})
+	sort.Slice(ss.OrphanedSectors, func(i, j int) bool {
+		return bytes.Compare(ss.OrphanedSectors[i].ID[:], ss.OrphanedSectors[j].ID[:]) < 0
+	})
 	return ss
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -796,3 +797,100 @@ func TestFolderRechecker(t *testing.T) {
 		t.Error("the storage folder growth does not seem to have worked")
 	}
 }
+
+// TestLoadSectorLocationsCorruptedFolder checks that a storage folder with
+// corrupted metadata doesn't prevent the sector locations of the other storage
+// folders from being loaded at startup.
+func TestLoadSectorLocationsCorruptedFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyNoRecheck)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a few storage folders.
+	numFolders := 3
+	for i := 0; i < numFolders; i++ {
+		dir := filepath.Join(cmt.persistDir, "storageFolder"+strconv.Itoa(i))
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add some sectors and remember which folder they were added to.
+	numSectors := 10
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	paths := make([]string, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		err = cmt.cm.AddSector(roots[i], datas[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmt.cm.sectorMu.Lock()
+		sl := cmt.cm.sectorLocations[cmt.cm.managedSectorID(roots[i])]
+		paths[i] = cmt.cm.storageFolders[sl.storageFolder].path
+		cmt.cm.sectorMu.Unlock()
+	}
+
+	// Close the contract manager and corrupt the metadata of the folder
+	// containing the first sector by truncating it.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := paths[0]
+	err = os.Truncate(filepath.Join(corrupted, metadataFile), sectorMetadataDiskSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-open the contract manager.
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The corrupted folder should be reported with a failed read and the
+	// others should be fine.
+	sfs := cmt.cm.StorageFolders()
+	if len(sfs) != numFolders {
+		t.Fatal("wrong number of storage folders being reported", len(sfs))
+	}
+	for _, sf := range sfs {
+		if sf.Path == corrupted && sf.FailedReads == 0 {
+			t.Error("expected failed read for corrupted folder")
+		} else if sf.Path != corrupted && sf.FailedReads != 0 {
+			t.Error("unexpected failed read for healthy folder", sf.Path)
+		}
+	}
+
+	// The sectors of the healthy folders should be readable while the sectors
+	// of the corrupted folder are missing.
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if paths[i] == corrupted {
+			if err == nil {
+				t.Fatal("expected error when reading sector of corrupted folder")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("wrong data returned")
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package contractmanager
+
+import (
+	"container/list"
+	"sync"
+
+	"go.sia.tech/siad/modules"
+)
+
+type (
+	// readCache is an in-memory LRU cache of full sectors which allows hosts
+	// serving popular content to avoid reading the same sectors from disk over
+	// and over.
+	//
+	// The cache doesn't have its own notion of which sectors exist. Callers
+	// need to hold the sector lock of a sector while accessing its entry and
+	// need to check the sector's location before using a cached entry. That
+	// way a cached entry can never be returned for a sector that was just
+	// deleted or overwritten.
+	readCache struct {
+		// maxSize is the byte budget of the cache. A maxSize of 0 disables
+		// the cache.
+		maxSize uint64
+		size    uint64
+
+		// entries maps the cached sectors to their elements in the lru list.
+		// locations maps the on-disk location of each cached sector to its id
+		// to be able to invalidate an entry when its location is written to.
+		entries   map[sectorID]*list.Element
+		locations map[readCacheLocation]sectorID
+		lru       *list.List
+
+		hits   uint64
+		misses uint64
+
+		mu sync.Mutex
+	}
+
+	// readCacheEntry is a single sector within the readCache.
+	readCacheEntry struct {
+		id       sectorID
+		location readCacheLocation
+		data     []byte
+	}
+
+	// readCacheLocation is the on-disk location of a cached sector.
+	readCacheLocation struct {
+		storageFolder uint16
+		index         uint32
+	}
+)
+
+// newReadCache creates a new readCache with the provided byte budget.
+func newReadCache(maxSize uint64) *readCache {
+	return &readCache{
+		maxSize:   maxSize,
+		entries:   make(map[sectorID]*list.Element),
+		locations: make(map[readCacheLocation]sectorID),
+		lru:       list.New(),
+	}
+}
+
+// callAdd adds the data of a sector that was read from the provided location
+// to the cache. The data must not be modified after adding it.
+func (rc *readCache) callAdd(id sectorID, storageFolder uint16, index uint32, data []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if uint64(len(data)) > rc.maxSize {
+		return
+	}
+	rc.remove(id)
+	location := readCacheLocation{storageFolder: storageFolder, index: index}
+	if oldID, exists := rc.locations[location]; exists {
+		rc.remove(oldID)
+	}
+	rc.entries[id] = rc.lru.PushFront(&readCacheEntry{
+		id:       id,
+		location: location,
+		data:     data,
+	})
+	rc.locations[location] = id
+	rc.size += uint64(len(data))
+	rc.evict()
+}
+
+// callGet returns the cached data of a sector if it was read from the provided
+// location. An entry for a different location is considered stale and is
+// removed. Hits and misses are only tracked while the cache is enabled.
+func (rc *readCache) callGet(id sectorID, storageFolder uint16, index uint32) ([]byte, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.maxSize == 0 {
+		return nil, false
+	}
+	elem, exists := rc.entries[id]
+	if !exists {
+		rc.misses++
+		return nil, false
+	}
+	entry := elem.Value.(*readCacheEntry)
+	if entry.location != (readCacheLocation{storageFolder: storageFolder, index: index}) {
+		rc.remove(id)
+		rc.misses++
+		return nil, false
+	}
+	rc.lru.MoveToFront(elem)
+	rc.hits++
+	return entry.data, true
+}
+
+// callEnabled returns whether the cache is enabled.
+func (rc *readCache) callEnabled() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.maxSize > 0
+}
+
+// callRemove removes a sector from the cache.
+func (rc *readCache) callRemove(id sectorID) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.remove(id)
+}
+
+// callRemoveLocation removes the sector that was read from the provided
+// location from the cache. It needs to be called before writing to a sector
+// index.
+func (rc *readCache) callRemoveLocation(storageFolder uint16, index uint32) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	id, exists := rc.locations[readCacheLocation{storageFolder: storageFolder, index: index}]
+	if exists {
+		rc.remove(id)
+	}
+}
+
+// callSetMaxSize updates the byte budget of the cache, evicting entries if
+// necessary.
+func (rc *readCache) callSetMaxSize(maxSize uint64) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.maxSize = maxSize
+	rc.evict()
+}
+
+// callStatus returns the status of the cache.
+func (rc *readCache) callStatus() modules.ReadCacheStatus {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return modules.ReadCacheStatus{
+		Capacity: rc.maxSize,
+		Size:     rc.size,
+		Hits:     rc.hits,
+		Misses:   rc.misses,
+	}
+}
+
+// evict removes the least recently used entries until the cache is within its
+// byte budget.
+func (rc *readCache) evict() {
+	for rc.size > rc.maxSize {
+		elem := rc.lru.Back()
+		if elem == nil {
+			return
+		}
+		rc.remove(elem.Value.(*readCacheEntry).id)
+	}
+}
+
+// remove removes a sector from the cache.
+func (rc *readCache) remove(id sectorID) {
+	elem, exists := rc.entries[id]
+	if !exists {
+		return
+	}
+	entry := elem.Value.(*readCacheEntry)
+	rc.lru.Remove(elem)
+	delete(rc.entries, id)
+	delete(rc.locations, entry.location)
+	rc.size -= uint64(len(entry.data))
+}
+
+// ReadCacheStatus returns the status of the sector read cache.
+func (cm *ContractManager) ReadCacheStatus() modules.ReadCacheStatus {
+	return cm.staticReadCache.callStatus()
+}
+
+// SetReadCacheSize sets the byte budget of the sector read cache. A size of 0
+// disables the cache.
+func (cm *ContractManager) SetReadCacheSize(size uint64) {
+	cm.staticReadCache.callSetMaxSize(size)
+}
@@ -0,0 +1,228 @@
+package contractmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/modules"
+)
+
+// TestReadCache is a unit test for the readCache.
+func TestReadCache(t *testing.T) {
+	t.Parallel()
+
+	// randID is a helper to create a random sector id.
+	randID := func() (id sectorID) {
+		fastrand.Read(id[:])
+		return
+	}
+
+	// A disabled cache doesn't cache anything and doesn't track hits or
+	// misses.
+	rc := newReadCache(0)
+	id1 := randID()
+	data1 := fastrand.Bytes(10)
+	rc.callAdd(id1, 0, 1, data1)
+	if _, ok := rc.callGet(id1, 0, 1); ok {
+		t.Fatal("disabled cache shouldn't return data")
+	}
+	if status := rc.callStatus(); status != (modules.ReadCacheStatus{}) {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Enable the cache with room for 2 entries.
+	rc.callSetMaxSize(20)
+	rc.callAdd(id1, 0, 1, data1)
+	id2 := randID()
+	data2 := fastrand.Bytes(10)
+	rc.callAdd(id2, 0, 2, data2)
+	if data, ok := rc.callGet(id1, 0, 1); !ok || !bytes.Equal(data, data1) {
+		t.Fatal("expected cache hit")
+	}
+
+	// A lookup for a different location is a miss and removes the entry.
+	if _, ok := rc.callGet(id2, 1, 2); ok {
+		t.Fatal("expected cache miss for different location")
+	}
+	if _, ok := rc.callGet(id2, 0, 2); ok {
+		t.Fatal("stale entry should be removed")
+	}
+	rc.callAdd(id2, 0, 2, data2)
+
+	// Adding a third entry evicts the least recently used one.
+	if _, ok := rc.callGet(id1, 0, 1); !ok {
+		t.Fatal("expected cache hit")
+	}
+	id3 := randID()
+	rc.callAdd(id3, 0, 3, fastrand.Bytes(10))
+	if _, ok := rc.callGet(id2, 0, 2); ok {
+		t.Fatal("least recently used entry should be evicted")
+	}
+	if _, ok := rc.callGet(id1, 0, 1); !ok {
+		t.Fatal("recently used entry shouldn't be evicted")
+	}
+
+	// Writing to a location invalidates the entry.
+	rc.callRemoveLocation(0, 1)
+	if _, ok := rc.callGet(id1, 0, 1); ok {
+		t.Fatal("entry should be invalidated")
+	}
+
+	// Removing an entry by id.
+	rc.callRemove(id3)
+	if _, ok := rc.callGet(id3, 0, 3); ok {
+		t.Fatal("entry should be removed")
+	}
+	status := rc.callStatus()
+	expected := modules.ReadCacheStatus{
+		Capacity: 20,
+		Size:     0,
+		Hits:     3,
+		Misses:   5,
+	}
+	if status != expected {
+		t.Fatalf("expected status %v but got %v", expected, status)
+	}
+
+	// Entries larger than the cache aren't added and shrinking the cache
+	// evicts entries.
+	rc.callAdd(id1, 0, 1, fastrand.Bytes(21))
+	if status := rc.callStatus(); status.Size != 0 {
+		t.Fatal("entry shouldn't be added", status)
+	}
+	rc.callAdd(id1, 0, 1, data1)
+	rc.callAdd(id2, 0, 2, data2)
+	rc.callSetMaxSize(10)
+	if status := rc.callStatus(); status.Size != 10 {
+		t.Fatal("cache should be shrunk", status)
+	}
+	if _, ok := rc.callGet(id2, 0, 2); !ok {
+		t.Fatal("most recently used entry should remain")
+	}
+}
+
+// TestContractManagerReadCache tests that the contract manager serves reads
+// from the read cache and invalidates the cache when sectors are deleted.
+func TestContractManagerReadCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+	cm := cmt.cm
+
+	// Add a storage folder.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a sector.
+	root, data := randSector()
+	err = cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The cache is disabled by default.
+	if _, err := cm.ReadSector(root); err != nil {
+		t.Fatal(err)
+	}
+	if status := cm.ReadCacheStatus(); status != (modules.ReadCacheStatus{}) {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Enable the cache and read a partial sector twice. The first read is a
+	// miss which populates the cache and the second one a hit.
+	cm.SetReadCacheSize(2 * modules.SectorSize)
+	offset, length := uint64(64), uint64(128)
+	for i := 0; i < 2; i++ {
+		partial, err := cm.ReadPartialSector(root, offset, length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(partial, data[offset:offset+length]) {
+			t.Fatal("wrong data returned")
+		}
+		// Modifying the returned data mustn't modify the cache.
+		fastrand.Read(partial)
+	}
+	full, err := cm.ReadSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(full, data) {
+		t.Fatal("wrong data returned")
+	}
+	status := cm.ReadCacheStatus()
+	expected := modules.ReadCacheStatus{
+		Capacity: 2 * modules.SectorSize,
+		Size:     modules.SectorSize,
+		Hits:     2,
+		Misses:   1,
+	}
+	if status != expected {
+		t.Fatalf("expected status %v but got %v", expected, status)
+	}
+
+	// Deleting the sector invalidates the cache.
+	err = cm.DeleteSector(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := cm.ReadCacheStatus(); status.Size != 0 {
+		t.Fatal("cache should be empty", status)
+	}
+	if _, err := cm.ReadSector(root); !errors.Contains(err, ErrSectorNotFound) {
+		t.Fatal("expected ErrSectorNotFound", err)
+	}
+
+	// Add the sector twice and remove it once. It should still be cached. After
+	// removing it again it should be gone.
+	if err := cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.ReadSector(root); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.RemoveSector(root); err != nil {
+		t.Fatal(err)
+	}
+	if status := cm.ReadCacheStatus(); status.Size != modules.SectorSize {
+		t.Fatal("sector should still be cached", status)
+	}
+	if err := cm.RemoveSector(root); err != nil {
+		t.Fatal(err)
+	}
+	if status := cm.ReadCacheStatus(); status.Size != 0 {
+		t.Fatal("cache should be empty", status)
+	}
+
+	// Disabling the cache clears it.
+	if err := cm.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cm.ReadSector(root); err != nil {
+		t.Fatal(err)
+	}
+	cm.SetReadCacheSize(0)
+	if status := cm.ReadCacheStatus(); status.Size != 0 || status.Capacity != 0 {
+		t.Fatal("cache should be empty", status)
+	}
+}
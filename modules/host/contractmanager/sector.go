@@ -1,8 +1,11 @@
 package contractmanager
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"hash/crc32"
 	"sync"
 	"sync/atomic"
 
@@ -15,10 +18,22 @@ var (
 	// ErrSectorNotFound is returned when a lookup for a sector fails.
 	ErrSectorNotFound = errors.New("could not find the desired sector")
 
+	// ErrUnalignedRead is returned by ReadPartialSectorWithProof if the
+	// requested range isn't aligned to segment boundaries.
+	ErrUnalignedRead = fmt.Errorf("offset and length need to be multiples of the segment size %v", crypto.SegmentSize)
+
 	// errDiskTrouble is returned when the host is supposed to have enough
 	// storage to hold a new sector but failures that are likely related to the
 	// disk have prevented the host from successfully adding the sector.
 	errDiskTrouble = errors.New("host unable to add sector despite having the storage capacity to do so")
+
+	// sectorMetadataChecksumTable is the table used to compute the CRC32c
+	// checksums of the on-disk sector metadata.
+	sectorMetadataChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// emptySectorMetadata is the on-disk metadata of a slot which was never
+	// written.
+	emptySectorMetadata [sectorMetadataDiskSize]byte
 )
 
 // sectorLocation indicates the location of a sector on disk.
@@ -35,10 +50,19 @@ type (
 		storageFolder uint16
 
 		// count indicates the number of virtual sectors represented by the
-		// physical sector described by this object. A maximum of 2^16 virtual
-		// sectors are allowed for each sector. Proper use by the renter should
-		// mean that the host never has more than 3 virtual sectors for any sector.
+		// physical sector described by this object. On disk, the first 2^16-1
+		// virtual sectors are stored in the sector metadata and the remainder
+		// in the overflow file, which allows for up to 2^64-1 virtual sectors
+		// for each sector. Proper use by the renter should mean that the host
+		// never has more than 3 virtual sectors for any sector.
 		count uint64
+
+		// ReadCount and WriteCount are the number of times the sector was read
+		// and written since the contract manager was started. They are only
+		// tracked in memory and can be used to identify frequently accessed
+		// sectors.
+		ReadCount  uint64
+		WriteCount uint64
 	}
 
 	// sectorLock contains a lock plus a count of the number of threads
@@ -69,14 +93,40 @@ func readSector(f modules.File, sectorIndex uint32) ([]byte, error) {
 	return readPartialSector(f, sectorIndex, 0, modules.SectorSize)
 }
 
-// readFullMetadata will read a full sector metadata file into memory.
-func readFullMetadata(f modules.File, numSectors int) ([]byte, error) {
+// readFullMetadata will read a full sector metadata file into memory. The
+// indices of the entries with a bad checksum are returned alongside the
+// metadata.
+func readFullMetadata(f modules.File, numSectors int) ([]byte, []uint32, error) {
 	sectorLookupBytes := make([]byte, numSectors*sectorMetadataDiskSize)
 	_, err := f.ReadAt(sectorLookupBytes, 0)
 	if err != nil {
-		return nil, build.ExtendErr("unable to read metadata file for target storage folder", err)
+		return nil, nil, build.ExtendErr("unable to read metadata file for target storage folder", err)
 	}
-	return sectorLookupBytes, nil
+	var corrupt []uint32
+	for i := 0; i < numSectors; i++ {
+		if !validSectorMetadata(sectorLookupBytes[i*sectorMetadataDiskSize : (i+1)*sectorMetadataDiskSize]) {
+			corrupt = append(corrupt, uint32(i))
+		}
+	}
+	return sectorLookupBytes, corrupt, nil
+}
+
+// encodeSectorMetadata encodes the metadata of a sector into entry, which
+// needs to be sectorMetadataDiskSize bytes long.
+func encodeSectorMetadata(entry []byte, id sectorID, count uint16) {
+	copy(entry, id[:])
+	binary.LittleEndian.PutUint16(entry[12:], count)
+	binary.LittleEndian.PutUint32(entry[14:], crc32.Checksum(entry[:14], sectorMetadataChecksumTable))
+}
+
+// validSectorMetadata returns whether the checksum of a sector metadata entry
+// matches its content. Entries which were never written consist of zeros
+// only and are valid too.
+func validSectorMetadata(entry []byte) bool {
+	if bytes.Equal(entry, emptySectorMetadata[:]) {
+		return true
+	}
+	return binary.LittleEndian.Uint32(entry[14:]) == crc32.Checksum(entry[:14], sectorMetadataChecksumTable)
 }
 
 // writeSector will write the given sector into the given file at the given
@@ -93,8 +143,21 @@ func writeSector(f modules.File, sectorIndex uint32, data []byte) error {
 // to disk.
 func writeSectorMetadata(f modules.File, sectorIndex uint32, id sectorID, count uint16) error {
 	writeData := make([]byte, sectorMetadataDiskSize)
-	copy(writeData, id[:])
-	binary.LittleEndian.PutUint16(writeData[12:], count)
+	encodeSectorMetadata(writeData, id, count)
+	_, err := f.WriteAt(writeData, sectorMetadataDiskSize*int64(sectorIndex))
+	if err != nil {
+		return build.ExtendErr("unable to write in given file", err)
+	}
+	return nil
+}
+
+// writeConsecutiveSectorMetadata writes the metadata of sectors with consecutive
+// indices, starting at sectorIndex, with a single write.
+func writeConsecutiveSectorMetadata(f modules.File, sectorIndex uint32, ids []sectorID, counts []uint16) error {
+	writeData := make([]byte, sectorMetadataDiskSize*len(ids))
+	for i, id := range ids {
+		encodeSectorMetadata(writeData[i*sectorMetadataDiskSize:], id, counts[i])
+	}
 	_, err := f.WriteAt(writeData, sectorMetadataDiskSize*int64(sectorIndex))
 	if err != nil {
 		return build.ExtendErr("unable to write in given file", err)
@@ -120,8 +183,8 @@ func (cm *ContractManager) managedSectorID(sectorRoot crypto.Hash) (id sectorID)
 
 // ReadPartialSector will read a sector from the storage manager, returning the
 // 'length' bytes at offset 'offset' that match the input sector root.
-func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length uint64) ([]byte, error) {
-	err := cm.tg.Add()
+func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length uint64) (_ []byte, err error) {
+	err = cm.tg.Add()
 	if err != nil {
 		return nil, err
 	}
@@ -131,6 +194,13 @@ func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length ui
 	// Fetch the sector metadata.
 	cm.wal.managedLockSector(id)
 	defer cm.wal.managedUnlockSector(id)
+
+	// Count successful reads. This happens while the sector is still locked.
+	defer func() {
+		if err == nil {
+			cm.managedIncrementReadCount(id)
+		}
+	}()
 	cm.sectorMu.Lock()
 	sl, exists1 := cm.sectorLocations[id]
 	sf, exists2 := cm.storageFolders[sl.storageFolder]
@@ -147,6 +217,31 @@ func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length ui
 		return nil, ErrSectorNotFound
 	}
 
+	// Serve the read from the read cache if possible. The sector lock
+	// guarantees that the sector isn't deleted or overwritten in the meantime.
+	if offset+length > modules.SectorSize {
+		return nil, errors.New("ReadPartialSector: read is out of bounds")
+	}
+	if cached, ok := cm.staticReadCache.callGet(id, sl.storageFolder, sl.index); ok {
+		sectorData := make([]byte, length)
+		copy(sectorData, cached[offset:])
+		return sectorData, nil
+	}
+
+	// If the read cache is enabled, read the full sector to populate it.
+	if cm.staticReadCache.callEnabled() {
+		fullSector, err := readSector(sf.sectorFile, sl.index)
+		if err != nil {
+			atomic.AddUint64(&sf.atomicFailedReads, 1)
+			return nil, build.ExtendErr("unable to fetch sector", err)
+		}
+		atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+		cm.staticReadCache.callAdd(id, sl.storageFolder, sl.index, fullSector)
+		sectorData := make([]byte, length)
+		copy(sectorData, fullSector[offset:])
+		return sectorData, nil
+	}
+
 	// Read the sector.
 	sectorData, err := readPartialSector(sf.sectorFile, sl.index, offset, length)
 	if err != nil {
@@ -157,12 +252,47 @@ func (cm *ContractManager) ReadPartialSector(root crypto.Hash, offset, length ui
 	return sectorData, nil
 }
 
+// managedIncrementReadCount increments the read counter of a sector. The
+// sector needs to be locked by the caller.
+func (cm *ContractManager) managedIncrementReadCount(id sectorID) {
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+	sl, exists := cm.sectorLocations[id]
+	if !exists {
+		return
+	}
+	sl.ReadCount++
+	cm.sectorLocations[id] = sl
+}
+
 // ReadSector will read a sector from the storage manager, returning the bytes
 // that match the input sector root.
 func (cm *ContractManager) ReadSector(root crypto.Hash) ([]byte, error) {
 	return cm.ReadPartialSector(root, 0, modules.SectorSize)
 }
 
+// ReadPartialSectorWithProof will read a sector from the storage manager,
+// returning the 'length' bytes at offset 'offset' that match the input sector
+// root together with a merkle range proof for the data. Since the proof is
+// computed for full segments, offset and length need to be segment aligned.
+func (cm *ContractManager) ReadPartialSectorWithProof(root crypto.Hash, offset, length uint64) ([]byte, []crypto.Hash, error) {
+	if offset%crypto.SegmentSize != 0 || length%crypto.SegmentSize != 0 || length == 0 {
+		return nil, nil, ErrUnalignedRead
+	}
+	if offset > modules.SectorSize || length > modules.SectorSize-offset {
+		return nil, nil, errors.New("ReadPartialSectorWithProof: read is out of bounds")
+	}
+	// The proof requires the full sector.
+	sectorData, err := cm.ReadSector(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	proofStart := int(offset / crypto.SegmentSize)
+	proofEnd := int((offset + length) / crypto.SegmentSize)
+	proof := crypto.MerkleRangeProof(sectorData, proofStart, proofEnd)
+	return sectorData[offset : offset+length], proof, nil
+}
+
 // HasSector indicates whether the contract manager stores a sector with
 // a given root or not.
 func (cm *ContractManager) HasSector(sectorRoot crypto.Hash) bool {
@@ -177,6 +307,42 @@ func (cm *ContractManager) HasSector(sectorRoot crypto.Hash) bool {
 	return exists
 }
 
+// HasSectors indicates for every provided root whether the contract manager
+// stores the corresponding sector. The sector locations are looked up while
+// holding the lock only once which makes it a lot faster than calling
+// HasSector for every root.
+func (cm *ContractManager) HasSectors(sectorRoots []crypto.Hash) ([]bool, error) {
+	if len(sectorRoots) > modules.MaxHasSectorsBatchSize {
+		return nil, modules.ErrHasSectorsBatchTooLarge
+	}
+	// Compute the ids before acquiring the lock.
+	ids := make([]sectorID, len(sectorRoots))
+	for i, root := range sectorRoots {
+		ids[i] = cm.managedSectorID(root)
+	}
+
+	// Check if they exist.
+	exists := make([]bool, len(ids))
+	cm.sectorMu.Lock()
+	for i, id := range ids {
+		_, exists[i] = cm.sectorLocations[id]
+	}
+	cm.sectorMu.Unlock()
+	return exists, nil
+}
+
+// SectorCount returns the number of physical sectors stored by the contract
+// manager and the number of virtual sectors they represent.
+func (cm *ContractManager) SectorCount() (physicalSectors, virtualSectors uint64) {
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+	for _, sl := range cm.sectorLocations {
+		physicalSectors++
+		virtualSectors += sl.count
+	}
+	return
+}
+
 // managedLockSector grabs a sector lock.
 func (wal *writeAheadLog) managedLockSector(id sectorID) {
 	wal.cm.sectorMu.Lock()
@@ -1,11 +1,17 @@
 package contractmanager
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 )
 
@@ -61,3 +67,280 @@ func TestHasSector(t *testing.T) {
 		t.Fatal(fmt.Sprintf("Unexpected HasSector response: %v, sector has been deleted", exists))
 	}
 }
+
+// TestSectorCount verifies that SectorCount returns the correct number of
+// physical and virtual sectors.
+func TestSectorCount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkCount is a helper to check the counts.
+	checkCount := func(physical, virtual uint64) {
+		t.Helper()
+		p, v := cmt.cm.SectorCount()
+		if p != physical || v != virtual {
+			t.Fatalf("expected %v physical and %v virtual sectors but got %v and %v", physical, virtual, p, v)
+		}
+	}
+	checkCount(0, 0)
+
+	// Add two sectors.
+	root1, data1 := randSector()
+	root2, data2 := randSector()
+	if err := cmt.cm.AddSector(root1, data1); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddSector(root2, data2); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(2, 2)
+
+	// Add virtual copies of the first sector.
+	if err := cmt.cm.AddSector(root1, data1); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(2, 3)
+
+	// Add another virtual copy of both sectors. The batch is added in the
+	// background.
+	if err := cmt.cm.AddSectorBatch([]crypto.Hash{root1, root2}); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if p, v := cmt.cm.SectorCount(); p != 2 || v != 5 {
+			return fmt.Errorf("expected 2 physical and 5 virtual sectors but got %v and %v", p, v)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remove a virtual copy and then the second sector entirely.
+	if err := cmt.cm.RemoveSector(root1); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(2, 4)
+	if err := cmt.cm.RemoveSectorBatch([]crypto.Hash{root2, root2}); err != nil {
+		t.Fatal(err)
+	}
+	checkCount(1, 2)
+}
+
+// TestReadPartialSectorWithProof verifies that the proofs returned by
+// ReadPartialSectorWithProof are valid for the returned data.
+func TestReadPartialSectorWithProof(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a sector.
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Read different ranges and verify the proofs.
+	tests := []struct {
+		offset uint64
+		length uint64
+	}{
+		{0, crypto.SegmentSize},
+		{crypto.SegmentSize, crypto.SegmentSize},
+		{crypto.SegmentSize * 3, crypto.SegmentSize * 10},
+		{modules.SectorSize / 2, modules.SectorSize / 4},
+		{modules.SectorSize - crypto.SegmentSize, crypto.SegmentSize},
+		{0, modules.SectorSize},
+	}
+	for _, test := range tests {
+		readData, proof, err := cmt.cm.ReadPartialSectorWithProof(root, test.offset, test.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(readData, data[test.offset:test.offset+test.length]) {
+			t.Fatal("wrong data returned", test.offset, test.length)
+		}
+		proofStart := int(test.offset / crypto.SegmentSize)
+		proofEnd := int((test.offset + test.length) / crypto.SegmentSize)
+		if !crypto.VerifyRangeProof(readData, proof, proofStart, proofEnd, root) {
+			t.Fatal("failed to verify proof", test.offset, test.length)
+		}
+	}
+
+	// Unaligned reads should fail.
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(root, 1, crypto.SegmentSize)
+	if !errors.Contains(err, ErrUnalignedRead) {
+		t.Fatal("expected ErrUnalignedRead", err)
+	}
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(root, 0, crypto.SegmentSize+1)
+	if !errors.Contains(err, ErrUnalignedRead) {
+		t.Fatal("expected ErrUnalignedRead", err)
+	}
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(root, 0, 0)
+	if !errors.Contains(err, ErrUnalignedRead) {
+		t.Fatal("expected ErrUnalignedRead", err)
+	}
+
+	// Out of bounds reads should fail.
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(root, modules.SectorSize, crypto.SegmentSize)
+	if err == nil {
+		t.Fatal("expected out of bounds read to fail")
+	}
+	// The end of this read overflows to 0.
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(root, crypto.SegmentSize, math.MaxUint64-crypto.SegmentSize+1)
+	if err == nil {
+		t.Fatal("expected overflowing read to fail")
+	}
+
+	// Reading a sector that doesn't exist should fail.
+	missingRoot, _ := randSector()
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(missingRoot, 0, crypto.SegmentSize)
+	if !errors.Contains(err, ErrSectorNotFound) {
+		t.Fatal("expected ErrSectorNotFound", err)
+	}
+}
+
+// TestSectorIOStats verifies that the read and write counters of sectors are
+// incremented on every read and write, aggregated per storage folder and reset
+// after a restart.
+func TestSectorIOStats(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkStats is a helper to check the stats of the storage folder.
+	checkStats := func(read, written uint64) {
+		t.Helper()
+		stats := cmt.cm.StorageFolderStats()
+		if len(stats) != 1 {
+			t.Fatal("expected stats for a single folder", len(stats))
+		}
+		if stats[0].Folder != storageFolderDir {
+			t.Fatal("wrong folder", stats[0].Folder)
+		}
+		if stats[0].SectorsRead != read || stats[0].SectorsWritten != written {
+			t.Fatalf("expected %v reads and %v writes but got %v and %v", read, written, stats[0].SectorsRead, stats[0].SectorsWritten)
+		}
+		if stats[0].FailedReads != 0 || stats[0].FailedWrites != 0 {
+			t.Fatal("there shouldn't be any failures", stats[0])
+		}
+	}
+	checkStats(0, 0)
+
+	// Add two sectors.
+	root1, data1 := randSector()
+	err = cmt.cm.AddSector(root1, data1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root2, data2 := randSector()
+	err = cmt.cm.AddSector(root2, data2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(0, 2)
+
+	// Read the first sector a few times using the different read methods.
+	_, err = cmt.cm.ReadSector(root1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = cmt.cm.ReadPartialSector(root1, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = cmt.cm.ReadPartialSectorWithProof(root1, 0, crypto.SegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(3, 2)
+
+	// Check the per-sector counters.
+	cmt.cm.sectorMu.Lock()
+	sl1 := cmt.cm.sectorLocations[cmt.cm.managedSectorID(root1)]
+	sl2 := cmt.cm.sectorLocations[cmt.cm.managedSectorID(root2)]
+	cmt.cm.sectorMu.Unlock()
+	if sl1.ReadCount != 3 || sl1.WriteCount != 1 {
+		t.Fatal("wrong counters for first sector", sl1.ReadCount, sl1.WriteCount)
+	}
+	if sl2.ReadCount != 0 || sl2.WriteCount != 1 {
+		t.Fatal("wrong counters for second sector", sl2.ReadCount, sl2.WriteCount)
+	}
+
+	// Failed reads of missing sectors shouldn't be counted.
+	missingRoot, _ := randSector()
+	_, err = cmt.cm.ReadSector(missingRoot)
+	if !errors.Contains(err, ErrSectorNotFound) {
+		t.Fatal("expected ErrSectorNotFound", err)
+	}
+	checkStats(3, 2)
+
+	// The counters are not persisted and should be reset after a restart.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(0, 0)
+	_, err = cmt.cm.ReadSector(root2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkStats(1, 0)
+}
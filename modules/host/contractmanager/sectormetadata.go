@@ -0,0 +1,175 @@
+package contractmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/modules"
+)
+
+// upgradeLegacySectorMetadata upgrades the metadata file of a storage folder
+// which was created before checksums were added to the sector metadata. It is
+// only called for folders marked as legacy by the settings. The upgraded
+// metadata is written to a temporary file which then replaces the legacy file,
+// which makes it safe to interrupt the upgrade. If the upgrade was interrupted
+// after replacing the file but before the settings were synced, the folder is
+// still marked as legacy but the metadata already has the upgraded size, in
+// which case it is left untouched.
+//
+// COMPATV160
+func (cm *ContractManager) upgradeLegacySectorMetadata(sf *storageFolder) error {
+	numSectors := int64(len(sf.usage)) * storageFolderGranularity
+	fi, err := sf.metadataFile.Stat()
+	if err != nil {
+		return errors.AddContext(err, "unable to stat the metadata file")
+	}
+	if fi.Size() == numSectors*sectorMetadataDiskSize {
+		sf.legacyMetadata = false
+		return nil
+	}
+	if fi.Size() != numSectors*legacySectorMetadataDiskSize {
+		return fmt.Errorf("legacy metadata file has size %v but expected %v", fi.Size(), numSectors*legacySectorMetadataDiskSize)
+	}
+	legacy := make([]byte, fi.Size())
+	_, err = sf.metadataFile.ReadAt(legacy, 0)
+	if err != nil {
+		return errors.AddContext(err, "unable to read the legacy metadata")
+	}
+
+	// Add the checksums to the entries. Entries which were never written are
+	// left empty.
+	upgraded := make([]byte, numSectors*sectorMetadataDiskSize)
+	for i := int64(0); i < numSectors; i++ {
+		entry := legacy[i*legacySectorMetadataDiskSize : (i+1)*legacySectorMetadataDiskSize]
+		if bytes.Equal(entry, emptySectorMetadata[:legacySectorMetadataDiskSize]) {
+			continue
+		}
+		var id sectorID
+		copy(id[:], entry[:12])
+		encodeSectorMetadata(upgraded[i*sectorMetadataDiskSize:], id, binary.LittleEndian.Uint16(entry[12:]))
+	}
+
+	// Write the upgraded metadata to a temporary file and replace the legacy
+	// file with it.
+	path := filepath.Join(sf.path, metadataFile)
+	tmpPath := path + "_upgrade"
+	f, err := cm.dependencies.CreateFile(tmpPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to create the upgraded metadata file")
+	}
+	_, err = f.Write(upgraded)
+	if err != nil {
+		return errors.Compose(errors.AddContext(err, "unable to write the upgraded metadata"), f.Close())
+	}
+	err = errors.Compose(f.Sync(), f.Close(), sf.metadataFile.Close())
+	if err != nil {
+		return errors.AddContext(err, "unable to sync the upgraded metadata")
+	}
+	err = cm.dependencies.RenameFile(tmpPath, path)
+	if err != nil {
+		return errors.AddContext(err, "unable to replace the legacy metadata")
+	}
+	sf.metadataFile, err = cm.dependencies.OpenFile(path, os.O_RDWR, 0700)
+	if err != nil {
+		return errors.AddContext(err, "unable to open the upgraded metadata")
+	}
+	sf.legacyMetadata = false
+	cm.log.Printf("Upgraded the sector metadata of folder %v\n", sf.path)
+	return nil
+}
+
+// VerifySectorMetadata reads the on-disk sector metadata of all available
+// storage folders and verifies the checksums of all entries. The entries of
+// the sectors known to the contract manager are also compared to the sector
+// locations in memory. The results are logged and returned for each folder.
+func (cm *ContractManager) VerifySectorMetadata() ([]modules.SectorMetadataReport, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer cm.tg.Done()
+
+	cm.sectorMu.Lock()
+	var folders []*storageFolder
+	for _, sf := range cm.storageFolders {
+		folders = append(folders, sf)
+	}
+	cm.sectorMu.Unlock()
+	sort.Slice(folders, func(i, j int) bool {
+		return folders[i].index < folders[j].index
+	})
+
+	var reports []modules.SectorMetadataReport
+	for _, sf := range folders {
+		report, err := cm.managedVerifyFolderMetadata(sf)
+		if err != nil {
+			cm.log.Printf("ERROR: unable to verify the sector metadata of folder %v: %v\n", sf.path, err)
+			report.Unavailable = true
+		}
+		if report.Corrupted > 0 || report.Mismatched > 0 {
+			cm.log.Printf("ERROR: verified %v sector metadata entries of folder %v, %v have a bad checksum and %v don't match the sector locations\n", report.Checked, sf.path, report.Corrupted, report.Mismatched)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// managedVerifyFolderMetadata verifies the on-disk sector metadata of a single
+// storage folder.
+func (cm *ContractManager) managedVerifyFolderMetadata(sf *storageFolder) (modules.SectorMetadataReport, error) {
+	report := modules.SectorMetadataReport{
+		Folder: sf.path,
+	}
+
+	// Block sectors from being written to the folder while its metadata is
+	// verified.
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		report.Unavailable = true
+		return report, nil
+	}
+	numSectors := len(sf.usage) * storageFolderGranularity
+	sectorLookupBytes, corrupt, err := readFullMetadata(sf.metadataFile, numSectors)
+	if err != nil {
+		atomic.AddUint64(&sf.atomicFailedReads, 1)
+		return report, err
+	}
+	atomic.AddUint64(&sf.atomicSuccessfulReads, 1)
+	report.Checked = uint64(numSectors)
+	report.Corrupted = uint64(len(corrupt))
+	for _, sectorIndex := range corrupt {
+		cm.log.Printf("ERROR: sector metadata at index %v of folder %v has a bad checksum\n", sectorIndex, sf.path)
+	}
+
+	// Compare the sector locations to the metadata.
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+	for id, sl := range cm.sectorLocations {
+		if sl.storageFolder != sf.index {
+			continue
+		}
+		count := uint16(math.MaxUint16)
+		if sl.count < math.MaxUint16 {
+			count = uint16(sl.count)
+		}
+		expected := make([]byte, sectorMetadataDiskSize)
+		encodeSectorMetadata(expected, id, count)
+		readHead := int(sl.index) * sectorMetadataDiskSize
+		if !bytes.Equal(sectorLookupBytes[readHead:readHead+sectorMetadataDiskSize], expected) {
+			cm.log.Printf("ERROR: sector metadata at index %v of folder %v doesn't match the sector location\n", sl.index, sf.path)
+			report.Mismatched++
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,301 @@
+package contractmanager
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// TestSectorMetadataChecksum is a unit test for encodeSectorMetadata and
+// validSectorMetadata.
+func TestSectorMetadataChecksum(t *testing.T) {
+	t.Parallel()
+
+	// Empty entries are valid.
+	entry := make([]byte, sectorMetadataDiskSize)
+	if !validSectorMetadata(entry) {
+		t.Fatal("empty entry should be valid")
+	}
+
+	// Encoded entries are valid.
+	var id sectorID
+	fastrand.Read(id[:])
+	encodeSectorMetadata(entry, id, 3)
+	if !validSectorMetadata(entry) {
+		t.Fatal("encoded entry should be valid")
+	}
+	if !bytes.Equal(entry[:12], id[:]) || binary.LittleEndian.Uint16(entry[12:]) != 3 {
+		t.Fatal("wrong encoding")
+	}
+
+	// Flipping any bit invalidates the entry.
+	for i := 0; i < sectorMetadataDiskSize*8; i++ {
+		corrupted := append([]byte(nil), entry...)
+		corrupted[i/8] ^= 1 << (i % 8)
+		if validSectorMetadata(corrupted) {
+			t.Fatal("corrupted entry should be invalid", i)
+		}
+	}
+}
+
+// corruptSectorMetadata flips a bit in the on-disk metadata entry at the
+// provided index of the storage folder at path.
+func corruptSectorMetadata(t *testing.T, path string, index uint32) {
+	t.Helper()
+	f, err := os.OpenFile(filepath.Join(path, metadataFile), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	b := make([]byte, 1)
+	off := int64(index)*sectorMetadataDiskSize + int64(fastrand.Intn(sectorMetadataDiskSize))
+	if _, err := f.ReadAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+	b[0] ^= 1 << uint(fastrand.Intn(8))
+	if _, err := f.WriteAt(b, off); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLoadCorruptedSectorMetadata checks that sectors with a bad metadata
+// checksum are detected at startup, that they are unavailable and that their
+// slots aren't reused.
+func TestLoadCorruptedSectorMetadata(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyNoRecheck)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder and some sectors.
+	dir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity); err != nil {
+		t.Fatal(err)
+	}
+	numSectors := 10
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without corruption, the verification passes.
+	reports, err := cmt.cm.VerifySectorMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := modules.SectorMetadataReport{Folder: dir, Checked: storageFolderGranularity}
+	if len(reports) != 1 || reports[0] != expected {
+		t.Fatal("unexpected reports", reports)
+	}
+
+	// Corrupt the metadata of the first sector while the contract manager is
+	// running. The verification should detect it.
+	cmt.cm.sectorMu.Lock()
+	corruptedIndex := cmt.cm.sectorLocations[cmt.cm.managedSectorID(roots[0])].index
+	cmt.cm.sectorMu.Unlock()
+	corruptSectorMetadata(t, dir, corruptedIndex)
+	reports, err = cmt.cm.VerifySectorMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.Corrupted = 1
+	expected.Mismatched = 1
+	if len(reports) != 1 || reports[0] != expected {
+		t.Fatal("unexpected reports", reports)
+	}
+
+	// Restart the contract manager.
+	capacityRemaining := cmt.cm.StorageFolders()[0].CapacityRemaining
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sector with the corrupted metadata is unavailable while the others
+	// can still be read.
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if i == 0 {
+			if err == nil {
+				t.Fatal("expected error when reading sector with corrupted metadata")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("wrong data returned")
+		}
+	}
+
+	// The slot of the sector is still in use and the corrupted entry is left
+	// on disk for manual recovery.
+	if cmt.cm.StorageFolders()[0].CapacityRemaining != capacityRemaining {
+		t.Fatal("slot of the corrupted sector was freed")
+	}
+	reports, err = cmt.cm.VerifySectorMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected.Mismatched = 0
+	if len(reports) != 1 || reports[0] != expected {
+		t.Fatal("unexpected reports", reports)
+	}
+
+	// Fill up the remaining slots. None of them should overwrite the
+	// corrupted sector.
+	for i := 0; i < storageFolderGranularity-numSectors; i++ {
+		root, data := randSector()
+		if err := cmt.cm.AddSector(root, data); err != nil {
+			t.Fatal(err)
+		}
+		cmt.cm.sectorMu.Lock()
+		index := cmt.cm.sectorLocations[cmt.cm.managedSectorID(root)].index
+		cmt.cm.sectorMu.Unlock()
+		if index == corruptedIndex {
+			t.Fatal("slot of the corrupted sector was reused")
+		}
+	}
+	root, data := randSector()
+	if err := cmt.cm.AddSector(root, data); err == nil {
+		t.Fatal("expected the folder to be full")
+	}
+}
+
+// TestUpgradeLegacySectorMetadata checks that the metadata files of v1.2.0
+// settings are upgraded at startup.
+func TestUpgradeLegacySectorMetadata(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyNoRecheck)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder and some sectors.
+	dir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity*2); err != nil {
+		t.Fatal(err)
+	}
+	numSectors := 10
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Strip the checksums from the metadata.
+	path := filepath.Join(dir, metadataFile)
+	metadata, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var legacy []byte
+	for i := 0; i < len(metadata); i += sectorMetadataDiskSize {
+		legacy = append(legacy, metadata[i:i+legacySectorMetadataDiskSize]...)
+	}
+	if err := ioutil.WriteFile(path, legacy, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Downgrade the settings to v1.2.0.
+	settingsPath := filepath.Join(cmt.persistDir, modules.ContractManagerDir, settingsFile)
+	var ss savedSettings
+	if err := persist.LoadJSON(settingsMetadata, &ss, settingsPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := persist.SaveJSON(settingsMetadataV120, ss, settingsPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the contract manager. The metadata should be upgraded and all
+	// sectors should be available.
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upgraded, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(upgraded, metadata) {
+		t.Fatal("upgraded metadata doesn't match the original metadata")
+	}
+	for i, root := range roots {
+		data, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, datas[i]) {
+			t.Fatal("wrong data returned")
+		}
+	}
+	reports, err := cmt.cm.VerifySectorMetadata()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := modules.SectorMetadataReport{Folder: dir, Checked: storageFolderGranularity * 2}
+	if len(reports) != 1 || reports[0] != expected {
+		t.Fatal("unexpected reports", reports)
+	}
+
+	// The settings should be saved with the current version after a restart,
+	// without the folder being marked as legacy.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ss = savedSettings{}
+	if err := persist.LoadJSON(settingsMetadata, &ss, settingsPath); err != nil {
+		t.Fatal(err)
+	}
+	if len(ss.StorageFolders) != 1 || ss.StorageFolders[0].LegacyMetadata {
+		t.Fatal("unexpected storage folders", ss.StorageFolders)
+	}
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
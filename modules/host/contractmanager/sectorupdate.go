@@ -1,9 +1,11 @@
 package contractmanager
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -52,6 +54,18 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 		return errors.New("malformed sector")
 	}
 
+	// Count the sector as queued until it has been assigned a slot in a
+	// storage folder. The usage of the storage folder is set before the
+	// sector is dequeued, which means the sector might briefly be accounted
+	// for twice but never not at all.
+	atomic.AddUint64(&wal.cm.atomicQueuedSectors, 1)
+	queued := true
+	defer func() {
+		if queued {
+			atomic.AddUint64(&wal.cm.atomicQueuedSectors, ^uint64(0))
+		}
+	}()
+
 	// Find a committed storage folder that has enough space to receive
 	// this sector. Keep trying new storage folders if some return
 	// errors during disk operations.
@@ -92,17 +106,23 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 			// Set the usage, but mark it as uncommitted.
 			sf.setUsage(sectorIndex)
 			sf.availableSectors[id] = sectorIndex
+			atomic.AddUint64(&wal.cm.atomicQueuedSectors, ^uint64(0))
+			queued = false
 			wal.mu.Unlock()
 
 			// NOTE: The usage has been set, in the event of failure the usage
 			// must be cleared.
 
-			// Try writing the new sector to disk.
+			// Try writing the new sector to disk. Any cached data for the
+			// sector index is outdated after that.
+			wal.cm.staticReadCache.callRemoveLocation(sf.index, sectorIndex)
 			err = writeSector(sf.sectorFile, sectorIndex, data)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
 				atomic.AddUint64(&sf.atomicFailedWrites, 1)
 				wal.mu.Lock()
+				atomic.AddUint64(&wal.cm.atomicQueuedSectors, 1)
+				queued = true
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
 				wal.mu.Unlock()
@@ -122,6 +142,8 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
 				atomic.AddUint64(&sf.atomicFailedWrites, 1)
 				wal.mu.Lock()
+				atomic.AddUint64(&wal.cm.atomicQueuedSectors, 1)
+				queued = true
 				sf.clearUsage(sectorIndex)
 				delete(sf.availableSectors, id)
 				wal.mu.Unlock()
@@ -133,6 +155,7 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 				index:         sectorIndex,
 				storageFolder: sf.index,
 				count:         count,
+				WriteCount:    1,
 			}
 			wal.mu.Lock()
 			wal.appendChange(stateChange{
@@ -170,6 +193,151 @@ func (wal *writeAheadLog) managedAddPhysicalSector(id sectorID, data []byte) err
 	return nil
 }
 
+// managedAddPhysicalSectors is a WAL operation to add multiple physical sectors
+// to the contract manager at once. The sectors are placed in as few storage
+// folders as possible and written in the order of their sector index. The
+// metadata of sectors with consecutive indices is written at once.
+func (wal *writeAheadLog) managedAddPhysicalSectors(ids []sectorID, data [][]byte) error {
+	// Sanity check - data should have modules.SectorSize bytes.
+	for _, d := range data {
+		if uint64(len(d)) != modules.SectorSize {
+			wal.cm.log.Critical("sector has the wrong size", modules.SectorSize, len(d))
+			return errors.New("malformed sector")
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Count the sectors as queued until they have been assigned a slot in a
+	// storage folder, see managedAddPhysicalSector.
+	queued := uint64(len(ids))
+	atomic.AddUint64(&wal.cm.atomicQueuedSectors, queued)
+	defer func() {
+		atomic.AddUint64(&wal.cm.atomicQueuedSectors, ^(queued - 1))
+	}()
+
+	wal.mu.Lock()
+	storageFolders := wal.cm.availableStorageFolders()
+	wal.mu.Unlock()
+	var syncChan chan struct{}
+	var err error
+	for len(ids) > 0 {
+		if len(storageFolders) < 1 {
+			err = errors.New(modules.V1420HostOutOfStorageErrString)
+			break
+		}
+		var storageFolderIndex, added int
+		err = func() error {
+			// NOTE: The same locking convention as in
+			// managedAddPhysicalSector applies here.
+
+			// Grab a vacant storage folder and as many free sectors as
+			// possible from it.
+			wal.mu.Lock()
+			var sf *storageFolder
+			sf, storageFolderIndex = vacancyStorageFolder(storageFolders)
+			if sf == nil {
+				wal.mu.Unlock()
+				return errors.New(modules.V1420HostOutOfStorageErrString)
+			}
+			defer sf.mu.RUnlock()
+			sectorIndices := freeSectors(sf.usage, len(ids))
+			if len(sectorIndices) == 0 {
+				wal.mu.Unlock()
+				wal.cm.log.Critical("a storage folder with full usage was returned from vacancyStorageFolder")
+				return errNoFreeSectors
+			}
+			updates := make([]sectorUpdate, len(sectorIndices))
+			for i, sectorIndex := range sectorIndices {
+				sf.setUsage(sectorIndex)
+				sf.availableSectors[ids[i]] = sectorIndex
+				updates[i] = sectorUpdate{
+					Count:  1,
+					ID:     ids[i],
+					Folder: sf.index,
+					Index:  sectorIndex,
+				}
+			}
+			atomic.AddUint64(&wal.cm.atomicQueuedSectors, ^uint64(len(updates)-1))
+			queued -= uint64(len(updates))
+			wal.mu.Unlock()
+
+			// NOTE: The usage has been set, in the event of failure the usage
+			// must be cleared.
+			clearUsage := func() {
+				atomic.AddUint64(&sf.atomicFailedWrites, 1)
+				wal.mu.Lock()
+				atomic.AddUint64(&wal.cm.atomicQueuedSectors, uint64(len(updates)))
+				queued += uint64(len(updates))
+				for _, su := range updates {
+					sf.clearUsage(su.Index)
+					delete(sf.availableSectors, su.ID)
+				}
+				wal.mu.Unlock()
+			}
+
+			// Write the sectors in the order of their indices.
+			for i, su := range updates {
+				wal.cm.staticReadCache.callRemoveLocation(sf.index, su.Index)
+				err := writeSector(sf.sectorFile, su.Index, data[i])
+				if err != nil {
+					wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
+					clearUsage()
+					return errDiskTrouble
+				}
+			}
+
+			// Write the metadata of the sectors.
+			err := wal.writeConsecutiveSectorMetadata(sf, updates)
+			if err != nil {
+				wal.cm.log.Printf("ERROR: Unable to write sector metadata for folder %v: %v\n", sf.path, err)
+				clearUsage()
+				return errDiskTrouble
+			}
+
+			// Sectors added successfully, update the WAL and the state.
+			wal.mu.Lock()
+			wal.appendChange(stateChange{
+				SectorUpdates: updates,
+			})
+			wal.cm.sectorMu.Lock()
+			for _, su := range updates {
+				delete(wal.cm.storageFolders[su.Folder].availableSectors, su.ID)
+				wal.cm.sectorLocations[su.ID] = sectorLocation{
+					index:         su.Index,
+					storageFolder: su.Folder,
+					count:         su.Count,
+					WriteCount:    1,
+				}
+			}
+			wal.cm.sectorMu.Unlock()
+			syncChan = wal.syncChan
+			wal.mu.Unlock()
+			added = len(updates)
+			return nil
+		}()
+		if err != nil {
+			// End the loop if no storage folder proved suitable.
+			if storageFolderIndex == -1 {
+				err = errors.New(modules.V1420HostOutOfStorageErrString)
+				break
+			}
+
+			// Remove the storage folder that failed and try the next one.
+			storageFolders = append(storageFolders[:storageFolderIndex], storageFolders[storageFolderIndex+1:]...)
+			continue
+		}
+		ids, data = ids[added:], data[added:]
+	}
+
+	// Wait for the sectors which were added to be synced.
+	if syncChan != nil {
+		<-syncChan
+	}
+	return err
+}
+
 // managedAddVirtualSector will add a virtual sector to the contract manager.
 func (wal *writeAheadLog) managedAddVirtualSector(id sectorID, location sectorLocation) error {
 	// Update the location count.
@@ -265,7 +433,9 @@ func (wal *writeAheadLog) managedDeleteSector(id sectorID) error {
 
 		// Delete the sector and mark the usage as available.
 		delete(wal.cm.sectorLocations, id)
+		wal.cm.staticReadCache.callRemove(id)
 		sf.availableSectors[id] = location.index
+		atomic.AddUint64(&wal.cm.atomicPendingRemovals, 1)
 
 		// Block until the change has been committed.
 		syncChan = wal.syncChan
@@ -280,6 +450,7 @@ func (wal *writeAheadLog) managedDeleteSector(id sectorID) error {
 	// fully.
 	wal.mu.Lock()
 	delete(sf.availableSectors, id)
+	atomic.AddUint64(&wal.cm.atomicPendingRemovals, ^uint64(0))
 	sf.clearUsage(location.index)
 	wal.mu.Unlock()
 	return nil
@@ -329,7 +500,9 @@ func (wal *writeAheadLog) managedRemoveSector(id sectorID) error {
 		if location.count == 0 {
 			// Delete the sector and mark it as available.
 			delete(wal.cm.sectorLocations, id)
+			wal.cm.staticReadCache.callRemove(id)
 			sf.availableSectors[id] = location.index
+			atomic.AddUint64(&wal.cm.atomicPendingRemovals, 1)
 		} else {
 			// Reduce the sector usage.
 			wal.cm.sectorLocations[id] = location
@@ -368,6 +541,7 @@ func (wal *writeAheadLog) managedRemoveSector(id sectorID) error {
 	// the event of unclean shutdown.
 	if location.count == 0 {
 		wal.mu.Lock()
+		atomic.AddUint64(&wal.cm.atomicPendingRemovals, ^uint64(0))
 		sf.clearUsage(location.index)
 		delete(sf.availableSectors, id)
 		wal.mu.Unlock()
@@ -422,6 +596,39 @@ func (wal *writeAheadLog) writeSectorMetadata(sf *storageFolder, su sectorUpdate
 	return nil
 }
 
+// writeConsecutiveSectorMetadata writes the metadata of the sector updates,
+// which need to be sorted by their index. The metadata of updates with
+// consecutive indices is written at once. The updates are expected to be for
+// new sectors which don't need to update the overflow file.
+func (wal *writeAheadLog) writeConsecutiveSectorMetadata(sf *storageFolder, sus []sectorUpdate) error {
+	for start := 0; start < len(sus); {
+		// Find the end of the run of consecutive indices.
+		end := start + 1
+		for end < len(sus) && sus[end].Index == sus[end-1].Index+1 {
+			end++
+		}
+		ids := make([]sectorID, 0, end-start)
+		counts := make([]uint16, 0, end-start)
+		for _, su := range sus[start:end] {
+			if su.Count > math.MaxUint16 {
+				build.Critical("writeConsecutiveSectorMetadata called with overflowing count", su.Count)
+				return errors.New("sector count overflows the metadata")
+			}
+			ids = append(ids, su.ID)
+			counts = append(counts, uint16(su.Count))
+		}
+		err := writeConsecutiveSectorMetadata(sf.metadataFile, sus[start].Index, ids, counts)
+		if err != nil {
+			wal.cm.log.Printf("ERROR: unable to write sector metadata to folder %v when adding sectors: %v\n", sf.index, err)
+			atomic.AddUint64(&sf.atomicFailedWrites, 1)
+			return err
+		}
+		start = end
+	}
+	atomic.AddUint64(&sf.atomicSuccessfulWrites, uint64(len(sus)))
+	return nil
+}
+
 // AddSector will add a sector to the contract manager.
 func (cm *ContractManager) AddSector(root crypto.Hash, sectorData []byte) error {
 	var registerHostDiskTrouble bool
@@ -469,6 +676,96 @@ func (cm *ContractManager) AddSector(root crypto.Hash, sectorData []byte) error
 	return nil
 }
 
+// AddSectors adds multiple sectors to the contract manager and returns their
+// roots. Compared to calling AddSector for every sector, the new sectors are
+// grouped by storage folder and the metadata of consecutive sectors is written
+// at once which reduces the number of disk writes during large uploads. If an
+// error is returned, some of the sectors might have been added nonetheless.
+func (cm *ContractManager) AddSectors(data [][]byte) ([]crypto.Hash, error) {
+	// Prevent shutdown until this function completes.
+	err := cm.tg.Add()
+	if err != nil {
+		return nil, err
+	}
+	defer cm.tg.Done()
+
+	// Allow disk trouble simulation, for testing purposes
+	if cm.dependencies.Disrupt("diskTrouble") {
+		cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
+		return nil, errDiskTrouble
+	}
+
+	// Compute the roots and ids of the sectors.
+	roots := make([]crypto.Hash, len(data))
+	ids := make([]sectorID, len(data))
+	for i, sectorData := range data {
+		roots[i] = crypto.MerkleRoot(sectorData)
+		ids[i] = cm.managedSectorID(roots[i])
+	}
+
+	// Hold the sector locks throughout the duration of the function. The
+	// locks are acquired in a fixed order to prevent deadlocks between
+	// concurrent batches.
+	first := make(map[sectorID]int, len(ids))
+	var lockOrder []sectorID
+	for i, id := range ids {
+		if _, exists := first[id]; !exists {
+			first[id] = i
+			lockOrder = append(lockOrder, id)
+		}
+	}
+	sort.Slice(lockOrder, func(i, j int) bool {
+		return bytes.Compare(lockOrder[i][:], lockOrder[j][:]) < 0
+	})
+	for _, id := range lockOrder {
+		cm.wal.managedLockSector(id)
+	}
+	defer func() {
+		for _, id := range lockOrder {
+			cm.wal.managedUnlockSector(id)
+		}
+	}()
+
+	// Sectors which already exist or appear multiple times within the batch
+	// are added as virtual sectors after the physical ones.
+	var physicalIDs, virtualIDs []sectorID
+	var physicalData [][]byte
+	cm.sectorMu.Lock()
+	for i, id := range ids {
+		if _, exists := cm.sectorLocations[id]; exists || first[id] != i {
+			virtualIDs = append(virtualIDs, id)
+			continue
+		}
+		physicalIDs = append(physicalIDs, id)
+		physicalData = append(physicalData, data[i])
+	}
+	cm.sectorMu.Unlock()
+
+	err = cm.wal.managedAddPhysicalSectors(physicalIDs, physicalData)
+	if errors.Contains(err, errDiskTrouble) {
+		cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
+	}
+	if err != nil {
+		cm.log.Println("ERROR: Unable to add sectors:", err)
+		return nil, err
+	}
+	for _, id := range virtualIDs {
+		cm.sectorMu.Lock()
+		location, exists := cm.sectorLocations[id]
+		cm.sectorMu.Unlock()
+		if !exists {
+			cm.log.Critical("sector location missing after adding it")
+			return nil, errors.New("sector wasn't added")
+		}
+		err = cm.wal.managedAddVirtualSector(id, location)
+		if err != nil {
+			cm.log.Println("ERROR: Unable to add sectors:", err)
+			return nil, err
+		}
+	}
+	return roots, nil
+}
+
 // AddSectorBatch is a non-ACID call to add a bunch of sectors at once.
 // Necessary for compatibility with old renters.
 //
@@ -12,15 +12,19 @@ package contractmanager
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"math"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/fastrand"
 
+	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/persist"
@@ -2206,3 +2210,413 @@ func TestAddVirtualSectorOverflow(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestAddVirtualSectorMaxCount drives the virtual sector count of a sector to
+// the limit and checks that further virtual sectors are rejected without
+// corrupting the persisted count.
+func TestAddVirtualSectorMaxCount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder to the contract manager tester.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sf *storageFolder
+	for _, storageFolder := range cmt.cm.storageFolders {
+		sf = storageFolder
+		break
+	}
+
+	// Add a sector and manually set its count to one below the limit.
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := cmt.cm.managedSectorID(root)
+	sl := cmt.cm.sectorLocations[id]
+	su := sectorUpdate{
+		Count:  math.MaxUint64 - 1,
+		Folder: sf.index,
+		ID:     id,
+		Index:  sl.index,
+	}
+	err = cmt.cm.wal.writeSectorMetadata(sf, su)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sl.count = su.Count
+	cmt.cm.sectorLocations[id] = sl
+
+	// Adding the sector again should push the count to the limit.
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := cmt.cm.sectorLocations[id].count; count != math.MaxUint64 {
+		t.Fatal("wrong count", count)
+	}
+
+	// Adding the sector one more time should fail and leave the count
+	// untouched.
+	err = cmt.cm.AddSector(root, data)
+	if !errors.Is(err, modules.ErrMaxVirtualSectors) {
+		t.Fatal("expected ErrMaxVirtualSectors", err)
+	}
+	if count := cmt.cm.sectorLocations[id].count; count != math.MaxUint64 {
+		t.Fatal("wrong count", count)
+	}
+
+	// The full count should survive a restart.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := cmt.cm.sectorLocations[id].count; count != math.MaxUint64 {
+		t.Fatal("wrong count after restart", count)
+	}
+}
+
+// TestCapacityPendingSectors checks that the adjusted remaining storage of the
+// contract manager accounts for queued sector additions and doesn't credit
+// sector removals before they are committed.
+func TestCapacityPendingSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder to the contract manager tester.
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := cmt.cm.Capacity()
+	if before.RemainingRaw != before.Total || before.RemainingAdjusted != before.Total {
+		t.Fatal("empty contract manager should have all storage remaining", before)
+	}
+
+	// Block the WAL and queue some sectors. They should be queued but not
+	// use up any storage in the folders yet.
+	numSectors := uint64(10)
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+	}
+	cmt.cm.wal.mu.Lock()
+	var wg sync.WaitGroup
+	for i := range roots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cmt.cm.AddSector(roots[i], datas[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		if c := cmt.cm.Capacity(); c.PendingAdditions != numSectors {
+			return fmt.Errorf("expected %v pending additions but got %v", numSectors, c.PendingAdditions)
+		}
+		return nil
+	})
+	if err != nil {
+		cmt.cm.wal.mu.Unlock()
+		t.Fatal(err)
+	}
+	c := cmt.cm.Capacity()
+	expected := before.RemainingRaw - numSectors*modules.SectorSize
+	if c.RemainingRaw != before.RemainingRaw {
+		t.Error("raw remaining storage shouldn't change yet", c.RemainingRaw, before.RemainingRaw)
+	}
+	if c.RemainingAdjusted != expected {
+		t.Error("adjusted remaining storage should account for queued sectors", c.RemainingAdjusted, expected)
+	}
+
+	// Unblock the WAL. The adjusted remaining storage should never exceed
+	// the remaining storage after all sectors have been added.
+	cmt.cm.wal.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		if c := cmt.cm.Capacity(); c.RemainingAdjusted > expected || c.RemainingAdjusted > c.RemainingRaw {
+			t.Fatal("advertised storage isn't conservative", c, expected)
+		}
+	}
+	c = cmt.cm.Capacity()
+	if c.PendingAdditions != 0 || c.RemainingRaw != expected || c.RemainingAdjusted != expected {
+		t.Fatal("unexpected capacity after adding sectors", c, expected)
+	}
+
+	// Remove the sectors again. A removal shouldn't be credited while it is
+	// still pending.
+	done = make(chan struct{})
+	for i := range roots {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := cmt.cm.RemoveSector(roots[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		c := cmt.cm.Capacity()
+		if c.RemainingAdjusted > expected+(numSectors-c.PendingRemovals)*modules.SectorSize {
+			t.Fatal("pending removals shouldn't be credited", c, expected)
+		}
+	}
+	c = cmt.cm.Capacity()
+	if c.PendingRemovals != 0 || c.RemainingRaw != before.RemainingRaw || c.RemainingAdjusted != before.RemainingAdjusted {
+		t.Fatal("unexpected capacity after removing sectors", c, before)
+	}
+}
+
+// dependencyCountWrites counts the WriteAt calls on the sector and metadata
+// files of the storage folders.
+type dependencyCountWrites struct {
+	modules.ProductionDependencies
+	writes *uint64
+}
+
+// countingFile counts the calls to WriteAt in the dependency.
+type countingFile struct {
+	writes *uint64
+	*os.File
+}
+
+// CreateFile returns a file which counts its WriteAt calls if it is the
+// sector or metadata file of a storage folder.
+func (d *dependencyCountWrites) CreateFile(s string) (modules.File, error) {
+	osfile, err := os.Create(s)
+	if err != nil {
+		return nil, err
+	}
+	if name := filepath.Base(s); name != sectorFile && name != metadataFile {
+		return osfile, nil
+	}
+	return &countingFile{
+		writes: d.writes,
+		File:   osfile,
+	}, nil
+}
+
+// WriteAt counts the call before writing to the file.
+func (cf *countingFile) WriteAt(b []byte, offset int64) (int, error) {
+	atomic.AddUint64(cf.writes, 1)
+	return cf.File.WriteAt(b, offset)
+}
+
+// TestAddSectors checks that a batch of sectors can be added at once, including
+// sectors which already exist and sectors which appear twice in the batch.
+func TestAddSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add two storage folders which can hold 64 sectors each.
+	for _, name := range []string{"storageFolderOne", "storageFolderTwo"} {
+		storageFolderDir := filepath.Join(cmt.persistDir, name)
+		err = os.MkdirAll(storageFolderDir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*storageFolderGranularity)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add a sector the regular way.
+	existingRoot, existingData := randSector()
+	err = cmt.cm.AddSector(existingRoot, existingData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a batch which needs both storage folders and contains the existing
+	// sector as well as a duplicate.
+	var data [][]byte
+	for i := 0; i < 100; i++ {
+		_, d := randSector()
+		data = append(data, d)
+	}
+	data = append(data, existingData, data[0])
+	roots, err := cmt.cm.AddSectors(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != len(data) {
+		t.Fatal("wrong number of roots", len(roots), len(data))
+	}
+	for i, root := range roots {
+		if root != crypto.MerkleRoot(data[i]) {
+			t.Fatal("wrong root", i)
+		}
+	}
+	physical, virtual := cmt.cm.SectorCount()
+	if physical != 101 || virtual != 103 {
+		t.Fatal("wrong sector count", physical, virtual)
+	}
+	for _, sf := range cmt.cm.StorageFolders() {
+		if sf.CapacityRemaining == sf.Capacity {
+			t.Fatal("expected sectors in both storage folders")
+		}
+	}
+	if c := cmt.cm.Capacity(); c.PendingAdditions != 0 {
+		t.Fatal("sectors shouldn't be queued anymore", c.PendingAdditions)
+	}
+
+	// checkSectors checks that all sectors can be read.
+	checkSectors := func() {
+		for i, root := range roots {
+			sectorData, err := cmt.cm.ReadSector(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(sectorData, data[i]) {
+				t.Fatal("wrong sector data", i)
+			}
+		}
+	}
+	checkSectors()
+
+	// The sectors should survive a restart.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSectors()
+	physical, virtual = cmt.cm.SectorCount()
+	if physical != 101 || virtual != 103 {
+		t.Fatal("wrong sector count after restart", physical, virtual)
+	}
+
+	// A batch which doesn't fit anymore fails.
+	data = data[:0]
+	for i := 0; i < 50; i++ {
+		_, d := randSector()
+		data = append(data, d)
+	}
+	_, err = cmt.cm.AddSectors(data)
+	if err == nil || err.Error() != modules.V1420HostOutOfStorageErrString {
+		t.Fatal("expected out of storage error", err)
+	}
+}
+
+// TestAddSectorsWriteAtCount compares the number of WriteAt calls of
+// AddSectors with calling AddSector for every sector.
+func TestAddSectorsWriteAtCount(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	numSectors := uint64(storageFolderGranularity)
+	loopWrites := countAddSectorWrites(t, t.Name()+"Loop", numSectors, false)
+	batchWrites := countAddSectorWrites(t, t.Name()+"Batch", numSectors, true)
+	if loopWrites != 2*numSectors {
+		t.Fatal("expected two writes per sector", loopWrites)
+	}
+	if float64(batchWrites) > 0.6*float64(loopWrites) {
+		t.Fatalf("expected at least 40%% fewer writes but got %v instead of %v", batchWrites, loopWrites)
+	}
+}
+
+// countAddSectorWrites adds numSectors sectors to a new contract manager with a
+// single storage folder and returns the number of WriteAt calls on the storage
+// folder files.
+func countAddSectorWrites(t testing.TB, name string, numSectors uint64, batch bool) uint64 {
+	d := &dependencyCountWrites{writes: new(uint64)}
+	cmt, err := newMockedContractManagerTester(d, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+	storageFolderDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(storageFolderDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(storageFolderDir, modules.SectorSize*numSectors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([][]byte, numSectors)
+	for i := range data {
+		_, data[i] = randSector()
+	}
+
+	// Only count the writes of the sectors.
+	atomic.StoreUint64(d.writes, 0)
+	if batch {
+		_, err = cmt.cm.AddSectors(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		for _, sectorData := range data {
+			err = cmt.cm.AddSector(crypto.MerkleRoot(sectorData), sectorData)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return atomic.LoadUint64(d.writes)
+}
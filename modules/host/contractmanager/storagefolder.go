@@ -7,6 +7,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"sync/atomic"
 	"time"
 
@@ -95,6 +96,11 @@ type storageFolder struct {
 	// an error if it is queried.
 	atomicUnavailable uint64 // uint64 for alignment
 
+	// Atomic bool indicating whether or not the storage folder is read-only.
+	// No new sectors are placed in a read-only storage folder but the sectors
+	// it already contains can still be read and removed.
+	atomicReadOnly uint64 // uint64 for alignment
+
 	// The index, path, and usage are all saved directly to disk.
 	index uint16
 	path  string
@@ -110,6 +116,10 @@ type storageFolder struct {
 	availableSectors map[sectorID]uint32
 	sectors          uint64
 
+	// legacyMetadata indicates that the sector metadata of the folder doesn't
+	// contain checksums yet and needs to be upgraded before it can be loaded.
+	legacyMetadata bool
+
 	// An open file handle is kept so that writes can easily be made to the
 	// storage folder without needing to grab a new file handle. This also
 	// makes it easy to do delayed-syncing.
@@ -180,6 +190,33 @@ func randFreeSector(usage []uint64) (uint32, error) {
 	return uint32((uint64(i) * 64) + msz), nil
 }
 
+// freeSectors returns up to n free sector indices from a storage folder's usage
+// array in ascending order. The scan starts at a random location like
+// randFreeSector, but the free sectors are collected in order from there on
+// to make it likely that the returned indices are consecutive.
+func freeSectors(usage []uint64, n int) []uint32 {
+	if len(usage) == 0 || n <= 0 {
+		return nil
+	}
+	start := fastrand.Intn(len(usage))
+	var indices []uint32
+	for k := 0; k < len(usage) && len(indices) < n; k++ {
+		i := (start + k) % len(usage)
+		if usage[i] == math.MaxUint64 {
+			continue
+		}
+		for j := uint64(0); j < storageFolderGranularity && len(indices) < n; j++ {
+			if usage[i]&(1<<j) == 0 {
+				indices = append(indices, uint32(uint64(i)*storageFolderGranularity+j))
+			}
+		}
+	}
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i] < indices[j]
+	})
+	return indices
+}
+
 // usageSectors takes a storage folder usage array and returns a list of active
 // sectors in that usage array by their index.
 func usageSectors(usage []uint64) (usageSectors []uint32) {
@@ -215,6 +252,11 @@ func vacancyStorageFolder(sfs []*storageFolder) (*storageFolder, int) {
 			continue
 		}
 
+		// Skip past this storage folder if it's read-only.
+		if atomic.LoadUint64(&sf.atomicReadOnly) == 1 {
+			continue
+		}
+
 		// Skip past this storage folder if it's not available to receive new
 		// data.
 		if !sf.mu.TryRLock() {
@@ -328,6 +370,17 @@ func (cm *ContractManager) threadedFolderRecheck() {
 			var err1, err2 error
 			sf.metadataFile, err1 = cm.dependencies.OpenFile(filepath.Join(sf.path, metadataFile), os.O_RDWR, 0700)
 			sf.sectorFile, err2 = cm.dependencies.OpenFile(filepath.Join(sf.path, sectorFile), os.O_RDWR, 0700)
+			if err1 == nil && err2 == nil && sf.legacyMetadata {
+				// The storage folder was unavailable when the settings were
+				// upgraded, upgrade its metadata before loading it.
+				err := cm.upgradeLegacySectorMetadata(sf)
+				if err != nil {
+					cm.log.Printf("ERROR: unable to upgrade the %v sector metadata file: %v\n", sf.path, err)
+					sf.metadataFile.Close()
+					sf.sectorFile.Close()
+					continue
+				}
+			}
 			if err1 == nil && err2 == nil {
 				// The storage folder has been found, and loading can be
 				// completed.
@@ -449,6 +502,7 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 			FailedWrites:     atomic.LoadUint64(&sf.atomicFailedWrites),
 			SuccessfulReads:  atomic.LoadUint64(&sf.atomicSuccessfulReads),
 			SuccessfulWrites: atomic.LoadUint64(&sf.atomicSuccessfulWrites),
+			ReadOnly:         atomic.LoadUint64(&sf.atomicReadOnly) == 1,
 
 			Capacity:          modules.SectorSize * 64 * uint64(len(sf.usage)),
 			CapacityRemaining: ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize,
@@ -468,3 +522,75 @@ func (cm *ContractManager) StorageFolders() []modules.StorageFolderMetadata {
 	}
 	return smfs
 }
+
+// StorageFolderStats returns the I/O statistics of the storage folders for the
+// current boot cycle sorted by the folders' indices. The read and write counts
+// are aggregated from the sectors which are currently stored in each folder.
+func (cm *ContractManager) StorageFolderStats() []modules.StorageFolderStats {
+	err := cm.tg.Add()
+	if err != nil {
+		return nil
+	}
+	defer cm.tg.Done()
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+
+	stats := make(map[uint16]*modules.StorageFolderStats, len(cm.storageFolders))
+	indices := make([]uint16, 0, len(cm.storageFolders))
+	for _, sf := range cm.storageFolders {
+		stats[sf.index] = &modules.StorageFolderStats{
+			Folder:       sf.path,
+			FailedReads:  atomic.LoadUint64(&sf.atomicFailedReads),
+			FailedWrites: atomic.LoadUint64(&sf.atomicFailedWrites),
+		}
+		indices = append(indices, sf.index)
+	}
+	for _, sl := range cm.sectorLocations {
+		sfs, exists := stats[sl.storageFolder]
+		if !exists {
+			continue
+		}
+		sfs.SectorsRead += sl.ReadCount
+		sfs.SectorsWritten += sl.WriteCount
+	}
+
+	sort.Slice(indices, func(i, j int) bool {
+		return indices[i] < indices[j]
+	})
+	sfStats := make([]modules.StorageFolderStats, 0, len(indices))
+	for _, index := range indices {
+		sfStats = append(sfStats, *stats[index])
+	}
+	return sfStats
+}
+
+// Capacity returns the total and remaining storage of the contract manager.
+// The adjusted remaining storage subtracts the sectors that are still queued
+// for addition. Sectors pending removal are only credited once their removal
+// was committed and their usage was cleared.
+func (cm *ContractManager) Capacity() (c modules.StorageCapacity) {
+	err := cm.tg.Add()
+	if err != nil {
+		return
+	}
+	defer cm.tg.Done()
+
+	// Load the queued sectors before the usage of the storage folders. A
+	// sector that gets assigned a slot in between is then counted twice
+	// instead of not at all.
+	c.PendingAdditions = atomic.LoadUint64(&cm.atomicQueuedSectors)
+	cm.sectorMu.Lock()
+	for _, sf := range cm.storageFolders {
+		c.Total += modules.SectorSize * 64 * uint64(len(sf.usage))
+		c.RemainingRaw += ((64 * uint64(len(sf.usage))) - sf.sectors) * modules.SectorSize
+	}
+	cm.sectorMu.Unlock()
+	c.PendingRemovals = atomic.LoadUint64(&cm.atomicPendingRemovals)
+
+	// Compute the adjusted remaining storage.
+	pending := c.PendingAdditions * modules.SectorSize
+	if pending < c.RemainingRaw {
+		c.RemainingAdjusted = c.RemainingRaw - pending
+	}
+	return
+}
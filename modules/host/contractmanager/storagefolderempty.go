@@ -20,8 +20,8 @@ var (
 )
 
 // managedMoveSector will move a sector from its current storage folder to
-// another.
-func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
+// another. If dst is not nil, the sector will only be moved to dst.
+func (wal *writeAheadLog) managedMoveSector(id sectorID, dst *storageFolder) error {
 	wal.managedLockSector(id)
 	defer wal.managedUnlockSector(id)
 
@@ -52,9 +52,14 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 	}
 
 	// Place the sector into its new folder and add the atomic move to the WAL.
-	wal.mu.Lock()
-	storageFolders := wal.cm.availableStorageFolders()
-	wal.mu.Unlock()
+	var storageFolders []*storageFolder
+	if dst != nil {
+		storageFolders = []*storageFolder{dst}
+	} else {
+		wal.mu.Lock()
+		storageFolders = wal.cm.availableStorageFolders()
+		wal.mu.Unlock()
+	}
 	for len(storageFolders) >= 1 {
 		var storageFolderIndex int
 		err := func() error {
@@ -93,7 +98,9 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 			// NOTE: The usage has been set, in the event of failure the usage
 			// must be cleared.
 
-			// Try writing the new sector to disk.
+			// Try writing the new sector to disk. Any cached data for the
+			// sector index is outdated after that.
+			wal.cm.staticReadCache.callRemoveLocation(sf.index, sectorIndex)
 			err = writeSector(sf.sectorFile, sectorIndex, sectorData)
 			if err != nil {
 				wal.cm.log.Printf("ERROR: Unable to write sector for folder %v: %v\n", sf.path, err)
@@ -128,6 +135,8 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 				index:         sectorIndex,
 				storageFolder: sf.index,
 				count:         oldLocation.count,
+				ReadCount:     oldLocation.ReadCount,
+				WriteCount:    oldLocation.WriteCount,
 			}
 			wal.mu.Lock()
 			wal.cm.sectorMu.Lock()
@@ -162,12 +171,13 @@ func (wal *writeAheadLog) managedMoveSector(id sectorID) error {
 // provided index starting with the 'startingPoint'th sector all the way to the
 // end of the storage folder, allowing the storage folder to be safely
 // truncated. If 'force' is set to true, the function will not give up when
-// there is no more space available, instead choosing to lose data.
+// there is no more space available, instead choosing to lose data. If dst is
+// not nil, all sectors will be moved to dst.
 //
 // This function assumes that the storage folder has already been made
 // invisible to AddSector, and that this is the only thread that will be
 // interacting with the storage folder.
-func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoint uint32) (uint64, error) {
+func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoint uint32, dst *storageFolder) (uint64, error) {
 	// Allow disk trouble simulation, for testing purposes
 	if wal.cm.dependencies.Disrupt("diskTrouble") {
 		wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
@@ -184,7 +194,7 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 
 	// Read the sector lookup bytes into memory; we'll need them to figure out
 	// what sectors are in which locations.
-	sectorLookupBytes, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
+	sectorLookupBytes, _, err := readFullMetadata(sf.metadataFile, len(sf.usage)*storageFolderGranularity)
 	if err != nil {
 		atomic.AddUint64(&sf.atomicFailedReads, 1)
 		return 0, build.ExtendErr("unable to read sector metadata", err)
@@ -210,7 +220,7 @@ func (wal *writeAheadLog) managedEmptyStorageFolder(sfIndex uint16, startingPoin
 			for {
 				select {
 				case id := <-workChan:
-					err := wal.managedMoveSector(id)
+					err := wal.managedMoveSector(id, dst)
 					if errors.Contains(err, errDiskTrouble) {
 						wal.cm.staticAlerter.RegisterAlert(modules.AlertIDHostDiskTrouble, AlertMSGHostDiskTrouble, "", modules.SeverityCritical)
 					}
@@ -0,0 +1,158 @@
+package contractmanager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
+
+// folderHealthSample is a snapshot of the read and write statistics of a
+// storage folder.
+type folderHealthSample struct {
+	failed     uint64
+	successful uint64
+}
+
+// folderHealthMonitor keeps track of the recent read and write statistics of
+// the storage folders to compute their failure rate over a sliding window.
+type folderHealthMonitor struct {
+	// samples contains the most recent samples of each storage folder, the
+	// oldest sample first.
+	samples map[uint16][]folderHealthSample
+	mu      sync.Mutex
+}
+
+// newFolderHealthMonitor creates a new folderHealthMonitor.
+func newFolderHealthMonitor() *folderHealthMonitor {
+	return &folderHealthMonitor{
+		samples: make(map[uint16][]folderHealthSample),
+	}
+}
+
+// callReset forgets the samples of a storage folder.
+func (fhm *folderHealthMonitor) callReset(index uint16) {
+	fhm.mu.Lock()
+	defer fhm.mu.Unlock()
+	delete(fhm.samples, index)
+}
+
+// readOnlyFolderAlertID returns the id of the alert that is registered when a
+// storage folder is marked read-only.
+func readOnlyFolderAlertID(sf *storageFolder) modules.AlertID {
+	return modules.AlertID("cm-read-only-folder-" + sf.path)
+}
+
+// managedCheckFolderHealth samples the read and write statistics of the
+// storage folders and marks the folders whose failure rate within the window
+// is too high as read-only.
+func (cm *ContractManager) managedCheckFolderHealth() {
+	cm.sectorMu.Lock()
+	folders := make([]*storageFolder, 0, len(cm.storageFolders))
+	for _, sf := range cm.storageFolders {
+		folders = append(folders, sf)
+	}
+	cm.sectorMu.Unlock()
+
+	fhm := cm.staticFolderHealth
+	fhm.mu.Lock()
+	defer fhm.mu.Unlock()
+
+	// Forget about the folders that were removed.
+	exists := make(map[uint16]struct{}, len(folders))
+	for _, sf := range folders {
+		exists[sf.index] = struct{}{}
+	}
+	for index := range fhm.samples {
+		if _, ok := exists[index]; !ok {
+			delete(fhm.samples, index)
+		}
+	}
+
+	for _, sf := range folders {
+		// The statistics of unavailable folders are meaningless.
+		if atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+			delete(fhm.samples, sf.index)
+			continue
+		}
+		failedReads := atomic.LoadUint64(&sf.atomicFailedReads)
+		failedWrites := atomic.LoadUint64(&sf.atomicFailedWrites)
+		sample := folderHealthSample{
+			failed:     failedReads + failedWrites,
+			successful: atomic.LoadUint64(&sf.atomicSuccessfulReads) + atomic.LoadUint64(&sf.atomicSuccessfulWrites),
+		}
+
+		// The statistics are reset by ResetStorageFolderHealth. Start over if
+		// they decreased.
+		samples := fhm.samples[sf.index]
+		if len(samples) > 0 {
+			last := samples[len(samples)-1]
+			if sample.failed < last.failed || sample.successful < last.successful {
+				samples = nil
+			}
+		}
+		samples = append(samples, sample)
+		if len(samples) > folderHealthWindow+1 {
+			samples = append([]folderHealthSample(nil), samples[len(samples)-folderHealthWindow-1:]...)
+		}
+		fhm.samples[sf.index] = samples
+
+		// Check the failure rate of the folders which aren't read-only yet.
+		if atomic.LoadUint64(&sf.atomicReadOnly) == 1 {
+			continue
+		}
+		failed := sample.failed - samples[0].failed
+		total := failed + sample.successful - samples[0].successful
+		if total < folderHealthMinOperations || float64(failed)/float64(total) < folderHealthMaxFailureRate {
+			continue
+		}
+		atomic.StoreUint64(&sf.atomicReadOnly, 1)
+		cm.log.Printf("WARN: marking storage folder %v as read-only, %v of %v operations failed recently\n", sf.path, failed, total)
+		cause := fmt.Sprintf("%v of the last %v operations on storage folder %v failed (%v failed reads and %v failed writes since startup), no new sectors will be placed in the folder until it is marked writable again", failed, total, sf.path, failedReads, failedWrites)
+		cm.staticAlerter.RegisterAlert(readOnlyFolderAlertID(sf), AlertMSGHostReadOnlyFolder, cause, modules.SeverityError)
+	}
+}
+
+// threadedFolderHealthMonitor periodically checks the failure rate of the
+// storage folders.
+func (cm *ContractManager) threadedFolderHealthMonitor() {
+	for {
+		select {
+		case <-cm.tg.StopChan():
+			return
+		case <-time.After(folderHealthCheckInterval):
+		}
+		cm.managedCheckFolderHealth()
+	}
+}
+
+// SetStorageFolderReadOnly marks a storage folder as read-only or writable.
+// Marking a folder as writable clears the read-only flag that was set due to
+// disk errors, which should only be done once the disk was fixed.
+func (cm *ContractManager) SetStorageFolderReadOnly(index uint16, readOnly bool) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	cm.sectorMu.Lock()
+	sf, exists := cm.storageFolders[index]
+	cm.sectorMu.Unlock()
+	if !exists || atomic.LoadUint64(&sf.atomicUnavailable) == 1 {
+		return errStorageFolderNotFound
+	}
+
+	if readOnly {
+		atomic.StoreUint64(&sf.atomicReadOnly, 1)
+		return nil
+	}
+	// Forget about the failures within the current window to avoid marking
+	// the folder as read-only again right away.
+	cm.staticFolderHealth.callReset(index)
+	atomic.StoreUint64(&sf.atomicReadOnly, 0)
+	cm.staticAlerter.UnregisterAlert(readOnlyFolderAlertID(sf))
+	return nil
+}
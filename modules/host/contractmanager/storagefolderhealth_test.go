@@ -0,0 +1,157 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestFolderHealthReadOnly checks that a storage folder with too many failed
+// writes is marked read-only, that no new sectors are placed in it and that
+// the flag can be cleared again.
+func TestFolderHealthReadOnly(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyFailingWrites)
+	d.mu = new(sync.Mutex)
+	d.triggered = new(bool)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cmt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a failing and a healthy storage folder.
+	failingDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	healthyDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	for _, dir := range []string{failingDir, healthyDir} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity*2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// folder returns the metadata of the storage folder at path.
+	folder := func(path string) modules.StorageFolderMetadata {
+		for _, sf := range cmt.cm.StorageFolders() {
+			if sf.Path == path {
+				return sf
+			}
+		}
+		t.Fatal("storage folder not found", path)
+		return modules.StorageFolderMetadata{}
+	}
+	// hasAlert checks whether the read-only alert of the failing folder is
+	// registered.
+	hasAlert := func() bool {
+		_, errs, _, _ := cmt.cm.Alerts()
+		for _, alert := range errs {
+			if alert.Msg == AlertMSGHostReadOnlyFolder {
+				return true
+			}
+		}
+		return false
+	}
+	// addSectors adds n random sectors.
+	var roots []crypto.Hash
+	addSectors := func(n int) {
+		for i := 0; i < n; i++ {
+			root, data := randSector()
+			if err := cmt.cm.AddSector(root, data); err != nil {
+				t.Fatal(err)
+			}
+			roots = append(roots, root)
+		}
+	}
+
+	// Without failures, neither folder is marked read-only.
+	cmt.cm.managedCheckFolderHealth()
+	addSectors(10)
+	cmt.cm.managedCheckFolderHealth()
+	if folder(failingDir).ReadOnly || folder(healthyDir).ReadOnly || hasAlert() {
+		t.Fatal("folders shouldn't be read-only")
+	}
+
+	// Let the writes to the first folder fail. The sectors end up in the
+	// healthy folder.
+	d.mu.Lock()
+	*d.triggered = true
+	d.mu.Unlock()
+	addSectors(40)
+	if folder(failingDir).FailedWrites == 0 {
+		t.Fatal("expected failed writes")
+	}
+	cmt.cm.managedCheckFolderHealth()
+	if !folder(failingDir).ReadOnly || folder(healthyDir).ReadOnly {
+		t.Fatal("only the failing folder should be read-only")
+	}
+	if !hasAlert() {
+		t.Fatal("alert wasn't registered")
+	}
+
+	// No new sectors should be placed in the read-only folder, even after the
+	// writes succeed again, but the existing sectors can still be read.
+	d.mu.Lock()
+	*d.triggered = false
+	d.mu.Unlock()
+	remaining := folder(failingDir).CapacityRemaining
+	failedWrites := folder(failingDir).FailedWrites
+	addSectors(20)
+	if folder(failingDir).CapacityRemaining != remaining || folder(failingDir).FailedWrites != failedWrites {
+		t.Fatal("sectors were placed in the read-only folder")
+	}
+	for _, root := range roots {
+		if _, err := cmt.cm.ReadSector(root); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The flag should survive a restart.
+	if err := cmt.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = newContractManager(d, filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !folder(failingDir).ReadOnly {
+		t.Fatal("read-only flag wasn't persisted")
+	}
+
+	// Clear the flag. The folder should accept sectors again.
+	index := folder(failingDir).Index
+	if err := cmt.cm.SetStorageFolderReadOnly(index, false); err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm.managedCheckFolderHealth()
+	if folder(failingDir).ReadOnly || hasAlert() {
+		t.Fatal("folder should be writable again")
+	}
+	remaining = folder(failingDir).CapacityRemaining
+	addSectors(20)
+	if folder(failingDir).CapacityRemaining == remaining {
+		t.Fatal("no sectors were placed in the writable folder")
+	}
+
+	// Folders can also be marked read-only manually.
+	if err := cmt.cm.SetStorageFolderReadOnly(index, true); err != nil {
+		t.Fatal(err)
+	}
+	if !folder(failingDir).ReadOnly {
+		t.Fatal("folder should be read-only")
+	}
+	if err := cmt.cm.SetStorageFolderReadOnly(index+100, false); err != errStorageFolderNotFound {
+		t.Fatal("expected errStorageFolderNotFound", err)
+	}
+}
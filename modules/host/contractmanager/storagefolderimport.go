@@ -0,0 +1,380 @@
+package contractmanager
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// importVerificationSamples is the number of randomly chosen sectors of
+	// an imported storage folder which are read from disk and checked against
+	// their roots before the import is committed.
+	importVerificationSamples = 20
+)
+
+var (
+	// errImportInvalidManifest is returned if a manifest passed to ImportFolder
+	// is internally inconsistent.
+	errImportInvalidManifest = errors.New("storage folder manifest is invalid")
+
+	// errImportOutdatedManifest is returned if the storage folder described by
+	// a manifest is still part of the contract manager but its sectors have
+	// changed since the manifest was exported.
+	errImportOutdatedManifest = errors.New("storage folder has changed since the manifest was exported")
+
+	// errImportSectorExists is returned if a sector of the manifest is
+	// already stored in another storage folder of the contract manager.
+	errImportSectorExists = errors.New("sector of the manifest is already stored in another storage folder")
+
+	// errImportSectorMismatch is returned if a sampled sector of an imported
+	// storage folder doesn't match the sector id of the manifest.
+	errImportSectorMismatch = errors.New("sector data of the imported storage folder doesn't match the manifest")
+
+	// errImportTruncatedFile is returned if the files of an imported storage
+	// folder are smaller than the size of the storage folder in the manifest.
+	errImportTruncatedFile = errors.New("storage folder file is smaller than expected by the manifest")
+)
+
+type (
+	// FolderManifest describes the sectors of a storage folder. It is created
+	// by ExportFolderMetadata and used by ImportFolder to attach the data
+	// files of a storage folder which were copied to a new location.
+	FolderManifest struct {
+		Index      uint16                 `json:"index"`
+		NumSectors uint64                 `json:"numsectors"`
+		Sectors    []FolderManifestSector `json:"sectors"`
+	}
+
+	// FolderManifestSector is the location of a single sector within the
+	// storage folder described by a FolderManifest.
+	FolderManifestSector struct {
+		ID    [12]byte `json:"id"`
+		Index uint32   `json:"index"`
+		Count uint64   `json:"count"`
+	}
+)
+
+// validate checks that the manifest describes a valid storage folder.
+func (fm FolderManifest) validate() error {
+	if fm.NumSectors > MaximumSectorsPerStorageFolder {
+		return ErrLargeStorageFolder
+	}
+	if fm.NumSectors < MinimumSectorsPerStorageFolder {
+		return ErrSmallStorageFolder
+	}
+	if fm.NumSectors%storageFolderGranularity != 0 {
+		return errStorageFolderGranularity
+	}
+	indices := make(map[uint32]struct{}, len(fm.Sectors))
+	ids := make(map[sectorID]struct{}, len(fm.Sectors))
+	for _, s := range fm.Sectors {
+		if uint64(s.Index) >= fm.NumSectors {
+			return errors.AddContext(errImportInvalidManifest, "sector index out of bounds")
+		}
+		if s.Count == 0 {
+			return errors.AddContext(errImportInvalidManifest, "sector without references")
+		}
+		if _, exists := indices[s.Index]; exists {
+			return errors.AddContext(errImportInvalidManifest, "duplicate sector index")
+		}
+		if _, exists := ids[s.ID]; exists {
+			return errors.AddContext(errImportInvalidManifest, "duplicate sector id")
+		}
+		indices[s.Index] = struct{}{}
+		ids[s.ID] = struct{}{}
+	}
+	return nil
+}
+
+// commitImportStorageFolder integrates a pending ImportFolder call into the
+// state. The files of the replaced storage folder, if any, are closed but not
+// deleted. The sector locations don't need to be updated since they are loaded
+// from the synced metadata after the WAL is recovered.
+// commitImportStorageFolder should only be called during WAL recovery.
+func (wal *writeAheadLog) commitImportStorageFolder(ssf savedStorageFolder) {
+	wal.cm.sectorMu.Lock()
+	defer wal.cm.sectorMu.Unlock()
+	sf, exists := wal.cm.storageFolders[ssf.Index]
+	if exists && atomic.LoadUint64(&sf.atomicUnavailable) == 0 {
+		if sf.metadataFile != nil {
+			sf.metadataFile.Close()
+		}
+		if sf.sectorFile != nil {
+			sf.sectorFile.Close()
+		}
+	}
+
+	sf = &storageFolder{
+		index: ssf.Index,
+		path:  ssf.Path,
+		usage: ssf.Usage,
+
+		availableSectors: make(map[sectorID]uint32),
+	}
+	if ssf.ReadOnly {
+		atomic.StoreUint64(&sf.atomicReadOnly, 1)
+	}
+	wal.cm.storageFolders[sf.index] = sf
+
+	var err error
+	sf.metadataFile, err = wal.cm.dependencies.OpenFile(filepath.Join(sf.path, metadataFile), os.O_RDWR, 0700)
+	if err != nil {
+		atomic.StoreUint64(&sf.atomicUnavailable, 1)
+		wal.cm.log.Println("Difficulties opening sector metadata file for imported folder", sf.path, ":", err)
+		return
+	}
+	sf.sectorFile, err = wal.cm.dependencies.OpenFile(filepath.Join(sf.path, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		atomic.StoreUint64(&sf.atomicUnavailable, 1)
+		wal.cm.log.Println("Difficulties opening sector file for imported folder", sf.path, ":", err)
+		sf.metadataFile.Close()
+		return
+	}
+}
+
+// managedImportStorageFolder attaches the storage folder to the contract
+// manager, replacing the storage folder with the same index if it still
+// exists. The files of sf have already been verified and its metadata has been
+// rewritten from the manifest.
+func (wal *writeAheadLog) managedImportStorageFolder(sf *storageFolder, manifest FolderManifest) error {
+	// If the storage folder described by the manifest still exists, lock it
+	// for the duration of the import to prevent sectors from being added to
+	// it.
+	wal.cm.sectorMu.Lock()
+	old, exists := wal.cm.storageFolders[manifest.Index]
+	wal.cm.sectorMu.Unlock()
+	if exists {
+		old.mu.Lock()
+		defer old.mu.Unlock()
+	}
+
+	var syncChan chan struct{}
+	err := func() error {
+		wal.mu.Lock()
+		defer wal.mu.Unlock()
+
+		wal.cm.sectorMu.Lock()
+		defer wal.cm.sectorMu.Unlock()
+
+		current, currentExists := wal.cm.storageFolders[manifest.Index]
+		if currentExists != exists || current != old {
+			return errImportOutdatedManifest
+		}
+		for _, csf := range wal.cm.storageFolders {
+			if sf.path == csf.path {
+				return ErrRepeatFolder
+			}
+		}
+		if !exists && uint64(len(wal.cm.storageFolders)) > maximumStorageFolders {
+			return errMaxStorageFolders
+		}
+
+		// The sectors of the manifest need to match the sectors of the
+		// storage folder being replaced, or not be stored by the contract
+		// manager at all if there is no such storage folder.
+		var folderSectors, expectedSectors int
+		for _, sl := range wal.cm.sectorLocations {
+			if sl.storageFolder == manifest.Index {
+				folderSectors++
+			}
+		}
+		if exists {
+			expectedSectors = len(manifest.Sectors)
+		}
+		if folderSectors != expectedSectors {
+			return errImportOutdatedManifest
+		}
+		for _, s := range manifest.Sectors {
+			sl, located := wal.cm.sectorLocations[s.ID]
+			if !exists && located {
+				return errImportSectorExists
+			}
+			if exists && (!located || sl.storageFolder != manifest.Index || sl.index != s.Index || sl.count != s.Count) {
+				return errImportOutdatedManifest
+			}
+		}
+
+		// Replace the old storage folder. Its files are left on disk since
+		// they might still be needed by the user.
+		if exists {
+			if atomic.LoadUint64(&old.atomicUnavailable) == 0 {
+				err := build.ComposeErrors(old.metadataFile.Close(), old.sectorFile.Close())
+				if err != nil {
+					wal.cm.log.Printf("ERROR: unable to close the files of replaced storage folder %v: %v\n", old.path, err)
+				}
+			}
+			atomic.StoreUint64(&sf.atomicReadOnly, atomic.LoadUint64(&old.atomicReadOnly))
+		}
+		sf.index = manifest.Index
+		wal.cm.storageFolders[sf.index] = sf
+		for _, s := range manifest.Sectors {
+			sl := wal.cm.sectorLocations[s.ID]
+			sl.index = s.Index
+			sl.storageFolder = sf.index
+			sl.count = s.Count
+			wal.cm.sectorLocations[s.ID] = sl
+		}
+
+		wal.appendChange(stateChange{
+			StorageFolderImports: []savedStorageFolder{sf.savedStorageFolder()},
+		})
+		syncChan = wal.syncChan
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	// Wait to confirm the import until the WAL entry has synced.
+	<-syncChan
+	return nil
+}
+
+// ExportFolderMetadata returns a manifest of the sectors stored in the storage
+// folder with the given index. Together with a copy of the storage folder's
+// files, the manifest can be used to move the storage folder to a new location
+// using ImportFolder.
+func (cm *ContractManager) ExportFolderMetadata(index uint16) (FolderManifest, error) {
+	err := cm.tg.Add()
+	if err != nil {
+		return FolderManifest{}, err
+	}
+	defer cm.tg.Done()
+
+	cm.wal.mu.Lock()
+	defer cm.wal.mu.Unlock()
+	cm.sectorMu.Lock()
+	defer cm.sectorMu.Unlock()
+
+	sf, exists := cm.storageFolders[index]
+	if !exists {
+		return FolderManifest{}, errStorageFolderNotFound
+	}
+	manifest := FolderManifest{
+		Index:      index,
+		NumSectors: uint64(len(sf.usage)) * storageFolderGranularity,
+	}
+	for id, sl := range cm.sectorLocations {
+		if sl.storageFolder != index {
+			continue
+		}
+		manifest.Sectors = append(manifest.Sectors, FolderManifestSector{
+			ID:    id,
+			Index: sl.index,
+			Count: sl.count,
+		})
+	}
+	sort.Slice(manifest.Sectors, func(i, j int) bool {
+		return manifest.Sectors[i].Index < manifest.Sectors[j].Index
+	})
+	return manifest, nil
+}
+
+// ImportFolder attaches the storage folder files at path, which were copied
+// from the storage folder described by the manifest, to the contract manager.
+// A random sample of the sectors is verified against the manifest before the
+// sector locations are rebuilt from it. If the original storage folder is
+// still part of the contract manager, it is replaced by the imported one but
+// its files are not deleted.
+func (cm *ContractManager) ImportFolder(path string, manifest FolderManifest) (err error) {
+	err = cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	// Check that the manifest and the path are valid.
+	err = manifest.validate()
+	if err != nil {
+		return err
+	}
+	if !filepath.IsAbs(path) {
+		return errRelativePath
+	}
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !pathInfo.Mode().IsDir() {
+		return errStorageFolderNotFolder
+	}
+
+	// Open the copied files and make sure they are large enough.
+	sf := &storageFolder{
+		index: manifest.Index,
+		path:  path,
+		usage: make([]uint64, manifest.NumSectors/storageFolderGranularity),
+
+		availableSectors: make(map[sectorID]uint32),
+	}
+	sf.metadataFile, err = cm.dependencies.OpenFile(filepath.Join(path, metadataFile), os.O_RDWR, 0700)
+	if err != nil {
+		return build.ExtendErr("unable to open sector metadata file", err)
+	}
+	sf.sectorFile, err = cm.dependencies.OpenFile(filepath.Join(path, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		return errors.Compose(build.ExtendErr("unable to open sector file", err), sf.metadataFile.Close())
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Compose(err, sf.metadataFile.Close(), sf.sectorFile.Close())
+		}
+	}()
+	metadataInfo, err := sf.metadataFile.Stat()
+	if err != nil {
+		return build.ExtendErr("unable to stat sector metadata file", err)
+	}
+	sectorInfo, err := sf.sectorFile.Stat()
+	if err != nil {
+		return build.ExtendErr("unable to stat sector file", err)
+	}
+	if uint64(metadataInfo.Size()) < manifest.NumSectors*sectorMetadataDiskSize || uint64(sectorInfo.Size()) < manifest.NumSectors*modules.SectorSize {
+		return errImportTruncatedFile
+	}
+
+	// Verify a random sample of the sectors.
+	samples := fastrand.Perm(len(manifest.Sectors))
+	if len(samples) > importVerificationSamples {
+		samples = samples[:importVerificationSamples]
+	}
+	for _, i := range samples {
+		s := manifest.Sectors[i]
+		data, err := readSector(sf.sectorFile, s.Index)
+		if err != nil {
+			return build.ExtendErr("unable to read sector of imported storage folder", err)
+		}
+		if cm.managedSectorID(crypto.MerkleRoot(data)) != s.ID {
+			return errImportSectorMismatch
+		}
+	}
+
+	// Rebuild the metadata from the manifest and sync it before the import is
+	// added to the WAL. Entries of unused sectors are ignored since the usage
+	// is rebuilt from the manifest as well.
+	for _, s := range manifest.Sectors {
+		err = cm.wal.writeSectorMetadata(sf, sectorUpdate{
+			Count:  s.Count,
+			Folder: manifest.Index,
+			ID:     s.ID,
+			Index:  s.Index,
+		})
+		if err != nil {
+			return build.ExtendErr("unable to write sector metadata of imported storage folder", err)
+		}
+		sf.setUsage(s.Index)
+	}
+	err = build.ComposeErrors(sf.metadataFile.Sync(), cm.sectorLocationsCountOverflow.Sync())
+	if err != nil {
+		return build.ExtendErr("unable to sync sector metadata of imported storage folder", err)
+	}
+	return cm.wal.managedImportStorageFolder(sf, manifest)
+}
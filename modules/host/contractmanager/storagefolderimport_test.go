@@ -0,0 +1,361 @@
+package contractmanager
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// copyStorageFolder copies the files of the storage folder at src to dst,
+// simulating a user moving the data out of band.
+func copyStorageFolder(src, dst string) error {
+	err := os.MkdirAll(dst, 0700)
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{metadataFile, sectorFile} {
+		err = func() (err error) {
+			in, err := os.Open(filepath.Join(src, name))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err = errors.Compose(err, in.Close())
+			}()
+			out, err := os.Create(filepath.Join(dst, name))
+			if err != nil {
+				return err
+			}
+			defer func() {
+				err = errors.Compose(err, out.Close())
+			}()
+			_, err = io.Copy(out, in)
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addImportTestFolder adds a storage folder with numSectors random sectors to
+// the contract manager. The first sector is added twice to give it a virtual
+// sector.
+func addImportTestFolder(cmt *contractManagerTester, dir string, numSectors int) ([]crypto.Hash, [][]byte, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		return nil, nil, err
+	}
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		err = cmt.cm.AddSector(roots[i], datas[i])
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	err = cmt.cm.AddSector(roots[0], datas[0])
+	if err != nil {
+		return nil, nil, err
+	}
+	return roots, datas, nil
+}
+
+// TestExportImportStorageFolder checks that a storage folder can be moved to a
+// new location by exporting its metadata, copying its files and importing
+// them.
+func TestExportImportStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add a storage folder with some sectors.
+	srcDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	roots, datas, err := addImportTestFolder(cmt, srcDir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := cmt.cm.StorageFolders()[0].Index
+
+	// Exporting an unknown folder should fail.
+	_, err = cmt.cm.ExportFolderMetadata(index + 1)
+	if !errors.Contains(err, errStorageFolderNotFound) {
+		t.Fatal("expected errStorageFolderNotFound", err)
+	}
+
+	// Export the folder.
+	manifest, err := cmt.cm.ExportFolderMetadata(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Index != index || manifest.NumSectors != storageFolderGranularity*2 || len(manifest.Sectors) != len(roots) {
+		t.Fatal("wrong manifest", manifest.Index, manifest.NumSectors, len(manifest.Sectors))
+	}
+	for _, s := range manifest.Sectors {
+		expectedCount := uint64(1)
+		if s.ID == cmt.cm.managedSectorID(roots[0]) {
+			expectedCount = 2
+		}
+		if s.Count != expectedCount {
+			t.Fatalf("expected count %v but got %v", expectedCount, s.Count)
+		}
+	}
+
+	// Copy the files and import them.
+	dstDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	err = copyStorageFolder(srcDir, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.ImportFolder(dstDir, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Importing the same folder again should fail.
+	err = cmt.cm.ImportFolder(dstDir, manifest)
+	if !errors.Contains(err, ErrRepeatFolder) {
+		t.Fatal("expected ErrRepeatFolder", err)
+	}
+
+	// checkImport checks that the imported folder replaced the original one
+	// and that all sectors can be read from it.
+	checkImport := func() {
+		t.Helper()
+		sfs := cmt.cm.StorageFolders()
+		if len(sfs) != 1 {
+			t.Fatal("expected 1 storage folder but got", len(sfs))
+		}
+		if sfs[0].Path != dstDir || sfs[0].Index != index {
+			t.Fatal("wrong storage folder", sfs[0].Path, sfs[0].Index)
+		}
+		used := sfs[0].Capacity - sfs[0].CapacityRemaining
+		if used != uint64(len(roots))*modules.SectorSize {
+			t.Fatalf("storage folder should contain %v sectors but uses %v bytes", len(roots), used)
+		}
+		for i, root := range roots {
+			readData, err := cmt.cm.ReadSector(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(readData, datas[i]) {
+				t.Fatal("Reading a sector from the imported storage folder did not produce the right data")
+			}
+		}
+	}
+	checkImport()
+
+	// The files of the original folder should still exist.
+	if _, err := os.Stat(filepath.Join(srcDir, sectorFile)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Removing the virtual sector should keep the sector around.
+	err = cmt.cm.RemoveSector(roots[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImport()
+
+	// Restart the contract manager to check that the import was persisted.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkImport()
+
+	// New sectors should be stored in the imported folder.
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = append(roots, root)
+	datas = append(datas, data)
+	checkImport()
+}
+
+// TestImportStorageFolderErrors checks that imports of outdated manifests and
+// corrupted folders are aborted without affecting the original folder.
+func TestImportStorageFolderErrors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	srcDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	roots, datas, err := addImportTestFolder(cmt, srcDir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := cmt.cm.StorageFolders()[0].Index
+	manifest, err := cmt.cm.ExportFolderMetadata(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	err = copyStorageFolder(srcDir, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid manifests and paths should be rejected.
+	invalid := manifest
+	invalid.NumSectors++
+	if err := cmt.cm.ImportFolder(dstDir, invalid); !errors.Contains(err, errStorageFolderGranularity) {
+		t.Fatal("expected errStorageFolderGranularity", err)
+	}
+	invalid = manifest
+	invalid.Sectors = append([]FolderManifestSector{}, manifest.Sectors...)
+	invalid.Sectors[1].Index = invalid.Sectors[0].Index
+	if err := cmt.cm.ImportFolder(dstDir, invalid); !errors.Contains(err, errImportInvalidManifest) {
+		t.Fatal("expected errImportInvalidManifest", err)
+	}
+	if err := cmt.cm.ImportFolder("relative", manifest); !errors.Contains(err, errRelativePath) {
+		t.Fatal("expected errRelativePath", err)
+	}
+
+	// Corrupt one of the copied sectors. Since there are fewer sectors than
+	// samples, the corruption is guaranteed to be detected.
+	f, err := os.OpenFile(filepath.Join(dstDir, sectorFile), os.O_RDWR, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.WriteAt(fastrand.Bytes(32), int64(uint64(manifest.Sectors[3].Index)*modules.SectorSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.ImportFolder(dstDir, manifest)
+	if !errors.Contains(err, errImportSectorMismatch) {
+		t.Fatal("expected errImportSectorMismatch", err)
+	}
+
+	// Copy the folder again but add a sector to the original folder after the
+	// export. The manifest is outdated now.
+	err = copyStorageFolder(srcDir, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, data := randSector()
+	err = cmt.cm.AddSector(root, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = append(roots, root)
+	datas = append(datas, data)
+	err = cmt.cm.ImportFolder(dstDir, manifest)
+	if !errors.Contains(err, errImportOutdatedManifest) {
+		t.Fatal("expected errImportOutdatedManifest", err)
+	}
+
+	// The original folder should be unaffected.
+	sfs := cmt.cm.StorageFolders()
+	if len(sfs) != 1 || sfs[0].Path != srcDir {
+		t.Fatal("original storage folder should be unaffected")
+	}
+	for i, root := range roots {
+		readData, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(readData, datas[i]) {
+			t.Fatal("Reading a sector from the storage folder did not produce the right data")
+		}
+	}
+}
+
+// TestImportStorageFolderWAL imports a storage folder but leaves the WAL
+// behind so that a commit is necessary to finalize things.
+func TestImportStorageFolderWAL(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	d := new(dependencyLeaveWAL)
+	cmt, err := newMockedContractManagerTester(d, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	srcDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	roots, datas, err := addImportTestFolder(cmt, srcDir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	index := cmt.cm.StorageFolders()[0].Index
+	manifest, err := cmt.cm.ExportFolderMetadata(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dstDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	err = copyStorageFolder(srcDir, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Prevent the settings from being saved and import the folder.
+	d.mu.Lock()
+	d.triggered = true
+	d.mu.Unlock()
+	err = cmt.cm.ImportFolder(dstDir, manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restart the contract manager.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The imported folder should have replaced the original one.
+	sfs := cmt.cm.StorageFolders()
+	if len(sfs) != 1 || sfs[0].Path != dstDir || sfs[0].Index != index {
+		t.Fatal("imported storage folder wasn't recovered", sfs)
+	}
+	for i, root := range roots {
+		readData, err := cmt.cm.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(readData, datas[i]) {
+			t.Fatal("Reading a sector from the imported storage folder did not produce the right data")
+		}
+	}
+}
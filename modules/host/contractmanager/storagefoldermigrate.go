@@ -0,0 +1,80 @@
+package contractmanager
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// errMigrateSameStorageFolder is returned if the source and destination
+	// of a storage folder migration are the same storage folder.
+	errMigrateSameStorageFolder = errors.New("source and destination storage folder are the same")
+
+	// errMigrateUnavailableStorageFolder is returned if the destination of a
+	// storage folder migration is currently unavailable.
+	errMigrateUnavailableStorageFolder = errors.New("destination storage folder is unavailable")
+)
+
+// MigrateStorageFolder moves all of the sectors in the storage folder at
+// srcPath to the storage folder at dstPath. Every sector is written to the
+// destination folder before it is removed from the source folder, so a failed
+// migration never loses data. The source folder is not removed.
+func (cm *ContractManager) MigrateStorageFolder(srcPath, dstPath string) error {
+	err := cm.tg.Add()
+	if err != nil {
+		return err
+	}
+	defer cm.tg.Done()
+
+	// Retrieve the specified storage folders.
+	var src, dst *storageFolder
+	cm.sectorMu.Lock()
+	for _, sf := range cm.storageFolders {
+		if sf.path == srcPath {
+			src = sf
+		}
+		if sf.path == dstPath {
+			dst = sf
+		}
+	}
+	cm.sectorMu.Unlock()
+	if src == nil || dst == nil {
+		return errStorageFolderNotFound
+	}
+	if src == dst {
+		return errMigrateSameStorageFolder
+	}
+	if atomic.LoadUint64(&dst.atomicUnavailable) == 1 {
+		return errMigrateUnavailableStorageFolder
+	}
+
+	// Lock the source folder for the duration of the operation. This also
+	// prevents new sectors from being added to it.
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	// create a unique alert ID per storage folder migration and unregister it
+	// after completion.
+	alertID := modules.AlertID("cm-migrate-folder-" + hex.EncodeToString(fastrand.Bytes(12)))
+	defer cm.staticAlerter.UnregisterAlert(alertID)
+
+	cm.staticAlerter.RegisterAlert(alertID,
+		fmt.Sprintf("Migrating folder %s to folder %s", src.path, dst.path),
+		"folder op", modules.SeverityInfo)
+
+	// Move the sectors of the source folder to the destination folder.
+	_, err = cm.wal.managedEmptyStorageFolder(src.index, 0, dst)
+
+	// Wait for a synchronize to confirm that all of the moves have succeeded
+	// in full.
+	cm.wal.mu.Lock()
+	syncChan := cm.wal.syncChan
+	cm.wal.mu.Unlock()
+	<-syncChan
+	return err
+}
@@ -0,0 +1,113 @@
+package contractmanager
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// TestMigrateStorageFolder checks that migrating a storage folder moves all of
+// its sectors to the destination folder.
+func TestMigrateStorageFolder(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	cmt, err := newContractManagerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cmt.panicClose()
+
+	// Add the source folder and give it some sectors.
+	srcDir := filepath.Join(cmt.persistDir, "storageFolderOne")
+	err = os.MkdirAll(srcDir, 0700)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cmt.cm.AddStorageFolder(srcDir, modules.SectorSize*storageFolderGranularity*2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numSectors := 10
+	roots := make([]crypto.Hash, numSectors)
+	datas := make([][]byte, numSectors)
+	for i := range roots {
+		roots[i], datas[i] = randSector()
+		err = cmt.cm.AddSector(roots[i], datas[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add the destination folder as well as a third folder which shouldn't
+	// receive any sectors.
+	dstDir := filepath.Join(cmt.persistDir, "storageFolderTwo")
+	otherDir := filepath.Join(cmt.persistDir, "storageFolderThree")
+	for _, dir := range []string{dstDir, otherDir} {
+		err = os.MkdirAll(dir, 0700)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = cmt.cm.AddStorageFolder(dir, modules.SectorSize*storageFolderGranularity*2)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Migrating to the same or to an unknown folder should fail.
+	err = cmt.cm.MigrateStorageFolder(srcDir, srcDir)
+	if !errors.Contains(err, errMigrateSameStorageFolder) {
+		t.Fatal("expected errMigrateSameStorageFolder", err)
+	}
+	err = cmt.cm.MigrateStorageFolder(srcDir, filepath.Join(cmt.persistDir, "unknown"))
+	if !errors.Contains(err, errStorageFolderNotFound) {
+		t.Fatal("expected errStorageFolderNotFound", err)
+	}
+
+	// Migrate the sectors.
+	err = cmt.cm.MigrateStorageFolder(srcDir, dstDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkMigration checks that all sectors are stored in the destination
+	// folder and can be read.
+	checkMigration := func() {
+		t.Helper()
+		for _, sf := range cmt.cm.StorageFolders() {
+			used := sf.Capacity - sf.CapacityRemaining
+			if sf.Path == dstDir && used != uint64(numSectors)*modules.SectorSize {
+				t.Fatalf("destination folder should contain %v sectors but uses %v bytes", numSectors, used)
+			} else if sf.Path != dstDir && used != 0 {
+				t.Fatalf("folder %v should be empty but uses %v bytes", sf.Path, used)
+			}
+		}
+		for i, root := range roots {
+			readData, err := cmt.cm.ReadSector(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(readData, datas[i]) {
+				t.Fatal("Reading a sector from the storage folder did not produce the right data")
+			}
+		}
+	}
+	checkMigration()
+
+	// Restart the contract manager to check that the migration was persisted.
+	err = cmt.cm.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmt.cm, err = New(filepath.Join(cmt.persistDir, modules.ContractManagerDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkMigration()
+}
@@ -85,7 +85,7 @@ func (cm *ContractManager) RemoveStorageFolder(index uint16, force bool) error {
 		"folder op", modules.SeverityInfo)
 
 	// Clear out the sectors in the storage folder.
-	_, err = cm.wal.managedEmptyStorageFolder(index, 0)
+	_, err = cm.wal.managedEmptyStorageFolder(index, 0, nil)
 	if err != nil && !force {
 		return err
 	}
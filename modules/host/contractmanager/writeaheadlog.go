@@ -1,12 +1,15 @@
 package contractmanager
 
 import (
+	"bytes"
+	"encoding/binary"
 	"encoding/json"
-	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"github.com/pierrec/lz4/v4"
 	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
@@ -52,6 +55,7 @@ type (
 		ErroredStorageFolderExtensions    []uint16
 		StorageFolderAdditions            []savedStorageFolder
 		StorageFolderExtensions           []storageFolderExtension
+		StorageFolderImports              []savedStorageFolder
 		StorageFolderRemovals             []storageFolderRemoval
 		StorageFolderReductions           []storageFolderReduction
 		UnfinishedStorageFolderAdditions  []savedStorageFolder
@@ -70,12 +74,11 @@ type (
 		// The primary feature of the WAL is a file on disk that records all of
 		// the changes which have been proposed. The data is written to a temp
 		// file and then renamed atomically to a non-corrupt commitment of
-		// actions to be committed to the state. Data is written to the temp
-		// file continuously for performance reasons - when a Sync() ->
+		// actions to be committed to the state. The settings file, which might
+		// be multiple MiB large for larger storage arrays, is written to the
+		// temp file ahead of time for performance reasons - when a Sync() ->
 		// Rename() occurs, most of the data will have already been flushed to
-		// disk, making the operation faster. The same is done with the
-		// settings file, which might be multiple MiB large for larger storage
-		// arrays.
+		// disk, making the operation faster.
 		//
 		// To further increase throughput, the WAL will batch as many
 		// operations as possible. These operations can happen concurrently,
@@ -90,9 +93,15 @@ type (
 		// uncommittedChanges details a list of operations which have been
 		// suggested or queued to be made to the state, but are not yet
 		// guaranteed to have completed.
+		//
+		// pendingChanges are the uncommitted changes which haven't been
+		// written to the WAL tmp file yet. They are written as a single batch
+		// right before the file is synced, which allows for large batches to
+		// be compressed.
 		fileSettingsTmp    modules.File
 		fileWALTmp         modules.File
 		syncChan           chan struct{}
+		pendingChanges     []stateChange
 		uncommittedChanges []stateChange
 		committedSettings  savedSettings
 
@@ -113,10 +122,10 @@ func readWALMetadata(decoder *json.Decoder) error {
 		return build.ExtendErr("error reading WAL metadata", err)
 	}
 	if md.Header != walMetadata.Header {
-		return errors.New("WAL metadata header does not match header found in WAL file")
+		return persist.ErrBadHeader
 	}
-	if md.Version != walMetadata.Version {
-		return errors.New("WAL metadata version does not match version found in WAL file")
+	if md.Version != walMetadata.Version && md.Version != walMetadataV120.Version {
+		return persist.ErrBadVersion
 	}
 	return nil
 }
@@ -134,29 +143,141 @@ func writeWALMetadata(f modules.File) error {
 	return nil
 }
 
-// appendChange will add a change to the WAL, writing the details of the change
-// to the WAL file but not syncing - syncing is orchestrated by the sync loop.
+// marshalChanges marshals the changes into uncompressed WAL entries.
+func marshalChanges(changes []stateChange) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, sc := range changes {
+		changeBytes, err := json.MarshalIndent(sc, "", "\t")
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(changeBytes)
+	}
+	return buf.Bytes(), nil
+}
+
+// marshalCompressedChanges marshals the changes into a single compressed WAL
+// entry. The entry starts with a header of walCompressedEntryMagic followed by
+// the compressed and uncompressed size of the payload. The payload is a LZ4
+// block of the JSON encoded changes. If the changes can't be compressed, they
+// are marshaled into uncompressed entries instead.
+func marshalCompressedChanges(changes []stateChange) ([]byte, error) {
+	var payload bytes.Buffer
+	enc := json.NewEncoder(&payload)
+	for _, sc := range changes {
+		if err := enc.Encode(sc); err != nil {
+			return nil, err
+		}
+	}
+	entry := make([]byte, walCompressedEntryHeaderSize+lz4.CompressBlockBound(payload.Len()))
+	n, err := lz4.CompressBlock(payload.Bytes(), entry[walCompressedEntryHeaderSize:], nil)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to compress changes")
+	}
+	if n == 0 {
+		// The payload is incompressible.
+		return marshalChanges(changes)
+	}
+	entry[0] = walCompressedEntryMagic
+	binary.LittleEndian.PutUint64(entry[1:], uint64(n))
+	binary.LittleEndian.PutUint64(entry[9:], uint64(payload.Len()))
+	return entry[:walCompressedEntryHeaderSize+n], nil
+}
+
+// unmarshalChanges decodes the compressed and uncompressed WAL entries in the
+// provided data.
+func unmarshalChanges(data []byte) ([]stateChange, error) {
+	var scs []stateChange
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n")
+		if len(data) == 0 {
+			return scs, nil
+		}
+
+		// Uncompressed entries are decoded one at a time.
+		if data[0] != walCompressedEntryMagic {
+			var sc stateChange
+			dec := json.NewDecoder(bytes.NewReader(data))
+			if err := dec.Decode(&sc); err != nil {
+				return nil, err
+			}
+			scs = append(scs, sc)
+			data = data[dec.InputOffset():]
+			continue
+		}
+
+		// Decompress the payload of a compressed entry, which contains the
+		// changes as uncompressed entries.
+		if len(data) < walCompressedEntryHeaderSize {
+			return nil, errors.New("compressed WAL entry header is truncated")
+		}
+		compressedSize := binary.LittleEndian.Uint64(data[1:])
+		size := binary.LittleEndian.Uint64(data[9:])
+		data = data[walCompressedEntryHeaderSize:]
+		if compressedSize > uint64(len(data)) {
+			return nil, errors.New("compressed WAL entry is truncated")
+		}
+		// LZ4 can't compress data by more than a factor of 255.
+		if size > 255*compressedSize {
+			return nil, errors.New("compressed WAL entry has an invalid size")
+		}
+		payload := make([]byte, size)
+		n, err := lz4.UncompressBlock(data[:compressedSize], payload)
+		if err != nil {
+			return nil, errors.AddContext(err, "failed to decompress WAL entry")
+		}
+		if uint64(n) != size {
+			return nil, errors.New("decompressed WAL entry has the wrong size")
+		}
+		data = data[compressedSize:]
+		changes, err := unmarshalChanges(payload)
+		if err != nil {
+			return nil, err
+		}
+		scs = append(scs, changes...)
+	}
+}
+
+// appendChange will add a change to the WAL. The details of the change are
+// written to the WAL file by the sync loop right before syncing.
 //
 // The WAL is append only, which means that changes can only be revoked by
 // appending an error. This is common for long running operations like adding a
 // storage folder.
 func (wal *writeAheadLog) appendChange(sc stateChange) {
-	// Marshal the change and then write the change to the WAL file. Syncing
-	// happens in the sync loop.
-	changeBytes, err := json.MarshalIndent(sc, "", "\t")
+	// Update the WAL to include the new storage folder in the uncommitted
+	// changes.
+	wal.pendingChanges = append(wal.pendingChanges, sc)
+	wal.uncommittedChanges = append(wal.uncommittedChanges, sc)
+}
+
+// writePendingChanges writes the pending changes to the WAL file but doesn't
+// sync it. Batches which exceed walCompressionThreshold changes are written as
+// a single compressed entry.
+func (wal *writeAheadLog) writePendingChanges() {
+	changes := wal.pendingChanges
+	wal.pendingChanges = nil
+	if len(changes) == 0 {
+		return
+	}
+
+	// Marshal the changes and then write them to the WAL file.
+	var changeBytes []byte
+	var err error
+	if len(changes) > walCompressionThreshold && !wal.cm.dependencies.Disrupt("disableWALCompression") {
+		changeBytes, err = marshalCompressedChanges(changes)
+	} else {
+		changeBytes, err = marshalChanges(changes)
+	}
 	if err != nil {
-		wal.cm.log.Severe("Unable to marshal state change:", err)
-		panic("unable to append a change to the WAL, crashing to prevent corruption")
+		wal.cm.log.Severe("Unable to marshal state changes:", err)
+		panic("unable to append changes to the WAL, crashing to prevent corruption")
 	}
 	_, err = wal.fileWALTmp.Write(changeBytes)
 	if err != nil {
-		wal.cm.log.Severe("Unable to write state change to WAL:", err)
-		panic("unable to append a change to the WAL, crashing to prevent corruption")
+		wal.cm.log.Severe("Unable to write state changes to WAL:", err)
+		panic("unable to append changes to the WAL, crashing to prevent corruption")
 	}
-
-	// Update the WAL to include the new storage folder in the uncommitted
-	// changes.
-	wal.uncommittedChanges = append(wal.uncommittedChanges, sc)
 }
 
 // commitChange will commit the provided change to the contract manager,
@@ -177,6 +298,11 @@ func (wal *writeAheadLog) commitChange(sc stateChange) {
 			wal.commitStorageFolderExtension(sfe)
 		}
 	}
+	for _, sfi := range sc.StorageFolderImports {
+		for i := uint64(0); i < wal.cm.dependencies.AtLeastOne(); i++ {
+			wal.commitImportStorageFolder(sfi)
+		}
+	}
 	for _, sfr := range sc.StorageFolderReductions {
 		for i := uint64(0); i < wal.cm.dependencies.AtLeastOne(); i++ {
 			wal.commitStorageFolderReduction(sfr)
@@ -214,35 +340,35 @@ func (wal *writeAheadLog) createWALTmp() {
 // restoring the program to consistency after an unclean shutdown. The tmp WAL
 // file needs to be open before this function is called.
 func (wal *writeAheadLog) recoverWAL(walFile modules.File) error {
+	walBytes, err := ioutil.ReadAll(walFile)
+	if err != nil {
+		return build.ExtendErr("unable to read walFile", err)
+	}
+
 	// Read the WAL metadata to make sure that the version is correct.
-	decoder := json.NewDecoder(walFile)
-	err := readWALMetadata(decoder)
+	decoder := json.NewDecoder(bytes.NewReader(walBytes))
+	err = readWALMetadata(decoder)
 	if err != nil {
 		wal.cm.log.Println("ERROR: error while reading WAL metadata:", err)
 		return build.ExtendErr("walFile metadata mismatch", err)
 	}
 
-	// Read changes from the WAL one at a time and load them back into memory.
-	// A full list of changes is kept so that modifications to long running
-	// changes can be parsed properly.
-	var sc stateChange
-	var scs []stateChange
-	for err == nil {
-		err = decoder.Decode(&sc)
-		if err == nil {
-			// The uncommitted changes are loaded into memory using a simple
-			// append, because the tmp WAL file has not been created yet, and
-			// will not be created until the sync loop is spawned. The sync
-			// loop spawner will make sure that the uncommitted changes are
-			// written to the tmp WAL file.
-			wal.commitChange(sc)
-			scs = append(scs, sc)
-		}
-	}
-	if !errors.Contains(err, io.EOF) {
+	// Read changes from the WAL and load them back into memory. A full list
+	// of changes is kept so that modifications to long running changes can be
+	// parsed properly.
+	scs, err := unmarshalChanges(walBytes[decoder.InputOffset():])
+	if err != nil {
 		wal.cm.log.Println("ERROR: could not load WAL json:", err)
 		return build.ExtendErr("error loading WAL json", err)
 	}
+	for _, sc := range scs {
+		// The uncommitted changes are loaded into memory using a simple
+		// append, because the tmp WAL file has not been created yet, and will
+		// not be created until the sync loop is spawned. The sync loop spawner
+		// will make sure that the uncommitted changes are written to the tmp
+		// WAL file.
+		wal.commitChange(sc)
+	}
 
 	// Do any cleanup regarding long-running unfinished tasks. Long running
 	// task cleanup cannot be handled in the 'commitChange' loop because future
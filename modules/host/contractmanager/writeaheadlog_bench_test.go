@@ -0,0 +1,73 @@
+package contractmanager
+
+import (
+	"os"
+	"testing"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// dependencyNoWALCompression is a mocked dependency that disables the
+// compression of large batches of WAL entries.
+type dependencyNoWALCompression struct {
+	modules.ProductionDependencies
+}
+
+// Disrupt disables the WAL compression.
+func (*dependencyNoWALCompression) Disrupt(s string) bool {
+	return s == "disableWALCompression"
+}
+
+// BenchmarkWALWrite benchmarks writing the WAL entries of adding 10,000
+// sectors to the WAL file with and without compression.
+func BenchmarkWALWrite(b *testing.B) {
+	b.Run("Uncompressed", func(b *testing.B) {
+		benchmarkWALWrite(b, &dependencyNoWALCompression{})
+	})
+	b.Run("Compressed", func(b *testing.B) {
+		benchmarkWALWrite(b, modules.ProdDependencies)
+	})
+}
+
+// benchmarkWALWrite benchmarks writing the WAL entries of adding 10,000
+// sectors to the WAL file using the provided dependencies.
+func benchmarkWALWrite(b *testing.B, deps modules.Dependencies) {
+	testdir := build.TempDir(modules.ContractManagerDir, b.Name())
+	err := os.MkdirAll(testdir, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		b.Fatal(err)
+	}
+	wal := &writeAheadLog{
+		cm: &ContractManager{
+			dependencies: deps,
+			persistDir:   testdir,
+		},
+	}
+	scs := randomSectorUpdateChanges(10e3)
+
+	b.ResetTimer()
+	var walSize int64
+	for i := 0; i < b.N; i++ {
+		wal.createWALTmp()
+		for _, sc := range scs {
+			wal.appendChange(sc)
+		}
+		wal.writePendingChanges()
+		if err := wal.fileWALTmp.Sync(); err != nil {
+			b.Fatal(err)
+		}
+		stat, err := wal.fileWALTmp.Stat()
+		if err != nil {
+			b.Fatal(err)
+		}
+		walSize = stat.Size()
+		if err := wal.fileWALTmp.Close(); err != nil {
+			b.Fatal(err)
+		}
+		wal.uncommittedChanges = nil
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(walSize), "walbytes")
+}
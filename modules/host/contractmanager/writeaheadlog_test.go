@@ -1,11 +1,16 @@
 package contractmanager
 
 import (
+	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/persist"
@@ -48,3 +53,179 @@ func TestLoadWAL(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestReadWALMetadata tests that WALs of the current and the legacy version
+// are accepted and that other WALs are rejected.
+func TestReadWALMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		md  persist.Metadata
+		err error
+	}{
+		{walMetadata, nil},
+		{walMetadataV120, nil},
+		{persist.Metadata{Header: walMetadata.Header, Version: "1.7.0"}, persist.ErrBadVersion},
+		{persist.Metadata{Header: "Sia Contract Manager", Version: walMetadata.Version}, persist.ErrBadHeader},
+	}
+	for _, test := range tests {
+		b, err := json.MarshalIndent(test.md, "", "\t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = readWALMetadata(json.NewDecoder(bytes.NewReader(b)))
+		if test.err == nil && err != nil {
+			t.Fatal(test.md, err)
+		}
+		if test.err != nil && !errors.Contains(err, test.err) {
+			t.Fatalf("%v: expected %v but got %v", test.md, test.err, err)
+		}
+	}
+}
+
+// randomSectorUpdateChanges creates n state changes which each contain a
+// random sector update.
+func randomSectorUpdateChanges(n int) []stateChange {
+	scs := make([]stateChange, n)
+	for i := range scs {
+		su := sectorUpdate{
+			Count:  1,
+			Folder: uint16(fastrand.Intn(10)),
+			Index:  uint32(i),
+		}
+		fastrand.Read(su.ID[:])
+		scs[i].SectorUpdates = []sectorUpdate{su}
+	}
+	return scs
+}
+
+// TestWALCompression tests marshaling and unmarshaling compressed and
+// uncompressed WAL entries.
+func TestWALCompression(t *testing.T) {
+	t.Parallel()
+
+	scs := randomSectorUpdateChanges(100)
+
+	// Marshal the changes with and without compression.
+	plain, err := marshalChanges(scs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := marshalCompressedChanges(scs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain[0] != '{' {
+		t.Fatal("uncompressed entries should start with a JSON object")
+	}
+	if compressed[0] != walCompressedEntryMagic {
+		t.Fatal("compressed entry should start with the magic byte")
+	}
+	if len(compressed) >= len(plain) {
+		t.Fatalf("compressed entry should be smaller than the uncompressed entries: %v >= %v", len(compressed), len(plain))
+	}
+
+	// Both should unmarshal to the original changes.
+	for _, b := range [][]byte{plain, compressed} {
+		decoded, err := unmarshalChanges(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(decoded, scs) {
+			t.Fatal("decoded changes don't match the original ones")
+		}
+	}
+
+	// Mixing compressed and uncompressed entries should work as well.
+	mixed := append(append(append([]byte{}, plain...), compressed...), plain...)
+	decoded, err := unmarshalChanges(mixed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, append(append(append([]stateChange{}, scs...), scs...), scs...)) {
+		t.Fatal("decoded changes don't match the original ones")
+	}
+
+	// A truncated compressed entry should fail to decode.
+	if _, err := unmarshalChanges(compressed[:walCompressedEntryHeaderSize-1]); err == nil {
+		t.Fatal("expected truncated header to fail")
+	}
+	if _, err := unmarshalChanges(compressed[:len(compressed)-1]); err == nil {
+		t.Fatal("expected truncated entry to fail")
+	}
+}
+
+// TestWALCompressionThreshold tests that only batches exceeding the
+// walCompressionThreshold are compressed when writing the pending changes of
+// the WAL.
+func TestWALCompressionThreshold(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testdir := build.TempDir(modules.ContractManagerDir, t.Name())
+	err := os.MkdirAll(testdir, persist.DefaultDiskPermissionsTest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wal := &writeAheadLog{
+		cm: &ContractManager{
+			dependencies: modules.ProdDependencies,
+			persistDir:   testdir,
+		},
+	}
+
+	// writeChanges appends the changes to a fresh WAL tmp file and returns
+	// the changes written to it after the metadata.
+	metadata, err := json.MarshalIndent(walMetadata, "", "\t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeChanges := func(scs []stateChange) []byte {
+		wal.createWALTmp()
+		for _, sc := range scs {
+			wal.appendChange(sc)
+		}
+		wal.writePendingChanges()
+		if err := wal.fileWALTmp.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if len(wal.pendingChanges) != 0 {
+			t.Fatal("pending changes weren't cleared")
+		}
+		b, err := ioutil.ReadFile(filepath.Join(testdir, walFileTmp))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b[len(metadata):]
+	}
+
+	// A batch at the threshold isn't compressed.
+	scs := randomSectorUpdateChanges(walCompressionThreshold)
+	b := writeChanges(scs)
+	if b[0] != '{' {
+		t.Fatal("batch at the threshold shouldn't be compressed")
+	}
+	decoded, err := unmarshalChanges(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, scs) {
+		t.Fatal("decoded changes don't match the original ones")
+	}
+
+	// A batch exceeding the threshold is.
+	scs = randomSectorUpdateChanges(walCompressionThreshold + 1)
+	b = writeChanges(scs)
+	if b[0] != walCompressedEntryMagic {
+		t.Fatal("batch exceeding the threshold should be compressed")
+	}
+	decoded, err = unmarshalChanges(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, scs) {
+		t.Fatal("decoded changes don't match the original ones")
+	}
+}
@@ -105,6 +105,7 @@ func (wal *writeAheadLog) syncResources() {
 			return
 		}
 
+		wal.writePendingChanges()
 		err := wal.fileWALTmp.Sync()
 		if err != nil {
 			wal.cm.log.Severe("Unable to sync the write-ahead-log:", err)
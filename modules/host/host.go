@@ -160,6 +160,7 @@ type Host struct {
 	// Subsystems
 	staticAccountManager        *accountManager
 	staticMDM                   *mdm.MDM
+	staticRenterUsage           *renterUsage
 	staticRegistry              *registry.Registry
 	staticRegistrySubscriptions *registrySubscriptions
 
@@ -176,6 +177,7 @@ type Host struct {
 	// otherwise are not critical to always be correct.
 	autoAddress          modules.NetAddress // Determined using automatic tooling in network.go
 	financialMetrics     modules.HostFinancialMetrics
+	orphanedSectors      modules.HostOrphanedSectors
 	settings             modules.HostInternalSettings
 	revisionNumber       uint64
 	workingStatus        modules.HostWorkingStatus
@@ -211,12 +213,14 @@ type Host struct {
 
 // hostPrices is a helper type that wraps both the host's RPC price table and
 // the set of price tables containing prices it has guaranteed to all renters,
-// covered by a read write mutex to help lock contention. It contains a separate
-// minheap that enables efficiently purging expired price tables from the
-// 'guaranteed' map.
+// covered by a read write mutex to help lock contention. Price tables which
+// renters pinned for a specific RPC are tracked separately in the 'pinned'
+// map. It contains a separate minheap that enables efficiently purging expired
+// price tables from both maps.
 type hostPrices struct {
 	current       modules.RPCPriceTable
 	guaranteed    map[modules.UniqueID]*hostRPCPriceTable
+	pinned        map[modules.UniqueID]*pinnedRPCPriceTable
 	staticMinHeap priceTableHeap
 	mu            sync.RWMutex
 }
@@ -236,6 +240,22 @@ func (hp *hostPrices) managedGet(uid modules.UniqueID) (pt *hostRPCPriceTable, f
 	return
 }
 
+// managedGetForRPC returns the price table with given uid if it is either a
+// regular price table or a price table that was pinned for the given rpc.
+func (hp *hostPrices) managedGetForRPC(uid modules.UniqueID, rpc types.Specifier) (pt *hostRPCPriceTable, found bool) {
+	hp.mu.RLock()
+	defer hp.mu.RUnlock()
+	pt, found = hp.guaranteed[uid]
+	if found {
+		return
+	}
+	ppt, found := hp.pinned[uid]
+	if !found || ppt.staticRPC != rpc {
+		return nil, false
+	}
+	return ppt.hostRPCPriceTable, true
+}
+
 // managedSetCurrent overwrites the current price table with the one that's
 // given
 func (hp *hostPrices) managedSetCurrent(pt modules.RPCPriceTable) {
@@ -254,8 +274,21 @@ func (hp *hostPrices) managedTrack(pt *hostRPCPriceTable) {
 	hp.staticMinHeap.Push(pt)
 }
 
+// managedTrackPinned adds the given price table to the 'pinned' map, that
+// holds all of the price tables renters pinned for a specific rpc. It will also
+// add it to the heap which facilitates efficient pruning of that map.
+func (hp *hostPrices) managedTrackPinned(pt *hostRPCPriceTable, rpc types.Specifier) {
+	hp.mu.Lock()
+	hp.pinned[pt.UID] = &pinnedRPCPriceTable{
+		hostRPCPriceTable: pt,
+		staticRPC:         rpc,
+	}
+	hp.mu.Unlock()
+	hp.staticMinHeap.Push(pt)
+}
+
 // managedPruneExpired removes all of the price tables that have expired from
-// the 'guaranteed' map.
+// the 'guaranteed' and 'pinned' maps.
 func (hp *hostPrices) managedPruneExpired() {
 	current := hp.managedCurrent()
 	expired := hp.staticMinHeap.PopExpired()
@@ -272,6 +305,7 @@ func (hp *hostPrices) managedPruneExpired() {
 			continue
 		}
 		delete(hp.guaranteed, uid)
+		delete(hp.pinned, uid)
 	}
 	hp.mu.Unlock()
 }
@@ -378,6 +412,9 @@ func (h *Host) managedUpdatePriceTable() {
 		MaxDuration:    hes.MaxDuration,
 		WindowSize:     hes.WindowSize,
 
+		// Contract size related fields.
+		MaxContractSize: hes.MaxContractSize,
+
 		// Registry related fields.
 		RegistryEntriesLeft:  h.staticRegistry.Cap() - h.staticRegistry.Len(),
 		RegistryEntriesTotal: h.staticRegistry.Cap(),
@@ -451,11 +488,13 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 		lockedStorageObligations: make(map[types.FileContractID]*lockedObligation),
 		staticPriceTables: &hostPrices{
 			guaranteed: make(map[modules.UniqueID]*hostRPCPriceTable),
+			pinned:     make(map[modules.UniqueID]*pinnedRPCPriceTable),
 			staticMinHeap: priceTableHeap{
 				heap: make([]*hostRPCPriceTable, 0),
 			},
 		},
 		staticRegistrySubscriptions: newRegistrySubscriptions(),
+		staticRenterUsage:           newRenterUsage(),
 		persistDir:                  persistDir,
 	}
 
@@ -511,6 +550,7 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 	if err != nil {
 		return nil, err
 	}
+	h.StorageManager.SetReadCacheSize(h.settings.ReadCacheSize)
 	h.tg.AfterStop(func() {
 		err := h.saveSync()
 		if err != nil {
@@ -561,6 +601,10 @@ func newHost(dependencies modules.Dependencies, smDeps modules.Dependencies, cs
 	// Ensure the expired RPC tables get pruned as to not leak memory
 	go h.threadedPruneExpiredPriceTables()
 
+	// Periodically check for sectors which aren't referenced by any storage
+	// obligation.
+	go h.threadedCollectOrphanedSectors()
+
 	return h, nil
 }
 
@@ -715,6 +759,22 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 		}
 	}
 
+	// A delay of 0 resets the orphaned sector deletion delay to the default.
+	if settings.OrphanedSectorDeletionDelay == 0 {
+		settings.OrphanedSectorDeletionDelay = defaultOrphanedSectorDeletionDelay
+	}
+
+	// A validity of 0 resets the maximum pinned price table validity to the
+	// default.
+	if settings.MaxPinnedPriceTableValidity == 0 {
+		settings.MaxPinnedPriceTableValidity = defaultMaxPinnedPriceTableValidity
+	}
+
+	// Update the size of the sector read cache.
+	if h.settings.ReadCacheSize != settings.ReadCacheSize {
+		h.StorageManager.SetReadCacheSize(settings.ReadCacheSize)
+	}
+
 	// Migrate the registry if necessary.
 	if h.settings.CustomRegistryPath != settings.CustomRegistryPath {
 		path := settings.CustomRegistryPath
@@ -734,6 +794,7 @@ func (h *Host) SetInternalSettings(settings modules.HostInternalSettings) error
 	// The locked storage collateral was altered, we potentially want to
 	// unregister the insufficient collateral budget alert
 	h.tryUnregisterInsufficientCollateralBudgetAlert()
+	h.updateCollateralBudgetAlert()
 
 	err = h.saveSync()
 	if err != nil {
@@ -752,6 +813,17 @@ func (h *Host) InternalSettings() modules.HostInternalSettings {
 	return h.managedInternalSettings()
 }
 
+// MDMActivePrograms returns the programs which are currently executed by the
+// host's MDM.
+func (h *Host) MDMActivePrograms() []modules.MDMProgramInfo {
+	return h.staticMDM.ActivePrograms()
+}
+
+// MDMStats returns the cumulative statistics of the host's MDM.
+func (h *Host) MDMStats() modules.MDMStats {
+	return h.staticMDM.Stats()
+}
+
 // BlockHeight returns the host's current blockheight.
 func (h *Host) BlockHeight() types.BlockHeight {
 	h.mu.RLock()
@@ -78,7 +78,7 @@ func (ht *hostTester) initRenting() error {
 	}
 
 	// Announce the host.
-	err := ht.host.Announce()
+	err := ht.host.Announce(false)
 	if err != nil {
 		return err
 	}
@@ -967,13 +967,14 @@ func (p *renterHostPair) SubcribeToRV(stream siamux.Stream, pt *modules.RPCPrice
 	}
 
 	// Check response.
-	var rv *modules.SignedRegistryValue
 	if len(rvs) > 1 {
 		build.Critical("more responses than subscribed to values")
-	} else if len(rvs) == 1 {
-		rv = &rvs[0].Entry
 	}
-	return rv, nil
+	rv, exists := rvs[modules.DeriveRegistryEntryID(pubkey, tweak)]
+	if !exists {
+		return nil, nil
+	}
+	return &rv, nil
 }
 
 // UnsubscribeFromRV unsubscribes from the given publickey/tweak pair.
@@ -82,6 +82,13 @@ func (tb *testProgramBuilder) AddHasSectorInstruction(merkleRoot crypto.Hash) {
 	tb.staticValues.AddHasSectorInstruction()
 }
 
+// AddHasSectorsInstruction adds a hassectors instruction to the builder,
+// keeping track of running values.
+func (tb *testProgramBuilder) AddHasSectorsInstruction(merkleRoots []crypto.Hash) {
+	tb.staticPB.AddHasSectorsInstruction(merkleRoots)
+	tb.staticValues.AddHasSectorsInstruction(uint64(len(merkleRoots)))
+}
+
 // AddReadOffsetInstruction adds a readoffset instruction to the builder,
 // keeping track of running values.
 func (tb *testProgramBuilder) AddReadOffsetInstruction(length, offset uint64, merkleProof bool) {
@@ -110,6 +117,16 @@ func (tb *testProgramBuilder) AddSwapSectorInstruction(sector1Idx, sector2Idx ui
 	tb.staticValues.AddSwapSectorInstruction()
 }
 
+// AddUpdateSectorInstruction adds an UpdateSector instruction to the builder,
+// keeping track of running values.
+func (tb *testProgramBuilder) AddUpdateSectorInstruction(root crypto.Hash, data []byte, merkleProof bool) {
+	err := tb.staticPB.AddUpdateSectorInstruction(root, data, merkleProof)
+	if err != nil {
+		panic(err)
+	}
+	tb.staticValues.AddUpdateSectorInstruction(data)
+}
+
 // AddUpdateRegistryInstruction adds an UpdateRegistry instruction to the
 // builder, keeping track of running values.
 func (tb *testProgramBuilder) AddUpdateRegistryInstruction(spk types.SiaPublicKey, rv modules.SignedRegistryValue) {
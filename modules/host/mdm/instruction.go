@@ -46,6 +46,11 @@ type Output struct {
 	// FailureRefund is the amount of money that gets refunded should the
 	// program execution fail.
 	FailureRefund types.Currency
+	// UploadBandwidth and DownloadBandwidth contain the running program values
+	// for the bandwidth consumed by the executed instructions. The last output
+	// of a program contains the program's total bandwidth.
+	UploadBandwidth   uint64
+	DownloadBandwidth uint64
 }
 
 // output is the type returned by all instructions when being executed.
@@ -32,6 +32,10 @@ func (p *program) staticDecodeAppendInstruction(instruction modules.Instruction)
 	}
 	// Read args.
 	dataOffset := binary.LittleEndian.Uint64(instruction.Args[:8])
+	// Check that the operands are within the program data.
+	if err := p.staticData.checkBounds(dataOffset, modules.SectorSize); err != nil {
+		return nil, err
+	}
 	return &instructionAppend{
 		commonInstruction: commonInstruction{
 			staticData:        p.staticData,
@@ -56,6 +60,9 @@ func (i *instructionAppend) Execute(prevOutput output) (output, types.Currency)
 		return errOutput(err), types.ZeroCurrency
 	}
 	newFileSize := prevOutput.NewSize + modules.SectorSize
+	if maxSize := i.staticState.priceTable.MaxContractSize; maxSize != 0 && newFileSize > maxSize {
+		return errOutput(modules.ErrMaxContractSize), types.ZeroCurrency
+	}
 
 	// TODO: How to update finances with EA?
 	// i.staticState.potentialStorageRevenue = i.staticState.potentialStorageRevenue.Add(types.ZeroCurrency)
@@ -68,6 +75,7 @@ func (i *instructionAppend) Execute(prevOutput output) (output, types.Currency)
 	if err != nil {
 		return errOutput(err), types.ZeroCurrency
 	}
+	ps.bandwidth.upload += modules.SectorSize
 
 	// Construct proof if necessary.
 	var proof []crypto.Hash
@@ -1,8 +1,11 @@
 package mdm
 
 import (
+	"bytes"
+	"context"
 	"testing"
 
+	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
@@ -125,3 +128,56 @@ func TestInstructionSingleAppend(t *testing.T) {
 		t.Fatal("sectorRoots contains wrong root")
 	}
 }
+
+// TestInstructionAppendMaxContractSize tests that appending to a storage
+// obligation fails once the contract would exceed the max contract size of the
+// price table.
+func TestInstructionAppendMaxContractSize(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a program which appends 2 sectors to a storage obligation which
+	// only has room for 1.
+	pt := newTestPriceTable()
+	pt.MaxContractSize = modules.SectorSize
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddAppendInstruction(randomSectorData(), false)
+	tb.AddAppendInstruction(randomSectorData(), false)
+	program, data := tb.Program()
+	values := tb.Cost()
+	_, _, collateral, _ := values.Cost()
+
+	// Execute it.
+	so := host.newTestStorageObligation(true)
+	finalize, outputChan, err := mdm.ExecuteProgram(context.Background(), pt, program, values.Budget(true), collateral, so, duration, uint64(len(data)), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outputs []Output
+	for output := range outputChan {
+		outputs = append(outputs, output)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("expected 2 outputs but got %v", len(outputs))
+	}
+	// The first append should succeed.
+	if outputs[0].Error != nil || outputs[0].NewSize != modules.SectorSize {
+		t.Fatal("first append failed", outputs[0].Error, outputs[0].NewSize)
+	}
+	// The second one should fail.
+	if !errors.Contains(outputs[1].Error, modules.ErrMaxContractSize) {
+		t.Fatal("expected ErrMaxContractSize but got", outputs[1].Error)
+	}
+	if !modules.IsContractFullErr(outputs[1].Error) {
+		t.Fatal("error should be recognized as contract full error")
+	}
+	// Finalizing should fail.
+	if err := finalize(so); err == nil {
+		t.Fatal("finalizing should fail")
+	}
+	if len(so.sectorRoots) != 0 {
+		t.Fatal("storage obligation shouldn't have changed")
+	}
+}
@@ -32,6 +32,10 @@ func (p *program) staticDecodeDropSectorsInstruction(instruction modules.Instruc
 	}
 	// Read args.
 	numSectorsOffset := binary.LittleEndian.Uint64(instruction.Args[:8])
+	// Check that the operands are within the program data.
+	if err := p.staticData.checkBounds(numSectorsOffset, 8); err != nil {
+		return nil, err
+	}
 	return &instructionDropSectors{
 		commonInstruction: commonInstruction{
 			staticData:        p.staticData,
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -31,6 +32,10 @@ func (p *program) staticDecodeHasSectorInstruction(instruction modules.Instructi
 	}
 	// Read args.
 	rootOffset := binary.LittleEndian.Uint64(instruction.Args[:8])
+	// Check that the operands are within the program data.
+	if err := p.staticData.checkBounds(rootOffset, crypto.HashSize); err != nil {
+		return nil, err
+	}
 	return &instructionHasSector{
 		commonInstruction: commonInstruction{
 			staticData:        p.staticData,
@@ -0,0 +1,141 @@
+package mdm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// instructionHasSectors is an instruction which returns whether the host stores
+// the sectors with the given roots or not.
+type instructionHasSectors struct {
+	commonInstruction
+
+	numSectorsOffset  uint64
+	merkleRootsOffset uint64
+}
+
+// staticDecodeHasSectorsInstruction creates a new 'HasSectors' instruction from
+// the provided generic instruction.
+func (p *program) staticDecodeHasSectorsInstruction(instruction modules.Instruction) (instruction, error) {
+	// Check specifier.
+	if instruction.Specifier != modules.SpecifierHasSectors {
+		return nil, fmt.Errorf("expected specifier %v but got %v",
+			modules.SpecifierHasSectors, instruction.Specifier)
+	}
+	// Check args.
+	if len(instruction.Args) != modules.RPCIHasSectorsLen {
+		return nil, fmt.Errorf("expected instruction to have len %v but was %v",
+			modules.RPCIHasSectorsLen, len(instruction.Args))
+	}
+	// Read args.
+	numSectorsOffset := binary.LittleEndian.Uint64(instruction.Args[:8])
+	merkleRootsOffset := binary.LittleEndian.Uint64(instruction.Args[8:16])
+	// Check that the operands are within the program data. The length of the
+	// merkle roots is only known once the number of sectors is read.
+	err := errors.Compose(
+		p.staticData.checkBounds(numSectorsOffset, 8),
+		p.staticData.checkBounds(merkleRootsOffset, 0),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &instructionHasSectors{
+		commonInstruction: commonInstruction{
+			staticData:        p.staticData,
+			staticMerkleProof: false,
+			staticState:       p.staticProgramState,
+		},
+		numSectorsOffset:  numSectorsOffset,
+		merkleRootsOffset: merkleRootsOffset,
+	}, nil
+}
+
+// Batch declares whether or not this instruction can be batched together with
+// the previous instruction.
+func (i instructionHasSectors) Batch() bool {
+	return true
+}
+
+// Collateral is zero for the HasSectors instruction.
+func (i *instructionHasSectors) Collateral() types.Currency {
+	return modules.MDMHasSectorsCollateral()
+}
+
+// Cost returns the cost of executing this instruction.
+func (i *instructionHasSectors) Cost() (executionCost, _ types.Currency, err error) {
+	numSectors, err := i.staticNumSectors()
+	if err != nil {
+		return
+	}
+	executionCost = modules.MDMHasSectorsCost(i.staticState.priceTable, numSectors)
+	return
+}
+
+// Memory returns the memory allocated by this instruction beyond the end of its
+// lifetime.
+func (i *instructionHasSectors) Memory() uint64 {
+	return modules.MDMHasSectorsMemory()
+}
+
+// Execute executes the 'HasSectors' instruction.
+func (i *instructionHasSectors) Execute(prevOutput output) (output, types.Currency) {
+	// Fetch the operands.
+	numSectors, err := i.staticNumSectors()
+	if err != nil {
+		return errOutput(err), types.ZeroCurrency
+	}
+	sectorRoots := make([]crypto.Hash, numSectors)
+	for j := range sectorRoots {
+		sectorRoots[j], err = i.staticData.Hash(i.merkleRootsOffset + uint64(j)*crypto.HashSize)
+		if err != nil {
+			return errOutput(err), types.ZeroCurrency
+		}
+	}
+
+	// Fetch the requested information.
+	hasSectors, err := i.staticState.host.HasSectors(sectorRoots)
+	if err != nil {
+		return errOutput(err), types.ZeroCurrency
+	}
+
+	// Return the output.
+	out := make([]byte, len(hasSectors))
+	for j, hasSector := range hasSectors {
+		if hasSector {
+			out[j] = 1
+		}
+	}
+
+	return output{
+		NewSize:       prevOutput.NewSize,       // size stays the same
+		NewMerkleRoot: prevOutput.NewMerkleRoot, // root stays the same
+		Output:        out,
+	}, types.ZeroCurrency
+}
+
+// Time returns the execution time of an 'HasSectors' instruction.
+func (i *instructionHasSectors) Time() (uint64, error) {
+	numSectors, err := i.staticNumSectors()
+	if err != nil {
+		return 0, err
+	}
+	return modules.MDMHasSectorsTime(numSectors), nil
+}
+
+// staticNumSectors returns the number of sectors to look up. It returns an
+// error if the number exceeds the maximum batch size.
+func (i *instructionHasSectors) staticNumSectors() (uint64, error) {
+	numSectors, err := i.staticData.Uint64(i.numSectorsOffset)
+	if err != nil {
+		return 0, fmt.Errorf("bad input: numSectorsOffset: %v", err)
+	}
+	if numSectors > modules.MaxHasSectorsBatchSize {
+		return 0, modules.ErrHasSectorsBatchTooLarge
+	}
+	return numSectors, nil
+}
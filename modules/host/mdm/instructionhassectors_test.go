@@ -0,0 +1,103 @@
+package mdm
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestInstructionHasSectors tests executing a program with a single
+// HasSectorsInstruction.
+func TestInstructionHasSectors(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a program to check for sectors on the host.
+	so := host.newTestStorageObligation(true)
+	so.sectorRoots = randomSectorRoots(2)
+
+	// Add the sectors to the host.
+	for _, root := range so.sectorRoots {
+		_, err := host.ReadSector(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Build the program. The last root is not stored by the host.
+	roots := append(so.sectorRoots, randomSectorRoots(1)...)
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddHasSectorsInstruction(roots)
+
+	ics := so.ContractSize()
+	imr := so.MerkleRoot()
+
+	// Execute it.
+	outputs, err := mdm.ExecuteProgramWithBuilder(tb, so, duration, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Assert output.
+	err = outputs[0].assert(ics, imr, []crypto.Hash{}, []byte{1, 1, 0}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Looking up more than the max number of sectors at once should fail.
+	pb := modules.NewProgramBuilder(pt, duration)
+	pb.AddHasSectorsInstruction(make([]crypto.Hash, modules.MaxHasSectorsBatchSize+1))
+	program, programData := pb.Program()
+	cost, _, _ := pb.Cost(true)
+	budget := modules.NewBudget(cost)
+	_, outputChan, err := mdm.ExecuteProgram(context.Background(), pt, program, budget, types.ZeroCurrency, so, duration, uint64(len(programData)), bytes.NewReader(programData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lastOutput Output
+	for output := range outputChan {
+		lastOutput = output
+	}
+	if !errors.Contains(lastOutput.Error, modules.ErrHasSectorsBatchTooLarge) {
+		t.Fatal("expected ErrHasSectorsBatchTooLarge but got", lastOutput.Error)
+	}
+}
+
+// TestInstructionHasSectorsOutOfBounds tests that a HasSectors instruction with
+// a merkle roots offset beyond the end of the program data is rejected.
+func TestInstructionHasSectorsOutOfBounds(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Build a program and move the merkle roots offset out of bounds.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	pb := modules.NewProgramBuilder(pt, duration)
+	pb.AddHasSectorsInstruction(randomSectorRoots(1))
+	program, programData := pb.Program()
+	program[0] = modules.NewHasSectorsInstruction(0, uint64(len(programData))+1)
+	cost, _, _ := pb.Cost(true)
+	budget := modules.NewBudget(cost)
+
+	// Execute it.
+	so := host.newTestStorageObligation(true)
+	_, outputChan, err := mdm.ExecuteProgram(context.Background(), pt, program, budget, types.ZeroCurrency, so, duration, uint64(len(programData)), bytes.NewReader(programData))
+	if err == nil {
+		for range outputChan {
+		}
+		t.Fatal("program should be rejected when it is decoded")
+	}
+	if !errors.Contains(err, errProgramDataOutOfBounds) {
+		t.Fatal("expected errProgramDataOutOfBounds but got", err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
@@ -34,6 +35,14 @@ func (p *program) staticDecodeReadOffsetInstruction(instruction modules.Instruct
 	// Read args.
 	offsetOffset := binary.LittleEndian.Uint64(instruction.Args[0:8])
 	lengthOffset := binary.LittleEndian.Uint64(instruction.Args[8:16])
+	// Check that the operands are within the program data.
+	err := errors.Compose(
+		p.staticData.checkBounds(offsetOffset, 8),
+		p.staticData.checkBounds(lengthOffset, 8),
+	)
+	if err != nil {
+		return nil, err
+	}
 	return &instructionReadOffset{
 		commonInstruction: commonInstruction{
 			staticData:        p.staticData,
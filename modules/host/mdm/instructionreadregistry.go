@@ -6,6 +6,7 @@ import (
 
 	"gitlab.com/NebulousLabs/encoding"
 	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -44,6 +45,14 @@ func (p *program) staticDecodeReadRegistryInstruction(instruction modules.Instru
 	if len(instruction.Args) == modules.RPCIReadRegistryWithVersionLen {
 		iType = modules.ReadRegistryVersion(instruction.Args[24])
 	}
+	// Check that the operands are within the program data.
+	err := errors.Compose(
+		p.staticData.checkBounds(pubKeyOffset, pubKeyLength),
+		p.staticData.checkBounds(tweakOffset, crypto.HashSize),
+	)
+	if err != nil {
+		return nil, err
+	}
 	return &instructionReadRegistry{
 		commonInstruction: commonInstruction{
 			staticData:  p.staticData,
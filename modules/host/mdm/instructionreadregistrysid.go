@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -40,6 +41,10 @@ func (p *program) staticDecodeReadRegistryEIDInstruction(instruction modules.Ins
 	if len(instruction.Args) == modules.RPCIReadRegistryEIDWithVersionLen {
 		iType = modules.ReadRegistryVersion(instruction.Args[9])
 	}
+	// Check that the operands are within the program data.
+	if err := p.staticData.checkBounds(eidOffset, crypto.HashSize); err != nil {
+		return nil, err
+	}
 	return &instructionReadRegistryEID{
 		commonInstruction: commonInstruction{
 			staticData:  p.staticData,
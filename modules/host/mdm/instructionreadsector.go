@@ -1,6 +1,7 @@
 package mdm
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 
@@ -38,6 +39,15 @@ func (p *program) staticDecodeReadSectorInstruction(instruction modules.Instruct
 	offsetOffset := binary.LittleEndian.Uint64(instruction.Args[8:16])
 	lengthOffset := binary.LittleEndian.Uint64(instruction.Args[16:24])
 
+	// Check that the operands are within the program data.
+	err := errors.Compose(
+		p.staticData.checkBounds(rootOffset, crypto.HashSize),
+		p.staticData.checkBounds(offsetOffset, 8),
+		p.staticData.checkBounds(lengthOffset, 8),
+	)
+	if err != nil {
+		return nil, err
+	}
 	// Return instruction.
 	return &instructionReadSector{
 		commonInstruction: commonInstruction{
@@ -78,13 +88,17 @@ func executeReadSector(previousOutput output, ps *programState, length, offset u
 		return errOutput(err), nil
 	}
 	readData := sectorData[offset : offset+length]
+	ps.bandwidth.download += length
 
 	// Construct the Merkle proof, if requested.
 	var proof []crypto.Hash
 	if merkleProof {
 		proofStart := int(offset) / crypto.SegmentSize
 		proofEnd := int(offset+length) / crypto.SegmentSize
-		proof = crypto.MerkleRangeProof(sectorData, proofStart, proofEnd)
+		proof, err = crypto.MerkleRangeProofFromReader(bytes.NewReader(sectorData), proofStart, proofEnd)
+		if err != nil {
+			return errOutput(errors.AddContext(err, "failed to build Merkle proof")), nil
+		}
 	}
 
 	// Return the output.
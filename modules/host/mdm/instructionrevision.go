@@ -61,8 +61,12 @@ func (i *instructionRevision) Memory() uint64 {
 
 // Execute executes the 'Revision' instruction.
 func (i *instructionRevision) Execute(prevOutput output) (output, types.Currency) {
-	// Fetch the requested information.
+	// Fetch the requested information. It's only available if the program is
+	// associated with a contract.
 	revTxn := i.staticState.staticRevisionTxn
+	if len(revTxn.FileContractRevisions) == 0 || revTxn.FileContractRevisions[0].ParentID == (types.FileContractID{}) {
+		return errOutput(modules.ErrMDMMissingContract), types.ZeroCurrency
+	}
 
 	return output{
 		NewSize:       prevOutput.NewSize,       // size stays the same
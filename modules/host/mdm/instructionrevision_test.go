@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
 
 	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/crypto"
@@ -65,6 +66,12 @@ func TestInstructionRevision(t *testing.T) {
 		t.Fatal(err)
 	}
 	revisionTxn := response.RevisionTxn
+	if len(revisionTxn.FileContractRevisions) != 1 {
+		t.Fatal("expected a single revision", len(revisionTxn.FileContractRevisions))
+	}
+	if decodedRev := revisionTxn.FileContractRevisions[0]; decodedRev.ParentID != so.fcid || decodedRev.NewFileSize != ics || decodedRev.NewFileMerkleRoot != imr {
+		t.Fatal("output doesn't contain the expected revision", decodedRev)
+	}
 	var signature crypto.Signature
 	copy(signature[:], revisionTxn.RenterSignature().Signature)
 	hash := revisionTxn.SigHash(0, host.BlockHeight()) // this should be the start height but this works too
@@ -73,3 +80,33 @@ func TestInstructionRevision(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestInstructionRevisionMissingContract tests that executing a Revision
+// instruction fails if the program isn't associated with a contract.
+func TestInstructionRevisionMissingContract(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a storage obligation without a contract.
+	so := host.newTestStorageObligation(true)
+	so.fcid = types.FileContractID{}
+
+	// Build the program.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddRevisionInstruction()
+
+	// Execute it.
+	outputs, _, err := mdm.ExecuteProgramWithBuilderCustomBudget(tb, so, duration, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 1 || !errors.Contains(outputs[0].Error, modules.ErrMDMMissingContract) {
+		t.Fatal("expected ErrMDMMissingContract", outputs)
+	}
+	if len(outputs[0].Output) != 0 {
+		t.Fatal("output should be empty")
+	}
+}
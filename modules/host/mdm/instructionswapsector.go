@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
@@ -35,6 +36,14 @@ func (p *program) staticDecodeSwapSectorInstruction(instruction modules.Instruct
 	// Read args.
 	sector1Offset := binary.LittleEndian.Uint64(instruction.Args[:8])
 	sector2Offset := binary.LittleEndian.Uint64(instruction.Args[8:16])
+	// Check that the operands are within the program data.
+	err := errors.Compose(
+		p.staticData.checkBounds(sector1Offset, 8),
+		p.staticData.checkBounds(sector2Offset, 8),
+	)
+	if err != nil {
+		return nil, err
+	}
 	return &instructionSwapSector{
 		commonInstruction: commonInstruction{
 			staticData:        p.staticData,
@@ -4,6 +4,8 @@ import (
 	"encoding/binary"
 	"fmt"
 
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -52,6 +54,17 @@ func (p *program) staticDecodeUpdateRegistryInstruction(instruction modules.Inst
 		returnType = true
 		entryType = modules.RegistryEntryType(instruction.Args[56])
 	}
+	// Check that the operands are within the program data.
+	err := errors.Compose(
+		p.staticData.checkBounds(tweakOffset, crypto.HashSize),
+		p.staticData.checkBounds(revisionOffset, 8),
+		p.staticData.checkBounds(signatureOffset, crypto.SignatureSize),
+		p.staticData.checkBounds(pubKeyOffset, pubKeyLength),
+		p.staticData.checkBounds(dataOffset, dataLength),
+	)
+	if err != nil {
+		return nil, err
+	}
 	return &instructionUpdateRegistry{
 		commonInstruction: commonInstruction{
 			staticData:  p.staticData,
@@ -0,0 +1,135 @@
+package mdm
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// instructionUpdateSector is an instruction that replaces the data of an
+// existing sector of a file contract.
+type instructionUpdateSector struct {
+	commonInstruction
+
+	rootOffset uint64
+	dataOffset uint64
+}
+
+// staticDecodeUpdateSectorInstruction creates a new 'UpdateSector' instruction
+// from the provided generic instruction.
+func (p *program) staticDecodeUpdateSectorInstruction(instruction modules.Instruction) (instruction, error) {
+	// Check specifier.
+	if instruction.Specifier != modules.SpecifierUpdateSector {
+		return nil, fmt.Errorf("expected specifier %v but got %v",
+			modules.SpecifierUpdateSector, instruction.Specifier)
+	}
+	// Check args.
+	if len(instruction.Args) != modules.RPCIUpdateSectorLen {
+		return nil, fmt.Errorf("expected instruction to have len %v but was %v",
+			modules.RPCIUpdateSectorLen, len(instruction.Args))
+	}
+	// Read args.
+	rootOffset := binary.LittleEndian.Uint64(instruction.Args[:8])
+	dataOffset := binary.LittleEndian.Uint64(instruction.Args[8:16])
+	// Check that the operands are within the program data.
+	err := errors.Compose(
+		p.staticData.checkBounds(rootOffset, crypto.HashSize),
+		p.staticData.checkBounds(dataOffset, modules.SectorSize),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &instructionUpdateSector{
+		commonInstruction: commonInstruction{
+			staticData:        p.staticData,
+			staticMerkleProof: instruction.Args[16] == 1,
+			staticState:       p.staticProgramState,
+		},
+		rootOffset: rootOffset,
+		dataOffset: dataOffset,
+	}, nil
+}
+
+// Batch declares whether or not this instruction can be batched together with
+// the previous instruction.
+func (i instructionUpdateSector) Batch() bool {
+	return false
+}
+
+// Execute executes the 'UpdateSector' instruction.
+func (i *instructionUpdateSector) Execute(prevOutput output) (output, types.Currency) {
+	// Fetch the data.
+	root, err := i.staticData.Hash(i.rootOffset)
+	if err != nil {
+		return errOutput(err), types.ZeroCurrency
+	}
+	sectorData, err := i.staticData.Bytes(i.dataOffset, modules.SectorSize)
+	if err != nil {
+		return errOutput(err), types.ZeroCurrency
+	}
+
+	ps := i.staticState
+	idx, exists := ps.sectors.sectorIndex(root)
+	if !exists {
+		return errOutput(fmt.Errorf("sector %v doesn't exist", root)), types.ZeroCurrency
+	}
+	newMerkleRoot, err := ps.sectors.updateSector(root, sectorData)
+	if err != nil {
+		return errOutput(err), types.ZeroCurrency
+	}
+	ps.bandwidth.upload += modules.SectorSize
+
+	// If no proof was requested we are done.
+	if !i.staticMerkleProof {
+		return output{
+			NewSize:       prevOutput.NewSize,
+			NewMerkleRoot: newMerkleRoot,
+		}, types.ZeroCurrency
+	}
+
+	// Create the proof and return the old leaf hash of the updated sector as
+	// the data. The renter needs it to verify the proof against the old
+	// contract merkle root and will then verify the new root using the hash
+	// of the new data.
+	ranges := []crypto.ProofRange{{
+		Start: idx,
+		End:   idx + 1,
+	}}
+	newRoots := ps.sectors.merkleRoots
+	proof := crypto.MerkleDiffProof(ranges, uint64(len(newRoots)), nil, newRoots)
+	data := encoding.Marshal([]crypto.Hash{root})
+
+	return output{
+		NewSize:       prevOutput.NewSize,
+		NewMerkleRoot: newMerkleRoot,
+		Output:        data,
+		Proof:         proof,
+	}, types.ZeroCurrency
+}
+
+// Collateral returns the collateral cost of updating a sector.
+func (i *instructionUpdateSector) Collateral() types.Currency {
+	return modules.MDMUpdateSectorCollateral()
+}
+
+// Cost returns the Cost of this `UpdateSector` instruction.
+func (i *instructionUpdateSector) Cost() (executionCost, storage types.Currency, err error) {
+	executionCost = modules.MDMUpdateSectorCost(i.staticState.priceTable)
+	return
+}
+
+// Memory returns the memory allocated by the 'UpdateSector' instruction beyond
+// the lifetime of the instruction.
+func (i *instructionUpdateSector) Memory() uint64 {
+	return modules.MDMUpdateSectorMemory()
+}
+
+// Time returns the execution time of an 'UpdateSector' instruction.
+func (i *instructionUpdateSector) Time() (uint64, error) {
+	return modules.MDMTimeUpdateSector, nil
+}
@@ -0,0 +1,169 @@
+package mdm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestInstructionUpdateSector tests executing a program with a single
+// UpdateSector instruction.
+func TestInstructionUpdateSector(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a storage obligation with some random sectors.
+	numSectors := 10
+	so := host.newTestStorageObligation(true)
+	so.AddRandomSectors(numSectors)
+
+	// Prepare a priceTable and duration.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5)) // random since it doesn't matter for update
+
+	// Run basic case.
+	t.Run("Basic", func(t *testing.T) {
+		testInstructionUpdateSectorBasic(t, mdm, uint64(numSectors), pt, duration, so)
+	})
+	// Run case for appending and updating a sector within the same program.
+	t.Run("AppendAndUpdate", func(t *testing.T) {
+		testInstructionUpdateSectorAppended(t, mdm, pt, duration, so)
+	})
+	// Run case for updating a sector that doesn't exist.
+	t.Run("NotFound", func(t *testing.T) {
+		testInstructionUpdateSectorNotFound(t, mdm, pt, duration, so)
+	})
+}
+
+// testInstructionUpdateSectorBasic tests updating a random sector of a
+// filecontract and verifying the returned proof.
+func testInstructionUpdateSectorBasic(t *testing.T, mdm *MDM, numSectors uint64, pt *modules.RPCPriceTable, duration types.BlockHeight, so *TestStorageObligation) {
+	// Choose a random sector to update.
+	i := fastrand.Uint64n(numSectors)
+
+	ics := so.ContractSize()
+	imr := so.MerkleRoot()
+	oldRoots := append([]crypto.Hash{}, so.sectorRoots...)
+	newData := randomSectorData()
+	newRoot := crypto.MerkleRoot(newData)
+
+	// Use a builder to build the program.
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddUpdateSectorInstruction(oldRoots[i], newData, true)
+
+	// Execute it.
+	outputs, err := mdm.ExecuteProgramWithBuilder(tb, so, duration, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	output := outputs[0]
+
+	// Compute the expected proof.
+	ranges := []crypto.ProofRange{{
+		Start: i,
+		End:   i + 1,
+	}}
+	expectedProof := crypto.MerkleDiffProof(ranges, uint64(len(oldRoots)), nil, oldRoots)
+	expectedOutput := encoding.Marshal([]crypto.Hash{oldRoots[i]})
+
+	// Compute the expected new root.
+	newRoots := append([]crypto.Hash{}, oldRoots...)
+	newRoots[i] = newRoot
+	nmr := cachedMerkleRoot(newRoots)
+
+	// Assert the output.
+	err = output.assert(ics, nmr, expectedProof, expectedOutput, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify the proof, first by verifying the old merkle root.
+	var leafHashes []crypto.Hash
+	err = encoding.Unmarshal(output.Output, &leafHashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ok := crypto.VerifyDiffProof(ranges, uint64(len(oldRoots)), output.Proof, leafHashes, imr)
+	if !ok {
+		t.Fatal("failed to verify proof")
+	}
+
+	// ... then by replacing the leaf and verifying the new merkle root.
+	leafHashes[0] = newRoot
+	ok = crypto.VerifyDiffProof(ranges, uint64(len(oldRoots)), output.Proof, leafHashes, nmr)
+	if !ok {
+		t.Fatal("failed to verify proof")
+	}
+
+	// Make sure the sector was updated on the host.
+	if so.sectorRoots[i] != newRoot {
+		t.Fatal("sector wasn't updated")
+	}
+	if _, exists := so.sectorMap[oldRoots[i]]; exists {
+		t.Fatal("old sector wasn't removed")
+	}
+	if data, exists := so.sectorMap[newRoot]; !exists || !bytes.Equal(data, newData) {
+		t.Fatal("new sector wasn't added")
+	}
+}
+
+// testInstructionUpdateSectorAppended tests appending a sector and updating it
+// within the same program. The appended sector should never reach the host.
+func testInstructionUpdateSectorAppended(t *testing.T, mdm *MDM, pt *modules.RPCPriceTable, duration types.BlockHeight, so *TestStorageObligation) {
+	appendedData := randomSectorData()
+	appendedRoot := crypto.MerkleRoot(appendedData)
+	newData := randomSectorData()
+	newRoot := crypto.MerkleRoot(newData)
+	ics := so.ContractSize()
+	numSectorsOnHost := len(so.sectorMap)
+
+	// Use a builder to build the program.
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddAppendInstruction(appendedData, false)
+	tb.AddUpdateSectorInstruction(appendedRoot, newData, false)
+
+	// Execute it.
+	outputs, err := mdm.ExecuteProgramWithBuilder(tb, so, duration, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedRoots := append([]crypto.Hash{}, so.sectorRoots...)
+	err = outputs[1].assert(ics+modules.SectorSize, cachedMerkleRoot(expectedRoots), []crypto.Hash{}, []byte{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The host should only have gained the updated sector.
+	if len(so.sectorMap) != numSectorsOnHost+1 {
+		t.Fatalf("expected %v sectors on host but got %v", numSectorsOnHost+1, len(so.sectorMap))
+	}
+	if _, exists := so.sectorMap[appendedRoot]; exists {
+		t.Fatal("appended sector shouldn't be on host")
+	}
+	if so.sectorRoots[len(so.sectorRoots)-1] != newRoot {
+		t.Fatal("sector wasn't updated")
+	}
+}
+
+// testInstructionUpdateSectorNotFound tests that updating a sector which isn't
+// part of the filecontract causes the execution to fail.
+func testInstructionUpdateSectorNotFound(t *testing.T, mdm *MDM, pt *modules.RPCPriceTable, duration types.BlockHeight, so *TestStorageObligation) {
+	root := randomSector()
+
+	// Use a builder to build the program.
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddUpdateSectorInstruction(root, randomSectorData(), true)
+
+	// Execute it.
+	_, err := mdm.ExecuteProgramWithBuilder(tb, so, duration, true)
+	if err == nil || !strings.Contains(err.Error(), "doesn't exist") {
+		t.Fatal("expected execution to fail", err)
+	}
+}
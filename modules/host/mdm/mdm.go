@@ -1,6 +1,8 @@
 package mdm
 
 import (
+	"sync"
+
 	"gitlab.com/NebulousLabs/threadgroup"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
@@ -34,6 +36,7 @@ type StorageObligationSnapshot interface {
 type Host interface {
 	BlockHeight() types.BlockHeight
 	HasSector(crypto.Hash) bool
+	HasSectors([]crypto.Hash) ([]bool, error)
 	ReadSector(sectorRoot crypto.Hash) ([]byte, error)
 	RegistryUpdate(rv modules.SignedRegistryValue, pubKey types.SiaPublicKey, expiry types.BlockHeight) (modules.SignedRegistryValue, error)
 	RegistryGet(sid modules.RegistryEntryID) (types.SiaPublicKey, modules.SignedRegistryValue, bool)
@@ -50,12 +53,21 @@ type Host interface {
 type MDM struct {
 	host Host
 	tg   threadgroup.ThreadGroup
+
+	// activePrograms contains the programs which are currently executed.
+	// Programs register themselves when their execution starts and deregister
+	// when they are done.
+	activePrograms map[uint64]modules.MDMProgramInfo
+	nextProgramID  uint64
+	stats          modules.MDMStats
+	mu             sync.Mutex
 }
 
 // New creates a new MDM.
 func New(h Host) *MDM {
 	return &MDM{
-		host: h,
+		host:           h,
+		activePrograms: make(map[uint64]modules.MDMProgramInfo),
 	}
 }
 
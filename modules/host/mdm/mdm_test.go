@@ -41,7 +41,8 @@ type (
 		sectorRoots []crypto.Hash
 
 		// contract related fields.
-		sk crypto.SecretKey
+		fcid types.FileContractID
+		sk   crypto.SecretKey
 	}
 )
 
@@ -59,11 +60,13 @@ func newCustomTestHost(generateSectors bool) *TestHost {
 
 func (h *TestHost) newTestStorageObligation(locked bool) *TestStorageObligation {
 	sk, _ := crypto.GenerateKeyPair()
-	return &TestStorageObligation{
+	so := &TestStorageObligation{
 		host:      h,
 		sectorMap: make(map[crypto.Hash][]byte),
 		sk:        sk,
 	}
+	fastrand.Read(so.fcid[:])
+	return so
 }
 
 // BlockHeight returns an incremented blockheight.
@@ -83,6 +86,18 @@ func (h *TestHost) HasSector(sectorRoot crypto.Hash) bool {
 	return exists
 }
 
+// HasSectors indicates for every root whether the host stores a sector with
+// that root.
+func (h *TestHost) HasSectors(sectorRoots []crypto.Hash) ([]bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hasSectors := make([]bool, len(sectorRoots))
+	for i, root := range sectorRoots {
+		_, hasSectors[i] = h.sectors[root]
+	}
+	return hasSectors, nil
+}
+
 // RegistryGet retrieves a value from the registry.
 func (h *TestHost) RegistryGet(sid modules.RegistryEntryID) (types.SiaPublicKey, modules.SignedRegistryValue, bool) {
 	h.mu.Lock()
@@ -138,6 +153,7 @@ func (so *TestStorageObligation) AddRandomSector() {
 	data := fastrand.Bytes(int(modules.SectorSize))
 	root := crypto.MerkleRoot(data)
 	so.host.sectors[root] = data
+	so.sectorMap[root] = data
 	so.sectorRoots = append(so.sectorRoots, root)
 }
 
@@ -165,6 +181,7 @@ func (so *TestStorageObligation) MerkleRoot() crypto.Hash {
 // RecentRevision implements the StorageObligation interface.
 func (so *TestStorageObligation) RecentRevision() types.FileContractRevision {
 	return types.FileContractRevision{
+		ParentID:          so.fcid,
 		NewFileMerkleRoot: so.MerkleRoot(),
 		NewFileSize:       so.ContractSize(),
 	}
@@ -179,7 +196,7 @@ func (so *TestStorageObligation) RevisionTxn() types.Transaction {
 		},
 		TransactionSignatures: []types.TransactionSignature{
 			{
-				ParentID:       crypto.Hash(types.FileContractID{}),
+				ParentID:       crypto.Hash(so.fcid),
 				PublicKeyIndex: 0,
 				CoveredFields: types.CoveredFields{
 					FileContractRevisions: []uint64{0},
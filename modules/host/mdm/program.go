@@ -27,6 +27,14 @@ var (
 // the changes made by the program.
 type FnFinalize func(StorageObligation) error
 
+// bandwidthConsumed is the bandwidth consumed by the instructions of a program.
+// It is tracked separately from the program's execution cost to allow for
+// billing bandwidth separately from storage operations.
+type bandwidthConsumed struct {
+	upload   uint64
+	download uint64
+}
+
 // programState contains some fields needed for the execution of instructions.
 // The program's state is captured when the program is created and remains the
 // same during the execution of the program.
@@ -44,6 +52,9 @@ type programState struct {
 	riskedCollateral        types.Currency
 	potentialUploadRevenue  types.Currency
 
+	// bandwidth consumed by the executed instructions
+	bandwidth bandwidthConsumed
+
 	// budget related fields
 	priceTable *modules.RPCPriceTable
 }
@@ -63,23 +74,31 @@ type program struct {
 	additionalCollateral   types.Currency // collateral the host is required to add
 	failureRefund          types.Currency // This is refunded if the program doesn't commit.
 	usedMemory             uint64
+	executedInstructions   uint64
 
 	outputChan chan Output
 	outputErr  error // contains the error of the first instruction of the program that failed
 
+	// staticDryRun indicates that the program's changes should not be
+	// committed to the storage obligation when it is finalized.
+	staticDryRun bool
+
 	tg *threadgroup.ThreadGroup
 }
 
-// outputFromError is a convenience function to wrap an error in an Output.
-func outputFromError(err error, collateral, cost, refund types.Currency) Output {
+// outputFromError is a convenience function to wrap an error in an Output
+// containing the program's running values.
+func (p *program) outputFromError(err error) Output {
 	return Output{
 		output: output{
 			Error: err,
 		},
 
-		ExecutionCost:        cost,
-		AdditionalCollateral: collateral,
-		FailureRefund:        refund,
+		ExecutionCost:        p.executionCost,
+		AdditionalCollateral: p.additionalCollateral,
+		FailureRefund:        p.failureRefund,
+		UploadBandwidth:      p.staticProgramState.bandwidth.upload,
+		DownloadBandwidth:    p.staticProgramState.bandwidth.download,
 	}
 }
 
@@ -93,6 +112,8 @@ func decodeInstruction(p *program, i modules.Instruction) (instruction, error) {
 		return p.staticDecodeDropSectorsInstruction(i)
 	case modules.SpecifierHasSector:
 		return p.staticDecodeHasSectorInstruction(i)
+	case modules.SpecifierHasSectors:
+		return p.staticDecodeHasSectorsInstruction(i)
 	case modules.SpecifierReadSector:
 		return p.staticDecodeReadSectorInstruction(i)
 	case modules.SpecifierReadOffset:
@@ -101,6 +122,8 @@ func decodeInstruction(p *program, i modules.Instruction) (instruction, error) {
 		return p.staticDecodeRevisionInstruction(i)
 	case modules.SpecifierSwapSector:
 		return p.staticDecodeSwapSectorInstruction(i)
+	case modules.SpecifierUpdateSector:
+		return p.staticDecodeUpdateSectorInstruction(i)
 	case modules.SpecifierUpdateRegistry:
 		return p.staticDecodeUpdateRegistryInstruction(i)
 	case modules.SpecifierReadRegistry:
@@ -114,11 +137,27 @@ func decodeInstruction(p *program, i modules.Instruction) (instruction, error) {
 
 // ExecuteProgram initializes a new program from a set of instructions and a
 // reader which can be used to fetch the program's data and executes it.
-func (mdm *MDM) ExecuteProgram(ctx context.Context, pt *modules.RPCPriceTable, p modules.Program, budget *modules.RPCBudget, collateralBudget types.Currency, sos StorageObligationSnapshot, duration types.BlockHeight, programDataLen uint64, data io.Reader) (_ FnFinalize, _ <-chan Output, err error) {
+func (mdm *MDM) ExecuteProgram(ctx context.Context, pt *modules.RPCPriceTable, p modules.Program, budget *modules.RPCBudget, collateralBudget types.Currency, sos StorageObligationSnapshot, duration types.BlockHeight, programDataLen uint64, data io.Reader) (FnFinalize, <-chan Output, error) {
+	return mdm.managedExecuteProgram(ctx, pt, p, budget, collateralBudget, sos, duration, programDataLen, data, false)
+}
+
+// ExecuteProgramDryRun works like ExecuteProgram but the returned finalize
+// function won't commit the changes of the program to the storage obligation.
+// This allows for verifying a program and computing its final merkle root
+// without modifying the contract.
+func (mdm *MDM) ExecuteProgramDryRun(ctx context.Context, pt *modules.RPCPriceTable, p modules.Program, budget *modules.RPCBudget, collateralBudget types.Currency, sos StorageObligationSnapshot, duration types.BlockHeight, programDataLen uint64, data io.Reader) (FnFinalize, <-chan Output, error) {
+	return mdm.managedExecuteProgram(ctx, pt, p, budget, collateralBudget, sos, duration, programDataLen, data, true)
+}
+
+// managedExecuteProgram initializes and executes a new program. If dryRun is
+// set, finalizing the program won't update the storage obligation.
+func (mdm *MDM) managedExecuteProgram(ctx context.Context, pt *modules.RPCPriceTable, p modules.Program, budget *modules.RPCBudget, collateralBudget types.Currency, sos StorageObligationSnapshot, duration types.BlockHeight, programDataLen uint64, data io.Reader, dryRun bool) (_ FnFinalize, _ <-chan Output, err error) {
 	// Sanity check program length.
 	if len(p) == 0 {
 		return nil, nil, ErrEmptyProgram
 	}
+	// Remember the initial budget for the program's stats.
+	initialBudget := budget.Remaining()
 	// Derive a new context to use and close it on error.
 	ctx, cancel := context.WithCancel(ctx)
 	defer func() {
@@ -140,6 +179,7 @@ func (mdm *MDM) ExecuteProgram(ctx context.Context, pt *modules.RPCPriceTable, p
 		usedMemory:             modules.MDMInitMemory(),
 		staticCollateralBudget: collateralBudget,
 		staticData:             openProgramData(data, programDataLen),
+		staticDryRun:           dryRun,
 		tg:                     &mdm.tg,
 	}
 	// Convert the instructions.
@@ -159,6 +199,7 @@ func (mdm *MDM) ExecuteProgram(ctx context.Context, pt *modules.RPCPriceTable, p
 	if err := program.tg.Add(); err != nil {
 		return nil, nil, errors.Compose(err, program.staticData.Close())
 	}
+	id := mdm.managedRegisterProgram(uint64(len(program.instructions)), initialBudget, p.ReadOnly(), sos.RecentRevision().ParentID)
 	go func() {
 		defer cancel()
 		defer func() {
@@ -172,6 +213,17 @@ func (mdm *MDM) ExecuteProgram(ctx context.Context, pt *modules.RPCPriceTable, p
 		}()
 		defer program.tg.Done()
 		defer close(program.outputChan)
+		// Deregister the program before closing the output channel to make
+		// sure the stats are up-to-date once the caller is done reading the
+		// outputs.
+		defer mdm.managedDeregisterProgram(id, program)
+		// If the program was interrupted, the sectors it gained will never be
+		// committed. Release their data before the program is deregistered.
+		defer func() {
+			if errors.Contains(program.outputErr, ErrInterrupted) {
+				program.staticProgramState.sectors.releaseGained()
+			}
+		}()
 		program.outputErr = program.executeInstructions(ctx, sos.ContractSize(), sos.MerkleRoot())
 	}()
 	// If the program is readonly there is no need to finalize it.
@@ -230,15 +282,16 @@ func (p *program) executeInstructions(ctx context.Context, fcSize uint64, fcRoot
 	for idx, i := range p.instructions {
 		select {
 		case <-ctx.Done(): // Check for interrupt
-			p.outputChan <- outputFromError(ErrInterrupted, p.additionalCollateral, p.executionCost, p.failureRefund)
-			return ErrInterrupted
+			err := errors.Compose(ErrInterrupted, ctx.Err())
+			p.outputChan <- p.outputFromError(err)
+			return err
 		default:
 		}
 		// Increment collateral first.
 		collateral := i.Collateral()
 		err := p.addCollateral(collateral)
 		if err != nil {
-			p.outputChan <- outputFromError(err, p.additionalCollateral, p.executionCost, p.failureRefund)
+			p.outputChan <- p.outputFromError(err)
 			return err
 		}
 		// Add the memory the next instruction is going to allocate to the
@@ -246,20 +299,20 @@ func (p *program) executeInstructions(ctx context.Context, fcSize uint64, fcRoot
 		p.usedMemory += i.Memory()
 		time, err := i.Time()
 		if err != nil {
-			p.outputChan <- outputFromError(err, p.additionalCollateral, p.executionCost, p.failureRefund)
+			p.outputChan <- p.outputFromError(err)
 		}
 		memoryCost := modules.MDMMemoryCost(p.staticProgramState.priceTable, p.usedMemory, time)
 		// Get the instruction cost and storageCost.
 		instructionCost, failureRefund, err := i.Cost()
 		if err != nil {
-			p.outputChan <- outputFromError(err, p.additionalCollateral, p.executionCost, p.failureRefund)
+			p.outputChan <- p.outputFromError(err)
 			return err
 		}
 		cost := memoryCost.Add(instructionCost)
 		// Increment the cost.
 		err = p.addCost(cost)
 		if err != nil {
-			p.outputChan <- outputFromError(err, p.additionalCollateral, p.executionCost, p.failureRefund)
+			p.outputChan <- p.outputFromError(err)
 			return err
 		}
 		// Add the instruction's potential refund to the total.
@@ -270,6 +323,7 @@ func (p *program) executeInstructions(ctx context.Context, fcSize uint64, fcRoot
 		batch := idx < len(p.instructions)-1 && p.instructions[idx+1].Batch()
 		// Execute next instruction.
 		output, refund = i.Execute(output)
+		p.executedInstructions++
 		// Issue potential refund.
 		if !refund.IsZero() {
 			p.refundCost(refund)
@@ -280,6 +334,8 @@ func (p *program) executeInstructions(ctx context.Context, fcSize uint64, fcRoot
 			ExecutionCost:        p.executionCost,
 			AdditionalCollateral: p.additionalCollateral,
 			FailureRefund:        p.failureRefund,
+			UploadBandwidth:      p.staticProgramState.bandwidth.upload,
+			DownloadBandwidth:    p.staticProgramState.bandwidth.download,
 		}
 		// Abort if the last output contained an error.
 		if output.Error != nil {
@@ -289,6 +345,12 @@ func (p *program) executeInstructions(ctx context.Context, fcSize uint64, fcRoot
 	return nil
 }
 
+// DryRun returns whether the program is executed as a dry run, in which case
+// its changes are not committed when it is finalized.
+func (p *program) DryRun() bool {
+	return p.staticDryRun
+}
+
 // managedFinalize commits the changes made by the program to disk. It should
 // only be called after the channel returned by Execute is closed.
 func (p *program) managedFinalize(so StorageObligation) error {
@@ -302,6 +364,10 @@ func (p *program) managedFinalize(so StorageObligation) error {
 	if err != nil {
 		return err
 	}
+	// A dry run doesn't commit any changes.
+	if p.DryRun() {
+		return nil
+	}
 	// Commit the changes to the storage obligation.
 	s := p.staticProgramState.sectors
 	err = so.Update(s.merkleRoots, s.sectorsRemoved, s.sectorsGained)
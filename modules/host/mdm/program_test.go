@@ -3,6 +3,7 @@ package mdm
 import (
 	"bytes"
 	"context"
+	"reflect"
 	"testing"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -133,3 +134,207 @@ func TestNewProgramLowCollateralBudget(t *testing.T) {
 		t.Fatal("shouldn't be able to finalize program")
 	}
 }
+
+// TestProgramBandwidth checks that the bandwidth consumed by the instructions
+// of a program is accumulated in the outputs.
+func TestProgramBandwidth(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a program which appends two sectors, reads from them and updates
+	// one of them.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	data1, data2, data3 := randomSectorData(), randomSectorData(), randomSectorData()
+	tb.AddAppendInstruction(data1, false)
+	tb.AddAppendInstruction(data2, false)
+	tb.AddReadSectorInstruction(crypto.SegmentSize, 0, crypto.MerkleRoot(data1), false)
+	tb.AddReadOffsetInstruction(2*crypto.SegmentSize, modules.SectorSize, false)
+	tb.AddUpdateSectorInstruction(crypto.MerkleRoot(data2), data3, false)
+
+	// Execute it.
+	so := host.newTestStorageObligation(true)
+	outputs, err := mdm.ExecuteProgramWithBuilder(tb, so, duration, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Check the cumulative bandwidth of the outputs.
+	expected := []struct {
+		up, down uint64
+	}{
+		{modules.SectorSize, 0},
+		{2 * modules.SectorSize, 0},
+		{2 * modules.SectorSize, crypto.SegmentSize},
+		{2 * modules.SectorSize, 3 * crypto.SegmentSize},
+		{3 * modules.SectorSize, 3 * crypto.SegmentSize},
+	}
+	if len(outputs) != len(expected) {
+		t.Fatalf("expected %v outputs but got %v", len(expected), len(outputs))
+	}
+	for i, output := range outputs {
+		if output.UploadBandwidth != expected[i].up || output.DownloadBandwidth != expected[i].down {
+			t.Fatalf("%v: expected bandwidth %v/%v but got %v/%v", i, expected[i].up, expected[i].down, output.UploadBandwidth, output.DownloadBandwidth)
+		}
+	}
+}
+
+// TestProgramInterrupted checks that a program which is interrupted after its
+// first instruction sends a final output with the context's error before
+// closing the output channel.
+func TestProgramInterrupted(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a program which appends a few sectors.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	numInstructions := 5
+	for i := 0; i < numInstructions; i++ {
+		tb.AddAppendInstruction(randomSectorData(), false)
+	}
+	program, data := tb.Program()
+	values := tb.Cost()
+	_, _, collateral, _ := values.Cost()
+	budget := values.Budget(true)
+
+	// Execute it.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	so := host.newTestStorageObligation(true)
+	finalize, outputs, err := mdm.ExecuteProgram(ctx, pt, program, budget, collateral, so, duration, uint64(len(data)), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancel the context after the first instruction.
+	output := <-outputs
+	if output.Error != nil {
+		t.Fatal(output.Error)
+	}
+	cancel()
+
+	// Drain the outputs. The channel should be closed after an output with
+	// the context's error.
+	numOutputs := 1
+	var lastErr error
+	for output := range outputs {
+		numOutputs++
+		lastErr = output.Error
+	}
+	if numOutputs > numInstructions {
+		t.Fatalf("expected the program to be interrupted but got %v outputs", numOutputs)
+	}
+	if !errors.Contains(lastErr, ErrInterrupted) || !errors.Contains(lastErr, context.Canceled) {
+		t.Fatal("expected interruption error but got", lastErr)
+	}
+
+	// The program shouldn't be finalizable.
+	if err := finalize(so); err == nil {
+		t.Fatal("finalizing an interrupted program should fail")
+	}
+	if len(so.sectorRoots) != 0 {
+		t.Fatal("interrupted program shouldn't have added sectors", len(so.sectorRoots))
+	}
+}
+
+// TestProgramDataOutOfBounds tests that a program with an instruction that
+// references data beyond the end of the program data is rejected.
+func TestProgramDataOutOfBounds(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a program with an append instruction and truncate its data.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	tb.AddAppendInstruction(randomSectorData(), false)
+	program, data := tb.Program()
+	values := tb.Cost()
+	_, _, collateral, _ := values.Cost()
+	budget := values.Budget(true)
+	data = data[:len(data)-1]
+
+	// Execute it.
+	so := host.newTestStorageObligation(true)
+	_, _, err := mdm.ExecuteProgram(context.Background(), pt, program, budget, collateral, so, duration, uint64(len(data)), bytes.NewReader(data))
+	if !errors.Contains(err, errProgramDataOutOfBounds) {
+		t.Fatal("expected errProgramDataOutOfBounds but got", err)
+	}
+}
+
+// updateCountingObligation is a storage obligation which counts the calls to
+// Update.
+type updateCountingObligation struct {
+	*TestStorageObligation
+	updates int
+}
+
+// Update implements the StorageObligation interface.
+func (so *updateCountingObligation) Update(sectorRoots []crypto.Hash, sectorsRemoved map[crypto.Hash]struct{}, sectorsGained map[crypto.Hash][]byte) error {
+	so.updates++
+	return so.TestStorageObligation.Update(sectorRoots, sectorsRemoved, sectorsGained)
+}
+
+// TestProgramDryRun tests that a program executed as a dry run produces the
+// same outputs as a regular program without updating the storage obligation.
+func TestProgramDryRun(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+
+	// Create a program which appends a few sectors.
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(fastrand.Uint64n(5))
+	tb := newTestProgramBuilder(pt, duration)
+	sectors := [][]byte{randomSectorData(), randomSectorData()}
+	for _, sector := range sectors {
+		tb.AddAppendInstruction(sector, false)
+	}
+	program, data := tb.Program()
+	values := tb.Cost()
+	_, _, collateral, _ := values.Cost()
+	budget := values.Budget(true)
+
+	// Execute it as a dry run.
+	so := &updateCountingObligation{TestStorageObligation: host.newTestStorageObligation(true)}
+	so.AddRandomSector()
+	initialRoots := append([]crypto.Hash{}, so.sectorRoots...)
+	finalize, outputs, err := mdm.ExecuteProgramDryRun(context.Background(), pt, program, budget, collateral, so, duration, uint64(len(data)), bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var lastOutput Output
+	for output := range outputs {
+		if output.Error != nil {
+			t.Fatal(output.Error)
+		}
+		lastOutput = output
+	}
+	if err := finalize(so); err != nil {
+		t.Fatal(err)
+	}
+
+	// The final output should contain the merkle root of the contract with
+	// the appended sectors.
+	expectedRoots := append(append([]crypto.Hash{}, initialRoots...), crypto.MerkleRoot(sectors[0]), crypto.MerkleRoot(sectors[1]))
+	if lastOutput.NewMerkleRoot != cachedMerkleRoot(expectedRoots) {
+		t.Fatal("wrong merkle root")
+	}
+	if lastOutput.NewSize != uint64(len(expectedRoots))*modules.SectorSize {
+		t.Fatal("wrong size", lastOutput.NewSize)
+	}
+
+	// The storage obligation should be unchanged.
+	if so.updates != 0 {
+		t.Fatal("Update shouldn't be called on a dry run", so.updates)
+	}
+	if !reflect.DeepEqual(so.sectorRoots, initialRoots) {
+		t.Fatal("sector roots were modified by dry run")
+	}
+}
@@ -2,19 +2,40 @@ package mdm
 
 import (
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"sort"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/encoding"
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
 
+var (
+	// ErrMissingProgramData is returned if the renter failed to send the
+	// program data required by an instruction, either because the stream of
+	// program data ended early or because no data arrived within
+	// programDataTimeout.
+	ErrMissingProgramData = errors.New("renter failed to send program data")
+
+	// errProgramDataOutOfBounds is returned if an instruction tries to access
+	// data beyond the length of the program data.
+	errProgramDataOutOfBounds = errors.New("program data access out of bounds")
+
+	// programDataTimeout is the amount of time the MDM waits for new program
+	// data to arrive before failing an instruction which is waiting for it.
+	programDataTimeout = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 2 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
 // programData is a buffer for the program data. It will read packets from r and
 // append them to data.
 type programData struct {
@@ -95,7 +116,7 @@ func (pd *programData) threadedFetchData(r io.Reader) {
 		}
 		n, err := r.Read(d)
 		if err != nil {
-			quit(err)
+			quit(errors.Compose(ErrMissingProgramData, err))
 			return
 		}
 		remainingData -= int64(n)
@@ -118,13 +139,24 @@ func (pd *programData) threadedFetchData(r io.Reader) {
 	}
 }
 
+// checkBounds returns an error if the 'length' bytes at 'offset' are not
+// within the program data.
+func (pd *programData) checkBounds(offset, length uint64) error {
+	if offset > pd.staticLength || length > pd.staticLength-offset {
+		return errors.AddContext(errProgramDataOutOfBounds, fmt.Sprintf("offset %v + length %v > %v", offset, length, pd.staticLength))
+	}
+	return nil
+}
+
 // managedBytes tries to fetch length bytes at offset from the underlying data
 // slice of the programData. If the data is not available yet, a request will be
-// queued up and the method will block for the data to be read.
+// queued up and the method will block for the data to be read. If no new data
+// arrives within programDataTimeout while waiting, ErrMissingProgramData is
+// returned.
 func (pd *programData) managedBytes(offset, length uint64) ([]byte, error) {
 	// Check if request is valid.
-	if offset+length > pd.staticLength {
-		return nil, fmt.Errorf("offset+length out of bounds: %v > %v", offset+length, pd.staticLength)
+	if err := pd.checkBounds(offset, length); err != nil {
+		return nil, err
 	}
 	pd.mu.Lock()
 	// Check if data is available already.
@@ -143,8 +175,27 @@ func (pd *programData) managedBytes(offset, length uint64) ([]byte, error) {
 		requiredLength: offset + length,
 		c:              c,
 	})
+	received := len(pd.data)
 	pd.mu.Unlock()
-	<-c
+	timer := time.NewTimer(programDataTimeout)
+	defer timer.Stop()
+	for done := false; !done; {
+		select {
+		case <-c:
+			done = true
+		case <-timer.C:
+			// Only time out if the renter stopped sending data. A slow
+			// renter which keeps sending data is given more time.
+			pd.mu.Lock()
+			stalled := len(pd.data) == received
+			received = len(pd.data)
+			pd.mu.Unlock()
+			if stalled {
+				return nil, errors.AddContext(ErrMissingProgramData, fmt.Sprintf("no data received within %v", programDataTimeout))
+			}
+			timer.Reset(programDataTimeout)
+		}
+	}
 	pd.mu.Lock()
 	defer pd.mu.Unlock()
 	// Check if the data is available again. It should be unless there was a
@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"io"
+	"math"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
@@ -73,8 +75,13 @@ func TestOutOfBounds(t *testing.T) {
 	buf := bytes.NewReader(fastrand.Bytes(8))
 	pd := openProgramData(buf, 7)
 	_, err := pd.managedBytes(0, 8)
-	if err == nil {
-		t.Fatal("managedBytes should fail")
+	if !errors.Contains(err, errProgramDataOutOfBounds) {
+		t.Fatal("managedBytes should fail", err)
+	}
+	// An offset which causes an overflow should be out-of-bounds as well.
+	_, err = pd.managedBytes(math.MaxUint64, 2)
+	if !errors.Contains(err, errProgramDataOutOfBounds) {
+		t.Fatal("managedBytes should fail", err)
 	}
 	defer func() {
 		if err := pd.Close(); err != nil {
@@ -101,5 +108,68 @@ func TestEOFWhileReading(t *testing.T) {
 	if !errors.Contains(err, io.EOF) {
 		t.Errorf("error was supposed to be %v but was %v", io.EOF, err)
 	}
+	if !errors.Contains(err, ErrMissingProgramData) {
+		t.Errorf("error was supposed to be %v but was %v", ErrMissingProgramData, err)
+	}
 	close(cont)
 }
+
+// TestProgramDataTimeout tests that waiting for data which the renter doesn't
+// send times out while a renter which keeps sending data is given more time.
+func TestProgramDataTimeout(t *testing.T) {
+	t.Parallel()
+
+	// Open the program data with a reader that never sends the data.
+	r, w := io.Pipe()
+	pd := openProgramData(r, 16)
+	defer func() {
+		if err := errors.Compose(w.Close(), pd.Close()); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Send the first 8 bytes but not the remaining ones.
+	data := fastrand.Bytes(8)
+	go func() {
+		_, _ = w.Write(data)
+	}()
+	n, err := pd.Uint64(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := binary.LittleEndian.Uint64(data); n != expected {
+		t.Fatalf("uint64 should be %v but was %v", expected, n)
+	}
+	start := time.Now()
+	_, err = pd.Uint64(8)
+	if !errors.Contains(err, ErrMissingProgramData) {
+		t.Fatal("expected ErrMissingProgramData but got", err)
+	}
+	if time.Since(start) < programDataTimeout {
+		t.Fatal("request timed out too early", time.Since(start))
+	}
+
+	// Open another program data and send the data slowly. The request should
+	// succeed even though it takes longer than the timeout.
+	r2, w2 := io.Pipe()
+	pd2 := openProgramData(r2, 4)
+	defer func() {
+		if err := errors.Compose(w2.Close(), pd2.Close()); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	data = fastrand.Bytes(4)
+	go func() {
+		for i := range data {
+			time.Sleep(programDataTimeout / 2)
+			_, _ = w2.Write(data[i : i+1])
+		}
+	}()
+	b, err := pd2.Bytes(0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, data) {
+		t.Fatal("wrong data")
+	}
+}
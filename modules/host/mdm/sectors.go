@@ -49,6 +49,15 @@ func (s *sectors) appendSector(sectorData []byte) (crypto.Hash, error) {
 	return cachedMerkleRoot(s.merkleRoots), nil
 }
 
+// releaseGained releases the data of the sectors gained by the program. It is
+// called when a program is interrupted since its gained sectors will never be
+// committed.
+func (s *sectors) releaseGained() {
+	for root := range s.sectorsGained {
+		delete(s.sectorsGained, root)
+	}
+}
+
 // dropSectors drops the specified number of sectors and returns the new merkle
 // root.
 func (s *sectors) dropSectors(numSectorsDropped uint64) (crypto.Hash, error) {
@@ -102,6 +111,61 @@ func (s *sectors) swapSectors(idx1, idx2 uint64) (crypto.Hash, error) {
 	return cachedMerkleRoot(s.merkleRoots), nil
 }
 
+// updateSector replaces the sector with the given root with a sector
+// containing newData and returns the new merkle root. Only the first sector
+// with a matching root is updated.
+func (s *sectors) updateSector(root crypto.Hash, newData []byte) (crypto.Hash, error) {
+	if uint64(len(newData)) != modules.SectorSize {
+		return crypto.Hash{}, fmt.Errorf("trying to update sector with data of length %v", len(newData))
+	}
+	idx, exists := s.sectorIndex(root)
+	if !exists {
+		return crypto.Hash{}, fmt.Errorf("trying to update sector %v which doesn't exist", root)
+	}
+	newRoot := crypto.MerkleRoot(newData)
+
+	// If the data didn't change, there is nothing to update.
+	if newRoot == root {
+		return cachedMerkleRoot(s.merkleRoots), nil
+	}
+
+	// Update the program cache for the replaced sector.
+	_, gained := s.sectorsGained[root]
+	if gained {
+		// Remove the sector from the cache.
+		delete(s.sectorsGained, root)
+	} else {
+		// Mark the sector as removed in the cache.
+		s.sectorsRemoved[root] = struct{}{}
+	}
+
+	// Update the program cache for the new sector.
+	_, removed := s.sectorsRemoved[newRoot]
+	if removed {
+		// If the sector has been marked as removed, unmark it.
+		delete(s.sectorsRemoved, newRoot)
+	} else {
+		// Add the sector to the cache.
+		s.sectorsGained[newRoot] = newData
+	}
+
+	// Update the roots.
+	s.merkleRoots[idx] = newRoot
+
+	// Return the new merkle root of the contract.
+	return cachedMerkleRoot(s.merkleRoots), nil
+}
+
+// sectorIndex returns the index of the first sector with the given root.
+func (s *sectors) sectorIndex(sectorRoot crypto.Hash) (uint64, bool) {
+	for i, root := range s.merkleRoots {
+		if root == sectorRoot {
+			return uint64(i), true
+		}
+	}
+	return 0, false
+}
+
 // translateOffset translates an offset within a filecontract into a relative
 // offset within a sector and the sector's index within the contract.
 func (s *sectors) translateOffset(offset uint64) (uint64, uint64, error) {
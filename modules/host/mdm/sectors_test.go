@@ -266,6 +266,95 @@ func TestHasSector(t *testing.T) {
 	}
 }
 
+// TestUpdateSector tests updating sectors in the cache.
+func TestUpdateSector(t *testing.T) {
+	// Initialize the sectors.
+	sectorRoots := randomSectorRoots(initialContractSectors)
+	s := newSectors(append([]crypto.Hash{}, sectorRoots...))
+
+	// Try updating a sector with invalid data -- should fail.
+	_, err := s.updateSector(sectorRoots[0], []byte{0})
+	if err == nil {
+		t.Fatal("expected error when updating with invalid data")
+	}
+
+	// Try updating a sector that doesn't exist -- should fail.
+	_, err = s.updateSector(randomSector(), randomSectorData())
+	if err == nil {
+		t.Fatal("expected error when updating a non-existent sector")
+	}
+
+	// Update an existing sector.
+	idx := 3
+	oldSector := sectorRoots[idx]
+	newSectorData := randomSectorData()
+	newSector := crypto.MerkleRoot(newSectorData)
+	newMerkleRoot, err := s.updateSector(oldSector, newSectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoots[idx] = newSector
+	if newMerkleRoot != cachedMerkleRoot(sectorRoots) {
+		t.Fatal("unexpected merkle root")
+	}
+	if !reflect.DeepEqual(sectorRoots, s.merkleRoots) {
+		t.Fatal("expected sector roots different than actual sector roots")
+	}
+	if _, removed := s.sectorsRemoved[oldSector]; !removed || len(s.sectorsRemoved) != 1 {
+		t.Fatal("old sector should be the only removed sector", len(s.sectorsRemoved))
+	}
+	if !bytes.Equal(s.sectorsGained[newSector], newSectorData) || len(s.sectorsGained) != 1 {
+		t.Fatal("new sector should be the only gained sector", len(s.sectorsGained))
+	}
+
+	// Update the sector again. The intermediate sector was never committed so
+	// it should be neither gained nor removed.
+	newSectorData2 := randomSectorData()
+	newSector2 := crypto.MerkleRoot(newSectorData2)
+	_, err = s.updateSector(newSector, newSectorData2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sectorRoots[idx] = newSector2
+	if !reflect.DeepEqual(sectorRoots, s.merkleRoots) {
+		t.Fatal("expected sector roots different than actual sector roots")
+	}
+	if _, removed := s.sectorsRemoved[oldSector]; !removed || len(s.sectorsRemoved) != 1 {
+		t.Fatal("old sector should be the only removed sector", len(s.sectorsRemoved))
+	}
+	if !bytes.Equal(s.sectorsGained[newSector2], newSectorData2) || len(s.sectorsGained) != 1 {
+		t.Fatal("new sector should be the only gained sector", len(s.sectorsGained))
+	}
+
+	// Append a sector and update it within the same program. Only the updated
+	// sector should be gained.
+	appendedData := randomSectorData()
+	s = newSectors(randomSectorRoots(initialContractSectors))
+	_, err = s.appendSector(appendedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.updateSector(crypto.MerkleRoot(appendedData), newSectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.sectorsRemoved) != 0 || len(s.sectorsGained) != 1 {
+		t.Fatal("unexpected program cache", len(s.sectorsRemoved), len(s.sectorsGained))
+	}
+
+	// Updating a sector with the same data shouldn't change the cache.
+	_, err = s.updateSector(newSector, newSectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.sectorsRemoved) != 0 || len(s.sectorsGained) != 1 {
+		t.Fatal("unexpected program cache", len(s.sectorsRemoved), len(s.sectorsGained))
+	}
+	if !bytes.Equal(s.sectorsGained[newSector], newSectorData) {
+		t.Fatal("new sector not found in sectors gained")
+	}
+}
+
 // TestReadSector tests reading sector data from the cache and host.
 func TestReadSector(t *testing.T) {
 	// Initialize the host and sectors.
@@ -306,3 +395,21 @@ func TestReadSector(t *testing.T) {
 		}
 	}
 }
+
+// TestReleaseGained tests releasing the sectors gained by a program.
+func TestReleaseGained(t *testing.T) {
+	sectorRoots := randomSectorRoots(initialContractSectors)
+	s := newSectors(sectorRoots)
+	for i := 0; i < 3; i++ {
+		if _, err := s.appendSector(randomSectorData()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(s.sectorsGained) != 3 {
+		t.Fatalf("expected %v gained sectors but got %v", 3, len(s.sectorsGained))
+	}
+	s.releaseGained()
+	if len(s.sectorsGained) != 0 {
+		t.Fatalf("expected %v gained sectors but got %v", 0, len(s.sectorsGained))
+	}
+}
@@ -0,0 +1,63 @@
+package mdm
+
+import (
+	"sort"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// ActivePrograms returns the programs which are currently executed by the MDM
+// sorted by the time their execution started.
+func (mdm *MDM) ActivePrograms() []modules.MDMProgramInfo {
+	mdm.mu.Lock()
+	defer mdm.mu.Unlock()
+	programs := make([]modules.MDMProgramInfo, 0, len(mdm.activePrograms))
+	for _, info := range mdm.activePrograms {
+		programs = append(programs, info)
+	}
+	sort.Slice(programs, func(i, j int) bool {
+		return programs[i].ID < programs[j].ID
+	})
+	return programs
+}
+
+// Stats returns the cumulative statistics of the programs the MDM executed.
+func (mdm *MDM) Stats() modules.MDMStats {
+	mdm.mu.Lock()
+	defer mdm.mu.Unlock()
+	return mdm.stats
+}
+
+// managedRegisterProgram adds a program to the active programs and returns
+// the id it was registered with.
+func (mdm *MDM) managedRegisterProgram(numInstructions uint64, budget types.Currency, readOnly bool, fcid types.FileContractID) uint64 {
+	mdm.mu.Lock()
+	defer mdm.mu.Unlock()
+	id := mdm.nextProgramID
+	mdm.nextProgramID++
+	mdm.activePrograms[id] = modules.MDMProgramInfo{
+		ID:              id,
+		StartTime:       time.Now(),
+		NumInstructions: numInstructions,
+		Budget:          budget,
+		ReadOnly:        readOnly,
+		ContractID:      fcid,
+	}
+	return id
+}
+
+// managedDeregisterProgram removes a program from the active programs after
+// its execution finished and adds its results to the MDM's stats.
+func (mdm *MDM) managedDeregisterProgram(id uint64, p *program) {
+	mdm.mu.Lock()
+	defer mdm.mu.Unlock()
+	delete(mdm.activePrograms, id)
+	mdm.stats.ProgramsExecuted++
+	mdm.stats.InstructionsExecuted += p.executedInstructions
+	mdm.stats.BudgetConsumed = mdm.stats.BudgetConsumed.Add(p.executionCost)
+	if p.outputErr != nil {
+		mdm.stats.ProgramFailures++
+	}
+}
@@ -0,0 +1,120 @@
+package mdm
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestMDMStats runs a few programs concurrently and checks that the MDM lists
+// them as active programs while they are executed and that the stats add up
+// after they are done.
+func TestMDMStats(t *testing.T) {
+	host := newTestHost()
+	mdm := New(host)
+	defer mdm.Stop()
+	pt := newTestPriceTable()
+	duration := types.BlockHeight(1)
+	so := host.newTestStorageObligation(true)
+
+	// Start a few successful programs and one program without enough budget
+	// to run its first instruction. None of them can finish before we read
+	// their outputs.
+	numPrograms := 3
+	numInstructions := 2
+	var outputChans []<-chan Output
+	var budgets []types.Currency
+	for i := 0; i <= numPrograms; i++ {
+		tb := newTestProgramBuilder(pt, duration)
+		for j := 0; j < numInstructions; j++ {
+			tb.AddHasSectorInstruction(crypto.Hash{})
+		}
+		program, data := tb.Program()
+		values := tb.Cost()
+		budget := values.Budget(false)
+		if i == numPrograms {
+			budget = modules.NewBudget(modules.MDMInitCost(pt, uint64(len(data)), uint64(len(program))))
+		}
+		budgets = append(budgets, budget.Remaining())
+		_, outputChan, err := mdm.ExecuteProgram(context.Background(), pt, program, budget, types.ZeroCurrency, so, duration, uint64(len(data)), bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputChans = append(outputChans, outputChan)
+	}
+
+	// Check the active programs.
+	active := mdm.ActivePrograms()
+	if len(active) != numPrograms+1 {
+		t.Fatalf("expected %v active programs but got %v", numPrograms+1, len(active))
+	}
+	for i, info := range active {
+		if info.ID != uint64(i) {
+			t.Fatal("wrong id", info.ID, i)
+		}
+		if info.NumInstructions != uint64(numInstructions) {
+			t.Fatal("wrong number of instructions", info.NumInstructions)
+		}
+		if !info.Budget.Equals(budgets[i]) {
+			t.Fatal("wrong budget", info.Budget, budgets[i])
+		}
+		if !info.ReadOnly {
+			t.Fatal("program should be readonly")
+		}
+		if info.ContractID != so.RecentRevision().ParentID {
+			t.Fatal("wrong contract id", info.ContractID)
+		}
+		if info.StartTime.IsZero() {
+			t.Fatal("start time not set")
+		}
+	}
+	if stats := mdm.Stats(); stats.ProgramsExecuted != 0 {
+		t.Fatal("no program should be done yet", stats)
+	}
+
+	// Read the outputs concurrently and sum up the execution costs.
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var totalCost types.Currency
+	var failures uint64
+	for _, outputChan := range outputChans {
+		wg.Add(1)
+		go func(outputChan <-chan Output) {
+			defer wg.Done()
+			var last Output
+			for output := range outputChan {
+				last = output
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			totalCost = totalCost.Add(last.ExecutionCost)
+			if last.Error != nil {
+				failures++
+			}
+		}(outputChan)
+	}
+	wg.Wait()
+
+	// All programs should be done.
+	if active := mdm.ActivePrograms(); len(active) != 0 {
+		t.Fatal("expected no active programs", active)
+	}
+	stats := mdm.Stats()
+	if stats.ProgramsExecuted != uint64(numPrograms+1) {
+		t.Fatal("wrong number of executed programs", stats.ProgramsExecuted)
+	}
+	if stats.InstructionsExecuted != uint64(numPrograms*numInstructions) {
+		t.Fatal("wrong number of executed instructions", stats.InstructionsExecuted)
+	}
+	if failures != 1 || stats.ProgramFailures != failures {
+		t.Fatal("wrong number of failures", stats.ProgramFailures, failures)
+	}
+	if !stats.BudgetConsumed.Equals(totalCost) {
+		t.Fatal("wrong consumed budget", stats.BudgetConsumed, totalCost)
+	}
+}
@@ -87,6 +87,19 @@ func (v *TestValues) AddHasSectorInstruction() {
 	v.addInstruction(collateral, cost, types.ZeroCurrency, types.ZeroCurrency, memory, time, newData, readonly, batch)
 }
 
+// AddHasSectorsInstruction adds a hassectors instruction to the builder,
+// keeping track of running values.
+func (v *TestValues) AddHasSectorsInstruction(numSectors uint64) {
+	collateral := modules.MDMHasSectorsCollateral()
+	cost := modules.MDMHasSectorsCost(v.staticPT, numSectors)
+	memory := modules.MDMHasSectorsMemory()
+	time := modules.MDMHasSectorsTime(numSectors)
+	newData := 8 + int(numSectors)*crypto.HashSize
+	readonly := true
+	batch := true
+	v.addInstruction(collateral, cost, types.ZeroCurrency, types.ZeroCurrency, memory, time, newData, readonly, batch)
+}
+
 // AddReadOffsetInstruction adds a readoffset instruction to the builder,
 // keeping track of running values.
 func (v *TestValues) AddReadOffsetInstruction(length uint64) {
@@ -138,6 +151,19 @@ func (v *TestValues) AddSwapSectorInstruction() {
 	v.addInstruction(collateral, cost, types.ZeroCurrency, types.ZeroCurrency, memory, time, newData, readonly, batch)
 }
 
+// AddUpdateSectorInstruction adds the cost of an update sector instruction to
+// the object.
+func (v *TestValues) AddUpdateSectorInstruction(data []byte) {
+	collateral := modules.MDMUpdateSectorCollateral()
+	cost := modules.MDMUpdateSectorCost(v.staticPT)
+	memory := modules.MDMUpdateSectorMemory()
+	time := uint64(modules.MDMTimeUpdateSector)
+	newData := crypto.HashSize + len(data)
+	readonly := false
+	batch := false
+	v.addInstruction(collateral, cost, types.ZeroCurrency, types.ZeroCurrency, memory, time, newData, readonly, batch)
+}
+
 // AddUpdateRegistryInstruction adds a revision instruction to the builder, keeping
 // track of running values.
 func (v *TestValues) AddUpdateRegistryInstruction(spk types.SiaPublicKey, rv modules.SignedRegistryValue) {
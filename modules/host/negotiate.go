@@ -3,6 +3,8 @@ package host
 import (
 	"time"
 
+	"gitlab.com/NebulousLabs/errors"
+
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
@@ -103,6 +105,10 @@ var (
 	// will not accept revisions once the window start is too close.
 	ErrLateRevision = ErrorCommunication("renter is requesting revision after the revision deadline")
 
+	// ErrLargeContract is returned if the renter proposes to renew a file
+	// contract which is larger than the host's max contract size.
+	ErrLargeContract = ErrorCommunication("renter proposed a file contract which exceeds the host's max contract size")
+
 	// ErrLongDuration is returned if the renter proposes a file contract with
 	// an expiration that is too far into the future according to the host's
 	// settings.
@@ -279,6 +285,13 @@ func (h *Host) managedFinalizeContract(args finalizeContractArgs) ([]types.Trans
 
 		h: h,
 	}
+	if args.renewedSO != nil {
+		so.RenterKey, err = args.renewedSO.renterKey()
+		if err != nil {
+			builder.Drop()
+			return nil, types.TransactionSignature{}, types.FileContractID{}, errors.AddContext(err, "failed to get renter key of renewed contract")
+		}
+	}
 
 	// Get a lock on the storage obligation.
 	lockErr := h.managedTryLockStorageObligation(so.id(), obligationLockTimeout)
@@ -149,6 +149,10 @@ func (h *Host) managedVerifyRenewedContract(so storageObligation, txnSet []types
 	if fc.WindowStart > blockHeight+externalSettings.MaxDuration {
 		return types.Currency{}, ErrLongDuration
 	}
+	// The renewed contract must not exceed the max contract size.
+	if externalSettings.MaxContractSize != 0 && fc.FileSize > externalSettings.MaxContractSize {
+		return types.Currency{}, ErrLargeContract
+	}
 
 	// ValidProofOutputs shoud have 2 outputs (renter + host) and missed
 	// outputs should have 3 (renter + host + void)
@@ -108,6 +108,11 @@ func (h *Host) managedRevisionIteration(conn net.Conn, so *storageObligation, fi
 				if uint64(len(modification.Data)) != modules.SectorSize {
 					return ErrBadSectorSize
 				}
+				// Check that the contract doesn't exceed the max contract
+				// size.
+				if settings.MaxContractSize != 0 && uint64(len(so.SectorRoots)+1)*modules.SectorSize > settings.MaxContractSize {
+					return modules.ErrMaxContractSize
+				}
 
 				// Update finances.
 				blocksRemaining := so.proofDeadline() - blockHeight
@@ -11,17 +11,11 @@ import (
 )
 
 // capacity returns the amount of storage still available on the machine. The
-// amount can be negative if the total capacity was reduced to below the active
-// capacity.
+// remaining storage is the conservative estimate of the storage manager which
+// already accounts for sectors that are queued to be added.
 func (h *Host) capacity() (total, remaining uint64) {
-	// Total storage can be computed by summing the size of all the storage
-	// folders.
-	sfs := h.StorageFolders()
-	for _, sf := range sfs {
-		total += sf.Capacity
-		remaining += sf.CapacityRemaining
-	}
-	return total, remaining
+	c := h.Capacity()
+	return c.Total, c.RemainingAdjusted
 }
 
 // externalSettings compiles and returns the external settings for the host.
@@ -85,6 +79,8 @@ func (h *Host) externalSettings(maxFeeEstimation types.Currency) modules.HostExt
 		Collateral:    h.settings.Collateral,
 		MaxCollateral: maxCollateral,
 
+		MaxContractSize: h.settings.MaxContractSize,
+
 		BaseRPCPrice:           h.settings.MinBaseRPCPrice,
 		ContractPrice:          contractPrice,
 		DownloadBandwidthPrice: h.settings.MinDownloadBandwidthPrice,
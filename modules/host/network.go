@@ -347,6 +347,11 @@ func (h *Host) threadedHandleConn(conn net.Conn) {
 
 // threadedHandleStream handles incoming SiaMux streams.
 func (h *Host) threadedHandleStream(stream siamux.Stream) {
+	// wrap the stream to be able to attribute its bandwidth to the renter
+	// that paid for the RPC
+	is := newIdentifiedStream(stream)
+	stream = is
+
 	// close the stream when the method terminates
 	var cleanup afterCloseFn
 	defer func() {
@@ -362,6 +367,7 @@ func (h *Host) threadedHandleStream(stream siamux.Stream) {
 		l := stream.Limit()
 		atomic.AddUint64(&h.atomicStreamUpload, l.Uploaded())
 		atomic.AddUint64(&h.atomicStreamDownload, l.Downloaded())
+		h.staticRenterUsage.callTrackStream(is, l.Downloaded(), l.Uploaded())
 
 		// Call rpc specific cleanup if necessary.
 		if cleanup != nil {
@@ -413,6 +419,8 @@ func (h *Host) threadedHandleStream(stream siamux.Stream) {
 		err = h.managedRPCExecuteProgram(stream)
 	case modules.RPCUpdatePriceTable:
 		err = h.managedRPCUpdatePriceTable(stream)
+	case modules.RPCPinPriceTable:
+		err = h.managedRPCPinPriceTable(stream)
 	case modules.RPCFundAccount:
 		err = h.managedRPCFundEphemeralAccount(stream)
 	case modules.RPCLatestRevision:
@@ -1,6 +1,7 @@
 package host
 
 import (
+	"bytes"
 	"encoding/json"
 	"math"
 	"math/bits"
@@ -207,6 +208,11 @@ func (h *Host) managedRPCLoopWrite(s *rpcSession) error {
 				s.writeError(ErrBadSectorSize)
 				return ErrBadSectorSize
 			}
+			// Check that the contract doesn't exceed the max contract size.
+			if settings.MaxContractSize != 0 && uint64(len(newRoots)+1)*modules.SectorSize > settings.MaxContractSize {
+				s.writeError(modules.ErrMaxContractSize)
+				return modules.ErrMaxContractSize
+			}
 			// Update sector roots.
 			newRoot := crypto.MerkleRoot(action.Data)
 			newRoots = append(newRoots, newRoot)
@@ -549,7 +555,12 @@ func (h *Host) managedRPCLoopRead(s *rpcSession) error {
 		if req.MerkleProof {
 			proofStart := int(sec.Offset) / crypto.SegmentSize
 			proofEnd := int(sec.Offset+sec.Length) / crypto.SegmentSize
-			proof = crypto.MerkleRangeProof(sectorData, proofStart, proofEnd)
+			proof, err = crypto.MerkleRangeProofFromReader(bytes.NewReader(sectorData), proofStart, proofEnd)
+			if err != nil {
+				err = errors.AddContext(err, "failed to build Merkle proof")
+				err = errors.Compose(err, s.writeError(err))
+				return err
+			}
 		}
 
 		// Send the response. If the renter sent a stop signal, or this is the
@@ -0,0 +1,97 @@
+package host
+
+import (
+	"encoding/json"
+	"time"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+// managedReferencedSectorRoots returns the sector roots of all storage
+// obligations which haven't been resolved yet. Resolved obligations already
+// removed their sectors from the storage manager.
+func (h *Host) managedReferencedSectorRoots() (roots []crypto.Hash, err error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err = h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketStorageObligations).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var so storageObligation
+			err := json.Unmarshal(v, &so)
+			if err != nil {
+				return err
+			}
+			if so.ObligationStatus == obligationUnresolved {
+				roots = append(roots, so.SectorRoots...)
+			}
+		}
+		return nil
+	})
+	return roots, err
+}
+
+// managedCollectOrphanedSectors checks the storage manager for sectors which
+// aren't referenced by any active storage obligation. Depending on the host's
+// settings, the orphaned sectors are either only reported or deleted once they
+// remained orphaned for the configured delay.
+func (h *Host) managedCollectOrphanedSectors() error {
+	h.mu.RLock()
+	deleteOrphans := h.settings.OrphanedSectorDeletion
+	delay := h.settings.OrphanedSectorDeletionDelay
+	h.mu.RUnlock()
+
+	roots, err := h.managedReferencedSectorRoots()
+	if err != nil {
+		return errors.AddContext(err, "failed to get referenced sector roots")
+	}
+	report, err := h.StorageManager.CollectOrphanedSectors(roots, deleteOrphans, delay)
+	if err != nil {
+		return errors.AddContext(err, "failed to collect orphaned sectors")
+	}
+	if report.Flagged > 0 || report.Deleted > 0 {
+		h.log.Printf("Found %v orphaned sectors, deleted %v orphaned sectors", report.Flagged+report.Deleted, report.Deleted)
+	}
+
+	h.mu.Lock()
+	h.orphanedSectors.LastCheck = time.Now()
+	h.orphanedSectors.Flagged = report.Flagged
+	h.orphanedSectors.Deleted += report.Deleted
+	h.mu.Unlock()
+	return nil
+}
+
+// threadedCollectOrphanedSectors periodically checks for orphaned sectors.
+//
+// Note: threadgroup counter must be inside for loop. If not, calling 'Flush'
+// on the threadgroup would deadlock.
+func (h *Host) threadedCollectOrphanedSectors() {
+	for {
+		// Block until next cycle.
+		select {
+		case <-h.tg.StopChan():
+			return
+		case <-time.After(orphanedSectorsCheckFrequency):
+		}
+
+		func() {
+			if err := h.tg.Add(); err != nil {
+				return
+			}
+			defer h.tg.Done()
+			if err := h.managedCollectOrphanedSectors(); err != nil {
+				h.log.Println("WARN: failed to check for orphaned sectors:", err)
+			}
+		}()
+	}
+}
+
+// OrphanedSectors returns information about the host's sectors which aren't
+// referenced by any of its active storage obligations.
+func (h *Host) OrphanedSectors() modules.HostOrphanedSectors {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.orphanedSectors
+}
@@ -0,0 +1,99 @@
+package host
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/crypto"
+)
+
+// TestCollectOrphanedSectors checks that the host reports sectors which aren't
+// referenced by any storage obligation and only deletes them if enabled and
+// after the deletion delay.
+func TestCollectOrphanedSectors(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ht.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	h := ht.host
+
+	// The deletion is disabled by default.
+	settings := h.InternalSettings()
+	if settings.OrphanedSectorDeletion || settings.OrphanedSectorDeletionDelay != defaultOrphanedSectorDeletionDelay {
+		t.Fatal("unexpected default settings", settings.OrphanedSectorDeletion, settings.OrphanedSectorDeletionDelay)
+	}
+
+	// Add a storage obligation which references a sector.
+	so, err := ht.newTesterStorageObligation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, data := randSector()
+	so.SectorRoots = []crypto.Hash{root}
+	h.managedLockStorageObligation(so.id())
+	err = h.managedAddStorageObligation(so)
+	h.managedUnlockStorageObligation(so.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddSector(root, data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a sector which isn't referenced by any obligation.
+	orphanRoot, orphanData := randSector()
+	if err := h.AddSector(orphanRoot, orphanData); err != nil {
+		t.Fatal(err)
+	}
+
+	// checkOrphans is a helper to collect the orphaned sectors and check the
+	// result.
+	checkOrphans := func(flagged, deleted uint64, orphanExists bool) {
+		t.Helper()
+		if err := h.managedCollectOrphanedSectors(); err != nil {
+			t.Fatal(err)
+		}
+		orphans := h.OrphanedSectors()
+		if orphans.Flagged != flagged || orphans.Deleted != deleted || orphans.LastCheck.IsZero() {
+			t.Fatalf("expected %v flagged and %v deleted sectors but got %+v", flagged, deleted, orphans)
+		}
+		if !h.HasSector(root) {
+			t.Fatal("referenced sector shouldn't be deleted")
+		}
+		if h.HasSector(orphanRoot) != orphanExists {
+			t.Fatalf("expected orphan to exist: %v", orphanExists)
+		}
+	}
+
+	// In report-only mode the orphan is flagged but not deleted.
+	checkOrphans(1, 0, true)
+
+	// Enable the deletion. The orphan shouldn't be deleted before the delay
+	// passed.
+	settings.OrphanedSectorDeletion = true
+	if err := h.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	checkOrphans(1, 0, true)
+
+	// Lower the delay. Once it passed the orphan should be deleted.
+	delay := 100 * time.Millisecond
+	settings.OrphanedSectorDeletionDelay = delay
+	if err := h.SetInternalSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(delay)
+	checkOrphans(0, 1, false)
+
+	// Another check shouldn't find any orphans.
+	checkOrphans(0, 1, false)
+}
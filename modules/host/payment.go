@@ -48,6 +48,9 @@ func (h *Host) staticPayByEphemeralAccount(stream siamux.Stream, bh types.BlockH
 		return nil, errors.AddContext(err, "Withdraw failed")
 	}
 
+	// Remember the account for attributing the stream's bandwidth.
+	staticTrackStreamAccount(stream, req.Message.Account)
+
 	// Payment done through EAs don't move collateral
 	return newPaymentDetails(req.Message.Account, req.Message.Amount), nil
 }
@@ -131,6 +134,13 @@ func (h *Host) managedPayByContract(stream siamux.Stream, bh types.BlockHeight)
 		return nil, errors.AddContext(err, "Could not send PayByContractResponse")
 	}
 
+	// Remember the refund account of the renter for attributing the stream's
+	// bandwidth.
+	if renterKey, err := so.renterKey(); err == nil {
+		h.staticRenterUsage.callTrackAccount(accountID, renterKey)
+	}
+	staticTrackStreamAccount(stream, accountID)
+
 	return newPaymentDetails(accountID, amount), nil
 }
 
@@ -231,6 +241,13 @@ func (h *Host) managedFundAccount(stream siamux.Stream, request modules.FundAcco
 		return types.ZeroCurrency, errors.AddContext(err, "Could not send PayByContractResponse")
 	}
 
+	// Remember the funded account of the renter for attributing the stream's
+	// bandwidth.
+	if renterKey, err := so.renterKey(); err == nil {
+		h.staticRenterUsage.callTrackAccount(request.Account, renterKey)
+	}
+	staticTrackStreamAccount(stream, request.Account)
+
 	return deposit, nil
 }
 
@@ -25,6 +25,7 @@ type persistence struct {
 	Announced        bool                         `json:"announced"`
 	AutoAddress      modules.NetAddress           `json:"autoaddress"`
 	FinancialMetrics modules.HostFinancialMetrics `json:"financialmetrics"`
+	RenterUsage      renterUsagePersist           `json:"renterusage"`
 	PublicKey        types.SiaPublicKey           `json:"publickey"`
 	RevisionNumber   uint64                       `json:"revisionnumber"`
 	SecretKey        crypto.SecretKey             `json:"secretkey"`
@@ -43,6 +44,7 @@ func (h *Host) persistData() persistence {
 		Announced:        h.announced,
 		AutoAddress:      h.autoAddress,
 		FinancialMetrics: h.financialMetrics,
+		RenterUsage:      h.staticRenterUsage.callPersistData(),
 		PublicKey:        h.publicKey,
 		RevisionNumber:   h.revisionNumber,
 		SecretKey:        h.secretKey,
@@ -75,6 +77,10 @@ func (h *Host) establishDefaults() error {
 		EphemeralAccountExpiry:     modules.DefaultEphemeralAccountExpiry,
 		MaxEphemeralAccountBalance: modules.DefaultMaxEphemeralAccountBalance,
 		MaxEphemeralAccountRisk:    defaultMaxEphemeralAccountRisk,
+
+		OrphanedSectorDeletionDelay: defaultOrphanedSectorDeletionDelay,
+
+		MaxPinnedPriceTableValidity: defaultMaxPinnedPriceTableValidity,
 	}
 
 	// Load the host's key pair, use the same keys as the SiaMux.
@@ -112,6 +118,7 @@ func (h *Host) loadPersistObject(p *persistence) {
 		h.autoAddress = ""
 	}
 	h.financialMetrics = p.FinancialMetrics
+	h.staticRenterUsage.callLoad(p.RenterUsage, p.BlockHeight)
 	h.publicKey = p.PublicKey
 	h.revisionNumber = p.RevisionNumber
 	h.secretKey = p.SecretKey
@@ -221,6 +228,18 @@ func (h *Host) load() error {
 		h.settings.MinSectorAccessPrice = maxSectorAccessPrice
 		updated = true
 	}
+	// Hosts which were created before the orphaned sector deletion was added
+	// don't have a deletion delay yet.
+	if h.settings.OrphanedSectorDeletionDelay == 0 {
+		h.settings.OrphanedSectorDeletionDelay = defaultOrphanedSectorDeletionDelay
+		updated = true
+	}
+	// Hosts which were created before price tables could be pinned don't have
+	// a maximum pinned price table validity yet.
+	if h.settings.MaxPinnedPriceTableValidity == 0 {
+		h.settings.MaxPinnedPriceTableValidity = defaultMaxPinnedPriceTableValidity
+		updated = true
+	}
 	// If we updated the Price values we should save the changes to disk
 	if updated {
 		err = h.saveSync()
@@ -254,6 +273,9 @@ func (h *Host) load() error {
 		return err
 	}
 
+	// Check the collateral budget usage after recomputing the locked
+	// collateral.
+	h.updateCollateralBudgetAlert()
 	return nil
 }
 
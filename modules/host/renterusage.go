@@ -0,0 +1,449 @@
+package host
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"gitlab.com/NebulousLabs/bolt"
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/siamux"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// renterUsagePeriod is the number of blocks of a period of the renter
+	// usage. The bandwidth consumed by renters is tracked per period.
+	renterUsagePeriod = build.Select(build.Var{
+		Standard: types.BlockHeight(4032), // 4 weeks
+		Dev:      types.BlockHeight(200),
+		Testing:  types.BlockHeight(10),
+	}).(types.BlockHeight)
+
+	// renterUsageRetainedPeriods is the number of periods, including the
+	// current one, for which the renter usage is kept.
+	renterUsageRetainedPeriods = types.BlockHeight(3)
+)
+
+type (
+	// renterUsage tracks the bandwidth consumed by renters, identified by the
+	// public key of the first contract of a renewal chain. The bandwidth is
+	// tracked per period and persisted together with the host's financial
+	// metrics.
+	renterUsage struct {
+		// periods contains the bandwidth consumed by each renter in each of
+		// the retained periods. It is keyed by the start height of the period
+		// and the string representation of the renter's key.
+		periods map[types.BlockHeight]map[string]*renterBandwidth
+
+		// accounts maps the ephemeral accounts which were funded by or
+		// received refunds from a renter's contract to the renter's key. This
+		// allows for attributing RPCs paid by ephemeral accounts to renters.
+		// Accounts which weren't used within the retained periods are pruned.
+		accounts map[modules.AccountID]renterAccount
+
+		// currentPeriod is the start height of the current period.
+		currentPeriod types.BlockHeight
+
+		mu sync.Mutex
+	}
+
+	// renterAccount is an ephemeral account of a renter.
+	renterAccount struct {
+		renterKey types.SiaPublicKey
+
+		// lastPeriod is the start height of the last period in which the
+		// account was used.
+		lastPeriod types.BlockHeight
+	}
+
+	// renterBandwidth is the bandwidth consumed by a single renter within a
+	// period.
+	renterBandwidth struct {
+		key    types.SiaPublicKey
+		period types.BlockHeight
+		in     uint64
+		out    uint64
+	}
+
+	// renterUsagePersist is the persisted form of the renter usage.
+	renterUsagePersist struct {
+		Bandwidth []renterBandwidthPersist `json:"bandwidth"`
+		Accounts  []renterAccountPersist   `json:"accounts"`
+	}
+
+	// renterBandwidthPersist is the persisted form of a renterBandwidth.
+	renterBandwidthPersist struct {
+		RenterKey    types.SiaPublicKey `json:"renterkey"`
+		PeriodStart  types.BlockHeight  `json:"periodstart"`
+		BandwidthIn  uint64             `json:"bandwidthin"`
+		BandwidthOut uint64             `json:"bandwidthout"`
+	}
+
+	// renterAccountPersist is the persisted form of a renterAccount. The
+	// account is persisted as its key since AccountID has no JSON encoding.
+	renterAccountPersist struct {
+		Account    types.SiaPublicKey `json:"account"`
+		RenterKey  types.SiaPublicKey `json:"renterkey"`
+		LastPeriod types.BlockHeight  `json:"lastperiod"`
+	}
+
+	// identifiedStream is a stream which remembers the ephemeral account that
+	// paid for the RPC executed on the stream.
+	identifiedStream struct {
+		siamux.Stream
+
+		account    modules.AccountID
+		hasAccount bool
+		mu         sync.Mutex
+	}
+
+	// countingConn is a net.Conn which counts the bytes read from and written
+	// to the underlying connection.
+	countingConn struct {
+		net.Conn
+
+		atomicRead    uint64
+		atomicWritten uint64
+	}
+)
+
+// newRenterUsage creates a new renterUsage.
+func newRenterUsage() *renterUsage {
+	return &renterUsage{
+		periods:  make(map[types.BlockHeight]map[string]*renterBandwidth),
+		accounts: make(map[modules.AccountID]renterAccount),
+	}
+}
+
+// renterUsagePeriodStart returns the start height of the period that contains
+// the provided height.
+func renterUsagePeriodStart(height types.BlockHeight) types.BlockHeight {
+	return height - height%renterUsagePeriod
+}
+
+// newIdentifiedStream wraps a stream in an identifiedStream.
+func newIdentifiedStream(stream siamux.Stream) *identifiedStream {
+	return &identifiedStream{Stream: stream}
+}
+
+// newCountingConn wraps a connection in a countingConn.
+func newCountingConn(conn net.Conn) *countingConn {
+	return &countingConn{Conn: conn}
+}
+
+// Read implements net.Conn.
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.atomicRead, uint64(n))
+	return n, err
+}
+
+// Write implements net.Conn.
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.atomicWritten, uint64(n))
+	return n, err
+}
+
+// Counts returns the number of bytes read from and written to the connection.
+func (c *countingConn) Counts() (read, written uint64) {
+	return atomic.LoadUint64(&c.atomicRead), atomic.LoadUint64(&c.atomicWritten)
+}
+
+// renterKey returns the public key which identifies the renter of the storage
+// obligation. That's the renter's key of the first contract in the renewal
+// chain of the obligation. Contracts formed from scratch can't be linked to
+// previous contracts of the same renter since the renter uses a new key for
+// each of them.
+func (so storageObligation) renterKey() (types.SiaPublicKey, error) {
+	if len(so.RenterKey.Key) > 0 {
+		return so.RenterKey, nil
+	}
+	rev, err := so.recentRevision()
+	if err != nil {
+		return types.SiaPublicKey{}, err
+	}
+	if len(rev.UnlockConditions.PublicKeys) == 0 {
+		return types.SiaPublicKey{}, errors.New("revision doesn't contain the renter's key")
+	}
+	return rev.UnlockConditions.PublicKeys[0], nil
+}
+
+// callTrackAccount remembers that the account belongs to the renter with the
+// provided key.
+func (ru *renterUsage) callTrackAccount(account modules.AccountID, renterKey types.SiaPublicKey) {
+	if account.IsZeroAccount() {
+		return
+	}
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	ru.accounts[account] = renterAccount{
+		renterKey:  renterKey,
+		lastPeriod: ru.currentPeriod,
+	}
+}
+
+// callTrackBandwidth adds the provided bandwidth to the usage of the renter
+// with the provided key.
+func (ru *renterUsage) callTrackBandwidth(renterKey types.SiaPublicKey, in, out uint64) {
+	if in == 0 && out == 0 {
+		return
+	}
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	ru.trackBandwidth(renterKey, in, out)
+}
+
+// callTrackStream adds the provided bandwidth to the usage of the renter which
+// owns the account that paid for the RPC on the stream. The bandwidth is not
+// attributed if the account is unknown.
+func (ru *renterUsage) callTrackStream(stream *identifiedStream, in, out uint64) {
+	account, ok := stream.managedAccount()
+	if !ok {
+		return
+	}
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	ra, ok := ru.accounts[account]
+	if !ok {
+		return
+	}
+	ra.lastPeriod = ru.currentPeriod
+	ru.accounts[account] = ra
+	ru.trackBandwidth(ra.renterKey, in, out)
+}
+
+// callBandwidth returns the bandwidth consumed by every renter in each of the
+// retained periods.
+func (ru *renterUsage) callBandwidth() []renterBandwidth {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	var bandwidth []renterBandwidth
+	for _, renters := range ru.periods {
+		for _, rb := range renters {
+			bandwidth = append(bandwidth, *rb)
+		}
+	}
+	return bandwidth
+}
+
+// callSetBlockHeight updates the current period according to the provided
+// height and prunes the usage of the periods which are no longer retained.
+func (ru *renterUsage) callSetBlockHeight(height types.BlockHeight) {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	ru.currentPeriod = renterUsagePeriodStart(height)
+
+	// Prune the periods which started before the oldest retained period.
+	var oldestPeriod types.BlockHeight
+	if retained := (renterUsageRetainedPeriods - 1) * renterUsagePeriod; ru.currentPeriod > retained {
+		oldestPeriod = ru.currentPeriod - retained
+	}
+	for period := range ru.periods {
+		if period < oldestPeriod {
+			delete(ru.periods, period)
+		}
+	}
+	for account, ra := range ru.accounts {
+		if ra.lastPeriod < oldestPeriod {
+			delete(ru.accounts, account)
+		}
+	}
+}
+
+// callPersistData returns the renter usage in its persisted form.
+func (ru *renterUsage) callPersistData() renterUsagePersist {
+	ru.mu.Lock()
+	defer ru.mu.Unlock()
+	var p renterUsagePersist
+	for _, renters := range ru.periods {
+		for _, rb := range renters {
+			p.Bandwidth = append(p.Bandwidth, renterBandwidthPersist{
+				RenterKey:    rb.key,
+				PeriodStart:  rb.period,
+				BandwidthIn:  rb.in,
+				BandwidthOut: rb.out,
+			})
+		}
+	}
+	for account, ra := range ru.accounts {
+		p.Accounts = append(p.Accounts, renterAccountPersist{
+			Account:    account.SPK(),
+			RenterKey:  ra.renterKey,
+			LastPeriod: ra.lastPeriod,
+		})
+	}
+	return p
+}
+
+// callLoad replaces the renter usage with the persisted usage and sets the
+// current period according to the provided height.
+func (ru *renterUsage) callLoad(p renterUsagePersist, height types.BlockHeight) {
+	ru.mu.Lock()
+	ru.periods = make(map[types.BlockHeight]map[string]*renterBandwidth)
+	ru.accounts = make(map[modules.AccountID]renterAccount)
+	for _, rb := range p.Bandwidth {
+		ru.periodBandwidth(rb.RenterKey, rb.PeriodStart).in += rb.BandwidthIn
+		ru.periodBandwidth(rb.RenterKey, rb.PeriodStart).out += rb.BandwidthOut
+	}
+	for _, ra := range p.Accounts {
+		var account modules.AccountID
+		account.FromSPK(ra.Account)
+		ru.accounts[account] = renterAccount{
+			renterKey:  ra.RenterKey,
+			lastPeriod: ra.LastPeriod,
+		}
+	}
+	ru.mu.Unlock()
+	ru.callSetBlockHeight(height)
+}
+
+// periodBandwidth returns the bandwidth consumed by the renter with the
+// provided key within the period starting at the provided height. The entry is
+// created if it doesn't exist yet.
+func (ru *renterUsage) periodBandwidth(renterKey types.SiaPublicKey, period types.BlockHeight) *renterBandwidth {
+	renters, ok := ru.periods[period]
+	if !ok {
+		renters = make(map[string]*renterBandwidth)
+		ru.periods[period] = renters
+	}
+	rb, ok := renters[renterKey.String()]
+	if !ok {
+		rb = &renterBandwidth{key: renterKey, period: period}
+		renters[renterKey.String()] = rb
+	}
+	return rb
+}
+
+// trackBandwidth adds the provided bandwidth to the usage of the renter with
+// the provided key within the current period.
+func (ru *renterUsage) trackBandwidth(renterKey types.SiaPublicKey, in, out uint64) {
+	rb := ru.periodBandwidth(renterKey, ru.currentPeriod)
+	rb.in += in
+	rb.out += out
+}
+
+// managedAccount returns the account which paid for the RPC on the stream.
+func (s *identifiedStream) managedAccount() (modules.AccountID, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.account, s.hasAccount
+}
+
+// managedSetAccount sets the account which paid for the RPC on the stream.
+func (s *identifiedStream) managedSetAccount(account modules.AccountID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.account = account
+	s.hasAccount = true
+}
+
+// staticTrackStreamAccount remembers the account which paid for the RPC on the
+// stream if the stream supports it.
+func staticTrackStreamAccount(stream siamux.Stream, account modules.AccountID) {
+	if is, ok := stream.(*identifiedStream); ok && !account.IsZeroAccount() {
+		is.managedSetAccount(account)
+	}
+}
+
+// managedStoredDataByRenter returns the number of unresolved storage
+// obligations and the amount of data they store for every renter.
+func (h *Host) managedStoredDataByRenter() (map[string]*modules.HostRenterUsage, error) {
+	usage := make(map[string]*modules.HostRenterUsage)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	err := h.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(bucketStorageObligations).Cursor()
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			var so storageObligation
+			err := json.Unmarshal(v, &so)
+			if err != nil {
+				return err
+			}
+			if so.ObligationStatus != obligationUnresolved {
+				continue
+			}
+			renterKey, err := so.renterKey()
+			if err != nil {
+				continue
+			}
+			u, ok := usage[renterKey.String()]
+			if !ok {
+				u = &modules.HostRenterUsage{RenterKey: renterKey.String()}
+				usage[renterKey.String()] = u
+			}
+			u.Contracts++
+			u.StoredBytes += so.fileSize()
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// RenterUsage returns the amount of data stored by every renter with an active
+// contract and the bandwidth consumed by every renter within the retained
+// periods. The renters are sorted by the amount of data they store and the
+// bandwidth they consumed. If anonymize is set, the renters' keys are replaced
+// by a hash.
+func (h *Host) RenterUsage(anonymize bool) ([]modules.HostRenterUsage, error) {
+	if err := h.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer h.tg.Done()
+
+	usage, err := h.managedStoredDataByRenter()
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to get stored data by renter")
+	}
+	for _, rb := range h.staticRenterUsage.callBandwidth() {
+		u, ok := usage[rb.key.String()]
+		if !ok {
+			u = &modules.HostRenterUsage{RenterKey: rb.key.String()}
+			usage[rb.key.String()] = u
+		}
+		u.BandwidthIn += rb.in
+		u.BandwidthOut += rb.out
+		u.Periods = append(u.Periods, modules.HostRenterUsagePeriod{
+			PeriodStart:  rb.period,
+			BandwidthIn:  rb.in,
+			BandwidthOut: rb.out,
+		})
+	}
+
+	// Anonymize the keys. The hash includes the host's key to prevent
+	// correlating renters across hosts.
+	h.mu.RLock()
+	hostKey := h.publicKey
+	h.mu.RUnlock()
+	renters := make([]modules.HostRenterUsage, 0, len(usage))
+	for _, u := range usage {
+		if anonymize {
+			u.RenterKey = crypto.HashAll(hostKey, u.RenterKey).String()
+		}
+		sort.Slice(u.Periods, func(i, j int) bool {
+			return u.Periods[i].PeriodStart > u.Periods[j].PeriodStart
+		})
+		renters = append(renters, *u)
+	}
+
+	// Sort the renters by the data they store and the bandwidth they consumed
+	// in descending order.
+	sort.Slice(renters, func(i, j int) bool {
+		if renters[i].StoredBytes != renters[j].StoredBytes {
+			return renters[i].StoredBytes > renters[j].StoredBytes
+		}
+		bi := renters[i].BandwidthIn + renters[i].BandwidthOut
+		bj := renters[j].BandwidthIn + renters[j].BandwidthOut
+		if bi != bj {
+			return bi > bj
+		}
+		return renters[i].RenterKey < renters[j].RenterKey
+	})
+	return renters, nil
+}
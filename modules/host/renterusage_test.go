@@ -0,0 +1,243 @@
+package host
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestRenterUsage verifies that the host attributes stored data and bandwidth
+// to the renters which caused them.
+func TestRenterUsage(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ht.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	h := ht.host
+
+	// create two renters
+	pairs := make([]*renterHostPair, 2)
+	for i := range pairs {
+		pair, err := newRenterHostPairCustomHostTester(ht)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := pair.staticRenterMux.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		pairs[i] = pair
+	}
+	renter1, renter2 := pairs[0], pairs[1]
+
+	// the first renter stores a sector
+	if _, _, err := addRandomSector(renter1); err != nil {
+		t.Fatal(err)
+	}
+
+	// usage is a helper to get the usage of a renter
+	usage := func(pk types.SiaPublicKey) (modules.HostRenterUsage, error) {
+		renters, err := h.RenterUsage(false)
+		if err != nil {
+			return modules.HostRenterUsage{}, err
+		}
+		for _, u := range renters {
+			if u.RenterKey == pk.String() {
+				return u, nil
+			}
+		}
+		return modules.HostRenterUsage{}, errors.New("renter not found")
+	}
+
+	// both renters paid for a price table with their contract, so both
+	// should have consumed bandwidth
+	var u1, u2 modules.HostRenterUsage
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		u1, err = usage(renter1.staticRenterPK)
+		if err != nil {
+			return err
+		}
+		u2, err = usage(renter2.staticRenterPK)
+		if err != nil {
+			return err
+		}
+		if u1.BandwidthIn == 0 || u1.BandwidthOut == 0 || u2.BandwidthIn == 0 || u2.BandwidthOut == 0 {
+			return errors.New("bandwidth wasn't attributed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u1.Contracts != 1 || u1.StoredBytes != modules.SectorSize {
+		t.Fatal("wrong storage of first renter", u1.Contracts, u1.StoredBytes)
+	}
+	if u2.Contracts != 1 || u2.StoredBytes != 0 {
+		t.Fatal("wrong storage of second renter", u2.Contracts, u2.StoredBytes)
+	}
+
+	// the first renter should be sorted first
+	renters, err := h.RenterUsage(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(renters) != 2 || renters[0].RenterKey != renter1.staticRenterPK.String() {
+		t.Fatal("wrong renters", renters)
+	}
+
+	// the first renter funds its account and pays for an RPC with it, that
+	// bandwidth should only be attributed to the first renter
+	funding := h.InternalSettings().MaxEphemeralAccountBalance.Div64(10)
+	if _, err := renter1.managedFundEphemeralAccount(funding, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renter1.AccountBalance(false); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		u, err := usage(renter1.staticRenterPK)
+		if err != nil {
+			return err
+		}
+		if u.BandwidthIn <= u1.BandwidthIn || u.BandwidthOut <= u1.BandwidthOut {
+			return errors.New("bandwidth wasn't attributed to first renter")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := usage(renter2.staticRenterPK)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.BandwidthIn != u2.BandwidthIn || u.BandwidthOut != u2.BandwidthOut {
+		t.Fatal("bandwidth was attributed to the wrong renter")
+	}
+
+	// anonymized keys should be hashes
+	renters, err = h.RenterUsage(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := crypto.HashAll(h.publicKey, renter1.staticRenterPK.String()).String()
+	if len(renters) != 2 || renters[0].RenterKey != expected {
+		t.Fatal("keys weren't anonymized", renters)
+	}
+
+	// all the bandwidth was consumed within the current period
+	p := renters[0].Periods
+	if len(p) != 1 || p[0].BandwidthIn != renters[0].BandwidthIn || p[0].BandwidthOut != renters[0].BandwidthOut {
+		t.Fatal("wrong periods", p)
+	}
+
+	// the usage should be persisted with the host
+	h.mu.RLock()
+	persisted := h.persistData().RenterUsage
+	h.mu.RUnlock()
+	if len(persisted.Bandwidth) != 2 || len(persisted.Accounts) == 0 {
+		t.Fatal("usage wasn't persisted", persisted)
+	}
+}
+
+// TestRenterUsagePeriods is a unit test for tracking the renter usage per
+// period.
+func TestRenterUsagePeriods(t *testing.T) {
+	t.Parallel()
+
+	renter1 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	renter2 := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	account, _ := modules.NewAccountID()
+	stream := newIdentifiedStream(nil)
+	stream.managedSetAccount(account)
+
+	// bandwidth returns the sorted bandwidth of the usage.
+	bandwidth := func(ru *renterUsage) []renterBandwidth {
+		rbs := ru.callBandwidth()
+		sort.Slice(rbs, func(i, j int) bool {
+			if rbs[i].period != rbs[j].period {
+				return rbs[i].period < rbs[j].period
+			}
+			return rbs[i].key.String() < rbs[j].key.String()
+		})
+		return rbs
+	}
+
+	// track bandwidth in the first period, the second renter uses its account
+	ru := newRenterUsage()
+	ru.callSetBlockHeight(renterUsagePeriod - 1)
+	ru.callTrackBandwidth(renter1, 1, 2)
+	ru.callTrackAccount(account, renter2)
+	ru.callTrackStream(stream, 3, 4)
+
+	// track bandwidth of the first renter in the second period
+	ru.callSetBlockHeight(renterUsagePeriod)
+	ru.callTrackBandwidth(renter1, 5, 6)
+	rbs := bandwidth(ru)
+	if len(rbs) != 3 {
+		t.Fatal("wrong number of entries", rbs)
+	}
+	expected := []renterBandwidth{
+		{key: renter1, period: 0, in: 1, out: 2},
+		{key: renter2, period: 0, in: 3, out: 4},
+		{key: renter1, period: renterUsagePeriod, in: 5, out: 6},
+	}
+	for _, rb := range expected {
+		if !reflect.DeepEqual(ru.periods[rb.period][rb.key.String()], &rb) {
+			t.Fatal("wrong bandwidth", ru.periods[rb.period][rb.key.String()], rb)
+		}
+	}
+
+	// the usage should survive persisting it
+	b, err := json.Marshal(ru.callPersistData())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p renterUsagePersist
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	loaded := newRenterUsage()
+	loaded.callLoad(p, renterUsagePeriod)
+	if !reflect.DeepEqual(bandwidth(loaded), rbs) {
+		t.Fatal("loaded usage doesn't match", bandwidth(loaded), rbs)
+	}
+	if !reflect.DeepEqual(loaded.accounts, ru.accounts) {
+		t.Fatal("loaded accounts don't match", loaded.accounts, ru.accounts)
+	}
+
+	// once the first period expires, its bandwidth and the unused account are
+	// pruned
+	ru.callSetBlockHeight(renterUsageRetainedPeriods * renterUsagePeriod)
+	rbs = bandwidth(ru)
+	if len(rbs) != 1 || rbs[0].period != renterUsagePeriod {
+		t.Fatal("expired period wasn't pruned", rbs)
+	}
+	if len(ru.accounts) != 0 {
+		t.Fatal("account wasn't pruned", ru.accounts)
+	}
+	ru.callTrackStream(stream, 7, 8)
+	if rbs = bandwidth(ru); len(rbs) != 1 {
+		t.Fatal("bandwidth of pruned account was tracked", rbs)
+	}
+}
@@ -30,7 +30,7 @@ const (
 // managedRPCExecuteProgram handles incoming ExecuteProgram RPCs.
 func (h *Host) managedRPCExecuteProgram(stream siamux.Stream) error {
 	// read the price table
-	pt, err := h.staticReadPriceTableID(stream)
+	pt, err := h.staticReadPriceTableIDForRPC(stream, modules.RPCExecuteProgram)
 	if err != nil {
 		return errors.AddContext(err, "failed to read price table")
 	}
@@ -92,6 +92,9 @@ func (h *Host) managedRPCExecuteProgram(stream siamux.Stream) error {
 	// Get a snapshot of the storage obligation if required.
 	sos := ZeroStorageObligationSnapshot()
 	if program.RequiresSnapshot() {
+		if fcid == (types.FileContractID{}) {
+			return modules.ErrMDMMissingContract
+		}
 		sos, err = h.managedGetStorageObligationSnapshot(fcid)
 		if err != nil {
 			return errors.AddContext(err, fmt.Sprintf("failed to get storage obligation snapshot for contract %v", fcid))
@@ -200,6 +203,7 @@ func (h *Host) managedRPCExecuteProgram(stream siamux.Stream) error {
 		instructionSpecifier := program[numOutputs-1].Specifier
 		readInstruction := instructionSpecifier == modules.SpecifierReadOffset || instructionSpecifier == modules.SpecifierReadSector
 		updateRegistryInstruction := instructionSpecifier == modules.SpecifierUpdateRegistry
+		readRegistryInstruction := instructionSpecifier == modules.SpecifierReadRegistry || instructionSpecifier == modules.SpecifierReadRegistryEID
 		if (readInstruction || updateRegistryInstruction) && h.dependencies.Disrupt("CorruptMDMOutput") {
 			// Replace output with same amount of random data.
 			fastrand.Read(output.Output)
@@ -228,6 +232,11 @@ func (h *Host) managedRPCExecuteProgram(stream siamux.Stream) error {
 			time.Sleep(time.Second)
 		}
 
+		// Disrupt if the slow registry read dependency is set
+		if readRegistryInstruction && h.dependencies.Disrupt("SlowRegistryRead") {
+			time.Sleep(time.Second)
+		}
+
 		// Don't write contents of the buffer if the MDM recommends batching the
 		// output as long as the buffer stays under a threshold.
 		if output.Batch && buffer.Len() < modules.MDMMaxBatchBufferSize {
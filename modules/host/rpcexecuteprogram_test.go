@@ -721,6 +721,88 @@ func TestExecuteReadOffsetProgram(t *testing.T) {
 	t.Logf("Used bandwidth (read offset program): %v down, %v up", bandwidth.Downloaded(), bandwidth.Uploaded())
 }
 
+// TestExecuteRevisionProgram tests the managedRPCExecuteProgram with a
+// 'Revision' program.
+func TestExecuteRevisionProgram(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a blank host tester
+	rhp, err := newRenterHostPair(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := rhp.Close()
+		if err != nil {
+			t.Error(err)
+		}
+	}()
+	ht := rhp.staticHT
+
+	// fund an account.
+	pt := rhp.managedPriceTable()
+	his := ht.host.managedInternalSettings()
+	_, err = rhp.managedFundEphemeralAccount(his.MaxEphemeralAccountBalance.Add(pt.FundAccountCost), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// create the 'Revision' program.
+	pb := modules.NewProgramBuilder(pt, 0)
+	pb.AddRevisionInstruction()
+	program, data := pb.Program()
+	epr := modules.RPCExecuteProgramRequest{
+		FileContractID:    rhp.staticFCID,
+		Program:           program,
+		ProgramDataLength: uint64(len(data)),
+	}
+
+	// execute program.
+	budget := his.MaxEphemeralAccountBalance.Div64(2)
+	resps, _, err := rhp.managedExecuteProgram(epr, data, budget, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resps) != 1 {
+		t.Fatalf("expected 1 response but got %v", len(resps))
+	}
+	if resps[0].Error != nil {
+		t.Fatal(resps[0].Error)
+	}
+
+	// the output should decode to the latest revision txn of the contract
+	// including the signatures of both parties.
+	var response modules.MDMInstructionRevisionResponse
+	err = encoding.Unmarshal(resps[0].Output, &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	so, err := ht.host.managedGetStorageObligation(rhp.staticFCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := so.RevisionTransactionSet[len(so.RevisionTransactionSet)-1]
+	if !bytes.Equal(encoding.Marshal(response.RevisionTxn), encoding.Marshal(expected)) {
+		t.Fatal("output doesn't match the latest revision txn")
+	}
+	if len(response.RevisionTxn.FileContractRevisions) != 1 || response.RevisionTxn.FileContractRevisions[0].ParentID != rhp.staticFCID {
+		t.Fatal("wrong revision")
+	}
+	if len(response.RevisionTxn.TransactionSignatures) != 2 {
+		t.Fatal("expected 2 signatures but got", len(response.RevisionTxn.TransactionSignatures))
+	}
+
+	// executing the program without a contract should fail.
+	epr.FileContractID = types.FileContractID{}
+	_, _, err = rhp.managedExecuteProgram(epr, data, budget, false, true)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrMDMMissingContract.Error()) {
+		t.Fatal("expected ErrMDMMissingContract", err)
+	}
+}
+
 // TestVerifyExecuteProgramRevision is a unit test covering
 // verifyExecuteProgramRevision.
 func TestVerifyExecuteProgramRevision(t *testing.T) {
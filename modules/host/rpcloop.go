@@ -54,6 +54,10 @@ func (s *rpcSession) writeError(err error) error {
 // request and response. The loop terminates when the an RPC encounters an
 // error or the renter sends modules.RPCLoopExit.
 func (h *Host) managedRPCLoop(conn net.Conn) error {
+	// count the bandwidth of the session to attribute it to the renter
+	cc := newCountingConn(conn)
+	conn = cc
+
 	// read renter's half of key exchange
 	conn.SetDeadline(time.Now().Add(rpcRequestInterval))
 	var req modules.LoopKeyExchangeRequest
@@ -119,6 +123,26 @@ func (h *Host) managedRPCLoop(conn net.Conn) error {
 		}
 	}()
 
+	// attribute the bandwidth of the session to the renter once a contract
+	// was locked. This needs to happen before the contract is unlocked.
+	var renterKey types.SiaPublicKey
+	var renterKnown bool
+	var trackedIn, trackedOut uint64
+	trackBandwidth := func() {
+		if len(s.so.OriginTransactionSet) != 0 {
+			if key, err := s.so.renterKey(); err == nil {
+				renterKey, renterKnown = key, true
+			}
+		}
+		if !renterKnown {
+			return
+		}
+		in, out := cc.Counts()
+		h.staticRenterUsage.callTrackBandwidth(renterKey, in-trackedIn, out-trackedOut)
+		trackedIn, trackedOut = in, out
+	}
+	defer trackBandwidth()
+
 	// enter RPC loop
 	rpcs := map[types.Specifier]func(*rpcSession) error{
 		modules.RPCLoopLock:               h.managedRPCLoopLock,
@@ -140,9 +164,13 @@ func (h *Host) managedRPCLoop(conn net.Conn) error {
 		} else if id == modules.RPCLoopExit {
 			return nil
 		}
-		if rpcFn, ok := rpcs[id]; !ok {
+		rpcFn, ok := rpcs[id]
+		if !ok {
 			return errors.New("invalid or unknown RPC ID: " + id.String())
-		} else if err := rpcFn(s); err != nil {
+		}
+		err = rpcFn(s)
+		trackBandwidth()
+		if err != nil {
 			return extendErr("incoming RPC"+id.String()+" failed: ", err)
 		}
 	}
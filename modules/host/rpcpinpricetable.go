@@ -0,0 +1,71 @@
+package host
+
+import (
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/siamux"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errPriceTablePinNotSupported is returned if a renter tries to pin a
+	// price table for an rpc which doesn't support pinned price tables.
+	errPriceTablePinNotSupported = errors.New("price tables can't be pinned for that rpc")
+
+	// pinnableRPCs are the rpcs which accept pinned price tables. These are
+	// the rpcs which might outlive a regular price table.
+	pinnableRPCs = map[types.Specifier]struct{}{
+		modules.RPCExecuteProgram:       {},
+		modules.RPCRegistrySubscription: {},
+	}
+)
+
+// pinnedPriceTableCost returns the cost of pinning a price table for the given
+// validity. Pinning a price table costs as much as updating the price table
+// for every started rpcPriceGuaranteePeriod of the validity.
+func pinnedPriceTableCost(updatePriceTableCost types.Currency, validity time.Duration) types.Currency {
+	periods := uint64(validity / rpcPriceGuaranteePeriod)
+	if validity%rpcPriceGuaranteePeriod != 0 {
+		periods++
+	}
+	return updatePriceTableCost.Mul64(periods)
+}
+
+// managedRPCPinPriceTable returns a copy of the host's current rpc price table
+// that is valid for the validity requested by the renter, capped at the host's
+// MaxPinnedPriceTableValidity. The price table is tracked separately from the
+// regular price tables and is only accepted for the rpc it was pinned for.
+func (h *Host) managedRPCPinPriceTable(stream siamux.Stream) error {
+	// read the request
+	var req modules.RPCPinPriceTableRequest
+	err := modules.RPCRead(stream, &req)
+	if err != nil {
+		return errors.AddContext(err, "Failed to read pin price table request")
+	}
+	if _, pinnable := pinnableRPCs[req.RPC]; !pinnable {
+		return errors.AddContext(errPriceTablePinNotSupported, fmt.Sprint(req.RPC))
+	}
+
+	// determine the validity of the pinned price table
+	h.mu.RLock()
+	maxValidity := h.settings.MaxPinnedPriceTableValidity
+	h.mu.RUnlock()
+	validity := req.Validity
+	if validity > maxValidity {
+		validity = maxValidity
+	}
+	if validity < rpcPriceGuaranteePeriod {
+		validity = rpcPriceGuaranteePeriod
+	}
+
+	// price the pinned price table according to its validity
+	pt := *h.managedPriceTableForRenter()
+	pt.Validity = validity
+	pt.UpdatePriceTableCost = pinnedPriceTableCost(pt.UpdatePriceTableCost, validity)
+	return h.managedSendPriceTable(stream, pt, func(hpt *hostRPCPriceTable) {
+		h.staticPriceTables.managedTrackPinned(hpt, req.RPC)
+	})
+}
@@ -0,0 +1,157 @@
+package host
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// TestPinPriceTableRPC tests the PinPriceTableRPC by manually calling the RPC
+// handler.
+func TestPinPriceTableRPC(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// setup a host and renter pair with an emulated file contract between them
+	rhp, err := newRenterHostPair(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := rhp.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	host := rhp.staticHT.host
+
+	// Pinning a price table for an rpc that doesn't support it should fail.
+	_, err = runPinPriceTableRPC(rhp, modules.RPCFundAccount, 2*rpcPriceGuaranteePeriod)
+	if err == nil || !strings.Contains(err.Error(), errPriceTablePinNotSupported.Error()) {
+		t.Fatal("expected errPriceTablePinNotSupported", err)
+	}
+
+	// Pin a price table for a subscription.
+	validity := 2*rpcPriceGuaranteePeriod - time.Second
+	pt, err := runPinPriceTableRPC(rhp, modules.RPCRegistrySubscription, validity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Validity != validity {
+		t.Fatalf("expected validity %v but got %v", validity, pt.Validity)
+	}
+	current := host.staticPriceTables.managedCurrent()
+	if !pt.UpdatePriceTableCost.Equals(current.UpdatePriceTableCost.Mul64(2)) {
+		t.Fatal("pinned price table has wrong cost", pt.UpdatePriceTableCost)
+	}
+
+	// The price table should only be accepted for subscriptions.
+	if _, found := host.staticPriceTables.managedGet(pt.UID); found {
+		t.Fatal("pinned price table shouldn't be tracked as a regular price table")
+	}
+	if _, found := host.staticPriceTables.managedGetForRPC(pt.UID, modules.RPCRegistrySubscription); !found {
+		t.Fatal("pinned price table should be accepted for subscriptions")
+	}
+	if _, found := host.staticPriceTables.managedGetForRPC(pt.UID, modules.RPCExecuteProgram); found {
+		t.Fatal("pinned price table shouldn't be accepted for other rpcs")
+	}
+	if !host.managedPriceTableValidFor(pt, validity-time.Second) {
+		t.Fatal("pinned price table should be valid for the subscription")
+	}
+
+	// Using the pinned price table to fund the account should fail.
+	rhp.mu.Lock()
+	rhp.pt = pt
+	rhp.mu.Unlock()
+	_, err = rhp.managedFundEphemeralAccount(types.SiacoinPrecision, false)
+	if err == nil || !strings.Contains(err.Error(), modules.ErrPriceTableNotFound.Error()) {
+		t.Fatal("expected ErrPriceTableNotFound", err)
+	}
+
+	// A validity above the host's maximum should be capped.
+	maxValidity := host.InternalSettings().MaxPinnedPriceTableValidity
+	pt, err = runPinPriceTableRPC(rhp, modules.RPCExecuteProgram, 2*maxValidity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Validity != maxValidity {
+		t.Fatalf("expected validity %v but got %v", maxValidity, pt.Validity)
+	}
+}
+
+// TestPruneExpiredPinnedPriceTables verifies that expired pinned price tables
+// are pruned.
+func TestPruneExpiredPinnedPriceTables(t *testing.T) {
+	hp := &hostPrices{
+		guaranteed: make(map[modules.UniqueID]*hostRPCPriceTable),
+		pinned:     make(map[modules.UniqueID]*pinnedRPCPriceTable),
+		staticMinHeap: priceTableHeap{
+			heap: make([]*hostRPCPriceTable, 0),
+		},
+	}
+
+	// Pin an expired and a valid price table.
+	expired := &hostRPCPriceTable{modules.RPCPriceTable{UID: modules.UniqueID{1}, Validity: time.Minute}, time.Now().Add(-time.Hour)}
+	valid := &hostRPCPriceTable{modules.RPCPriceTable{UID: modules.UniqueID{2}, Validity: time.Hour}, time.Now()}
+	hp.managedTrackPinned(expired, modules.RPCRegistrySubscription)
+	hp.managedTrackPinned(valid, modules.RPCRegistrySubscription)
+
+	// Only the expired one should be pruned.
+	hp.managedPruneExpired()
+	if _, found := hp.managedGetForRPC(expired.UID, modules.RPCRegistrySubscription); found {
+		t.Fatal("expired price table should have been pruned")
+	}
+	if _, found := hp.managedGetForRPC(valid.UID, modules.RPCRegistrySubscription); !found {
+		t.Fatal("valid price table shouldn't have been pruned")
+	}
+}
+
+// runPinPriceTableRPC is a helper function that pins a price table for the
+// given rpc and validity and pays for it using the pair's contract.
+func runPinPriceTableRPC(rhp *renterHostPair, rpc types.Specifier, validity time.Duration) (_ *modules.RPCPriceTable, err error) {
+	stream := rhp.managedNewStream()
+	defer func() {
+		err = errors.Compose(err, stream.Close())
+	}()
+
+	// initiate the RPC
+	err = modules.RPCWriteAll(stream, modules.RPCPinPriceTable, modules.RPCPinPriceTableRequest{
+		RPC:      rpc,
+		Validity: validity,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// receive the price table response
+	var pt modules.RPCPriceTable
+	var update modules.RPCUpdatePriceTableResponse
+	err = modules.RPCRead(stream, &update)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(update.PriceTableJSON, &pt); err != nil {
+		return nil, err
+	}
+
+	// pay for the price table
+	err = rhp.managedPayByContract(stream, pt.UpdatePriceTableCost, rhp.staticAccountID)
+	if err != nil {
+		return nil, err
+	}
+
+	// await tracked response
+	var tracked modules.RPCTrackedPriceTableResponse
+	err = modules.RPCRead(stream, &tracked)
+	if err != nil {
+		return nil, err
+	}
+	return &pt, nil
+}
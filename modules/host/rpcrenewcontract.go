@@ -334,6 +334,10 @@ func verifyRenewedContract(so storageObligation, newContract types.FileContract,
 	if newContract.WindowStart > blockHeight+pt.MaxDuration {
 		return types.Currency{}, ErrLongDuration
 	}
+	// The renewed contract must not exceed pt.MaxContractSize.
+	if pt.MaxContractSize != 0 && newContract.FileSize > pt.MaxContractSize {
+		return types.Currency{}, ErrLargeContract
+	}
 
 	// ValidProofOutputs should have 2 outputs (renter + host) and missed
 	// outputs should have 3 (renter + host + void)
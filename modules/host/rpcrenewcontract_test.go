@@ -235,6 +235,14 @@ func TestVerifyRenewedContract(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Large contract
+	largePT := *pt
+	largePT.MaxContractSize = so.fileSize() - 1
+	_, err = verifyRenewedContract(so, fc, oldRevision, bh, is, unlockHash, &largePT, rpk, hpk, lockedCollateral)
+	if !errors.Contains(err, ErrLargeContract) {
+		t.Fatal(err)
+	}
+
 	// Bad output count #1
 	badFC = fc
 	badFC.ValidProofOutputs = nil
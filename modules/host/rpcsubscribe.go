@@ -199,7 +199,7 @@ func (h *Host) managedHandleExtendSubscriptionRequest(stream siamux.Stream, oldD
 	newDeadline := oldDeadline.Add(modules.SubscriptionPeriod)
 
 	// Read the price table
-	pt, err := h.staticReadPriceTableID(stream)
+	pt, err := h.staticReadPriceTableIDForRPC(stream, modules.RPCRegistrySubscription)
 	if err != nil {
 		return nil, time.Time{}, errors.AddContext(err, "failed to read price table")
 	}
@@ -265,9 +265,10 @@ func (h *Host) managedHandlePrepayBandwidth(stream siamux.Stream, info *subscrip
 }
 
 // managedPriceTableValidFor returns true if a price table is still valid for
-// the provided duration.
+// the provided duration. Price tables pinned for subscriptions are considered
+// as well.
 func (h *Host) managedPriceTableValidFor(pt *modules.RPCPriceTable, duration time.Duration) bool {
-	hpt, found := h.staticPriceTables.managedGet(pt.UID)
+	hpt, found := h.staticPriceTables.managedGetForRPC(pt.UID, modules.RPCRegistrySubscription)
 	if !found {
 		return false
 	}
@@ -355,7 +356,7 @@ func subscriptionResponseStream(info *subscriptionInfo, sm *siamux.SiaMux) (siam
 // managedRPCRegistrySubscribe handles the RegistrySubscribe rpc.
 func (h *Host) managedRPCRegistrySubscribe(stream siamux.Stream) (_ afterCloseFn, err error) {
 	// Read the price table
-	pt, err := h.staticReadPriceTableID(stream)
+	pt, err := h.staticReadPriceTableIDForRPC(stream, modules.RPCRegistrySubscription)
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to read price table")
 	}
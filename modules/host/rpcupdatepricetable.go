@@ -12,6 +12,7 @@ import (
 	"gitlab.com/NebulousLabs/fastrand"
 	"gitlab.com/NebulousLabs/siamux"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
 )
 
 var (
@@ -40,6 +41,14 @@ type (
 		modules.RPCPriceTable
 		creation time.Time
 	}
+
+	// pinnedRPCPriceTable is a price table that was pinned by a renter for a
+	// long running operation. It is only accepted for the rpc it was pinned
+	// for.
+	pinnedRPCPriceTable struct {
+		*hostRPCPriceTable
+		staticRPC types.Specifier
+	}
 )
 
 // Expiry returns the time at which the price table is considered to be expired
@@ -105,15 +114,30 @@ func (h *Host) managedPriceTableForRenter() *modules.RPCPriceTable {
 	// set the host's current blockheight, this allows the renter to create
 	// valid withdrawal messages in case it is not synced yet
 	pt.HostBlockHeight = h.BlockHeight()
+
+	// set the host's current time, this allows the renter to detect clock
+	// skew between itself and the host
+	now := time.Now()
+	if h.dependencies.Disrupt("SkewedClock") {
+		now = now.Add(time.Hour)
+	}
+	pt.HostTime = now.Unix()
 	return &pt
 }
 
 // managedRPCUpdatePriceTable returns a copy of the host's current rpc price
 // table. These prices are valid for the duration of the
 // rpcPriceGuaranteePeriod, which is defined by the price table's Expiry
-func (h *Host) managedRPCUpdatePriceTable(stream siamux.Stream) (err error) {
+func (h *Host) managedRPCUpdatePriceTable(stream siamux.Stream) error {
 	pt := *h.managedPriceTableForRenter()
+	return h.managedSendPriceTable(stream, pt, func(hpt *hostRPCPriceTable) {
+		h.staticPriceTables.managedTrack(hpt)
+	})
+}
 
+// managedSendPriceTable sends the given price table to the renter and tracks
+// it using the provided track function once the renter paid for it.
+func (h *Host) managedSendPriceTable(stream siamux.Stream, pt modules.RPCPriceTable, track func(*hostRPCPriceTable)) (err error) {
 	// json encode the price table
 	ptBytes, err := json.Marshal(pt)
 	if err != nil {
@@ -152,7 +176,7 @@ func (h *Host) managedRPCUpdatePriceTable(stream siamux.Stream) (err error) {
 
 	// after payment has been received, track the price table in the host's list
 	// of price tables and signal the renter we consider the price table valid
-	h.staticPriceTables.managedTrack(&hostRPCPriceTable{pt, time.Now()})
+	track(&hostRPCPriceTable{pt, time.Now()})
 	var tracked modules.RPCTrackedPriceTableResponse
 	if err = modules.RPCWrite(stream, tracked); err != nil {
 		return errors.AddContext(err, "Failed to signal renter we tracked the price table")
@@ -163,6 +187,12 @@ func (h *Host) managedRPCUpdatePriceTable(stream siamux.Stream) (err error) {
 // staticReadPriceTableID receives a stream and reads the price table's UID from
 // it, if it's a known UID we return the price table
 func (h *Host) staticReadPriceTableID(stream siamux.Stream) (*modules.RPCPriceTable, error) {
+	return h.staticReadPriceTableIDForRPC(stream, types.Specifier{})
+}
+
+// staticReadPriceTableIDForRPC works like staticReadPriceTableID but also
+// accepts price tables which were pinned for the given rpc.
+func (h *Host) staticReadPriceTableIDForRPC(stream siamux.Stream, rpc types.Specifier) (*modules.RPCPriceTable, error) {
 	// read the price table uid
 	var uid modules.UniqueID
 	err := modules.RPCRead(stream, &uid)
@@ -181,7 +211,7 @@ func (h *Host) staticReadPriceTableID(stream siamux.Stream) (*modules.RPCPriceTa
 
 	// check if we know the uid, if we do return it
 	var found bool
-	pt, found := h.staticPriceTables.managedGet(uid)
+	pt, found := h.staticPriceTables.managedGetForRPC(uid, rpc)
 	if !found {
 		return nil, errors.AddContext(modules.ErrPriceTableNotFound, fmt.Sprint(uid))
 	}
@@ -0,0 +1,24 @@
+package host
+
+import (
+	"fmt"
+
+	"go.sia.tech/siad/modules"
+)
+
+var _ modules.SelfTestRequirer = (*Host)(nil)
+
+// SelfTestRequirements returns the disk space that the storage folders of the
+// host still need. The storage folders are sparse files which only take up
+// space once sectors are stored in them.
+func (h *Host) SelfTestRequirements() modules.SelfTestRequirements {
+	var reqs modules.SelfTestRequirements
+	for _, sf := range h.StorageFolders() {
+		reqs.DiskSpace = append(reqs.DiskSpace, modules.DiskSpaceRequirement{
+			Dir:    sf.Path,
+			Bytes:  sf.CapacityRemaining,
+			Reason: fmt.Sprintf("storage folder %v", sf.Path),
+		})
+	}
+	return reqs
+}
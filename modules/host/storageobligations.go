@@ -167,6 +167,13 @@ type storageObligation struct {
 	OriginTransactionSet   []types.Transaction
 	RevisionTransactionSet []types.Transaction
 
+	// RenterKey identifies the renter of the storage obligation across
+	// renewals. The renter uses a new key for every contract, so a renewed
+	// obligation inherits the identity of the obligation it renewed. It's
+	// empty for obligations which weren't created by a renewal, in which case
+	// the renter's key of the contract identifies the renter.
+	RenterKey types.SiaPublicKey
+
 	// Variables indicating whether the critical transactions in a storage
 	// obligation have been confirmed on the blockchain.
 	ObligationStatus    storageObligationStatus
@@ -801,6 +808,10 @@ func (h *Host) updateFinancialMetricsAddSO(so storageObligation) {
 	h.financialMetrics.PotentialUploadBandwidthRevenue = h.financialMetrics.PotentialUploadBandwidthRevenue.Add(so.PotentialUploadRevenue)
 	h.financialMetrics.RiskedStorageCollateral = h.financialMetrics.RiskedStorageCollateral.Add(so.RiskedCollateral)
 	h.financialMetrics.TransactionFeeExpenses = h.financialMetrics.TransactionFeeExpenses.Add(so.TransactionFeesAdded)
+
+	// The locked storage collateral increased, the collateral budget might be
+	// running low.
+	h.updateCollateralBudgetAlert()
 }
 
 // updateFinancialMetricsAddSO updates the host's financial metrics for a
@@ -831,6 +842,7 @@ func (h *Host) updateFinancialMetricsUpdateSO(oldSO, newSO storageObligation) {
 	// The locked storage collateral was altered, we potentially want to
 	// unregister the insufficient collateral budget alert
 	h.tryUnregisterInsufficientCollateralBudgetAlert()
+	h.updateCollateralBudgetAlert()
 }
 
 // managedModifyStorageObligation will take an updated storage obligation along
@@ -1014,6 +1026,7 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 			// The locked storage collateral was altered, we potentially want to
 			// unregister the insufficient collateral budget alert
 			h.tryUnregisterInsufficientCollateralBudgetAlert()
+			h.updateCollateralBudgetAlert()
 		}
 	}
 	if sos == obligationSucceeded {
@@ -1045,6 +1058,7 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 		// The locked storage collateral was altered, we potentially want to
 		// unregister the insufficient collateral budget alert
 		h.tryUnregisterInsufficientCollateralBudgetAlert()
+		h.updateCollateralBudgetAlert()
 	}
 	if sos == obligationFailed {
 		// Remove the obligation statistics as potential risk and income.
@@ -1064,6 +1078,7 @@ func (h *Host) removeStorageObligation(so storageObligation, sos storageObligati
 		// The locked storage collateral was altered, we potentially want to
 		// unregister the insufficient collateral budget alert
 		h.tryUnregisterInsufficientCollateralBudgetAlert()
+		h.updateCollateralBudgetAlert()
 	}
 
 	// Update the storage obligation to be finalized but still in-database. The
@@ -1,7 +1,9 @@
 package host
 
 import (
+	"math"
 	"reflect"
+	"strings"
 	"testing"
 
 	"fmt"
@@ -520,3 +522,118 @@ func TestStorageObligationRequiresProof(t *testing.T) {
 		t.Fatal("obligation shouldn't require proof")
 	}
 }
+
+// TestCollateralBudgetAlert checks that the host registers an alert when the
+// locked collateral approaches the collateral budget and unregisters it when
+// the collateral is released again.
+func TestCollateralBudgetAlert(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	ht, err := newHostTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := ht.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set a collateral budget of 1000 SC.
+	is := ht.host.InternalSettings()
+	is.CollateralBudget = types.SiacoinPrecision.Mul64(1000)
+	err = ht.host.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// checkAlert checks the usage reported by the host and whether the alert
+	// is registered with the expected severity.
+	checkAlert := func(usage float64, severity modules.AlertSeverity, registered bool) {
+		t.Helper()
+		if u := ht.host.CollateralBudgetUsage(); math.Abs(u-usage) > 1e-9 {
+			t.Fatalf("expected usage %v but was %v", usage, u)
+		}
+		crit, _, warn, _ := ht.host.Alerts()
+		var found *modules.Alert
+		for _, alert := range append(crit, warn...) {
+			if strings.HasPrefix(alert.Msg, AlertMSGHostCollateralBudgetUsage) {
+				a := alert
+				found = &a
+			}
+		}
+		if !registered {
+			if found != nil {
+				t.Fatal("alert shouldn't be registered", *found)
+			}
+			return
+		}
+		if found == nil {
+			t.Fatal("alert should be registered")
+		}
+		if found.Severity != severity {
+			t.Fatalf("expected severity %v but got %v", severity, found.Severity)
+		}
+	}
+	checkAlert(0, 0, false)
+
+	// addSO adds a storage obligation which locks the given amount of
+	// collateral.
+	addSO := func(locked uint64) storageObligation {
+		t.Helper()
+		so, err := ht.newTesterStorageObligation()
+		if err != nil {
+			t.Fatal(err)
+		}
+		so.LockedCollateral = types.SiacoinPrecision.Mul64(locked)
+		ht.host.managedLockStorageObligation(so.id())
+		defer ht.host.managedUnlockStorageObligation(so.id())
+		err = ht.host.managedAddStorageObligation(so)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return so
+	}
+
+	// Lock 50% of the budget. No alert should be registered.
+	addSO(500)
+	checkAlert(0.5, 0, false)
+
+	// Lock 85% of the budget. A warning should be registered.
+	so2 := addSO(350)
+	checkAlert(0.85, modules.SeverityWarning, true)
+
+	// Lock 97% of the budget. The alert should become critical.
+	so3 := addSO(120)
+	checkAlert(0.97, modules.SeverityCritical, true)
+
+	// Remove the last obligation. The alert should be downgraded again.
+	ht.host.managedLockStorageObligation(so3.id())
+	err = ht.host.removeStorageObligation(so3, obligationSucceeded)
+	ht.host.managedUnlockStorageObligation(so3.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAlert(0.85, modules.SeverityWarning, true)
+
+	// Release some of the collateral of the second obligation as a renewal
+	// would. The alert should be unregistered.
+	so2.LockedCollateral = types.SiacoinPrecision.Mul64(100)
+	ht.host.managedLockStorageObligation(so2.id())
+	err = ht.host.managedModifyStorageObligation(so2, nil, nil)
+	ht.host.managedUnlockStorageObligation(so2.id())
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAlert(0.6, 0, false)
+
+	// Lowering the collateral budget should register the alert again.
+	is.CollateralBudget = types.SiacoinPrecision.Mul64(625)
+	err = ht.host.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkAlert(0.96, modules.SeverityCritical, true)
+}
@@ -304,6 +304,9 @@ func (h *Host) ProcessConsensusChange(cc modules.ConsensusChange) {
 	// change.
 	h.recentChange = cc.ID
 
+	// Move the renter usage to the period of the new height.
+	h.staticRenterUsage.callSetBlockHeight(h.blockHeight)
+
 	// Save the host.
 	err = h.saveSync()
 	if err != nil {
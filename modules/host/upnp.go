@@ -65,7 +65,9 @@ func (h *Host) managedLearnHostname() {
 	// address has changed.
 	if hostAcceptingContracts || hostContractCount > 0 {
 		h.log.Println("Host external IP address changed from", hostAutoAddress, "to", autoAddress, "- performing host announcement.")
-		err = h.managedAnnounce(autoAddress)
+		// The announcement is forced since routers without NAT loopback
+		// support would cause the reachability check to fail.
+		err = h.managedAnnounce(autoAddress, true)
 		if err != nil {
 			// Set h.announced to false, as the address has changed yet the
 			// renewed annoucement has failed.
@@ -74,6 +74,10 @@ const (
 	// MDMTimeHasSector is the time for executing a 'HasSector' instruction.
 	MDMTimeHasSector = 1
 
+	// MDMTimeHasSectorsBase is the base time for executing a 'HasSectors'
+	// instruction. Each looked up sector adds MDMTimeHasSector.
+	MDMTimeHasSectorsBase = 1
+
 	// MDMTimeInitProgram is the base time for initializing a program. `1`
 	// because no disk IO is involved.
 	MDMTimeInitProgram = 1
@@ -97,6 +101,10 @@ const (
 	// MDMTimeWriteSector is the time for executing a 'WriteSector' instruction.
 	MDMTimeWriteSector = 10000
 
+	// MDMTimeUpdateSector is the time for executing an 'UpdateSector'
+	// instruction.
+	MDMTimeUpdateSector = 10000
+
 	// MDMTimeUpdateRegistry is the time for executing an 'UpdateRegistry'
 	// instruction.
 	MDMTimeUpdateRegistry = 10000
@@ -117,6 +125,10 @@ const (
 	// instruction.
 	RPCIHasSectorLen = 8
 
+	// RPCIHasSectorsLen is the expected length of the 'Args' of a HasSectors
+	// instruction.
+	RPCIHasSectorsLen = 16
+
 	// RPCIReadSectorLen is the expected length of the 'Args' of a ReadSector
 	// instruction.
 	RPCIReadSectorLen = 25
@@ -133,6 +145,10 @@ const (
 	// instructon.
 	RPCISwapSectorLen = 17 // 2 uint64 offsets + merkle proof flag
 
+	// RPCIUpdateSectorLen is the expected length of the 'Args' of an
+	// UpdateSector instruction.
+	RPCIUpdateSectorLen = 17 // root offset + data offset + merkle proof flag
+
 	// RPCIUpdateRegistryLen is the expected length of the 'Args' of an
 	// UpdateRegistry instruction.
 	// tweakOffset + revisionOffset + signatureOffset + pubKeyOffset +
@@ -188,6 +204,9 @@ var (
 	// SpecifierHasSector is the specifier for the HasSector instruction.
 	SpecifierHasSector = InstructionSpecifier{'H', 'a', 's', 'S', 'e', 'c', 't', 'o', 'r'}
 
+	// SpecifierHasSectors is the specifier for the HasSectors instruction.
+	SpecifierHasSectors = InstructionSpecifier{'H', 'a', 's', 'S', 'e', 'c', 't', 'o', 'r', 's'}
+
 	// SpecifierReadOffset is the specifier for the ReadOffset instruction.
 	SpecifierReadOffset = InstructionSpecifier{'R', 'e', 'a', 'd', 'O', 'f', 'f', 's', 'e', 't'}
 
@@ -200,6 +219,9 @@ var (
 	// SpecifierSwapSector is the specifier for the SwapSector instruction.
 	SpecifierSwapSector = InstructionSpecifier{'S', 'w', 'a', 'p', 'S', 'e', 'c', 't', 'o', 'r'}
 
+	// SpecifierUpdateSector is the specifier for the UpdateSector instruction.
+	SpecifierUpdateSector = InstructionSpecifier{'U', 'p', 'd', 'a', 't', 'e', 'S', 'e', 'c', 't', 'o', 'r'}
+
 	// SpecifierUpdateRegistry is the specifier for the UpdateRegistry
 	// instruction.
 	SpecifierUpdateRegistry = InstructionSpecifier{'U', 'p', 'd', 'a', 't', 'e', 'R', 'e', 'g', 'i', 's', 't', 'r', 'y'}
@@ -224,6 +246,11 @@ var (
 	// collateral budget of an MDM program is not sufficient to execute the next
 	// instruction.
 	ErrMDMInsufficientCollateralBudget = errors.New("remaining collateral budget is insufficient")
+
+	// ErrMDMMissingContract is the error returned if a program contains an
+	// instruction which requires a file contract but the program isn't
+	// associated with one.
+	ErrMDMMissingContract = errors.New("instruction requires a file contract but the program isn't associated with one")
 )
 
 type (
@@ -302,6 +329,12 @@ func MDMHasSectorCost(pt *RPCPriceTable) types.Currency {
 	return cost
 }
 
+// MDMHasSectorsCost is the cost of executing a 'HasSectors' instruction. Every
+// sector is charged like a single 'HasSector' instruction.
+func MDMHasSectorsCost(pt *RPCPriceTable, numSectors uint64) types.Currency {
+	return pt.HasSectorBaseCost.Mul64(numSectors)
+}
+
 // MDMReadCost is the cost of executing a 'Read' instruction. It is defined as:
 // 'readBaseCost' + 'readLengthCost' * `readLength`
 func MDMReadCost(pt *RPCPriceTable, readLength uint64) types.Currency {
@@ -320,6 +353,13 @@ func MDMSwapSectorCost(pt *RPCPriceTable) types.Currency {
 	return pt.SwapSectorCost
 }
 
+// MDMUpdateSectorCost is the cost of executing an 'UpdateSector' instruction.
+// Since the size of the contract doesn't change, only the cost for writing the
+// new data is charged.
+func MDMUpdateSectorCost(pt *RPCPriceTable) types.Currency {
+	return MDMWriteCost(pt, SectorSize)
+}
+
 // V154MDMUpdateRegistryCost is the cost of executing a 'UpdateRegistry'
 // instruction in host versions 1.5.4 and below.
 func V154MDMUpdateRegistryCost(pt *RPCPriceTable) (_, _ types.Currency) {
@@ -423,6 +463,12 @@ func MDMHasSectorMemory() uint64 {
 	return 0 // 'HasSector' doesn't hold on to any memory beyond the lifetime of the instruction.
 }
 
+// MDMHasSectorsMemory returns the additional memory consumption of a
+// 'HasSectors' instruction.
+func MDMHasSectorsMemory() uint64 {
+	return 0 // 'HasSectors' doesn't hold on to any memory beyond the lifetime of the instruction.
+}
+
 // MDMReadMemory returns the additional memory consumption of a 'Read' instruction.
 func MDMReadMemory() uint64 {
 	return 0 // 'Read' doesn't hold on to any memory beyond the lifetime of the instruction.
@@ -440,6 +486,12 @@ func MDMSwapSectorMemory() uint64 {
 	return 0 // 'SwapSector' doesn't hold on to any memory beyond the lifetime of the instruction.
 }
 
+// MDMUpdateSectorMemory returns the additional memory consumption of an
+// 'UpdateSector' instruction.
+func MDMUpdateSectorMemory() uint64 {
+	return SectorSize // A full sector is added to the program's memory until the program is finalized.
+}
+
 // MDMUpdateRegistryMemory returns the additional memory consumption of a
 // 'UpdateRegistry' instruction.
 func MDMUpdateRegistryMemory() uint64 {
@@ -471,6 +523,12 @@ func MDMDropSectorsTime(numSectorsDropped uint64) uint64 {
 	return MDMTimeDropSectorsBase + MDMTimeDropSingleSector*numSectorsDropped
 }
 
+// MDMHasSectorsTime returns the time for a 'HasSectors' instruction given
+// 'numSectors'.
+func MDMHasSectorsTime(numSectors uint64) uint64 {
+	return MDMTimeHasSectorsBase + MDMTimeHasSector*numSectors
+}
+
 // MDMAppendCollateral returns the additional collateral a 'Append' instruction
 // requires the host to put up.
 func MDMAppendCollateral(pt *RPCPriceTable) types.Currency {
@@ -489,6 +547,12 @@ func MDMHasSectorCollateral() types.Currency {
 	return types.ZeroCurrency
 }
 
+// MDMHasSectorsCollateral returns the additional collateral a 'HasSectors'
+// instruction requires the host to put up.
+func MDMHasSectorsCollateral() types.Currency {
+	return types.ZeroCurrency
+}
+
 // MDMReadCollateral returns the additional collateral a 'Read' instruction
 // requires the host to put up.
 func MDMReadCollateral() types.Currency {
@@ -507,6 +571,12 @@ func MDMSwapSectorCollateral() types.Currency {
 	return types.ZeroCurrency
 }
 
+// MDMUpdateSectorCollateral returns the additional collateral an
+// 'UpdateSector' instruction requires the host to put up.
+func MDMUpdateSectorCollateral() types.Currency {
+	return types.ZeroCurrency // The size of the contract doesn't change.
+}
+
 // MDMUpdateRegistryCollateral returns the additional collateral a
 // 'UpdateRegistry' instruction requires the host to put up.
 func MDMUpdateRegistryCollateral() types.Currency {
@@ -528,11 +598,14 @@ func (p Program) ReadOnly() bool {
 		case SpecifierDropSectors:
 			return false
 		case SpecifierHasSector:
+		case SpecifierHasSectors:
 		case SpecifierReadOffset:
 		case SpecifierReadSector:
 		case SpecifierRevision:
 		case SpecifierSwapSector:
 			return false
+		case SpecifierUpdateSector:
+			return false
 		case SpecifierUpdateRegistry:
 			// considered read-only cause it doesn't update a contract
 		case SpecifierReadRegistry:
@@ -556,6 +629,7 @@ func (p Program) RequiresSnapshot() bool {
 		case SpecifierDropSectors:
 			return true
 		case SpecifierHasSector:
+		case SpecifierHasSectors:
 		case SpecifierReadOffset:
 			return true
 		case SpecifierReadSector:
@@ -563,6 +637,8 @@ func (p Program) RequiresSnapshot() bool {
 			return true
 		case SpecifierSwapSector:
 			return true
+		case SpecifierUpdateSector:
+			return true
 		case SpecifierUpdateRegistry:
 		case SpecifierReadRegistry:
 		case SpecifierReadRegistryEID:
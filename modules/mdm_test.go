@@ -226,6 +226,11 @@ func TestRequiresReadonlyAndSnapshot(t *testing.T) {
 			false,
 			true,
 		},
+		{
+			SpecifierUpdateSector,
+			false,
+			true,
+		},
 	}
 
 	for i, test := range tests {
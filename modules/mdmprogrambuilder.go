@@ -102,6 +102,30 @@ func (pb *ProgramBuilder) AddHasSectorInstruction(merkleRoot crypto.Hash) {
 	pb.addInstruction(collateral, cost, types.ZeroCurrency, memory, time)
 }
 
+// AddHasSectorsInstruction adds a HasSectors instruction to the program which
+// looks up multiple sectors at once.
+func (pb *ProgramBuilder) AddHasSectorsInstruction(merkleRoots []crypto.Hash) {
+	// Compute the argument offsets.
+	numSectorsOffset := uint64(pb.programData.Len())
+	merkleRootsOffset := numSectorsOffset + 8
+	// Extend the programData.
+	numSectors := uint64(len(merkleRoots))
+	binary.Write(pb.programData, binary.LittleEndian, numSectors)
+	for _, root := range merkleRoots {
+		binary.Write(pb.programData, binary.LittleEndian, root[:])
+	}
+	// Create the instruction.
+	i := NewHasSectorsInstruction(numSectorsOffset, merkleRootsOffset)
+	// Append instruction
+	pb.program = append(pb.program, i)
+	// Update cost, collateral and memory usage.
+	collateral := MDMHasSectorsCollateral()
+	cost := MDMHasSectorsCost(pb.staticPT, numSectors)
+	memory := MDMHasSectorsMemory()
+	time := MDMHasSectorsTime(numSectors)
+	pb.addInstruction(collateral, cost, types.ZeroCurrency, memory, time)
+}
+
 // AddReadOffsetInstruction adds a ReadOffset instruction to the program.
 func (pb *ProgramBuilder) AddReadOffsetInstruction(length, offset uint64, merkleProof bool) {
 	// Compute the argument offsets.
@@ -181,6 +205,31 @@ func (pb *ProgramBuilder) AddSwapSectorInstruction(sector1Idx, sector2Idx uint64
 	pb.readonly = false
 }
 
+// AddUpdateSectorInstruction adds an UpdateSector instruction to the program.
+func (pb *ProgramBuilder) AddUpdateSectorInstruction(root crypto.Hash, data []byte, merkleProof bool) error {
+	if uint64(len(data)) != SectorSize {
+		return fmt.Errorf("expected updated data to have size %v but was %v", SectorSize, len(data))
+	}
+	// Compute the argument offsets.
+	rootOffset := uint64(pb.programData.Len())
+	dataOffset := rootOffset + crypto.HashSize
+	// Extend the programData.
+	binary.Write(pb.programData, binary.LittleEndian, root[:])
+	binary.Write(pb.programData, binary.LittleEndian, data)
+	// Create the instruction.
+	i := NewUpdateSectorInstruction(rootOffset, dataOffset, merkleProof)
+	// Append instruction
+	pb.program = append(pb.program, i)
+	// Update cost, collateral and memory usage.
+	collateral := MDMUpdateSectorCollateral()
+	cost := MDMUpdateSectorCost(pb.staticPT)
+	memory := MDMUpdateSectorMemory()
+	time := uint64(MDMTimeUpdateSector)
+	pb.addInstruction(collateral, cost, types.ZeroCurrency, memory, time)
+	pb.readonly = false
+	return nil
+}
+
 // V156AddUpdateRegistryInstruction adds an UpdateRegistry instruction to the
 // program.
 func (pb *ProgramBuilder) V156AddUpdateRegistryInstruction(spk types.SiaPublicKey, rv SignedRegistryValue) error {
@@ -546,6 +595,17 @@ func NewHasSectorInstruction(merkleRootOffset uint64) Instruction {
 	return i
 }
 
+// NewHasSectorsInstruction creates a modules.Instruction from arguments.
+func NewHasSectorsInstruction(numSectorsOffset, merkleRootsOffset uint64) Instruction {
+	i := Instruction{
+		Specifier: SpecifierHasSectors,
+		Args:      make([]byte, RPCIHasSectorsLen),
+	}
+	binary.LittleEndian.PutUint64(i.Args[:8], numSectorsOffset)
+	binary.LittleEndian.PutUint64(i.Args[8:16], merkleRootsOffset)
+	return i
+}
+
 // NewReadOffsetInstruction creates a modules.Instruction from arguments.
 func NewReadOffsetInstruction(lengthOffset, offsetOffset uint64, merkleProof bool) Instruction {
 	i := Instruction{
@@ -589,6 +649,20 @@ func NewSwapSectorInstruction(sector1Offset, sector2Offset uint64, merkleProof b
 	return i
 }
 
+// NewUpdateSectorInstruction creates a modules.Instruction from arguments.
+func NewUpdateSectorInstruction(rootOffset, dataOffset uint64, merkleProof bool) Instruction {
+	i := Instruction{
+		Specifier: SpecifierUpdateSector,
+		Args:      make([]byte, RPCIUpdateSectorLen),
+	}
+	binary.LittleEndian.PutUint64(i.Args[:8], rootOffset)
+	binary.LittleEndian.PutUint64(i.Args[8:16], dataOffset)
+	if merkleProof {
+		i.Args[16] = 1
+	}
+	return i
+}
+
 // NewRevisionInstruction creates a modules.Instruction from arguments.
 func NewRevisionInstruction(merkleRootOffset uint64) Instruction {
 	return Instruction{
@@ -23,7 +23,7 @@ import (
 const (
 	// RHPVersion is the version of the Sia renter-host protocol currently
 	// implemented by the host module.
-	RHPVersion = "1.5.7"
+	RHPVersion = "1.5.9"
 
 	// MinimumSupportedRenterHostProtocolVersion is the minimum version of Sia
 	// that supports the currently used version of the renter-host protocol.
@@ -169,6 +169,11 @@ var (
 	// announcement is not a type of signature that is recognized.
 	ErrAnnUnrecognizedSignature = errors.New("the signature provided in the host announcement is not recognized")
 
+	// ErrMaxContractSize is returned by the host if a renter tries to add data
+	// to a contract which would grow the contract beyond the host's maximum
+	// contract size. Renters should consider such a contract to be full.
+	ErrMaxContractSize = errors.New("contract would exceed the host's max contract size")
+
 	// ErrMaxVirtualSectors is returned when a sector cannot be added because
 	// the maximum number of virtual sectors for that sector id already exist.
 	ErrMaxVirtualSectors = errors.New("sector collides with a physical sector that already has the maximum allowed number of virtual sectors")
@@ -300,6 +305,10 @@ type (
 		Collateral    types.Currency `json:"collateral"`
 		MaxCollateral types.Currency `json:"maxcollateral"`
 
+		// MaxContractSize is the maximum size in bytes the host allows a
+		// single contract to grow to. 0 means that there is no limit.
+		MaxContractSize uint64 `json:"maxcontractsize"`
+
 		// ContractPrice is the number of coins that the renter needs to pay to
 		// the host just to open a file contract with them. Generally, the price
 		// is only to cover the siacoin fees that the host will suffer when
@@ -935,6 +944,12 @@ func IsOOSErr(err error) bool {
 	return false
 }
 
+// IsContractFullErr is a helper function to determine whether an error from a
+// host is indicating that the contract reached the host's max contract size.
+func IsContractFullErr(err error) bool {
+	return err != nil && strings.Contains(err.Error(), ErrMaxContractSize.Error())
+}
+
 // IsContractNotRecognizedErr is a helper function to determine whether an error
 // from a host is a indicating that they do not recognize a contract that the
 // renter is updating.
@@ -1116,8 +1131,11 @@ func RPCStopSubscription(stream siamux.Stream) (err error) {
 	return nil
 }
 
-// RPCSubscribeToRVs subscribes to the given publickey/tweak pairs.
-func RPCSubscribeToRVs(stream siamux.Stream, requests []RPCRegistrySubscriptionRequest) ([]RPCRegistrySubscriptionNotificationEntryUpdate, error) {
+// RPCSubscribeToRVs subscribes to the given publickey/tweak pairs. The initial
+// values returned by the host are verified and returned by the ids of the
+// entries they belong to. Entries that the host doesn't know are missing from
+// the returned map.
+func RPCSubscribeToRVs(stream siamux.Stream, requests []RPCRegistrySubscriptionRequest) (map[RegistryEntryID]SignedRegistryValue, error) {
 	// Send the type of the request.
 	buf := bytes.NewBuffer(nil)
 	err := RPCWrite(buf, SubscriptionRequestSubscribe)
@@ -1144,31 +1162,61 @@ func RPCSubscribeToRVs(stream siamux.Stream, requests []RPCRegistrySubscriptionR
 	if len(rvs) > len(requests) {
 		return nil, fmt.Errorf("host returned more rvs than we subscribed to %v > %v", len(rvs), len(requests))
 	}
-	// Verify response. The rvs should be returned in the same order as
-	// requested so we start by verifying against the first request and work our
-	// way through to the last one. If not all rvs are verified successfully
-	// after running out of requests, something is wrong.
-	left := rvs
-	var initialNotifications []RPCRegistrySubscriptionNotificationEntryUpdate
+	return verifySubscribedRVs(requests, rvs)
+}
+
+// verifySubscribedRVs matches the rvs returned by the host when subscribing to
+// the requests it belongs to and verifies its signature. The host is not
+// required to return the rvs in the same order as requested. Since an rv
+// doesn't contain the public key it was signed with, it is verified against
+// all requests with the same tweak. If any rv can't be matched to a request,
+// or if multiple rvs match the same request, an error listing all of these rvs
+// is returned.
+func verifySubscribedRVs(requests []RPCRegistrySubscriptionRequest, rvs []SignedRegistryValue) (map[RegistryEntryID]SignedRegistryValue, error) {
+	requestsByTweak := make(map[crypto.Hash][]RPCRegistrySubscriptionRequest)
 	for _, req := range requests {
-		if len(left) == 0 {
-			return initialNotifications, nil // all returned notifications were verified
+		requestsByTweak[req.Tweak] = append(requestsByTweak[req.Tweak], req)
+	}
+	values := make(map[RegistryEntryID]SignedRegistryValue, len(rvs))
+	var failures []string
+	for i, rv := range rvs {
+		candidates := requestsByTweak[rv.Tweak]
+		var matched, duplicate bool
+		for _, req := range candidates {
+			if rv.Verify(req.PubKey.ToPublicKey()) != nil {
+				continue
+			}
+			sid := DeriveRegistryEntryID(req.PubKey, rv.Tweak)
+			if _, exists := values[sid]; exists {
+				duplicate = true
+				continue
+			}
+			values[sid] = rv
+			matched = true
+			break
+		}
+		if matched {
+			continue
 		}
-		rv := left[0]
-		err = rv.Verify(req.PubKey.ToPublicKey())
-		if err != nil {
-			continue // try next request
+		var failure string
+		switch {
+		case duplicate:
+			failure = fmt.Sprintf("rv %v with tweak %v is a duplicate", i, rv.Tweak)
+		case len(candidates) == 0:
+			failure = fmt.Sprintf("rv %v with tweak %v wasn't requested", i, rv.Tweak)
+		default:
+			var ids []string
+			for _, req := range candidates {
+				ids = append(ids, crypto.Hash(DeriveRegistryEntryID(req.PubKey, rv.Tweak)).String())
+			}
+			failure = fmt.Sprintf("rv %v with tweak %v doesn't match entry %v", i, rv.Tweak, strings.Join(ids, " or "))
 		}
-		left = left[1:]
-		initialNotifications = append(initialNotifications, RPCRegistrySubscriptionNotificationEntryUpdate{
-			Entry:  rv,
-			PubKey: req.PubKey,
-		})
+		failures = append(failures, failure)
 	}
-	if len(left) > 0 {
-		return nil, fmt.Errorf("failed to verify %v of %v rvs", len(left), len(rvs))
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("failed to verify %v of %v rvs: %v", len(failures), len(rvs), strings.Join(failures, "; "))
 	}
-	return initialNotifications, nil
+	return values, nil
 }
 
 // RPCUnsubscribeFromRVs unsubscribes from the given publickey/tweak pairs.
@@ -2,9 +2,12 @@ package modules
 
 import (
 	"bytes"
+	"reflect"
+	"strings"
 	"testing"
 
 	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/types"
 )
@@ -159,3 +162,76 @@ func TestRenterPayoutsPreTax(t *testing.T) {
 		t.Fatal("Negative currency returned for host collateral", hostCollateral)
 	}
 }
+
+// TestVerifySubscribedRVs is a unit test for verifySubscribedRVs.
+func TestVerifySubscribedRVs(t *testing.T) {
+	t.Parallel()
+
+	// Create 3 entries. The first two share the same tweak.
+	newEntry := func(tweak crypto.Hash) (RPCRegistrySubscriptionRequest, SignedRegistryValue, crypto.SecretKey) {
+		sk, pk := crypto.GenerateKeyPair()
+		spk := types.Ed25519PublicKey(pk)
+		rv := NewRegistryValue(tweak, fastrand.Bytes(10), 1, RegistryTypeWithoutPubkey).Sign(sk)
+		return RPCRegistrySubscriptionRequest{PubKey: spk, Tweak: tweak}, rv, sk
+	}
+	req1, rv1, _ := newEntry(crypto.Hash{1})
+	req2, rv2, sk2 := newEntry(crypto.Hash{1})
+	req3, rv3, _ := newEntry(crypto.Hash{2})
+	requests := []RPCRegistrySubscriptionRequest{req1, req2, req3}
+	sid1 := DeriveRegistryEntryID(req1.PubKey, req1.Tweak)
+	sid2 := DeriveRegistryEntryID(req2.PubKey, req2.Tweak)
+	sid3 := DeriveRegistryEntryID(req3.PubKey, req3.Tweak)
+
+	// Values in order.
+	values, err := verifySubscribedRVs(requests, []SignedRegistryValue{rv1, rv2, rv3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[RegistryEntryID]SignedRegistryValue{sid1: rv1, sid2: rv2, sid3: rv3}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatal("wrong values", values)
+	}
+
+	// Values out of order.
+	values, err = verifySubscribedRVs(requests, []SignedRegistryValue{rv3, rv2, rv1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, expected) {
+		t.Fatal("wrong values", values)
+	}
+
+	// Missing entries.
+	values, err = verifySubscribedRVs(requests, []SignedRegistryValue{rv2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(values, map[RegistryEntryID]SignedRegistryValue{sid2: rv2}) {
+		t.Fatal("wrong values", values)
+	}
+	values, err = verifySubscribedRVs(requests, nil)
+	if err != nil || len(values) != 0 {
+		t.Fatal("expected no values", values, err)
+	}
+
+	// A value signed with the wrong key. The error should name the entry.
+	wrongKey := NewRegistryValue(req3.Tweak, rv3.Data, rv3.Revision, rv3.Type).Sign(sk2)
+	_, err = verifySubscribedRVs(requests, []SignedRegistryValue{rv1, wrongKey})
+	if err == nil || !strings.Contains(err.Error(), crypto.Hash(sid3).String()) || !strings.Contains(err.Error(), "1 of 2") {
+		t.Fatal("expected verification to fail for entry 3", err)
+	}
+
+	// A value for a tweak that wasn't requested.
+	_, _, sk := newEntry(crypto.Hash{3})
+	unrequested := NewRegistryValue(crypto.Hash{3}, rv3.Data, rv3.Revision, rv3.Type).Sign(sk)
+	_, err = verifySubscribedRVs(requests, []SignedRegistryValue{unrequested})
+	if err == nil || !strings.Contains(err.Error(), "wasn't requested") {
+		t.Fatal("expected verification to fail for unrequested value", err)
+	}
+
+	// The same value twice.
+	_, err = verifySubscribedRVs(requests, []SignedRegistryValue{rv1, rv1})
+	if err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Fatal("expected verification to fail for duplicate value", err)
+	}
+}
@@ -52,6 +52,26 @@ type (
 	RegistryEntryType uint8
 )
 
+const (
+	// RegistryReadConsistencyFastest returns the highest revision among the
+	// first responses without waiting for the slower hosts.
+	RegistryReadConsistencyFastest = RegistryReadConsistency("fastest")
+	// RegistryReadConsistencyQuorum waits for a fraction of the queried hosts
+	// to respond and returns the highest revision among them.
+	RegistryReadConsistencyQuorum = RegistryReadConsistency("quorum")
+	// RegistryReadConsistencyAll waits for all of the queried hosts to
+	// respond, or for the timeout, and returns the highest revision among
+	// them.
+	RegistryReadConsistencyAll = RegistryReadConsistency("all")
+)
+
+type (
+	// RegistryReadConsistency describes how many hosts a registry read waits
+	// for before returning a value. Waiting for more hosts increases the
+	// latency of the read but makes it less likely to return a stale value.
+	RegistryReadConsistency string
+)
+
 var (
 	// ErrInsufficientWork is returned when the revision numbers of two entries
 	// match but the new entry doesn't have enough pow to replace the existing
@@ -73,6 +93,9 @@ var (
 	// ErrUnknownRegistryEntryType is returned when an entry has an unknown
 	// entry type.
 	ErrUnknownRegistryEntryType = errors.New("unknown entry type")
+	// ErrInvalidRegistryReadConsistency is returned when an unknown read
+	// consistency is specified.
+	ErrInvalidRegistryReadConsistency = errors.New("invalid registry read consistency")
 )
 
 // RoundRegistrySize is a helper to correctly round up the size of a registry to
@@ -86,6 +109,19 @@ func RoundRegistrySize(size uint64) uint64 {
 	return nUnits * smallestRegUnit
 }
 
+// ParseRegistryReadConsistency parses a registry read consistency. An empty
+// string defaults to RegistryReadConsistencyFastest.
+func ParseRegistryReadConsistency(s string) (RegistryReadConsistency, error) {
+	switch c := RegistryReadConsistency(s); c {
+	case "":
+		return RegistryReadConsistencyFastest, nil
+	case RegistryReadConsistencyFastest, RegistryReadConsistencyQuorum, RegistryReadConsistencyAll:
+		return c, nil
+	default:
+		return "", errors.AddContext(ErrInvalidRegistryReadConsistency, s)
+	}
+}
+
 // RegistryValue is a value that can be registered on a host's registry.
 type RegistryValue struct {
 	Tweak    crypto.Hash
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"time"
 
@@ -64,11 +65,19 @@ var (
 	// manually by the user.
 	ErrDownloadCancelled = errors.New("download was cancelled")
 
+	// ErrDownloadGroupNotFound is returned when a download group doesn't
+	// exist.
+	ErrDownloadGroupNotFound = errors.New("download group not found")
+
 	// ErrNotEnoughWorkersInWorkerPool is an error that is returned whenever an
 	// operation expects a certain number of workers but there aren't that many
 	// available.
 	ErrNotEnoughWorkersInWorkerPool = errors.New("not enough workers in worker pool")
 
+	// ErrRenterNotSynced is returned by renter operations that can't make
+	// progress until the consensus set is synced.
+	ErrRenterNotSynced = errors.New("renter is waiting for the consensus set to sync")
+
 	// PriceEstimationScope is the number of hosts that get queried by the
 	// renter when providing price estimates. Especially for the 'Standard'
 	// variable, there should be congruence with the number of contracts being
@@ -159,6 +168,14 @@ const (
 	DefaultFilePerm = 0644
 )
 
+// StuckOutcomePending, StuckOutcomeSuccess and StuckOutcomeFailure are the
+// possible outcomes of a stuck repair attempt.
+const (
+	StuckOutcomePending = "pending"
+	StuckOutcomeSuccess = "success"
+	StuckOutcomeFailure = "failure"
+)
+
 // String returns the string value for the FilterMode
 func (fm FilterMode) String() string {
 	switch fm {
@@ -351,6 +368,7 @@ type DirectoryInfo struct {
 	AggregateMaxHealthPercentage float64   `json:"aggregatemaxhealthpercentage"`
 	AggregateMinRedundancy       float64   `json:"aggregateminredundancy"`
 	AggregateMostRecentModTime   time.Time `json:"aggregatemostrecentmodtime"`
+	AggregateNumAbandonedChunks  uint64    `json:"aggregatenumabandonedchunks"`
 	AggregateNumFiles            uint64    `json:"aggregatenumfiles"`
 	AggregateNumStuckChunks      uint64    `json:"aggregatenumstuckchunks"`
 	AggregateNumSubDirs          uint64    `json:"aggregatenumsubdirs"`
@@ -368,6 +386,7 @@ type DirectoryInfo struct {
 	MinRedundancy       float64     `json:"minredundancy"`
 	DirMode             os.FileMode `json:"mode,siamismatch"` // Field is called DirMode for fuse compatibility
 	MostRecentModTime   time.Time   `json:"mostrecentmodtime"`
+	NumAbandonedChunks  uint64      `json:"numabandonedchunks"`
 	NumFiles            uint64      `json:"numfiles"`
 	NumStuckChunks      uint64      `json:"numstuckchunks"`
 	NumSubDirs          uint64      `json:"numsubdirs"`
@@ -401,8 +420,9 @@ func (d DirectoryInfo) Sys() interface{} { return nil }
 // download.
 type DownloadInfo struct {
 	Destination     string  `json:"destination"`     // The destination of the download.
-	DestinationType string  `json:"destinationtype"` // Can be "file", "memory buffer", or "http stream".
+	DestinationType string  `json:"destinationtype"` // Can be "file", "memory buffer", "http stream" or "file and http stream".
 	Length          uint64  `json:"length"`          // The length requested for the download.
+	Group           string  `json:"group"`           // The download group of the download.
 	Offset          uint64  `json:"offset"`          // The offset within the siafile requested for the download.
 	SiaPath         SiaPath `json:"siapath"`         // The siapath of the file used for the download.
 
@@ -413,6 +433,23 @@ type DownloadInfo struct {
 	StartTime            time.Time `json:"starttime"`            // The time when the download was started.
 	StartTimeUnix        int64     `json:"starttimeunix"`        // The time when the download was started in unix format.
 	TotalDataTransferred uint64    `json:"totaldatatransferred"` // Total amount of data transferred, including negotiation, etc.
+	Warnings             []string  `json:"warnings"`             // Destinations that failed without failing the download.
+}
+
+// DownloadGroupInfo provides information about a group of downloads which are
+// tracked together.
+type DownloadGroupInfo struct {
+	Name     string `json:"name"`     // The name of the group.
+	Finished bool   `json:"finished"` // Whether all downloads of the group have completed.
+
+	FilesCompleted uint64 `json:"filescompleted"` // Number of downloads that completed successfully.
+	FilesFailed    uint64 `json:"filesfailed"`    // Number of downloads that failed.
+	FilesTotal     uint64 `json:"filestotal"`     // Number of downloads in the group.
+
+	BytesReceived uint64 `json:"bytesreceived"` // Amount of data confirmed and decoded.
+	BytesTotal    uint64 `json:"bytestotal"`    // The total length requested by the downloads.
+
+	Errors []string `json:"errors"` // The errors of the failed downloads.
 }
 
 // FileUploadParams contains the information used by the Renter to upload a
@@ -435,35 +472,49 @@ type FileUploadParams struct {
 	CipherKey crypto.CipherKey
 }
 
+// ChunkInfo provides debugging information about a single chunk of a file.
+type ChunkInfo struct {
+	Index             uint64    `json:"index"`
+	Abandoned         bool      `json:"abandoned"`
+	LastRepairFailure time.Time `json:"lastrepairfailure"`
+	RepairFailures    uint8     `json:"repairfailures"`
+	Stuck             bool      `json:"stuck"`
+}
+
 // FileInfo provides information about a file.
 type FileInfo struct {
-	AccessTime       time.Time         `json:"accesstime"`
-	Available        bool              `json:"available"`
-	ChangeTime       time.Time         `json:"changetime"`
-	CipherType       string            `json:"ciphertype"`
-	CreateTime       time.Time         `json:"createtime"`
-	Expiration       types.BlockHeight `json:"expiration"`
-	Filesize         uint64            `json:"filesize"`
-	Health           float64           `json:"health"`
-	LocalPath        string            `json:"localpath"`
-	MaxHealth        float64           `json:"maxhealth"`
-	MaxHealthPercent float64           `json:"maxhealthpercent"`
-	ModificationTime time.Time         `json:"modtime,siamismatch"` // Stays as 'modtime' in json for compatibility
-	FileMode         os.FileMode       `json:"mode,siamismatch"`    // Field is called FileMode for fuse compatibility
-	NumStuckChunks   uint64            `json:"numstuckchunks"`
-	OnDisk           bool              `json:"ondisk"`
-	Recoverable      bool              `json:"recoverable"`
-	Redundancy       float64           `json:"redundancy"`
-	Renewing         bool              `json:"renewing"`
-	RepairBytes      uint64            `json:"repairbytes"`
-	Skylinks         []string          `json:"skylinks"`
-	SiaPath          SiaPath           `json:"siapath"`
-	Stuck            bool              `json:"stuck"`
-	StuckBytes       uint64            `json:"stuckbytes"`
-	StuckHealth      float64           `json:"stuckhealth"`
-	UID              uint64            `json:"uid"`
-	UploadedBytes    uint64            `json:"uploadedbytes"`
-	UploadProgress   float64           `json:"uploadprogress"`
+	AccessTime         time.Time         `json:"accesstime"`
+	Available          bool              `json:"available"`
+	ChangeTime         time.Time         `json:"changetime"`
+	CipherType         string            `json:"ciphertype"`
+	ConversionProgress float64           `json:"conversionprogress"`
+	Converting         bool              `json:"converting"`
+	CreateTime         time.Time         `json:"createtime"`
+	Expiration         types.BlockHeight `json:"expiration"`
+	Filesize           uint64            `json:"filesize"`
+	Health             float64           `json:"health"`
+	LocalPath          string            `json:"localpath"`
+	MaxHealth          float64           `json:"maxhealth"`
+	MaxHealthPercent   float64           `json:"maxhealthpercent"`
+	ModificationTime   time.Time         `json:"modtime,siamismatch"` // Stays as 'modtime' in json for compatibility
+	FileMode           os.FileMode       `json:"mode,siamismatch"`    // Field is called FileMode for fuse compatibility
+	NumAbandonedChunks uint64            `json:"numabandonedchunks"`
+	NumStuckChunks     uint64            `json:"numstuckchunks"`
+	OnDisk             bool              `json:"ondisk"`
+	QueuedOffline      bool              `json:"queuedoffline"`
+	Recoverable        bool              `json:"recoverable"`
+	Redundancy         float64           `json:"redundancy"`
+	Renewing           bool              `json:"renewing"`
+	RepairBytes        uint64            `json:"repairbytes"`
+	Resilient          bool              `json:"resilient"`
+	Skylinks           []string          `json:"skylinks"`
+	SiaPath            SiaPath           `json:"siapath"`
+	Stuck              bool              `json:"stuck"`
+	StuckBytes         uint64            `json:"stuckbytes"`
+	StuckHealth        float64           `json:"stuckhealth"`
+	UID                uint64            `json:"uid"`
+	UploadedBytes      uint64            `json:"uploadedbytes"`
+	UploadProgress     float64           `json:"uploadprogress"`
 }
 
 // Name implements os.FileInfo.
@@ -611,6 +662,14 @@ type RenterPriceEstimation struct {
 	UploadTerabyte types.Currency `json:"uploadterabyte"`
 }
 
+// RenterUploadCostEstimate contains the estimated cost of uploading a file and
+// the confidence interval of the estimate.
+type RenterUploadCostEstimate struct {
+	Estimate types.Currency `json:"estimate"`
+	Min      types.Currency `json:"min"`
+	Max      types.Currency `json:"max"`
+}
+
 // RenterSettings control the behavior of the Renter.
 type RenterSettings struct {
 	Allowance        Allowance     `json:"allowance"`
@@ -618,6 +677,167 @@ type RenterSettings struct {
 	MaxUploadSpeed   int64         `json:"maxuploadspeed"`
 	MaxDownloadSpeed int64         `json:"maxdownloadspeed"`
 	UploadsStatus    UploadsStatus `json:"uploadsstatus"`
+
+	// UploadStagingSize is the maximum number of bytes of upload sources
+	// that are staged on local disk. A size of 0 disables upload staging.
+	UploadStagingSize uint64 `json:"uploadstagingsize"`
+
+	// MaxRepairAttempts is the number of consecutive failed repair attempts
+	// after which a chunk is marked as stuck.
+	MaxRepairAttempts uint64 `json:"maxrepairattempts"`
+
+	// MaxStuckRepairAttempts is the number of consecutive failed repairs of
+	// a chunk by the stuck loop after which the chunk is abandoned.
+	MaxStuckRepairAttempts uint64 `json:"maxstuckrepairattempts"`
+
+	// MetadataBatchWindow is the window within which the metadata writes of
+	// pieces added to the same file are batched. DisableMetadataBatching
+	// persists every added piece right away.
+	MetadataBatchWindow     time.Duration `json:"metadatabatchwindow"`
+	DisableMetadataBatching bool          `json:"disablemetadatabatching"`
+
+	// SkipUnavailableLocalFiles causes the repair code to skip chunks which
+	// can't be repaired because their source file is unavailable instead of
+	// marking them as stuck.
+	SkipUnavailableLocalFiles bool `json:"skipunavailablelocalfiles"`
+
+	// MaxConcurrentRepairs is the maximum number of chunks which are fetched
+	// and repaired concurrently. 0 means that the limit is derived from the
+	// number of workers.
+	MaxConcurrentRepairs uint64 `json:"maxconcurrentrepairs"`
+
+	// DiskLatencyThrottle limits the number of concurrent repairs while the
+	// writes to the renter's persist directory are slow to keep the renter
+	// responsive.
+	DiskLatencyThrottle bool `json:"disklatencythrottle"`
+
+	// MaxSubscriptionsPerWorker is the maximum number of registry entries a
+	// worker keeps active subscriptions for. Once the limit is reached, the
+	// least recently used subscriptions are evicted. 0 means that the default
+	// limit is used.
+	MaxSubscriptionsPerWorker uint64 `json:"maxsubscriptionsperworker"`
+
+	// MaxRepairDownloadSpeed and MaxRepairUploadSpeed limit the bandwidth used
+	// by repairs in addition to MaxDownloadSpeed and MaxUploadSpeed. That way
+	// repairs can't slow down user initiated downloads and uploads. 0 means
+	// that repairs are not limited separately.
+	MaxRepairDownloadSpeed int64 `json:"maxrepairdownloadspeed"`
+	MaxRepairUploadSpeed   int64 `json:"maxrepairuploadspeed"`
+}
+
+// MetadataBatchStats contains information about the batching of siafile
+// metadata writes.
+type MetadataBatchStats struct {
+	BatchedPieces uint64        `json:"batchedpieces"`
+	FailedFlushes uint64        `json:"failedflushes"`
+	Flushes       uint64        `json:"flushes"`
+	PendingFiles  uint64        `json:"pendingfiles"`
+	Window        time.Duration `json:"window"`
+}
+
+// UploadStagingStatus contains information about the renter's upload staging
+// directory.
+type UploadStagingStatus struct {
+	Capacity uint64 `json:"capacity"`
+	NumFiles uint64 `json:"numfiles"`
+	Used     uint64 `json:"used"`
+}
+
+// RepairStreamReport describes the outcome of repairing a file from a stream
+// of its content.
+type RepairStreamReport struct {
+	// Repaired is the number of chunks which were repaired using the data
+	// from the stream.
+	Repaired uint64 `json:"repaired"`
+
+	// Skipped is the number of chunks which didn't need to be repaired or
+	// which were already being repaired.
+	Skipped uint64 `json:"skipped"`
+
+	// Rejected contains the indices of the chunks whose data didn't match
+	// the previously uploaded pieces.
+	Rejected []uint64 `json:"rejected"`
+
+	// Failed contains the indices of the chunks which matched but couldn't
+	// be uploaded.
+	Failed []uint64 `json:"failed"`
+}
+
+// RenterSyncStatus contains information about the progress of the initial
+// consensus sync that some renter operations are blocked on.
+type RenterSyncStatus struct {
+	Synced          bool              `json:"synced"`
+	Height          types.BlockHeight `json:"height"`
+	EstimatedHeight types.BlockHeight `json:"estimatedheight"`
+
+	// Progress is the estimated sync progress in percent.
+	Progress float64 `json:"progress"`
+
+	// ETA is the estimated remaining time until the consensus set is synced.
+	// It is 0 if there is not enough information to make an estimate yet.
+	ETA time.Duration `json:"eta"`
+}
+
+// RenterDiskLatency contains the latency measurements of the most recent
+// writes to the renter's persist directory.
+type RenterDiskLatency struct {
+	// Samples is the number of recent writes the measurements are based on.
+	Samples int `json:"samples"`
+
+	// P50, P95 and Max are the median, 95th percentile and maximum latency
+	// of the recent writes.
+	P50 time.Duration `json:"p50"`
+	P95 time.Duration `json:"p95"`
+	Max time.Duration `json:"max"`
+
+	// Threshold is the 95th percentile latency above which the persist
+	// directory is considered slow.
+	Threshold time.Duration `json:"threshold"`
+
+	// Slow indicates whether the persist directory is considered slow and
+	// Throttling whether the repairs are throttled because of it.
+	Slow       bool `json:"slow"`
+	Throttling bool `json:"throttling"`
+}
+
+// RenterDirHealthSummary is a summary of the health of a directory and all of
+// its subdirectories. It is read from the directory's cached metadata which is
+// updated whenever the directory is bubbled.
+type RenterDirHealthSummary struct {
+	// WorstChunkHealth is the worst health of any chunk within the
+	// directory's subtree, stuck or not stuck.
+	WorstChunkHealth float64 `json:"worstchunkhealth"`
+
+	// NumChunksNeedingRepair is the number of chunks within the directory's
+	// subtree which are not stuck but need to be repaired.
+	NumChunksNeedingRepair uint64 `json:"numchunksneedingrepair"`
+
+	// NumStuckChunks is the number of stuck chunks within the directory's
+	// subtree.
+	NumStuckChunks uint64 `json:"numstuckchunks"`
+}
+
+// String returns a human readable description of the sync status, e.g.
+// "waiting for sync (84%, ~22 min)".
+func (rss RenterSyncStatus) String() string {
+	if rss.Synced {
+		return "synced"
+	}
+	// Round down to avoid displaying 100% before being synced.
+	progress := math.Floor(rss.Progress)
+	if rss.ETA <= 0 {
+		return fmt.Sprintf("waiting for sync (%.0f%%)", progress)
+	}
+	var eta string
+	switch {
+	case rss.ETA < time.Minute:
+		eta = "<1 min"
+	case rss.ETA < 2*time.Hour:
+		eta = fmt.Sprintf("~%d min", int(rss.ETA.Round(time.Minute).Minutes()))
+	default:
+		eta = fmt.Sprintf("~%d h", int(rss.ETA.Round(time.Hour).Hours()))
+	}
+	return fmt.Sprintf("waiting for sync (%.0f%%, %v)", progress, eta)
 }
 
 // UploadsStatus contains information about the Renter's Uploads
@@ -721,6 +941,10 @@ type RenterContract struct {
 	// Utility contains utility information about the renter.
 	Utility ContractUtility
 
+	// NumRoots is the number of sector roots the renter tracks for the
+	// contract.
+	NumRoots uint64
+
 	// TotalCost indicates the amount of money that the renter spent and/or
 	// locked up while forming a contract. This includes fees, and includes
 	// funds which were allocated (but not necessarily committed) to spend on
@@ -755,6 +979,14 @@ type SpendingDetails struct {
 	UploadSpending      types.Currency
 }
 
+// Total returns the sum of all spending categories.
+func (x RenterAccountSpending) Total() types.Currency {
+	return x.Downloads.Add(x.RegistryReads).Add(x.RegistryWrites).
+		Add(x.RepairDownloads).Add(x.RepairUploads).
+		Add(x.SnapshotDownloads).Add(x.SnapshotUploads).
+		Add(x.Subscriptions).Add(x.Uploads)
+}
+
 // MaintenanceSpending is a helper struct that contains a breakdown of costs
 // related to the maintenance (a.k.a upkeep) of the RHP3 protocol. This includes
 // the costs to sync the account balance, update the price table, etc.
@@ -789,6 +1021,37 @@ func (rc *RenterContract) Size() uint64 {
 	return size
 }
 
+// MaxRoots returns the number of sector roots the contract can hold given the
+// host's collateral price. It is the number of sectors covered by the
+// contract's size plus the number of sectors the collateral the host has left
+// in the contract can back until the contract ends.
+func (rc *RenterContract) MaxRoots(collateral types.Currency, blockHeight types.BlockHeight) uint64 {
+	stored := rc.Size() / SectorSize
+	if len(rc.Transaction.FileContractRevisions) == 0 || blockHeight >= rc.EndHeight {
+		return stored
+	}
+	duration := uint64(rc.EndHeight - blockHeight)
+	sectorCollateral := collateral.Mul64(SectorSize).Mul64(duration)
+	if sectorCollateral.IsZero() {
+		return math.MaxUint64
+	}
+	hostCollateral := rc.Transaction.FileContractRevisions[0].MissedHostPayout()
+	backed, err := hostCollateral.Div(sectorCollateral).Uint64()
+	if err != nil || backed > math.MaxUint64-stored {
+		return math.MaxUint64
+	}
+	return stored + backed
+}
+
+// Utilization returns the percentage of maxRoots that is used by the
+// contract's sector roots.
+func (rc *RenterContract) Utilization(maxRoots uint64) float64 {
+	if maxRoots == 0 {
+		return 0
+	}
+	return 100 * float64(rc.NumRoots) / float64(maxRoots)
+}
+
 // ContractorSpending contains the metrics about how much the Contractor has
 // spent during the current billing period.
 type ContractorSpending struct {
@@ -855,6 +1118,189 @@ type ContractorChurnStatus struct {
 	MaxPeriodChurn uint64 `json:"maxperiodchurn"`
 }
 
+// RepairMetrics contains counters about the progress of the renter's repair
+// pipeline.
+type RepairMetrics struct {
+	// ChunksDiscovered is the number of chunks which were found to be in need
+	// of repair while building the upload heap.
+	ChunksDiscovered uint64 `json:"chunksdiscovered"`
+	// ChunksRepaired is the number of chunks which were uploaded successfully.
+	ChunksRepaired uint64 `json:"chunksrepaired"`
+	// ChunksStuck is the number of chunks which were marked as stuck.
+	ChunksStuck uint64 `json:"chunksstuck"`
+
+	// RepairBytesUploaded is the number of bytes uploaded for chunks which
+	// already had pieces on the network. NewBytesUploaded is the number of
+	// bytes uploaded for chunks which didn't.
+	RepairBytesUploaded uint64 `json:"repairbytesuploaded"`
+	NewBytesUploaded    uint64 `json:"newbytesuploaded"`
+}
+
+// RenterRepairMetrics contains the renter's repair metrics since the renter was
+// started as well as the metrics of the recent past.
+type RenterRepairMetrics struct {
+	Total        RepairMetrics `json:"total"`
+	Recent       RepairMetrics `json:"recent"`
+	RecentWindow time.Duration `json:"recentwindow"`
+	StartTime    time.Time     `json:"starttime"`
+
+	DirectoryHeap DirectoryHeapStatus `json:"directoryheap"`
+
+	// ConcurrentRepairs is the number of chunks that are currently being
+	// fetched and repaired and ConcurrentRepairsLimit the maximum number of
+	// chunks that are allowed to be fetched and repaired concurrently.
+	ConcurrentRepairs      int `json:"concurrentrepairs"`
+	ConcurrentRepairsLimit int `json:"concurrentrepairslimit"`
+
+	// FailedPushes counts the chunks which couldn't be pushed onto the upload
+	// heap since the renter was started by the reason of the failure.
+	FailedPushes map[string]uint64 `json:"failedpushes"`
+}
+
+// DirectoryHeapStatus contains information about the resets and
+// re-initializations of the directory heap the repair loop uses to find
+// directories in need of repair.
+type DirectoryHeapStatus struct {
+	// Len is the number of directories currently in the heap.
+	Len int `json:"len"`
+
+	// Resets is the number of times the heap was cleared. ManualResets is the
+	// number of those resets which were requested by the user.
+	Resets       uint64    `json:"resets"`
+	ManualResets uint64    `json:"manualresets"`
+	LastReset    time.Time `json:"lastreset"`
+
+	// Initializations is the number of times the heap was initialized from
+	// the root directory of the filesystem.
+	Initializations    uint64    `json:"initializations"`
+	LastInitialization time.Time `json:"lastinitialization"`
+}
+
+// RepairLoopPhase describes what the renter's repair loop is currently doing.
+type RepairLoopPhase string
+
+const (
+	// RepairLoopPhaseActive means that the repair loop is adding chunks to
+	// the upload heap or repairing them.
+	RepairLoopPhaseActive RepairLoopPhase = "active"
+
+	// RepairLoopPhaseBlocked means that the repair loop is waiting for the
+	// consensus set to be synced or for the renter to be online.
+	RepairLoopPhaseBlocked RepairLoopPhase = "blocked"
+
+	// RepairLoopPhasePaused means that the repairs were paused by the user.
+	RepairLoopPhasePaused RepairLoopPhase = "paused"
+
+	// RepairLoopPhaseSleeping means that the filesystem is healthy and that
+	// the repair loop is waiting for new uploads or repairs.
+	RepairLoopPhaseSleeping RepairLoopPhase = "sleeping"
+)
+
+// RenterRepairStatus describes whether the renter is repairing, what it is
+// repairing and how far along the repairs are.
+type RenterRepairStatus struct {
+	// Phase is the current phase of the repair loop and BlockedReason
+	// explains why the loop is blocked if the phase is
+	// RepairLoopPhaseBlocked.
+	Phase         RepairLoopPhase `json:"phase"`
+	BlockedReason string          `json:"blockedreason,omitempty"`
+
+	// PhaseStart is the time at which the repair loop entered its current
+	// phase.
+	PhaseStart time.Time `json:"phasestart"`
+
+	// LastCompletedCycle is the time at which the repair loop last finished
+	// repairing the chunks it added to the upload heap. It is zero if no
+	// cycle was completed since startup.
+	LastCompletedCycle time.Time `json:"lastcompletedcycle"`
+
+	// Directories are the directories at the top of the directory heap,
+	// sorted by their repair priority.
+	Directories []RepairStatusDirectory `json:"directories"`
+
+	// UploadHeap summarizes the chunks in the upload heap.
+	UploadHeap UploadHeapSummary `json:"uploadheap"`
+
+	// RepairingChunks are the chunks which are currently being repaired.
+	RepairingChunks []RepairStatusChunk `json:"repairingchunks"`
+}
+
+// RepairStatusDirectory is a directory within the directory heap of the
+// repair loop.
+type RepairStatusDirectory struct {
+	SiaPath SiaPath `json:"siapath"`
+
+	// Health is the health the directory is prioritized by. Remote
+	// indicates that the health is the health of remote files.
+	Health float64 `json:"health"`
+	Remote bool    `json:"remote"`
+
+	// Explored indicates whether the subdirectories of the directory were
+	// already added to the heap.
+	Explored bool `json:"explored"`
+}
+
+// UploadHeapSummary contains the number of chunks in the upload heap.
+type UploadHeapSummary struct {
+	// Len is the number of chunks waiting in the heap. Stuck and Unstuck
+	// break that number down by whether the chunks are stuck.
+	Len     int `json:"len"`
+	Stuck   int `json:"stuck"`
+	Unstuck int `json:"unstuck"`
+
+	// Repairing is the number of chunks which were popped from the heap and
+	// are currently being repaired.
+	Repairing int `json:"repairing"`
+}
+
+// RepairStatusChunk is a chunk which is currently being repaired.
+type RepairStatusChunk struct {
+	SiaPath SiaPath `json:"siapath"`
+	Index   uint64  `json:"index"`
+	Health  float64 `json:"health"`
+	Stuck   bool    `json:"stuck"`
+
+	// PiecesCompleted is the number of pieces which were uploaded so far and
+	// PiecesNeeded the number of pieces the chunk needs to be fully repaired.
+	PiecesCompleted int `json:"piecescompleted"`
+	PiecesNeeded    int `json:"piecesneeded"`
+}
+
+// StuckCursorEntry describes the recent stuck repair attempts of a single
+// file which the stuck loop remembers across restarts.
+type StuckCursorEntry struct {
+	SiaPath SiaPath `json:"siapath"`
+
+	// NumStuckChunks is the number of stuck chunks the file had when it was
+	// last attempted or repaired.
+	NumStuckChunks uint64 `json:"numstuckchunks"`
+
+	Attempts  uint64 `json:"attempts"`
+	Successes uint64 `json:"successes"`
+	Failures  uint64 `json:"failures"`
+
+	// LastAttempt is the time of the last attempt and LastOutcome its outcome.
+	// The outcome is one of StuckOutcomePending, StuckOutcomeSuccess and
+	// StuckOutcomeFailure.
+	LastAttempt time.Time `json:"lastattempt"`
+	LastOutcome string    `json:"lastoutcome"`
+}
+
+// RenterBandwidth contains the current upload and download rates of the
+// renter's workers as well as the total number of bytes transferred since the
+// renter was started.
+type RenterBandwidth struct {
+	// DownloadRate and UploadRate are the current rates in bytes per second.
+	DownloadRate uint64 `json:"downloadrate"`
+	UploadRate   uint64 `json:"uploadrate"`
+
+	// TotalDownloaded and TotalUploaded are the total number of bytes
+	// transferred since StartTime.
+	TotalDownloaded uint64    `json:"totaldownloaded"`
+	TotalUploaded   uint64    `json:"totaluploaded"`
+	StartTime       time.Time `json:"starttime"`
+}
+
 // UploadedBackup contains metadata about an uploaded backup.
 type UploadedBackup struct {
 	Name           string
@@ -862,17 +1308,22 @@ type UploadedBackup struct {
 	CreationDate   types.Timestamp
 	Size           uint64 // size of snapshot .sia file
 	UploadProgress float64
+
+	// RemainingChunks is the number of chunks of the snapshot which still
+	// need to be uploaded before the snapshot is safe.
+	RemainingChunks uint64
 }
 
 type (
 	// WorkerPoolStatus contains information about the status of the workerPool
 	// and the workers
 	WorkerPoolStatus struct {
-		NumWorkers               int            `json:"numworkers"`
-		TotalDownloadCoolDown    int            `json:"totaldownloadcooldown"`
-		TotalMaintenanceCoolDown int            `json:"totalmaintenancecooldown"`
-		TotalUploadCoolDown      int            `json:"totaluploadcooldown"`
-		Workers                  []WorkerStatus `json:"workers"`
+		DisabledWorkers          []types.SiaPublicKey `json:"disabledworkers"`
+		NumWorkers               int                  `json:"numworkers"`
+		TotalDownloadCoolDown    int                  `json:"totaldownloadcooldown"`
+		TotalMaintenanceCoolDown int                  `json:"totalmaintenancecooldown"`
+		TotalUploadCoolDown      int                  `json:"totaluploadcooldown"`
+		Workers                  []WorkerStatus       `json:"workers"`
 	}
 
 	// WorkerStatus contains information about the status of a worker
@@ -881,20 +1332,25 @@ type (
 		ContractID      types.FileContractID `json:"contractid"`
 		ContractUtility ContractUtility      `json:"contractutility"`
 		HostPubKey      types.SiaPublicKey   `json:"hostpubkey"`
+		Version         string               `json:"version"`
 
 		// Download status information
-		DownloadCoolDownError string        `json:"downloadcooldownerror"`
-		DownloadCoolDownTime  time.Duration `json:"downloadcooldowntime"`
-		DownloadOnCoolDown    bool          `json:"downloadoncooldown"`
-		DownloadQueueSize     int           `json:"downloadqueuesize"`
-		DownloadTerminated    bool          `json:"downloadterminated"`
+		BytesDownloaded          uint64        `json:"bytesdownloaded"`
+		DownloadCoolDownError    string        `json:"downloadcooldownerror"`
+		DownloadCoolDownTime     time.Duration `json:"downloadcooldowntime"`
+		DownloadOnCoolDown       bool          `json:"downloadoncooldown"`
+		DownloadQueueSize        int           `json:"downloadqueuesize"`
+		DownloadSpeedBytesPerSec float64       `json:"downloadspeedbytespersec"`
+		DownloadTerminated       bool          `json:"downloadterminated"`
 
 		// Upload status information
-		UploadCoolDownError string        `json:"uploadcooldownerror"`
-		UploadCoolDownTime  time.Duration `json:"uploadcooldowntime"`
-		UploadOnCoolDown    bool          `json:"uploadoncooldown"`
-		UploadQueueSize     int           `json:"uploadqueuesize"`
-		UploadTerminated    bool          `json:"uploadterminated"`
+		BytesUploaded          uint64        `json:"bytesuploaded"`
+		UploadCoolDownError    string        `json:"uploadcooldownerror"`
+		UploadCoolDownTime     time.Duration `json:"uploadcooldowntime"`
+		UploadOnCoolDown       bool          `json:"uploadoncooldown"`
+		UploadQueueSize        int           `json:"uploadqueuesize"`
+		UploadSpeedBytesPerSec float64       `json:"uploadspeedbytespersec"`
+		UploadTerminated       bool          `json:"uploadterminated"`
 
 		// Maintenance Cooldown information
 		MaintenanceOnCooldown    bool          `json:"maintenanceoncooldown"`
@@ -908,6 +1364,9 @@ type (
 		// PriceTable information
 		PriceTableStatus WorkerPriceTableStatus `json:"pricetablestatus"`
 
+		// Host settings information
+		HostSettingsStatus WorkerHostSettingsStatus `json:"hostsettingsstatus"`
+
 		// Job Queues
 		DownloadSnapshotJobQueueSize int `json:"downloadsnapshotjobqueuesize"`
 		UploadSnapshotJobQueueSize   int `json:"uploadsnapshotjobqueuesize"`
@@ -923,6 +1382,25 @@ type (
 
 		// UpdateRegistry Job information
 		UpdateRegistryJobsStatus WorkerUpdateRegistryJobStatus `json:"updateregistryjobsstatus"`
+
+		// Registry subscription information
+		SubscriptionStatus WorkerSubscriptionStatus `json:"subscriptionstatus"`
+	}
+
+	// WorkerSubscriptionStatus contains information about the worker's
+	// registry subscription session.
+	WorkerSubscriptionStatus struct {
+		Active            bool           `json:"active"`
+		NumSubscriptions  uint64         `json:"numsubscriptions"`
+		NumEvicted        uint64         `json:"numevicted"`
+		MaxSubscriptions  uint64         `json:"maxsubscriptions"`
+		BudgetRemaining   types.Currency `json:"budgetremaining"`
+		Spent             types.Currency `json:"spent"`
+		TimeUntilDeadline time.Duration  `json:"timeuntildeadline"`
+		Backoff           time.Duration  `json:"backoff"`
+
+		RecentErr     string    `json:"recenterr"`
+		RecentErrTime time.Time `json:"recenterrtime"`
 	}
 
 	// WorkerGenericJobsStatus contains the common information for worker jobs.
@@ -940,11 +1418,76 @@ type (
 		AvailableBalance types.Currency `json:"availablebalance"`
 		NegativeBalance  types.Currency `json:"negativebalance"`
 
+		// PredictedSpendingRate is the predicted spending of the account in
+		// hastings per second. RefillTarget is the balance the account is
+		// refilled to based on the prediction and RefillSize is the amount of
+		// the most recent refill.
+		PredictedSpendingRate types.Currency `json:"predictedspendingrate"`
+		RefillSize            types.Currency `json:"refillsize"`
+		RefillTarget          types.Currency `json:"refilltarget"`
+
 		RecentErr         string    `json:"recenterr"`
 		RecentErrTime     time.Time `json:"recenterrtime"`
 		RecentSuccessTime time.Time `json:"recentsuccesstime"`
 	}
 
+	// RenterAccount contains information about the renter's ephemeral account
+	// with a host. The balance details include all pending deposits and
+	// withdrawals that have not been committed yet.
+	RenterAccount struct {
+		AccountID string             `json:"accountid"`
+		HostKey   types.SiaPublicKey `json:"hostkey"`
+
+		AvailableBalance   types.Currency `json:"availablebalance"`
+		Balance            types.Currency `json:"balance"`
+		NegativeBalance    types.Currency `json:"negativebalance"`
+		PendingDeposits    types.Currency `json:"pendingdeposits"`
+		PendingWithdrawals types.Currency `json:"pendingwithdrawals"`
+
+		// The balance drift is the delta between the renter's and the host's
+		// version of the balance, expressed as the average drift per day
+		// since the account was first seen.
+		BalanceDriftNegativePerDay types.Currency `json:"balancedriftnegativeperday"`
+		BalanceDriftPositivePerDay types.Currency `json:"balancedriftpositiveperday"`
+		FirstSeen                  time.Time      `json:"firstseen"`
+
+		// RecoveredFromDirtyShutdown indicates whether the balance was
+		// restored from the host's version of the balance after an unclean
+		// shutdown of the renter.
+		RecoveredFromDirtyShutdown bool `json:"recoveredfromdirtyshutdown"`
+
+		Spending RenterAccountSpending `json:"spending"`
+	}
+
+	// RenterAccountSpending contains a breakdown of the money that was spent
+	// from an ephemeral account.
+	RenterAccountSpending struct {
+		Downloads         types.Currency `json:"downloads"`
+		RegistryReads     types.Currency `json:"registryreads"`
+		RegistryWrites    types.Currency `json:"registrywrites"`
+		RepairDownloads   types.Currency `json:"repairdownloads"`
+		RepairUploads     types.Currency `json:"repairuploads"`
+		SnapshotDownloads types.Currency `json:"snapshotdownloads"`
+		SnapshotUploads   types.Currency `json:"snapshotuploads"`
+		Subscriptions     types.Currency `json:"subscriptions"`
+		Uploads           types.Currency `json:"uploads"`
+	}
+
+	// WorkerHostSettingsStatus contains the host settings and the price table
+	// expiry as they were last cached by the worker.
+	WorkerHostSettingsStatus struct {
+		DownloadBandwidthPrice types.Currency    `json:"downloadbandwidthprice"`
+		MaxDuration            types.BlockHeight `json:"maxduration"`
+		StoragePrice           types.Currency    `json:"storageprice"`
+		UploadBandwidthPrice   types.Currency    `json:"uploadbandwidthprice"`
+
+		Affordable       bool      `json:"affordable"`
+		PriceTableExpiry time.Time `json:"pricetableexpiry"`
+		StalePriceTable  bool      `json:"stalepricetable"`
+
+		LastUpdate time.Time `json:"lastupdate"`
+	}
+
 	// WorkerPriceTableStatus contains detailed information about the price
 	// table
 	WorkerPriceTableStatus struct {
@@ -953,6 +1496,9 @@ type (
 
 		Active bool `json:"active"`
 
+		ClockSkew   time.Duration `json:"clockskew"`
+		ClockSkewed bool          `json:"clockskewed"`
+
 		RecentErr     string    `json:"recenterr"`
 		RecentErrTime time.Time `json:"recenterrtime"`
 	}
@@ -988,6 +1534,10 @@ type (
 	// registry jobs.
 	WorkerReadRegistryJobStatus struct {
 		WorkerGenericJobsStatus
+
+		// StaleResponses is the number of lookups for which the host returned
+		// a lower revision than another host or no entry at all.
+		StaleResponses uint64 `json:"staleresponses"`
 	}
 
 	// WorkerUpdateRegistryJobStatus contains detailed information about the update
@@ -1006,15 +1556,52 @@ type Renter interface {
 	// sorted by preference.
 	ActiveHosts() ([]HostDBEntry, error)
 
+	// Account returns information about the renter's ephemeral account with
+	// the host with the given key.
+	Account(hostKey types.SiaPublicKey) (RenterAccount, error)
+
+	// Accounts returns information about all of the renter's ephemeral
+	// accounts.
+	Accounts() ([]RenterAccount, error)
+
 	// AllHosts returns the full list of hosts known to the renter.
 	AllHosts() ([]HostDBEntry, error)
 
+	// AvgRepairRate returns the average number of bytes per second the
+	// renter recently repaired.
+	AvgRepairRate() (uint64, error)
+
+	// Bandwidth returns the current upload and download rates of the
+	// renter's workers as well as the total number of bytes transferred since
+	// startup.
+	Bandwidth() (RenterBandwidth, error)
+
+	// RepairMetrics returns the renter's repair metrics since startup as well
+	// as the metrics of the recent past.
+	RepairMetrics() (RenterRepairMetrics, error)
+
+	// RepairStatus returns what the repair loop is currently doing and which
+	// directories and chunks it is repairing.
+	RepairStatus() (RenterRepairStatus, error)
+
+	// ResetDirectoryHeap clears the directory heap of the repair loop and
+	// re-initializes it from the filesystem.
+	ResetDirectoryHeap() error
+
+	// StuckCursor returns the recent stuck repair attempts the stuck loop
+	// uses to bias its selection of stuck chunks. It is meant for debugging.
+	StuckCursor() ([]StuckCursorEntry, error)
+
 	// Close closes the Renter.
 	Close() error
 
 	// CancelContract cancels a specific contract of the renter.
 	CancelContract(id types.FileContractID) error
 
+	// ChangeErasureCode re-encodes the file at siaPath with the new erasure
+	// code. The file is converted in the background by the repair loop.
+	ChangeErasureCode(siaPath SiaPath, newEC ErasureCoder) error
+
 	// Contracts returns the staticContracts of the renter's hostContractor.
 	Contracts() []RenterContract
 
@@ -1082,6 +1669,14 @@ type Renter interface {
 	// RefreshedContract checks if the contract was previously refreshed
 	RefreshedContract(fcid types.FileContractID) bool
 
+	// RepairFile immediately adds the chunks of a file that need repair to
+	// the upload heap with priority.
+	RepairFile(siaPath SiaPath) error
+
+	// ResetFileAbandoned marks the abandoned chunks of a file as stuck again to
+	// make the stuck loop retry them.
+	ResetFileAbandoned(siaPath SiaPath) error
+
 	// SetFileStuck sets the 'stuck' status of a file.
 	SetFileStuck(siaPath SiaPath, stuck bool) error
 
@@ -1102,6 +1697,13 @@ type Renter interface {
 	// DeleteFile deletes a file entry from the renter.
 	DeleteFile(siaPath SiaPath) error
 
+	// DisableWorker disables the worker for the given host without affecting
+	// the host's entry in the hostdb or the contract with the host.
+	DisableWorker(hostKey types.SiaPublicKey) error
+
+	// EnableWorker enables a previously disabled worker for the given host.
+	EnableWorker(hostKey types.SiaPublicKey) error
+
 	// Download creates a download according to the parameters passed, including
 	// downloads of `offset` and `length` type. It returns a method to
 	// start the download.
@@ -1118,15 +1720,39 @@ type Renter interface {
 	// inclusive for before and after times.
 	ClearDownloadHistory(after, before time.Time) error
 
+	// DownloadByRoot downloads the range [offset, offset+length) of the
+	// sector with the given root from any host that stores it.
+	DownloadByRoot(root crypto.Hash, offset, length uint64) ([]byte, error)
+
 	// DownloadByUID returns a download from the download history given its uid.
 	DownloadByUID(uid DownloadID) (DownloadInfo, bool)
 
 	// DownloadHistory lists all the files that have been scheduled for download.
 	DownloadHistory() []DownloadInfo
 
+	// DownloadGroups returns information about all download groups.
+	DownloadGroups() []DownloadGroupInfo
+
+	// AwaitDownloadGroup blocks until all downloads of a download group have
+	// completed or, if failFast is true, until any download of the group
+	// failed. If the timeout is reached first, the current state of the group
+	// is returned. A timeout of 0 waits indefinitely.
+	AwaitDownloadGroup(name string, failFast bool, timeout time.Duration) (DownloadGroupInfo, error)
+
 	// File returns information on specific file queried by user
 	File(siaPath SiaPath) (FileInfo, error)
 
+	// FileChunks returns debugging information about the chunks of a file.
+	FileChunks(siaPath SiaPath) ([]ChunkInfo, error)
+
+	// FileRepairCostEstimate returns the estimated cost of uploading all of
+	// the missing pieces of a file.
+	FileRepairCostEstimate(siaPath SiaPath) (types.Currency, error)
+
+	// EstimateUploadCost estimates the cost of uploading a file of the given
+	// size at the given redundancy.
+	EstimateUploadCost(size uint64, redundancy float64) (RenterUploadCostEstimate, error)
+
 	// FileList returns information on all of the files stored by the renter at the
 	// specified folder. The 'cached' argument specifies whether cached values
 	// should be returned or not.
@@ -1162,19 +1788,34 @@ type Renter interface {
 	// ReadRegistry starts a registry lookup on all available workers. The
 	// jobs have 'timeout' amount of time to finish their jobs and return a
 	// response. Otherwise the response with the highest revision number will be
-	// used.
-	ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout time.Duration) (SignedRegistryValue, error)
+	// used. The consistency determines how many workers the lookup waits for
+	// and quorum is the fraction of the workers that need to respond for
+	// RegistryReadConsistencyQuorum.
+	ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout time.Duration, consistency RegistryReadConsistency, quorum float64) (SignedRegistryValue, error)
 
 	// ScoreBreakdown will return the score for a host db entry using the
 	// hostdb's weighting algorithm.
 	ScoreBreakdown(entry HostDBEntry) (HostScoreBreakdown, error)
 
+	// SubscribeRegistry subscribes to updates of the registry entry with the
+	// given public key and tweak. Updates are delivered on the returned
+	// channel until the returned function is called.
+	SubscribeRegistry(spk types.SiaPublicKey, tweak crypto.Hash) (<-chan SignedRegistryValue, func(), error)
+
 	// Settings returns the Renter's current settings.
 	Settings() (RenterSettings, error)
 
 	// SetSettings sets the Renter's settings.
 	SetSettings(RenterSettings) error
 
+	// SyncStatus returns the progress of the consensus sync that some renter
+	// operations are waiting for.
+	SyncStatus() RenterSyncStatus
+
+	// DiskLatency returns the latency measurements of the writes to the
+	// renter's persist directory.
+	DiskLatency() RenterDiskLatency
+
 	// SetFileTrackingPath sets the on-disk location of an uploaded file to a
 	// new value. Useful if files need to be moved on disk.
 	SetFileTrackingPath(siaPath SiaPath, newPath string) error
@@ -1190,6 +1831,14 @@ type Renter interface {
 	// ResumeRepairsAndUploads resumes the renter's repairs and uploads
 	ResumeRepairsAndUploads() error
 
+	// ClearStuckChunks removes all stuck chunks from the upload heap so that
+	// the repair loop re-evaluates the renter's files from scratch.
+	ClearStuckChunks() error
+
+	// PruneUploadHeap removes all chunks with a health below healthThreshold
+	// from the upload heap and returns the number of removed chunks.
+	PruneUploadHeap(healthThreshold float64) (int, error)
+
 	// Streamer creates a io.ReadSeeker that can be used to stream downloads
 	// from the Sia network and also returns the fileName of the streamed
 	// resource.
@@ -1198,10 +1847,24 @@ type Renter interface {
 	// Upload uploads a file using the input parameters.
 	Upload(FileUploadParams) error
 
+	// UploadStagingStatus returns the status of the renter's upload staging
+	// directory.
+	UploadStagingStatus() (UploadStagingStatus, error)
+
+	// MetadataBatchStats returns information about the batching of siafile
+	// metadata writes.
+	MetadataBatchStats() (MetadataBatchStats, error)
+
 	// UploadStreamFromReader reads from the provided reader until io.EOF is
 	// reached and upload the data to the Sia network.
 	UploadStreamFromReader(up FileUploadParams, reader io.Reader) error
 
+	// RepairFromStream repairs a file using its content read from the
+	// provided reader instead of downloading it from the hosts. The data of
+	// every chunk is checked against the previously uploaded pieces and
+	// chunks which don't match are rejected individually.
+	RepairFromStream(siaPath SiaPath, reader io.Reader) (RepairStreamReport, error)
+
 	// CreateDir creates a directory for the renter
 	CreateDir(siaPath SiaPath, mode os.FileMode) error
 
@@ -1211,6 +1874,10 @@ type Renter interface {
 	// DirList lists the directories in a siadir
 	DirList(siaPath SiaPath) ([]DirectoryInfo, error)
 
+	// DirHealthSummary returns a summary of the health of a siadir and its
+	// subdirectories without scanning its siafiles.
+	DirHealthSummary(siaPath SiaPath) (RenterDirHealthSummary, error)
+
 	// WorkerPoolStatus returns the current status of the Renter's worker pool
 	WorkerPoolStatus() (WorkerPoolStatus, error)
 
@@ -1250,6 +1917,10 @@ type RenterDownloadParameters struct {
 	SiaPath          SiaPath
 	Destination      string
 	DisableDiskFetch bool
+
+	// Group is the optional name of the download group the download belongs
+	// to.
+	Group string
 }
 
 // HealthPercentage returns the health in a more human understandable format out
@@ -0,0 +1,184 @@
+package renter
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// bandwidthStatsUpdateInterval is the interval at which the renter's
+	// current upload and download rates are updated.
+	bandwidthStatsUpdateInterval = build.Select(build.Var{
+		Dev:      time.Second,
+		Standard: time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// bandwidthStatsWindow is the time constant of the exponentially weighted
+	// moving average used for the renter's current upload and download rates.
+	bandwidthStatsWindow = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 5 * time.Second,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
+// workerSpeedDecay is the weight of a worker's previous average speed when a
+// new sample is added.
+const workerSpeedDecay = 0.9
+
+// bandwidthStats tracks the bytes uploaded and downloaded by the renter's
+// workers. The totals are updated atomically by the workers which keeps the
+// overhead on the job execution paths to a minimum. The current rates are
+// computed periodically from the totals.
+type bandwidthStats struct {
+	atomicTotalDownloaded uint64
+	atomicTotalUploaded   uint64
+
+	// downloadRate and uploadRate are the exponentially weighted moving
+	// averages of the bytes transferred per second.
+	downloadRate float64
+	uploadRate   float64
+
+	// lastDownloaded and lastUploaded are the totals at the time of the last
+	// update.
+	lastDownloaded uint64
+	lastUploaded   uint64
+	lastUpdate     time.Time
+
+	staticStartTime time.Time
+	mu              sync.Mutex
+}
+
+// newBandwidthStats creates new bandwidth stats.
+func newBandwidthStats() *bandwidthStats {
+	return &bandwidthStats{
+		lastUpdate:      time.Now(),
+		staticStartTime: time.Now(),
+	}
+}
+
+// callAddDownloaded adds n downloaded bytes to the stats.
+func (bs *bandwidthStats) callAddDownloaded(n uint64) {
+	atomic.AddUint64(&bs.atomicTotalDownloaded, n)
+}
+
+// callAddUploaded adds n uploaded bytes to the stats.
+func (bs *bandwidthStats) callAddUploaded(n uint64) {
+	atomic.AddUint64(&bs.atomicTotalUploaded, n)
+}
+
+// callStatus returns the current rates and the totals of the stats.
+func (bs *bandwidthStats) callStatus() modules.RenterBandwidth {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return modules.RenterBandwidth{
+		DownloadRate:    uint64(bs.downloadRate),
+		UploadRate:      uint64(bs.uploadRate),
+		TotalDownloaded: atomic.LoadUint64(&bs.atomicTotalDownloaded),
+		TotalUploaded:   atomic.LoadUint64(&bs.atomicTotalUploaded),
+		StartTime:       bs.staticStartTime,
+	}
+}
+
+// callUpdate updates the current rates using the bytes that were transferred
+// since the last update.
+func (bs *bandwidthStats) callUpdate(now time.Time) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	elapsed := now.Sub(bs.lastUpdate)
+	if elapsed <= 0 {
+		return
+	}
+	downloaded := atomic.LoadUint64(&bs.atomicTotalDownloaded)
+	uploaded := atomic.LoadUint64(&bs.atomicTotalUploaded)
+
+	// The weight of the new sample depends on the time that passed since the
+	// last update to make the average independent of the update frequency.
+	alpha := 1 - math.Exp(-elapsed.Seconds()/bandwidthStatsWindow.Seconds())
+	downloadSample := float64(downloaded-bs.lastDownloaded) / elapsed.Seconds()
+	uploadSample := float64(uploaded-bs.lastUploaded) / elapsed.Seconds()
+	bs.downloadRate += alpha * (downloadSample - bs.downloadRate)
+	bs.uploadRate += alpha * (uploadSample - bs.uploadRate)
+
+	bs.lastDownloaded = downloaded
+	bs.lastUploaded = uploaded
+	bs.lastUpdate = now
+}
+
+// staticAddBytesDownloaded adds n bytes to the bytes downloaded by the worker
+// and the renter.
+func (w *worker) staticAddBytesDownloaded(n uint64) {
+	atomic.AddUint64(&w.atomicBytesDownloaded, n)
+	w.renter.staticBandwidthStats.callAddDownloaded(n)
+}
+
+// staticAddBytesUploaded adds n bytes to the bytes uploaded by the worker and
+// the renter.
+func (w *worker) staticAddBytesUploaded(n uint64) {
+	atomic.AddUint64(&w.atomicBytesUploaded, n)
+	w.renter.staticBandwidthStats.callAddUploaded(n)
+}
+
+// updateSpeed adds a sample of n bytes transferred within d to the
+// exponentially weighted moving average of a speed in bytes per second. The
+// first sample initializes the average.
+func updateSpeed(avg float64, n uint64, d time.Duration) float64 {
+	if d <= 0 {
+		return avg
+	}
+	sample := float64(n) / d.Seconds()
+	if avg == 0 {
+		return sample
+	}
+	return workerSpeedDecay*avg + (1-workerSpeedDecay)*sample
+}
+
+// managedAddDownloadSpeedSample updates the worker's download speed after
+// downloading n bytes within d.
+func (w *worker) managedAddDownloadSpeedSample(n uint64, d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.downloadSpeed = updateSpeed(w.downloadSpeed, n, d)
+}
+
+// managedAddUploadSpeedSample updates the worker's upload speed after
+// uploading n bytes within d.
+func (w *worker) managedAddUploadSpeedSample(n uint64, d time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.uploadSpeed = updateSpeed(w.uploadSpeed, n, d)
+}
+
+// threadedUpdateBandwidthStats periodically updates the renter's current upload
+// and download rates.
+func (r *Renter) threadedUpdateBandwidthStats() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(bandwidthStatsUpdateInterval):
+		}
+		r.staticBandwidthStats.callUpdate(time.Now())
+	}
+}
+
+// Bandwidth returns the current upload and download rates of the renter's
+// workers as well as the total number of bytes transferred since startup.
+func (r *Renter) Bandwidth() (modules.RenterBandwidth, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterBandwidth{}, err
+	}
+	defer r.tg.Done()
+	return r.staticBandwidthStats.callStatus(), nil
+}
@@ -0,0 +1,77 @@
+package renter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBandwidthStats is a unit test for the bandwidthStats.
+func TestBandwidthStats(t *testing.T) {
+	t.Parallel()
+
+	bs := newBandwidthStats()
+	now := bs.lastUpdate
+
+	// Without any transfers the rates should be 0.
+	bs.callUpdate(now.Add(time.Second))
+	if status := bs.callStatus(); status.DownloadRate != 0 || status.UploadRate != 0 {
+		t.Fatal("expected rates to be 0", status)
+	}
+
+	// Simulate a steady transfer of 1000 bytes per second uploaded and 500
+	// bytes per second downloaded for a few windows.
+	now = bs.lastUpdate
+	for i := 0; i < int(10*bandwidthStatsWindow/bandwidthStatsUpdateInterval); i++ {
+		bs.callAddUploaded(uint64(1000 * bandwidthStatsUpdateInterval.Seconds()))
+		bs.callAddDownloaded(uint64(500 * bandwidthStatsUpdateInterval.Seconds()))
+		now = now.Add(bandwidthStatsUpdateInterval)
+		bs.callUpdate(now)
+	}
+	status := bs.callStatus()
+	if status.UploadRate < 990 || status.UploadRate > 1000 {
+		t.Fatal("unexpected upload rate", status.UploadRate)
+	}
+	if status.DownloadRate < 490 || status.DownloadRate > 500 {
+		t.Fatal("unexpected download rate", status.DownloadRate)
+	}
+	if status.TotalUploaded == 0 || status.TotalUploaded != 2*status.TotalDownloaded {
+		t.Fatal("unexpected totals", status)
+	}
+
+	// The rates should drop once the transfer stops.
+	bs.callUpdate(now.Add(bandwidthStatsWindow))
+	newStatus := bs.callStatus()
+	if newStatus.UploadRate >= status.UploadRate/2 || newStatus.DownloadRate >= status.DownloadRate/2 {
+		t.Fatal("rates should have dropped", newStatus)
+	}
+	if newStatus.TotalUploaded != status.TotalUploaded || newStatus.TotalDownloaded != status.TotalDownloaded {
+		t.Fatal("totals shouldn't change", newStatus)
+	}
+}
+
+// TestUpdateSpeed is a unit test for updateSpeed.
+func TestUpdateSpeed(t *testing.T) {
+	t.Parallel()
+
+	// The first sample initializes the average.
+	avg := updateSpeed(0, 1000, time.Second)
+	if avg != 1000 {
+		t.Fatal("wrong average", avg)
+	}
+	// A sample without a duration is ignored.
+	if updated := updateSpeed(avg, 1000, 0); updated != avg {
+		t.Fatal("sample without duration shouldn't change the average", updated)
+	}
+	// A faster sample increases the average by a fraction of the difference.
+	avg = updateSpeed(avg, 2000, time.Second)
+	if expected := workerSpeedDecay*1000 + (1-workerSpeedDecay)*2000; avg != expected {
+		t.Fatalf("expected %v but got %v", expected, avg)
+	}
+	// The average converges towards a steady speed.
+	for i := 0; i < 100; i++ {
+		avg = updateSpeed(avg, 500, time.Second)
+	}
+	if avg < 499 || avg > 501 {
+		t.Fatal("average should converge to 500 but was", avg)
+	}
+}
@@ -301,7 +301,9 @@ func (bs *bubbleScheduler) managedPerformBubbleUpdate(siaPath modules.SiaPath) (
 		defer func() {
 			err = errors.Compose(err, siaDir.Close())
 		}()
-		err = siaDir.UpdateBubbledMetadata(metadata)
+		err = r.managedTimePersistWrite(func() error {
+			return siaDir.UpdateBubbledMetadata(metadata)
+		})
 		if err != nil {
 			e := fmt.Sprintf("could not update the metadata of the directory %v", siaPath.String())
 			err = errors.AddContext(err, e)
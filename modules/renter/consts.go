@@ -18,6 +18,13 @@ const (
 const (
 	// AlertMSGSiafileLowRedundancy indicates that a file is below 75% redundancy.
 	AlertMSGSiafileLowRedundancy = "The SiaFile mentioned in the 'Cause' is below 75% redundancy"
+
+	// AlertMSGFailedFileCloses indicates that file entries of chunks were
+	// leaked which prevents the affected files from being deleted or renamed.
+	AlertMSGFailedFileCloses = "The renter failed to close some files which might prevent them from being deleted or renamed"
+	// AlertMSGSlowPersistDisk indicates that the writes to the renter's
+	// persist directory are slow which slows down the whole renter.
+	AlertMSGSlowPersistDisk = "The disk of the renter's persist directory is slow"
 	// AlertSiafileLowRedundancyThreshold is the health threshold at which we start
 	// registering the LowRedundancy alert for a Siafile.
 	AlertSiafileLowRedundancyThreshold = 0.75
@@ -155,12 +162,38 @@ const (
 	DefaultMaxUploadSpeed = 0
 )
 
+// DefaultMaxRepairAttempts is the default number of consecutive failed repair
+// attempts after which a chunk is marked as stuck instead of being pushed onto
+// the upload heap again.
+const DefaultMaxRepairAttempts = 5
+
+// DefaultMaxStuckRepairAttempts is the default number of consecutive failed
+// repairs of a chunk by the stuck loop after which the chunk is abandoned.
+// Abandoned chunks are skipped by the stuck loop until they are reset manually.
+const DefaultMaxStuckRepairAttempts = 50
+
+// DefaultMaxSubscriptionsPerWorker is the default number of registry entries a
+// worker keeps active subscriptions for.
+const DefaultMaxSubscriptionsPerWorker = 1000
+
+// DefaultMetadataBatchWindow is the default window within which the metadata
+// writes of pieces added to the same siafile are batched.
+var DefaultMetadataBatchWindow = build.Select(build.Var{
+	Dev:      50 * time.Millisecond,
+	Standard: 50 * time.Millisecond,
+	Testing:  20 * time.Millisecond,
+}).(time.Duration)
+
 // Naming conventions for code readability.
 const (
 	// destinationTypeSeekStream is the destination type used for downloads
 	// from the /renter/stream endpoint.
 	destinationTypeSeekStream = "httpseekstream"
 
+	// destinationTypeFileAndStream is the destination type used for downloads
+	// which are written to a file and a http stream at the same time.
+	destinationTypeFileAndStream = "file and http stream"
+
 	// memoryPriorityLow is used to request low priority memory
 	memoryPriorityLow = false
 
@@ -175,6 +208,17 @@ const (
 	// Heap
 	maxConsecutiveDirHeapFailures = 5
 
+	// materialDirHealthChange is the amount by which the health of a directory
+	// has to get worse while its chunks are added to the upload heap for the
+	// directory to be pushed back onto the directory heap.
+	materialDirHealthChange = 0.1
+
+	// maxDirectoryPopsPerPass is the number of times a directory can be popped
+	// off of the directory heap to have its chunks added to the upload heap
+	// before it yields to the other directories that need to be repaired. The
+	// count is reset whenever the directory heap is reset.
+	maxDirectoryPopsPerPass = 3
+
 	// maxRandomStuckChunksAddToHeap is the maximum number of random stuck
 	// chunks that the stuck loop will add to the uploadHeap at a time. Random
 	// stuck chunks are the stuck chunks chosen at random from the file system
@@ -241,6 +285,14 @@ var (
 		Testing:  2,
 	}).(int)
 
+	// maxStuckCursorEntries is the maximum number of files the stuck cursor
+	// remembers the stuck repair attempts of.
+	maxStuckCursorEntries = build.Select(build.Var{
+		Dev:      100,
+		Standard: 1000,
+		Testing:  100,
+	}).(int)
+
 	// maxUploadHeapChunks is the maximum number of chunks that we should add to
 	// the upload heap. This also will be used as the target number of chunks to
 	// add to the upload heap which which will mean for small directories we
@@ -260,6 +312,32 @@ var (
 		Testing:  1,
 	}).(int)
 
+	// repairFailureBackoff is the base backoff of a chunk after a failed
+	// repair. It doubles with every consecutive failure of the chunk.
+	repairFailureBackoff = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 5 * time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+
+	// maxRepairFailureBackoff is the maximum backoff of a chunk after failed
+	// repairs.
+	maxRepairFailureBackoff = build.Select(build.Var{
+		Dev:      time.Hour,
+		Standard: 24 * time.Hour,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// minActiveWorkers is the number of workers the worker pool won't scale
+	// below when the upload heap runs dry. It matches the default number of
+	// pieces of a chunk so that new uploads can still be fully distributed
+	// while the pool is scaled down.
+	minActiveWorkers = build.Select(build.Var{
+		Dev:      5,
+		Standard: 30,
+		Testing:  10,
+	}).(int)
+
 	// numBubbleWorkerThreads is the number of threads used when using worker
 	// groups in various bubble methods
 	numBubbleWorkerThreads = build.Select(build.Var{
@@ -1,15 +1,15 @@
 package contractor
 
 import (
-	"errors"
 	"reflect"
 
+	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
 
 var (
-	errAllowanceNotSynced = errors.New("you must be synced to set an allowance")
+	errAllowanceNotSynced = errors.AddContext(modules.ErrRenterNotSynced, "you must be synced to set an allowance")
 
 	// ErrAllowanceZeroFunds is returned if the allowance funds are being set to
 	// zero when not cancelling the allowance
@@ -178,7 +178,7 @@ func TestIntegrationSetAllowance(t *testing.T) {
 	defer tryClose(hostCF, t)
 
 	// announce the extra host
-	err = h.Announce()
+	err = h.Announce(false)
 	if err != nil {
 		t.Fatal(err)
 	}
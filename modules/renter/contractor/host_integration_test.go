@@ -215,7 +215,7 @@ func newCustomTestingTrio(name string, mux *siamux.SiaMux, hdeps, cdeps modules.
 	}
 
 	// announce the host
-	err = h.Announce()
+	err = h.Announce(false)
 	if err != nil {
 		return nil, nil, nil, nil, build.ExtendErr("error announcing host", err)
 	}
@@ -178,6 +178,11 @@ func (c *Contractor) managedCriticalUtilityChecks(sc *proto.SafeContract, host m
 		return u, needsUpdate
 	}
 
+	u, needsUpdate = c.contractFullCheck(contract, host)
+	if needsUpdate {
+		return u, needsUpdate
+	}
+
 	return contract.Utility, false
 }
 
@@ -299,3 +304,23 @@ func (c *Contractor) outOfStorageCheck(contract modules.RenterContract, blockHei
 	}
 	return u, false
 }
+
+// contractFullCheck checks if the contract can't fit another sector without
+// exceeding the host's max contract size.
+// Returns true if a check fails and the utility returned must be used to update
+// the contract state.
+func (c *Contractor) contractFullCheck(contract modules.RenterContract, host modules.HostDBEntry) (modules.ContractUtility, bool) {
+	u := contract.Utility
+	if host.MaxContractSize == 0 || contract.Size()+modules.SectorSize <= host.MaxContractSize {
+		return u, false
+	}
+	if u.GoodForUpload {
+		c.log.Println("Marking contract as not good for upload because it reached the host's max contract size:", contract.ID)
+	}
+	if !u.GoodForRenew {
+		c.log.Println("Marking contract as being good for renew:", contract.ID)
+	}
+	u.GoodForUpload = false
+	u.GoodForRenew = true
+	return u, true
+}
@@ -0,0 +1,517 @@
+package renter
+
+// conversion.go contains the logic for changing the erasure code of an existing
+// file. A conversion creates a new siafile with the new erasure code at the
+// same relative path within the modules.ConversionsFolder. The repair loop
+// uploads the chunks of that siafile like those of any other file, but fetches
+// their logical data either from the local file or by downloading it from the
+// original siafile. Once the new siafile is healthy it replaces the original.
+//
+// The state of a conversion is entirely defined by the conversion siafile
+// which means that a conversion resumes after a restart.
+
+import (
+	"bytes"
+	"strings"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem"
+)
+
+var (
+	// errConversionInProgress is returned when trying to change the erasure
+	// code of a file that is already being converted.
+	errConversionInProgress = errors.New("the erasure code of the file is already being changed")
+
+	// errConversionOfSystemFile is returned when trying to change the erasure
+	// code of a file within the conversions folder.
+	errConversionOfSystemFile = errors.New("can't change the erasure code of a file within the conversions folder")
+
+	// errSameErasureCode is returned when trying to change the erasure code
+	// of a file to the erasure code it already uses.
+	errSameErasureCode = errors.New("the file already uses the provided erasure code")
+)
+
+// conversionSiaPath returns the siapath of the conversion siafile for the file
+// at siaPath.
+func conversionSiaPath(siaPath modules.SiaPath) (modules.SiaPath, error) {
+	return modules.ConversionsFolder.Join(siaPath.String())
+}
+
+// conversionSourceSiaPath returns the siapath of the original file of a
+// conversion siafile. The returned bool is false if siaPath doesn't belong to
+// a conversion siafile.
+func conversionSourceSiaPath(siaPath modules.SiaPath) (modules.SiaPath, bool) {
+	if !isInDir(siaPath, modules.ConversionsFolder, true) {
+		return modules.SiaPath{}, false
+	}
+	source, err := modules.NewSiaPath(strings.TrimPrefix(siaPath.String(), modules.ConversionsFolder.String()+"/"))
+	return source, err == nil
+}
+
+// ChangeErasureCode changes the erasure code of the file at siaPath to newEC.
+// The file is re-encoded by the repair loop which uploads the file with the
+// new erasure code before replacing the original file.
+func (r *Renter) ChangeErasureCode(siaPath modules.SiaPath, newEC modules.ErasureCoder) (err error) {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	if _, conversion := conversionSourceSiaPath(siaPath); conversion {
+		return errConversionOfSystemFile
+	}
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		return err
+	}
+
+	r.conversionMu.Lock()
+	defer r.conversionMu.Unlock()
+
+	// Open the original file.
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to open the file")
+	}
+	defer func() {
+		err = errors.Compose(err, entry.Close())
+	}()
+	if entry.ErasureCode().Identifier() == newEC.Identifier() {
+		return errSameErasureCode
+	}
+
+	// Create the conversion siafile. The new file uses a new key and doesn't
+	// support partial chunks since the original file is fully uploaded
+	// already.
+	err = r.staticFileSystem.NewSiaFile(convSiaPath, entry.LocalPath(), newEC, crypto.GenerateSiaKey(entry.MasterKey().Type()), entry.Size(), entry.Mode(), true)
+	if errors.Contains(err, filesystem.ErrExists) {
+		return errConversionInProgress
+	}
+	if err != nil {
+		return errors.AddContext(err, "unable to create the conversion siafile")
+	}
+	convEntry, err := r.staticFileSystem.OpenSiaFile(convSiaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to open the conversion siafile")
+	}
+	defer func() {
+		err = errors.Compose(err, convEntry.Close())
+	}()
+	if err := convEntry.SetConversionSourceUID(entry.UID()); err != nil {
+		return errors.AddContext(err, "unable to mark the conversion siafile")
+	}
+
+	// Bubble the conversion siafile's directory to make sure the repair loop
+	// finds the file after a restart.
+	convDirSiaPath, err := convSiaPath.Dir()
+	if err != nil {
+		return err
+	}
+	_ = r.staticBubbleScheduler.callQueueBubble(convDirSiaPath)
+
+	// Send the chunks of the conversion siafile to the repair loop. Just like
+	// for uploads, nil maps result in the worst possible health.
+	nilMap := make(map[string]bool)
+	hosts := r.managedRefreshHostsAndWorkers()
+	r.callBuildAndPushChunks([]*filesystem.FileNode{convEntry}, hosts, targetUnstuckChunks, nilMap, nilMap)
+	select {
+	case r.uploadHeap.newUploads <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// managedDownloadConversionChunkData downloads the logical data of a
+// conversion chunk from the original file and creates the chunk's physical
+// pieces from it.
+func (r *Renter) managedDownloadConversionChunkData(chunk *unfinishedUploadChunk) (err error) {
+	source, err := r.staticFileSystem.OpenSiaFile(chunk.staticConversionSource)
+	if err != nil {
+		return errors.AddContext(err, "unable to open the original file of the conversion")
+	}
+	defer func() {
+		err = errors.Compose(err, source.Close())
+	}()
+
+	// The last chunk might not be full.
+	downloadLength := chunk.length
+	if uint64(chunk.offset)+downloadLength > chunk.fileEntry.Size() {
+		downloadLength = chunk.fileEntry.Size() - uint64(chunk.offset)
+	}
+	snap, err := source.SnapshotRange(chunk.staticConversionSource, uint64(chunk.offset), downloadLength)
+	if err != nil {
+		return err
+	}
+
+	// The chunks of the original file don't line up with the chunks of the
+	// conversion siafile so the data is downloaded as a stream.
+	buf := bytes.NewBuffer(make([]byte, 0, downloadLength))
+	ddw := newDownloadDestinationWriter(buf)
+	d, err := r.managedNewDownload(downloadParams{
+		destination:       ddw,
+		destinationType:   "buffer",
+		disableLocalFetch: true,
+		file:              snap,
+
+		latencyTarget: 200e3, // No need to rush latency on repair downloads.
+		length:        downloadLength,
+		needsMemory:   false, // We already requested memory for the chunk.
+		offset:        uint64(chunk.offset),
+		overdrive:     0, // No need to rush the latency on repair downloads.
+		priority:      0, // Repair downloads are completely de-prioritized.
+
+		staticMemoryManager:    chunk.staticMemoryManager,
+		staticSpendingCategory: categoryRepairDownload,
+	})
+	if err != nil {
+		return errors.Compose(err, ddw.Close())
+	}
+	d.OnComplete(func(_ error) error {
+		// Close the destination to avoid deadlocks.
+		return ddw.Close()
+	})
+	if err := d.Start(); err != nil {
+		return err
+	}
+	select {
+	case <-d.completeChan:
+	case <-r.tg.StopChan():
+		return errors.New("conversion download interrupted by stop call")
+	}
+	if d.Err() != nil {
+		return d.Err()
+	}
+	if _, err := chunk.staticReadLogicalData(buf); err != nil {
+		return errors.AddContext(err, "unable to read the downloaded data")
+	}
+	return chunk.staticEncryptAndCheckIntegrity()
+}
+
+// managedTryFinishConversion replaces the original file at siaPath with its
+// conversion siafile if none of the chunks of the conversion siafile need to be
+// repaired anymore. The returned bool indicates whether the conversion was
+// finished.
+func (r *Renter) managedTryFinishConversion(siaPath modules.SiaPath) (bool, error) {
+	return r.managedUpdateConversion(siaPath, nil)
+}
+
+// managedConversionChunkRepaired updates the health of the repaired chunk of
+// the conversion siafile of the file at siaPath and finishes the conversion if
+// it was the last chunk that needed to be repaired.
+func (r *Renter) managedConversionChunkRepaired(siaPath modules.SiaPath, chunkIndex uint64) (bool, error) {
+	return r.managedUpdateConversion(siaPath, []uint64{chunkIndex})
+}
+
+// managedUpdateConversion tracks which chunks of the conversion siafile of the
+// file at siaPath are healthy and finishes the conversion once all of them
+// are. The chunks are only checked from scratch the first time a conversion is
+// updated and when all of them appear to be healthy. Otherwise only the
+// repairedChunks are checked.
+func (r *Renter) managedUpdateConversion(siaPath modules.SiaPath, repairedChunks []uint64) (_ bool, err error) {
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		return false, err
+	}
+
+	r.conversionMu.Lock()
+	defer r.conversionMu.Unlock()
+
+	// Open the conversion siafile. If it doesn't exist, the conversion was
+	// already finished.
+	entry, err := r.staticFileSystem.OpenSiaFile(convSiaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.AddContext(err, "unable to open the conversion siafile")
+	}
+	defer func() {
+		err = errors.Compose(err, entry.Close())
+	}()
+
+	// Update the healthy chunks.
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+	convUID := entry.UID()
+	healthy, tracked := r.conversionHealthyChunks[convUID]
+	if !tracked {
+		healthy, err = healthyChunks(entry, offline, goodForRenew)
+		if err != nil {
+			return false, err
+		}
+		r.conversionHealthyChunks[convUID] = healthy
+		repairedChunks = nil // already checked
+	}
+	for _, chunkIndex := range repairedChunks {
+		ok, err := chunkHealthy(entry, chunkIndex, offline, goodForRenew)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			healthy[chunkIndex] = struct{}{}
+		} else {
+			delete(healthy, chunkIndex)
+		}
+	}
+	if uint64(len(healthy)) < entry.NumChunks() {
+		return false, nil
+	}
+
+	// All the chunks appear to be healthy. Check them once more since hosts
+	// might have gone offline since the chunks were repaired.
+	healthy, err = healthyChunks(entry, offline, goodForRenew)
+	if err != nil {
+		return false, err
+	}
+	r.conversionHealthyChunks[convUID] = healthy
+	if uint64(len(healthy)) < entry.NumChunks() {
+		return false, nil
+	}
+
+	// Replace the original file. The conversion siafile inherits the UID and
+	// metadata of the original file first. That way the renter shutting down
+	// after deleting the original file doesn't lose them and the conversion
+	// siafile is moved into place the next time this method is called. The
+	// original file's chunks are removed from the upload heap since they were
+	// built for the old erasure code.
+	//
+	// If the original file is missing but the conversion siafile didn't
+	// inherit its UID yet, the original file was removed without cancelling
+	// the conversion and the conversion siafile must not take its place.
+	original, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) && entry.UID() != entry.ConversionSourceUID() {
+		r.log.Printf("Cancelling the conversion of %v since the original file no longer exists", siaPath)
+		return false, r.cancelConversion(siaPath)
+	}
+	if err == nil {
+		uid := original.UID()
+		err = entry.InheritMetadata(original.Metadata())
+		if err = errors.Compose(err, original.Close()); err != nil {
+			return false, errors.AddContext(err, "unable to inherit the metadata of the original file")
+		}
+		if err := r.staticFileSystem.DeleteFile(siaPath); err != nil {
+			return false, errors.AddContext(err, "unable to delete the original file")
+		}
+		if err := r.uploadHeap.managedRemoveByFileUID(uid); err != nil {
+			r.log.Printf("Unable to remove the chunks of converted siafile %v from the upload heap: %v", siaPath, err)
+		}
+		r.staticUnfinishedChunkCache.callRemoveFile(uid)
+	} else if !errors.Contains(err, filesystem.ErrNotExist) {
+		return false, errors.AddContext(err, "unable to open the original file")
+	}
+	delete(r.conversionHealthyChunks, convUID)
+	if err := r.staticFileSystem.RenameFile(convSiaPath, siaPath); err != nil {
+		return false, errors.AddContext(err, "unable to move the conversion siafile into place")
+	}
+	r.log.Printf("Finished changing the erasure code of %v", siaPath)
+
+	// Update the metadata of both directories.
+	for _, sp := range []modules.SiaPath{siaPath, convSiaPath} {
+		dir, err := sp.Dir()
+		if err != nil {
+			return true, err
+		}
+		_ = r.staticBubbleScheduler.callQueueBubble(dir)
+	}
+	return true, nil
+}
+
+// healthyChunks returns the indices of the chunks of a siafile which don't
+// need to be repaired, no matter whether they are stuck or not.
+func healthyChunks(entry *filesystem.FileNode, offline, goodForRenew map[string]bool) (map[uint64]struct{}, error) {
+	healthy := make(map[uint64]struct{})
+	for chunkIndex := uint64(0); chunkIndex < entry.NumChunks(); chunkIndex++ {
+		ok, err := chunkHealthy(entry, chunkIndex, offline, goodForRenew)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			healthy[chunkIndex] = struct{}{}
+		}
+	}
+	return healthy, nil
+}
+
+// chunkHealthy returns whether a chunk of a siafile doesn't need to be
+// repaired. Just like for the health of a file, abandoned chunks and the chunk
+// of a zero byte file are ignored.
+func chunkHealthy(entry *filesystem.FileNode, chunkIndex uint64, offline, goodForRenew map[string]bool) (bool, error) {
+	if entry.Size() == 0 {
+		return true, nil
+	}
+	abandoned, err := entry.AbandonedChunkByIndex(chunkIndex)
+	if err != nil {
+		return false, errors.AddContext(err, "unable to check whether the chunk is abandoned")
+	}
+	if abandoned {
+		return true, nil
+	}
+	health, _, _, err := entry.ChunkHealth(int(chunkIndex), offline, goodForRenew)
+	if err != nil {
+		return false, errors.AddContext(err, "unable to get the health of the chunk")
+	}
+	return !modules.NeedsRepair(health), nil
+}
+
+// managedFinishConversions tries to finish all the conversions in progress.
+func (r *Renter) managedFinishConversions() {
+	var siaPaths []modules.SiaPath
+	err := r.staticFileSystem.CachedList(modules.ConversionsFolder, true, func(fi modules.FileInfo) {
+		if source, ok := conversionSourceSiaPath(fi.SiaPath); ok {
+			siaPaths = append(siaPaths, source)
+		}
+	}, func(modules.DirectoryInfo) {})
+	if err != nil {
+		r.log.Println("WARN: unable to list the conversions in progress:", err)
+		return
+	}
+	for _, siaPath := range siaPaths {
+		if _, err := r.managedTryFinishConversion(siaPath); err != nil {
+			r.log.Printf("WARN: unable to finish changing the erasure code of %v: %v", siaPath, err)
+		}
+	}
+}
+
+// managedConversionProgress returns whether the file at siaPath is being
+// converted and the upload progress of its conversion siafile.
+func (r *Renter) managedConversionProgress(siaPath modules.SiaPath, cached bool) (bool, float64) {
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		return false, 0
+	}
+	var fi modules.FileInfo
+	if cached {
+		fi, err = r.staticFileSystem.CachedFileInfo(convSiaPath)
+	} else {
+		offline, goodForRenew, contracts := r.managedContractUtilityMaps()
+		fi, err = r.staticFileSystem.FileInfo(convSiaPath, offline, goodForRenew, r.managedContractHostSubnets(contracts), contracts)
+	}
+	if err != nil {
+		return false, 0
+	}
+	return true, fi.UploadProgress
+}
+
+// cancelConversion deletes the conversion siafile of the file at siaPath if
+// there is one. The caller must hold conversionMu.
+func (r *Renter) cancelConversion(siaPath modules.SiaPath) error {
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		return err
+	}
+	entry, err := r.staticFileSystem.OpenSiaFile(convSiaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.AddContext(err, "unable to open the conversion siafile")
+	}
+	uid := entry.UID()
+	if err := entry.Close(); err != nil {
+		return errors.AddContext(err, "unable to close the conversion siafile")
+	}
+	if err := r.staticFileSystem.DeleteFile(convSiaPath); err != nil {
+		return errors.AddContext(err, "unable to delete the conversion siafile")
+	}
+	delete(r.conversionHealthyChunks, uid)
+	if err := r.uploadHeap.managedRemoveByFileUID(uid); err != nil {
+		r.log.Printf("Unable to remove the chunks of the conversion siafile %v from the upload heap: %v", convSiaPath, err)
+	}
+	if dir, err := convSiaPath.Dir(); err == nil {
+		_ = r.staticBubbleScheduler.callQueueBubble(dir)
+	}
+	return nil
+}
+
+// renameConversion moves the conversion siafile of the file at oldSiaPath to
+// the conversion siafile location of newSiaPath if there is one. The caller
+// must hold conversionMu.
+func (r *Renter) renameConversion(oldSiaPath, newSiaPath modules.SiaPath) error {
+	oldConvSiaPath, err := conversionSiaPath(oldSiaPath)
+	if err != nil {
+		return err
+	}
+	newConvSiaPath, err := conversionSiaPath(newSiaPath)
+	if err != nil {
+		return err
+	}
+	err = r.staticFileSystem.RenameFile(oldConvSiaPath, newConvSiaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.AddContext(err, "unable to rename the conversion siafile")
+	}
+	for _, sp := range []modules.SiaPath{oldConvSiaPath, newConvSiaPath} {
+		if dir, err := sp.Dir(); err == nil {
+			_ = r.staticBubbleScheduler.callQueueBubble(dir)
+		}
+	}
+	return nil
+}
+
+// cancelDirConversions deletes the conversion siafiles of all the files within
+// the directory at siaPath. The caller must hold conversionMu.
+func (r *Renter) cancelDirConversions(siaPath modules.SiaPath) error {
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		return err
+	}
+	exists, err := r.staticFileSystem.DirExists(convSiaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to check for conversions within the directory")
+	}
+	if !exists {
+		return nil
+	}
+	uids, err := r.managedSiaFileUIDs(convSiaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to get the conversion siafiles within the directory")
+	}
+	if err := r.staticFileSystem.DeleteDir(convSiaPath); err != nil {
+		return errors.AddContext(err, "unable to delete the conversion siafiles within the directory")
+	}
+	for _, uid := range uids {
+		delete(r.conversionHealthyChunks, uid)
+		if err := r.uploadHeap.managedRemoveByFileUID(uid); err != nil {
+			r.log.Printf("Unable to remove the chunks of a conversion siafile within %v from the upload heap: %v", convSiaPath, err)
+		}
+	}
+	if dir, err := convSiaPath.Dir(); err == nil {
+		_ = r.staticBubbleScheduler.callQueueBubble(dir)
+	}
+	return nil
+}
+
+// renameDirConversions moves the conversion siafiles of all the files within
+// the directory at oldSiaPath to the conversion siafile locations within
+// newSiaPath. The caller must hold conversionMu.
+func (r *Renter) renameDirConversions(oldSiaPath, newSiaPath modules.SiaPath) error {
+	oldConvSiaPath, err := conversionSiaPath(oldSiaPath)
+	if err != nil {
+		return err
+	}
+	newConvSiaPath, err := conversionSiaPath(newSiaPath)
+	if err != nil {
+		return err
+	}
+	exists, err := r.staticFileSystem.DirExists(oldConvSiaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to check for conversions within the directory")
+	}
+	if !exists {
+		return nil
+	}
+	if err := r.staticFileSystem.RenameDir(oldConvSiaPath, newConvSiaPath); err != nil {
+		return errors.AddContext(err, "unable to rename the conversion siafiles within the directory")
+	}
+	for _, sp := range []modules.SiaPath{oldConvSiaPath, newConvSiaPath} {
+		if dir, err := sp.Dir(); err == nil {
+			_ = r.staticBubbleScheduler.callQueueBubble(dir)
+		}
+	}
+	return nil
+}
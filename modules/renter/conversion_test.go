@@ -0,0 +1,467 @@
+package renter
+
+import (
+	"container/heap"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestChangeErasureCode is a unit test for creating, moving and cancelling
+// erasure code conversions.
+func TestChangeErasureCode(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a remote file.
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Converting to the same erasure code and converting a conversion
+	// siafile should fail.
+	if err := r.ChangeErasureCode(siaPath, rsc); !errors.Contains(err, errSameErasureCode) {
+		t.Fatal("unexpected error", err)
+	}
+	newEC, _ := modules.NewRSCode(2, 1)
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ChangeErasureCode(convSiaPath, newEC); !errors.Contains(err, errConversionOfSystemFile) {
+		t.Fatal("unexpected error", err)
+	}
+
+	// Change the erasure code.
+	if err := r.ChangeErasureCode(siaPath, newEC); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.ChangeErasureCode(siaPath, newEC); !errors.Contains(err, errConversionInProgress) {
+		t.Fatal("unexpected error", err)
+	}
+	source, ok := conversionSourceSiaPath(convSiaPath)
+	if !ok || !source.Equals(siaPath) {
+		t.Fatal("wrong source", source, ok)
+	}
+	if _, ok := conversionSourceSiaPath(siaPath); ok {
+		t.Fatal("file shouldn't be a conversion siafile")
+	}
+
+	// The conversion siafile should use the new erasure code and its chunks
+	// should be conversion chunks.
+	entry, err := r.staticFileSystem.OpenSiaFile(convSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.ErasureCode().Identifier() != newEC.Identifier() {
+		t.Fatal("wrong erasure code", entry.ErasureCode().Identifier())
+	}
+	chunk, err := r.managedBuildUnfinishedChunk(entry, 0, nil, nil, false, nil, nil, nil, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chunk.staticConversion || !chunk.staticConversionSource.Equals(siaPath) {
+		t.Fatal("chunk isn't a conversion chunk", chunk.staticConversion, chunk.staticConversionSource)
+	}
+	if err := errors.Compose(r.managedCloseEntry(chunk), entry.Close()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The conversion should be visible through the file info.
+	fi, err := r.File(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.Converting || fi.ConversionProgress != 0 {
+		t.Fatal("wrong conversion info", fi.Converting, fi.ConversionProgress)
+	}
+
+	// The conversion isn't done yet.
+	finished, err := r.managedTryFinishConversion(siaPath)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+
+	// Renaming the file moves the conversion.
+	newSiaPath, err := modules.NewSiaPath("dir2/file2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenameFile(siaPath, newSiaPath); err != nil {
+		t.Fatal(err)
+	}
+	newConvSiaPath, err := conversionSiaPath(newSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.staticFileSystem.FileInfo(newConvSiaPath, nil, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.staticFileSystem.FileInfo(convSiaPath, nil, nil, nil, nil); !errors.Contains(err, filesystem.ErrNotExist) {
+		t.Fatal("conversion siafile should have been moved", err)
+	}
+
+	// Deleting the file cancels the conversion.
+	if err := r.DeleteFile(newSiaPath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.staticFileSystem.FileInfo(newConvSiaPath, nil, nil, nil, nil); !errors.Contains(err, filesystem.ErrNotExist) {
+		t.Fatal("conversion siafile should have been deleted", err)
+	}
+}
+
+// TestFinishConversion checks that the health of the chunks of a conversion
+// siafile is tracked across calls and that the conversion siafile inherits the
+// metadata of the original file when it replaces it.
+func TestFinishConversion(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a remote file and a zero byte file and convert both.
+	rsc, _ := modules.NewRSCode(1, 1)
+	newEC, _ := modules.NewRSCode(2, 1)
+	siaPath, err := modules.NewSiaPath("file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emptySiaPath, err := modules.NewSiaPath("empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	localPath, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(emptySiaPath, localPath, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 0, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sp := range []modules.SiaPath{siaPath, emptySiaPath} {
+		if err := r.ChangeErasureCode(sp, newEC); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// convUID returns the UID of the conversion siafile of sp.
+	convUID := func(sp modules.SiaPath) siafile.SiafileUID {
+		convSiaPath, err := conversionSiaPath(sp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, err := r.staticFileSystem.OpenSiaFile(convSiaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := entry.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		return entry.UID()
+	}
+
+	// Without contracts none of the chunks of the remote file are healthy.
+	uid := convUID(siaPath)
+	finished, err := r.managedTryFinishConversion(siaPath)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+	r.conversionMu.Lock()
+	healthy, tracked := r.conversionHealthyChunks[uid]
+	r.conversionMu.Unlock()
+	if !tracked || len(healthy) != 0 {
+		t.Fatal("wrong healthy chunks", healthy, tracked)
+	}
+
+	// Pretend that all chunks but the last one were repaired. Updating the
+	// last chunk only checks that chunk.
+	convSiaPath, err := conversionSiaPath(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	convEntry, err := r.staticFileSystem.OpenSiaFile(convSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numChunks := convEntry.NumChunks()
+	if err := convEntry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if numChunks < 2 {
+		t.Fatal("file should have multiple chunks", numChunks)
+	}
+	healthy = make(map[uint64]struct{})
+	for chunkIndex := uint64(0); chunkIndex < numChunks-1; chunkIndex++ {
+		healthy[chunkIndex] = struct{}{}
+	}
+	r.conversionMu.Lock()
+	r.conversionHealthyChunks[uid] = healthy
+	r.conversionMu.Unlock()
+	finished, err = r.managedConversionChunkRepaired(siaPath, numChunks-1)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+	r.conversionMu.Lock()
+	healthy = r.conversionHealthyChunks[uid]
+	r.conversionMu.Unlock()
+	if _, exists := healthy[numChunks-1]; exists || uint64(len(healthy)) != numChunks-1 {
+		t.Fatal("wrong healthy chunks", healthy)
+	}
+
+	// Pretend that all chunks were repaired. The conversion siafile is checked
+	// from scratch before it replaces the original file.
+	r.conversionMu.Lock()
+	healthy[numChunks-1] = struct{}{}
+	r.conversionMu.Unlock()
+	finished, err = r.managedTryFinishConversion(siaPath)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+	r.conversionMu.Lock()
+	healthy = r.conversionHealthyChunks[uid]
+	r.conversionMu.Unlock()
+	if len(healthy) != 0 {
+		t.Fatal("healthy chunks weren't reset", healthy)
+	}
+
+	// The zero byte file is finished right away.
+	original, err := r.staticFileSystem.OpenSiaFile(emptySiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := original.Metadata()
+	if err := original.Close(); err != nil {
+		t.Fatal(err)
+	}
+	uid = convUID(emptySiaPath)
+	finished, err = r.managedTryFinishConversion(emptySiaPath)
+	if err != nil || !finished {
+		t.Fatal("conversion should be finished", finished, err)
+	}
+	r.conversionMu.Lock()
+	_, tracked = r.conversionHealthyChunks[uid]
+	r.conversionMu.Unlock()
+	if tracked {
+		t.Fatal("finished conversion is still tracked")
+	}
+
+	// The replacement should use the new erasure code but inherit the
+	// metadata of the original file.
+	entry, err := r.staticFileSystem.OpenSiaFile(emptySiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := entry.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if entry.ErasureCode().Identifier() != newEC.Identifier() {
+		t.Fatal("wrong erasure code", entry.ErasureCode().Identifier())
+	}
+	newMD := entry.Metadata()
+	if newMD.UniqueID != md.UniqueID || newMD.LocalPath != localPath || !newMD.CreateTime.Equal(md.CreateTime) || newMD.Mode != md.Mode {
+		t.Fatal("metadata wasn't inherited", newMD.UniqueID, md.UniqueID, newMD.LocalPath, newMD.CreateTime, md.CreateTime)
+	}
+}
+
+// TestConversionDirOperations checks that deleting or renaming a directory
+// cancels or moves the conversions of the files within it and that a
+// conversion siafile never takes the place of an original file that was
+// removed without cancelling the conversion.
+func TestConversionDirOperations(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// convert creates a zero byte file at path and changes its erasure code.
+	// The conversion of a zero byte file is finished by the first call to
+	// managedTryFinishConversion.
+	rsc, _ := modules.NewRSCode(1, 1)
+	newEC, _ := modules.NewRSCode(2, 1)
+	localPath, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	convert := func(path string) (modules.SiaPath, modules.SiaPath) {
+		siaPath, err := modules.NewSiaPath(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.staticFileSystem.NewSiaFile(siaPath, localPath, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 0, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.ChangeErasureCode(siaPath, newEC); err != nil {
+			t.Fatal(err)
+		}
+		convSiaPath, err := conversionSiaPath(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return siaPath, convSiaPath
+	}
+	exists := func(siaPath modules.SiaPath) bool {
+		exists, err := r.staticFileSystem.FileExists(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return exists
+	}
+
+	// Deleting the directory cancels the conversion and finishing it
+	// afterwards doesn't bring the file back.
+	siaPath, convSiaPath := convert("deleted/sub/file")
+	deletedDir, err := modules.NewSiaPath("deleted")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteDir(deletedDir); err != nil {
+		t.Fatal(err)
+	}
+	if exists(convSiaPath) {
+		t.Fatal("conversion siafile should have been deleted")
+	}
+	finished, err := r.managedTryFinishConversion(siaPath)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+	if exists(siaPath) {
+		t.Fatal("deleted file was brought back")
+	}
+
+	// Renaming the directory moves the conversion and finishing it replaces
+	// the file at the new location only.
+	oldSiaPath, oldConvSiaPath := convert("old/sub/file")
+	oldDir, err := modules.NewSiaPath("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDir, err := modules.NewSiaPath("new")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenameDir(oldDir, newDir); err != nil {
+		t.Fatal(err)
+	}
+	newSiaPath, err := modules.NewSiaPath("new/sub/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newConvSiaPath, err := conversionSiaPath(newSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists(oldConvSiaPath) || !exists(newConvSiaPath) {
+		t.Fatal("conversion siafile wasn't moved")
+	}
+	finished, err = r.managedTryFinishConversion(oldSiaPath)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+	finished, err = r.managedTryFinishConversion(newSiaPath)
+	if err != nil || !finished {
+		t.Fatal("conversion should be finished", finished, err)
+	}
+	if exists(oldSiaPath) || !exists(newSiaPath) {
+		t.Fatal("conversion finished at the wrong location")
+	}
+	entry, err := r.staticFileSystem.OpenSiaFile(newSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec := entry.ErasureCode()
+	if err := entry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if ec.Identifier() != newEC.Identifier() {
+		t.Fatal("wrong erasure code", ec.Identifier())
+	}
+
+	// An original file that is removed without cancelling the conversion
+	// isn't replaced by the conversion siafile.
+	siaPath, convSiaPath = convert("removed")
+	if err := r.staticFileSystem.DeleteFile(siaPath); err != nil {
+		t.Fatal(err)
+	}
+	finished, err = r.managedTryFinishConversion(siaPath)
+	if err != nil || finished {
+		t.Fatal("conversion shouldn't be finished", finished, err)
+	}
+	if exists(siaPath) || exists(convSiaPath) {
+		t.Fatal("conversion should have been cancelled")
+	}
+}
+
+// TestUploadHeapConversionPriority checks that conversion chunks are
+// prioritized right after remote chunks.
+func TestUploadHeapConversionPriority(t *testing.T) {
+	remote := &unfinishedUploadChunk{health: 0.5}
+	conversion := &unfinishedUploadChunk{health: 0.3, staticConversion: true}
+	remoteConversion := &unfinishedUploadChunk{health: 2, staticConversion: true}
+	local := &unfinishedUploadChunk{health: 3, onDisk: true}
+
+	var uch uploadChunkHeap
+	for _, c := range []*unfinishedUploadChunk{local, conversion, remoteConversion, remote} {
+		heap.Push(&uch, c)
+	}
+	for _, expected := range []*unfinishedUploadChunk{remote, remoteConversion, conversion, local} {
+		if c := heap.Pop(&uch).(*unfinishedUploadChunk); c != expected {
+			t.Fatalf("wrong chunk popped, expected health %v but got %v", expected.health, c.health)
+		}
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"container/heap"
 	"fmt"
 	"math"
+	"sort"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/threadgroup"
@@ -65,6 +67,17 @@ func (d *directory) managedHeapHealth() (float64, bool) {
 	return health, false
 }
 
+// managedUpdateHealth updates the health fields of the directory with the
+// values of the provided metadata.
+func (d *directory) managedUpdateHealth(metadata siadir.Metadata) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.aggregateHealth = metadata.AggregateHealth
+	d.aggregateRemoteHealth = metadata.AggregateRemoteHealth
+	d.health = metadata.Health
+	d.remoteHealth = metadata.RemoteHealth
+}
+
 // directoryHeap contains a priority sorted heap of directories that are being
 // explored and repaired
 type directoryHeap struct {
@@ -74,6 +87,19 @@ type directoryHeap struct {
 	// heap
 	heapDirectories map[modules.SiaPath]*directory
 
+	// popCounts tracks how many times each directory has been popped off of
+	// the heap to have its chunks added to the upload heap since the heap was
+	// last reset.
+	popCounts map[modules.SiaPath]int
+
+	// resets and initializations count how often the heap was cleared and
+	// how often it was re-initialized with the root directory afterwards.
+	resets             uint64
+	manualResets       uint64
+	lastReset          time.Time
+	initializations    uint64
+	lastInitialization time.Time
+
 	mu sync.Mutex
 }
 
@@ -193,8 +219,82 @@ func (dh *directoryHeap) managedPush(d *directory) {
 func (dh *directoryHeap) managedReset() {
 	dh.mu.Lock()
 	defer dh.mu.Unlock()
+	dh.reset()
+}
+
+// managedManualReset clears the directory heap like managedReset but counts the
+// reset as a manual one.
+func (dh *directoryHeap) managedManualReset() {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	dh.reset()
+	dh.manualResets++
+}
+
+// reset clears the directory heap and updates the reset stats.
+func (dh *directoryHeap) reset() {
 	dh.heapDirectories = make(map[modules.SiaPath]*directory)
 	dh.heap = repairDirectoryHeap{}
+	dh.popCounts = make(map[modules.SiaPath]int)
+	dh.resets++
+	dh.lastReset = time.Now()
+}
+
+// managedStatus returns information about the resets and re-initializations of
+// the directory heap.
+func (dh *directoryHeap) managedStatus() modules.DirectoryHeapStatus {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	return modules.DirectoryHeapStatus{
+		Len:                dh.heap.Len(),
+		Resets:             dh.resets,
+		ManualResets:       dh.manualResets,
+		LastReset:          dh.lastReset,
+		Initializations:    dh.initializations,
+		LastInitialization: dh.lastInitialization,
+	}
+}
+
+// managedTop returns up to n directories from the top of the heap sorted by
+// their repair priority. The heap itself is not modified.
+func (dh *directoryHeap) managedTop(n int) []modules.RepairStatusDirectory {
+	dh.mu.Lock()
+	dirs := make([]modules.RepairStatusDirectory, 0, len(dh.heap))
+	for _, d := range dh.heap {
+		health, remote := d.managedHeapHealth()
+		d.mu.Lock()
+		explored := d.explored
+		d.mu.Unlock()
+		dirs = append(dirs, modules.RepairStatusDirectory{
+			SiaPath:  d.staticSiaPath,
+			Health:   health,
+			Remote:   remote,
+			Explored: explored,
+		})
+	}
+	dh.mu.Unlock()
+
+	// Sort the directories the same way the heap prioritizes them.
+	sort.SliceStable(dirs, func(i, j int) bool {
+		if dirs[i].Remote != dirs[j].Remote {
+			return dirs[i].Remote
+		}
+		return dirs[i].Health > dirs[j].Health
+	})
+	if len(dirs) > n {
+		dirs = dirs[:n]
+	}
+	return dirs
+}
+
+// managedIncrementPopCount increments the number of times the directory has
+// been popped to have its chunks added to the upload heap and returns the
+// updated count.
+func (dh *directoryHeap) managedIncrementPopCount(siaPath modules.SiaPath) int {
+	dh.mu.Lock()
+	defer dh.mu.Unlock()
+	dh.popCounts[siaPath]++
+	return dh.popCounts[siaPath]
 }
 
 // update will update the directory that is currently in the heap based on the
@@ -301,6 +401,46 @@ func (r *Renter) managedPushSubDirectories(d *directory) error {
 	return nil
 }
 
+// managedInitDirectoryHeap initializes the directory heap by pushing the root
+// directory onto it as an unexplored directory.
+func (r *Renter) managedInitDirectoryHeap() error {
+	err := r.managedPushUnexploredDirectory(modules.RootSiaPath())
+	if err != nil {
+		return err
+	}
+	dh := &r.directoryHeap
+	dh.mu.Lock()
+	dh.initializations++
+	dh.lastInitialization = time.Now()
+	dh.mu.Unlock()
+	return nil
+}
+
+// ResetDirectoryHeap clears the directory heap and re-initializes it from the
+// filesystem. If the repair loop is currently adding chunks from the directory
+// heap to the upload heap, the reset waits for it to finish.
+func (r *Renter) ResetDirectoryHeap() error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	r.directoryHeapMu.Lock()
+	r.directoryHeap.managedManualReset()
+	err := r.managedInitDirectoryHeap()
+	r.directoryHeapMu.Unlock()
+	if err != nil {
+		return errors.AddContext(err, "unable to re-initialize the directory heap")
+	}
+
+	// Wake up the repair loop in case it is waiting for work.
+	select {
+	case r.uploadHeap.repairNeeded <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 // managedPushUnexploredDirectory reads the health from the siadir metadata and
 // pushes an unexplored directory element onto the heap
 func (r *Renter) managedPushUnexploredDirectory(siaPath modules.SiaPath) (err error) {
@@ -321,3 +461,24 @@ func (r *Renter) managedPushUnexploredDirectory(siaPath modules.SiaPath) (err er
 	r.directoryHeap.managedPushDirectory(siaPath, metadata, false)
 	return nil
 }
+
+// managedPushBackDirectoryIfWorse reads the current health of a directory from
+// its metadata and pushes the directory back onto the heap as explored if its
+// health is materially worse than the provided health. The returned boolean
+// indicates whether the directory was pushed.
+func (r *Renter) managedPushBackDirectoryIfWorse(siaPath modules.SiaPath, health float64) (bool, error) {
+	metadata, err := r.managedDirectoryMetadata(siaPath)
+	if err != nil {
+		return false, err
+	}
+	d := &directory{
+		explored:      true,
+		staticSiaPath: siaPath,
+	}
+	d.managedUpdateHealth(metadata)
+	if currentHealth, _ := d.managedHeapHealth(); currentHealth-health <= materialDirHealthChange {
+		return false, nil
+	}
+	r.directoryHeap.managedPush(d)
+	return true, nil
+}
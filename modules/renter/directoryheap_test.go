@@ -1,13 +1,16 @@
 package renter
 
 import (
+	"fmt"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
 	"go.sia.tech/siad/siatest/dependencies"
 )
 
@@ -548,3 +551,86 @@ func TestDirectoryHeapHealth(t *testing.T) {
 		t.Errorf("Expected heapHealth to be %v but was %v", d.health, heapHealth)
 	}
 }
+
+// TestResetDirectoryHeap probes ResetDirectoryHeap and the reset stats of the
+// directory heap.
+func TestResetDirectoryHeap(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	dh := &rt.renter.directoryHeap
+
+	// Without the repair loops, the heap was never reset or initialized.
+	if status := dh.managedStatus(); status.Resets != 0 || status.Initializations != 0 || status.Len != 0 {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Fill the heap with some directories.
+	for i := 0; i < 3; i++ {
+		siaPath, err := modules.NewSiaPath(fmt.Sprintf("dir%v", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dh.managedPushDirectory(siaPath, siadir.Metadata{}, true)
+	}
+
+	// Block the reset by pretending that the repair loop is adding chunks to
+	// the upload heap.
+	rt.renter.directoryHeapMu.Lock()
+	done := make(chan error)
+	go func() {
+		done <- rt.renter.ResetDirectoryHeap()
+	}()
+	select {
+	case <-done:
+		t.Fatal("reset should wait for the repair loop")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if status := dh.managedStatus(); status.Resets != 0 || status.Len != 3 {
+		t.Fatal("heap shouldn't have been reset yet", status)
+	}
+	rt.renter.directoryHeapMu.Unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The heap should only contain the unexplored root directory now.
+	status := dh.managedStatus()
+	if status.Len != 1 || status.Resets != 1 || status.ManualResets != 1 || status.Initializations != 1 {
+		t.Fatal("unexpected status", status)
+	}
+	if status.LastReset.IsZero() || status.LastInitialization.Before(status.LastReset) {
+		t.Fatal("unexpected timestamps", status.LastReset, status.LastInitialization)
+	}
+	d := dh.managedPop()
+	if !d.staticSiaPath.Equals(modules.RootSiaPath()) || d.explored {
+		t.Fatal("expected unexplored root directory", d.staticSiaPath, d.explored)
+	}
+
+	// Automatic resets aren't counted as manual ones.
+	dh.managedReset()
+	if status := dh.managedStatus(); status.Resets != 2 || status.ManualResets != 1 {
+		t.Fatal("unexpected status", status)
+	}
+
+	// The stats are exposed through the repair metrics.
+	metrics, err := rt.renter.RepairMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.DirectoryHeap.Resets != 2 || metrics.DirectoryHeap.Initializations != 1 {
+		t.Fatal("unexpected metrics", metrics.DirectoryHeap)
+	}
+}
@@ -1,12 +1,15 @@
 package renter
 
 import (
+	"math"
 	"os"
 	"sort"
 	"sync"
 
 	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 )
 
 // CreateDir creates a directory for the renter
@@ -26,7 +29,27 @@ func (r *Renter) DeleteDir(siaPath modules.SiaPath) error {
 		return err
 	}
 	defer r.tg.Done()
-	return r.staticFileSystem.DeleteDir(siaPath)
+
+	// Grab the UIDs of the files within the directory to be able to remove
+	// their staged copies once they are deleted.
+	uids, err := r.managedSiaFileUIDs(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to get the files within the directory")
+	}
+
+	// Delete the directory. Deleting a directory also cancels the conversions
+	// of the files within it.
+	r.conversionMu.Lock()
+	err = r.staticFileSystem.DeleteDir(siaPath)
+	if err == nil {
+		err = r.cancelDirConversions(siaPath)
+	}
+	r.conversionMu.Unlock()
+	if err != nil {
+		return err
+	}
+	r.managedRemoveStagedUploads(uids)
+	return nil
 }
 
 // DirList lists the directories in a siadir
@@ -38,6 +61,33 @@ func (r *Renter) DirList(siaPath modules.SiaPath) (dis []modules.DirectoryInfo,
 	return r.managedDirList(siaPath)
 }
 
+// DirHealthSummary returns a summary of the health of a siadir and its
+// subdirectories. The summary is read from the cached metadata of the siadir
+// which means that none of the siafiles are opened and that the summary is
+// only as recent as the last bubble of the siadir.
+func (r *Renter) DirHealthSummary(siaPath modules.SiaPath) (_ modules.RenterDirHealthSummary, err error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterDirHealthSummary{}, err
+	}
+	defer r.tg.Done()
+	siaDir, err := r.staticFileSystem.OpenSiaDir(siaPath)
+	if err != nil {
+		return modules.RenterDirHealthSummary{}, err
+	}
+	defer func() {
+		err = errors.Compose(err, siaDir.Close())
+	}()
+	md, err := siaDir.Metadata()
+	if err != nil {
+		return modules.RenterDirHealthSummary{}, err
+	}
+	return modules.RenterDirHealthSummary{
+		WorstChunkHealth:       math.Max(md.AggregateHealth, md.AggregateStuckHealth),
+		NumChunksNeedingRepair: md.AggregateNumChunksNeedingRepair,
+		NumStuckChunks:         md.AggregateNumStuckChunks,
+	}, nil
+}
+
 // managedDirList lists the directories in a siadir
 func (r *Renter) managedDirList(siaPath modules.SiaPath) (dis []modules.DirectoryInfo, _ error) {
 	var mu sync.Mutex
@@ -69,5 +119,45 @@ func (r *Renter) RenameDir(oldPath, newPath modules.SiaPath) error {
 	if newPath.IsRoot() {
 		return errors.New("cannot rename a file to the root directory")
 	}
-	return r.staticFileSystem.RenameDir(oldPath, newPath)
+
+	// Rename the directory. The conversions of the files within it are moved
+	// along with it.
+	r.conversionMu.Lock()
+	defer r.conversionMu.Unlock()
+	err := r.staticFileSystem.RenameDir(oldPath, newPath)
+	if err != nil {
+		return err
+	}
+	return r.renameDirConversions(oldPath, newPath)
+}
+
+// managedSiaFileUIDs returns the UIDs of all the siafiles within the directory
+// at siaPath and its subdirectories.
+func (r *Renter) managedSiaFileUIDs(siaPath modules.SiaPath) ([]siafile.SiafileUID, error) {
+	var mu sync.Mutex
+	var siaPaths []modules.SiaPath
+	flf := func(fi modules.FileInfo) {
+		mu.Lock()
+		siaPaths = append(siaPaths, fi.SiaPath)
+		mu.Unlock()
+	}
+	err := r.staticFileSystem.CachedList(siaPath, true, flf, func(modules.DirectoryInfo) {})
+	if err != nil {
+		return nil, err
+	}
+	uids := make([]siafile.SiafileUID, 0, len(siaPaths))
+	for _, sp := range siaPaths {
+		entry, err := r.staticFileSystem.OpenSiaFile(sp)
+		if errors.Contains(err, filesystem.ErrNotExist) {
+			continue // deleted in the meantime
+		}
+		if err != nil {
+			return nil, errors.AddContext(err, "unable to open siafile")
+		}
+		uids = append(uids, entry.UID())
+		if err := entry.Close(); err != nil {
+			return nil, errors.AddContext(err, "unable to close siafile")
+		}
+	}
+	return uids, nil
 }
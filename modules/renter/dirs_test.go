@@ -12,9 +12,11 @@ import (
 
 	"gitlab.com/NebulousLabs/errors"
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/filesystem"
 	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/siatest/dependencies"
 )
 
@@ -440,3 +442,126 @@ func compareDirectoryInfoAndMetadataCustom(di modules.DirectoryInfo, siaDir *fil
 	}
 	return nil
 }
+
+// TestDirHealthSummary verifies that the health summary of a directory, which
+// is read from the cached metadata, matches the health found by scanning all
+// the chunks of the directory's files after a bubble.
+func TestDirHealthSummary(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renterTester
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create files with multiple chunks in a directory and a subdirectory.
+	// Mark some of the chunks as stuck.
+	dir, err := modules.NewSiaPath("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subDir, err := dir.Join("sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var siaPaths []modules.SiaPath
+	for i, parent := range []modules.SiaPath{dir, dir, subDir} {
+		siaPath, err := parent.Join(fmt.Sprintf("file%v", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, rsc := testingFileParamsCustom(2, 2)
+		fileSize := uint64(i+2) * modules.SectorSize * uint64(rsc.MinPieces())
+		err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), fileSize, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for chunkIndex := uint64(0); chunkIndex < uint64(i); chunkIndex++ {
+			if err := f.SetStuck(chunkIndex, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		siaPaths = append(siaPaths, siaPath)
+	}
+
+	// Bubble the directories from the bottom up.
+	for _, siaPath := range []modules.SiaPath{subDir, dir, modules.RootSiaPath()} {
+		if err := rt.bubble(siaPath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Compute the expected summaries by scanning all chunks of the files.
+	offline, goodForRenew, _, _ := r.callRenterContractsAndUtilities()
+	scan := func(siaPaths []modules.SiaPath) (expected modules.RenterDirHealthSummary) {
+		for _, siaPath := range siaPaths {
+			f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for chunkIndex := uint64(0); chunkIndex < f.NumChunks(); chunkIndex++ {
+				health, _, _, err := f.ChunkHealth(int(chunkIndex), offline, goodForRenew)
+				if err != nil {
+					t.Fatal(err)
+				}
+				stuck, err := f.StuckChunkByIndex(chunkIndex)
+				if err != nil {
+					t.Fatal(err)
+				}
+				expected.WorstChunkHealth = math.Max(expected.WorstChunkHealth, health)
+				if stuck {
+					expected.NumStuckChunks++
+				} else if modules.NeedsRepair(health) {
+					expected.NumChunksNeedingRepair++
+				}
+			}
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return
+	}
+	tests := []struct {
+		siaPath  modules.SiaPath
+		siaPaths []modules.SiaPath
+	}{
+		{subDir, siaPaths[2:]},
+		{dir, siaPaths},
+		{modules.RootSiaPath(), siaPaths},
+	}
+	for _, test := range tests {
+		expected := scan(test.siaPaths)
+		if expected.NumStuckChunks == 0 || expected.NumChunksNeedingRepair == 0 {
+			t.Fatal("test files should have stuck chunks and chunks needing repair", expected)
+		}
+		summary, err := r.DirHealthSummary(test.siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if summary != expected {
+			t.Fatalf("summary of %v doesn't match scan: %+v != %+v", test.siaPath, summary, expected)
+		}
+	}
+
+	// Requesting the summary of a directory that doesn't exist should fail.
+	if _, err := r.DirHealthSummary(modules.RandomSiaPath()); !errors.Contains(err, filesystem.ErrNotExist) {
+		t.Fatal("expected ErrNotExist", err)
+	}
+}
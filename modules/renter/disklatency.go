@@ -0,0 +1,241 @@
+package renter
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+var (
+	// diskLatencyNumSamples is the number of recent writes to the persist
+	// directory that the latency percentiles are computed from.
+	diskLatencyNumSamples = build.Select(build.Var{
+		Dev:      100,
+		Standard: 1000,
+		Testing:  20,
+	}).(int)
+
+	// diskLatencyMinSamples is the minimum number of samples that need to be
+	// collected before the disk is considered to be slow.
+	diskLatencyMinSamples = build.Select(build.Var{
+		Dev:      20,
+		Standard: 100,
+		Testing:  10,
+	}).(int)
+
+	// diskLatencyMaxSampleAge is the age after which a sample is no longer
+	// taken into account. Otherwise a burst of slow writes would keep the
+	// disk marked as slow until enough new writes happened.
+	diskLatencyMaxSampleAge = build.Select(build.Var{
+		Dev:      5 * time.Minute,
+		Standard: 30 * time.Minute,
+		Testing:  time.Minute,
+	}).(time.Duration)
+
+	// diskLatencyUpdateInterval is the interval at which the renter checks
+	// whether the disk is slow.
+	diskLatencyUpdateInterval = build.Select(build.Var{
+		Dev:      5 * time.Second,
+		Standard: 30 * time.Second,
+		Testing:  100 * time.Millisecond,
+	}).(time.Duration)
+
+	// diskLatencyThreshold is the 95th percentile latency of writes to the
+	// persist directory above which the disk is considered to be slow.
+	diskLatencyThreshold = build.Select(build.Var{
+		Dev:      250 * time.Millisecond,
+		Standard: 500 * time.Millisecond,
+		Testing:  50 * time.Millisecond,
+	}).(time.Duration)
+)
+
+const (
+	// diskLatencyPercentile is the percentile of the write latency that is
+	// compared against the diskLatencyThreshold.
+	diskLatencyPercentile = 0.95
+
+	// diskLatencyThrottledRepairs is the number of concurrent repairs that
+	// are allowed while the persist directory is slow and throttling is
+	// enabled.
+	diskLatencyThrottledRepairs = 1
+)
+
+// diskLatencyTracker keeps track of the latency of the most recent writes to
+// the renter's persist directory. Slow persist disks cause renter-wide
+// slowness since siafile, siadir and account writes all block on them. Adding
+// a sample is cheap since the percentiles are only computed by callUpdate and
+// callStatus.
+type diskLatencyTracker struct {
+	// samples is a ring buffer of the most recent writes.
+	samples []diskLatencySample
+	next    int
+	full    bool
+
+	// slow is the result of the last call to callUpdate.
+	slow bool
+
+	mu sync.Mutex
+}
+
+// diskLatencySample is the latency of a single write to the persist
+// directory.
+type diskLatencySample struct {
+	latency   time.Duration
+	timestamp time.Time
+}
+
+// newDiskLatencyTracker creates a new diskLatencyTracker.
+func newDiskLatencyTracker() *diskLatencyTracker {
+	return &diskLatencyTracker{
+		samples: make([]diskLatencySample, diskLatencyNumSamples),
+	}
+}
+
+// callAddSample adds the latency of a write to the tracker, replacing the
+// oldest sample once the buffer is full.
+func (dlt *diskLatencyTracker) callAddSample(latency time.Duration) {
+	dlt.mu.Lock()
+	defer dlt.mu.Unlock()
+	dlt.samples[dlt.next] = diskLatencySample{
+		latency:   latency,
+		timestamp: time.Now(),
+	}
+	dlt.next = (dlt.next + 1) % len(dlt.samples)
+	dlt.full = dlt.full || dlt.next == 0
+}
+
+// callSlow returns whether the disk was considered slow by the last call to
+// callUpdate.
+func (dlt *diskLatencyTracker) callSlow() bool {
+	dlt.mu.Lock()
+	defer dlt.mu.Unlock()
+	return dlt.slow
+}
+
+// callUpdate recomputes whether the disk is slow from the samples which
+// haven't expired yet. It returns the new value and whether it changed.
+func (dlt *diskLatencyTracker) callUpdate() (slow, changed bool) {
+	dlt.mu.Lock()
+	defer dlt.mu.Unlock()
+	slow = sortedSlow(dlt.sortedSamples())
+	changed = slow != dlt.slow
+	dlt.slow = slow
+	return slow, changed
+}
+
+// callStatus returns the latency measurements of the tracker.
+func (dlt *diskLatencyTracker) callStatus() modules.RenterDiskLatency {
+	dlt.mu.Lock()
+	defer dlt.mu.Unlock()
+	sorted := dlt.sortedSamples()
+	status := modules.RenterDiskLatency{
+		Samples:   len(sorted),
+		Slow:      dlt.slow,
+		Threshold: diskLatencyThreshold,
+	}
+	if len(sorted) > 0 {
+		status.P50 = sortedPercentile(sorted, 0.5)
+		status.P95 = sortedPercentile(sorted, diskLatencyPercentile)
+		status.Max = sorted[len(sorted)-1]
+	}
+	return status
+}
+
+// sortedSamples returns the sorted latencies of the samples which haven't
+// expired yet.
+func (dlt *diskLatencyTracker) sortedSamples() []time.Duration {
+	n := dlt.next
+	if dlt.full {
+		n = len(dlt.samples)
+	}
+	sorted := make([]time.Duration, 0, n)
+	for _, sample := range dlt.samples[:n] {
+		if time.Since(sample.timestamp) < diskLatencyMaxSampleAge {
+			sorted = append(sorted, sample.latency)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+	return sorted
+}
+
+// sortedSlow returns whether the sorted samples indicate a slow disk.
+func sortedSlow(sorted []time.Duration) bool {
+	return len(sorted) >= diskLatencyMinSamples && sortedPercentile(sorted, diskLatencyPercentile) >= diskLatencyThreshold
+}
+
+// sortedPercentile returns the p-th percentile of a sorted, non-empty slice of
+// durations.
+func sortedPercentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p * float64(len(sorted)))
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// managedTimePersistWrite executes a write to the renter's persist directory,
+// usually an fsync, and records its latency.
+func (r *Renter) managedTimePersistWrite(write func() error) error {
+	start := time.Now()
+	r.deps.Disrupt("SlowPersistWrite")
+	err := write()
+	r.staticDiskLatency.callAddSample(time.Since(start))
+	return err
+}
+
+// managedUpdateDiskLatency checks whether the persist directory is slow and
+// updates the alert if that changed.
+func (r *Renter) managedUpdateDiskLatency() {
+	slow, changed := r.staticDiskLatency.callUpdate()
+	if !changed {
+		return
+	}
+	if slow {
+		cause := fmt.Sprintf("the 95th percentile latency of the renter's writes to its persist directory is above %v", diskLatencyThreshold)
+		r.staticAlerter.RegisterAlert(modules.AlertIDRenterSlowPersistDisk, AlertMSGSlowPersistDisk, cause, modules.SeverityWarning)
+	} else {
+		r.staticAlerter.UnregisterAlert(modules.AlertIDRenterSlowPersistDisk)
+	}
+}
+
+// threadedUpdateDiskLatency periodically checks whether the persist directory
+// is slow.
+func (r *Renter) threadedUpdateDiskLatency() {
+	err := r.tg.Add()
+	if err != nil {
+		return
+	}
+	defer r.tg.Done()
+	for {
+		select {
+		case <-r.tg.StopChan():
+			return
+		case <-time.After(diskLatencyUpdateInterval):
+		}
+		r.managedUpdateDiskLatency()
+	}
+}
+
+// managedDiskLatencyThrottling returns whether the repairs are currently
+// throttled due to a slow persist directory.
+func (r *Renter) managedDiskLatencyThrottling() bool {
+	slow := r.staticDiskLatency.callSlow()
+	id := r.mu.RLock()
+	throttle := r.persist.DiskLatencyThrottle
+	r.mu.RUnlock(id)
+	return throttle && slow
+}
+
+// DiskLatency returns the latency measurements of the writes to the renter's
+// persist directory.
+func (r *Renter) DiskLatency() modules.RenterDiskLatency {
+	status := r.staticDiskLatency.callStatus()
+	status.Throttling = r.managedDiskLatencyThrottling()
+	return status
+}
@@ -0,0 +1,204 @@
+package renter
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestDiskLatencyTracker is a unit test for the diskLatencyTracker.
+func TestDiskLatencyTracker(t *testing.T) {
+	t.Parallel()
+
+	dlt := newDiskLatencyTracker()
+
+	// Without samples the disk isn't slow.
+	status := dlt.callStatus()
+	if status.Samples != 0 || status.Slow || status.P95 != 0 {
+		t.Fatal("wrong status", status)
+	}
+
+	// Slow samples don't mark the disk as slow until there are enough of
+	// them.
+	for i := 0; i < diskLatencyMinSamples-1; i++ {
+		dlt.callAddSample(2 * diskLatencyThreshold)
+		if slow, changed := dlt.callUpdate(); slow || changed {
+			t.Fatal("disk shouldn't be slow yet", i)
+		}
+	}
+	dlt.callAddSample(2 * diskLatencyThreshold)
+	if dlt.callSlow() {
+		t.Fatal("slow flag shouldn't change before the update")
+	}
+	if slow, changed := dlt.callUpdate(); !slow || !changed {
+		t.Fatal("disk should be slow")
+	}
+	if !dlt.callSlow() {
+		t.Fatal("disk should be slow")
+	}
+	dlt.callAddSample(2 * diskLatencyThreshold)
+	if slow, changed := dlt.callUpdate(); !slow || changed {
+		t.Fatal("disk should still be slow", slow, changed)
+	}
+	status = dlt.callStatus()
+	if !status.Slow || status.Samples != diskLatencyMinSamples+1 || status.P95 != 2*diskLatencyThreshold {
+		t.Fatal("wrong status", status)
+	}
+
+	// Fast samples push the slow ones out of the window eventually. Once the
+	// disk is fast again, it stays fast.
+	var released bool
+	for i := 0; i < diskLatencyNumSamples; i++ {
+		dlt.callAddSample(time.Millisecond)
+		slow, _ := dlt.callUpdate()
+		if slow && released {
+			t.Fatal("disk became slow again", i)
+		}
+		released = !slow
+	}
+	status = dlt.callStatus()
+	if !released || status.Slow || status.Samples != diskLatencyNumSamples {
+		t.Fatal("wrong status", released, status)
+	}
+	if status.P50 != time.Millisecond || status.P95 != time.Millisecond || status.Max != time.Millisecond {
+		t.Fatal("wrong percentiles", status)
+	}
+
+	// Slow samples expire after a while even without new writes.
+	for i := 0; i < diskLatencyNumSamples; i++ {
+		dlt.callAddSample(2 * diskLatencyThreshold)
+	}
+	if slow, _ := dlt.callUpdate(); !slow {
+		t.Fatal("disk should be slow")
+	}
+	dlt.mu.Lock()
+	for i := range dlt.samples {
+		dlt.samples[i].timestamp = time.Now().Add(-diskLatencyMaxSampleAge)
+	}
+	dlt.mu.Unlock()
+	if slow, changed := dlt.callUpdate(); slow || !changed {
+		t.Fatal("expired samples should be ignored", slow, changed)
+	}
+	if status := dlt.callStatus(); status.Samples != 0 || status.Slow {
+		t.Fatal("wrong status", status)
+	}
+}
+
+// TestDiskLatencyThrottle checks that slow writes to the persist directory
+// register an alert and throttle the repairs and that both are released once
+// the writes are fast again.
+func TestDiskLatencyThrottle(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	deps := &dependencies.DependencyToggleSlowPersistWrites{}
+	rt, err := newRenterTesterWithDependency(t.Name(), deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// The syncs of the accounts file on startup are timed.
+	if status := r.DiskLatency(); status.Samples == 0 {
+		t.Fatal("account syncs weren't timed")
+	}
+
+	// Enable the throttle.
+	settings, err := r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.DiskLatencyThrottle = true
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	defaultLimit := r.managedMaxConcurrentRepairs()
+	if defaultLimit <= diskLatencyThrottledRepairs {
+		t.Fatal("default limit is too low for the test", defaultLimit)
+	}
+
+	// hasAlert checks whether the slow disk alert is registered.
+	hasAlert := func() bool {
+		_, _, warn, _ := r.staticAlerter.Alerts()
+		for _, alert := range warn {
+			if alert.Msg == AlertMSGSlowPersistDisk {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Slow down the writes and bubble the root dir to write its metadata.
+	deps.SetLatency(2 * diskLatencyThreshold)
+	for i := 0; i < diskLatencyMinSamples; i++ {
+		if err := rt.bubble(modules.RootSiaPath()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = build.Retry(100, diskLatencyUpdateInterval, func() error {
+		status := r.DiskLatency()
+		if !status.Slow || !status.Throttling || status.P95 < diskLatencyThreshold {
+			return fmt.Errorf("disk should be slow and throttled %v", status)
+		}
+		if !hasAlert() {
+			return errors.New("alert wasn't registered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit := r.managedMaxConcurrentRepairs(); limit != diskLatencyThrottledRepairs {
+		t.Fatal("repairs weren't throttled", limit)
+	}
+
+	// Disabling the throttle lifts the limit while the disk is still slow.
+	settings.DiskLatencyThrottle = false
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if limit := r.managedMaxConcurrentRepairs(); limit != defaultLimit {
+		t.Fatal("repairs shouldn't be throttled", limit)
+	}
+	settings.DiskLatencyThrottle = true
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Speed the writes up again. After enough fast writes, the alert and
+	// throttle should be released.
+	deps.SetLatency(0)
+	for i := 0; i < diskLatencyNumSamples; i++ {
+		if err := rt.bubble(modules.RootSiaPath()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = build.Retry(100, diskLatencyUpdateInterval, func() error {
+		status := r.DiskLatency()
+		if status.Slow || status.Throttling {
+			return fmt.Errorf("disk shouldn't be slow anymore %v", status)
+		}
+		if hasAlert() {
+			return errors.New("alert wasn't unregistered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if limit := r.managedMaxConcurrentRepairs(); limit != defaultLimit {
+		t.Fatal("repairs are still throttled", limit)
+	}
+}
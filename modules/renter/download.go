@@ -46,6 +46,7 @@ type (
 		destination           downloadDestination
 		destinationString     string             // The string reported to the user to indicate the download's destination.
 		staticDestinationType string             // "memory buffer", "http stream", "file", etc.
+		staticGroup           string             // The download group of the download, if any.
 		staticLength          uint64             // Length to download starting from the offset.
 		staticOffset          uint64             // Offset within the file to start the download.
 		staticSiaPath         modules.SiaPath    // The path of the siafile at the time the download started.
@@ -53,6 +54,11 @@ type (
 
 		staticParams downloadParams
 
+		// staticWarnings returns warnings about destinations which failed
+		// without failing the download. It is nil for downloads with a single
+		// destination.
+		staticWarnings func() []string
+
 		// Retrieval settings for the file.
 		staticLatencyTarget time.Duration // In milliseconds. Lower latency results in lower total system throughput.
 		staticOverdrive     int           // How many extra pieces to download to prevent slow hosts from being a bottleneck.
@@ -79,12 +85,25 @@ type (
 
 		staticMemoryManager *memoryManager
 
+		// staticExcludedHosts contains hosts which won't be used for the
+		// download.
+		staticExcludedHosts map[string]struct{}
+
 		// staticSpendingCategory specifies what field to update when we track
 		// the amount of money spent from an ephemeral account
 		staticSpendingCategory spendingCategory
 	}
 )
 
+// managedWarnings returns the warnings about failed destinations of the
+// download.
+func (d *download) managedWarnings() []string {
+	if d.staticWarnings == nil {
+		return nil
+	}
+	return d.staticWarnings()
+}
+
 // managedCancel cancels a download by marking it as failed.
 func (d *download) managedCancel() {
 	d.managedFail(modules.ErrDownloadCancelled)
@@ -260,9 +279,6 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (_ *downloa
 	if p.Async && isHTTPResp {
 		return nil, errors.New("cannot async download to http response")
 	}
-	if isHTTPResp && p.Destination != "" {
-		return nil, errors.New("destination cannot be specified when downloading to http response")
-	}
 	if !isHTTPResp && p.Destination == "" {
 		return nil, errors.New("destination not supplied")
 	}
@@ -284,10 +300,12 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (_ *downloa
 		return nil, fmt.Errorf("offset and length combination invalid, max byte is at index %d", entry.Size()-1)
 	}
 
-	// Instantiate the correct downloadWriter implementation.
+	// Instantiate the correct downloadWriter implementation. If both a http
+	// response and a destination are provided, the data is written to both.
 	var dw downloadDestination
 	var destinationType string
-	if isHTTPResp {
+	var warnings func() []string
+	if isHTTPResp && p.Destination == "" {
 		dw = newDownloadDestinationWriter(p.Httpwriter)
 		destinationType = "http stream"
 	} else {
@@ -302,6 +320,14 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (_ *downloa
 		}
 		destinationType = "file"
 	}
+	if isHTTPResp && p.Destination != "" {
+		// The http stream goes last since it blocks until all prior data was
+		// written.
+		ddm := newDownloadDestinationMulti([]downloadDestination{dw, newDownloadDestinationWriter(p.Httpwriter)}, []string{"file", "http stream"})
+		dw = ddm
+		destinationType = destinationTypeFileAndStream
+		warnings = ddm.managedWarnings
+	}
 
 	// If the destination is a httpWriter, we set the Content-Length in the
 	// header.
@@ -341,6 +367,8 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (_ *downloa
 	} else if err != nil {
 		return nil, err
 	}
+	d.staticWarnings = warnings
+	d.staticGroup = p.Group
 
 	// Register some cleanup for when the download is done.
 	d.OnComplete(func(_ error) error {
@@ -349,7 +377,7 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (_ *downloa
 			return closer.Close()
 		}
 		// sanity check that we close files.
-		if destinationType == "file" {
+		if destinationType == "file" || destinationType == destinationTypeFileAndStream {
 			build.Critical("file wasn't closed after download")
 		}
 		return nil
@@ -362,6 +390,11 @@ func (r *Renter) managedDownload(p modules.RenterDownloadParameters) (_ *downloa
 		r.downloadHistoryMu.Unlock()
 	}
 
+	// Add the download to its group.
+	if p.Group != "" {
+		r.managedAddDownloadToGroup(p.Group, d)
+	}
+
 	// Return the download object
 	return d, nil
 }
@@ -454,6 +487,10 @@ func (d *download) Start() error {
 		pieces := params.file.Pieces(chunkIndex)
 		for pieceIndex, pieceSet := range pieces {
 			for _, piece := range pieceSet {
+				// Skip excluded hosts.
+				if _, excluded := params.staticExcludedHosts[piece.HostPubKey.String()]; excluded {
+					continue
+				}
 				// Sanity check - the same worker should not have two pieces for
 				// the same chunk.
 				_, exists := chunkMaps[chunkIndex-minChunk][piece.HostPubKey.String()]
@@ -556,6 +593,7 @@ func (r *Renter) DownloadByUID(uid modules.DownloadID) (modules.DownloadInfo, bo
 	return modules.DownloadInfo{
 		Destination:     d.destinationString,
 		DestinationType: d.staticDestinationType,
+		Group:           d.staticGroup,
 		Length:          d.staticLength,
 		Offset:          d.staticOffset,
 		SiaPath:         d.staticSiaPath,
@@ -566,6 +604,7 @@ func (r *Renter) DownloadByUID(uid modules.DownloadID) (modules.DownloadInfo, bo
 		StartTime:            d.staticStartTime,
 		StartTimeUnix:        d.staticStartTime.UnixNano(),
 		TotalDataTransferred: atomic.LoadUint64(&d.atomicTotalDataTransferred),
+		Warnings:             d.managedWarnings(),
 	}, true
 }
 
@@ -600,6 +639,7 @@ func (r *Renter) DownloadHistory() []modules.DownloadInfo {
 		downloads[i] = modules.DownloadInfo{
 			Destination:     d.destinationString,
 			DestinationType: d.staticDestinationType,
+			Group:           d.staticGroup,
 			Length:          d.staticLength,
 			Offset:          d.staticOffset,
 			SiaPath:         d.staticSiaPath,
@@ -610,6 +650,7 @@ func (r *Renter) DownloadHistory() []modules.DownloadInfo {
 			StartTime:            d.staticStartTime,
 			StartTimeUnix:        d.staticStartTime.UnixNano(),
 			TotalDataTransferred: atomic.LoadUint64(&d.atomicTotalDataTransferred),
+			Warnings:             d.managedWarnings(),
 		}
 		// Release download lock before calling d.Err(), which will acquire the
 		// lock. The error needs to be checked separately because we need to
@@ -651,6 +692,7 @@ func (r *Renter) ClearDownloadHistory(after, before time.Time) error {
 	// Clear download history if both before and after timestamps are zero values
 	if before.Equal(types.EndOfTime) && after.IsZero() {
 		r.downloadHistory = make(map[modules.DownloadID]*download)
+		r.managedPruneDownloadGroups(r.downloadHistory)
 		return nil
 	}
 
@@ -665,5 +707,6 @@ func (r *Renter) ClearDownloadHistory(after, before time.Time) error {
 		}
 	}
 	r.downloadHistory = filtered
+	r.managedPruneDownloadGroups(r.downloadHistory)
 	return nil
 }
@@ -0,0 +1,84 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// DownloadByRootTimeout is the amount of time a download by root is
+	// allowed to take before it is aborted.
+	DownloadByRootTimeout = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: 5 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+
+	// errInvalidDownloadByRootRange is returned if the requested range is not
+	// within the bounds of a sector.
+	errInvalidDownloadByRootRange = errors.New("requested range is not within the bounds of a sector")
+)
+
+// DownloadByRoot downloads the range [offset, offset+length) of the sector
+// with the given root from any host that stores it. HasSector queries are
+// fanned out to all workers and the download is raced between the fastest
+// hosts that have the sector. The data returned by the hosts is verified
+// against the root using a merkle proof.
+func (r *Renter) DownloadByRoot(root crypto.Hash, offset, length uint64) ([]byte, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+
+	// Check the range.
+	if length == 0 || offset+length < offset || offset+length > modules.SectorSize {
+		return nil, errors.AddContext(errInvalidDownloadByRootRange, fmt.Sprintf("offset %v, length %v", offset, length))
+	}
+
+	// Create a context which expires after the timeout. Cancelling it once the
+	// download is done also cancels any jobs of slower hosts which are still
+	// outstanding.
+	ctx, cancel := context.WithTimeout(r.tg.StopCtx(), DownloadByRootTimeout)
+	defer cancel()
+
+	// Sectors that are downloaded by root are not erasure coded or encrypted
+	// by the renter, so we use a passthrough erasure coder and a plaintext
+	// cipher.
+	ptec := modules.NewPassthroughErasureCoder()
+	ptck, err := crypto.NewSiaKey(crypto.TypePlain, nil)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create plaintext cipher key")
+	}
+
+	// Block until there is memory available for the downloaded data and make
+	// sure it is returned.
+	_, pieceLength := getPieceOffsetAndLen(ptec, offset, length)
+	if !r.userDownloadMemoryManager.Request(ctx, pieceLength, memoryPriorityHigh) {
+		return nil, errors.New("timeout while waiting for memory - server is busy")
+	}
+	defer r.userDownloadMemoryManager.Return(pieceLength)
+
+	// Create the worker set. This launches the HasSector jobs on all workers.
+	pcws, err := r.newPCWSByRoots(ctx, []crypto.Hash{root}, ptec, ptck, 0)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create worker set")
+	}
+
+	// Download the data.
+	respChan, err := pcws.managedDownload(ctx, types.ZeroCurrency, offset, length)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to start download")
+	}
+	resp := <-respChan
+	if resp.err != nil {
+		return nil, errors.AddContext(resp.err, "download by root failed")
+	}
+	return resp.data, nil
+}
@@ -11,6 +11,7 @@ package renter
 //		+ os.File
 //		+ downloadDestinationBuffer (an alias of a []byte)
 //		+ downloadDestinationWriteCloser (created using an io.WriteCloser)
+//		+ downloadDestinationMulti (writes to multiple of the above)
 //
 // There is also a helper function to convert an io.Writer to an io.WriteCloser,
 // so that an io.Writer can be used to create a downloadDestinationWriteCloser
@@ -18,6 +19,7 @@ package renter
 
 import (
 	"bufio"
+	"fmt"
 	"io"
 	"os"
 	"sync"
@@ -142,6 +144,127 @@ func (ddf *downloadDestinationFile) WritePieces(ec modules.ErasureCoder, pieces
 	return errors.AddContext(errors.Compose(err, err2), "unable to write pieces to destination file")
 }
 
+// downloadDestinationMulti is a downloadDestination that writes the recovered
+// data to multiple underlying destinations, e.g. to a http stream and to a file
+// on disk at the same time. The errors of the destinations are tracked
+// independently. Once a destination fails it is closed and skipped for all
+// further writes, while the remaining destinations continue to receive the
+// data. A write only fails once all of the destinations have failed.
+type downloadDestinationMulti struct {
+	destinations []downloadDestination
+	errs         []error
+	closed       []bool
+	staticNames  []string // used to report which destination failed
+	mu           sync.Mutex
+}
+
+// newDownloadDestinationMulti creates a downloadDestination that writes to all
+// of the provided destinations. The names are used to report warnings about
+// failed destinations.
+func newDownloadDestinationMulti(destinations []downloadDestination, names []string) *downloadDestinationMulti {
+	return &downloadDestinationMulti{
+		destinations: destinations,
+		errs:         make([]error, len(destinations)),
+		closed:       make([]bool, len(destinations)),
+		staticNames:  names,
+	}
+}
+
+// Close closes all of the underlying destinations that haven't been closed yet.
+func (ddm *downloadDestinationMulti) Close() (err error) {
+	ddm.mu.Lock()
+	defer ddm.mu.Unlock()
+	for i := range ddm.destinations {
+		err = errors.Compose(err, ddm.close(i))
+	}
+	// Drop the destinations so that the garbage collector can free them while
+	// the warnings remain available.
+	ddm.destinations = nil
+	return err
+}
+
+// WritePieces writes the pieces to all of the destinations which haven't
+// failed yet. An error is only returned if all destinations failed.
+//
+// NOTE: the destinations are written to one after another, in the order they
+// were provided in. Destinations which block until prior data was written,
+// like the downloadDestinationWriter, should therefore be provided last to not
+// stall the others.
+func (ddm *downloadDestinationMulti) WritePieces(ec modules.ErasureCoder, pieces [][]byte, dataOffset uint64, offset int64, length uint64) error {
+	ddm.mu.Lock()
+	destinations := ddm.destinations
+	ddm.mu.Unlock()
+
+	for i, dst := range destinations {
+		ddm.mu.Lock()
+		failed := ddm.errs[i] != nil
+		ddm.mu.Unlock()
+		if failed {
+			continue
+		}
+
+		// Write without holding the lock, writes to a destination might block
+		// until data at lower offsets has been written.
+		err := dst.WritePieces(ec, pieces, dataOffset, offset, length)
+		if err == nil {
+			continue
+		}
+
+		// Track the error and close the destination. This unblocks any calls
+		// to WritePieces which are waiting on this destination.
+		ddm.mu.Lock()
+		if ddm.errs[i] == nil {
+			ddm.errs[i] = err
+			ddm.errs[i] = errors.Compose(ddm.errs[i], ddm.close(i))
+		}
+		ddm.mu.Unlock()
+	}
+
+	// Only return an error if all destinations failed.
+	ddm.mu.Lock()
+	defer ddm.mu.Unlock()
+	var errs []error
+	for _, err := range ddm.errs {
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, err)
+	}
+	return errors.AddContext(errors.Compose(errs...), "all download destinations failed")
+}
+
+// close closes the destination at index i if it can be closed and hasn't been
+// closed yet.
+func (ddm *downloadDestinationMulti) close(i int) error {
+	if ddm.closed[i] {
+		return nil
+	}
+	ddm.closed[i] = true
+	closer, ok := ddm.destinations[i].(io.Closer)
+	if !ok {
+		return nil
+	}
+	err := closer.Close()
+	// A failed destination might have been closed already.
+	if ddm.errs[i] != nil && errors.Contains(err, errClosedStream) {
+		return nil
+	}
+	return err
+}
+
+// managedWarnings returns a warning for every destination which failed.
+func (ddm *downloadDestinationMulti) managedWarnings() []string {
+	ddm.mu.Lock()
+	defer ddm.mu.Unlock()
+	var warnings []string
+	for i, err := range ddm.errs {
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("writing to %v failed: %v", ddm.staticNames[i], err))
+		}
+	}
+	return warnings
+}
+
 // downloadDestinationWriter is a downloadDestination that writes to an
 // underlying data stream. The data stream is expecting sequential data while
 // the download chunks will be written in an arbitrary order using calls to
@@ -1,8 +1,17 @@
 package renter
 
 import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/modules"
 )
 
@@ -20,3 +29,194 @@ func TestWritePiecesPanic(t *testing.T) {
 	rsc, _ := modules.NewRSCode(1, 1)
 	ddw.WritePieces(rsc, [][]byte{}, 0, 0, 0)
 }
+
+// failingWriter is a helper type which fails all writes once more than 'limit'
+// bytes were written to it.
+type failingWriter struct {
+	limit   int
+	written int
+	err     error
+}
+
+// Write implements the io.Writer interface.
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.written+len(p) > fw.limit {
+		return 0, fw.err
+	}
+	fw.written += len(p)
+	return len(p), nil
+}
+
+// TestDownloadDestinationMulti tests writing to multiple destinations at once,
+// including the cases where one of the destinations fails.
+func TestDownloadDestinationMulti(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	t.Run("Success", testDownloadDestinationMultiSuccess)
+	t.Run("StreamFails", testDownloadDestinationMultiStreamFails)
+	t.Run("FileFails", testDownloadDestinationMultiFileFails)
+	t.Run("AllFail", testDownloadDestinationMultiAllFail)
+}
+
+// newTestDownloadDestinationMulti is a helper that creates a multi destination
+// which writes to a file and to the provided writer. It returns the
+// destination and the path of the file.
+func newTestDownloadDestinationMulti(t *testing.T, w io.Writer, chunkSize int64) (*downloadDestinationMulti, *os.File, string) {
+	path := filepath.Join(t.TempDir(), "file")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, modules.DefaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ddf := &downloadDestinationFile{
+		deps:            modules.ProdDependencies,
+		f:               f,
+		staticChunkSize: chunkSize,
+	}
+	ddm := newDownloadDestinationMulti([]downloadDestination{ddf, newDownloadDestinationWriter(w)}, []string{"file", "http stream"})
+	return ddm, f, path
+}
+
+// writeTestChunks is a helper that erasure codes the data in chunks of
+// chunkSize and writes them to the destination in reverse order, to make sure
+// the writes to the stream block on each other.
+func writeTestChunks(ddm *downloadDestinationMulti, data []byte, chunkSize int) []error {
+	rsc, err := modules.NewRSCode(2, 1)
+	if err != nil {
+		return []error{err}
+	}
+	numChunks := len(data) / chunkSize
+	errs := make([]error, numChunks)
+	var wg sync.WaitGroup
+	for i := numChunks - 1; i >= 0; i-- {
+		chunk := data[i*chunkSize : (i+1)*chunkSize]
+		pieces, err := rsc.Encode(chunk)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = ddm.WritePieces(rsc, pieces, 0, int64(i*chunkSize), uint64(chunkSize))
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
+
+// testDownloadDestinationMultiSuccess verifies the data is written to both
+// destinations.
+func testDownloadDestinationMultiSuccess(t *testing.T) {
+	chunkSize := 64
+	data := fastrand.Bytes(chunkSize * 4)
+
+	var buf bytes.Buffer
+	ddm, _, path := newTestDownloadDestinationMulti(t, &buf, int64(chunkSize))
+	for _, err := range writeTestChunks(ddm, data, chunkSize) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ddm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("stream has wrong data")
+	}
+	onDisk, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(onDisk, data) {
+		t.Fatal("file has wrong data")
+	}
+	if len(ddm.managedWarnings()) != 0 {
+		t.Fatal("unexpected warnings", ddm.managedWarnings())
+	}
+}
+
+// testDownloadDestinationMultiStreamFails verifies the file is still written
+// correctly if the stream fails, e.g. because the http client disconnected.
+func testDownloadDestinationMultiStreamFails(t *testing.T) {
+	chunkSize := 64
+	data := fastrand.Bytes(chunkSize * 4)
+
+	errDisconnected := errors.New("client disconnected")
+	fw := &failingWriter{limit: chunkSize, err: errDisconnected}
+	ddm, _, path := newTestDownloadDestinationMulti(t, fw, int64(chunkSize))
+	for _, err := range writeTestChunks(ddm, data, chunkSize) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ddm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	onDisk, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(onDisk, data) {
+		t.Fatal("file has wrong data")
+	}
+	warnings := ddm.managedWarnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "http stream") || !strings.Contains(warnings[0], errDisconnected.Error()) {
+		t.Fatal("unexpected warnings", warnings)
+	}
+}
+
+// testDownloadDestinationMultiFileFails verifies the stream still receives all
+// of the data if writing the file fails.
+func testDownloadDestinationMultiFileFails(t *testing.T) {
+	chunkSize := 64
+	data := fastrand.Bytes(chunkSize * 4)
+
+	var buf bytes.Buffer
+	ddm, f, _ := newTestDownloadDestinationMulti(t, &buf, int64(chunkSize))
+
+	// Close the file to cause the writes to fail.
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range writeTestChunks(ddm, data, chunkSize) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ddm.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), data) {
+		t.Fatal("stream has wrong data")
+	}
+	warnings := ddm.managedWarnings()
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "file") {
+		t.Fatal("unexpected warnings", warnings)
+	}
+}
+
+// testDownloadDestinationMultiAllFail verifies writes fail once all of the
+// destinations failed.
+func testDownloadDestinationMultiAllFail(t *testing.T) {
+	chunkSize := 64
+	data := fastrand.Bytes(chunkSize * 4)
+
+	fw := &failingWriter{err: errors.New("client disconnected")}
+	ddm, f, _ := newTestDownloadDestinationMulti(t, fw, int64(chunkSize))
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var failed bool
+	for _, err := range writeTestChunks(ddm, data, chunkSize) {
+		failed = failed || err != nil
+	}
+	if !failed {
+		t.Fatal("expected writes to fail")
+	}
+	if len(ddm.managedWarnings()) != 2 {
+		t.Fatal("unexpected warnings", ddm.managedWarnings())
+	}
+}
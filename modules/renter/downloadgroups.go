@@ -0,0 +1,172 @@
+package renter
+
+import (
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+type (
+	// downloadGroup is a named set of downloads which are tracked together to
+	// allow for waiting on all of them at once.
+	downloadGroup struct {
+		downloads []*download
+
+		// updateChan is closed and replaced whenever a download is added to or
+		// removed from the group and whenever a download of the group
+		// completes.
+		updateChan chan struct{}
+	}
+)
+
+// managedAddDownloadToGroup adds a download to the download group with the
+// provided name. The group is created if it doesn't exist yet.
+func (r *Renter) managedAddDownloadToGroup(name string, d *download) {
+	r.downloadGroupsMu.Lock()
+	g, exists := r.downloadGroups[name]
+	if !exists {
+		g = &downloadGroup{
+			updateChan: make(chan struct{}),
+		}
+		r.downloadGroups[name] = g
+	}
+	g.downloads = append(g.downloads, d)
+	g.notify()
+	r.downloadGroupsMu.Unlock()
+
+	// Notify the group when the download completes.
+	d.OnComplete(func(_ error) error {
+		r.downloadGroupsMu.Lock()
+		defer r.downloadGroupsMu.Unlock()
+		if g, exists := r.downloadGroups[name]; exists {
+			g.notify()
+		}
+		return nil
+	})
+}
+
+// managedDownloadGroupInfo returns information about the download group with
+// the provided name as well as a channel which is closed on the next update
+// of the group.
+func (r *Renter) managedDownloadGroupInfo(name string) (modules.DownloadGroupInfo, <-chan struct{}, bool) {
+	// Copy the downloads and the update channel. The download locks are not
+	// acquired while holding the group lock since the download's
+	// OnComplete functions acquire the group lock while holding the download
+	// lock.
+	r.downloadGroupsMu.Lock()
+	g, exists := r.downloadGroups[name]
+	if !exists {
+		r.downloadGroupsMu.Unlock()
+		return modules.DownloadGroupInfo{}, nil, false
+	}
+	downloads := append([]*download{}, g.downloads...)
+	updateChan := g.updateChan
+	r.downloadGroupsMu.Unlock()
+
+	info := modules.DownloadGroupInfo{
+		Name:       name,
+		FilesTotal: uint64(len(downloads)),
+	}
+	for _, d := range downloads {
+		info.BytesReceived += atomic.LoadUint64(&d.atomicDataReceived)
+		info.BytesTotal += d.staticLength
+		if !d.staticComplete() {
+			continue
+		}
+		if err := d.Err(); err != nil {
+			info.FilesFailed++
+			info.Errors = append(info.Errors, fmt.Sprintf("%v: %v", d.staticSiaPath, err))
+			continue
+		}
+		info.FilesCompleted++
+	}
+	info.Finished = info.FilesCompleted+info.FilesFailed == info.FilesTotal
+	return info, updateChan, true
+}
+
+// managedPruneDownloadGroups removes the downloads which are no longer part of
+// the provided download history from their groups. Groups without downloads
+// are deleted.
+func (r *Renter) managedPruneDownloadGroups(history map[modules.DownloadID]*download) {
+	r.downloadGroupsMu.Lock()
+	defer r.downloadGroupsMu.Unlock()
+	for name, g := range r.downloadGroups {
+		var downloads []*download
+		for _, d := range g.downloads {
+			if _, exists := history[d.UID()]; exists {
+				downloads = append(downloads, d)
+			}
+		}
+		if len(downloads) == len(g.downloads) {
+			continue
+		}
+		g.downloads = downloads
+		g.notify()
+		if len(downloads) == 0 {
+			delete(r.downloadGroups, name)
+		}
+	}
+}
+
+// notify wakes up all threads waiting for an update of the group.
+func (g *downloadGroup) notify() {
+	close(g.updateChan)
+	g.updateChan = make(chan struct{})
+}
+
+// AwaitDownloadGroup blocks until all downloads of a download group have
+// completed or, if failFast is true, until any download of the group failed.
+// If the timeout is reached first, the current state of the group is returned.
+// A timeout of 0 waits indefinitely.
+func (r *Renter) AwaitDownloadGroup(name string, failFast bool, timeout time.Duration) (modules.DownloadGroupInfo, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.DownloadGroupInfo{}, err
+	}
+	defer r.tg.Done()
+
+	var timeoutChan <-chan time.Time
+	if timeout > 0 {
+		timeoutChan = time.After(timeout)
+	}
+	for {
+		info, updateChan, exists := r.managedDownloadGroupInfo(name)
+		if !exists {
+			return modules.DownloadGroupInfo{}, modules.ErrDownloadGroupNotFound
+		}
+		if info.Finished || (failFast && info.FilesFailed > 0) {
+			return info, nil
+		}
+		select {
+		case <-updateChan:
+		case <-timeoutChan:
+			return info, nil
+		case <-r.tg.StopChan():
+			return modules.DownloadGroupInfo{}, errors.New("renter shutdown before download group finished")
+		}
+	}
+}
+
+// DownloadGroups returns information about all download groups sorted by
+// name.
+func (r *Renter) DownloadGroups() []modules.DownloadGroupInfo {
+	r.downloadGroupsMu.Lock()
+	names := make([]string, 0, len(r.downloadGroups))
+	for name := range r.downloadGroups {
+		names = append(names, name)
+	}
+	r.downloadGroupsMu.Unlock()
+	sort.Strings(names)
+
+	groups := make([]modules.DownloadGroupInfo, 0, len(names))
+	for _, name := range names {
+		info, _, exists := r.managedDownloadGroupInfo(name)
+		if exists {
+			groups = append(groups, info)
+		}
+	}
+	return groups
+}
@@ -0,0 +1,125 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/modules"
+)
+
+// TestDownloadGroups tests awaiting a download group with a failed download
+// using both the fail-fast and the continue policy.
+func TestDownloadGroups(t *testing.T) {
+	t.Parallel()
+
+	r := &Renter{
+		downloadGroups: make(map[string]*downloadGroup),
+	}
+
+	// newGroupDownload creates a download and adds it to a group.
+	var numDownloads int
+	newGroupDownload := func(group string, length uint64) *download {
+		numDownloads++
+		siaPath, err := modules.NewSiaPath(group + "/" + string(rune('a'+numDownloads)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := &download{
+			completeChan:  make(chan struct{}),
+			staticLength:  length,
+			staticSiaPath: siaPath,
+			staticUID:     modules.DownloadID(siaPath.String()),
+			r:             r,
+		}
+		r.managedAddDownloadToGroup(group, d)
+		return d
+	}
+	// complete marks a download as successfully completed.
+	complete := func(d *download) {
+		d.mu.Lock()
+		d.markComplete()
+		d.mu.Unlock()
+	}
+
+	// Create a group with 3 downloads and another group with a single
+	// download.
+	d1 := newGroupDownload("nightly", 10)
+	d2 := newGroupDownload("nightly", 20)
+	d3 := newGroupDownload("nightly", 30)
+	d4 := newGroupDownload("other", 40)
+
+	// Awaiting an unknown group should fail.
+	_, err := r.AwaitDownloadGroup("unknown", false, time.Millisecond)
+	if !errors.Contains(err, modules.ErrDownloadGroupNotFound) {
+		t.Fatal("expected ErrDownloadGroupNotFound", err)
+	}
+
+	// Awaiting the group should time out since no download has completed.
+	info, err := r.AwaitDownloadGroup("nightly", true, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Finished || info.FilesTotal != 3 || info.BytesTotal != 60 || info.FilesCompleted != 0 || info.FilesFailed != 0 {
+		t.Fatal("unexpected group info", info)
+	}
+
+	// Wait for the group using the continue policy in the background.
+	resultChan := make(chan modules.DownloadGroupInfo)
+	go func() {
+		info, err := r.AwaitDownloadGroup("nightly", false, 0)
+		if err != nil {
+			t.Error(err)
+		}
+		resultChan <- info
+	}()
+
+	// Force one download to fail and complete another one. The fail-fast
+	// policy should return right away.
+	d2.managedFail(errors.New("forced failure"))
+	complete(d1)
+	info, err = r.AwaitDownloadGroup("nightly", true, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Finished || info.FilesFailed != 1 || len(info.Errors) != 1 {
+		t.Fatal("unexpected group info", info)
+	}
+
+	// The continue policy should still be waiting for the last download.
+	select {
+	case info := <-resultChan:
+		t.Fatal("await returned before the group finished", info)
+	case <-time.After(100 * time.Millisecond):
+	}
+	complete(d3)
+	select {
+	case info = <-resultChan:
+	case <-time.After(10 * time.Second):
+		t.Fatal("await didn't return after the group finished")
+	}
+	if !info.Finished || info.FilesTotal != 3 || info.FilesCompleted != 2 || info.FilesFailed != 1 || len(info.Errors) != 1 {
+		t.Fatal("unexpected group info", info)
+	}
+
+	// Both groups should be reported sorted by name.
+	groups := r.DownloadGroups()
+	if len(groups) != 2 || groups[0].Name != "nightly" || groups[1].Name != "other" {
+		t.Fatal("unexpected groups", groups)
+	}
+	if groups[1].Finished || groups[1].BytesTotal != 40 {
+		t.Fatal("unexpected group info", groups[1])
+	}
+
+	// Pruning the downloads of the first group from the history should
+	// remove the group.
+	r.managedPruneDownloadGroups(map[modules.DownloadID]*download{d4.UID(): d4})
+	groups = r.DownloadGroups()
+	if len(groups) != 1 || groups[0].Name != "other" {
+		t.Fatal("unexpected groups", groups)
+	}
+	_, err = r.AwaitDownloadGroup("nightly", false, 0)
+	if !errors.Contains(err, modules.ErrDownloadGroupNotFound) {
+		t.Fatal("expected ErrDownloadGroupNotFound", err)
+	}
+}
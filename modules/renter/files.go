@@ -2,6 +2,9 @@ package renter
 
 import (
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/types"
 
 	"gitlab.com/NebulousLabs/errors"
 )
@@ -15,12 +18,40 @@ func (r *Renter) DeleteFile(siaPath modules.SiaPath) error {
 	}
 	defer r.tg.Done()
 
-	// Perform the delete operation.
+	// Grab the UID of the file to be able to remove its chunks from the upload
+	// heap once it is deleted.
+	var uid siafile.SiafileUID
+	if entry, err := r.staticFileSystem.OpenSiaFile(siaPath); err == nil {
+		uid = entry.UID()
+		if err := entry.Close(); err != nil {
+			r.log.Printf("Unable to close siafile %v: %v", siaPath, err)
+		}
+	}
+
+	// Perform the delete operation. Deleting a file also cancels its
+	// conversion.
+	r.conversionMu.Lock()
 	err = r.staticFileSystem.DeleteFile(siaPath)
+	if err == nil {
+		err = r.cancelConversion(siaPath)
+	}
+	r.conversionMu.Unlock()
 	if err != nil {
 		return errors.AddContext(err, "unable to delete siafile from filesystem")
 	}
 
+	// Remove the chunks of the file from the upload heap and its staged copy.
+	if uid != "" {
+		err = r.uploadHeap.managedRemoveByFileUID(uid)
+		if err != nil {
+			r.log.Printf("Unable to remove the chunks of deleted siafile %v from the upload heap: %v", siaPath, err)
+		}
+		err = r.staticUploadStaging.managedRemove(uid)
+		if err != nil {
+			r.log.Printf("Unable to remove the staged copy of deleted siafile %v: %v", siaPath, err)
+		}
+	}
+
 	// Update the filesystem metadata.
 	//
 	// TODO: This is incorrect, should be running the metadata update call on a
@@ -47,12 +78,21 @@ func (r *Renter) FileList(siaPath modules.SiaPath, recursive, cached bool, flf m
 		return err
 	}
 	defer r.tg.Done()
+	// Mark the files that are waiting for the renter to come online.
+	if offlineUploads := r.managedOfflineUploads(); len(offlineUploads) > 0 {
+		listFunc := flf
+		flf = func(fi modules.FileInfo) {
+			_, fi.QueuedOffline = offlineUploads[fi.SiaPath]
+			listFunc(fi)
+		}
+	}
 	var err error
 	if cached {
 		err = r.staticFileSystem.CachedList(siaPath, recursive, flf, func(modules.DirectoryInfo) {})
 	} else {
 		offlineMap, goodForRenewMap, contractsMap := r.managedContractUtilityMaps()
-		err = r.staticFileSystem.List(siaPath, recursive, offlineMap, goodForRenewMap, contractsMap, flf, func(modules.DirectoryInfo) {})
+		hostSubnets := r.managedContractHostSubnets(contractsMap)
+		err = r.staticFileSystem.List(siaPath, recursive, offlineMap, goodForRenewMap, hostSubnets, contractsMap, flf, func(modules.DirectoryInfo) {})
 	}
 	if err != nil {
 		return err
@@ -68,10 +108,12 @@ func (r *Renter) File(siaPath modules.SiaPath) (modules.FileInfo, error) {
 	}
 	defer r.tg.Done()
 	offline, goodForRenew, contracts := r.managedContractUtilityMaps()
-	fi, err := r.staticFileSystem.FileInfo(siaPath, offline, goodForRenew, contracts)
+	fi, err := r.staticFileSystem.FileInfo(siaPath, offline, goodForRenew, r.managedContractHostSubnets(contracts), contracts)
 	if err != nil {
 		return modules.FileInfo{}, errors.AddContext(err, "unable to get the fileinfo from the filesystem")
 	}
+	fi.QueuedOffline = r.managedIsOfflineUpload(siaPath)
+	fi.Converting, fi.ConversionProgress = r.managedConversionProgress(siaPath, false)
 	return fi, nil
 }
 
@@ -82,7 +124,13 @@ func (r *Renter) FileCached(siaPath modules.SiaPath) (modules.FileInfo, error) {
 		return modules.FileInfo{}, err
 	}
 	defer r.tg.Done()
-	return r.staticFileSystem.CachedFileInfo(siaPath)
+	fi, err := r.staticFileSystem.CachedFileInfo(siaPath)
+	if err != nil {
+		return modules.FileInfo{}, err
+	}
+	fi.QueuedOffline = r.managedIsOfflineUpload(siaPath)
+	fi.Converting, fi.ConversionProgress = r.managedConversionProgress(siaPath, true)
+	return fi, nil
 }
 
 // RenameFile takes an existing file and changes the nickname. The original
@@ -94,8 +142,13 @@ func (r *Renter) RenameFile(currentName, newName modules.SiaPath) error {
 	}
 	defer r.tg.Done()
 
-	// Rename file.
+	// Rename file. A conversion of the file is moved along with it.
+	r.conversionMu.Lock()
 	err := r.staticFileSystem.RenameFile(currentName, newName)
+	if err == nil {
+		err = r.renameConversion(currentName, newName)
+	}
+	r.conversionMu.Unlock()
 	if err != nil {
 		return err
 	}
@@ -122,6 +175,81 @@ func (r *Renter) RenameFile(currentName, newName modules.SiaPath) error {
 	return bubblePaths.callRefreshAll()
 }
 
+// ResetFileAbandoned marks the abandoned chunks of a siafile as stuck again
+// and resets their stuck repair attempts.
+func (r *Renter) ResetFileAbandoned(siaPath modules.SiaPath) (err error) {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	// Open the file.
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err = errors.Compose(err, entry.Close())
+	}()
+	// Update the file. Resetting the abandoned chunks marks them as stuck
+	// again which means that their cached metadata is outdated.
+	err = entry.ResetAbandoned()
+	r.staticUnfinishedChunkCache.callRemoveFile(entry.UID())
+	return err
+}
+
+// FileChunks returns debugging information about the chunks of a siafile.
+func (r *Renter) FileChunks(siaPath modules.SiaPath) (_ []modules.ChunkInfo, err error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	// Open the file.
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = errors.Compose(err, entry.Close())
+	}()
+	return entry.ChunkInfos()
+}
+
+// FileRepairCostEstimate returns the estimated cost of uploading all of the
+// file's missing pieces. The estimate is cached in the file's metadata and
+// updated by every bubble.
+func (r *Renter) FileRepairCostEstimate(siaPath modules.SiaPath) (_ types.Currency, err error) {
+	if err := r.tg.Add(); err != nil {
+		return types.ZeroCurrency, err
+	}
+	defer r.tg.Done()
+	// Open the file.
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return types.ZeroCurrency, err
+	}
+	defer func() {
+		err = errors.Compose(err, entry.Close())
+	}()
+	// Use the cached estimate if possible.
+	if cost, valid := entry.RepairCostEstimate(); valid {
+		return cost, nil
+	}
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+	return r.managedRepairCostEstimate(entry, offline, goodForRenew)
+}
+
+// managedRepairCostEstimate computes the estimated cost of uploading all of the
+// file's missing pieces using the price tables of the workers and caches it in
+// the file's metadata. If none of the workers has a price table yet, the
+// estimate is zero and not cached.
+func (r *Renter) managedRepairCostEstimate(entry *filesystem.FileNode, offline, goodForRenew map[string]bool) (types.Currency, error) {
+	cost, _, err := entry.UpdateRepairCostEstimate(offline, goodForRenew, r.staticWorkerPool.callSectorWriteCostsByHost())
+	if err != nil {
+		return types.ZeroCurrency, errors.AddContext(err, "failed to estimate repair cost")
+	}
+	return cost, nil
+}
+
 // SetFileStuck sets the Stuck field of the whole siafile to stuck.
 func (r *Renter) SetFileStuck(siaPath modules.SiaPath, stuck bool) (err error) {
 	if err := r.tg.Add(); err != nil {
@@ -137,5 +265,5 @@ func (r *Renter) SetFileStuck(siaPath modules.SiaPath, stuck bool) (err error) {
 		err = errors.Compose(err, entry.Close())
 	}()
 	// Update the file.
-	return entry.SetAllStuck(stuck)
+	return r.managedSetAllChunksStuck(entry, stuck)
 }
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
@@ -15,7 +16,10 @@ import (
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
 )
 
 // newSiaPath returns a new SiaPath for testing and panics on error
@@ -85,7 +89,11 @@ func TestRenterFileListLocalPath(t *testing.T) {
 	}()
 	id := rt.renter.mu.Lock()
 	entry, _ := rt.renter.newRenterTestFile()
-	if err := entry.SetLocalPath("TestPath"); err != nil {
+	localPath := filepath.Join(rt.dir, "TestPath")
+	if err := ioutil.WriteFile(localPath, fastrand.Bytes(int(entry.Size())), persist.DefaultDiskPermissionsTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.SetLocalPath(localPath); err != nil {
 		t.Fatal(err)
 	}
 	rt.renter.mu.Unlock(id)
@@ -96,8 +104,8 @@ func TestRenterFileListLocalPath(t *testing.T) {
 	if len(files) != 1 {
 		t.Fatal("wrong number of files, got", len(files), "wanted one")
 	}
-	if files[0].LocalPath != "TestPath" {
-		t.Fatal("file had wrong LocalPath: got", files[0].LocalPath, "wanted TestPath")
+	if files[0].LocalPath != localPath {
+		t.Fatal("file had wrong LocalPath: got", files[0].LocalPath, "wanted", localPath)
 	}
 }
 
@@ -511,3 +519,129 @@ func TestRenterFileDir(t *testing.T) {
 		t.Fatal("No .sia file found on disk")
 	}
 }
+
+// TestRenterFileRepairCostEstimate tests estimating the repair cost of a file.
+func TestRenterFileRepairCostEstimate(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a file with a single chunk.
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath := modules.RandomSiaPath()
+	entry, err := r.createRenterTestFileWithParams(siaPath, rsc, crypto.TypeDefaultRenter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := entry.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Without any price tables there is no estimate.
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+	cost, err := r.managedRepairCostEstimate(entry, offline, goodForRenew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cost.IsZero() {
+		t.Fatal("expected zero estimate without price tables", cost)
+	}
+	if _, valid := entry.RepairCostEstimate(); valid {
+		t.Fatal("estimate shouldn't be cached without price tables")
+	}
+
+	// Add a cheap and an expensive worker with valid price tables.
+	addWorker := func(writeBaseCost types.Currency) (types.SiaPublicKey, types.Currency) {
+		hpk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+		pt := modules.RPCPriceTable{
+			WriteBaseCost:   writeBaseCost,
+			WriteLengthCost: types.NewCurrency64(1),
+		}
+		w := &worker{staticHostPubKeyStr: hpk.String()}
+		w.staticSetPriceTable(&workerPriceTable{
+			staticPriceTable: pt,
+			staticExpiryTime: time.Now().Add(time.Hour),
+		})
+		r.staticWorkerPool.mu.Lock()
+		r.staticWorkerPool.workers[w.staticHostPubKeyStr] = w
+		r.staticWorkerPool.mu.Unlock()
+		offline[hpk.String()] = false
+		goodForRenew[hpk.String()] = true
+		return hpk, modules.MDMWriteCost(&pt, modules.SectorSize)
+	}
+	cheapHost, cheapCost := addWorker(types.SiacoinPrecision)
+	_, expensiveCost := addWorker(types.SiacoinPrecision.Mul64(2))
+
+	// Both pieces of the file are missing. One of them is uploaded to each
+	// host.
+	expected := cheapCost.Add(expensiveCost)
+	cost, err = r.managedRepairCostEstimate(entry, offline, goodForRenew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cost.Equals(expected) {
+		t.Fatalf("expected %v but got %v", expected, cost)
+	}
+	cached, valid := entry.RepairCostEstimate()
+	if !valid || !cached.Equals(expected) {
+		t.Fatal("estimate wasn't cached", cached, valid)
+	}
+	cost, err = r.FileRepairCostEstimate(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cost.Equals(expected) {
+		t.Fatalf("expected cached estimate %v but got %v", expected, cost)
+	}
+
+	// Upload a piece to the cheap host. The missing piece needs to be uploaded
+	// to the expensive host. The bubble should update the estimate.
+	if err := entry.AddPiece(cheapHost, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.managedUpdateFileMetadata(entry, offline, goodForRenew, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	cached, valid = entry.RepairCostEstimate()
+	if !valid || !cached.Equals(expensiveCost) {
+		t.Fatalf("expected estimate %v but got %v %v", expensiveCost, cached, valid)
+	}
+
+	// Upload the other piece to a host without a worker. The bubble should
+	// update the estimate of the fully healthy file to zero and persist it.
+	hpk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	if err := entry.AddPiece(hpk, 0, 1, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	offline[hpk.String()] = false
+	goodForRenew[hpk.String()] = true
+	if err := r.managedUpdateFileMetadata(entry, offline, goodForRenew, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	cached, valid = entry.RepairCostEstimate()
+	if !valid || !cached.IsZero() {
+		t.Fatal("expected zero estimate for healthy file", cached, valid)
+	}
+	md, err := siafile.LoadSiaFileMetadata(entry.SiaFilePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !md.CachedRepairCostEstimateValid || !md.CachedRepairCostEstimate.IsZero() {
+		t.Fatal("estimate wasn't persisted", md.CachedRepairCostEstimate, md.CachedRepairCostEstimateValid)
+	}
+}
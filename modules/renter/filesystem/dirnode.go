@@ -170,7 +170,7 @@ func (n *DirNode) UpdateMetadata(md siadir.Metadata) error {
 // managedList returns the files and dirs within the SiaDir specified by siaPath.
 // offlineMap, goodForRenewMap and contractMap don't need to be provided if
 // 'cached' is set to 'true'.
-func (n *DirNode) managedList(fsRoot string, recursive, cached bool, offlineMap map[string]bool, goodForRenewMap map[string]bool, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) error {
+func (n *DirNode) managedList(fsRoot string, recursive, cached bool, offlineMap map[string]bool, goodForRenewMap map[string]bool, hostSubnets map[string][]string, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) error {
 	// Prepare a pool of workers.
 	numThreads := 40
 	dirLoadChan := make(chan *DirNode, numThreads)
@@ -206,7 +206,7 @@ func (n *DirNode) managedList(fsRoot string, recursive, cached bool, offlineMap
 			if cached {
 				fi, err = sf.staticCachedInfo(nodeSiaPath(fsRoot, &sf.node))
 			} else {
-				fi, err = sf.managedFileInfo(nodeSiaPath(fsRoot, &sf.node), offlineMap, goodForRenewMap, contractsMap)
+				fi, err = sf.managedFileInfo(nodeSiaPath(fsRoot, &sf.node), offlineMap, goodForRenewMap, hostSubnets, contractsMap)
 			}
 			if errors.Contains(err, ErrNotExist) {
 				continue
@@ -352,8 +352,9 @@ func (n *DirNode) managedNewSiaFileFromExisting(sf *siafile.SiaFile, chunks siaf
 	}
 	// Add the node to the dir.
 	fileName := strings.TrimSuffix(filepath.Base(currentPath), modules.SiaFileExtension)
+	sf.SetMetadataBatcher(n.staticBatcher)
 	fn := &FileNode{
-		node:    newNode(n, currentPath, fileName, 0, n.staticWal, n.staticLog),
+		node:    newNode(n, currentPath, fileName, 0, n.staticWal, n.staticBatcher, n.staticLog),
 		SiaFile: sf,
 	}
 	n.files[fileName] = fn
@@ -380,9 +381,10 @@ func (n *DirNode) managedNewSiaFileFromLegacyData(fileName string, fd siafile.Fi
 	if err != nil {
 		return nil, err
 	}
+	sf.SetMetadataBatcher(n.staticBatcher)
 	// Add it to the node.
 	fn := &FileNode{
-		node:    newNode(n, path, key, 0, n.staticWal, n.staticLog),
+		node:    newNode(n, path, key, 0, n.staticWal, n.staticBatcher, n.staticLog),
 		SiaFile: sf,
 	}
 	n.files[key] = fn
@@ -584,6 +586,7 @@ func (n *DirNode) managedInfo(siaPath modules.SiaPath) (modules.DirectoryInfo, e
 		AggregateMaxHealthPercentage: modules.HealthPercentage(aggregateMaxHealth),
 		AggregateMinRedundancy:       metadata.AggregateMinRedundancy,
 		AggregateMostRecentModTime:   metadata.AggregateModTime,
+		AggregateNumAbandonedChunks:  metadata.AggregateNumAbandonedChunks,
 		AggregateNumFiles:            metadata.AggregateNumFiles,
 		AggregateNumStuckChunks:      metadata.AggregateNumStuckChunks,
 		AggregateNumSubDirs:          metadata.AggregateNumSubDirs,
@@ -600,6 +603,7 @@ func (n *DirNode) managedInfo(siaPath modules.SiaPath) (modules.DirectoryInfo, e
 		MinRedundancy:       metadata.MinRedundancy,
 		DirMode:             metadata.Mode,
 		MostRecentModTime:   metadata.ModTime,
+		NumAbandonedChunks:  metadata.NumAbandonedChunks,
 		NumFiles:            metadata.NumFiles,
 		NumStuckChunks:      metadata.NumStuckChunks,
 		NumSubDirs:          metadata.NumSubDirs,
@@ -720,8 +724,9 @@ func (n *DirNode) readonlyOpenFile(fileName string) (*FileNode, error) {
 	if err != nil {
 		return nil, errors.AddContext(err, fmt.Sprintf("failed to load SiaFile '%v' from disk", filePath))
 	}
+	sf.SetMetadataBatcher(n.staticBatcher)
 	fn = &FileNode{
-		node:    newNode(n, filePath, fileName, 0, n.staticWal, n.staticLog),
+		node:    newNode(n, filePath, fileName, 0, n.staticWal, n.staticBatcher, n.staticLog),
 		SiaFile: sf,
 	}
 	// Clone the node, give it a new UID and return it.
@@ -755,7 +760,7 @@ func (n *DirNode) openDir(dirName string) (*DirNode, error) {
 	}
 	// Add the dir to the opened dirs.
 	dir = &DirNode{
-		node:        newNode(n, dirPath, dirName, 0, n.staticWal, n.staticLog),
+		node:        newNode(n, dirPath, dirName, 0, n.staticWal, n.staticBatcher, n.staticLog),
 		directories: make(map[string]*DirNode),
 		files:       make(map[string]*FileNode),
 		lazySiaDir:  new(*siadir.SiaDir),
@@ -53,6 +53,11 @@ func (n *FileNode) close() {
 	// Remove node from parent if the current thread was the last one.
 	parent := n.parent
 	if parent != nil && len(n.threads) == 0 {
+		// Flush pending changes since the file will be loaded from disk the
+		// next time it is opened.
+		if err := n.SiaFile.Flush(); err != nil {
+			n.staticLog.Println("WARN: failed to flush pending changes of closed file:", err)
+		}
 		parent.removeFile(n)
 	}
 }
@@ -118,8 +123,10 @@ func (n *FileNode) managedMode() os.FileMode {
 	return n.SiaFile.Mode()
 }
 
-// managedFileInfo returns the FileInfo of the file node.
-func (n *FileNode) managedFileInfo(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) (modules.FileInfo, error) {
+// managedFileInfo returns the FileInfo of the file node. Unlike
+// staticCachedInfo, all the health, redundancy and diversity related fields are
+// computed from scratch.
+func (n *FileNode) managedFileInfo(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, hostSubnets map[string][]string, contracts map[string]modules.RenterContract) (modules.FileInfo, error) {
 	// Build the FileInfo
 	var onDisk bool
 	localPath := n.LocalPath()
@@ -128,41 +135,47 @@ func (n *FileNode) managedFileInfo(siaPath modules.SiaPath, offline map[string]b
 		onDisk = err == nil
 	}
 	_, _, health, stuckHealth, numStuckChunks, repairBytes, stuckBytes := n.Health(offline, goodForRenew)
-	_, redundancy, err := n.Redundancy(offline, goodForRenew)
+	repairRedundancy, redundancy, err := n.Redundancy(offline, goodForRenew)
 	if err != nil {
 		return modules.FileInfo{}, errors.AddContext(err, "failed to get n redundancy")
 	}
+	available, resilient, err := n.Availability(offline, goodForRenew, hostSubnets)
+	if err != nil {
+		return modules.FileInfo{}, errors.AddContext(err, "failed to get n availability")
+	}
 	uploadProgress, uploadedBytes, err := n.UploadProgressAndBytes()
 	if err != nil {
 		return modules.FileInfo{}, errors.AddContext(err, "failed to get upload progress and bytes")
 	}
 	maxHealth := math.Max(health, stuckHealth)
 	fileInfo := modules.FileInfo{
-		AccessTime:       n.AccessTime(),
-		Available:        redundancy >= 1,
-		ChangeTime:       n.ChangeTime(),
-		CipherType:       n.MasterKey().Type().String(),
-		CreateTime:       n.CreateTime(),
-		Expiration:       n.Expiration(contracts),
-		Filesize:         n.Size(),
-		Health:           health,
-		LocalPath:        localPath,
-		MaxHealth:        maxHealth,
-		MaxHealthPercent: modules.HealthPercentage(maxHealth),
-		ModificationTime: n.ModTime(),
-		NumStuckChunks:   numStuckChunks,
-		OnDisk:           onDisk,
-		Recoverable:      onDisk || redundancy >= 1,
-		Redundancy:       redundancy,
-		Renewing:         true,
-		RepairBytes:      repairBytes,
-		SiaPath:          siaPath,
-		Stuck:            numStuckChunks > 0,
-		StuckHealth:      stuckHealth,
-		StuckBytes:       stuckBytes,
-		UID:              n.staticUID,
-		UploadedBytes:    uploadedBytes,
-		UploadProgress:   uploadProgress,
+		AccessTime:         n.AccessTime(),
+		Available:          redundancy >= 1 && available,
+		ChangeTime:         n.ChangeTime(),
+		CipherType:         n.MasterKey().Type().String(),
+		CreateTime:         n.CreateTime(),
+		Expiration:         n.Expiration(contracts),
+		Filesize:           n.Size(),
+		Health:             health,
+		LocalPath:          localPath,
+		MaxHealth:          maxHealth,
+		MaxHealthPercent:   modules.HealthPercentage(maxHealth),
+		ModificationTime:   n.ModTime(),
+		NumAbandonedChunks: n.NumAbandonedChunks(),
+		NumStuckChunks:     numStuckChunks,
+		OnDisk:             onDisk,
+		Recoverable:        onDisk || redundancy >= 1,
+		Redundancy:         redundancy,
+		Renewing:           true,
+		RepairBytes:        repairBytes,
+		Resilient:          repairRedundancy >= 1 && resilient,
+		SiaPath:            siaPath,
+		Stuck:              numStuckChunks > 0,
+		StuckHealth:        stuckHealth,
+		StuckBytes:         stuckBytes,
+		UID:                n.staticUID,
+		UploadedBytes:      uploadedBytes,
+		UploadProgress:     uploadProgress,
 	}
 	return fileInfo, nil
 }
@@ -222,31 +235,33 @@ func (n *FileNode) staticCachedInfo(siaPath modules.SiaPath) (modules.FileInfo,
 	}
 	maxHealth := math.Max(md.CachedHealth, md.CachedStuckHealth)
 	fileInfo := modules.FileInfo{
-		AccessTime:       md.AccessTime,
-		Available:        md.CachedUserRedundancy >= 1,
-		ChangeTime:       md.ChangeTime,
-		CipherType:       md.StaticMasterKeyType.String(),
-		CreateTime:       md.CreateTime,
-		Expiration:       md.CachedExpiration,
-		Filesize:         uint64(md.FileSize),
-		Health:           md.CachedHealth,
-		LocalPath:        localPath,
-		MaxHealth:        maxHealth,
-		MaxHealthPercent: modules.HealthPercentage(maxHealth),
-		ModificationTime: md.ModTime,
-		NumStuckChunks:   md.NumStuckChunks,
-		OnDisk:           onDisk,
-		Recoverable:      onDisk || md.CachedUserRedundancy >= 1,
-		Redundancy:       md.CachedUserRedundancy,
-		Renewing:         true,
-		RepairBytes:      md.CachedRepairBytes,
-		SiaPath:          siaPath,
-		Stuck:            md.NumStuckChunks > 0,
-		StuckBytes:       md.CachedStuckBytes,
-		StuckHealth:      md.CachedStuckHealth,
-		UID:              n.staticUID,
-		UploadedBytes:    md.CachedUploadedBytes,
-		UploadProgress:   md.CachedUploadProgress,
+		AccessTime:         md.AccessTime,
+		Available:          md.CachedUserRedundancy >= 1 && !md.CachedLacksDiversity,
+		ChangeTime:         md.ChangeTime,
+		CipherType:         md.StaticMasterKeyType.String(),
+		CreateTime:         md.CreateTime,
+		Expiration:         md.CachedExpiration,
+		Filesize:           uint64(md.FileSize),
+		Health:             md.CachedHealth,
+		LocalPath:          localPath,
+		MaxHealth:          maxHealth,
+		MaxHealthPercent:   modules.HealthPercentage(maxHealth),
+		ModificationTime:   md.ModTime,
+		NumAbandonedChunks: md.NumAbandonedChunks,
+		NumStuckChunks:     md.NumStuckChunks,
+		OnDisk:             onDisk,
+		Recoverable:        onDisk || md.CachedUserRedundancy >= 1,
+		Redundancy:         md.CachedUserRedundancy,
+		Renewing:           true,
+		RepairBytes:        md.CachedRepairBytes,
+		Resilient:          md.CachedRedundancy >= 1 && !md.CachedLacksRenewDiversity,
+		SiaPath:            siaPath,
+		Stuck:              md.NumStuckChunks > 0,
+		StuckBytes:         md.CachedStuckBytes,
+		StuckHealth:        md.CachedStuckHealth,
+		UID:                n.staticUID,
+		UploadedBytes:      md.CachedUploadedBytes,
+		UploadProgress:     md.CachedUploadProgress,
 	}
 	return fileInfo, nil
 }
@@ -54,6 +54,10 @@ type (
 		staticUID uint64
 		mu        *sync.Mutex
 
+		// staticBatcher batches the metadata writes of the SiaFiles within
+		// the filesystem.
+		staticBatcher *siafile.MetadataBatcher
+
 		// fields that differ between copies of the same node.
 		threadUID threadUID // unique ID of a copy of a node
 	}
@@ -61,17 +65,18 @@ type (
 )
 
 // newNode is a convenience function to initialize a node.
-func newNode(parent *DirNode, path, name string, uid threadUID, wal *writeaheadlog.WAL, log *persist.Logger) node {
+func newNode(parent *DirNode, path, name string, uid threadUID, wal *writeaheadlog.WAL, batcher *siafile.MetadataBatcher, log *persist.Logger) node {
 	return node{
-		path:      &path,
-		parent:    parent,
-		name:      &name,
-		staticLog: log,
-		staticUID: newInode(),
-		staticWal: wal,
-		threads:   make(map[threadUID]struct{}),
-		threadUID: uid,
-		mu:        new(sync.Mutex),
+		path:          &path,
+		parent:        parent,
+		name:          &name,
+		staticBatcher: batcher,
+		staticLog:     log,
+		staticUID:     newInode(),
+		staticWal:     wal,
+		threads:       make(map[threadUID]struct{}),
+		threadUID:     uid,
+		mu:            new(sync.Mutex),
 	}
 }
 
@@ -147,12 +152,13 @@ func (n *node) managedAbsPath() string {
 }
 
 // New creates a new FileSystem at the specified root path. The folder will be
-// created if it doesn't exist already.
-func New(root string, log *persist.Logger, wal *writeaheadlog.WAL) (*FileSystem, error) {
+// created if it doesn't exist already. The batcher is used to batch the
+// metadata writes of the SiaFiles and can be nil to disable batching.
+func New(root string, log *persist.Logger, wal *writeaheadlog.WAL, batcher *siafile.MetadataBatcher) (*FileSystem, error) {
 	fs := &FileSystem{
 		DirNode: DirNode{
 			// The root doesn't require a parent, a name or uid.
-			node:        newNode(nil, root, "", 0, wal, log),
+			node:        newNode(nil, root, "", 0, wal, batcher, log),
 			directories: make(map[string]*DirNode),
 			files:       make(map[string]*FileNode),
 			lazySiaDir:  new(*siadir.SiaDir),
@@ -200,12 +206,12 @@ func (fs *FileSystem) AddSiaFileFromReader(rs io.ReadSeeker, siaPath modules.Sia
 
 // CachedFileInfo returns the cached File Information of the siafile
 func (fs *FileSystem) CachedFileInfo(siaPath modules.SiaPath) (modules.FileInfo, error) {
-	return fs.managedFileInfo(siaPath, true, nil, nil, nil)
+	return fs.managedFileInfo(siaPath, true, nil, nil, nil, nil)
 }
 
 // CachedList lists the files and directories within a SiaDir.
 func (fs *FileSystem) CachedList(siaPath modules.SiaPath, recursive bool, flf modules.FileListFunc, dlf modules.DirListFunc) error {
-	return fs.managedList(siaPath, recursive, true, nil, nil, nil, flf, dlf)
+	return fs.managedList(siaPath, recursive, true, nil, nil, nil, nil, flf, dlf)
 }
 
 // CachedListOnNode will return the files and directories within a given siadir
@@ -222,7 +228,7 @@ func (fs *FileSystem) CachedListOnNode(d *DirNode) (fis []modules.FileInfo, dis
 		dis = append(dis, di)
 		dmu.Unlock()
 	}
-	err = d.managedList(fs.managedAbsPath(), false, true, nil, nil, nil, flf, dlf)
+	err = d.managedList(fs.managedAbsPath(), false, true, nil, nil, nil, nil, flf, dlf)
 
 	// Sort slices by SiaPath.
 	sort.Slice(dis, func(i, j int) bool {
@@ -278,9 +284,10 @@ func (fs *FileSystem) DirNodeInfo(n *DirNode) (modules.DirectoryInfo, error) {
 	return n.managedInfo(sp)
 }
 
-// FileInfo returns the File Information of the siafile
-func (fs *FileSystem) FileInfo(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) (modules.FileInfo, error) {
-	return fs.managedFileInfo(siaPath, false, offline, goodForRenew, contracts)
+// FileInfo returns the File Information of the siafile. hostSubnets maps host
+// keys to the subnets of the hosts and is used to check the file's diversity.
+func (fs *FileSystem) FileInfo(siaPath modules.SiaPath, offline map[string]bool, goodForRenew map[string]bool, hostSubnets map[string][]string, contracts map[string]modules.RenterContract) (modules.FileInfo, error) {
+	return fs.managedFileInfo(siaPath, false, offline, goodForRenew, hostSubnets, contracts)
 }
 
 // FileNodeInfo returns the FileInfo of a siafile given the node for the
@@ -291,8 +298,8 @@ func (fs *FileSystem) FileNodeInfo(n *FileNode) (modules.FileInfo, error) {
 }
 
 // List lists the files and directories within a SiaDir.
-func (fs *FileSystem) List(siaPath modules.SiaPath, recursive bool, offlineMap, goodForRenewMap map[string]bool, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) error {
-	return fs.managedList(siaPath, recursive, false, offlineMap, goodForRenewMap, contractsMap, flf, dlf)
+func (fs *FileSystem) List(siaPath modules.SiaPath, recursive bool, offlineMap, goodForRenewMap map[string]bool, hostSubnets map[string][]string, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) error {
+	return fs.managedList(siaPath, recursive, false, offlineMap, goodForRenewMap, hostSubnets, contractsMap, flf, dlf)
 }
 
 // FileExists checks to see if a file with the provided siaPath already exists
@@ -611,7 +618,7 @@ func (fs *FileSystem) managedDeleteDir(path string) (err error) {
 }
 
 // managedFileInfo returns the FileInfo of the siafile.
-func (fs *FileSystem) managedFileInfo(siaPath modules.SiaPath, cached bool, offline map[string]bool, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) (_ modules.FileInfo, err error) {
+func (fs *FileSystem) managedFileInfo(siaPath modules.SiaPath, cached bool, offline map[string]bool, goodForRenew map[string]bool, hostSubnets map[string][]string, contracts map[string]modules.RenterContract) (_ modules.FileInfo, err error) {
 	// Open the file.
 	file, err := fs.managedOpenFile(siaPath.String())
 	if err != nil {
@@ -623,13 +630,13 @@ func (fs *FileSystem) managedFileInfo(siaPath modules.SiaPath, cached bool, offl
 	if cached {
 		return file.staticCachedInfo(siaPath)
 	}
-	return file.managedFileInfo(siaPath, offline, goodForRenew, contracts)
+	return file.managedFileInfo(siaPath, offline, goodForRenew, hostSubnets, contracts)
 }
 
 // managedList returns the files and dirs within the SiaDir specified by siaPath.
 // offlineMap, goodForRenewMap and contractMap don't need to be provided if
 // 'cached' is set to 'true'.
-func (fs *FileSystem) managedList(siaPath modules.SiaPath, recursive, cached bool, offlineMap map[string]bool, goodForRenewMap map[string]bool, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) (err error) {
+func (fs *FileSystem) managedList(siaPath modules.SiaPath, recursive, cached bool, offlineMap map[string]bool, goodForRenewMap map[string]bool, hostSubnets map[string][]string, contractsMap map[string]modules.RenterContract, flf modules.FileListFunc, dlf modules.DirListFunc) (err error) {
 	// Open the folder.
 	dir, err := fs.managedOpenDir(siaPath.String())
 	if err != nil {
@@ -638,7 +645,7 @@ func (fs *FileSystem) managedList(siaPath modules.SiaPath, recursive, cached boo
 	defer func() {
 		err = errors.Compose(err, dir.Close())
 	}()
-	return dir.managedList(fs.managedAbsPath(), recursive, cached, offlineMap, goodForRenewMap, contractsMap, flf, dlf)
+	return dir.managedList(fs.managedAbsPath(), recursive, cached, offlineMap, goodForRenewMap, hostSubnets, contractsMap, flf, dlf)
 }
 
 // managedNewSiaDir creates the folder at the specified siaPath.
@@ -23,6 +23,7 @@ import (
 	"go.sia.tech/siad/modules/renter/filesystem/siadir"
 	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/types"
 
 	"go.sia.tech/siad/build"
 )
@@ -103,7 +104,7 @@ func newTestFileSystem(root string) *FileSystem {
 	if err != nil {
 		panic(err.Error())
 	}
-	fs, err := New(root, logger, wal)
+	fs, err := New(root, logger, wal, nil)
 	if err != nil {
 		panic(err.Error())
 	}
@@ -2319,3 +2320,81 @@ func TestList(t *testing.T) {
 		t.Fatal("wrong number of dirs", len(dis), len(dirStructure))
 	}
 }
+
+// TestFileInfoUncached verifies that the uncached FileInfo computes the
+// availability and resilience of a file from scratch instead of relying on the
+// values cached by a previous call.
+func TestFileInfoUncached(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a 2-of-3 file.
+	root := filepath.Join(testDir(t.Name()), "fs-root")
+	fs := newTestFileSystem(root)
+	sp := newSiaPath("file")
+	rsc, _ := modules.NewRSCode(2, 1)
+	err := fs.NewSiaFile(sp, "", rsc, crypto.GenerateSiaKey(crypto.TypeDefaultRenter), 100, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, err := fs.OpenSiaFile(sp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Store the pieces of every chunk on 3 different hosts.
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+	var hosts []string
+	for i := 0; i < 3; i++ {
+		pk := types.SiaPublicKey{Key: []byte{byte(i)}}
+		offline[pk.String()] = false
+		goodForRenew[pk.String()] = true
+		hosts = append(hosts, pk.String())
+		for chunkIndex := uint64(0); chunkIndex < sf.NumChunks(); chunkIndex++ {
+			if err := sf.AddPiece(pk, chunkIndex, uint64(i), crypto.Hash{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// checkFileInfo fetches the uncached FileInfo and compares it to the
+	// expected values.
+	checkFileInfo := func(subnets map[string][]string, available, resilient bool) {
+		t.Helper()
+		fi, err := fs.FileInfo(sp, offline, goodForRenew, subnets, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Available != available || fi.Resilient != resilient {
+			t.Fatal("unexpected file info", fi.Available, fi.Resilient)
+		}
+	}
+
+	// The file is healthy.
+	checkFileInfo(nil, true, true)
+
+	// If all hosts share a subnet, the file lacks diversity.
+	checkFileInfo(map[string][]string{
+		hosts[0]: {"1.1.1.0/24"},
+		hosts[1]: {"1.1.1.0/24"},
+		hosts[2]: {"1.1.1.0/24"},
+	}, false, false)
+
+	// If one host is not goodForRenew, the file needs repair but is still
+	// available.
+	goodForRenew[hosts[2]] = false
+	checkFileInfo(nil, true, true)
+
+	// If two hosts are offline, the file is unavailable.
+	offline[hosts[0]] = true
+	offline[hosts[1]] = true
+	checkFileInfo(nil, false, false)
+}
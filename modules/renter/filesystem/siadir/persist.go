@@ -97,6 +97,13 @@ func LoadSiaDir(path string, deps modules.Dependencies) (sd *SiaDir, err error)
 	return sd, err
 }
 
+// LoadSiaDirMetadata loads the metadata of the .siadir file at path. Unlike
+// LoadSiaDir it never tries to repair a corrupted file which makes it safe to
+// use for inspecting the metadata offline.
+func LoadSiaDirMetadata(path string) (Metadata, error) {
+	return callLoadSiaDirMetadata(path, modules.ProdDependencies)
+}
+
 // Delete removes the directory from disk and marks it as deleted. Once the
 // directory is deleted, attempting to access the directory will return an
 // error.
@@ -202,6 +209,8 @@ func (sd *SiaDir) updateMetadata(metadata Metadata) error {
 	sd.metadata.AggregateLastHealthCheckTime = metadata.AggregateLastHealthCheckTime
 	sd.metadata.AggregateMinRedundancy = metadata.AggregateMinRedundancy
 	sd.metadata.AggregateModTime = metadata.AggregateModTime
+	sd.metadata.AggregateNumAbandonedChunks = metadata.AggregateNumAbandonedChunks
+	sd.metadata.AggregateNumChunksNeedingRepair = metadata.AggregateNumChunksNeedingRepair
 	sd.metadata.AggregateNumFiles = metadata.AggregateNumFiles
 	sd.metadata.AggregateNumStuckChunks = metadata.AggregateNumStuckChunks
 	sd.metadata.AggregateNumSubDirs = metadata.AggregateNumSubDirs
@@ -216,6 +225,8 @@ func (sd *SiaDir) updateMetadata(metadata Metadata) error {
 	sd.metadata.MinRedundancy = metadata.MinRedundancy
 	sd.metadata.ModTime = metadata.ModTime
 	sd.metadata.Mode = metadata.Mode
+	sd.metadata.NumAbandonedChunks = metadata.NumAbandonedChunks
+	sd.metadata.NumChunksNeedingRepair = metadata.NumChunksNeedingRepair
 	sd.metadata.NumFiles = metadata.NumFiles
 	sd.metadata.NumStuckChunks = metadata.NumStuckChunks
 	sd.metadata.NumSubDirs = metadata.NumSubDirs
@@ -269,7 +280,7 @@ func callLoadSiaDirMetadata(path string, deps modules.Dependencies) (md Metadata
 
 	// Verify there is enough data for a checksum
 	if len(fileBytes) < crypto.HashSize {
-		return Metadata{}, ErrCorruptFile
+		return Metadata{}, errors.AddContext(ErrCorruptFile, fmt.Sprintf("file is only %v bytes long", len(fileBytes)))
 	}
 
 	// Verify checksum
@@ -277,12 +288,16 @@ func callLoadSiaDirMetadata(path string, deps modules.Dependencies) (md Metadata
 	mdBytes := fileBytes[crypto.HashSize:]
 	fileChecksum := crypto.HashBytes(mdBytes)
 	if !bytes.Equal(checksum, fileChecksum[:]) {
-		return Metadata{}, ErrInvalidChecksum
+		return Metadata{}, errors.AddContext(ErrInvalidChecksum, "checksum at byte offset 0 doesn't match")
 	}
 
 	// Parse the json object.
 	err = json.Unmarshal(mdBytes, &md)
-	if err != nil {
+	if serr, ok := err.(*json.SyntaxError); ok {
+		return Metadata{}, errors.AddContext(err, fmt.Sprintf("unable to unmarshal metadata at byte offset %v", serr.Offset+crypto.HashSize))
+	} else if terr, ok := err.(*json.UnmarshalTypeError); ok {
+		return Metadata{}, errors.AddContext(err, fmt.Sprintf("unable to unmarshal metadata at byte offset %v", terr.Offset+crypto.HashSize))
+	} else if err != nil {
 		return Metadata{}, errors.AddContext(err, "unable to unmarshal metadata")
 	}
 
@@ -43,6 +43,12 @@ type (
 		// ModTime is the last time any of the siafiles in the siadir was
 		// updated
 		//
+		// NumAbandonedChunks is the sum of all the abandoned chunks of any of
+		// the siafiles in the siadir
+		//
+		// NumChunksNeedingRepair is the sum of all the chunks of any of the
+		// siafiles in the siadir that are not stuck but need to be repaired
+		//
 		// NumFiles is the total number of siafiles in a siadir
 		//
 		// NumStuckChunks is the sum of all the Stuck Chunks of any of the
@@ -58,34 +64,38 @@ type (
 		// The following fields are aggregate values of the siadir. These values are
 		// the totals of the siadir and any sub siadirs, or are calculated based on
 		// all the values in the subtree
-		AggregateHealth              float64   `json:"aggregatehealth"`
-		AggregateLastHealthCheckTime time.Time `json:"aggregatelasthealthchecktime"`
-		AggregateMinRedundancy       float64   `json:"aggregateminredundancy"`
-		AggregateModTime             time.Time `json:"aggregatemodtime"`
-		AggregateNumFiles            uint64    `json:"aggregatenumfiles"`
-		AggregateNumStuckChunks      uint64    `json:"aggregatenumstuckchunks"`
-		AggregateNumSubDirs          uint64    `json:"aggregatenumsubdirs"`
-		AggregateRemoteHealth        float64   `json:"aggregateremotehealth"`
-		AggregateRepairSize          uint64    `json:"aggregaterepairsize"`
-		AggregateSize                uint64    `json:"aggregatesize"`
-		AggregateStuckHealth         float64   `json:"aggregatestuckhealth"`
-		AggregateStuckSize           uint64    `json:"aggregatestucksize"`
+		AggregateHealth                 float64   `json:"aggregatehealth"`
+		AggregateLastHealthCheckTime    time.Time `json:"aggregatelasthealthchecktime"`
+		AggregateMinRedundancy          float64   `json:"aggregateminredundancy"`
+		AggregateModTime                time.Time `json:"aggregatemodtime"`
+		AggregateNumAbandonedChunks     uint64    `json:"aggregatenumabandonedchunks"`
+		AggregateNumChunksNeedingRepair uint64    `json:"aggregatenumchunksneedingrepair"`
+		AggregateNumFiles               uint64    `json:"aggregatenumfiles"`
+		AggregateNumStuckChunks         uint64    `json:"aggregatenumstuckchunks"`
+		AggregateNumSubDirs             uint64    `json:"aggregatenumsubdirs"`
+		AggregateRemoteHealth           float64   `json:"aggregateremotehealth"`
+		AggregateRepairSize             uint64    `json:"aggregaterepairsize"`
+		AggregateSize                   uint64    `json:"aggregatesize"`
+		AggregateStuckHealth            float64   `json:"aggregatestuckhealth"`
+		AggregateStuckSize              uint64    `json:"aggregatestucksize"`
 
 		// The following fields are information specific to the siadir that is not
 		// an aggregate of the entire sub directory tree
-		Health              float64     `json:"health"`
-		LastHealthCheckTime time.Time   `json:"lasthealthchecktime"`
-		MinRedundancy       float64     `json:"minredundancy"`
-		Mode                os.FileMode `json:"mode"`
-		ModTime             time.Time   `json:"modtime"`
-		NumFiles            uint64      `json:"numfiles"`
-		NumStuckChunks      uint64      `json:"numstuckchunks"`
-		NumSubDirs          uint64      `json:"numsubdirs"`
-		RemoteHealth        float64     `json:"remotehealth"`
-		RepairSize          uint64      `json:"repairsize"`
-		Size                uint64      `json:"size"`
-		StuckHealth         float64     `json:"stuckhealth"`
-		StuckSize           uint64      `json:"stucksize"`
+		Health                 float64     `json:"health"`
+		LastHealthCheckTime    time.Time   `json:"lasthealthchecktime"`
+		MinRedundancy          float64     `json:"minredundancy"`
+		Mode                   os.FileMode `json:"mode"`
+		ModTime                time.Time   `json:"modtime"`
+		NumAbandonedChunks     uint64      `json:"numabandonedchunks"`
+		NumChunksNeedingRepair uint64      `json:"numchunksneedingrepair"`
+		NumFiles               uint64      `json:"numfiles"`
+		NumStuckChunks         uint64      `json:"numstuckchunks"`
+		NumSubDirs             uint64      `json:"numsubdirs"`
+		RemoteHealth           float64     `json:"remotehealth"`
+		RepairSize             uint64      `json:"repairsize"`
+		Size                   uint64      `json:"size"`
+		StuckHealth            float64     `json:"stuckhealth"`
+		StuckSize              uint64      `json:"stucksize"`
 
 		// Version is the used version of the header file.
 		Version string `json:"version"`
@@ -185,12 +185,26 @@ func unmarshalPubKeyTable(raw []byte) (keys []HostPublicKey, err error) {
 	// Unmarshal the keys one by one until EOF or a different error occur.
 	for {
 		var key HostPublicKey
+		offset := len(raw) - r.Len()
 		if err = key.UnmarshalSia(r); errors.Contains(err, io.EOF) {
 			break
 		} else if err != nil {
-			return nil, err
+			return nil, errors.AddContext(err, fmt.Sprintf("failed to unmarshal key %v at table offset %v", len(keys), offset))
 		}
 		keys = append(keys, key)
 	}
 	return keys, nil
 }
+
+// jsonErrorOffset returns the byte offset at which decoding a json object
+// failed or -1 if the error doesn't contain that information.
+func jsonErrorOffset(err error) int64 {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return e.Offset
+	case *json.UnmarshalTypeError:
+		return e.Offset
+	default:
+		return -1
+	}
+}
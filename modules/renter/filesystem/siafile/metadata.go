@@ -49,6 +49,11 @@ type (
 		StaticPieceSize     uint64   `json:"piecesize"`     // size of a single piece of the file
 		LocalPath           string   `json:"localpath"`     // file to the local copy of the file used for repairing
 
+		// ConversionSourceUID is the UID of the file whose erasure code is
+		// being changed by replacing it with this file. It is empty for files
+		// which aren't conversion siafiles.
+		ConversionSourceUID SiafileUID `json:"conversionsourceuid"`
+
 		// Fields for encryption
 		StaticMasterKey      []byte            `json:"masterkey"` // masterkey used to encrypt pieces
 		StaticMasterKeyType  crypto.CipherType `json:"masterkeytype"`
@@ -84,6 +89,9 @@ type (
 		// CachedHealth is the health of the file on the network and is also
 		// periodically updated by the health check loop whenever 'Health' is called.
 		//
+		// CachedNumChunksNeedingRepair is the number of unstuck chunks of the file
+		// which need to be repaired. It is updated whenever 'Health' is called.
+		//
 		// CachedStuckHealth is the health of the stuck chunks of the file. It is
 		// updated by the health check loop. CachedExpiration is the lowest height at
 		// which any of the file's contracts will expire. Also updated periodically by
@@ -95,16 +103,36 @@ type (
 		//
 		// CachedUploadProgress is the upload progress of the file and is updated
 		// every time a piece is added to the siafile.
-		CachedRedundancy     float64           `json:"cachedredundancy"`
-		CachedRepairBytes    uint64            `json:"cachedrepairbytes"`
-		CachedUserRedundancy float64           `json:"cacheduserredundancy"`
-		CachedHealth         float64           `json:"cachedhealth"`
-		CachedNumStuckChunks uint64            `json:"cachednumstuckchunks"`
-		CachedStuckBytes     uint64            `json:"cachedstuckbytes"`
-		CachedStuckHealth    float64           `json:"cachedstuckhealth"`
-		CachedExpiration     types.BlockHeight `json:"cachedexpiration"`
-		CachedUploadedBytes  uint64            `json:"cacheduploadedbytes"`
-		CachedUploadProgress float64           `json:"cacheduploadprogress"`
+		//
+		// CachedRepairCostEstimate is the estimated cost of uploading all of the
+		// file's missing pieces based on the price tables of the renter's hosts.
+		// It is updated every time the file's metadata is bubbled.
+		// CachedRepairCostEstimateValid indicates whether there were price
+		// tables to compute the estimate with.
+		//
+		// CachedLacksDiversity and CachedLacksRenewDiversity indicate that at
+		// least one chunk of the file doesn't have MinPieces pieces on distinct
+		// hosts in distinct subnets when considering all online hosts or only
+		// the goodForRenew hosts respectively. They are updated within the
+		// 'Availability' method and are negated so that files which were
+		// persisted before the fields existed aren't reported as unavailable.
+		CachedRedundancy             float64           `json:"cachedredundancy"`
+		CachedRepairBytes            uint64            `json:"cachedrepairbytes"`
+		CachedUserRedundancy         float64           `json:"cacheduserredundancy"`
+		CachedHealth                 float64           `json:"cachedhealth"`
+		CachedNumStuckChunks         uint64            `json:"cachednumstuckchunks"`
+		CachedNumChunksNeedingRepair uint64            `json:"cachednumchunksneedingrepair"`
+		CachedStuckBytes             uint64            `json:"cachedstuckbytes"`
+		CachedStuckHealth            float64           `json:"cachedstuckhealth"`
+		CachedExpiration             types.BlockHeight `json:"cachedexpiration"`
+		CachedUploadedBytes          uint64            `json:"cacheduploadedbytes"`
+		CachedUploadProgress         float64           `json:"cacheduploadprogress"`
+
+		CachedRepairCostEstimate      types.Currency `json:"cachedrepaircostestimate"`
+		CachedRepairCostEstimateValid bool           `json:"cachedrepaircostestimatevalid"`
+
+		CachedLacksDiversity      bool `json:"cachedlacksdiversity"`
+		CachedLacksRenewDiversity bool `json:"cachedlacksrenewdiversity"`
 
 		// Repair loop fields
 		//
@@ -114,6 +142,9 @@ type (
 		// LastHealthCheckTime is the timestamp of the last time the SiaFile's
 		// health was checked by Health()
 		//
+		// NumAbandonedChunks is the number of the SiaFile's chunks that the
+		// stuck loop gave up on repairing after too many failed attempts.
+		//
 		// NumStuckChunks is the number of all the SiaFile's chunks that have
 		// been marked as stuck by the repair loop. This doesn't include a potential
 		// partial chunk at the end of the file though. Use 'numStuckChunks()' for
@@ -125,6 +156,7 @@ type (
 		// StuckHealth is the worst health of any of the file's stuck chunks
 		Health              float64   `json:"health"`
 		LastHealthCheckTime time.Time `json:"lasthealthchecktime"`
+		NumAbandonedChunks  uint64    `json:"numabandonedchunks"`
 		NumStuckChunks      uint64    `json:"numstuckchunks"`
 		Redundancy          float64   `json:"redundancy"`
 		RepairBytes         uint64    `json:"repairbytes"`
@@ -168,17 +200,19 @@ type (
 
 	// BubbledMetadata is the metadata of a siafile that gets bubbled
 	BubbledMetadata struct {
-		Health              float64
-		LastHealthCheckTime time.Time
-		ModTime             time.Time
-		NumStuckChunks      uint64
-		OnDisk              bool
-		Redundancy          float64
-		RepairBytes         uint64
-		Size                uint64
-		StuckBytes          uint64
-		StuckHealth         float64
-		UID                 SiafileUID
+		Health                 float64
+		LastHealthCheckTime    time.Time
+		ModTime                time.Time
+		NumAbandonedChunks     uint64
+		NumChunksNeedingRepair uint64
+		NumStuckChunks         uint64
+		OnDisk                 bool
+		Redundancy             float64
+		RepairBytes            uint64
+		Size                   uint64
+		StuckBytes             uint64
+		StuckHealth            float64
+		UID                    SiafileUID
 	}
 )
 
@@ -237,6 +271,14 @@ func (sf *SiaFile) LocalPath() string {
 	return sf.staticMetadata.LocalPath
 }
 
+// ConversionSourceUID returns the UID of the file whose erasure code is being
+// changed by replacing it with this file.
+func (sf *SiaFile) ConversionSourceUID() SiafileUID {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.staticMetadata.ConversionSourceUID
+}
+
 // MasterKey returns the masterkey used to encrypt the file.
 func (sf *SiaFile) MasterKey() crypto.CipherKey {
 	return sf.staticMasterKey()
@@ -266,6 +308,14 @@ func (sf *SiaFile) ModTime() time.Time {
 	return sf.staticMetadata.ModTime
 }
 
+// NumAbandonedChunks returns the number of abandoned chunks recorded in the
+// file's metadata.
+func (sf *SiaFile) NumAbandonedChunks() uint64 {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.staticMetadata.NumAbandonedChunks
+}
+
 // NumStuckChunks returns the Number of Stuck Chunks recorded in the file's
 // metadata
 func (sf *SiaFile) NumStuckChunks() uint64 {
@@ -279,6 +329,14 @@ func (sf *SiaFile) PieceSize() uint64 {
 	return sf.staticMetadata.StaticPieceSize
 }
 
+// RepairCostEstimate returns the cached repair cost estimate of the file and
+// whether it is still valid.
+func (sf *SiaFile) RepairCostEstimate() (types.Currency, bool) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	return sf.staticMetadata.CachedRepairCostEstimate, sf.staticMetadata.CachedRepairCostEstimateValid
+}
+
 // Rename changes the name of the file to a new one. To guarantee that renaming
 // the file is atomic across all operating systems, we create a wal transaction
 // that moves over all the chunks one-by-one and deletes the src file.
@@ -309,6 +367,7 @@ func (md Metadata) backup() (b Metadata) {
 	b.UniqueID = md.UniqueID
 	b.FileSize = md.FileSize
 	b.LocalPath = md.LocalPath
+	b.ConversionSourceUID = md.ConversionSourceUID
 	b.DisablePartialChunk = md.DisablePartialChunk
 	b.HasPartialChunk = md.HasPartialChunk
 	b.ModTime = md.ModTime
@@ -321,12 +380,18 @@ func (md Metadata) backup() (b Metadata) {
 	b.CachedUserRedundancy = md.CachedUserRedundancy
 	b.CachedHealth = md.CachedHealth
 	b.CachedNumStuckChunks = md.CachedNumStuckChunks
+	b.CachedNumChunksNeedingRepair = md.CachedNumChunksNeedingRepair
 	b.CachedStuckHealth = md.CachedStuckHealth
 	b.CachedExpiration = md.CachedExpiration
 	b.CachedUploadedBytes = md.CachedUploadedBytes
 	b.CachedUploadProgress = md.CachedUploadProgress
+	b.CachedRepairCostEstimate = md.CachedRepairCostEstimate
+	b.CachedRepairCostEstimateValid = md.CachedRepairCostEstimateValid
+	b.CachedLacksDiversity = md.CachedLacksDiversity
+	b.CachedLacksRenewDiversity = md.CachedLacksRenewDiversity
 	b.Health = md.Health
 	b.LastHealthCheckTime = md.LastHealthCheckTime
+	b.NumAbandonedChunks = md.NumAbandonedChunks
 	b.NumStuckChunks = md.NumStuckChunks
 	b.RepairBytes = md.RepairBytes
 	b.StuckBytes = md.StuckBytes
@@ -360,6 +425,7 @@ func (md *Metadata) restore(b Metadata) {
 	md.UniqueID = b.UniqueID
 	md.FileSize = b.FileSize
 	md.LocalPath = b.LocalPath
+	md.ConversionSourceUID = b.ConversionSourceUID
 	md.DisablePartialChunk = b.DisablePartialChunk
 	md.PartialChunks = b.PartialChunks
 	md.HasPartialChunk = b.HasPartialChunk
@@ -373,12 +439,18 @@ func (md *Metadata) restore(b Metadata) {
 	md.CachedUserRedundancy = b.CachedUserRedundancy
 	md.CachedHealth = b.CachedHealth
 	md.CachedNumStuckChunks = b.CachedNumStuckChunks
+	md.CachedNumChunksNeedingRepair = b.CachedNumChunksNeedingRepair
 	md.CachedStuckHealth = b.CachedStuckHealth
 	md.CachedExpiration = b.CachedExpiration
 	md.CachedUploadedBytes = b.CachedUploadedBytes
 	md.CachedUploadProgress = b.CachedUploadProgress
+	md.CachedRepairCostEstimate = b.CachedRepairCostEstimate
+	md.CachedRepairCostEstimateValid = b.CachedRepairCostEstimateValid
+	md.CachedLacksDiversity = b.CachedLacksDiversity
+	md.CachedLacksRenewDiversity = b.CachedLacksRenewDiversity
 	md.Health = b.Health
 	md.LastHealthCheckTime = b.LastHealthCheckTime
+	md.NumAbandonedChunks = b.NumAbandonedChunks
 	md.NumStuckChunks = b.NumStuckChunks
 	md.RepairBytes = b.RepairBytes
 	md.StuckBytes = b.StuckBytes
@@ -496,6 +568,38 @@ func (sf *SiaFile) SetMode(mode os.FileMode) (err error) {
 	return sf.createAndApplyTransaction(updates...)
 }
 
+// InheritMetadata copies the UID, local path, timestamps and ownership of md
+// into the metadata of the sia file. It is used when the sia file replaces the
+// file md belongs to, which should look like the same file to the user
+// afterwards.
+func (sf *SiaFile) InheritMetadata(md Metadata) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	sf.staticMetadata.UniqueID = md.UniqueID
+	sf.staticMetadata.LocalPath = md.LocalPath
+	sf.staticMetadata.ModTime = md.ModTime
+	sf.staticMetadata.AccessTime = md.AccessTime
+	sf.staticMetadata.CreateTime = md.CreateTime
+	sf.staticMetadata.ChangeTime = time.Now()
+	sf.staticMetadata.Mode = md.Mode
+	sf.staticMetadata.UserID = md.UserID
+	sf.staticMetadata.GroupID = md.GroupID
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
 // SetLastHealthCheckTime sets the LastHealthCheckTime in memory to the current
 // time but does not update and write to disk.
 //
@@ -508,8 +612,18 @@ func (sf *SiaFile) SetLastHealthCheckTime() {
 }
 
 // SetLocalPath changes the local path of the file which is used to repair
-// the file from disk.
+// the file from disk. Unless the path is empty, which removes the local path,
+// it needs to point to an existing regular file of the same size as the
+// SiaFile.
 func (sf *SiaFile) SetLocalPath(path string) (err error) {
+	// Check the path before acquiring the lock to avoid blocking other
+	// operations on the file while accessing the disk. The size of a SiaFile
+	// never changes.
+	if path != "" {
+		if err := checkLocalPath(path, int64(sf.Size())); err != nil {
+			return err
+		}
+	}
 	sf.mu.Lock()
 	defer sf.mu.Unlock()
 	// backup the changed metadata before changing it. Revert the change on
@@ -530,6 +644,48 @@ func (sf *SiaFile) SetLocalPath(path string) (err error) {
 	return sf.createAndApplyTransaction(updates...)
 }
 
+// SetConversionSourceUID marks the file as the conversion siafile of the file
+// with the given UID.
+func (sf *SiaFile) SetConversionSourceUID(uid SiafileUID) (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+
+	sf.staticMetadata.ConversionSourceUID = uid
+
+	// Save changes to metadata to disk.
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	return sf.createAndApplyTransaction(updates...)
+}
+
+// checkLocalPath checks that path points to an existing regular file of the
+// given size.
+func checkLocalPath(path string, size int64) error {
+	fi, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return errors.AddContext(ErrInvalidLocalPath, fmt.Sprintf("file %v doesn't exist", path))
+	}
+	if err != nil {
+		return errors.AddContext(err, "failed to get fileinfo of the file")
+	}
+	if !fi.Mode().IsRegular() {
+		return errors.AddContext(ErrInvalidLocalPath, fmt.Sprintf("%v is not a regular file", path))
+	}
+	if fi.Size() != size {
+		return errors.AddContext(ErrInvalidLocalPath, fmt.Sprintf("file sizes don't match - want %v but got %v", size, fi.Size()))
+	}
+	return nil
+}
+
 // Size returns the file's size.
 func (sf *SiaFile) Size() uint64 {
 	sf.mu.RLock()
@@ -567,6 +723,11 @@ func (sf *SiaFile) UpdateAccessTime() (err error) {
 // metadata.
 func (sf *SiaFile) numStuckChunks() uint64 {
 	numStuckChunks := sf.staticMetadata.NumStuckChunks
+	// Files loaded using LoadSiaFileReadonly don't have access to the
+	// partials siafile.
+	if sf.partialsSiaFile == nil {
+		return numStuckChunks
+	}
 	for _, cc := range sf.staticMetadata.PartialChunks {
 		stuck, err := sf.partialsSiaFile.StuckChunkByIndex(cc.Index)
 		if err != nil {
@@ -2,6 +2,7 @@ package siafile
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -12,6 +13,7 @@ import (
 	"gitlab.com/NebulousLabs/fastrand"
 	"gitlab.com/NebulousLabs/writeaheadlog"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
 )
 
@@ -150,3 +152,74 @@ func TestBackupRestoreMetadata(t *testing.T) {
 		t.Fatalf("metadata wasn't restored successfully %v %v", mdBefore, sf.staticMetadata)
 	}
 }
+
+// TestSetLocalPath probes the validation of the new path in SetLocalPath.
+func TestSetLocalPath(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	sf := newTestFile()
+	oldPath := sf.LocalPath()
+
+	// Create a directory for the local files.
+	dir := filepath.Join(os.TempDir(), t.Name())
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// A path that doesn't exist should be rejected.
+	err := sf.SetLocalPath(filepath.Join(dir, "missing"))
+	if !errors.Contains(err, ErrInvalidLocalPath) {
+		t.Fatal("expected ErrInvalidLocalPath", err)
+	}
+
+	// A directory should be rejected.
+	err = sf.SetLocalPath(dir)
+	if !errors.Contains(err, ErrInvalidLocalPath) {
+		t.Fatal("expected ErrInvalidLocalPath", err)
+	}
+
+	// A file of the wrong size should be rejected.
+	wrongSizePath := filepath.Join(dir, "wrongsize")
+	if err := ioutil.WriteFile(wrongSizePath, fastrand.Bytes(int(sf.Size())+1), persist.DefaultDiskPermissionsTest); err != nil {
+		t.Fatal(err)
+	}
+	err = sf.SetLocalPath(wrongSizePath)
+	if !errors.Contains(err, ErrInvalidLocalPath) {
+		t.Fatal("expected ErrInvalidLocalPath", err)
+	}
+
+	// None of the failed attempts should have changed the path.
+	if sf.LocalPath() != oldPath {
+		t.Fatal("local path was changed", sf.LocalPath())
+	}
+
+	// A file of the right size should be persisted.
+	localPath := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(localPath, fastrand.Bytes(int(sf.Size())), persist.DefaultDiskPermissionsTest); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.SetLocalPath(localPath); err != nil {
+		t.Fatal(err)
+	}
+	sf2, err := LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf2.LocalPath() != localPath {
+		t.Fatalf("expected local path %v but got %v", localPath, sf2.LocalPath())
+	}
+
+	// An empty path removes the local path.
+	if err := sf.SetLocalPath(""); err != nil {
+		t.Fatal(err)
+	}
+	if sf.LocalPath() != "" {
+		t.Fatal("local path wasn't removed", sf.LocalPath())
+	}
+}
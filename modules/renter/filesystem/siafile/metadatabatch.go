@@ -0,0 +1,282 @@
+package siafile
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/writeaheadlog"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+const (
+	// maxFlushRetryInterval is the maximum time between retries of a failed
+	// background flush. The time between retries starts at the batch window
+	// and doubles with every failed retry.
+	maxFlushRetryInterval = 10 * time.Minute
+)
+
+// MetadataBatcher batches the metadata writes caused by adding pieces to
+// SiaFiles. Instead of persisting every added piece right away, the changed
+// chunks of a file are kept in memory and written to disk within a single
+// transaction once the batch window has passed. This reduces the number of
+// syncs during high-churn repairs at the cost of losing at most a window's
+// worth of added pieces on an unclean shutdown, which the repair code will
+// re-establish.
+type MetadataBatcher struct {
+	// atomicWindow is the batch window in nanoseconds. A window of 0 disables
+	// batching.
+	atomicWindow int64
+
+	// stats
+	atomicBatchedPieces uint64
+	atomicFailedFlushes uint64
+	atomicFlushes       uint64
+
+	// files contains the files with pending changes.
+	files  map[*SiaFile]struct{}
+	closed bool
+	mu     sync.Mutex
+
+	// staticLog is used to log flushes which fail in the background. It may
+	// be nil.
+	staticLog *persist.Logger
+}
+
+// NewMetadataBatcher creates a new MetadataBatcher with the provided window.
+// Failed background flushes are logged to the provided logger if it isn't nil.
+func NewMetadataBatcher(window time.Duration, log *persist.Logger) *MetadataBatcher {
+	return &MetadataBatcher{
+		atomicWindow: int64(window),
+		files:        make(map[*SiaFile]struct{}),
+		staticLog:    log,
+	}
+}
+
+// Close flushes the pending changes of all files and disables batching. The
+// pending background flushes are stopped, changes which fail to flush aren't
+// retried anymore.
+func (b *MetadataBatcher) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	files := make([]*SiaFile, 0, len(b.files))
+	for sf := range b.files {
+		files = append(files, sf)
+	}
+	b.mu.Unlock()
+
+	var errs []error
+	for _, sf := range files {
+		sf.mu.Lock()
+		if sf.flushTimer != nil {
+			sf.flushTimer.Stop()
+			sf.flushTimer = nil
+		}
+		err := sf.flushPendingChanges()
+		sf.mu.Unlock()
+		if err != nil {
+			errs = append(errs, errors.AddContext(err, "failed to flush pending changes of "+sf.siaFilePath))
+		}
+	}
+	return errors.Compose(errs...)
+}
+
+// SetWindow sets the batch window. A window of 0 disables batching.
+func (b *MetadataBatcher) SetWindow(window time.Duration) {
+	atomic.StoreInt64(&b.atomicWindow, int64(window))
+}
+
+// Stats returns the batcher's stats.
+func (b *MetadataBatcher) Stats() modules.MetadataBatchStats {
+	b.mu.Lock()
+	pendingFiles := uint64(len(b.files))
+	b.mu.Unlock()
+	return modules.MetadataBatchStats{
+		BatchedPieces: atomic.LoadUint64(&b.atomicBatchedPieces),
+		FailedFlushes: atomic.LoadUint64(&b.atomicFailedFlushes),
+		Flushes:       atomic.LoadUint64(&b.atomicFlushes),
+		PendingFiles:  pendingFiles,
+		Window:        b.window(),
+	}
+}
+
+// staticFlushFailed records a failed background flush of a file's pending
+// changes.
+func (b *MetadataBatcher) staticFlushFailed(sf *SiaFile, err error) {
+	atomic.AddUint64(&b.atomicFailedFlushes, 1)
+	if b.staticLog != nil {
+		b.staticLog.Printf("WARN: failed to flush pending changes of %v: %v", sf.siaFilePath, err)
+	}
+}
+
+// window returns the batch window of the batcher. It's safe to call on a nil
+// batcher.
+func (b *MetadataBatcher) window() time.Duration {
+	if b == nil {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&b.atomicWindow))
+}
+
+// managedTrack tracks a file with pending changes. It returns false if the
+// batcher was closed.
+func (b *MetadataBatcher) managedTrack(sf *SiaFile) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return false
+	}
+	b.files[sf] = struct{}{}
+	return true
+}
+
+// managedClosed returns whether the batcher was closed.
+func (b *MetadataBatcher) managedClosed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.closed
+}
+
+// managedUntrack stops tracking a file once its pending changes were flushed.
+func (b *MetadataBatcher) managedUntrack(sf *SiaFile) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.files, sf)
+}
+
+// SetMetadataBatcher sets the batcher which is used to batch the metadata
+// writes of the file. A nil batcher disables batching.
+func (sf *SiaFile) SetMetadataBatcher(b *MetadataBatcher) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	sf.batcher = b
+}
+
+// Flush writes the file's pending changes to disk.
+func (sf *SiaFile) Flush() error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	return sf.flushPendingChanges()
+}
+
+// batchChunk adds a chunk with an added piece to the file's pending changes
+// instead of writing it to disk. The changes are flushed when the batch window
+// passes. It returns false if the batcher doesn't accept the chunk, in which
+// case it needs to be persisted right away.
+func (sf *SiaFile) batchChunk(c chunk) bool {
+	window := sf.batcher.window()
+	if window == 0 || !sf.batcher.managedTrack(sf) {
+		return false
+	}
+	if sf.pendingChunks == nil {
+		sf.pendingChunks = make(map[int]chunk)
+	}
+	sf.pendingChunks[c.Index] = c
+	sf.pendingMetadata = true
+	atomic.AddUint64(&sf.batcher.atomicBatchedPieces, 1)
+
+	// Schedule a flush if there isn't one already.
+	if sf.flushTimer == nil {
+		sf.scheduleFlush(window)
+	}
+	return true
+}
+
+// scheduleFlush flushes the file's pending changes after the provided delay.
+// If the flush fails, the changes stay pending and the flush is retried after
+// twice the delay, up to maxFlushRetryInterval, unless the batcher was closed
+// in the meantime.
+func (sf *SiaFile) scheduleFlush(delay time.Duration) {
+	sf.flushTimer = time.AfterFunc(delay, func() {
+		sf.mu.Lock()
+		defer sf.mu.Unlock()
+		sf.flushTimer = nil
+		err := sf.flushPendingChanges()
+		if err == nil {
+			return
+		}
+		sf.batcher.staticFlushFailed(sf, err)
+		if sf.batcher.managedClosed() {
+			return
+		}
+		delay *= 2
+		if delay > maxFlushRetryInterval {
+			delay = maxFlushRetryInterval
+		}
+		sf.scheduleFlush(delay)
+	})
+}
+
+// discardPendingChanges drops the file's pending changes without persisting
+// them.
+func (sf *SiaFile) discardPendingChanges() {
+	if sf.flushTimer != nil {
+		sf.flushTimer.Stop()
+		sf.flushTimer = nil
+	}
+	sf.pendingChunks = nil
+	sf.pendingMetadata = false
+	if sf.batcher != nil {
+		sf.batcher.managedUntrack(sf)
+	}
+}
+
+// flushPendingChanges writes the file's pending changes to disk within a
+// single transaction.
+func (sf *SiaFile) flushPendingChanges() error {
+	if !sf.pendingMetadata && len(sf.pendingChunks) == 0 {
+		return nil
+	}
+	// Deleted files don't need to be persisted anymore.
+	if sf.deleted {
+		sf.discardPendingChanges()
+		return nil
+	}
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	// The pending chunks are added by createAndApplyTransaction.
+	err = sf.createAndApplyTransaction(updates...)
+	if err != nil {
+		return err
+	}
+	sf.discardPendingChanges()
+	atomic.AddUint64(&sf.batcher.atomicFlushes, 1)
+	return nil
+}
+
+// pendingChunk returns a copy of the chunk with the given index if it has
+// pending changes.
+func (sf *SiaFile) pendingChunk(chunkIndex int) (chunk, bool) {
+	c, exists := sf.pendingChunks[chunkIndex]
+	if !exists {
+		return chunk{}, false
+	}
+	pieces := make([][]piece, len(c.Pieces))
+	for i := range c.Pieces {
+		pieces[i] = append([]piece{}, c.Pieces[i]...)
+	}
+	c.Pieces = pieces
+	return c, true
+}
+
+// pendingChunkUpdates returns the updates which persist the pending chunks.
+// They always need to be applied after any other updates since they are
+// computed using the current ChunkOffset of the file.
+func (sf *SiaFile) pendingChunkUpdates() []writeaheadlog.Update {
+	indices := make([]int, 0, len(sf.pendingChunks))
+	for index := range sf.pendingChunks {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+	updates := make([]writeaheadlog.Update, 0, len(indices))
+	for _, index := range indices {
+		offset := sf.chunkOffset(index)
+		updates = append(updates, sf.createInsertUpdate(offset, marshalChunk(sf.pendingChunks[index])))
+	}
+	return updates
+}
@@ -0,0 +1,398 @@
+package siafile
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+type (
+	// dependencyCountSyncs counts the number of times a file opened through
+	// the dependency is synced.
+	dependencyCountSyncs struct {
+		modules.ProductionDependencies
+		syncs uint64
+	}
+
+	// countingFile is a file which increments the syncs of its dependency.
+	countingFile struct {
+		modules.File
+		deps *dependencyCountSyncs
+	}
+)
+
+// OpenFile wraps the opened file in a countingFile.
+func (d *dependencyCountSyncs) OpenFile(path string, flag int, perm os.FileMode) (modules.File, error) {
+	f, err := d.ProductionDependencies.OpenFile(path, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &countingFile{File: f, deps: d}, nil
+}
+
+// Sync increments the counter before syncing the file.
+func (f *countingFile) Sync() error {
+	atomic.AddUint64(&f.deps.syncs, 1)
+	return f.File.Sync()
+}
+
+// numPieces is a helper that returns the number of pieces of a chunk.
+func numPieces(sf *SiaFile, chunkIndex uint64) (int, error) {
+	pieces, err := sf.Pieces(chunkIndex)
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, pieceSet := range pieces {
+		n += len(pieceSet)
+	}
+	return n, nil
+}
+
+// newBatchTestFile is a helper that creates a file without a partial chunk
+// and sets a batcher with the provided window.
+func newBatchTestFile(window time.Duration) (*SiaFile, *MetadataBatcher) {
+	siaFilePath, _, source, rc, sk, fileSize, numChunks, fileMode := newTestFileParams(2, false)
+	sf, _, _ := customTestFileAndWAL(siaFilePath, source, rc, sk, fileSize, numChunks, fileMode)
+	b := NewMetadataBatcher(window, nil)
+	sf.SetMetadataBatcher(b)
+	return sf, b
+}
+
+// TestMetadataBatching tests that added pieces are batched and that a file
+// loaded from disk before and after a flush is consistent.
+func TestMetadataBatching(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Use a window long enough for the flush to never happen on its own.
+	sf, b := newBatchTestFile(time.Hour)
+
+	// Adding a piece for an unknown host changes the host table which is
+	// persisted right away.
+	hpk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+	if err := sf.AddPiece(hpk, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	if stats := b.Stats(); stats.BatchedPieces != 0 || stats.PendingFiles != 0 {
+		t.Fatal("piece shouldn't have been batched", stats)
+	}
+
+	// Add more pieces for the same host. They should be batched.
+	numBatched := sf.ErasureCode().NumPieces() - 1
+	for pieceIndex := 1; pieceIndex <= numBatched; pieceIndex++ {
+		if err := sf.AddPiece(hpk, 0, uint64(pieceIndex), crypto.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sf.AddPiece(hpk, 1, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	stats := b.Stats()
+	if stats.BatchedPieces != uint64(numBatched+1) || stats.Flushes != 0 || stats.PendingFiles != 1 {
+		t.Fatal("unexpected stats", stats)
+	}
+	// The batched pieces are visible.
+	if n, err := numPieces(sf, 0); err != nil || n != numBatched+1 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+
+	// Simulate a crash by loading the file from disk. It should only contain
+	// the persisted piece.
+	crashed, err := LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := numPieces(crashed, 0); err != nil || n != 1 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+	if n, err := numPieces(crashed, 1); err != nil || n != 0 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+
+	// Flush the file. Now the pieces should be on disk.
+	if err := sf.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	stats = b.Stats()
+	if stats.Flushes != 1 || stats.PendingFiles != 0 {
+		t.Fatal("unexpected stats", stats)
+	}
+	sf2, err := LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := equalFiles(sf, sf2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Shorten the window. The next piece should be flushed on its own.
+	b.SetWindow(10 * time.Millisecond)
+	if err := sf.AddPiece(hpk, 1, 1, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		if stats := b.Stats(); stats.Flushes != 2 || stats.PendingFiles != 0 {
+			return errors.New("piece wasn't flushed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf2, err = LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := numPieces(sf2, 1); err != nil || n != 2 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+
+	// Disable batching. Pieces should be persisted right away.
+	b.SetWindow(0)
+	if err := sf.AddPiece(hpk, 1, 2, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	sf2, err = LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := numPieces(sf2, 1); err != nil || n != 3 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+
+	// Enable batching again and close the batcher. The pending piece should
+	// be flushed and further pieces are persisted right away.
+	b.SetWindow(time.Hour)
+	if err := sf.AddPiece(hpk, 1, 3, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.AddPiece(hpk, 1, 4, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	sf2, err = LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := numPieces(sf2, 1); err != nil || n != 5 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+	if stats := b.Stats(); stats.Flushes != 3 || stats.PendingFiles != 0 {
+		t.Fatal("unexpected stats", stats)
+	}
+}
+
+// TestMetadataBatchingFailedFlush tests that a failed background flush is
+// tracked and retried.
+func TestMetadataBatchingFailedFlush(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	sf, b := newBatchTestFile(10 * time.Millisecond)
+
+	// Add a piece for a new host to persist the host table and then batch
+	// another one.
+	hpk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+	if err := sf.AddPiece(hpk, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	sf.mu.Lock()
+	wal := sf.wal
+	sf.wal = nil
+	sf.mu.Unlock()
+	if err := sf.AddPiece(hpk, 0, 1, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a WAL the flush fails. The change should stay pending.
+	err := build.Retry(100, 10*time.Millisecond, func() error {
+		if stats := b.Stats(); stats.FailedFlushes == 0 || stats.PendingFiles != 1 {
+			return fmt.Errorf("flush didn't fail: %v", stats)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore the WAL. The retry should persist the piece.
+	sf.mu.Lock()
+	sf.wal = wal
+	sf.mu.Unlock()
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		if stats := b.Stats(); stats.Flushes != 1 || stats.PendingFiles != 0 {
+			return fmt.Errorf("piece wasn't flushed: %v", stats)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf2, err := LoadSiaFile(sf.siaFilePath, wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := numPieces(sf2, 0); err != nil || n != 2 {
+		t.Fatal("wrong number of pieces", n, err)
+	}
+}
+
+// TestMetadataBatchingCloseStopsRetries tests that a failed background flush
+// isn't retried anymore once the batcher is closed.
+func TestMetadataBatchingCloseStopsRetries(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	sf, b := newBatchTestFile(10 * time.Millisecond)
+
+	// Persist the host table and remove the WAL to make the flushes of the
+	// next piece fail.
+	hpk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+	if err := sf.AddPiece(hpk, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	sf.mu.Lock()
+	sf.wal = nil
+	sf.mu.Unlock()
+	if err := sf.AddPiece(hpk, 0, 1, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	err := build.Retry(100, 10*time.Millisecond, func() error {
+		if stats := b.Stats(); stats.FailedFlushes == 0 {
+			return fmt.Errorf("flush didn't fail: %v", stats)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the batcher fails to flush the file as well and stops the
+	// retries.
+	if err := b.Close(); err == nil {
+		t.Fatal("expected close to fail")
+	}
+	sf.mu.Lock()
+	timer := sf.flushTimer
+	sf.mu.Unlock()
+	if timer != nil {
+		t.Fatal("flush timer wasn't stopped")
+	}
+	// Wait for a flush which might have been in progress during the close
+	// before checking that no more flushes are attempted.
+	time.Sleep(20 * time.Millisecond)
+	failed := b.Stats().FailedFlushes
+	time.Sleep(100 * time.Millisecond)
+	if stats := b.Stats(); stats.FailedFlushes != failed {
+		t.Fatal("flush was retried after close", stats.FailedFlushes, failed)
+	}
+}
+
+// TestMetadataBatchingHeaderGrowth tests that pending chunks are persisted
+// correctly when the header of the file grows and moves the chunks on disk.
+func TestMetadataBatchingHeaderGrowth(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	sf, b := newBatchTestFile(time.Hour)
+
+	// Add a piece for a known host to every chunk.
+	hpk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+	if err := sf.AddPiece(hpk, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	for chunkIndex := uint64(1); chunkIndex < sf.NumChunks(); chunkIndex++ {
+		if err := sf.AddPiece(hpk, chunkIndex, 0, crypto.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats := b.Stats(); stats.BatchedPieces != sf.NumChunks()-1 {
+		t.Fatal("unexpected stats", stats)
+	}
+
+	// Add pieces of new hosts until the header needs to grow. Every one of
+	// them is persisted right away together with the pending chunks.
+	chunkOffset := sf.staticMetadata.ChunkOffset
+	for sf.staticMetadata.ChunkOffset == chunkOffset {
+		hpk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+		if err := sf.AddPiece(hpk, 0, 1, crypto.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Load the file from disk. It should contain all the pieces.
+	sf2, err := LoadSiaFile(sf.siaFilePath, sf.wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sf2.staticMetadata.ChunkOffset != sf.staticMetadata.ChunkOffset {
+		t.Fatal("wrong chunk offset", sf2.staticMetadata.ChunkOffset, sf.staticMetadata.ChunkOffset)
+	}
+	for chunkIndex := uint64(0); chunkIndex < sf.NumChunks(); chunkIndex++ {
+		expected, err := numPieces(sf, chunkIndex)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n, err := numPieces(sf2, chunkIndex); err != nil || n != expected {
+			t.Fatal("wrong number of pieces", chunkIndex, n, err)
+		}
+	}
+}
+
+// BenchmarkMetadataBatching benchmarks adding pieces to a file with and
+// without batching and reports the number of syncs of the file.
+func BenchmarkMetadataBatching(b *testing.B) {
+	b.Run("Unbatched", func(b *testing.B) { benchmarkMetadataBatching(b, 0) })
+	b.Run("Batched", func(b *testing.B) { benchmarkMetadataBatching(b, 20*time.Millisecond) })
+}
+
+// benchmarkMetadataBatching benchmarks adding pieces to a file using the
+// provided batch window.
+func benchmarkMetadataBatching(b *testing.B, window time.Duration) {
+	sf, batcher := newBatchTestFile(window)
+	deps := &dependencyCountSyncs{}
+	sf.deps = deps
+
+	// Add a piece for the host to the file to add it to the host table.
+	hpk := types.SiaPublicKey{Key: fastrand.Bytes(crypto.EntropySize)}
+	if err := sf.AddPiece(hpk, 0, 0, crypto.Hash{}); err != nil {
+		b.Fatal(err)
+	}
+	atomic.StoreUint64(&deps.syncs, 0)
+
+	numChunks := sf.NumChunks()
+	numPieces := uint64(sf.ErasureCode().NumPieces())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		chunkIndex := uint64(i) % numChunks
+		pieceIndex := (uint64(i) / numChunks) % numPieces
+		if err := sf.AddPiece(hpk, chunkIndex, pieceIndex, crypto.Hash{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := batcher.Close(); err != nil {
+		b.Fatal(err)
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(atomic.LoadUint64(&deps.syncs))/float64(b.N), "syncs/op")
+}
@@ -37,6 +37,14 @@ func LoadSiaFile(path string, wal *writeaheadlog.WAL) (*SiaFile, error) {
 	return loadSiaFile(path, wal, modules.ProdDependencies)
 }
 
+// LoadSiaFileReadonly loads a SiaFile without a WAL. It doesn't require the
+// renter to be running or the file to be part of a file system which makes it
+// useful for inspecting files offline. Any attempt to modify the returned
+// SiaFile will fail with ErrReadonly.
+func LoadSiaFileReadonly(path string) (*SiaFile, error) {
+	return loadSiaFile(path, nil, modules.ProdDependencies)
+}
+
 // LoadSiaFileFromReader allows loading a SiaFile from a different location that
 // directly from disk as long as the source satisfies the SiaFileSource
 // interface.
@@ -248,7 +256,7 @@ func loadSiaFileFromReader(r io.ReadSeeker, path string, wal *writeaheadlog.WAL,
 	decoder := json.NewDecoder(r)
 	err := decoder.Decode(&sf.staticMetadata)
 	if err != nil {
-		return nil, errors.AddContext(err, "failed to decode metadata")
+		return nil, errors.AddContext(err, fmt.Sprintf("failed to decode metadata at byte offset %v", jsonErrorOffset(err)))
 	}
 	// COMPATv137 legacy files might not have a unique id.
 	if sf.staticMetadata.UniqueID == "" {
@@ -288,7 +296,7 @@ func loadSiaFileFromReader(r io.ReadSeeker, path string, wal *writeaheadlog.WAL,
 		// Unmarshal table.
 		sf.pubKeyTable, err = unmarshalPubKeyTable(rawPubKeyTable)
 		if err != nil {
-			return nil, errors.AddContext(err, "failed to unmarshal pubKeyTable")
+			return nil, errors.AddContext(err, fmt.Sprintf("failed to unmarshal pubKeyTable at byte offset %v", sf.staticMetadata.PubKeyTableOffset))
 		}
 	}
 	// Seek to the start of the chunks.
@@ -325,6 +333,7 @@ func loadSiaFileMetadata(path string, deps modules.Dependencies) (md Metadata, e
 	// Load the metadata.
 	decoder := json.NewDecoder(f)
 	if err = decoder.Decode(&md); err != nil {
+		err = errors.AddContext(err, fmt.Sprintf("failed to decode metadata at byte offset %v", jsonErrorOffset(err)))
 		return
 	}
 	// Create the erasure coder.
@@ -515,6 +524,10 @@ func (sf *SiaFile) chunk(chunkIndex int) (_ chunk, err error) {
 	} else if sf.isIncompletePartialChunk(uint64(chunkIndex)) {
 		return chunk{Index: chunkIndex}, nil
 	}
+	// Handle chunk with pending changes.
+	if c, ok := sf.pendingChunk(chunkIndex); ok {
+		return c, nil
+	}
 	// Handle full chunk.
 	chunkOffset := sf.chunkOffset(chunkIndex)
 	chunkBytes := make([]byte, int(sf.staticMetadata.StaticPagesPerChunk)*pageSize)
@@ -601,6 +614,10 @@ func (sf *SiaFile) iterateChunksReadonly(iterFunc func(chunk chunk) error) (err
 			if err != nil {
 				return errors.AddContext(err, fmt.Sprintf("failed to unmarshal chunk %v", chunkIndex))
 			}
+			// Prefer the chunk with pending changes over the one on disk.
+			if pc, ok := sf.pendingChunk(chunkIndex); ok {
+				c = pc
+			}
 		}
 		c.Index = chunkIndex
 		if err := iterFunc(c); err != nil {
@@ -625,9 +642,16 @@ func (sf *SiaFile) createAndApplyTransaction(updates ...writeaheadlog.Update) (e
 	if sf.deleted {
 		return errors.New("can't call createAndApplyTransaction on deleted file")
 	}
+	// Pending chunks are persisted together with the other updates. They need
+	// to be applied last since they depend on the ChunkOffset.
+	updates = append(updates, sf.pendingChunkUpdates()...)
 	if len(updates) == 0 {
 		return nil
 	}
+	// Files loaded without a WAL can't be modified.
+	if sf.wal == nil {
+		return ErrReadonly
+	}
 	// Create the writeaheadlog transaction.
 	txn, err := sf.wal.NewTransaction(updates)
 	if err != nil {
@@ -652,6 +676,8 @@ func (sf *SiaFile) createAndApplyTransaction(updates ...writeaheadlog.Update) (e
 	if err := txn.SignalUpdatesApplied(); err != nil {
 		return errors.AddContext(err, "failed to signal that updates are applied")
 	}
+	// The pending chunks are persisted now.
+	sf.pendingChunks = nil
 	return nil
 }
 
@@ -662,6 +688,9 @@ func createAndApplyTransaction(wal *writeaheadlog.WAL, updates ...writeaheadlog.
 	if len(updates) == 0 {
 		return nil
 	}
+	if wal == nil {
+		return ErrReadonly
+	}
 	// Create the writeaheadlog transaction.
 	txn, err := wal.NewTransaction(updates)
 	if err != nil {
@@ -788,6 +817,8 @@ func (sf *SiaFile) saveFile(chunks []chunk) (err error) {
 // NOTE: For consistency chunk updates always need to be created after the
 // header or metadata updates.
 func (sf *SiaFile) saveChunkUpdate(chunk chunk) writeaheadlog.Update {
+	// The update supersedes any pending changes to the chunk.
+	delete(sf.pendingChunks, chunk.Index)
 	offset := sf.chunkOffset(chunk.Index)
 	chunkBytes := marshalChunk(chunk)
 	return sf.createInsertUpdate(offset, chunkBytes)
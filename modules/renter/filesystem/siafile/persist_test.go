@@ -364,6 +364,65 @@ func TestNewFile(t *testing.T) {
 	}
 }
 
+// TestLoadSiaFileReadonly tests loading a SiaFile without a WAL and
+// summarizing its chunks.
+func TestLoadSiaFileReadonly(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	sf := newTestFile()
+	ro, err := LoadSiaFileReadonly(sf.siaFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The metadata should match.
+	if ro.Metadata().UniqueID != sf.UID() || ro.NumChunks() != sf.NumChunks() {
+		t.Fatal("metadata doesn't match")
+	}
+	// The summaries should match the chunks of the file.
+	summaries, err := ro.ChunkSummaries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(summaries)) != sf.NumChunks() {
+		t.Fatalf("expected %v summaries but got %v", sf.NumChunks(), len(summaries))
+	}
+	for _, summary := range summaries {
+		if summary.Err != "" {
+			t.Fatal(summary.Err)
+		}
+		if summary.Partial {
+			continue
+		}
+		pieces, err := sf.Pieces(uint64(summary.Index))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var numPieces, uniquePieces int
+		for _, pieceSet := range pieces {
+			numPieces += len(pieceSet)
+			if len(pieceSet) > 0 {
+				uniquePieces++
+			}
+		}
+		if summary.NumPieces != numPieces || summary.UniquePieces != uniquePieces || len(summary.Pieces) != numPieces {
+			t.Fatal("summary doesn't match chunk", summary.NumPieces, numPieces, summary.UniquePieces, uniquePieces)
+		}
+		if summary.Offset != sf.chunkOffset(summary.Index) {
+			t.Fatal("wrong offset", summary.Offset, sf.chunkOffset(summary.Index))
+		}
+	}
+	// Modifying the file should fail.
+	if err := ro.SetStuck(0, true); !errors.Contains(err, ErrReadonly) {
+		t.Fatal("expected ErrReadonly but got", err)
+	}
+	if err := ro.AddPiece(types.SiaPublicKey{}, 0, 0, crypto.Hash{}); !errors.Contains(err, ErrReadonly) {
+		t.Fatal("expected ErrReadonly but got", err)
+	}
+}
+
 // TestCreateReadInsertUpdate tests if an update can be created using createInsertUpdate
 // and if the created update can be read using readInsertUpdate.
 func TestCreateReadInsertUpdate(t *testing.T) {
@@ -1,10 +1,12 @@
 package siafile
 
 import (
+	"encoding/binary"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"sort"
 	"sync"
 	"time"
 
@@ -29,6 +31,37 @@ var (
 	// ErrDeleted is returned when an operation failed due to the siafile being
 	// deleted already.
 	ErrDeleted = errors.New("files was deleted")
+	// ErrReadonly is returned when trying to modify a SiaFile that was loaded
+	// using LoadSiaFileReadonly.
+	ErrReadonly = errors.New("siafile was loaded in read-only mode")
+	// ErrInvalidLocalPath is returned when the local path of a SiaFile is set
+	// to a path that doesn't point to a regular file of the SiaFile's size.
+	ErrInvalidLocalPath = errors.New("invalid local path")
+)
+
+const (
+	// extensionInfoFlagsIndex is the index of the byte within a chunk's
+	// ExtensionInfo that holds the chunk's flags.
+	extensionInfoFlagsIndex = 0
+
+	// extensionInfoStuckRepairAttemptsIndex is the index of the byte within a
+	// chunk's ExtensionInfo that holds the number of consecutive failed repair
+	// attempts of the stuck loop.
+	extensionInfoStuckRepairAttemptsIndex = 1
+
+	// extensionInfoRepairFailuresIndex is the index of the byte within a
+	// chunk's ExtensionInfo that holds the number of consecutive failed
+	// repairs of the chunk.
+	extensionInfoRepairFailuresIndex = 2
+
+	// extensionInfoLastRepairFailureIndex is the index of the 8 bytes within a
+	// chunk's ExtensionInfo that hold the unix timestamp of the chunk's last
+	// failed repair.
+	extensionInfoLastRepairFailureIndex = 3
+
+	// chunkFlagAbandoned indicates that the stuck loop gave up on repairing the
+	// chunk.
+	chunkFlagAbandoned = 1 << 0
 )
 
 type (
@@ -66,6 +99,14 @@ type (
 		// chunk we simply keep the megafiles always open and assign them to SiaFiles
 		// with matching redundancy.
 		partialsSiaFile *SiaFile
+
+		// batcher batches the metadata writes of added pieces. pendingChunks
+		// and pendingMetadata are the changes that haven't been persisted
+		// yet and flushTimer is the timer for the next flush.
+		batcher         *MetadataBatcher
+		pendingChunks   map[int]chunk
+		pendingMetadata bool
+		flushTimer      *time.Timer
 	}
 
 	// Chunks is an exported version of a chunk slice.. It exists for
@@ -187,6 +228,58 @@ func (c *chunk) numPieces() (numPieces int) {
 	return
 }
 
+// abandoned returns whether the stuck loop gave up on repairing the chunk.
+func (c *chunk) abandoned() bool {
+	return c.ExtensionInfo[extensionInfoFlagsIndex]&chunkFlagAbandoned != 0
+}
+
+// setAbandoned sets the abandoned flag of the chunk.
+func (c *chunk) setAbandoned(abandoned bool) {
+	if abandoned {
+		c.ExtensionInfo[extensionInfoFlagsIndex] |= chunkFlagAbandoned
+	} else {
+		c.ExtensionInfo[extensionInfoFlagsIndex] &^= chunkFlagAbandoned
+	}
+}
+
+// stuckRepairAttempts returns the number of consecutive failed repair attempts
+// of the stuck loop for the chunk.
+func (c *chunk) stuckRepairAttempts() uint8 {
+	return c.ExtensionInfo[extensionInfoStuckRepairAttemptsIndex]
+}
+
+// setStuckRepairAttempts sets the number of consecutive failed repair attempts
+// of the stuck loop for the chunk.
+func (c *chunk) setStuckRepairAttempts(attempts uint8) {
+	c.ExtensionInfo[extensionInfoStuckRepairAttemptsIndex] = attempts
+}
+
+// repairFailures returns the number of consecutive failed repairs of the
+// chunk.
+func (c *chunk) repairFailures() uint8 {
+	return c.ExtensionInfo[extensionInfoRepairFailuresIndex]
+}
+
+// lastRepairFailure returns the time of the chunk's last failed repair.
+func (c *chunk) lastRepairFailure() time.Time {
+	ts := int64(binary.LittleEndian.Uint64(c.ExtensionInfo[extensionInfoLastRepairFailureIndex:]))
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
+}
+
+// setRepairFailures sets the number of consecutive failed repairs of the chunk
+// and the time of the last failure.
+func (c *chunk) setRepairFailures(failures uint8, lastFailure time.Time) {
+	c.ExtensionInfo[extensionInfoRepairFailuresIndex] = failures
+	var ts int64
+	if !lastFailure.IsZero() {
+		ts = lastFailure.Unix()
+	}
+	binary.LittleEndian.PutUint64(c.ExtensionInfo[extensionInfoLastRepairFailureIndex:], uint64(ts))
+}
+
 // New create a new SiaFile.
 func New(siaFilePath, source string, wal *writeaheadlog.WAL, erasureCode modules.ErasureCoder, masterKey crypto.CipherKey, fileSize uint64, fileMode os.FileMode, partialsSiaFile *SiaFile, disablePartialUpload bool) (*SiaFile, error) {
 	// TODO remove this
@@ -244,9 +337,11 @@ func New(siaFilePath, source string, wal *writeaheadlog.WAL, erasureCode modules
 		return nil, errors.New("can't create a file with a partial chunk without assigning a partialsSiaFile")
 	}
 	file.numChunks = int(numChunks)
+	file.staticMetadata.CachedNumChunksNeedingRepair = numChunks
 	// Update cached fields for 0-Byte files.
 	if file.staticMetadata.FileSize == 0 {
 		file.staticMetadata.CachedHealth = 0
+		file.staticMetadata.CachedNumChunksNeedingRepair = 0
 		file.staticMetadata.CachedNumStuckChunks = 0
 		file.staticMetadata.CachedRepairBytes = 0
 		file.staticMetadata.CachedStuckBytes = 0
@@ -426,6 +521,11 @@ func (sf *SiaFile) AddPiece(pk types.SiaPublicKey, chunkIndex, pieceIndex uint64
 	if chunkSize > maxChunkSize {
 		return fmt.Errorf("chunk doesn't fit into allocated space %v > %v", chunkSize, maxChunkSize)
 	}
+	// If the host table didn't change, the write can be batched with other
+	// added pieces.
+	if !tableChanged && sf.batchChunk(chunk) {
+		return nil
+	}
 	// Update the file atomically.
 	var updates []writeaheadlog.Update
 	// Get the updates for the header.
@@ -504,6 +604,8 @@ func (sf *SiaFile) Delete() (err error) {
 			sf.staticMetadata.restore(backup)
 		}
 	}(sf.staticMetadata.backup())
+	// Pending changes don't need to be persisted anymore.
+	sf.discardPendingChanges()
 	update := sf.createDeleteUpdate()
 	err = sf.createAndApplyTransaction(update)
 	sf.deleted = true
@@ -656,8 +758,10 @@ func (sf *SiaFile) Health(offline map[string]bool, goodForRenew map[string]bool)
 	sf.mu.Lock()
 	defer sf.mu.Unlock()
 	// Update the cache.
+	var numChunksNeedingRepair uint64
 	defer func() {
 		sf.staticMetadata.CachedHealth = h
+		sf.staticMetadata.CachedNumChunksNeedingRepair = numChunksNeedingRepair
 		sf.staticMetadata.CachedNumStuckChunks = nsc
 		sf.staticMetadata.CachedRepairBytes = rb
 		sf.staticMetadata.CachedStuckBytes = sb
@@ -679,8 +783,14 @@ func (sf *SiaFile) Health(offline map[string]bool, goodForRenew map[string]bool)
 
 	// Iterate over the chunks to gather the health information
 	var health, stuckHealth, userHealth, userStuckHealth float64
-	var numStuckChunks, repairBytesRemaing, stuckBytes uint64
+	var numAbandonedChunks, numStuckChunks, repairBytesRemaing, stuckBytes uint64
 	err := sf.iterateChunksReadonly(func(c chunk) error {
+		// Abandoned chunks are neither targeted by the repair loop nor the
+		// stuck loop so they don't contribute to the file's health.
+		if c.abandoned() {
+			numAbandonedChunks++
+			return nil
+		}
 		chunkHealth, userChunkHealth, chunkRepairBytesRemaining, err := sf.chunkHealth(c, offline, goodForRenew)
 		if err != nil {
 			return err
@@ -716,6 +826,7 @@ func (sf *SiaFile) Health(offline map[string]bool, goodForRenew map[string]bool)
 		// If the chunk is not stuck then we only count the remaining repair bytes
 		// if the chunk needs repair.
 		if modules.NeedsRepair(chunkHealth) {
+			numChunksNeedingRepair++
 			repairBytesRemaing += chunkRepairBytesRemaining
 		}
 
@@ -727,9 +838,9 @@ func (sf *SiaFile) Health(offline map[string]bool, goodForRenew map[string]bool)
 		return 0, 0, 0, 0, 0, 0, 0
 	}
 
-	// Check if all chunks are stuck, if so then set health to max health to
-	// avoid file being targeted for repair
-	if int(numStuckChunks) == sf.numChunks {
+	// Check if all chunks are stuck or abandoned, if so then set health to max
+	// health to avoid file being targeted for repair
+	if int(numStuckChunks+numAbandonedChunks) == sf.numChunks {
 		health = float64(0)
 	}
 	// Sanity check, verify that the calculated health is not worse (greater)
@@ -750,6 +861,10 @@ func (sf *SiaFile) Health(offline map[string]bool, goodForRenew map[string]bool)
 		// metadata with the information read directly from the chunks
 		sf.staticMetadata.NumStuckChunks = numStuckChunks
 	}
+	// Same for the number of abandoned chunks.
+	if numAbandonedChunks != sf.staticMetadata.NumAbandonedChunks {
+		sf.staticMetadata.NumAbandonedChunks = numAbandonedChunks
+	}
 	return health, stuckHealth, userHealth, userStuckHealth, numStuckChunks, repairBytesRemaing, stuckBytes
 }
 
@@ -833,6 +948,88 @@ func (sf *SiaFile) Pieces(chunkIndex uint64) ([][]Piece, error) {
 	return pieces, nil
 }
 
+// Availability returns whether every chunk of the file has MinPieces pieces on
+// distinct online hosts in distinct subnets. The file is resilient if the same
+// is true when only considering goodForRenew hosts. hostSubnets maps host keys
+// to the subnets of the hosts. Hosts without subnets are considered to be in a
+// subnet of their own and a nil map disables the subnet check. The results are
+// cached within the metadata.
+func (sf *SiaFile) Availability(offlineMap map[string]bool, goodForRenewMap map[string]bool, hostSubnets map[string][]string) (available, resilient bool, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	// If the file has been deleted, we can't compute its availability.
+	if sf.deleted {
+		return false, false, errors.AddContext(ErrDeleted, "can't call Availability on deleted file")
+	}
+
+	// Update the cache.
+	defer func() {
+		sf.staticMetadata.CachedLacksDiversity = !available
+		sf.staticMetadata.CachedLacksRenewDiversity = !resilient
+	}()
+	if sf.staticMetadata.FileSize == 0 {
+		return true, true, nil
+	}
+
+	minPieces := sf.staticMetadata.staticErasureCode.MinPieces()
+	available, resilient = true, true
+	err = sf.iterateChunksReadonly(func(chunk chunk) error {
+		// Partial chunks are stored within the partials siafile and
+		// incomplete partial chunks have full redundancy by definition.
+		if _, ok := sf.isIncludedPartialChunk(uint64(chunk.Index)); ok || sf.isIncompletePartialChunk(uint64(chunk.Index)) {
+			return nil
+		}
+		if sf.diversePieces(chunk, offlineMap, goodForRenewMap, hostSubnets, false) < minPieces {
+			available = false
+		}
+		if sf.diversePieces(chunk, offlineMap, goodForRenewMap, hostSubnets, true) < minPieces {
+			resilient = false
+		}
+		return nil
+	})
+	if err != nil {
+		return false, false, err
+	}
+	return available, resilient, nil
+}
+
+// diversePieces returns the number of unique pieces of a chunk which are stored
+// on distinct online hosts in distinct subnets. If renewOnly is true, only
+// goodForRenew hosts are considered. The pieces are assigned greedily in the
+// order of their index which might undercount in rare cases.
+func (sf *SiaFile) diversePieces(chunk chunk, offlineMap map[string]bool, goodForRenewMap map[string]bool, hostSubnets map[string][]string, renewOnly bool) int {
+	usedHosts := make(map[string]struct{})
+	usedSubnets := make(map[string]struct{})
+	var n int
+	for _, pieceSet := range chunk.Pieces {
+	PIECES:
+		for _, piece := range pieceSet {
+			hpk := sf.hostKey(piece.HostTableOffset).PublicKey.String()
+			offline, exists := offlineMap[hpk]
+			if !exists || offline || (renewOnly && !goodForRenewMap[hpk]) {
+				continue
+			}
+			if _, used := usedHosts[hpk]; used {
+				continue
+			}
+			subnets := hostSubnets[hpk]
+			for _, subnet := range subnets {
+				if _, used := usedSubnets[subnet]; used {
+					continue PIECES
+				}
+			}
+			usedHosts[hpk] = struct{}{}
+			for _, subnet := range subnets {
+				usedSubnets[subnet] = struct{}{}
+			}
+			n++
+			break
+		}
+	}
+	return n
+}
+
 // Redundancy returns the redundancy of the least redundant chunk. A file
 // becomes available when this redundancy is >= 1. Assumes that every piece is
 // unique within a file contract. -1 is returned if the file has size 0. It
@@ -936,10 +1133,10 @@ func (sf *SiaFile) SetAllStuck(stuck bool) (err error) {
 			sf.staticMetadata.restore(backup)
 		}
 	}(sf.staticMetadata.backup())
-	// Figure out which chunks to update.
+	// Figure out which chunks to update. Abandoned chunks are ignored.
 	var setStuck []chunk
 	errIter := sf.iterateChunksReadonly(func(chunk chunk) error {
-		if chunk.Stuck != stuck {
+		if chunk.Stuck != stuck && !chunk.abandoned() {
 			setStuck = append(setStuck, chunk)
 			return nil
 		}
@@ -954,9 +1151,9 @@ func (sf *SiaFile) SetAllStuck(stuck bool) (err error) {
 	}
 	// Update metadata.
 	if stuck && sf.staticMetadata.HasPartialChunk && len(sf.staticMetadata.PartialChunks) == 0 {
-		sf.staticMetadata.NumStuckChunks = uint64(sf.numChunks) - 1 // partial chunk can't be stuck in this state
+		sf.staticMetadata.NumStuckChunks = uint64(sf.numChunks) - 1 - sf.staticMetadata.NumAbandonedChunks // partial chunk can't be stuck in this state
 	} else if stuck {
-		sf.staticMetadata.NumStuckChunks = uint64(sf.numChunks)
+		sf.staticMetadata.NumStuckChunks = uint64(sf.numChunks) - sf.staticMetadata.NumAbandonedChunks
 	} else {
 		sf.staticMetadata.NumStuckChunks = 0
 	}
@@ -1025,6 +1222,299 @@ func (sf *SiaFile) SetStuck(index uint64, stuck bool) (err error) {
 	return sf.setStuck(index, stuck)
 }
 
+// AbandonedChunkByIndex returns if the chunk at the index is marked as
+// abandoned or not.
+func (sf *SiaFile) AbandonedChunkByIndex(index uint64) (bool, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// Partial chunks are never abandoned.
+	if _, ok := sf.isIncludedPartialChunk(index); ok || sf.isIncompletePartialChunk(index) {
+		return false, nil
+	}
+	chunk, err := sf.chunk(int(index))
+	if err != nil {
+		return false, errors.AddContext(err, "failed to read chunk")
+	}
+	return chunk.abandoned(), nil
+}
+
+// MarkStuckRepairFailed records a failed repair attempt of the stuck loop for
+// the chunk at the given index. Once the chunk failed maxAttempts consecutive
+// repairs it is marked as abandoned and is no longer considered stuck.
+// Otherwise it is marked as stuck.
+func (sf *SiaFile) MarkStuckRepairFailed(index uint64, maxAttempts uint8) (abandoned bool, err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	// Partial chunks can't be abandoned, they are only marked as stuck.
+	if _, ok := sf.isIncludedPartialChunk(index); ok || sf.isIncompletePartialChunk(index) {
+		return false, sf.setStuck(index, true)
+	}
+	// If the file has been deleted we can't update the chunk.
+	if sf.deleted {
+		return false, errors.AddContext(ErrDeleted, "can't call MarkStuckRepairFailed on deleted file")
+	}
+	//  Get chunk.
+	chunk, err := sf.chunk(int(index))
+	if err != nil {
+		return false, err
+	}
+	if chunk.abandoned() {
+		return true, nil
+	}
+	// Backup the changed metadata before changing it. Revert the change on
+	// error.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	// Increment the attempts and abandon the chunk if necessary.
+	attempts := chunk.stuckRepairAttempts()
+	if attempts < math.MaxUint8 {
+		attempts++
+	}
+	chunk.setStuckRepairAttempts(attempts)
+	abandoned = attempts >= maxAttempts
+	if abandoned {
+		if chunk.Stuck {
+			sf.staticMetadata.NumStuckChunks--
+		}
+		chunk.Stuck = false
+		chunk.setAbandoned(true)
+		sf.staticMetadata.NumAbandonedChunks++
+	} else if !chunk.Stuck {
+		chunk.Stuck = true
+		sf.staticMetadata.NumStuckChunks++
+	}
+	// Update chunk and metadata on disk
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return false, err
+	}
+	update := sf.saveChunkUpdate(chunk)
+	updates = append(updates, update)
+	return abandoned, sf.createAndApplyTransaction(updates...)
+}
+
+// ChunkInfos returns debugging information about the chunks of the file that
+// are stored on disk.
+func (sf *SiaFile) ChunkInfos() ([]modules.ChunkInfo, error) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	var infos []modules.ChunkInfo
+	err := sf.iterateChunksReadonly(func(chunk chunk) error {
+		infos = append(infos, modules.ChunkInfo{
+			Index:             uint64(chunk.Index),
+			Abandoned:         chunk.abandoned(),
+			LastRepairFailure: chunk.lastRepairFailure(),
+			RepairFailures:    chunk.repairFailures(),
+			Stuck:             chunk.Stuck,
+		})
+		return nil
+	})
+	return infos, err
+}
+
+// UpdateRepairCostEstimate estimates the cost of uploading all of the pieces
+// the file needs to reach full redundancy and caches it in memory. The missing
+// pieces of a chunk are priced with the write costs of the cheapest hosts in
+// writeCosts which don't store a piece of the chunk yet. If there aren't
+// enough of those hosts, the remaining pieces are priced with the most
+// expensive write cost. Abandoned chunks and partial chunks which haven't been
+// included in a combined chunk yet are ignored. The returned boolean is false
+// if the file is missing pieces but there are no write costs to estimate their
+// cost with.
+//
+// NOTE: This call should be used in conjunction with a method that saves the
+// SiaFile metadata
+func (sf *SiaFile) UpdateRepairCostEstimate(offline map[string]bool, goodForRenew map[string]bool, writeCosts map[string]types.Currency) (types.Currency, bool, error) {
+	// Sort the hosts by their write cost.
+	type hostCost struct {
+		host string
+		cost types.Currency
+	}
+	hosts := make([]hostCost, 0, len(writeCosts))
+	for host, cost := range writeCosts {
+		hosts = append(hosts, hostCost{host: host, cost: cost})
+	}
+	sort.Slice(hosts, func(i, j int) bool {
+		return hosts[i].cost.Cmp(hosts[j].cost) < 0
+	})
+
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	numPieces := uint64(sf.staticMetadata.staticErasureCode.NumPieces())
+	estimate := types.ZeroCurrency
+	var totalMissing uint64
+	err := sf.iterateChunksReadonly(func(chunk chunk) error {
+		if chunk.abandoned() || sf.isIncompletePartialChunk(uint64(chunk.Index)) {
+			return nil
+		}
+		goodPieces, _ := sf.goodPieces(chunk, offline, goodForRenew)
+		if goodPieces >= numPieces {
+			return nil
+		}
+		missing := numPieces - goodPieces
+		totalMissing += missing
+
+		// A host never stores more than one piece of a chunk.
+		used := make(map[string]struct{})
+		for _, pieceSet := range chunk.Pieces {
+			for _, piece := range pieceSet {
+				used[sf.hostKey(piece.HostTableOffset).PublicKey.String()] = struct{}{}
+			}
+		}
+		for _, hc := range hosts {
+			if missing == 0 {
+				break
+			}
+			if _, exists := used[hc.host]; exists {
+				continue
+			}
+			estimate = estimate.Add(hc.cost)
+			missing--
+		}
+		if missing > 0 && len(hosts) > 0 {
+			estimate = estimate.Add(hosts[len(hosts)-1].cost.Mul64(missing))
+		}
+		return nil
+	})
+	if err != nil {
+		return types.ZeroCurrency, false, err
+	}
+	if totalMissing > 0 && len(hosts) == 0 {
+		sf.staticMetadata.CachedRepairCostEstimateValid = false
+		return types.ZeroCurrency, false, nil
+	}
+	sf.staticMetadata.CachedRepairCostEstimate = estimate
+	sf.staticMetadata.CachedRepairCostEstimateValid = true
+	return estimate, true, nil
+}
+
+// MarkRepairFailed records a failed repair for the chunk at the given index by
+// incrementing its repair failures and updating the time of its last failure.
+// Partial chunks don't track their repair failures.
+func (sf *SiaFile) MarkRepairFailed(index uint64) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if _, ok := sf.isIncludedPartialChunk(index); ok || sf.isIncompletePartialChunk(index) {
+		return nil
+	}
+	if sf.deleted {
+		return errors.AddContext(ErrDeleted, "can't call MarkRepairFailed on deleted file")
+	}
+	chunk, err := sf.chunk(int(index))
+	if err != nil {
+		return err
+	}
+	failures := chunk.repairFailures()
+	if failures < math.MaxUint8 {
+		failures++
+	}
+	chunk.setRepairFailures(failures, time.Now())
+	return sf.createAndApplyTransaction(sf.saveChunkUpdate(chunk))
+}
+
+// RepairFailuresByIndex returns the number of consecutive failed repairs of
+// the chunk at the given index as well as the time of the last failure.
+func (sf *SiaFile) RepairFailuresByIndex(index uint64) (uint8, time.Time, error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if _, ok := sf.isIncludedPartialChunk(index); ok || sf.isIncompletePartialChunk(index) {
+		return 0, time.Time{}, nil
+	}
+	chunk, err := sf.chunk(int(index))
+	if err != nil {
+		return 0, time.Time{}, errors.AddContext(err, "failed to read chunk")
+	}
+	return chunk.repairFailures(), chunk.lastRepairFailure(), nil
+}
+
+// ResetRepairFailures resets the repair failures of the chunk at the given
+// index after a successful repair.
+func (sf *SiaFile) ResetRepairFailures(index uint64) error {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+	if _, ok := sf.isIncludedPartialChunk(index); ok || sf.isIncompletePartialChunk(index) {
+		return nil
+	}
+	if sf.deleted {
+		return errors.AddContext(ErrDeleted, "can't call ResetRepairFailures on deleted file")
+	}
+	chunk, err := sf.chunk(int(index))
+	if err != nil {
+		return err
+	}
+	// Check for change.
+	if chunk.repairFailures() == 0 && chunk.lastRepairFailure().IsZero() {
+		return nil
+	}
+	chunk.setRepairFailures(0, time.Time{})
+	return sf.createAndApplyTransaction(sf.saveChunkUpdate(chunk))
+}
+
+// ResetAbandoned marks all the abandoned chunks of the file as stuck again and
+// resets their repair attempts to give the stuck loop another chance at
+// repairing them.
+func (sf *SiaFile) ResetAbandoned() (err error) {
+	sf.mu.Lock()
+	defer sf.mu.Unlock()
+
+	// If the file has been deleted we can't reset the chunks.
+	if sf.deleted {
+		return errors.AddContext(ErrDeleted, "can't call ResetAbandoned on deleted file")
+	}
+	// Backup metadata before doing any kind of persistence.
+	defer func(backup Metadata) {
+		if err != nil {
+			sf.staticMetadata.restore(backup)
+		}
+	}(sf.staticMetadata.backup())
+	// Figure out which chunks to update.
+	var abandoned []chunk
+	errIter := sf.iterateChunksReadonly(func(chunk chunk) error {
+		if chunk.abandoned() {
+			abandoned = append(abandoned, chunk)
+		}
+		return nil
+	})
+	if errIter != nil {
+		return errIter
+	}
+	// Check if work needs to be done.
+	if len(abandoned) == 0 {
+		return nil
+	}
+	// Update metadata.
+	sf.staticMetadata.NumStuckChunks += uint64(len(abandoned))
+	sf.staticMetadata.NumAbandonedChunks = 0
+	// Create metadata updates and apply updates on disk
+	updates, err := sf.saveMetadataUpdates()
+	if err != nil {
+		return err
+	}
+	// Create chunk updates.
+	chunkUpdates, errIter := sf.iterateChunks(func(chunk *chunk) (bool, error) {
+		if len(abandoned) == 0 {
+			return false, nil
+		}
+		if chunk.Index == abandoned[0].Index {
+			chunk.setAbandoned(false)
+			chunk.setStuckRepairAttempts(0)
+			chunk.Stuck = true
+			abandoned = abandoned[1:]
+			return true, nil
+		}
+		return false, nil
+	})
+	if errIter != nil {
+		return errIter
+	}
+	// Apply updates.
+	updates = append(updates, chunkUpdates...)
+	return sf.createAndApplyTransaction(updates...)
+}
+
 // StuckChunkByIndex returns if the chunk at the index is marked as Stuck or not
 func (sf *SiaFile) StuckChunkByIndex(index uint64) (bool, error) {
 	sf.mu.Lock()
@@ -1381,6 +1871,9 @@ func (sf *SiaFile) removeLastChunk() (err error) {
 	if chunk.Stuck {
 		sf.staticMetadata.NumStuckChunks--
 	}
+	if chunk.abandoned() {
+		sf.staticMetadata.NumAbandonedChunks--
+	}
 	// Truncate the file on disk.
 	fi, err := os.Stat(sf.siaFilePath)
 	if err != nil {
@@ -1409,8 +1902,13 @@ func (sf *SiaFile) setStuck(index uint64, stuck bool) (err error) {
 	if err != nil {
 		return err
 	}
-	// Check for change
-	if stuck == chunk.Stuck {
+	// Abandoned chunks are only changed by ResetAbandoned.
+	if chunk.abandoned() {
+		return nil
+	}
+	// Check for change. Unsticking a chunk also resets its stuck repair
+	// attempts.
+	if stuck == chunk.Stuck && (stuck || chunk.stuckRepairAttempts() == 0) {
 		return nil
 	}
 	// Backup the changed metadata before changing it. Revert the change on
@@ -1421,12 +1919,15 @@ func (sf *SiaFile) setStuck(index uint64, stuck bool) (err error) {
 		}
 	}(sf.staticMetadata.backup())
 	// Update chunk and NumStuckChunks in siafile metadata
-	chunk.Stuck = stuck
-	if stuck {
+	if stuck && !chunk.Stuck {
 		sf.staticMetadata.NumStuckChunks++
-	} else {
+	} else if !stuck && chunk.Stuck {
 		sf.staticMetadata.NumStuckChunks--
 	}
+	chunk.Stuck = stuck
+	if !stuck {
+		chunk.setStuckRepairAttempts(0)
+	}
 	// Update chunk and metadata on disk
 	updates, err := sf.saveMetadataUpdates()
 	if err != nil {
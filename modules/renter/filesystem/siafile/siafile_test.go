@@ -299,6 +299,80 @@ func TestFileRedundancy(t *testing.T) {
 	}
 }
 
+// TestFileAvailability tests that the availability of a file takes the subnets
+// of the hosts into account.
+func TestFileAvailability(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a 2-of-3 file.
+	rsc, _ := modules.NewRSCode(2, 1)
+	siaFilePath, _, source, _, sk, fileSize, numChunks, fileMode := newTestFileParamsWithRC(2, false, rsc)
+	sf, _, _ := customTestFileAndWAL(siaFilePath, source, rsc, sk, fileSize, numChunks, fileMode)
+
+	// Create 3 hosts and store one piece of every chunk on each of them.
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+	var hosts []string
+	for i := 0; i < 3; i++ {
+		pk := types.SiaPublicKey{Key: []byte{byte(i)}}
+		offline[pk.String()] = false
+		goodForRenew[pk.String()] = true
+		hosts = append(hosts, pk.String())
+		for chunkIndex := uint64(0); chunkIndex < sf.NumChunks(); chunkIndex++ {
+			if err := sf.AddPiece(pk, chunkIndex, uint64(i), crypto.Hash{}); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// checkAvailability checks the availability of the file and its cached
+	// values.
+	checkAvailability := func(subnets map[string][]string, expectedAvailable, expectedResilient bool) {
+		t.Helper()
+		available, resilient, err := sf.Availability(offline, goodForRenew, subnets)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if available != expectedAvailable || resilient != expectedResilient {
+			t.Fatalf("expected %v and %v but got %v and %v", expectedAvailable, expectedResilient, available, resilient)
+		}
+		md := sf.Metadata()
+		if md.CachedLacksDiversity == available || md.CachedLacksRenewDiversity == resilient {
+			t.Fatal("cached values weren't updated", md.CachedLacksDiversity, md.CachedLacksRenewDiversity)
+		}
+	}
+
+	// Without subnets the file is available and resilient.
+	checkAvailability(nil, true, true)
+
+	// If all hosts are in the same subnet, the file is neither.
+	checkAvailability(map[string][]string{
+		hosts[0]: {"1.1.1.0/24"},
+		hosts[1]: {"1.1.1.0/24"},
+		hosts[2]: {"1.1.1.0/24", "::/54"},
+	}, false, false)
+
+	// If only two hosts share a subnet, the file is both.
+	subnets := map[string][]string{
+		hosts[0]: {"1.1.1.0/24"},
+		hosts[1]: {"1.1.1.0/24"},
+		hosts[2]: {"2.2.2.0/24"},
+	}
+	checkAvailability(subnets, true, true)
+
+	// If the host in the other subnet is not goodForRenew, the file is
+	// available but not resilient.
+	goodForRenew[hosts[2]] = false
+	checkAvailability(subnets, true, false)
+
+	// If it is offline, the file is unavailable.
+	offline[hosts[2]] = true
+	checkAvailability(subnets, false, false)
+}
+
 // TestFileHealth tests that the health of the file is correctly calculated.
 //
 // Health is equal to (targetParityPieces - actualParityPieces)/targetParityPieces
@@ -1161,6 +1235,190 @@ func TestStuckChunks(t *testing.T) {
 	}
 }
 
+// TestAbandonedChunks checks that chunks are abandoned after too many failed
+// stuck repairs, that the abandoned status is persisted and that it can be
+// reset.
+func TestAbandonedChunks(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a siafile with at least one full chunk.
+	sf, wal, _ := newBlankTestFileAndWAL(2)
+	maxAttempts := uint8(3)
+
+	// chunkAt is a helper to fetch the first chunk.
+	chunkAt := func() chunk {
+		c, err := sf.chunk(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// Fail the repair until right before the chunk is abandoned.
+	for i := uint8(1); i < maxAttempts; i++ {
+		abandoned, err := sf.MarkStuckRepairFailed(0, maxAttempts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if abandoned {
+			t.Fatal("chunk shouldn't be abandoned yet")
+		}
+		if c := chunkAt(); !c.Stuck || c.stuckRepairAttempts() != i {
+			t.Fatal("unexpected chunk state", c.Stuck, c.stuckRepairAttempts())
+		}
+	}
+	if sf.NumStuckChunks() != 1 || sf.NumAbandonedChunks() != 0 {
+		t.Fatal("wrong counts", sf.NumStuckChunks(), sf.NumAbandonedChunks())
+	}
+
+	// Unsticking the chunk should reset the attempts.
+	if err := sf.SetStuck(0, false); err != nil {
+		t.Fatal(err)
+	}
+	if c := chunkAt(); c.Stuck || c.stuckRepairAttempts() != 0 {
+		t.Fatal("unexpected chunk state", c.Stuck, c.stuckRepairAttempts())
+	}
+
+	// Fail the repair until the chunk is abandoned.
+	var abandoned bool
+	for i := uint8(0); i < maxAttempts; i++ {
+		var err error
+		abandoned, err = sf.MarkStuckRepairFailed(0, maxAttempts)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !abandoned {
+		t.Fatal("chunk should be abandoned")
+	}
+	if sf.NumStuckChunks() != 0 || sf.NumAbandonedChunks() != 1 {
+		t.Fatal("wrong counts", sf.NumStuckChunks(), sf.NumAbandonedChunks())
+	}
+
+	// Marking the chunk as stuck shouldn't affect an abandoned chunk.
+	if err := sf.SetStuck(0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := sf.SetAllStuck(true); err != nil {
+		t.Fatal(err)
+	}
+	if c := chunkAt(); c.Stuck || !c.abandoned() {
+		t.Fatal("unexpected chunk state", c.Stuck, c.abandoned())
+	}
+	if sf.NumStuckChunks() != sf.NumChunks()-1 {
+		t.Fatal("wrong number of stuck chunks", sf.NumStuckChunks())
+	}
+	if err := sf.SetAllStuck(false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The abandoned chunk shouldn't count towards the health.
+	_, _, _, _, nsc, _, _ := sf.Health(nil, nil)
+	if nsc != 0 {
+		t.Fatal("abandoned chunk shouldn't be stuck", nsc)
+	}
+
+	// Reload the file and check that the state was persisted.
+	sf, err := LoadSiaFile(sf.SiaFilePath(), wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abandoned, err = sf.AbandonedChunkByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !abandoned || sf.NumAbandonedChunks() != 1 {
+		t.Fatal("abandoned state wasn't persisted", abandoned, sf.NumAbandonedChunks())
+	}
+
+	// Reset the abandoned chunks.
+	if err := sf.ResetAbandoned(); err != nil {
+		t.Fatal(err)
+	}
+	if c := chunkAt(); !c.Stuck || c.abandoned() || c.stuckRepairAttempts() != 0 {
+		t.Fatal("unexpected chunk state", c.Stuck, c.abandoned(), c.stuckRepairAttempts())
+	}
+	if sf.NumStuckChunks() != 1 || sf.NumAbandonedChunks() != 0 {
+		t.Fatal("wrong counts", sf.NumStuckChunks(), sf.NumAbandonedChunks())
+	}
+	if err := ensureMetadataValid(sf.Metadata()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestRepairFailures checks that the repair failures of a chunk are tracked,
+// persisted and reset.
+func TestRepairFailures(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a siafile with at least one full chunk.
+	sf, wal, _ := newBlankTestFileAndWAL(2)
+
+	// A new chunk shouldn't have any failures.
+	failures, lastFailure, err := sf.RepairFailuresByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 0 || !lastFailure.IsZero() {
+		t.Fatal("unexpected failures", failures, lastFailure)
+	}
+
+	// Fail the repair a few times.
+	start := time.Now().Add(-time.Second)
+	for i := 0; i < 3; i++ {
+		if err := sf.MarkRepairFailed(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Reload the file and check that the failures were persisted.
+	sf, err = LoadSiaFile(sf.SiaFilePath(), wal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failures, lastFailure, err = sf.RepairFailuresByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 3 || lastFailure.Before(start) {
+		t.Fatal("unexpected failures", failures, lastFailure)
+	}
+
+	// The failures shouldn't affect the other chunk flags.
+	if c, err := sf.chunk(0); err != nil {
+		t.Fatal(err)
+	} else if c.Stuck || c.abandoned() || c.stuckRepairAttempts() != 0 {
+		t.Fatal("unexpected chunk state", c.Stuck, c.abandoned(), c.stuckRepairAttempts())
+	}
+
+	// The failures should show up in the chunk infos.
+	infos, err := sf.ChunkInfos()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) == 0 || infos[0].Index != 0 || infos[0].RepairFailures != 3 {
+		t.Fatal("unexpected chunk infos", infos)
+	}
+
+	// Reset the failures.
+	if err := sf.ResetRepairFailures(0); err != nil {
+		t.Fatal(err)
+	}
+	failures, lastFailure, err = sf.RepairFailuresByIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failures != 0 || !lastFailure.IsZero() {
+		t.Fatal("failures weren't reset", failures, lastFailure)
+	}
+}
+
 // TestUploadedBytes tests that uploadedBytes() returns the expected values for
 // total and unique uploaded bytes.
 func TestUploadedBytes(t *testing.T) {
@@ -70,6 +70,10 @@ func (sfr *SnapshotReader) Stat() (os.FileInfo, error) {
 // TODO: Things upstream would be a lot easier if we could drop the requirement
 // to hold a lock for the duration of the life of the snapshot reader.
 func (sf *SiaFile) SnapshotReader() (*SnapshotReader, error) {
+	// Flush pending changes since the reader reads the file from disk.
+	if err := sf.Flush(); err != nil {
+		return nil, errors.AddContext(err, "failed to flush pending changes")
+	}
 	// Lock the file.
 	sf.mu.RLock()
 	if sf.deleted {
@@ -0,0 +1,137 @@
+package siafile
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/crypto"
+)
+
+type (
+	// ChunkSummary is a summary of a single chunk of a SiaFile as it is
+	// persisted on disk. It is meant for inspecting SiaFiles and is computed
+	// without knowledge of the renter's contracts which is why the health
+	// assumes that all hosts are online and good for renew.
+	ChunkSummary struct {
+		Index  int   `json:"index"`
+		Offset int64 `json:"offset"`
+
+		// Partial indicates that the chunk is a partial chunk. Partial chunks
+		// are stored in the partials siafile and are therefore not parsed.
+		Partial bool `json:"partial"`
+		Stuck   bool `json:"stuck"`
+
+		// NumPieces is the total number of pieces of the chunk and
+		// UniquePieces the number of piece indices with at least one piece.
+		NumPieces          int     `json:"numpieces"`
+		UniquePieces       int     `json:"uniquepieces"`
+		InvalidHostOffsets int     `json:"invalidhostoffsets"`
+		Health             float64 `json:"health"`
+
+		Pieces []PieceSummary `json:"pieces,omitempty"`
+
+		// Err is set if the chunk couldn't be parsed. It contains the byte
+		// offset of the chunk within the file.
+		Err string `json:"err,omitempty"`
+	}
+
+	// PieceSummary is a summary of a single piece of a chunk.
+	PieceSummary struct {
+		PieceIndex      uint32      `json:"pieceindex"`
+		HostTableOffset uint32      `json:"hosttableoffset"`
+		HostPubKey      string      `json:"hostpubkey"`
+		MerkleRoot      crypto.Hash `json:"merkleroot"`
+	}
+)
+
+// ChunkSummaries reads all the chunks of the SiaFile from disk and returns a
+// summary for each of them. Unlike other methods which read chunks, it doesn't
+// fail on the first corrupted chunk. Instead the summary of the chunk will
+// contain the error and the remaining chunks are still parsed.
+func (sf *SiaFile) ChunkSummaries() (_ []ChunkSummary, err error) {
+	sf.mu.RLock()
+	defer sf.mu.RUnlock()
+	if sf.deleted {
+		return nil, errors.AddContext(ErrDeleted, "can't call ChunkSummaries on deleted file")
+	}
+	f, err := os.Open(sf.siaFilePath)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to open file")
+	}
+	defer func() {
+		err = errors.Compose(err, f.Close())
+	}()
+
+	ec := sf.staticMetadata.staticErasureCode
+	chunkBytes := make([]byte, int(sf.staticMetadata.StaticPagesPerChunk)*pageSize)
+	summaries := make([]ChunkSummary, 0, sf.numChunks)
+	for chunkIndex := 0; chunkIndex < sf.numChunks; chunkIndex++ {
+		summary := ChunkSummary{
+			Index:  chunkIndex,
+			Offset: sf.chunkOffset(chunkIndex),
+		}
+		_, included := sf.isIncludedPartialChunk(uint64(chunkIndex))
+		if included || sf.isIncompletePartialChunk(uint64(chunkIndex)) {
+			summary.Partial = true
+			summaries = append(summaries, summary)
+			continue
+		}
+		// Read and parse the chunk. The last chunk is not padded which is why
+		// reading a partial chunk is fine.
+		for i := range chunkBytes {
+			chunkBytes[i] = 0
+		}
+		n, err := f.ReadAt(chunkBytes, summary.Offset)
+		if errors.Contains(err, io.EOF) && n > 0 {
+			err = nil
+		}
+		if err != nil {
+			summary.Err = fmt.Sprintf("failed to read chunk at byte offset %v: %v", summary.Offset, err)
+			summaries = append(summaries, summary)
+			continue
+		}
+		c, err := unmarshalChunk(uint32(ec.NumPieces()), chunkBytes)
+		if err != nil {
+			summary.Err = fmt.Sprintf("failed to unmarshal chunk at byte offset %v: %v", summary.Offset, err)
+			summaries = append(summaries, summary)
+			continue
+		}
+		summary.Stuck = c.Stuck
+		for pieceIndex, pieceSet := range c.Pieces {
+			if len(pieceSet) > 0 {
+				summary.UniquePieces++
+			}
+			for _, piece := range pieceSet {
+				ps := PieceSummary{
+					PieceIndex:      uint32(pieceIndex),
+					HostTableOffset: piece.HostTableOffset,
+					MerkleRoot:      piece.MerkleRoot,
+				}
+				if piece.HostTableOffset < uint32(len(sf.pubKeyTable)) {
+					ps.HostPubKey = sf.pubKeyTable[piece.HostTableOffset].PublicKey.String()
+				} else {
+					summary.InvalidHostOffsets++
+				}
+				summary.Pieces = append(summary.Pieces, ps)
+				summary.NumPieces++
+			}
+		}
+		summary.Health = summaryHealth(summary.UniquePieces, ec.MinPieces(), ec.NumPieces())
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// summaryHealth is a wrapper for CalculateHealth which also handles erasure
+// codes without redundancy.
+func summaryHealth(goodPieces, minPieces, numPieces int) float64 {
+	if minPieces == numPieces {
+		if goodPieces < minPieces {
+			return 1 + float64(minPieces-goodPieces)/float64(minPieces)
+		}
+		return 0
+	}
+	return CalculateHealth(goodPieces, minPieces, numPieces)
+}
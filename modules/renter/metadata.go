@@ -37,31 +37,35 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath modules.SiaPath) (siadir
 	// Set default metadata values to start
 	now := time.Now()
 	metadata := siadir.Metadata{
-		AggregateHealth:              siadir.DefaultDirHealth,
-		AggregateLastHealthCheckTime: now,
-		AggregateMinRedundancy:       math.MaxFloat64,
-		AggregateModTime:             time.Time{},
-		AggregateNumFiles:            uint64(0),
-		AggregateNumStuckChunks:      uint64(0),
-		AggregateNumSubDirs:          uint64(0),
-		AggregateRemoteHealth:        siadir.DefaultDirHealth,
-		AggregateRepairSize:          uint64(0),
-		AggregateSize:                uint64(0),
-		AggregateStuckHealth:         siadir.DefaultDirHealth,
-		AggregateStuckSize:           uint64(0),
-
-		Health:              siadir.DefaultDirHealth,
-		LastHealthCheckTime: now,
-		MinRedundancy:       math.MaxFloat64,
-		ModTime:             time.Time{},
-		NumFiles:            uint64(0),
-		NumStuckChunks:      uint64(0),
-		NumSubDirs:          uint64(0),
-		RemoteHealth:        siadir.DefaultDirHealth,
-		RepairSize:          uint64(0),
-		Size:                uint64(0),
-		StuckHealth:         siadir.DefaultDirHealth,
-		StuckSize:           uint64(0),
+		AggregateHealth:                 siadir.DefaultDirHealth,
+		AggregateLastHealthCheckTime:    now,
+		AggregateMinRedundancy:          math.MaxFloat64,
+		AggregateModTime:                time.Time{},
+		AggregateNumAbandonedChunks:     uint64(0),
+		AggregateNumChunksNeedingRepair: uint64(0),
+		AggregateNumFiles:               uint64(0),
+		AggregateNumStuckChunks:         uint64(0),
+		AggregateNumSubDirs:             uint64(0),
+		AggregateRemoteHealth:           siadir.DefaultDirHealth,
+		AggregateRepairSize:             uint64(0),
+		AggregateSize:                   uint64(0),
+		AggregateStuckHealth:            siadir.DefaultDirHealth,
+		AggregateStuckSize:              uint64(0),
+
+		Health:                 siadir.DefaultDirHealth,
+		LastHealthCheckTime:    now,
+		MinRedundancy:          math.MaxFloat64,
+		ModTime:                time.Time{},
+		NumAbandonedChunks:     uint64(0),
+		NumChunksNeedingRepair: uint64(0),
+		NumFiles:               uint64(0),
+		NumStuckChunks:         uint64(0),
+		NumSubDirs:             uint64(0),
+		RemoteHealth:           siadir.DefaultDirHealth,
+		RepairSize:             uint64(0),
+		Size:                   uint64(0),
+		StuckHealth:            siadir.DefaultDirHealth,
+		StuckSize:              uint64(0),
 	}
 	// Read directory
 	fileinfos, err := r.staticFileSystem.ReadDir(siaPath)
@@ -170,6 +174,8 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath modules.SiaPath) (siadir
 			}
 
 			// Update aggregate fields.
+			metadata.AggregateNumAbandonedChunks += fileMetadata.NumAbandonedChunks
+			metadata.AggregateNumChunksNeedingRepair += fileMetadata.NumChunksNeedingRepair
 			metadata.AggregateNumFiles++
 			metadata.AggregateNumStuckChunks += fileMetadata.NumStuckChunks
 			metadata.AggregateSize += fileMetadata.Size
@@ -185,6 +191,8 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath modules.SiaPath) (siadir
 			if fileMetadata.ModTime.After(metadata.ModTime) {
 				metadata.ModTime = fileMetadata.ModTime
 			}
+			metadata.NumAbandonedChunks += fileMetadata.NumAbandonedChunks
+			metadata.NumChunksNeedingRepair += fileMetadata.NumChunksNeedingRepair
 			metadata.NumFiles++
 			metadata.NumStuckChunks += fileMetadata.NumStuckChunks
 			if !fileMetadata.OnDisk {
@@ -226,6 +234,8 @@ func (r *Renter) callCalculateDirectoryMetadata(siaPath modules.SiaPath) (siadir
 			aggregateRemoteHealth = dirMetadata.AggregateRemoteHealth
 
 			// Update aggregate fields.
+			metadata.AggregateNumAbandonedChunks += dirMetadata.AggregateNumAbandonedChunks
+			metadata.AggregateNumChunksNeedingRepair += dirMetadata.AggregateNumChunksNeedingRepair
 			metadata.AggregateNumFiles += dirMetadata.AggregateNumFiles
 			metadata.AggregateNumStuckChunks += dirMetadata.AggregateNumStuckChunks
 			metadata.AggregateNumSubDirs += dirMetadata.AggregateNumSubDirs
@@ -294,9 +304,12 @@ func (r *Renter) managedCachedFileMetadata(siaPath modules.SiaPath) (bubbledSiaF
 	// Grab the metadata to pull the cached information from
 	md := sf.Metadata()
 
-	// Check if original file is on disk
+	// Check if original file is on disk. The data of a conversion siafile is
+	// still available from the original file so it is never considered
+	// remote.
 	_, err = os.Stat(sf.LocalPath())
-	onDisk := err == nil
+	_, conversion := conversionSourceSiaPath(siaPath)
+	onDisk := err == nil || conversion
 	if !onDisk && md.CachedRedundancy < 1 {
 		r.log.Debugf("File not found on disk and possibly unrecoverable: LocalPath %v; SiaPath %v", sf.LocalPath(), siaPath.String())
 	}
@@ -305,17 +318,19 @@ func (r *Renter) managedCachedFileMetadata(siaPath modules.SiaPath) (bubbledSiaF
 	return bubbledSiaFileMetadata{
 		sp: siaPath,
 		bm: siafile.BubbledMetadata{
-			Health:              md.CachedHealth,
-			LastHealthCheckTime: sf.LastHealthCheckTime(),
-			ModTime:             sf.ModTime(),
-			NumStuckChunks:      md.CachedNumStuckChunks,
-			OnDisk:              onDisk,
-			Redundancy:          md.CachedRedundancy,
-			RepairBytes:         md.CachedRepairBytes,
-			Size:                sf.Size(),
-			StuckHealth:         md.CachedStuckHealth,
-			StuckBytes:          md.CachedStuckBytes,
-			UID:                 sf.UID(),
+			Health:                 md.CachedHealth,
+			LastHealthCheckTime:    sf.LastHealthCheckTime(),
+			ModTime:                sf.ModTime(),
+			NumAbandonedChunks:     md.NumAbandonedChunks,
+			NumChunksNeedingRepair: md.CachedNumChunksNeedingRepair,
+			NumStuckChunks:         md.CachedNumStuckChunks,
+			OnDisk:                 onDisk,
+			Redundancy:             md.CachedRedundancy,
+			RepairBytes:            md.CachedRepairBytes,
+			Size:                   sf.Size(),
+			StuckHealth:            md.CachedStuckHealth,
+			StuckBytes:             md.CachedStuckBytes,
+			UID:                    sf.UID(),
 		},
 	}, nil
 }
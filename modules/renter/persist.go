@@ -3,6 +3,7 @@ package renter
 import (
 	"os"
 	"path/filepath"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/writeaheadlog"
@@ -24,6 +25,9 @@ const (
 	SiaDirMetadata = ".siadir"
 	// walFile is the filename of the renter's writeaheadlog's file.
 	walFile = modules.RenterDir + ".wal"
+	// uploadStagingDir is the name of the directory upload sources are staged
+	// in.
+	uploadStagingDir = "uploadstaging"
 )
 
 var (
@@ -56,13 +60,38 @@ var (
 type (
 	// persist contains all of the persistent renter data.
 	persistence struct {
-		MaxDownloadSpeed int64
-		MaxUploadSpeed   int64
-		UploadedBackups  []modules.UploadedBackup
-		SyncedContracts  []types.FileContractID
+		DisabledWorkers        []types.SiaPublicKey
+		MaxDownloadSpeed       int64
+		MaxRepairAttempts      uint64
+		MaxStuckRepairAttempts uint64
+		MaxUploadSpeed         int64
+		UploadedBackups        []modules.UploadedBackup
+
+		MetadataBatchWindow     time.Duration
+		DisableMetadataBatching bool
+
+		SkipUnavailableLocalFiles bool
+		MaxConcurrentRepairs      uint64
+		DiskLatencyThrottle       bool
+		MaxSubscriptionsPerWorker uint64
+
+		MaxRepairDownloadSpeed int64
+		MaxRepairUploadSpeed   int64
+
+		UploadStagingSize uint64
+		SyncedContracts   []types.FileContractID
 	}
 )
 
+// metadataBatchWindow returns the window the metadata batcher should use
+// according to the persisted settings.
+func (p persistence) metadataBatchWindow() time.Duration {
+	if p.DisableMetadataBatching {
+		return 0
+	}
+	return p.MetadataBatchWindow
+}
+
 // saveSync stores the current renter data to disk and then syncs to disk.
 func (r *Renter) saveSync() error {
 	return persist.SaveJSON(settingsMetadata, r.persist, filepath.Join(r.persistDir, PersistFilename))
@@ -76,6 +105,9 @@ func (r *Renter) managedLoadSettings() error {
 		// No persistence yet, set the defaults and continue.
 		r.persist.MaxDownloadSpeed = DefaultMaxDownloadSpeed
 		r.persist.MaxUploadSpeed = DefaultMaxUploadSpeed
+		r.persist.MaxRepairAttempts = DefaultMaxRepairAttempts
+		r.persist.MaxStuckRepairAttempts = DefaultMaxStuckRepairAttempts
+		r.persist.MetadataBatchWindow = DefaultMetadataBatchWindow
 		id := r.mu.Lock()
 		err = r.saveSync()
 		r.mu.Unlock(id)
@@ -108,6 +140,26 @@ func (r *Renter) managedLoadSettings() error {
 		return err
 	}
 
+	// Older persist files don't contain the max repair attempts.
+	if r.persist.MaxRepairAttempts == 0 {
+		r.persist.MaxRepairAttempts = DefaultMaxRepairAttempts
+	}
+	// Nor the max stuck repair attempts.
+	if r.persist.MaxStuckRepairAttempts == 0 {
+		r.persist.MaxStuckRepairAttempts = DefaultMaxStuckRepairAttempts
+	}
+	// Older persist files don't contain the metadata batch window either.
+	if r.persist.MetadataBatchWindow == 0 {
+		r.persist.MetadataBatchWindow = DefaultMetadataBatchWindow
+	}
+	r.staticMetadataBatcher.SetWindow(r.persist.metadataBatchWindow())
+
+	// Set the repair bandwidth limits.
+	err = setRateLimits(r.staticRepairRL, r.persist.MaxRepairDownloadSpeed, r.persist.MaxRepairUploadSpeed)
+	if err != nil {
+		return err
+	}
+
 	// Set the bandwidth limits on the contractor, which was already initialized
 	// without bandwidth limits.
 	return r.setBandwidthLimits(r.persist.MaxDownloadSpeed, r.persist.MaxUploadSpeed)
@@ -141,6 +193,10 @@ func (r *Renter) managedInitPersist() error {
 	if err := r.tg.AfterStop(wal.Close); err != nil {
 		return err
 	}
+	// Flush the batched metadata writes before the wal is closed.
+	if err := r.tg.AfterStop(r.staticMetadataBatcher.Close); err != nil {
+		return err
+	}
 
 	// Apply unapplied wal txns before loading the persistence structure to
 	// avoid loading potentially corrupted files.
@@ -169,7 +225,7 @@ func (r *Renter) managedInitPersist() error {
 	}
 
 	// Create the filesystem.
-	fs, err := filesystem.New(fsRoot, r.log, wal)
+	fs, err := filesystem.New(fsRoot, r.log, wal, r.staticMetadataBatcher)
 	if err != nil {
 		return err
 	}
@@ -184,6 +240,12 @@ func (r *Renter) managedInitPersist() error {
 		return errors.AddContext(err, "failed to load renter's persistence structrue")
 	}
 
+	// Initialize the upload staging.
+	r.staticUploadStaging, err = newUploadStaging(filepath.Join(r.persistDir, uploadStagingDir), r.persist.UploadStagingSize)
+	if err != nil {
+		return errors.AddContext(err, "failed to initialize upload staging")
+	}
+
 	// Create the essential dirs in the filesystem.
 	err = fs.NewSiaDir(modules.HomeFolder, modules.DefaultDirPerm)
 	if err != nil && !errors.Contains(err, filesystem.ErrExists) {
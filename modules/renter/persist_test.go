@@ -79,6 +79,9 @@ func TestRenterSaveLoad(t *testing.T) {
 	if settings.MaxUploadSpeed != DefaultMaxUploadSpeed {
 		t.Error("default max upload speed not set at init")
 	}
+	if settings.MaxStuckRepairAttempts != DefaultMaxStuckRepairAttempts {
+		t.Error("default max stuck repair attempts not set at init")
+	}
 
 	// The registry stats should be seeded.
 	if rt.renter.staticRRS.Estimate() != readRegistryStatsSeed+readRegistryStatsInterval {
@@ -93,8 +96,10 @@ func TestRenterSaveLoad(t *testing.T) {
 	// download speed.
 	newDownSpeed := int64(300e3)
 	newUpSpeed := int64(500e3)
+	newStuckRepairAttempts := uint64(DefaultMaxStuckRepairAttempts + 1)
 	settings.MaxDownloadSpeed = newDownSpeed
 	settings.MaxUploadSpeed = newUpSpeed
+	settings.MaxStuckRepairAttempts = newStuckRepairAttempts
 	err = rt.renter.SetSettings(settings)
 	if err != nil {
 		t.Fatal(err)
@@ -146,6 +151,9 @@ func TestRenterSaveLoad(t *testing.T) {
 	if newSettings.MaxUploadSpeed != newUpSpeed {
 		t.Error("upload settings not being persisted correctly")
 	}
+	if newSettings.MaxStuckRepairAttempts != newStuckRepairAttempts {
+		t.Error("max stuck repair attempts not being persisted correctly")
+	}
 
 	// Check that SiaFileSet loaded the renter's file
 	_, err = rt.renter.staticFileSystem.OpenSiaFile(siapath)
@@ -258,6 +258,7 @@ func (c *SafeContract) Metadata() modules.RenterContract {
 		TxnFee:              h.TxnFee,
 		SiafundFee:          h.SiafundFee,
 		Utility:             h.Utility,
+		NumRoots:            uint64(c.merkleRoots.len()),
 	}
 }
 
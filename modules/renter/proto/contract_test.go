@@ -936,6 +936,79 @@ func TestContractRecordCommitAppendIntent(t *testing.T) {
 	}
 }
 
+// TestContractMetadataNumRoots makes sure that the metadata of a contract
+// reports the number of merkle roots of the contract.
+func TestContractMetadataNumRoots(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create contract set
+	dir := build.TempDir(filepath.Join("proto", t.Name()))
+	rl := ratelimit.NewRateLimit(0, 0, 0)
+	cs, err := NewContractSet(dir, rl, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := cs.Close(); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	// add a contract with a few roots
+	initialHeader := contractHeader{
+		Transaction: types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{{
+				NewRevisionNumber: 1,
+				NewValidProofOutputs: []types.SiacoinOutput{
+					{Value: types.SiacoinPrecision},
+					{Value: types.SiacoinPrecision},
+				},
+				NewMissedProofOutputs: []types.SiacoinOutput{
+					{Value: types.SiacoinPrecision},
+					{Value: types.SiacoinPrecision},
+					{Value: types.ZeroCurrency},
+				},
+				UnlockConditions: types.UnlockConditions{
+					PublicKeys: []types.SiaPublicKey{{}, {}},
+				},
+			}},
+		},
+	}
+	initialRoots := []crypto.Hash{{1}, {2}, {3}}
+	contract, err := cs.managedInsertContract(initialHeader, initialRoots)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contract.NumRoots != uint64(len(initialRoots)) {
+		t.Fatalf("expected %v roots but got %v", len(initialRoots), contract.NumRoots)
+	}
+	sc := cs.managedMustAcquire(t, contract.ID)
+
+	// append a root
+	curr := sc.LastRevision()
+	newRoot := crypto.Hash{4}
+	rev, err := newUploadRevision(curr, newRoot, types.ZeroCurrency, types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	walTxn, err := sc.managedRecordAppendIntent(rev, newRoot, types.ZeroCurrency, types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sc.managedCommitAppend(walTxn, rev.ToTransaction(), types.ZeroCurrency, types.ZeroCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := sc.Metadata()
+	if md.NumRoots != uint64(len(initialRoots)+1) {
+		t.Fatalf("expected %v roots but got %v", len(initialRoots)+1, md.NumRoots)
+	}
+	cs.Return(sc)
+}
+
 // TestContractRecordCommitRenewAndClearIntent tests recording and committing
 // downloads and makes sure they use the wal correctly.
 func TestContractRecordCommitRenewAndClearIntent(t *testing.T) {
@@ -122,6 +122,13 @@ func (he *Editor) Upload(data []byte) (_ modules.RenterContract, _ crypto.Hash,
 				u.LastOOSErr = he.height
 				err = errors.Compose(err, sc.UpdateUtility(u))
 			}
+			// If the contract reached the host's max contract size, we stop
+			// uploading to it.
+			if modules.IsContractFullErr(err) {
+				u := sc.Utility()
+				u.GoodForUpload = false
+				err = errors.Compose(err, sc.UpdateUtility(u))
+			}
 			he.hdb.IncrementFailedInteractions(he.host.PublicKey)
 			err = errors.Extend(err, modules.ErrHostFault)
 		} else {
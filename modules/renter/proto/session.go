@@ -296,6 +296,13 @@ func (s *Session) write(sc *SafeContract, actions []modules.LoopWriteAction) (_
 	defer func() {
 		// Increase Successful/Failed interactions accordingly
 		if err != nil {
+			// If the contract reached the host's max contract size, we stop
+			// uploading to it.
+			if modules.IsContractFullErr(err) {
+				u := sc.Utility()
+				u.GoodForUpload = false
+				err = errors.Compose(err, sc.UpdateUtility(u))
+			}
 			s.hdb.IncrementFailedInteractions(s.host.PublicKey)
 		} else {
 			s.hdb.IncrementSuccessfulInteractions(s.host.PublicKey)
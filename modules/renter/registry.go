@@ -3,6 +3,7 @@ package renter
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -37,6 +38,11 @@ var (
 	// returned instead if the lookup timed out before all workers returned.
 	ErrRegistryLookupTimeout = errors.New("registry entry not found within given time")
 
+	// ErrRegistryQuorumNotReached is returned if a registry lookup with
+	// RegistryReadConsistencyQuorum didn't receive enough successful
+	// responses.
+	ErrRegistryQuorumNotReached = errors.New("registry lookup failed to reach a quorum of hosts")
+
 	// ErrRegistryUpdateInsufficientRedundancy is returned if updating the
 	// registry failed due to running out of workers before reaching
 	// MinUpdateRegistrySuccess successful updates.
@@ -58,6 +64,11 @@ var (
 		Testing:  3,
 	}).(int)
 
+	// DefaultRegistryReadQuorum is the default fraction of the queried hosts
+	// that need to respond to a registry lookup with
+	// RegistryReadConsistencyQuorum.
+	DefaultRegistryReadQuorum = 0.5
+
 	// ReadRegistryBackgroundTimeout is the amount of time a read registry job
 	// can stay active in the background before being cancelled.
 	ReadRegistryBackgroundTimeout = build.Select(build.Var{
@@ -224,11 +235,83 @@ func (rs *readRegistryStats) threadedAddResponseSet(ctx context.Context, startTi
 	_ = rs.AddDatum(d)
 }
 
+// markStaleRegistryResponses reports the workers of the successful responses
+// which returned a lower revision than the highest one or no entry at all
+// even though another host had one.
+func markStaleRegistryResponses(resps []*jobReadRegistryResponse) {
+	// Find the highest revision.
+	var highest *modules.SignedRegistryValue
+	for _, resp := range resps {
+		if resp.staticErr != nil || resp.staticSignedRegistryValue == nil {
+			continue
+		}
+		if highest == nil || resp.staticSignedRegistryValue.Revision > highest.Revision {
+			highest = resp.staticSignedRegistryValue
+		}
+	}
+	// If no host has the entry, none of them is stale.
+	if highest == nil {
+		return
+	}
+	for _, resp := range resps {
+		if resp.staticErr != nil || resp.staticWorker == nil {
+			continue
+		}
+		if resp.staticSignedRegistryValue == nil || resp.staticSignedRegistryValue.Revision < highest.Revision {
+			resp.staticWorker.staticJobReadRegistryQueue.callReportStale()
+		}
+	}
+}
+
+// quorumResponses returns the number of responses out of numWorkers required
+// to reach the quorum. At least one response is always required.
+func quorumResponses(numWorkers int, quorum float64) int {
+	needed := int(math.Ceil(quorum * float64(numWorkers)))
+	if needed < 1 {
+		needed = 1
+	}
+	if needed > numWorkers {
+		needed = numWorkers
+	}
+	return needed
+}
+
 // ReadRegistry starts a registry lookup on all available workers. The
 // jobs have 'timeout' amount of time to finish their jobs and return a
 // response. Otherwise the response with the highest revision number will be
-// used.
-func (r *Renter) ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout time.Duration) (modules.SignedRegistryValue, error) {
+// used. The consistency determines how many workers the lookup waits for
+// and quorum is the fraction of the workers that need to respond for
+// RegistryReadConsistencyQuorum. A quorum of 0 uses the
+// DefaultRegistryReadQuorum.
+func (r *Renter) ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout time.Duration, consistency modules.RegistryReadConsistency, quorum float64) (modules.SignedRegistryValue, error) {
+	// Check the consistency.
+	consistency, err := modules.ParseRegistryReadConsistency(string(consistency))
+	if err != nil {
+		return modules.SignedRegistryValue{}, err
+	}
+	if quorum == 0 {
+		quorum = DefaultRegistryReadQuorum
+	}
+	if quorum < 0 || quorum > 1 {
+		return modules.SignedRegistryValue{}, fmt.Errorf("quorum needs to be within (0, 1] but was %v", quorum)
+	}
+
+	// Mark the subscriptions for the entry as used. That way frequently read
+	// entries aren't evicted and evicted ones are subscribed to again.
+	sid := modules.DeriveRegistryEntryID(spk, tweak)
+	r.managedMarkSubscriptionUsed(sid)
+
+	// If a worker is subscribed to the entry, the host keeps us up-to-date
+	// and there is no need to look it up. This only applies to the fastest
+	// consistency since the other levels require the responses of multiple
+	// hosts.
+	if consistency == modules.RegistryReadConsistencyFastest {
+		srv, cached := r.staticRegistrySubscriptionCache.callGet(sid)
+		if cached {
+			return srv, nil
+		}
+	}
+
 	// Create a context. If the timeout is greater than zero, have the context
 	// expire when the timeout triggers.
 	ctx := r.tg.StopCtx()
@@ -247,7 +330,7 @@ func (r *Renter) ReadRegistry(spk types.SiaPublicKey, tweak crypto.Hash, timeout
 	defer r.registryMemoryManager.Return(readRegistryMemory)
 
 	// Start the ReadRegistry jobs.
-	srv, err := r.managedReadRegistry(ctx, spk, tweak)
+	srv, err := r.managedReadRegistry(ctx, spk, tweak, consistency, quorum)
 	if errors.Contains(err, ErrRegistryLookupTimeout) {
 		err = errors.AddContext(err, fmt.Sprintf("timed out after %vs", timeout.Seconds()))
 	}
@@ -286,7 +369,7 @@ func (r *Renter) UpdateRegistry(spk types.SiaPublicKey, srv modules.SignedRegist
 // jobs have 'timeout' amount of time to finish their jobs and return a
 // response. Otherwise the response with the highest revision number will be
 // used.
-func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash) (modules.SignedRegistryValue, error) {
+func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey, tweak crypto.Hash, consistency modules.RegistryReadConsistency, quorum float64) (modules.SignedRegistryValue, error) {
 	// Specify a sane timeout for jobs that is independent of the user specified
 	// timeout. It is the maximum time that we let a job execute in the
 	// background before cancelling it.
@@ -345,19 +428,35 @@ func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey
 	// Create the response set.
 	responseSet := newReadResponseSet(staticResponseChan, numWorkers)
 
-	// Add the response set to the stats after this method is done.
+	// Add the response set to the stats and remember the hosts which returned
+	// stale values after this method is done.
 	startTime := time.Now()
 	defer func() {
 		_ = r.tg.Launch(func() {
 			r.staticRRS.threadedAddResponseSet(backgroundCtx, startTime, responseSet)
+			markStaleRegistryResponses(responseSet.readResps)
 			backgroundCancel()
 		})
 	}()
 
-	// Further restrict the input timeout using historical data.
-	ctx, cancel := context.WithTimeout(ctx, r.staticRRS.Estimate())
+	// Further restrict the input timeout using historical data. The other
+	// consistency levels trade latency for a more up-to-date value and are
+	// only restricted by the time the jobs are allowed to run.
+	var cancel context.CancelFunc
+	if consistency == modules.RegistryReadConsistencyFastest {
+		ctx, cancel = context.WithTimeout(ctx, r.staticRRS.Estimate())
+	} else {
+		ctx, cancel = context.WithTimeout(ctx, ReadRegistryBackgroundTimeout)
+	}
 	defer cancel()
 
+	// Determine the number of successful responses to wait for if the lookup
+	// requires a quorum.
+	var needed int
+	if consistency == modules.RegistryReadConsistencyQuorum {
+		needed = quorumResponses(len(workers), quorum)
+	}
+
 	// Prepare a context which will be overwritten by a child context with a timeout
 	// when we receive the first response. useHighestRevDefaultTimeout after
 	// receiving the first response, this will be closed to abort the search for
@@ -366,10 +465,11 @@ func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey
 
 	var srv *modules.SignedRegistryValue
 	responses := 0
+	successfulResponses := 0
 	for responseSet.responsesLeft() > 0 {
 		// Check cancel condition and block for more responses.
 		var resp *jobReadRegistryResponse
-		if srv != nil {
+		if srv != nil && consistency == modules.RegistryReadConsistencyFastest {
 			// If we have a successful response already, we wait on the highest
 			// rev ctx.
 			resp = responseSet.next(useHighestRevCtx)
@@ -393,19 +493,36 @@ func (r *Renter) managedReadRegistry(ctx context.Context, spk types.SiaPublicKey
 		// Increment responses.
 		responses++
 
-		// Ignore error responses and responses that returned no entry.
-		if resp.staticErr != nil || resp.staticSignedRegistryValue == nil {
+		// Ignore error responses.
+		if resp.staticErr != nil {
 			continue
 		}
+		successfulResponses++
 
 		// Remember the response with the highest revision number. We use >=
 		// here to also catch the edge case of the initial revision being 0.
-		revHigher := srv != nil && resp.staticSignedRegistryValue.RegistryValue.Revision > srv.Revision
-		revSame := srv != nil && resp.staticSignedRegistryValue.RegistryValue.Revision == srv.Revision
-		moreWork := srv != nil && resp.staticSignedRegistryValue.HasMoreWork(srv.RegistryValue)
-		if srv == nil || revHigher || (revSame && moreWork) {
-			srv = resp.staticSignedRegistryValue
+		if resp.staticSignedRegistryValue != nil {
+			revHigher := srv != nil && resp.staticSignedRegistryValue.RegistryValue.Revision > srv.Revision
+			revSame := srv != nil && resp.staticSignedRegistryValue.RegistryValue.Revision == srv.Revision
+			moreWork := srv != nil && resp.staticSignedRegistryValue.HasMoreWork(srv.RegistryValue)
+			if srv == nil || revHigher || (revSame && moreWork) {
+				srv = resp.staticSignedRegistryValue
+			}
+		}
+
+		// Responses that didn't find the entry count towards the quorum too.
+		if consistency == modules.RegistryReadConsistencyQuorum && successfulResponses >= needed {
+			break
+		}
+	}
+
+	// Check if we reached the quorum.
+	if consistency == modules.RegistryReadConsistencyQuorum && successfulResponses < needed {
+		err := errors.AddContext(ErrRegistryQuorumNotReached, fmt.Sprintf("%v of %v required hosts responded successfully", successfulResponses, needed))
+		if responses < len(workers) {
+			err = errors.Compose(err, ErrRegistryLookupTimeout)
 		}
+		return modules.SignedRegistryValue{}, err
 	}
 
 	// If we don't have a successful response and also not a response for every
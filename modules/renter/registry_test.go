@@ -3,7 +3,15 @@ package renter
 import (
 	"context"
 	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/host"
+	"go.sia.tech/siad/siatest/dependencies"
 )
 
 // TestReadResponseSet is a unit test for the readResponseSet.
@@ -96,3 +104,181 @@ func TestReadResponseSet(t *testing.T) {
 		t.Fatal("resps should be empty", resps)
 	}
 }
+
+// TestQuorumResponses is a unit test for quorumResponses.
+func TestQuorumResponses(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		numWorkers int
+		quorum     float64
+		needed     int
+	}{
+		{10, 0.5, 5},
+		{3, 0.5, 2},
+		{3, 1, 3},
+		{1, 0.5, 1},
+		{10, 0.01, 1},
+		{10, 2, 10},
+	}
+	for _, test := range tests {
+		if needed := quorumResponses(test.numWorkers, test.quorum); needed != test.needed {
+			t.Errorf("%v workers with quorum %v should need %v responses but needed %v", test.numWorkers, test.quorum, test.needed, needed)
+		}
+	}
+}
+
+// TestReadRegistryConsistency checks the value and latency of registry lookups
+// with the different consistency levels against hosts which serve different
+// revisions of the same entry.
+func TestReadRegistryConsistency(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// Add two more hosts. The last one takes a second to answer registry
+	// lookups.
+	fastHost, err := wt.rt.addCustomHost(filepath.Join(wt.rt.dir, "fasthost"), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slowHost, err := wt.rt.addCustomHost(filepath.Join(wt.rt.dir, "slowhost"), &dependencies.HostSlowRegistryRead{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait until all workers are ready.
+	err = build.Retry(600, 100*time.Millisecond, func() error {
+		r.staticWorkerPool.callUpdate()
+		workers := r.staticWorkerPool.callWorkers()
+		if len(workers) != 3 {
+			_, err = wt.rt.miner.AddBlock()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return fmt.Errorf("expected 3 workers but got %v", len(workers))
+		}
+		for _, w := range workers {
+			if !w.staticPriceTable().staticValid() || w.staticAccount.managedAvailableBalance().IsZero() {
+				return errors.New("worker is not ready yet")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Set increasing revisions of the entry on the hosts. The slow host has
+	// the most recent one.
+	rv, spk, sk := randomRegistryValue()
+	rv.Revision = 0
+	expiry := wt.staticCache().staticBlockHeight + 100
+	for _, h := range []modules.Host{wt.host, fastHost, slowHost} {
+		rv = rv.Sign(sk)
+		_, err = h.(*host.Host).RegistryUpdate(rv, spk, expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rv.Revision++
+	}
+	slowRevision := rv.Revision - 1
+
+	// read is a helper to read the entry with the given consistency and
+	// quorum and to time the lookup.
+	read := func(consistency modules.RegistryReadConsistency, quorum float64) (modules.SignedRegistryValue, time.Duration) {
+		start := time.Now()
+		srv, err := r.ReadRegistry(spk, rv.Tweak, MaxRegistryReadTimeout, consistency, quorum)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return srv, time.Since(start)
+	}
+
+	// The fastest lookup doesn't wait for the slow host and returns a stale
+	// value.
+	srv, d := read(modules.RegistryReadConsistencyFastest, 0)
+	if srv.Revision == slowRevision || d >= time.Second {
+		t.Fatal("fastest lookup waited for the slow host", srv.Revision, d)
+	}
+
+	// A quorum of half the hosts is reached by the two fast hosts.
+	srv, d = read(modules.RegistryReadConsistencyQuorum, 0.5)
+	if srv.Revision != slowRevision-1 || d >= time.Second {
+		t.Fatal("quorum lookup returned the wrong revision", srv.Revision, d)
+	}
+
+	// A quorum of all hosts and waiting for all of them returns the most
+	// recent value but takes longer.
+	for _, consistency := range []modules.RegistryReadConsistency{modules.RegistryReadConsistencyQuorum, modules.RegistryReadConsistencyAll} {
+		srv, d = read(consistency, 1)
+		if srv.Revision != slowRevision || d < time.Second {
+			t.Fatal("lookup didn't wait for the slow host", consistency, srv.Revision, d)
+		}
+	}
+
+	// Invalid parameters are rejected.
+	_, err = r.ReadRegistry(spk, rv.Tweak, time.Second, "invalid", 0)
+	if !errors.Contains(err, modules.ErrInvalidRegistryReadConsistency) {
+		t.Fatal("expected invalid consistency error", err)
+	}
+	_, err = r.ReadRegistry(spk, rv.Tweak, time.Second, modules.RegistryReadConsistencyQuorum, 1.5)
+	if err == nil {
+		t.Fatal("expected invalid quorum error")
+	}
+
+	// The hosts with the outdated revisions should be recorded as stale once
+	// all responses were collected.
+	slowKey := slowHost.PublicKey().String()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		for _, w := range r.staticWorkerPool.callWorkers() {
+			stale := w.callReadRegistryJobsStatus().StaleResponses
+			if w.staticHostPubKeyStr == slowKey && stale != 0 {
+				t.Fatal("slow host shouldn't be stale", stale)
+			} else if w.staticHostPubKeyStr != slowKey && stale != 4 {
+				return fmt.Errorf("expected 4 stale responses but got %v", stale)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The host which never returned a stale value should be preferred for
+	// subscriptions.
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+	workers := r.managedRegistrySubscriptionWorkers(sid, nil)
+	if len(workers) == 0 || workers[0].staticHostPubKeyStr != slowKey {
+		t.Fatal("expected the slow host to be preferred for subscriptions")
+	}
+
+	// Cache an outdated value as if a worker was subscribed to the entry. Only
+	// the fastest lookup should use the cache.
+	cachedRV := rv
+	cachedRV.Revision = 0
+	cachedRV = cachedRV.Sign(sk)
+	r.staticRegistrySubscriptionCache.callUpdate(slowKey, sid, cachedRV)
+	srv, _ = read(modules.RegistryReadConsistencyFastest, 0)
+	if srv.Revision != 0 {
+		t.Fatal("fastest lookup should use the cached value", srv.Revision)
+	}
+	for _, consistency := range []modules.RegistryReadConsistency{modules.RegistryReadConsistencyQuorum, modules.RegistryReadConsistencyAll} {
+		srv, _ = read(consistency, 1)
+		if srv.Revision != slowRevision {
+			t.Fatal("lookup shouldn't use the cached value", consistency, srv.Revision)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package renter
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// registrySubscriptionNumWorkers is the max number of workers a
+	// subscription of the renter's user is registered with. Using more than
+	// one worker makes sure updates are delivered even if one of the hosts
+	// goes offline or doesn't notify the worker.
+	registrySubscriptionNumWorkers = 3
+
+	// registrySubscriberBufferSize is the number of updates buffered for a
+	// subscriber of the renter. Once the buffer is full, the oldest update is
+	// dropped to make room for the newest one.
+	registrySubscriberBufferSize = 10
+)
+
+var (
+	// registrySubscriptionRefreshInterval is the interval at which the
+	// workers of a subscription of the renter's user are reselected. This
+	// replaces workers whose hosts went away and keeps the subscriptions of
+	// the selected workers from being evicted.
+	registrySubscriptionRefreshInterval = build.Select(build.Var{
+		Dev:      30 * time.Second,
+		Standard: time.Minute,
+		Testing:  time.Second,
+	}).(time.Duration)
+)
+
+var (
+	// errNoRegistrySubscriptionWorkers is returned by SubscribeRegistry if no
+	// worker is able to subscribe to registry entries.
+	errNoRegistrySubscriptionWorkers = errors.New("no workers available that support registry subscriptions")
+)
+
+type (
+	// registrySubscribers keeps track of the subscribers of the renter which
+	// want to be notified about updates to registry entries.
+	registrySubscribers struct {
+		subscribers map[modules.RegistryEntryID]map[*registrySubscriber]struct{}
+
+		// closed indicates that the renter is shutting down and that no more
+		// subscribers are accepted.
+		closed bool
+
+		mu sync.Mutex
+	}
+
+	// registrySubscriptionCandidate is a worker which could be used for a
+	// subscription of the renter's user.
+	registrySubscriptionCandidate struct {
+		w          *worker
+		subscribed bool
+		cooldown   bool
+		stale      uint64
+	}
+
+	// registrySubscriber is a single subscriber of a registry entry. Updates
+	// are delivered on staticC.
+	registrySubscriber struct {
+		staticC chan modules.SignedRegistryValue
+
+		// latest is the latest value delivered to the subscriber. It is used to
+		// deduplicate the updates received from multiple workers.
+		latest *modules.SignedRegistryValue
+	}
+)
+
+// newRegistrySubscribers creates a new registrySubscribers object.
+func newRegistrySubscribers() *registrySubscribers {
+	return &registrySubscribers{
+		subscribers: make(map[modules.RegistryEntryID]map[*registrySubscriber]struct{}),
+	}
+}
+
+// callAdd adds a new subscriber for the entry with the given id. If the
+// subscribers were already closed, the channel of the returned subscriber is
+// closed right away.
+func (rs *registrySubscribers) callAdd(sid modules.RegistryEntryID) *registrySubscriber {
+	s := &registrySubscriber{
+		staticC: make(chan modules.SignedRegistryValue, registrySubscriberBufferSize),
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.closed {
+		close(s.staticC)
+		return s
+	}
+	subscribers, exists := rs.subscribers[sid]
+	if !exists {
+		subscribers = make(map[*registrySubscriber]struct{})
+		rs.subscribers[sid] = subscribers
+	}
+	subscribers[s] = struct{}{}
+	return s
+}
+
+// callRemove removes the subscriber of the entry with the given id and closes
+// its channel.
+func (rs *registrySubscribers) callRemove(sid modules.RegistryEntryID, s *registrySubscriber) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	subscribers, exists := rs.subscribers[sid]
+	if !exists {
+		return
+	}
+	if _, exists := subscribers[s]; !exists {
+		return
+	}
+	delete(subscribers, s)
+	if len(subscribers) == 0 {
+		delete(rs.subscribers, sid)
+	}
+	close(s.staticC)
+}
+
+// callCloseAll removes all subscribers and closes their channels. Subscribers
+// added afterwards are closed right away. It is called when the renter shuts
+// down.
+func (rs *registrySubscribers) callCloseAll() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for sid, subscribers := range rs.subscribers {
+		for s := range subscribers {
+			close(s.staticC)
+		}
+		delete(rs.subscribers, sid)
+	}
+	rs.closed = true
+}
+
+// callNotify notifies the subscribers of the entry with the given id about an
+// update. Updates which don't have a higher revision than the latest value
+// delivered to a subscriber, or the same revision with more work, are ignored.
+// callNotify never blocks, which is why it is safe to call from the workers'
+// notification path.
+func (rs *registrySubscribers) callNotify(sid modules.RegistryEntryID, rv modules.SignedRegistryValue) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	for s := range rs.subscribers[sid] {
+		s.notify(rv)
+	}
+}
+
+// notify delivers the value to the subscriber if it is newer than the latest
+// delivered value. If the subscriber's buffer is full, the oldest buffered
+// update is dropped.
+func (s *registrySubscriber) notify(rv modules.SignedRegistryValue) {
+	if s.latest != nil && (rv.Revision < s.latest.Revision || (rv.Revision == s.latest.Revision && !rv.HasMoreWork(s.latest.RegistryValue))) {
+		return
+	}
+	s.latest = &rv
+	for {
+		select {
+		case s.staticC <- rv:
+			return
+		default:
+		}
+		// Drop the oldest update. The consumer might have drained the
+		// channel in the meantime which is why this can't block either.
+		select {
+		case <-s.staticC:
+		default:
+		}
+	}
+}
+
+// SubscribeRegistry subscribes to updates of the registry entry with the given
+// public key and tweak. The subscription is registered with up to
+// registrySubscriptionNumWorkers workers which run the subscription loop and
+// the updates received by any of them are delivered on the returned channel.
+// The workers are reselected periodically to replace workers whose hosts went
+// away. Only updates with a higher revision than the previously delivered one
+// are delivered. If the consumer falls behind, the oldest buffered updates are
+// dropped. The returned function cancels the subscription and closes the
+// channel. The channel is also closed when the renter shuts down.
+func (r *Renter) SubscribeRegistry(spk types.SiaPublicKey, tweak crypto.Hash) (<-chan modules.SignedRegistryValue, func(), error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, nil, err
+	}
+	defer r.tg.Done()
+
+	// Pick the workers to subscribe with.
+	sid := modules.DeriveRegistryEntryID(spk, tweak)
+	workers := r.managedRegistrySubscriptionWorkers(sid, nil)
+	if len(workers) == 0 {
+		return nil, nil, errNoRegistrySubscriptionWorkers
+	}
+
+	// Register the subscriber before subscribing with the workers to not miss
+	// any updates.
+	s := r.staticRegistrySubscribers.callAdd(sid)
+	if rv, cached := r.staticRegistrySubscriptionCache.callGet(sid); cached {
+		r.staticRegistrySubscribers.callNotify(sid, rv)
+	}
+	for _, w := range workers {
+		w.managedSubscribe(spk, tweak)
+	}
+
+	// Keep the workers up-to-date in the background. The thread owns the
+	// workers' references to the subscription from now on.
+	done := make(chan struct{})
+	err := r.tg.Launch(func() {
+		r.threadedRefreshRegistrySubscription(spk, tweak, workers, done)
+	})
+	if err != nil {
+		for _, w := range workers {
+			w.managedUnsubscribe(sid)
+		}
+		r.staticRegistrySubscribers.callRemove(sid, s)
+		return nil, nil, err
+	}
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			r.staticRegistrySubscribers.callRemove(sid, s)
+			close(done)
+		})
+	}
+	return s.staticC, cancel, nil
+}
+
+// threadedRefreshRegistrySubscription periodically reselects the workers of a
+// subscription of the renter's user. Workers which are no longer selected are
+// unsubscribed from the entry and newly selected workers are subscribed to it.
+// The subscriptions of the workers which are kept are marked as used to
+// prevent them from being evicted, or to subscribe to the entry again if they
+// already were. Once done is closed, the thread releases the subscriptions of
+// all of its workers.
+func (r *Renter) threadedRefreshRegistrySubscription(spk types.SiaPublicKey, tweak crypto.Hash, workers []*worker, done <-chan struct{}) {
+	sid := modules.DeriveRegistryEntryID(spk, tweak)
+	defer func() {
+		for _, w := range workers {
+			w.managedUnsubscribe(sid)
+		}
+	}()
+
+	ticker := time.NewTicker(registrySubscriptionRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-r.tg.StopChan():
+			return
+		case <-ticker.C:
+		}
+
+		current := make(map[string]*worker, len(workers))
+		for _, w := range workers {
+			current[w.staticHostPubKeyStr] = w
+		}
+		selected := r.managedRegistrySubscriptionWorkers(sid, current)
+		if len(selected) == 0 {
+			// Keep the current workers. They keep trying to resubscribe
+			// and we might find better ones next time.
+			for _, w := range workers {
+				w.staticSubscriptionInfo.managedMarkUsed(sid)
+			}
+			continue
+		}
+		for _, w := range selected {
+			if _, exists := current[w.staticHostPubKeyStr]; exists {
+				delete(current, w.staticHostPubKeyStr)
+				w.staticSubscriptionInfo.managedMarkUsed(sid)
+				continue
+			}
+			w.managedSubscribe(spk, tweak)
+		}
+		for _, w := range current {
+			w.managedUnsubscribe(sid)
+		}
+		workers = selected
+	}
+}
+
+// managedRegistrySubscriptionWorkers returns the workers that should be used
+// to subscribe to the entry with the given id. The workers in current, keyed
+// by their host's public key, are treated like subscribed workers as long as
+// their subscription loop isn't on a cooldown. That way a subscription which
+// is still being established isn't moved to another worker.
+func (r *Renter) managedRegistrySubscriptionWorkers(sid modules.RegistryEntryID, current map[string]*worker) []*worker {
+	var candidates []registrySubscriptionCandidate
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		if !w.staticCache().staticGoodForSubscription {
+			continue
+		}
+		if w.staticPriceTable().staticClockSkewed() {
+			continue
+		}
+		subscribed := w.managedIsSubscribed(sid)
+		if _, exists := current[w.staticHostPubKeyStr]; exists && !subscribed {
+			_, subscriptionCooldown := w.staticSubscriptionInfo.managedOnCooldown()
+			subscribed = !subscriptionCooldown
+		}
+		candidates = append(candidates, registrySubscriptionCandidate{
+			w:          w,
+			subscribed: subscribed,
+			cooldown:   w.managedOnMaintenanceCooldown(),
+			stale:      w.staticJobReadRegistryQueue.callStaleResponses(),
+		})
+	}
+	return selectRegistrySubscriptionWorkers(candidates)
+}
+
+// selectRegistrySubscriptionWorkers picks up to registrySubscriptionNumWorkers
+// workers from the candidates. Workers which are already subscribed to the
+// entry are preferred, followed by workers which aren't on a maintenance
+// cooldown and workers whose hosts returned fewer stale registry values.
+func selectRegistrySubscriptionWorkers(candidates []registrySubscriptionCandidate) []*worker {
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].subscribed != candidates[j].subscribed {
+			return candidates[i].subscribed
+		}
+		if candidates[i].cooldown != candidates[j].cooldown {
+			return !candidates[i].cooldown
+		}
+		return candidates[i].stale < candidates[j].stale
+	})
+	if len(candidates) > registrySubscriptionNumWorkers {
+		candidates = candidates[:registrySubscriptionNumWorkers]
+	}
+	workers := make([]*worker, 0, len(candidates))
+	for _, c := range candidates {
+		workers = append(workers, c.w)
+	}
+	return workers
+}
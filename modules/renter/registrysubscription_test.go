@@ -0,0 +1,365 @@
+package renter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestRegistrySubscribers is a unit test for the registrySubscribers. It
+// simulates two workers which deliver conflicting revisions of the same entry.
+func TestRegistrySubscribers(t *testing.T) {
+	t.Parallel()
+
+	rs := newRegistrySubscribers()
+	rv, spk, sk := randomRegistryValue()
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+
+	// revision returns a signed copy of rv with the given revision.
+	revision := func(rev uint64) modules.SignedRegistryValue {
+		rv.Revision = rev
+		return rv.Sign(sk)
+	}
+	// assertNext asserts that the next update has the given revision.
+	assertNext := func(s *registrySubscriber, rev uint64) {
+		t.Helper()
+		select {
+		case update := <-s.staticC:
+			if update.Revision != rev {
+				t.Fatalf("expected revision %v but got %v", rev, update.Revision)
+			}
+		default:
+			t.Fatalf("expected update with revision %v", rev)
+		}
+	}
+	// assertEmpty asserts that no update is pending.
+	assertEmpty := func(s *registrySubscriber) {
+		t.Helper()
+		select {
+		case update := <-s.staticC:
+			t.Fatalf("unexpected update with revision %v", update.Revision)
+		default:
+		}
+	}
+
+	// Notifying without subscribers is a no-op.
+	rs.callNotify(sid, revision(1))
+
+	// Add two subscribers.
+	s1 := rs.callAdd(sid)
+	s2 := rs.callAdd(sid)
+
+	// The first worker delivers revision 2 and the second one the outdated
+	// revision 1. Only revision 2 is delivered.
+	rs.callNotify(sid, revision(2))
+	rs.callNotify(sid, revision(1))
+	assertNext(s1, 2)
+	assertNext(s2, 2)
+	assertEmpty(s1)
+	assertEmpty(s2)
+
+	// Both workers deliver revision 3. It is only delivered once.
+	rs.callNotify(sid, revision(3))
+	rs.callNotify(sid, revision(3))
+	assertNext(s1, 3)
+	assertNext(s2, 3)
+	assertEmpty(s1)
+	assertEmpty(s2)
+
+	// Remove the second subscriber. Its channel should be closed and it
+	// shouldn't receive any more updates.
+	rs.callRemove(sid, s2)
+	if _, ok := <-s2.staticC; ok {
+		t.Fatal("channel should be closed")
+	}
+	rs.callRemove(sid, s2)
+	rs.callNotify(sid, revision(4))
+	assertNext(s1, 4)
+
+	// Fill the buffer of the remaining subscriber without consuming the
+	// updates. Notifying it shouldn't block and the oldest updates should be
+	// dropped.
+	for rev := uint64(5); rev < 5+2*registrySubscriberBufferSize; rev++ {
+		rs.callNotify(sid, revision(rev))
+	}
+	for rev := uint64(5 + registrySubscriberBufferSize); rev < 5+2*registrySubscriberBufferSize; rev++ {
+		assertNext(s1, rev)
+	}
+	assertEmpty(s1)
+
+	// Remove the last subscriber. The entry should be gone.
+	rs.callRemove(sid, s1)
+	if len(rs.subscribers) != 0 {
+		t.Fatal("subscribers should be empty", len(rs.subscribers))
+	}
+
+	// Add a subscriber and close all of them. Its channel should be closed
+	// and removing it afterwards is a no-op.
+	s3 := rs.callAdd(sid)
+	rs.callCloseAll()
+	if _, ok := <-s3.staticC; ok {
+		t.Fatal("channel should be closed")
+	}
+	if len(rs.subscribers) != 0 {
+		t.Fatal("subscribers should be empty", len(rs.subscribers))
+	}
+	rs.callRemove(sid, s3)
+
+	// Subscribers added after closing are closed right away.
+	s4 := rs.callAdd(sid)
+	if _, ok := <-s4.staticC; ok {
+		t.Fatal("channel should be closed")
+	}
+}
+
+// TestRegistrySubscribersWithWorker checks that the updates a worker receives
+// for its subscriptions are delivered to the renter's subscribers.
+func TestRegistrySubscribersWithWorker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// nextUpdate waits for the next update of the subscriber.
+	nextUpdate := func(s *registrySubscriber) modules.SignedRegistryValue {
+		t.Helper()
+		select {
+		case update := <-s.staticC:
+			return update
+		case <-time.After(10 * time.Second):
+			t.Fatal("no update received")
+		}
+		return modules.SignedRegistryValue{}
+	}
+
+	// Set a random entry on the host and subscribe to it.
+	rv, spk, sk := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+	s := r.staticRegistrySubscribers.callAdd(sid)
+	wt.managedSubscribe(spk, rv.Tweak)
+	defer wt.managedUnsubscribe(sid)
+
+	// The initial value should be delivered.
+	if update := nextUpdate(s); !reflect.DeepEqual(update, rv) {
+		t.Fatal("wrong initial value")
+	}
+
+	// Update the entry. The update should be delivered as well.
+	rv.Revision++
+	rv = rv.Sign(sk)
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update := nextUpdate(s); !reflect.DeepEqual(update, rv) {
+		t.Fatal("wrong updated value")
+	}
+	r.staticRegistrySubscribers.callRemove(sid, s)
+}
+
+// TestSelectRegistrySubscriptionWorkers is a unit test for
+// selectRegistrySubscriptionWorkers.
+func TestSelectRegistrySubscriptionWorkers(t *testing.T) {
+	t.Parallel()
+
+	workers := make([]*worker, 5)
+	for i := range workers {
+		workers[i] = new(worker)
+	}
+	candidates := []registrySubscriptionCandidate{
+		{w: workers[0], cooldown: true},
+		{w: workers[1], stale: 5},
+		{w: workers[2], subscribed: true, cooldown: true, stale: 10},
+		{w: workers[3], stale: 1},
+		{w: workers[4], stale: 1},
+	}
+
+	// The subscribed worker is preferred, followed by the workers without
+	// cooldown with the fewest stale responses.
+	selected := selectRegistrySubscriptionWorkers(candidates)
+	if !reflect.DeepEqual(selected, []*worker{workers[2], workers[3], workers[4]}) {
+		t.Fatal("wrong selection")
+	}
+
+	// If there are fewer candidates than needed, all of them are returned.
+	selected = selectRegistrySubscriptionWorkers([]registrySubscriptionCandidate{
+		{w: workers[0], cooldown: true},
+		{w: workers[1], stale: 5},
+	})
+	if !reflect.DeepEqual(selected, []*worker{workers[1], workers[0]}) {
+		t.Fatal("wrong selection")
+	}
+	if selected := selectRegistrySubscriptionWorkers(nil); len(selected) != 0 {
+		t.Fatal("expected no workers", len(selected))
+	}
+}
+
+// TestSubscribeRegistryNoWorkers checks that subscribing fails if there are no
+// workers.
+func TestSubscribeRegistryNoWorkers(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	rv, spk, _ := randomRegistryValue()
+	_, _, err = rt.renter.SubscribeRegistry(spk, rv.Tweak)
+	if !errors.Contains(err, errNoRegistrySubscriptionWorkers) {
+		t.Fatal("unexpected error", err)
+	}
+}
+
+// TestSubscribeRegistry checks that subscribing to a registry entry using the
+// renter delivers the updates of the entry and that cancelling the
+// subscription unsubscribes the workers.
+func TestSubscribeRegistry(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// Set a random entry on the host and subscribe to it.
+	rv, spk, sk := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+	updates, cancel, err := r.SubscribeRegistry(spk, rv.Tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nextUpdate waits for the next update.
+	nextUpdate := func() modules.SignedRegistryValue {
+		t.Helper()
+		select {
+		case update := <-updates:
+			return update
+		case <-time.After(10 * time.Second):
+			t.Fatal("no update received")
+		}
+		return modules.SignedRegistryValue{}
+	}
+
+	// The initial value should be delivered and the worker should be
+	// subscribed.
+	if update := nextUpdate(); !reflect.DeepEqual(update, rv) {
+		t.Fatal("wrong initial value")
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if !wt.managedIsSubscribed(sid) {
+			return errors.New("worker not subscribed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the entry. The update should be delivered as well.
+	rv.Revision++
+	rv = rv.Sign(sk)
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update := nextUpdate(); !reflect.DeepEqual(update, rv) {
+		t.Fatal("wrong updated value")
+	}
+
+	// Evict the worker's subscription. The subscription is refreshed in the
+	// background which should restore it.
+	wt.staticSubscriptionInfo.mu.Lock()
+	wt.staticSubscriptionInfo.subscriptions[sid].evicted = true
+	wt.staticSubscriptionInfo.mu.Unlock()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		wt.staticSubscriptionInfo.mu.Lock()
+		defer wt.staticSubscriptionInfo.mu.Unlock()
+		if wt.staticSubscriptionInfo.subscriptions[sid].evicted {
+			return errors.New("subscription still evicted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cancel the subscription. The channel should be closed and the worker
+	// shouldn't reference the subscription anymore. Cancelling twice is a
+	// no-op.
+	cancel()
+	cancel()
+	select {
+	case _, ok := <-updates:
+		if ok {
+			t.Fatal("channel should be closed")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("channel wasn't closed")
+	}
+	subInfo := wt.staticSubscriptionInfo
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		subInfo.mu.Lock()
+		defer subInfo.mu.Unlock()
+		sub, exists := subInfo.subscriptions[sid]
+		if exists && sub.refs != 0 {
+			return fmt.Errorf("subscription is still referenced %v", sub.refs)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.staticRegistrySubscribers.mu.Lock()
+	numSubscribers := len(r.staticRegistrySubscribers.subscribers)
+	r.staticRegistrySubscribers.mu.Unlock()
+	if numSubscribers != 0 {
+		t.Fatal("renter still has subscribers", numSubscribers)
+	}
+}
@@ -0,0 +1,90 @@
+package renter
+
+import (
+	"sync"
+
+	"go.sia.tech/siad/modules"
+)
+
+type (
+	// registrySubscriptionCache caches the latest registry values which the
+	// workers received through their registry subscriptions. As long as at
+	// least one worker is actively subscribed to an entry, the host notifies
+	// the worker about updates to it which keeps the cached value fresh
+	// enough to serve registry reads without contacting any hosts.
+	registrySubscriptionCache struct {
+		entries map[modules.RegistryEntryID]*subscribedRegistryValue
+		mu      sync.Mutex
+	}
+
+	// subscribedRegistryValue is a cached registry value together with the
+	// workers that are actively subscribed to it.
+	subscribedRegistryValue struct {
+		rv modules.SignedRegistryValue
+
+		// subscribers contains the host keys of the workers which are
+		// subscribed to the entry.
+		subscribers map[string]struct{}
+	}
+)
+
+// newRegistrySubscriptionCache creates a new registry subscription cache.
+func newRegistrySubscriptionCache() *registrySubscriptionCache {
+	return &registrySubscriptionCache{
+		entries: make(map[modules.RegistryEntryID]*subscribedRegistryValue),
+	}
+}
+
+// callGet returns the cached value of the entry with the given id if a worker
+// is subscribed to it.
+func (c *registrySubscriptionCache) callGet(sid modules.RegistryEntryID) (modules.SignedRegistryValue, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, exists := c.entries[sid]
+	if !exists {
+		return modules.SignedRegistryValue{}, false
+	}
+	return entry.rv, true
+}
+
+// callRemoveSubscriber removes the worker for the host with the given key from
+// the subscribers of the entry with the given id. The cached value is
+// invalidated once no worker is subscribed to the entry anymore.
+func (c *registrySubscriptionCache) callRemoveSubscriber(hostKey string, sid modules.RegistryEntryID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, exists := c.entries[sid]
+	if !exists {
+		return
+	}
+	delete(entry.subscribers, hostKey)
+	if len(entry.subscribers) == 0 {
+		delete(c.entries, sid)
+	}
+}
+
+// callUpdate adds the worker for the host with the given key to the
+// subscribers of the entry with the given id and updates the cached value.
+// Values with a lower revision than the cached one never replace the cached
+// value. The value should already be verified by the caller.
+func (c *registrySubscriptionCache) callUpdate(hostKey string, sid modules.RegistryEntryID, rv modules.SignedRegistryValue) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, exists := c.entries[sid]
+	if !exists {
+		c.entries[sid] = &subscribedRegistryValue{
+			rv: rv,
+			subscribers: map[string]struct{}{
+				hostKey: {},
+			},
+		}
+		return
+	}
+	entry.subscribers[hostKey] = struct{}{}
+
+	// Only replace the cached value with a higher revision or the same
+	// revision with more work.
+	if rv.Revision > entry.rv.Revision || (rv.Revision == entry.rv.Revision && rv.HasMoreWork(entry.rv.RegistryValue)) {
+		entry.rv = rv
+	}
+}
@@ -0,0 +1,69 @@
+package renter
+
+import (
+	"reflect"
+	"testing"
+
+	"go.sia.tech/siad/modules"
+)
+
+// TestRegistrySubscriptionCache is a unit test for the
+// registrySubscriptionCache.
+func TestRegistrySubscriptionCache(t *testing.T) {
+	t.Parallel()
+
+	c := newRegistrySubscriptionCache()
+	rv, spk, sk := randomRegistryValue()
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+
+	// The cache should be empty.
+	if _, cached := c.callGet(sid); cached {
+		t.Fatal("entry shouldn't be cached")
+	}
+
+	// Add the value for two subscribers.
+	c.callUpdate("host1", sid, rv)
+	c.callUpdate("host2", sid, rv)
+	cachedRV, cached := c.callGet(sid)
+	if !cached || !reflect.DeepEqual(cachedRV, rv) {
+		t.Fatal("wrong cached value", cached)
+	}
+
+	// Update the value with a higher revision.
+	rv2 := rv
+	rv2.Revision++
+	rv2 = rv2.Sign(sk)
+	c.callUpdate("host1", sid, rv2)
+	cachedRV, cached = c.callGet(sid)
+	if !cached || !reflect.DeepEqual(cachedRV, rv2) {
+		t.Fatal("wrong cached value", cached)
+	}
+
+	// An older revision shouldn't overwrite the newer one.
+	c.callUpdate("host2", sid, rv)
+	cachedRV, cached = c.callGet(sid)
+	if !cached || !reflect.DeepEqual(cachedRV, rv2) {
+		t.Fatal("wrong cached value", cached)
+	}
+
+	// Removing one subscriber shouldn't invalidate the value.
+	c.callRemoveSubscriber("host1", sid)
+	if _, cached = c.callGet(sid); !cached {
+		t.Fatal("entry should still be cached")
+	}
+
+	// Removing an unknown subscriber is a no-op.
+	c.callRemoveSubscriber("host3", sid)
+	if _, cached = c.callGet(sid); !cached {
+		t.Fatal("entry should still be cached")
+	}
+
+	// Removing the last one should.
+	c.callRemoveSubscriber("host2", sid)
+	if _, cached = c.callGet(sid); cached {
+		t.Fatal("entry shouldn't be cached")
+	}
+	if len(c.entries) != 0 {
+		t.Fatal("cache should be empty", len(c.entries))
+	}
+}
@@ -26,8 +26,8 @@ package renter
 import (
 	"fmt"
 	"io"
+	"math"
 	"net"
-	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -45,6 +45,7 @@ import (
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/contractor"
 	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/modules/renter/hostdb"
 	"go.sia.tech/siad/persist"
 	siasync "go.sia.tech/siad/sync"
@@ -198,11 +199,20 @@ type Renter struct {
 	downloadHistory   map[modules.DownloadID]*download
 	downloadHistoryMu sync.Mutex
 
+	// downloadGroups contains the named groups of downloads which are tracked
+	// together. Cleared downloads are removed from their groups.
+	downloadGroups   map[string]*downloadGroup
+	downloadGroupsMu sync.Mutex
+
 	// Upload management.
 	uploadHeap    uploadHeap
 	directoryHeap directoryHeap
 	stuckStack    stuckStack
 
+	// staticStuckCursor remembers the stuck loop's recent stuck repair
+	// attempts across restarts.
+	staticStuckCursor *stuckCursor
+
 	// Cache the hosts from the last price estimation result.
 	lastEstimationHosts []modules.HostDBEntry
 
@@ -218,6 +228,10 @@ type Renter struct {
 	// The renter's bandwidth ratelimit.
 	rl *ratelimit.RateLimit
 
+	// staticRepairRL is the ratelimit for repair traffic. It applies on top
+	// of the renter's bandwidth ratelimit.
+	staticRepairRL *ratelimit.RateLimit
+
 	// stats cache related fields.
 	statsChan chan struct{}
 	statsMu   sync.Mutex
@@ -225,6 +239,58 @@ type Renter struct {
 	// read registry stats
 	staticRRS *readRegistryStats
 
+	// staticRegistrySubscriptionCache caches the registry values the workers
+	// are subscribed to.
+	staticRegistrySubscriptionCache *registrySubscriptionCache
+
+	// staticRegistrySubscribers are the subscribers of the renter which are
+	// notified about updates to registry entries.
+	staticRegistrySubscribers *registrySubscribers
+
+	// staticMetadataBatcher batches the metadata writes of pieces added to
+	// siafiles.
+	staticMetadataBatcher *siafile.MetadataBatcher
+
+	// repair stats
+	staticRepairStats *repairStats
+
+	// staticBandwidthStats tracks the upload and download bandwidth of the
+	// renter's workers.
+	staticBandwidthStats *bandwidthStats
+
+	// staticRepairMetrics tracks the progress of the repair pipeline.
+	staticRepairMetrics *repairMetrics
+
+	// staticRepairLimiter limits the number of concurrent
+	// threadedFetchAndRepairChunk goroutines.
+	staticRepairLimiter *repairLimiter
+
+	// staticDiskLatency tracks the latency of writes to the renter's persist
+	// directory.
+	staticDiskLatency *diskLatencyTracker
+
+	// staticRepairLoopState records the phase of the repair loop.
+	staticRepairLoopState *repairLoopState
+
+	// staticUnfinishedChunkCache caches the metadata of recently built
+	// unfinished chunks.
+	staticUnfinishedChunkCache *unfinishedChunkCache
+
+	// staticUploadStaging manages the staged copies of upload sources.
+	staticUploadStaging *uploadStaging
+
+	// conversionMu serializes the creation, completion and removal of
+	// erasure code conversions. It also protects conversionHealthyChunks
+	// which tracks the indices of the chunks of each conversion siafile that
+	// don't need to be repaired anymore.
+	conversionMu            sync.Mutex
+	conversionHealthyChunks map[siafile.SiafileUID]map[uint64]struct{}
+
+	// directoryHeapMu is held by the repair loop while it adds chunks from the
+	// directory heap to the upload heap. It prevents manual resets of the
+	// directory heap from interfering with that.
+	directoryHeapMu sync.Mutex
+
 	// Memory management
 	//
 	// registryMemoryManager is used for updating registry entries and reading
@@ -257,6 +323,7 @@ type Renter struct {
 	staticAlerter                      *modules.GenericAlerter
 	staticFileSystem                   *filesystem.FileSystem
 	staticFuseManager                  renterFuseManager
+	staticSyncStatus                   *syncStatusTracker
 	staticStreamBufferSet              *streamBufferSet
 	tg                                 threadgroup.ThreadGroup
 	tpool                              modules.TransactionPool
@@ -277,6 +344,36 @@ func (r *Renter) Close() error {
 	return errors.Compose(r.tg.Stop(), r.hostDB.Close(), r.hostContractor.Close())
 }
 
+// AvgRepairRate returns the average number of bytes per second the renter
+// recently repaired.
+func (r *Renter) AvgRepairRate() (uint64, error) {
+	if err := r.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer r.tg.Done()
+	return r.staticRepairStats.callAvgRepairRate(), nil
+}
+
+// UploadStagingStatus returns the status of the renter's upload staging
+// directory.
+func (r *Renter) UploadStagingStatus() (modules.UploadStagingStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.UploadStagingStatus{}, err
+	}
+	defer r.tg.Done()
+	return r.staticUploadStaging.callStatus(), nil
+}
+
+// MetadataBatchStats returns information about the batching of siafile
+// metadata writes.
+func (r *Renter) MetadataBatchStats() (modules.MetadataBatchStats, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.MetadataBatchStats{}, err
+	}
+	defer r.tg.Done()
+	return r.staticMetadataBatcher.Stats(), nil
+}
+
 // MemoryStatus returns the current status of the memory manager
 func (r *Renter) MemoryStatus() (modules.MemoryStatus, error) {
 	if err := r.tg.Add(); err != nil {
@@ -573,6 +670,12 @@ func (r *Renter) managedUpdateRenterContractsAndUtilities() {
 // setBandwidthLimits will change the bandwidth limits of the renter based on
 // the persist values for the bandwidth.
 func (r *Renter) setBandwidthLimits(downloadSpeed int64, uploadSpeed int64) error {
+	return setRateLimits(r.rl, downloadSpeed, uploadSpeed)
+}
+
+// setRateLimits sets the limits of the provided ratelimit. A limit of 0 means
+// that the bandwidth isn't limited.
+func setRateLimits(rl *ratelimit.RateLimit, downloadSpeed int64, uploadSpeed int64) error {
 	// Input validation.
 	if downloadSpeed < 0 || uploadSpeed < 0 {
 		return errors.New("download/upload rate limit can't be below 0")
@@ -580,10 +683,10 @@ func (r *Renter) setBandwidthLimits(downloadSpeed int64, uploadSpeed int64) erro
 
 	// Check for sentinel "no limits" value.
 	if downloadSpeed == 0 && uploadSpeed == 0 {
-		r.rl.SetLimits(0, 0, 0)
+		rl.SetLimits(0, 0, 0)
 	} else {
 		// Set the rate limits according to the provided values.
-		r.rl.SetLimits(downloadSpeed, uploadSpeed, 4*4096)
+		rl.SetLimits(downloadSpeed, uploadSpeed, 4*4096)
 	}
 	return nil
 }
@@ -604,6 +707,27 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	if s.MaxDownloadSpeed < 0 || s.MaxUploadSpeed < 0 {
 		return errors.New("bandwidth limits cannot be negative")
 	}
+	if s.MaxRepairDownloadSpeed < 0 || s.MaxRepairUploadSpeed < 0 {
+		return errors.New("repair bandwidth limits cannot be negative")
+	}
+	if s.MaxRepairAttempts > math.MaxUint8 {
+		return fmt.Errorf("max repair attempts cannot be greater than %v", math.MaxUint8)
+	}
+	if s.MaxRepairAttempts == 0 {
+		s.MaxRepairAttempts = DefaultMaxRepairAttempts
+	}
+	if s.MaxStuckRepairAttempts > math.MaxUint8 {
+		return fmt.Errorf("max stuck repair attempts cannot be greater than %v", math.MaxUint8)
+	}
+	if s.MaxStuckRepairAttempts == 0 {
+		s.MaxStuckRepairAttempts = DefaultMaxStuckRepairAttempts
+	}
+	if s.MetadataBatchWindow < 0 {
+		return errors.New("metadata batch window cannot be negative")
+	}
+	if s.MetadataBatchWindow == 0 {
+		s.MetadataBatchWindow = DefaultMetadataBatchWindow
+	}
 
 	// Set allowance.
 	err := r.hostContractor.SetAllowance(s.Allowance)
@@ -619,16 +743,44 @@ func (r *Renter) SetSettings(s modules.RenterSettings) error {
 	if err != nil {
 		return err
 	}
+	err = setRateLimits(r.staticRepairRL, s.MaxRepairDownloadSpeed, s.MaxRepairUploadSpeed)
+	if err != nil {
+		return err
+	}
+
+	// Set the upload staging size.
+	r.staticUploadStaging.callSetCapacity(s.UploadStagingSize)
 
 	// Save the changes.
 	id := r.mu.Lock()
 	r.persist.MaxDownloadSpeed = s.MaxDownloadSpeed
 	r.persist.MaxUploadSpeed = s.MaxUploadSpeed
+	r.persist.UploadStagingSize = s.UploadStagingSize
+	r.persist.MaxRepairAttempts = s.MaxRepairAttempts
+	r.persist.MaxStuckRepairAttempts = s.MaxStuckRepairAttempts
+	r.persist.MetadataBatchWindow = s.MetadataBatchWindow
+	r.persist.DisableMetadataBatching = s.DisableMetadataBatching
+	r.persist.SkipUnavailableLocalFiles = s.SkipUnavailableLocalFiles
+	r.persist.MaxConcurrentRepairs = s.MaxConcurrentRepairs
+	r.persist.DiskLatencyThrottle = s.DiskLatencyThrottle
+	r.persist.MaxSubscriptionsPerWorker = s.MaxSubscriptionsPerWorker
+	r.persist.MaxRepairDownloadSpeed = s.MaxRepairDownloadSpeed
+	r.persist.MaxRepairUploadSpeed = s.MaxRepairUploadSpeed
+	r.staticMetadataBatcher.SetWindow(r.persist.metadataBatchWindow())
 	err = r.saveSync()
 	r.mu.Unlock(id)
 	if err != nil {
 		return err
 	}
+	// Wake up chunks waiting for the repair limiter in case the limit was
+	// raised.
+	r.staticRepairLimiter.callWake()
+
+	// Apply the subscription limit to the workers.
+	maxSubscriptions := r.managedMaxSubscriptionsPerWorker()
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		w.staticSubscriptionInfo.managedSetMaxSubscriptions(maxSubscriptions)
+	}
 
 	// Update the worker pool so that the changes are immediately apparent to
 	// users.
@@ -656,17 +808,8 @@ func (r *Renter) SetFileTrackingPath(siaPath modules.SiaPath, newPath string) (e
 		err = errors.Compose(err, entry.Close())
 	}()
 
-	// Sanity check that a file with the correct size exists at the new
-	// location.
-	fi, err := os.Stat(newPath)
-	if err != nil {
-		return errors.AddContext(err, "failed to get fileinfo of the file")
-	}
-	if uint64(fi.Size()) != entry.Size() {
-		return fmt.Errorf("file sizes don't match - want %v but got %v", entry.Size(), fi.Size())
-	}
-
-	// Set the new path on disk.
+	// Set the new path on disk. SetLocalPath checks that a file with the
+	// correct size exists at the new location.
 	return entry.SetLocalPath(newPath)
 }
 
@@ -775,6 +918,9 @@ func (r *Renter) ContractorChurnStatus() modules.ContractorChurnStatus {
 // InitRecoveryScan starts scanning the whole blockchain for recoverable
 // contracts within a separate thread.
 func (r *Renter) InitRecoveryScan() error {
+	if !r.cs.Synced() {
+		return errors.AddContext(modules.ErrRenterNotSynced, "unable to start recovery scan")
+	}
 	return r.hostContractor.InitRecoveryScan()
 }
 
@@ -812,11 +958,20 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 	}
 	defer r.tg.Done()
 	download, upload, _ := r.rl.Limits()
+	repairDownload, repairUpload, _ := r.staticRepairRL.Limits()
 	enabled, err := r.hostDB.IPViolationsCheck()
 	if err != nil {
 		return modules.RenterSettings{}, errors.AddContext(err, "error getting IPViolationsCheck:")
 	}
 	paused, endTime := r.uploadHeap.managedPauseStatus()
+	id := r.mu.RLock()
+	batchWindow := r.persist.MetadataBatchWindow
+	batchingDisabled := r.persist.DisableMetadataBatching
+	skipUnavailableLocal := r.persist.SkipUnavailableLocalFiles
+	maxConcurrentRepairs := r.persist.MaxConcurrentRepairs
+	diskLatencyThrottle := r.persist.DiskLatencyThrottle
+	maxSubscriptions := r.persist.MaxSubscriptionsPerWorker
+	r.mu.RUnlock(id)
 	return modules.RenterSettings{
 		Allowance:        r.hostContractor.Allowance(),
 		IPViolationCheck: enabled,
@@ -826,9 +981,60 @@ func (r *Renter) Settings() (modules.RenterSettings, error) {
 			Paused:       paused,
 			PauseEndTime: endTime,
 		},
+		UploadStagingSize: r.staticUploadStaging.callStatus().Capacity,
+		MaxRepairAttempts: uint64(r.managedMaxRepairAttempts()),
+
+		MaxStuckRepairAttempts: uint64(r.managedMaxStuckRepairAttempts()),
+
+		MetadataBatchWindow:     batchWindow,
+		DisableMetadataBatching: batchingDisabled,
+
+		SkipUnavailableLocalFiles: skipUnavailableLocal,
+		MaxConcurrentRepairs:      maxConcurrentRepairs,
+		DiskLatencyThrottle:       diskLatencyThrottle,
+		MaxSubscriptionsPerWorker: maxSubscriptions,
+
+		MaxRepairDownloadSpeed: repairDownload,
+		MaxRepairUploadSpeed:   repairUpload,
 	}, nil
 }
 
+// managedMaxRepairAttempts returns the number of consecutive failed repair
+// attempts after which a chunk is marked as stuck.
+func (r *Renter) managedMaxRepairAttempts() int {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return int(r.persist.MaxRepairAttempts)
+}
+
+// managedMaxStuckRepairAttempts returns the number of consecutive failed
+// repairs by the stuck loop after which a chunk is abandoned.
+func (r *Renter) managedMaxStuckRepairAttempts() uint8 {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return uint8(r.persist.MaxStuckRepairAttempts)
+}
+
+// managedMaxSubscriptionsPerWorker returns the maximum number of registry
+// entries a worker keeps active subscriptions for.
+func (r *Renter) managedMaxSubscriptionsPerWorker() int {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	if r.persist.MaxSubscriptionsPerWorker == 0 {
+		return DefaultMaxSubscriptionsPerWorker
+	}
+	return int(r.persist.MaxSubscriptionsPerWorker)
+}
+
+// managedSkipUnavailableLocalFiles returns whether chunks that can't be repaired
+// due to their source file being unavailable should be skipped when building
+// the upload heap.
+func (r *Renter) managedSkipUnavailableLocalFiles() bool {
+	id := r.mu.RLock()
+	defer r.mu.RUnlock(id)
+	return r.persist.SkipUnavailableLocalFiles
+}
+
 // ProcessConsensusChange returns the process consensus change
 func (r *Renter) ProcessConsensusChange(cc modules.ConsensusChange) {
 	id := r.mu.Lock()
@@ -836,6 +1042,8 @@ func (r *Renter) ProcessConsensusChange(cc modules.ConsensusChange) {
 	r.mu.Unlock(id)
 	if cc.Synced {
 		_ = r.tg.Launch(r.staticWorkerPool.callUpdate)
+	} else {
+		r.staticSyncStatus.callAddSample(cc.BlockHeight, time.Now())
 	}
 }
 
@@ -898,7 +1106,10 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 			repairingChunks:   make(map[uploadChunkID]*unfinishedUploadChunk),
 			stuckHeapChunks:   make(map[uploadChunkID]*unfinishedUploadChunk),
 			unstuckHeapChunks: make(map[uploadChunkID]*unfinishedUploadChunk),
+			offlineUploads:    make(map[modules.SiaPath]struct{}),
+			failedPushes:      make(map[pushFailureReason]uint64),
 
+			backupNeeded:      make(chan struct{}, 1),
 			newUploads:        make(chan struct{}, 1),
 			repairNeeded:      make(chan struct{}, 1),
 			stuckChunkFound:   make(chan struct{}, 1),
@@ -908,9 +1119,13 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		},
 		directoryHeap: directoryHeap{
 			heapDirectories: make(map[modules.SiaPath]*directory),
+			popCounts:       make(map[modules.SiaPath]int),
 		},
 
 		downloadHistory: make(map[modules.DownloadID]*download),
+		downloadGroups:  make(map[string]*downloadGroup),
+
+		conversionHealthyChunks: make(map[siafile.SiafileUID]map[uint64]struct{}),
 
 		cs:             cs,
 		deps:           deps,
@@ -920,6 +1135,7 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		hostContractor: hc,
 		persistDir:     persistDir,
 		rl:             rl,
+		staticRepairRL: ratelimit.NewRateLimit(0, 0, 0),
 		staticAlerter:  modules.NewAlerter("renter"),
 		staticMux:      mux,
 		mu:             siasync.New(modules.SafeMutexDelay, 1),
@@ -929,6 +1145,15 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	r.staticStreamBufferSet = newStreamBufferSet(&r.tg)
 	r.staticUploadChunkDistributionQueue = newUploadChunkDistributionQueue(r)
 	r.staticRRS = newReadRegistryStats(ReadRegistryBackgroundTimeout, readRegistryStatsInterval, readRegistryStatsDecay, readRegistryStatsPercentile)
+	r.staticRegistrySubscriptionCache = newRegistrySubscriptionCache()
+	r.staticRegistrySubscribers = newRegistrySubscribers()
+	r.staticRepairStats = newRepairStats(repairStatsDecay)
+	r.staticBandwidthStats = newBandwidthStats()
+	r.staticRepairMetrics = newRepairMetrics()
+	r.staticRepairLimiter = newRepairLimiter()
+	r.staticDiskLatency = newDiskLatencyTracker()
+	r.staticRepairLoopState = newRepairLoopState()
+	r.staticUnfinishedChunkCache = newUnfinishedChunkCache(unfinishedChunkCacheSize, workerCacheUpdateFrequency)
 	close(r.uploadHeap.pauseChan)
 
 	// Seed the rrs.
@@ -958,6 +1183,7 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	if err := r.tg.AfterStop(r.repairLog.Close); err != nil {
 		return nil, err
 	}
+	r.staticMetadataBatcher = siafile.NewMetadataBatcher(DefaultMetadataBatchWindow, r.log)
 
 	// Initialize some of the components.
 	err = r.newAccountManager()
@@ -971,6 +1197,7 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	r.repairMemoryManager = newMemoryManager(repairMemoryDefault, repairMemoryPriorityDefault, r.tg.StopChan())
 
 	r.staticFuseManager = newFuseManager(r)
+	r.staticSyncStatus = newSyncStatusTracker()
 	r.stuckStack = callNewStuckStack()
 
 	// Load all saved data.
@@ -979,6 +1206,15 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 		return nil, err
 	}
 
+	// Load the stuck cursor and persist it on shutdown.
+	r.staticStuckCursor, err = newStuckCursor(filepath.Join(r.persistDir, stuckCursorFilename))
+	if err != nil {
+		return nil, err
+	}
+	if err := r.tg.AfterStop(r.staticStuckCursor.callSave); err != nil {
+		return nil, err
+	}
+
 	// After persist is initialized, create the worker pool.
 	r.staticWorkerPool = r.newWorkerPool()
 
@@ -989,12 +1225,15 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	// the utilities regularly.
 	r.managedUpdateRenterContractsAndUtilities()
 	go r.threadedUpdateRenterContractsAndUtilities()
+	go r.threadedUpdateBandwidthStats()
+	go r.threadedUpdateDiskLatency()
+	go r.threadedPruneUploadStaging()
 
 	// Spin up background threads which are not depending on the renter being
 	// up-to-date with consensus.
 	if !r.deps.Disrupt("DisableRepairAndHealthLoops") {
 		// Push the root directory onto the directory heap for the repair process.
-		err = r.managedPushUnexploredDirectory(modules.RootSiaPath())
+		err = r.managedInitDirectoryHeap()
 		if err != nil {
 			return nil, err
 		}
@@ -1013,6 +1252,14 @@ func renterBlockingStartup(g modules.Gateway, cs modules.ConsensusSet, tpool mod
 	if err != nil {
 		return nil, err
 	}
+	// Close the channels of the registry subscribers on shutdown.
+	err = r.tg.OnStop(func() error {
+		r.staticRegistrySubscribers.callCloseAll()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return r, nil
 }
 
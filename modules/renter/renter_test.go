@@ -88,7 +88,7 @@ func (rt *renterTester) addCustomHost(testdir string, deps modules.Dependencies)
 	}
 
 	// announce the host
-	err = h.Announce()
+	err = h.Announce(false)
 	if err != nil {
 		return nil, build.ExtendErr("error announcing host", err)
 	}
@@ -381,3 +381,83 @@ func TestRenterPricesDivideByZero(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestMetadataBatchSettings verifies that the metadata batching settings are
+// applied to the batcher and persisted.
+func TestMetadataBatchSettings(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Check the defaults.
+	settings, err := r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.MetadataBatchWindow != DefaultMetadataBatchWindow || settings.DisableMetadataBatching {
+		t.Fatal("unexpected defaults", settings.MetadataBatchWindow, settings.DisableMetadataBatching)
+	}
+	stats, err := r.MetadataBatchStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Window != DefaultMetadataBatchWindow {
+		t.Fatal("unexpected window", stats.Window)
+	}
+
+	// A negative window is invalid.
+	settings.MetadataBatchWindow = -time.Second
+	if err := r.SetSettings(settings); err == nil {
+		t.Fatal("expected error")
+	}
+
+	// Update the window and disable batching.
+	settings.MetadataBatchWindow = 100 * time.Millisecond
+	settings.DisableMetadataBatching = true
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = r.MetadataBatchStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Window != 0 {
+		t.Fatal("batching should be disabled", stats.Window)
+	}
+
+	// Reload the renter and enable batching again. The window should have been
+	// persisted.
+	r, err = rt.reloadRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings, err = r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.MetadataBatchWindow != 100*time.Millisecond || !settings.DisableMetadataBatching {
+		t.Fatal("settings weren't persisted", settings.MetadataBatchWindow, settings.DisableMetadataBatching)
+	}
+	settings.DisableMetadataBatching = false
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	stats, err = r.MetadataBatchStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Window != 100*time.Millisecond {
+		t.Fatal("unexpected window", stats.Window)
+	}
+}
@@ -130,11 +130,11 @@ func (r *Renter) managedAddStuckChunksToHeap(siaPath modules.SiaPath, hosts map[
 
 	// Build unfinished stuck chunks
 	var allErrors error
-	unfinishedStuckChunks := r.managedBuildUnfinishedChunks(sf, hosts, targetStuckChunks, offline, goodForRenew, r.repairMemoryManager)
+	unfinishedStuckChunks := r.managedBuildUnfinishedChunks(sf, hosts, targetStuckChunks, offline, goodForRenew, r.repairMemoryManager, r.managedSkipUnavailableLocalFiles())
 	defer func() {
 		// Close out remaining file entries
 		for _, chunk := range unfinishedStuckChunks {
-			allErrors = errors.Compose(allErrors, chunk.fileEntry.Close())
+			allErrors = errors.Compose(allErrors, r.managedCloseEntry(chunk))
 		}
 	}()
 
@@ -146,14 +146,12 @@ func (r *Renter) managedAddStuckChunksToHeap(siaPath modules.SiaPath, hosts map[
 		unfinishedStuckChunks = unfinishedStuckChunks[1:]
 		chunk.stuckRepair = true
 		chunk.fileRecentlySuccessful = true
-		pushed, err := r.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
+		pushed, err := r.managedPushOrClose(chunk)
 		if err != nil {
-			return errors.Compose(allErrors, err, chunk.fileEntry.Close())
+			return errors.Compose(allErrors, err)
 		}
 		if !pushed {
-			// Stuck chunk unable to be added. Close the file entry of that
-			// chunk
-			allErrors = errors.Compose(allErrors, chunk.fileEntry.Close())
+			// Stuck chunk unable to be added.
 			continue
 		}
 		stuckChunksAdded++
@@ -300,14 +298,29 @@ func (r *Renter) managedStuckDirectory() (modules.SiaPath, error) {
 			return siaPath, nil
 		}
 
+		// Weigh the directories by their number of stuck chunks. The stuck
+		// cursor biases the weights towards stuck chunks which weren't
+		// attempted recently. The first element is the current directory which
+		// is weighed by the stuck chunks of its files.
+		weights := make([]uint64, len(directories))
+		weights[0] = r.staticStuckCursor.callDirWeight(directories[0].SiaPath, directories[0].NumStuckChunks, false)
+		totalWeight := weights[0]
+		for i := 1; i < len(directories); i++ {
+			weights[i] = r.staticStuckCursor.callDirWeight(directories[i].SiaPath, directories[i].AggregateNumStuckChunks, true)
+			totalWeight += weights[i]
+		}
+		if totalWeight == 0 {
+			// The metadata of the subdirectories is not in sync with the
+			// current directory.
+			return siaPath, nil
+		}
+
 		// Get random int
-		rand := fastrand.Intn(int(directories[0].AggregateNumStuckChunks))
+		rand := fastrand.Intn(int(totalWeight))
 		// Use rand to decide which directory to go into. Work backwards over
-		// the slice of directories. Since the first element is the current
-		// directory that means that it is the sum of all the files and
-		// directories.  We can chose a directory by subtracting the number of
-		// stuck chunks a directory has from rand and if rand gets to 0 or less
-		// we choose that directory
+		// the slice of directories. We can chose a directory by subtracting
+		// the weight of a directory from rand and if rand gets to 0 or less we
+		// choose that directory
 		for i := len(directories) - 1; i >= 0; i-- {
 			// If we are on the last iteration and the directory does have files
 			// then return the current directory
@@ -317,11 +330,11 @@ func (r *Renter) managedStuckDirectory() (modules.SiaPath, error) {
 			}
 
 			// Skip directories with no stuck chunks
-			if directories[i].AggregateNumStuckChunks == uint64(0) {
+			if weights[i] == 0 {
 				continue
 			}
 
-			rand = rand - int(directories[i].AggregateNumStuckChunks)
+			rand = rand - int(weights[i])
 			siaPath = directories[i].SiaPath
 			// If rand is less than 0 break out of the loop and continue into
 			// that directory
@@ -365,18 +378,18 @@ func (r *Renter) managedStuckFile(dirSiaPath modules.SiaPath) (siapath modules.S
 		return modules.SiaPath{}, err
 	}
 
-	// Use rand to decide which file to select. We can chose a file by
-	// subtracting the number of stuck chunks a file has from rand and if rand
-	// gets to 0 or less we choose that file
-	rand := fastrand.Intn(int(aggregateNumStuckChunks))
-
 	// Read the directory, using ReadDir so we don't read all the siafiles
 	// unless we need to
 	fileinfos, err := r.staticFileSystem.ReadDir(dirSiaPath)
 	if err != nil {
 		return modules.SiaPath{}, errors.AddContext(err, "unable to open siadir: "+dirSiaPath.String())
 	}
-	// Iterate over the fileinfos
+	// Collect the stuck files and their weights. The weight of a file is its
+	// number of stuck chunks biased by the stuck cursor towards files which
+	// weren't attempted recently.
+	var stuckFiles []modules.SiaPath
+	var weights []uint64
+	var totalWeight uint64
 	for _, fi := range fileinfos {
 		// Check for SiaFile
 		if fi.IsDir() || filepath.Ext(fi.Name()) != modules.SiaFileExtension {
@@ -394,7 +407,7 @@ func (r *Renter) managedStuckFile(dirSiaPath modules.SiaPath) (siapath modules.S
 		if err != nil {
 			return modules.SiaPath{}, errors.AddContext(err, "could not open siafileset for "+sp.String())
 		}
-		numStuckChunks := int(f.NumStuckChunks())
+		numStuckChunks := f.NumStuckChunks()
 		if err := f.Close(); err != nil {
 			return modules.SiaPath{}, errors.AddContext(err, "failed to close filenode "+sp.String())
 		}
@@ -403,12 +416,24 @@ func (r *Renter) managedStuckFile(dirSiaPath modules.SiaPath) (siapath modules.S
 		if numStuckChunks == 0 {
 			continue
 		}
+		weight := r.staticStuckCursor.callFileWeight(sp, numStuckChunks)
+		stuckFiles = append(stuckFiles, sp)
+		weights = append(weights, weight)
+		totalWeight += weight
+	}
 
-		// Decrement rand and check if we have decremented fully
-		rand = rand - numStuckChunks
-		siapath = sp
-		if rand < 0 {
-			break
+	// Use rand to decide which file to select. We can chose a file by
+	// subtracting the weight of a file from rand and if rand gets to 0 or less
+	// we choose that file. The random number is drawn from the weights of the
+	// files we just read since the directory's metadata might be outdated.
+	if totalWeight > 0 {
+		rand := fastrand.Intn(int(totalWeight))
+		for i, sp := range stuckFiles {
+			rand = rand - int(weights[i])
+			if rand < 0 {
+				siapath = sp
+				break
+			}
 		}
 	}
 	if siapath.IsEmpty() {
@@ -498,6 +523,12 @@ func (r *Renter) threadedStuckFileLoop() {
 		}
 		dirSiaPaths = append(dirSiaPaths, randomDirSiaPaths...)
 
+		// Persist the stuck cursor to make sure the attempts survive an
+		// unclean shutdown.
+		if err := r.staticStuckCursor.callSave(); err != nil {
+			r.repairLog.Println("WARN: unable to save stuck cursor:", err)
+		}
+
 		// Check if any stuck chunks were added to the upload heap
 		numStuckChunks, _ := r.uploadHeap.managedNumStuckChunks()
 		if numStuckChunks == 0 {
@@ -772,6 +803,34 @@ func (r *Renter) managedUpdateFileMetadatasParams(dirSiaPath modules.SiaPath, of
 	return errs
 }
 
+// managedHostSubnets returns the subnets of the provided hosts according to the
+// hostdb. If the IP violation check is disabled, nil is returned to indicate
+// that the subnets of the hosts don't matter.
+func (r *Renter) managedHostSubnets(hosts []types.SiaPublicKey) map[string][]string {
+	if enabled, err := r.hostDB.IPViolationsCheck(); err != nil || !enabled {
+		return nil
+	}
+	subnets := make(map[string][]string, len(hosts))
+	for _, pk := range hosts {
+		host, exists, err := r.hostDB.Host(pk)
+		if err != nil || !exists {
+			continue
+		}
+		subnets[pk.String()] = host.IPNets
+	}
+	return subnets
+}
+
+// managedContractHostSubnets returns the subnets of the hosts of the provided
+// contracts. It is used when computing the diversity of many files at once.
+func (r *Renter) managedContractHostSubnets(contracts map[string]modules.RenterContract) map[string][]string {
+	hosts := make([]types.SiaPublicKey, 0, len(contracts))
+	for _, c := range contracts {
+		hosts = append(hosts, c.HostPublicKey)
+	}
+	return r.managedHostSubnets(hosts)
+}
+
 // managedUpdateFileMetadata updates the metadata of a siafile.
 func (r *Renter) managedUpdateFileMetadata(sf *filesystem.FileNode, offlineMap, goodForRenew map[string]bool, contracts map[string]modules.RenterContract, used []types.SiaPublicKey) (err error) {
 	// Update the siafile's used hosts.
@@ -783,14 +842,31 @@ func (r *Renter) managedUpdateFileMetadata(sf *filesystem.FileNode, offlineMap,
 	if err != nil {
 		return errors.AddContext(err, "WARN: Could not update cached redundancy")
 	}
+	// Update cached availability values.
+	_, _, err = sf.Availability(offlineMap, goodForRenew, r.managedHostSubnets(sf.HostPublicKeys()))
+	if err != nil {
+		return errors.AddContext(err, "WARN: Could not update cached availability")
+	}
 	// Update cached health values.
-	_, _, _, _, _, _, _ = sf.Health(offlineMap, goodForRenew)
+	health, stuckHealth, _, _, numStuckChunks, _, _ := sf.Health(offlineMap, goodForRenew)
+	// Update the cached repair cost estimate.
+	if _, err := r.managedRepairCostEstimate(sf, offlineMap, goodForRenew); err != nil {
+		return errors.AddContext(err, "WARN: Could not update cached repair cost estimate")
+	}
+	// Remove the staged copy of the file's source once the file reached full
+	// redundancy. Stuck and abandoned chunks don't count towards the health
+	// so they need to be checked separately.
+	if health == 0 && stuckHealth == 0 && numStuckChunks == 0 && sf.NumAbandonedChunks() == 0 {
+		if err := r.staticUploadStaging.managedRemove(sf.UID()); err != nil {
+			r.log.Printf("WARN: failed to remove staged copy of %v: %v", sf.SiaFilePath(), err)
+		}
+	}
 	// Set the LastHealthCheckTime
 	sf.SetLastHealthCheckTime()
 	// Update the cached expiration of the siafile.
 	_ = sf.Expiration(contracts)
 	// Save the metadata.
-	err = sf.SaveMetadata()
+	err = r.managedTimePersistWrite(sf.SaveMetadata)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,124 @@
+package renter
+
+import (
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+)
+
+const (
+	// concurrentRepairsPerWorker is the number of concurrent
+	// threadedFetchAndRepairChunk goroutines per worker that are allowed if
+	// the user didn't set a limit.
+	concurrentRepairsPerWorker = 2
+
+	// minConcurrentRepairs is the minimum number of concurrent
+	// threadedFetchAndRepairChunk goroutines that are allowed if the user
+	// didn't set a limit.
+	minConcurrentRepairs = 4
+)
+
+var (
+	// errRepairLimiterStopped is returned if the renter shuts down while a
+	// chunk is waiting for the repair limiter.
+	errRepairLimiterStopped = errors.New("renter shut down while waiting for a repair slot")
+)
+
+// repairLimiter is a semaphore which limits the number of concurrent
+// threadedFetchAndRepairChunk goroutines. The memory manager only limits the
+// memory used by the repairs but not the number of goroutines, file handles
+// and downloads of remote repairs.
+type repairLimiter struct {
+	inFlight int
+
+	// wakeChan is closed and replaced whenever a slot is released or the
+	// limit might have changed to wake up waiting chunks.
+	wakeChan chan struct{}
+	mu       sync.Mutex
+}
+
+// newRepairLimiter creates a new repairLimiter.
+func newRepairLimiter() *repairLimiter {
+	return &repairLimiter{
+		wakeChan: make(chan struct{}),
+	}
+}
+
+// managedAcquire blocks until fewer than limit() repairs are in flight and
+// then acquires a slot. limit is called every time the limiter is woken up
+// since the limit might have changed in the meantime. An error is returned if
+// stop is closed before a slot was acquired.
+func (rl *repairLimiter) managedAcquire(limit func() int, stop <-chan struct{}) error {
+	for {
+		max := limit()
+		rl.mu.Lock()
+		if rl.inFlight < max {
+			rl.inFlight++
+			rl.mu.Unlock()
+			return nil
+		}
+		wakeChan := rl.wakeChan
+		rl.mu.Unlock()
+
+		select {
+		case <-stop:
+			return errRepairLimiterStopped
+		case <-wakeChan:
+		}
+	}
+}
+
+// callRelease releases a slot acquired with managedAcquire.
+func (rl *repairLimiter) callRelease() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	if rl.inFlight == 0 {
+		build.Critical("repairLimiter released more often than acquired")
+		return
+	}
+	rl.inFlight--
+	rl.wake()
+}
+
+// callWake wakes up all waiting chunks to check the limit again.
+func (rl *repairLimiter) callWake() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	rl.wake()
+}
+
+// callInFlight returns the number of acquired slots.
+func (rl *repairLimiter) callInFlight() int {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	return rl.inFlight
+}
+
+// wake closes and replaces the wakeChan.
+func (rl *repairLimiter) wake() {
+	close(rl.wakeChan)
+	rl.wakeChan = make(chan struct{})
+}
+
+// managedMaxConcurrentRepairs returns the maximum number of concurrent
+// threadedFetchAndRepairChunk goroutines. If the user didn't set a limit, it
+// is derived from the number of workers. The limit is lowered while the
+// repairs are throttled due to a slow persist directory.
+func (r *Renter) managedMaxConcurrentRepairs() int {
+	id := r.mu.RLock()
+	max := r.persist.MaxConcurrentRepairs
+	r.mu.RUnlock(id)
+	limit := int(max)
+	if max == 0 {
+		limit = concurrentRepairsPerWorker * r.staticWorkerPool.callNumWorkers()
+		if limit < minConcurrentRepairs {
+			limit = minConcurrentRepairs
+		}
+	}
+	// Throttle the repairs while the persist directory is slow.
+	if r.managedDiskLatencyThrottling() && limit > diskLatencyThrottledRepairs {
+		limit = diskLatencyThrottledRepairs
+	}
+	return limit
+}
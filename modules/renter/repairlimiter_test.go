@@ -0,0 +1,208 @@
+package renter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestRepairLimiter is a unit test for the repairLimiter.
+func TestRepairLimiter(t *testing.T) {
+	t.Parallel()
+
+	rl := newRepairLimiter()
+	var limitMu sync.Mutex
+	limit := 2
+	limitFn := func() int {
+		limitMu.Lock()
+		defer limitMu.Unlock()
+		return limit
+	}
+	stop := make(chan struct{})
+
+	// Acquire all slots.
+	for i := 0; i < limitFn(); i++ {
+		if err := rl.managedAcquire(limitFn, stop); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if inFlight := rl.callInFlight(); inFlight != limitFn() {
+		t.Fatal("wrong number of repairs in flight", inFlight)
+	}
+
+	// The next acquire should block until a slot is released.
+	acquired := make(chan error)
+	go func() {
+		acquired <- rl.managedAcquire(limitFn, stop)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquire should block")
+	case <-time.After(100 * time.Millisecond):
+	}
+	rl.callRelease()
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	// Raising the limit and waking up the limiter should unblock a waiting
+	// acquire.
+	go func() {
+		acquired <- rl.managedAcquire(limitFn, stop)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("acquire should block")
+	case <-time.After(100 * time.Millisecond):
+	}
+	limitMu.Lock()
+	limit++
+	limitMu.Unlock()
+	rl.callWake()
+	if err := <-acquired; err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the stop channel should unblock a waiting acquire without
+	// acquiring a slot.
+	go func() {
+		acquired <- rl.managedAcquire(limitFn, stop)
+	}()
+	close(stop)
+	if err := <-acquired; !errors.Contains(err, errRepairLimiterStopped) {
+		t.Fatal("unexpected error", err)
+	}
+	if inFlight := rl.callInFlight(); inFlight != 3 {
+		t.Fatal("wrong number of repairs in flight", inFlight)
+	}
+}
+
+// TestConcurrentRepairsLimit checks that the number of concurrent
+// threadedFetchAndRepairChunk goroutines doesn't exceed the limit set in the
+// renter's settings.
+func TestConcurrentRepairsLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a renter with slow fetches.
+	rt, err := newRenterTesterWithDependency(t.Name(), dependencies.NewDependencySlowFetchAndRepair(100*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Check the default limit.
+	if limit := r.managedMaxConcurrentRepairs(); limit != minConcurrentRepairs {
+		t.Fatal("wrong default limit", limit)
+	}
+
+	// Set the limit.
+	settings, err := r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.MaxConcurrentRepairs = 2
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	settings, err = r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.MaxConcurrentRepairs != 2 {
+		t.Fatal("setting wasn't updated", settings.MaxConcurrentRepairs)
+	}
+
+	// Create a file with a few chunks.
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("concurrentRepairs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	numChunks := 6
+	err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), uint64(numChunks)*modules.SectorSize, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Prepare the chunks in the background while checking the number of
+	// repairs in flight.
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(done)
+		for i := uint64(0); i < uint64(numChunks); i++ {
+			chunk, err := r.managedBuildUnfinishedChunk(f, i, nil, nil, false, nil, nil, nil, r.repairMemoryManager)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if err := r.managedPrepareNextChunk(chunk); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	var maxInFlight int
+	for {
+		metrics, err := r.RepairMetrics()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metrics.ConcurrentRepairsLimit != 2 {
+			t.Fatal("wrong limit", metrics.ConcurrentRepairsLimit)
+		}
+		if metrics.ConcurrentRepairs > maxInFlight {
+			maxInFlight = metrics.ConcurrentRepairs
+		}
+		select {
+		case <-done:
+		case <-time.After(10 * time.Millisecond):
+			continue
+		}
+		break
+	}
+	wg.Wait()
+	if maxInFlight > 2 {
+		t.Fatal("limit wasn't enforced", maxInFlight)
+	}
+	if maxInFlight == 0 {
+		t.Fatal("no repairs were in flight")
+	}
+
+	// All the repairs should finish eventually.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if inFlight := r.staticRepairLimiter.callInFlight(); inFlight != 0 {
+			return errors.New("repairs still in flight")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,128 @@
+package renter
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// repairMetricsBuckets is the number of buckets the rolling window of the
+	// repair metrics is split into.
+	repairMetricsBuckets = 60
+)
+
+// repairMetricsWindow is the length of the rolling window of the repair
+// metrics.
+var repairMetricsWindow = build.Select(build.Var{
+	Dev:      10 * time.Minute,
+	Standard: time.Hour,
+	Testing:  time.Minute,
+}).(time.Duration)
+
+// repairMetrics tracks the progress of the renter's repair pipeline. The
+// totals are updated atomically. The metrics of the rolling window are kept in
+// a ring buffer of buckets which each cover repairMetricsWindow /
+// repairMetricsBuckets.
+type repairMetrics struct {
+	atomicChunksDiscovered    uint64
+	atomicChunksRepaired      uint64
+	atomicChunksStuck         uint64
+	atomicRepairBytesUploaded uint64
+	atomicNewBytesUploaded    uint64
+
+	// buckets contains the metrics of each bucket of the rolling window and
+	// bucketStarts the start of the interval covered by the bucket.
+	buckets      [repairMetricsBuckets]modules.RepairMetrics
+	bucketStarts [repairMetricsBuckets]time.Time
+
+	staticStartTime time.Time
+	mu              sync.Mutex
+}
+
+// newRepairMetrics creates new repair metrics.
+func newRepairMetrics() *repairMetrics {
+	return &repairMetrics{
+		staticStartTime: time.Now(),
+	}
+}
+
+// addRepairMetrics adds the metrics of b to a.
+func addRepairMetrics(a *modules.RepairMetrics, b modules.RepairMetrics) {
+	a.ChunksDiscovered += b.ChunksDiscovered
+	a.ChunksRepaired += b.ChunksRepaired
+	a.ChunksStuck += b.ChunksStuck
+	a.RepairBytesUploaded += b.RepairBytesUploaded
+	a.NewBytesUploaded += b.NewBytesUploaded
+}
+
+// callAdd adds the provided metrics to the totals and to the bucket of the
+// rolling window which covers now.
+func (rm *repairMetrics) callAdd(now time.Time, delta modules.RepairMetrics) {
+	atomic.AddUint64(&rm.atomicChunksDiscovered, delta.ChunksDiscovered)
+	atomic.AddUint64(&rm.atomicChunksRepaired, delta.ChunksRepaired)
+	atomic.AddUint64(&rm.atomicChunksStuck, delta.ChunksStuck)
+	atomic.AddUint64(&rm.atomicRepairBytesUploaded, delta.RepairBytesUploaded)
+	atomic.AddUint64(&rm.atomicNewBytesUploaded, delta.NewBytesUploaded)
+
+	interval := repairMetricsWindow / repairMetricsBuckets
+	start := now.Truncate(interval)
+	i := (start.UnixNano() / int64(interval)) % repairMetricsBuckets
+
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	// Reset the bucket if it still contains the metrics of a previous
+	// interval.
+	if !rm.bucketStarts[i].Equal(start) {
+		rm.buckets[i] = modules.RepairMetrics{}
+		rm.bucketStarts[i] = start
+	}
+	addRepairMetrics(&rm.buckets[i], delta)
+}
+
+// callStatus returns the total repair metrics and the metrics of the rolling
+// window ending at now.
+func (rm *repairMetrics) callStatus(now time.Time) modules.RenterRepairMetrics {
+	status := modules.RenterRepairMetrics{
+		Total: modules.RepairMetrics{
+			ChunksDiscovered:    atomic.LoadUint64(&rm.atomicChunksDiscovered),
+			ChunksRepaired:      atomic.LoadUint64(&rm.atomicChunksRepaired),
+			ChunksStuck:         atomic.LoadUint64(&rm.atomicChunksStuck),
+			RepairBytesUploaded: atomic.LoadUint64(&rm.atomicRepairBytesUploaded),
+			NewBytesUploaded:    atomic.LoadUint64(&rm.atomicNewBytesUploaded),
+		},
+		RecentWindow: repairMetricsWindow,
+		StartTime:    rm.staticStartTime,
+	}
+
+	windowStart := now.Add(-repairMetricsWindow)
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	for i := range rm.buckets {
+		if rm.bucketStarts[i].After(windowStart) {
+			addRepairMetrics(&status.Recent, rm.buckets[i])
+		}
+	}
+	return status
+}
+
+// RepairMetrics returns the renter's repair metrics since startup as well as
+// the metrics of the recent past.
+func (r *Renter) RepairMetrics() (modules.RenterRepairMetrics, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterRepairMetrics{}, err
+	}
+	defer r.tg.Done()
+	metrics := r.staticRepairMetrics.callStatus(time.Now())
+	metrics.DirectoryHeap = r.directoryHeap.managedStatus()
+	metrics.ConcurrentRepairs = r.staticRepairLimiter.callInFlight()
+	metrics.ConcurrentRepairsLimit = r.managedMaxConcurrentRepairs()
+	metrics.FailedPushes = make(map[string]uint64)
+	for reason, n := range r.uploadHeap.managedFailedPushes() {
+		metrics.FailedPushes[string(reason)] = n
+	}
+	return metrics, nil
+}
@@ -0,0 +1,73 @@
+package renter
+
+import (
+	"testing"
+
+	"go.sia.tech/siad/modules"
+)
+
+// TestRepairMetrics is a unit test for the repairMetrics.
+func TestRepairMetrics(t *testing.T) {
+	t.Parallel()
+
+	rm := newRepairMetrics()
+	now := rm.staticStartTime
+	interval := repairMetricsWindow / repairMetricsBuckets
+
+	// Without any updates all metrics should be 0.
+	status := rm.callStatus(now)
+	if status.Total != (modules.RepairMetrics{}) || status.Recent != (modules.RepairMetrics{}) {
+		t.Fatal("expected empty metrics", status)
+	}
+	if status.RecentWindow != repairMetricsWindow || !status.StartTime.Equal(rm.staticStartTime) {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Add a metric to every bucket of a full window.
+	delta := modules.RepairMetrics{
+		ChunksDiscovered:    5,
+		ChunksRepaired:      4,
+		ChunksStuck:         3,
+		RepairBytesUploaded: 2,
+		NewBytesUploaded:    1,
+	}
+	for i := 0; i < repairMetricsBuckets; i++ {
+		rm.callAdd(now, delta)
+		now = now.Add(interval)
+	}
+	expected := modules.RepairMetrics{
+		ChunksDiscovered:    5 * repairMetricsBuckets,
+		ChunksRepaired:      4 * repairMetricsBuckets,
+		ChunksStuck:         3 * repairMetricsBuckets,
+		RepairBytesUploaded: 2 * repairMetricsBuckets,
+		NewBytesUploaded:    1 * repairMetricsBuckets,
+	}
+	status = rm.callStatus(now.Add(-interval))
+	if status.Total != expected || status.Recent != expected {
+		t.Fatal("unexpected metrics", status)
+	}
+
+	// Adding another metric overwrites the oldest bucket. The total keeps
+	// counting.
+	rm.callAdd(now, modules.RepairMetrics{ChunksStuck: 1})
+	status = rm.callStatus(now)
+	expected.ChunksStuck++
+	if status.Total != expected {
+		t.Fatal("unexpected total", status.Total)
+	}
+	expected.ChunksDiscovered -= 5
+	expected.ChunksRepaired -= 4
+	expected.ChunksStuck -= 3
+	expected.RepairBytesUploaded -= 2
+	expected.NewBytesUploaded--
+	if status.Recent != expected {
+		t.Fatal("unexpected recent metrics", status.Recent)
+	}
+
+	// After a full window without updates the recent metrics should be 0
+	// again.
+	status = rm.callStatus(now.Add(repairMetricsWindow))
+	if status.Recent != (modules.RepairMetrics{}) {
+		t.Fatal("expected empty recent metrics", status.Recent)
+	}
+}
@@ -0,0 +1,72 @@
+package renter
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+)
+
+const (
+	// repairStatsDecay is the decay applied to the repair stats every
+	// repairStatsDecayInterval.
+	repairStatsDecay = 0.9
+)
+
+// repairStatsDecayInterval is the interval after which the repair stats are
+// decayed by repairStatsDecay.
+var repairStatsDecayInterval = build.Select(build.Var{
+	Dev:      10 * time.Second,
+	Standard: time.Minute,
+	Testing:  time.Second,
+}).(time.Duration)
+
+// repairStats tracks the rate at which the renter repairs data. Both the
+// repaired bytes and the elapsed time decay over time so that the rate focuses
+// on recent repairs.
+type repairStats struct {
+	decayedBytes   float64
+	decayedSeconds float64
+	lastDecay      time.Time
+	staticDecay    float64
+
+	mu sync.Mutex
+}
+
+// newRepairStats creates new repair stats with the provided decay.
+func newRepairStats(decay float64) *repairStats {
+	return &repairStats{
+		lastDecay:   time.Now(),
+		staticDecay: decay,
+	}
+}
+
+// callAddBytes adds the number of bytes that were repaired to the stats.
+func (rs *repairStats) callAddBytes(n uint64) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.decay()
+	rs.decayedBytes += float64(n)
+}
+
+// callAvgRepairRate returns the average number of bytes repaired per second.
+func (rs *repairStats) callAvgRepairRate() uint64 {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.decay()
+	if rs.decayedSeconds == 0 {
+		return 0
+	}
+	return uint64(rs.decayedBytes / rs.decayedSeconds)
+}
+
+// decay decays the tracked bytes and time according to the time that passed
+// since the last decay and adds that time to the tracked time.
+func (rs *repairStats) decay() {
+	elapsed := time.Since(rs.lastDecay)
+	rs.lastDecay = time.Now()
+	d := math.Pow(rs.staticDecay, float64(elapsed)/float64(repairStatsDecayInterval))
+	rs.decayedBytes *= d
+	rs.decayedSeconds = rs.decayedSeconds*d + elapsed.Seconds()
+}
@@ -0,0 +1,36 @@
+package renter
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRepairStats is a unit test for the repairStats.
+func TestRepairStats(t *testing.T) {
+	t.Parallel()
+
+	rs := newRepairStats(repairStatsDecay)
+
+	// Without any repairs the rate should be 0.
+	if rate := rs.callAvgRepairRate(); rate != 0 {
+		t.Fatal("expected rate to be 0", rate)
+	}
+
+	// Pretend that 1000 bytes were repaired over the course of 10 seconds.
+	rs.mu.Lock()
+	rs.lastDecay = time.Now().Add(-10 * time.Second)
+	rs.mu.Unlock()
+	rs.callAddBytes(1000)
+	rate := rs.callAvgRepairRate()
+	if rate < 90 || rate > 100 {
+		t.Fatal("unexpected rate", rate)
+	}
+
+	// A lot of time passing without repairs should drive the rate down.
+	rs.mu.Lock()
+	rs.lastDecay = time.Now().Add(-100 * repairStatsDecayInterval)
+	rs.mu.Unlock()
+	if newRate := rs.callAvgRepairRate(); newRate >= rate {
+		t.Fatal("rate should have decreased", newRate, rate)
+	}
+}
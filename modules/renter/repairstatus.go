@@ -0,0 +1,80 @@
+package renter
+
+import (
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// repairStatusNumDirectories is the number of directories from the top
+	// of the directory heap that are returned by RepairStatus.
+	repairStatusNumDirectories = 10
+)
+
+// repairLoopState records the phase threadedUploadAndRepair is currently in
+// and when it last completed a repair cycle.
+type repairLoopState struct {
+	phase              modules.RepairLoopPhase
+	blockedReason      string
+	phaseStart         time.Time
+	lastCompletedCycle time.Time
+	mu                 sync.Mutex
+}
+
+// newRepairLoopState creates a new repairLoopState. The loop is considered
+// blocked until it starts.
+func newRepairLoopState() *repairLoopState {
+	return &repairLoopState{
+		phase:         modules.RepairLoopPhaseBlocked,
+		blockedReason: "the repair loop hasn't started yet",
+		phaseStart:    time.Now(),
+	}
+}
+
+// callSetPhase updates the phase of the repair loop. The start of the phase is
+// only updated if the phase or the reason for being blocked changed.
+func (rls *repairLoopState) callSetPhase(phase modules.RepairLoopPhase, blockedReason string) {
+	rls.mu.Lock()
+	defer rls.mu.Unlock()
+	if rls.phase == phase && rls.blockedReason == blockedReason {
+		return
+	}
+	rls.phase = phase
+	rls.blockedReason = blockedReason
+	rls.phaseStart = time.Now()
+}
+
+// callCompleteCycle records the completion of a repair cycle.
+func (rls *repairLoopState) callCompleteCycle(t time.Time) {
+	rls.mu.Lock()
+	defer rls.mu.Unlock()
+	rls.lastCompletedCycle = t
+}
+
+// callStatus returns a repair status containing the state of the repair loop.
+func (rls *repairLoopState) callStatus() modules.RenterRepairStatus {
+	rls.mu.Lock()
+	defer rls.mu.Unlock()
+	return modules.RenterRepairStatus{
+		Phase:              rls.phase,
+		BlockedReason:      rls.blockedReason,
+		PhaseStart:         rls.phaseStart,
+		LastCompletedCycle: rls.lastCompletedCycle,
+	}
+}
+
+// RepairStatus returns what the repair loop is currently doing, the
+// directories at the top of the directory heap, a summary of the upload heap
+// and the chunks which are currently being repaired.
+func (r *Renter) RepairStatus() (modules.RenterRepairStatus, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterRepairStatus{}, err
+	}
+	defer r.tg.Done()
+	status := r.staticRepairLoopState.callStatus()
+	status.Directories = r.directoryHeap.managedTop(repairStatusNumDirectories)
+	status.UploadHeap, status.RepairingChunks = r.uploadHeap.managedSnapshot(r.staticFileSystem.FileSiaPath)
+	return status, nil
+}
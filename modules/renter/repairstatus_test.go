@@ -0,0 +1,140 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestRepairLoopState is a unit test for the repairLoopState.
+func TestRepairLoopState(t *testing.T) {
+	t.Parallel()
+
+	rls := newRepairLoopState()
+	status := rls.callStatus()
+	if status.Phase != modules.RepairLoopPhaseBlocked || status.BlockedReason == "" {
+		t.Fatal("loop should be blocked before it starts", status)
+	}
+	if !status.LastCompletedCycle.IsZero() {
+		t.Fatal("no cycle should have been completed", status.LastCompletedCycle)
+	}
+
+	// Setting the same phase again shouldn't reset the start of the phase.
+	rls.callSetPhase(modules.RepairLoopPhaseActive, "")
+	start := rls.callStatus().PhaseStart
+	time.Sleep(10 * time.Millisecond)
+	rls.callSetPhase(modules.RepairLoopPhaseActive, "")
+	status = rls.callStatus()
+	if status.Phase != modules.RepairLoopPhaseActive || status.BlockedReason != "" || !status.PhaseStart.Equal(start) {
+		t.Fatal("wrong status", status, start)
+	}
+
+	// Changing the phase should.
+	rls.callSetPhase(modules.RepairLoopPhaseSleeping, "")
+	status = rls.callStatus()
+	if status.Phase != modules.RepairLoopPhaseSleeping || !status.PhaseStart.After(start) {
+		t.Fatal("wrong status", status, start)
+	}
+
+	// Completing a cycle doesn't change the phase.
+	completed := time.Now()
+	rls.callCompleteCycle(completed)
+	status = rls.callStatus()
+	if status.Phase != modules.RepairLoopPhaseSleeping || !status.LastCompletedCycle.Equal(completed) {
+		t.Fatal("wrong status", status, completed)
+	}
+}
+
+// TestRepairStatus probes the snapshots of the directory heap and upload heap
+// returned by RepairStatus.
+func TestRepairStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Without the repair loop, the loop is blocked and the heaps are empty.
+	status, err := r.RepairStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Phase != modules.RepairLoopPhaseBlocked || len(status.Directories) != 0 || len(status.RepairingChunks) != 0 || status.UploadHeap != (modules.UploadHeapSummary{}) {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Add more directories than are returned.
+	addDirectoriesToHeap(r, 3, true, true)
+	addDirectoriesToHeap(r, 3, true, false)
+	addDirectoriesToHeap(r, 3, false, true)
+	addDirectoriesToHeap(r, 3, false, false)
+
+	// The top directories should be returned in the order they are popped
+	// without modifying the heap.
+	status, err = r.RepairStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status.Directories) != repairStatusNumDirectories {
+		t.Fatal("wrong number of directories", len(status.Directories))
+	}
+	if r.directoryHeap.managedLen() != 12 {
+		t.Fatal("heap was modified", r.directoryHeap.managedLen())
+	}
+	for i, dir := range status.Directories {
+		d := r.directoryHeap.managedPop()
+		health, remote := d.managedHeapHealth()
+		if !dir.SiaPath.Equals(d.staticSiaPath) || dir.Health != health || dir.Remote != remote {
+			t.Fatal("wrong directory", i, dir, d.staticSiaPath, health, remote)
+		}
+	}
+
+	// Add stuck and unstuck chunks to the upload heap and pop the stuck ones
+	// to mark them as repairing.
+	if err := addChunksOfDifferentHealth(r, 3, false, false, false, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := addChunksOfDifferentHealth(r, 2, false, false, true, false); err != nil {
+		t.Fatal(err)
+	}
+	r.uploadHeap.managedPop()
+	r.uploadHeap.managedPop()
+
+	// The chunks in the test don't belong to a file so the siapath is
+	// looked up with a stub.
+	siaPath := modules.RandomSiaPath()
+	summary, chunks := r.uploadHeap.managedSnapshot(func(*filesystem.FileNode) modules.SiaPath {
+		return siaPath
+	})
+	expected := modules.UploadHeapSummary{
+		Len:       3,
+		Stuck:     0,
+		Unstuck:   3,
+		Repairing: 2,
+	}
+	if summary != expected {
+		t.Fatal("wrong summary", summary)
+	}
+	if len(chunks) != 2 {
+		t.Fatal("wrong number of repairing chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if !chunk.SiaPath.Equals(siaPath) || !chunk.Stuck {
+			t.Fatal("wrong chunk", chunk)
+		}
+	}
+}
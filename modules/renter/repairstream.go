@@ -0,0 +1,160 @@
+package renter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+var (
+	// errRepairStreamMismatch is returned if the streamed data of a chunk
+	// doesn't match the roots of the previously uploaded pieces.
+	errRepairStreamMismatch = errors.New("streamed data doesn't match the uploaded pieces")
+
+	// errRepairStreamUnverifiable is returned if the streamed data of a chunk
+	// can't be verified since the roots of all its pieces are unknown or the
+	// pieces can't be re-encrypted deterministically.
+	errRepairStreamUnverifiable = errors.New("streamed data can't be verified against the uploaded pieces")
+)
+
+// RepairFromStream repairs a file using its content read from the provided
+// reader. Chunks which need to be repaired are verified against the roots of
+// their previously uploaded pieces before they are handed to the workers,
+// which means that no data needs to be downloaded from the hosts. Chunks that
+// don't match are rejected without interrupting the repair of the remaining
+// chunks.
+func (r *Renter) RepairFromStream(siaPath modules.SiaPath, reader io.Reader) (_ modules.RepairStreamReport, err error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RepairStreamReport{}, err
+	}
+	defer r.tg.Done()
+
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return modules.RepairStreamReport{}, err
+	}
+	defer func() {
+		err = errors.Compose(err, entry.Close())
+	}()
+
+	// Build a map of host public keys.
+	pks := make(map[string]types.SiaPublicKey)
+	for _, pk := range entry.HostPublicKeys() {
+		pks[string(pk.Key)] = pk
+	}
+	hosts := r.managedRefreshHostsAndWorkers()
+	expensiveHosts := r.staticWorkerPool.callExpensiveUploadHosts()
+
+	// Read the chunks one by one from the stream and push the ones which need
+	// to be repaired.
+	var report modules.RepairStreamReport
+	var chunks []*unfinishedUploadChunk
+	for chunkIndex := uint64(0); chunkIndex < entry.NumChunks(); chunkIndex++ {
+		offset := chunkIndex * entry.ChunkSize()
+		length := entry.ChunkSize()
+		if offset+length > entry.Size() {
+			length = entry.Size() - offset
+		}
+		data := make([]byte, length)
+		_, err := io.ReadFull(reader, data)
+		if err != nil {
+			return report, errors.AddContext(err, fmt.Sprintf("unable to read chunk %v from the stream", chunkIndex))
+		}
+
+		offline, goodForRenew, _ := r.managedContractUtilityMaps()
+		uuc, err := r.managedBuildUnfinishedChunk(entry, chunkIndex, hosts, pks, memoryPriorityHigh, offline, goodForRenew, expensiveHosts, r.userUploadMemoryManager)
+		if err != nil {
+			return report, errors.AddContext(err, fmt.Sprintf("unable to build chunk %v", chunkIndex))
+		}
+		if uuc.piecesCompleted >= uuc.staticPiecesNeeded {
+			report.Skipped++
+			_ = r.managedCloseEntry(uuc)
+			continue
+		}
+		err = uuc.staticVerifyStreamedData(data)
+		if err != nil {
+			r.repairLog.Printf("Rejecting streamed data of chunk %v of %v: %v", chunkIndex, siaPath, err)
+			report.Rejected = append(report.Rejected, chunkIndex)
+			_ = r.managedCloseEntry(uuc)
+			continue
+		}
+
+		// The length of the last chunk is adjusted to the actual data to
+		// prevent the size of the file from changing when the data is read.
+		uuc.length = length
+		uuc.sourceReader = ioutil.NopCloser(bytes.NewReader(data))
+		pushed, err := r.managedPushChunkForRepair(uuc, chunkTypeStreamChunk)
+		if err != nil {
+			r.repairLog.Printf("Unable to push streamed chunk %v of %v: %v", chunkIndex, siaPath, err)
+			report.Failed = append(report.Failed, chunkIndex)
+			continue
+		}
+		if !pushed {
+			// The chunk is already being repaired.
+			report.Skipped++
+			_ = r.managedCloseEntry(uuc)
+			continue
+		}
+		chunks = append(chunks, uuc)
+	}
+
+	// Wait for the repaired chunks to become available.
+	for _, chunk := range chunks {
+		select {
+		case <-r.tg.StopChan():
+			return report, errors.New("repair interrupted by shutdown")
+		case <-chunk.staticAvailableChan:
+		}
+		chunk.mu.Lock()
+		chunkErr := chunk.err
+		chunk.mu.Unlock()
+		if chunkErr != nil {
+			report.Failed = append(report.Failed, chunk.staticIndex)
+			continue
+		}
+		report.Repaired++
+	}
+	return report, nil
+}
+
+// staticVerifyStreamedData checks that the provided data of the chunk matches
+// the roots of all of the chunk's previously uploaded pieces. Unlike
+// staticEncryptAndCheckIntegrity, the pieces which are still stored on good
+// hosts are checked too and the chunk itself isn't modified.
+func (uc *unfinishedUploadChunk) staticVerifyStreamedData(data []byte) error {
+	// Twofish-GCM uses a random nonce which means that the roots of the
+	// uploaded pieces can't be reproduced.
+	if uc.fileEntry.MasterKey().Type() == crypto.TypeTwofish {
+		return errRepairStreamUnverifiable
+	}
+	dataPieces, _, err := readDataPieces(bytes.NewReader(data), uc.fileEntry.ErasureCode(), uc.fileEntry.PieceSize())
+	if err != nil {
+		return errors.AddContext(err, "unable to read the data pieces")
+	}
+	pieces, err := uc.fileEntry.ErasureCode().EncodeShards(dataPieces)
+	if err != nil {
+		return errors.AddContext(err, "unable to encode the data pieces")
+	}
+	var verified int
+	for i, root := range uc.staticExpectedPieceRoots {
+		if root == (crypto.Hash{}) {
+			continue
+		}
+		padAndEncryptPiece(uc.staticIndex, uint64(i), pieces, uc.fileEntry.MasterKey())
+		if crypto.MerkleRoot(pieces[i]) != root {
+			return errRepairStreamMismatch
+		}
+		verified++
+	}
+	if verified == 0 {
+		return errRepairStreamUnverifiable
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package renter
+
+import (
+	"bytes"
+	"testing"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/crypto"
+)
+
+// TestStaticVerifyStreamedData is a unit test for staticVerifyStreamedData.
+func TestStaticVerifyStreamedData(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	siaPath, rsc := testingFileParams()
+	sf, err := rt.renter.createRenterTestFileWithParams(siaPath, rsc, crypto.TypeThreefish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := sf.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Compute the roots of the pieces of a partial chunk.
+	data := fastrand.Bytes(int(sf.ChunkSize() / 2))
+	dataPieces, _, err := readDataPieces(bytes.NewReader(data), sf.ErasureCode(), sf.PieceSize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces, err := sf.ErasureCode().EncodeShards(dataPieces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := make([]crypto.Hash, len(pieces))
+	for i := range pieces {
+		padAndEncryptPiece(1, uint64(i), pieces, sf.MasterKey())
+		roots[i] = crypto.MerkleRoot(pieces[i])
+	}
+
+	// Without any roots the data can't be verified.
+	uc := &unfinishedUploadChunk{
+		fileEntry:                sf,
+		staticIndex:              1,
+		staticExpectedPieceRoots: make([]crypto.Hash, len(roots)),
+	}
+	if err := uc.staticVerifyStreamedData(data); !errors.Contains(err, errRepairStreamUnverifiable) {
+		t.Fatal("expected data to be unverifiable", err)
+	}
+
+	// Knowing the root of the last piece is enough.
+	uc.staticExpectedPieceRoots[len(roots)-1] = roots[len(roots)-1]
+	if err := uc.staticVerifyStreamedData(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// The data matches all roots.
+	copy(uc.staticExpectedPieceRoots, roots)
+	if err := uc.staticVerifyStreamedData(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupted data doesn't match.
+	corrupted := append([]byte(nil), data...)
+	corrupted[fastrand.Intn(len(corrupted))]++
+	if err := uc.staticVerifyStreamedData(corrupted); !errors.Contains(err, errRepairStreamMismatch) {
+		t.Fatal("expected corrupted data to mismatch", err)
+	}
+
+	// The data of a different chunk doesn't match either.
+	uc.staticIndex = 0
+	if err := uc.staticVerifyStreamedData(data); !errors.Contains(err, errRepairStreamMismatch) {
+		t.Fatal("expected data of the wrong chunk to mismatch", err)
+	}
+
+	// The data of a file encrypted with Twofish-GCM can't be verified since
+	// the encryption uses a random nonce.
+	siaPath, rsc = testingFileParams()
+	twofish, err := rt.renter.createRenterTestFileWithParams(siaPath, rsc, crypto.TypeTwofish)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := twofish.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	uc.fileEntry = twofish
+	if err := uc.staticVerifyStreamedData(data); !errors.Contains(err, errRepairStreamUnverifiable) {
+		t.Fatal("expected data to be unverifiable", err)
+	}
+}
@@ -0,0 +1,35 @@
+package renter
+
+import (
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+)
+
+const (
+	// selfTestChunkMetadataOverhead and selfTestPieceMetadataSize approximate
+	// the size of a chunk's metadata on disk. A chunk consists of a fixed
+	// overhead and a piece index, table offset and merkle root per piece.
+	selfTestChunkMetadataOverhead = 16 + 2 + 1
+	selfTestPieceMetadataSize     = 4 + 4 + crypto.HashSize
+)
+
+var _ modules.SelfTestRequirer = (*Renter)(nil)
+
+// SelfTestRequirements returns the disk space needed for the metadata of the
+// files that the renter expects to store according to its allowance.
+func (r *Renter) SelfTestRequirements() modules.SelfTestRequirements {
+	var reqs modules.SelfTestRequirements
+	expectedStorage := r.hostContractor.Allowance().ExpectedStorage
+	if expectedStorage == 0 {
+		return reqs
+	}
+	chunkSize := modules.SectorSize * uint64(modules.RenterDefaultDataPieces)
+	numChunks := (expectedStorage + chunkSize - 1) / chunkSize
+	numPieces := uint64(modules.RenterDefaultDataPieces + modules.RenterDefaultParityPieces)
+	reqs.DiskSpace = append(reqs.DiskSpace, modules.DiskSpaceRequirement{
+		Dir:    r.persistDir,
+		Bytes:  numChunks * (selfTestChunkMetadataOverhead + numPieces*selfTestPieceMetadataSize),
+		Reason: "renter metadata of the expected storage",
+	})
+	return reqs
+}
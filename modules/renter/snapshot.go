@@ -183,6 +183,12 @@ func (r *Renter) managedUploadBackup(src, name string) error {
 		return err
 	}
 
+	// Signal r.threadedUploadAndRepair to repair the backup chunks which the
+	// upload stream didn't finish.
+	select {
+	case r.uploadHeap.backupNeeded <- struct{}{}:
+	default:
+	}
 	return nil
 }
 
@@ -266,6 +272,21 @@ func (r *Renter) managedSnapshotExists(name string) bool {
 	return false
 }
 
+// managedSnapshotRemainingChunks returns the number of chunks of a snapshot
+// siafile which still need to be repaired. Stuck chunks are only counted if
+// they actually need repair.
+func (r *Renter) managedSnapshotRemainingChunks(siaPath modules.SiaPath, offline, goodForRenew map[string]bool) (uint64, error) {
+	entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return 0, errors.AddContext(err, "failed to get entry for snapshot")
+	}
+	healthy, err := healthyChunks(entry, offline, goodForRenew)
+	if err != nil {
+		return 0, errors.Compose(err, entry.Close())
+	}
+	return entry.NumChunks() - uint64(len(healthy)), entry.Close()
+}
+
 // managedSaveSnapshot saves snapshot metadata to disk.
 func (r *Renter) managedSaveSnapshot(meta modules.UploadedBackup) error {
 	id := r.mu.Lock()
@@ -586,6 +607,7 @@ func (r *Renter) threadedSynchronizeSnapshots() {
 
 		// First, process any snapshot siafiles that may have finished uploading.
 		root := modules.BackupFolder
+		offlineMap, goodForRenewMap, contractsMap := r.managedContractUtilityMaps()
 		var mu sync.Mutex
 		flf := func(info modules.FileInfo) {
 			// Make sure we only look at a single info at a time.
@@ -609,8 +631,15 @@ func (r *Renter) threadedSynchronizeSnapshots() {
 				return
 			}
 
-			// record current UploadProgress
+			// record current UploadProgress and the number of chunks which
+			// still need to be uploaded
 			meta.UploadProgress = calcSnapshotUploadProgress(info.UploadProgress, 0)
+			remainingChunks, err := r.managedSnapshotRemainingChunks(info.SiaPath, offlineMap, goodForRenewMap)
+			if err != nil {
+				r.log.Println("Could not count remaining chunks:", err)
+				return
+			}
+			meta.RemainingChunks = remainingChunks
 			if err := r.managedSaveSnapshot(meta); err != nil {
 				r.log.Println("Could not save upload progress:", err)
 				return
@@ -621,7 +650,7 @@ func (r *Renter) threadedSynchronizeSnapshots() {
 				return
 			}
 			r.log.Println("Uploading snapshot", info.SiaPath)
-			err := func() error {
+			err = func() error {
 				// Grab the entry for the uploaded backup's siafile.
 				entry, err := r.staticFileSystem.OpenSiaFile(info.SiaPath)
 				if err != nil {
@@ -655,6 +684,7 @@ func (r *Renter) threadedSynchronizeSnapshots() {
 
 				// Upload the snapshot to the network.
 				meta.UploadProgress = calcSnapshotUploadProgress(100, 0)
+				meta.RemainingChunks = 0
 				meta.Size = uint64(len(dotSia))
 				if err := r.managedUploadSnapshot(meta, dotSia); err != nil {
 					return errors.Compose(err, entry.Close())
@@ -676,8 +706,8 @@ func (r *Renter) threadedSynchronizeSnapshots() {
 				r.log.Println("Failed to upload snapshot .sia:", err)
 			}
 		}
-		offlineMap, goodForRenewMap, contractsMap := r.managedContractUtilityMaps()
-		err := r.staticFileSystem.List(root, true, offlineMap, goodForRenewMap, contractsMap, flf, func(modules.DirectoryInfo) {})
+		hostSubnets := r.managedContractHostSubnets(contractsMap)
+		err := r.staticFileSystem.List(root, true, offlineMap, goodForRenewMap, hostSubnets, contractsMap, flf, func(modules.DirectoryInfo) {})
 		if err != nil {
 			r.log.Println("Could not get un-uploaded snapshots:", err)
 		}
@@ -691,7 +721,7 @@ func (r *Renter) threadedSynchronizeSnapshots() {
 			} else {
 				// signal r.threadedUploadAndRepair to keep uploading the snapshot
 				select {
-				case r.uploadHeap.repairNeeded <- struct{}{}:
+				case r.uploadHeap.backupNeeded <- struct{}{}:
 				default:
 				}
 			}
@@ -0,0 +1,229 @@
+package renter
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+const (
+	// stuckCursorFilename is the filename of the file the stuck cursor is
+	// persisted in.
+	stuckCursorFilename = "stuckcursor.json"
+
+	// stuckCursorUntriedWeight, stuckCursorTriedWeight and
+	// stuckCursorFailedWeight are the factors the number of stuck chunks of a
+	// file is multiplied with when the stuck loop randomly selects a stuck
+	// chunk. Files which were never attempted are preferred over files which
+	// were attempted and files whose last attempt failed are the least likely
+	// to be selected. None of the weights are 0 to make sure that every stuck
+	// chunk is eventually retried.
+	stuckCursorUntriedWeight = 4
+	stuckCursorTriedWeight   = 2
+	stuckCursorFailedWeight  = 1
+)
+
+var (
+	// stuckCursorMetadata is the header of the stuck cursor's persist file.
+	stuckCursorMetadata = persist.Metadata{
+		Header:  "Renter Stuck Cursor",
+		Version: "1.0",
+	}
+)
+
+type (
+	// stuckCursor remembers the recent stuck repair attempts of the stuck loop
+	// and their outcomes. It is persisted to disk which allows the stuck loop
+	// to continue where it left off after a restart instead of revisiting the
+	// same files over and over again.
+	stuckCursor struct {
+		// entries contains the entries ordered by the time of their last
+		// attempt with the oldest attempt first.
+		//
+		// NOTE: the entries are stored in a slice since the number of entries
+		// is small. All lookups are linear.
+		entries []modules.StuckCursorEntry
+		dirty   bool
+
+		staticPath string
+		mu         sync.Mutex
+	}
+)
+
+// newStuckCursor loads the stuck cursor from the provided path. A missing file
+// results in an empty cursor.
+func newStuckCursor(path string) (*stuckCursor, error) {
+	sc := &stuckCursor{
+		staticPath: path,
+	}
+	err := persist.LoadJSON(stuckCursorMetadata, &sc.entries, path)
+	if os.IsNotExist(err) {
+		return sc, nil
+	}
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to load stuck cursor")
+	}
+	// Drop the oldest entries in case the limit was lowered.
+	if len(sc.entries) > maxStuckCursorEntries {
+		sc.entries = sc.entries[len(sc.entries)-maxStuckCursorEntries:]
+	}
+	return sc, nil
+}
+
+// entryWeight returns the weight factor of an entry.
+func entryWeight(e modules.StuckCursorEntry) uint64 {
+	if e.LastOutcome == modules.StuckOutcomeFailure {
+		return stuckCursorFailedWeight
+	}
+	return stuckCursorTriedWeight
+}
+
+// isInDir returns whether the siaPath is within the directory. If recursive
+// is false, it needs to be a direct child of the directory.
+func isInDir(siaPath, dir modules.SiaPath, recursive bool) bool {
+	if !recursive {
+		parent, err := siaPath.Dir()
+		return err == nil && parent.Equals(dir)
+	}
+	return dir.IsRoot() || strings.HasPrefix(siaPath.String(), dir.String()+"/")
+}
+
+// index returns the index of the entry for the siaPath or -1.
+func (sc *stuckCursor) index(siaPath modules.SiaPath) int {
+	for i := range sc.entries {
+		if sc.entries[i].SiaPath.Equals(siaPath) {
+			return i
+		}
+	}
+	return -1
+}
+
+// callDirWeight returns the weight of a directory with numStuckChunks stuck
+// chunks for the stuck loop's random walk. If recursive is false, only the
+// files directly within the directory are considered. The weight of a
+// directory is the weight its stuck chunks would have if none of them were
+// attempted reduced by the stuck chunks of the attempted files.
+func (sc *stuckCursor) callDirWeight(dir modules.SiaPath, numStuckChunks uint64, recursive bool) uint64 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	weight := numStuckChunks * stuckCursorUntriedWeight
+	remaining := numStuckChunks
+	for _, e := range sc.entries {
+		if remaining == 0 {
+			break
+		}
+		if !isInDir(e.SiaPath, dir, recursive) {
+			continue
+		}
+		n := e.NumStuckChunks
+		if n > remaining {
+			n = remaining
+		}
+		weight -= n * (stuckCursorUntriedWeight - entryWeight(e))
+		remaining -= n
+	}
+	return weight
+}
+
+// callFileWeight returns the weight of a file with numStuckChunks stuck chunks
+// for the stuck loop's random selection.
+func (sc *stuckCursor) callFileWeight(siaPath modules.SiaPath, numStuckChunks uint64) uint64 {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	i := sc.index(siaPath)
+	if i == -1 {
+		return numStuckChunks * stuckCursorUntriedWeight
+	}
+	return numStuckChunks * entryWeight(sc.entries[i])
+}
+
+// callRecordAttempt records a stuck repair attempt for a file with
+// numStuckChunks stuck chunks.
+func (sc *stuckCursor) callRecordAttempt(siaPath modules.SiaPath, numStuckChunks uint64, now time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.recordAttempt(siaPath, numStuckChunks, now)
+}
+
+// recordAttempt is the unlocked version of callRecordAttempt. It returns the
+// index of the updated entry.
+func (sc *stuckCursor) recordAttempt(siaPath modules.SiaPath, numStuckChunks uint64, now time.Time) int {
+	var e modules.StuckCursorEntry
+	if i := sc.index(siaPath); i != -1 {
+		e = sc.entries[i]
+		sc.entries = append(sc.entries[:i], sc.entries[i+1:]...)
+	}
+	e.SiaPath = siaPath
+	e.NumStuckChunks = numStuckChunks
+	e.Attempts++
+	e.LastAttempt = now
+	e.LastOutcome = modules.StuckOutcomePending
+
+	// Prune the oldest entries if the cursor is full.
+	if len(sc.entries) >= maxStuckCursorEntries {
+		sc.entries = sc.entries[len(sc.entries)-maxStuckCursorEntries+1:]
+	}
+	sc.entries = append(sc.entries, e)
+	sc.dirty = true
+	return len(sc.entries) - 1
+}
+
+// callRecordOutcome records the outcome of a stuck repair of a file which
+// has numStuckChunks stuck chunks left. Repairs of files the stuck loop didn't
+// select at random, e.g. from the stuck stack, are recorded as attempts as
+// well.
+func (sc *stuckCursor) callRecordOutcome(siaPath modules.SiaPath, numStuckChunks uint64, success bool, now time.Time) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	i := sc.index(siaPath)
+	if i == -1 {
+		i = sc.recordAttempt(siaPath, numStuckChunks, now)
+	}
+	e := &sc.entries[i]
+	e.NumStuckChunks = numStuckChunks
+	if success {
+		e.Successes++
+		e.LastOutcome = modules.StuckOutcomeSuccess
+	} else {
+		e.Failures++
+		e.LastOutcome = modules.StuckOutcomeFailure
+	}
+	sc.dirty = true
+}
+
+// callEntries returns a copy of the cursor's entries.
+func (sc *stuckCursor) callEntries() []modules.StuckCursorEntry {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return append([]modules.StuckCursorEntry{}, sc.entries...)
+}
+
+// callSave persists the cursor if it changed since it was last persisted.
+func (sc *stuckCursor) callSave() error {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if !sc.dirty {
+		return nil
+	}
+	if err := persist.SaveJSON(stuckCursorMetadata, sc.entries, sc.staticPath); err != nil {
+		return errors.AddContext(err, "failed to save stuck cursor")
+	}
+	sc.dirty = false
+	return nil
+}
+
+// StuckCursor returns the recent stuck repair attempts the stuck loop uses to
+// bias its selection of stuck chunks.
+func (r *Renter) StuckCursor() ([]modules.StuckCursorEntry, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	return r.staticStuckCursor.callEntries(), nil
+}
@@ -0,0 +1,334 @@
+package renter
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/siatest/dependencies"
+)
+
+// TestStuckCursor is a unit test for the stuckCursor.
+func TestStuckCursor(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir("renter", t.Name())
+	if err := os.MkdirAll(dir, modules.DefaultDirPerm); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, stuckCursorFilename)
+	sc, err := newStuckCursor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sp := func(s string) modules.SiaPath {
+		siaPath, err := modules.NewSiaPath(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return siaPath
+	}
+	dirA, file1, file2, file3 := sp("a"), sp("a/1"), sp("a/2"), sp("3")
+
+	// Without entries all stuck chunks are untried.
+	if w := sc.callDirWeight(modules.RootSiaPath(), 10, true); w != 10*stuckCursorUntriedWeight {
+		t.Fatal("wrong weight", w)
+	}
+	if w := sc.callFileWeight(file1, 2); w != 2*stuckCursorUntriedWeight {
+		t.Fatal("wrong weight", w)
+	}
+
+	// Attempt file 1 and fail file 2. File 3 is repaired successfully without
+	// a prior attempt.
+	now := time.Now().Round(0)
+	sc.callRecordAttempt(file1, 2, now)
+	sc.callRecordAttempt(file2, 3, now)
+	sc.callRecordOutcome(file2, 3, false, now)
+	sc.callRecordOutcome(file3, 1, true, now)
+	if w := sc.callFileWeight(file1, 2); w != 2*stuckCursorTriedWeight {
+		t.Fatal("wrong weight", w)
+	}
+	if w := sc.callFileWeight(file2, 3); w != 3*stuckCursorFailedWeight {
+		t.Fatal("wrong weight", w)
+	}
+	if w := sc.callFileWeight(file3, 1); w != stuckCursorTriedWeight {
+		t.Fatal("wrong weight", w)
+	}
+
+	// Dir a has 10 stuck chunks, 2 of them tried and 3 of them failed.
+	expected := uint64(5*stuckCursorUntriedWeight + 2*stuckCursorTriedWeight + 3*stuckCursorFailedWeight)
+	if w := sc.callDirWeight(dirA, 10, true); w != expected {
+		t.Fatal("wrong weight", w, expected)
+	}
+	// The root's files only contain file 3.
+	if w := sc.callDirWeight(modules.RootSiaPath(), 1, false); w != stuckCursorTriedWeight {
+		t.Fatal("wrong weight", w)
+	}
+	// Outdated entries never reduce the weight below the failed weight.
+	if w := sc.callDirWeight(dirA, 1, true); w != stuckCursorTriedWeight {
+		t.Fatal("wrong weight", w)
+	}
+
+	// Check the entries.
+	entries := sc.callEntries()
+	if len(entries) != 3 {
+		t.Fatal("wrong number of entries", len(entries))
+	}
+	e := entries[1]
+	if !e.SiaPath.Equals(file2) || e.Attempts != 1 || e.Failures != 1 || e.Successes != 0 || e.LastOutcome != modules.StuckOutcomeFailure {
+		t.Fatal("wrong entry", e)
+	}
+	e = entries[2]
+	if !e.SiaPath.Equals(file3) || e.Attempts != 1 || e.Successes != 1 || e.LastOutcome != modules.StuckOutcomeSuccess {
+		t.Fatal("wrong entry", e)
+	}
+
+	// Another attempt of file 1 moves it to the end.
+	sc.callRecordAttempt(file1, 2, now)
+	entries = sc.callEntries()
+	if e := entries[2]; !e.SiaPath.Equals(file1) || e.Attempts != 2 || e.LastOutcome != modules.StuckOutcomePending {
+		t.Fatal("wrong entry", e)
+	}
+
+	// Save and reload the cursor.
+	if err := sc.callSave(); err != nil {
+		t.Fatal(err)
+	}
+	sc2, err := newStuckCursor(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(sc2.callEntries()) != fmt.Sprint(sc.callEntries()) {
+		t.Fatal("entries don't match after reload", sc2.callEntries(), sc.callEntries())
+	}
+
+	// Fill the cursor. The oldest entries should be pruned.
+	for i := 0; i < maxStuckCursorEntries; i++ {
+		sc.callRecordAttempt(sp(fmt.Sprint("b/", i)), 1, now)
+	}
+	entries = sc.callEntries()
+	if len(entries) != maxStuckCursorEntries {
+		t.Fatal("wrong number of entries", len(entries))
+	}
+	if !entries[0].SiaPath.Equals(sp("b/0")) {
+		t.Fatal("wrong first entry", entries[0])
+	}
+}
+
+// TestStuckCursorRestarts restarts the renter repeatedly while selecting
+// random stuck files and checks that the stuck cursor covers more stuck files
+// than a memoryless random selection.
+func TestStuckCursorRestarts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create 4 directories with 25 files each. Every file has a single stuck
+	// chunk.
+	numDirs, filesPerDir := 4, 25
+	numFiles := numDirs * filesPerDir
+	rsc, _ := modules.NewRSCode(1, 1)
+	var dirs []modules.SiaPath
+	for i := 0; i < numDirs; i++ {
+		dir, err := modules.NewSiaPath(fmt.Sprint("dir", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs = append(dirs, dir)
+		for j := 0; j < filesPerDir; j++ {
+			siaPath, err := dir.Join(fmt.Sprint("file", j))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = rt.renter.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 100, persist.DefaultDiskPermissionsTest, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := rt.renter.SetFileStuck(siaPath, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := rt.bubbleAll(dirs); err != nil {
+		t.Fatal(err)
+	}
+	// Wait for all the bubbles to finish to make sure that no bubble is
+	// interrupted by restarting the renter.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		bs := rt.renter.staticBubbleScheduler
+		bs.mu.Lock()
+		pending := len(bs.bubbleUpdates)
+		bs.mu.Unlock()
+		if pending > 0 {
+			return fmt.Errorf("%v bubbles pending", pending)
+		}
+		metadata, err := rt.renter.managedDirectoryMetadata(modules.RootSiaPath())
+		if err != nil {
+			return err
+		}
+		if metadata.AggregateNumStuckChunks != uint64(numFiles) {
+			_ = rt.renter.staticBubbleScheduler.callQueueBubble(modules.RootSiaPath())
+			return fmt.Errorf("expected %v stuck chunks but got %v", numFiles, metadata.AggregateNumStuckChunks)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pick selects a random stuck file the same way the stuck loop does and
+	// records a failed repair attempt for it.
+	pick := func(r *Renter) modules.SiaPath {
+		dir, err := r.managedStuckDirectory()
+		if err != nil {
+			t.Fatal(err)
+		}
+		siaPath, err := r.managedStuckFile(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.staticStuckCursor.callRecordAttempt(siaPath, 1, time.Now())
+		r.staticStuckCursor.callRecordOutcome(siaPath, 1, false, time.Now())
+		return siaPath
+	}
+
+	// Select as many files as there are stuck files. Restart the renter after
+	// every few selections.
+	numSessions, picksPerSession := 10, numFiles/10
+	covered := make(map[modules.SiaPath]struct{})
+	r := rt.renter
+	for i := 0; i < numSessions; i++ {
+		for j := 0; j < picksPerSession; j++ {
+			covered[pick(r)] = struct{}{}
+		}
+		r, err = rt.reloadRenter(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The attempts should have survived the restart.
+		entries, err := r.StuckCursor()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(covered) {
+			t.Fatalf("expected %v entries after restart but got %v", len(covered), len(entries))
+		}
+	}
+
+	// Do the same without a cursor. Clearing the cursor before every
+	// selection results in the same selection the renter made before it
+	// remembered its attempts. Average the coverage over multiple runs to
+	// reduce the variance.
+	numRuns := 5
+	var totalMemoryless int
+	for run := 0; run < numRuns; run++ {
+		coveredMemoryless := make(map[modules.SiaPath]struct{})
+		for i := 0; i < numSessions*picksPerSession; i++ {
+			r.staticStuckCursor.mu.Lock()
+			r.staticStuckCursor.entries = nil
+			r.staticStuckCursor.mu.Unlock()
+			coveredMemoryless[pick(r)] = struct{}{}
+		}
+		totalMemoryless += len(coveredMemoryless)
+	}
+	avgMemoryless := float64(totalMemoryless) / float64(numRuns)
+
+	t.Logf("covered %v of %v stuck files with the cursor and %.1f without", len(covered), numFiles, avgMemoryless)
+	if float64(len(covered)) <= avgMemoryless {
+		t.Fatal("cursor didn't improve the coverage")
+	}
+}
+
+// TestStuckFileOutdatedCursor checks that managedStuckFile selects the stuck
+// files of a directory according to their weights even if the weight of the
+// directory doesn't match the weights of its files.
+func TestStuckFileOutdatedCursor(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a directory with 2 files with a single stuck chunk each.
+	dir, err := modules.NewSiaPath("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []modules.SiaPath
+	rsc, _ := modules.NewRSCode(1, 1)
+	for i := 0; i < 2; i++ {
+		siaPath, err := dir.Join(fmt.Sprint("file", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 100, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.SetFileStuck(siaPath, true); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, siaPath)
+	}
+	if err := rt.bubble(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Record a failed attempt for the first file without any stuck chunks.
+	// The entry doesn't reduce the weight of the directory but it reduces
+	// the weight of the file.
+	r.staticStuckCursor.callRecordOutcome(files[0], 0, false, time.Now())
+	if w := r.staticStuckCursor.callDirWeight(dir, 2, false); w != 2*stuckCursorUntriedWeight {
+		t.Fatal("wrong dir weight", w)
+	}
+
+	// The first file should be selected according to its share of the
+	// weights of the files. If the selection was based on the weight of the
+	// directory, the leftover weight would select the last file.
+	numPicks := 1000
+	var picked int
+	for i := 0; i < numPicks; i++ {
+		siaPath, err := r.managedStuckFile(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if siaPath.Equals(files[0]) {
+			picked++
+		}
+	}
+	expected := numPicks * stuckCursorFailedWeight / (stuckCursorFailedWeight + stuckCursorUntriedWeight)
+	if picked < expected*4/5 || picked > expected*6/5 {
+		t.Fatalf("expected the first file to be picked about %v times but was %v", expected, picked)
+	}
+}
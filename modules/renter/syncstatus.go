@@ -0,0 +1,108 @@
+package renter
+
+import (
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// syncStatusSampleInterval is the minimum amount of time between two
+	// samples of the sync progress.
+	syncStatusSampleInterval = 10 * time.Second
+
+	// syncStatusWindow is the amount of time over which the sync rate is
+	// computed.
+	syncStatusWindow = 10 * time.Minute
+)
+
+type (
+	// syncStatusTracker keeps track of the rate at which the consensus set is
+	// syncing to be able to estimate how long operations that are waiting for
+	// the consensus set to be synced will be blocked.
+	syncStatusTracker struct {
+		samples []syncSample
+		mu      sync.Mutex
+	}
+
+	// syncSample is the height of the consensus set at a certain point in
+	// time.
+	syncSample struct {
+		height    types.BlockHeight
+		timestamp time.Time
+	}
+)
+
+// newSyncStatusTracker creates a new syncStatusTracker.
+func newSyncStatusTracker() *syncStatusTracker {
+	return &syncStatusTracker{}
+}
+
+// callAddSample adds a sample of the consensus height to the tracker. Samples
+// that are taken too close to the previous sample are ignored and samples that
+// are outside of the window are pruned.
+func (sst *syncStatusTracker) callAddSample(height types.BlockHeight, now time.Time) {
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+
+	// Ignore samples that are too close to the previous one.
+	if n := len(sst.samples); n > 0 && now.Sub(sst.samples[n-1].timestamp) < syncStatusSampleInterval {
+		return
+	}
+	sst.samples = append(sst.samples, syncSample{
+		height:    height,
+		timestamp: now,
+	})
+
+	// Prune samples outside of the window but keep at least two samples
+	// around to be able to compute a rate.
+	for len(sst.samples) > 2 && now.Sub(sst.samples[0].timestamp) > syncStatusWindow {
+		sst.samples = sst.samples[1:]
+	}
+}
+
+// callStatus returns the sync status for the provided heights.
+func (sst *syncStatusTracker) callStatus(height, estimatedHeight types.BlockHeight, synced bool) modules.RenterSyncStatus {
+	status := modules.RenterSyncStatus{
+		Synced:          synced,
+		Height:          height,
+		EstimatedHeight: estimatedHeight,
+		Progress:        100,
+	}
+	if synced {
+		return status
+	}
+
+	// Compute the progress. The estimated height is only an estimate so we
+	// never report 100% if we are not synced.
+	if estimatedHeight > 0 {
+		status.Progress = float64(height) / float64(estimatedHeight) * 100
+	}
+	if status.Progress > 99.9 {
+		status.Progress = 99.9
+	}
+
+	// Compute the ETA from the sync rate within the window.
+	sst.mu.Lock()
+	defer sst.mu.Unlock()
+	if len(sst.samples) < 2 || estimatedHeight <= height {
+		return status
+	}
+	first, last := sst.samples[0], sst.samples[len(sst.samples)-1]
+	elapsed := last.timestamp.Sub(first.timestamp)
+	if last.height <= first.height || elapsed <= 0 {
+		return status
+	}
+	blocksPerSecond := float64(last.height-first.height) / elapsed.Seconds()
+	remaining := float64(estimatedHeight - height)
+	status.ETA = time.Duration(remaining / blocksPerSecond * float64(time.Second))
+	return status
+}
+
+// SyncStatus returns the progress of the consensus sync that some renter
+// operations are waiting for.
+func (r *Renter) SyncStatus() modules.RenterSyncStatus {
+	return r.staticSyncStatus.callStatus(r.cs.Height(), r.cs.EstimatedHeight(), r.cs.Synced())
+}
@@ -0,0 +1,57 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/types"
+)
+
+// TestSyncStatusTracker is a unit test for the syncStatusTracker.
+func TestSyncStatusTracker(t *testing.T) {
+	t.Parallel()
+
+	sst := newSyncStatusTracker()
+
+	// A synced consensus set is reported as such.
+	status := sst.callStatus(100, 100, true)
+	if !status.Synced || status.Progress != 100 || status.ETA != 0 {
+		t.Fatal("wrong status", status)
+	}
+
+	// Without samples there is no ETA.
+	status = sst.callStatus(50, 100, false)
+	if status.Synced || status.Progress != 50 || status.ETA != 0 {
+		t.Fatal("wrong status", status)
+	}
+
+	// The progress is capped at 99.9% while not synced.
+	status = sst.callStatus(100, 100, false)
+	if status.Progress != 99.9 {
+		t.Fatal("wrong progress", status.Progress)
+	}
+
+	// Add samples that are too close together. Only the first one should be
+	// kept.
+	now := time.Now()
+	sst.callAddSample(10, now)
+	sst.callAddSample(20, now.Add(syncStatusSampleInterval/2))
+	if len(sst.samples) != 1 {
+		t.Fatal("wrong number of samples", len(sst.samples))
+	}
+
+	// Sync 10 blocks per second for a minute.
+	for i := 1; i <= 6; i++ {
+		sst.callAddSample(types.BlockHeight(10+100*i), now.Add(time.Duration(i)*syncStatusSampleInterval))
+	}
+	status = sst.callStatus(610, 6610, false)
+	if status.ETA != 10*time.Minute {
+		t.Fatal("wrong eta", status.ETA)
+	}
+
+	// Samples outside of the window are pruned.
+	sst.callAddSample(1000, now.Add(2*syncStatusWindow))
+	if len(sst.samples) != 2 {
+		t.Fatal("wrong number of samples", len(sst.samples))
+	}
+}
@@ -10,6 +10,8 @@ package renter
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"os"
 
 	"gitlab.com/NebulousLabs/errors"
@@ -18,13 +20,74 @@ import (
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/types"
 )
 
 var (
 	// ErrUploadDirectory is returned if the user tries to upload a directory.
 	ErrUploadDirectory = errors.New("cannot upload directory")
+
+	// errNoUploadCostEstimate is returned if the upload cost can't be
+	// estimated because no worker has a valid price table.
+	errNoUploadCostEstimate = errors.New("no worker with a valid price table to estimate the upload cost")
 )
 
+// EstimateUploadCost estimates the cost of uploading a file of the given size
+// at the given redundancy using the default number of data pieces. The
+// estimate is based on the median cost of writing a sector across all workers
+// with a valid price table.
+func (r *Renter) EstimateUploadCost(size uint64, redundancy float64) (modules.RenterUploadCostEstimate, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterUploadCostEstimate{}, err
+	}
+	defer r.tg.Done()
+	if math.IsNaN(redundancy) || math.IsInf(redundancy, 0) || redundancy < 1 {
+		return modules.RenterUploadCostEstimate{}, fmt.Errorf("redundancy must be a finite number of at least 1 but was %v", redundancy)
+	}
+	costs := r.staticWorkerPool.callSectorWriteCosts()
+	if len(costs) == 0 {
+		return modules.RenterUploadCostEstimate{}, errNoUploadCostEstimate
+	}
+	return estimateUploadCost(costs, size, redundancy), nil
+}
+
+// estimateUploadCost estimates the cost of uploading a file of the given size
+// at the given redundancy from the provided sector write costs which are
+// expected to be sorted in ascending order. The confidence interval is ±20% of
+// the estimate. It is widened to the costs at the 20th and 80th percentile if
+// the costs vary more than that.
+func estimateUploadCost(costs []types.Currency, size uint64, redundancy float64) modules.RenterUploadCostEstimate {
+	// Compute the number of sectors to upload.
+	dataPieces := uint64(modules.RenterDefaultDataPieces)
+	numPieces := uint64(math.Ceil(float64(dataPieces) * redundancy))
+	chunkSize := dataPieces * modules.SectorSize
+	numChunks := size / chunkSize
+	if size%chunkSize != 0 {
+		numChunks++
+	}
+	numSectors := numChunks * numPieces
+
+	// Compute the estimate from the median.
+	median := costs[len(costs)/2]
+	if len(costs)%2 == 0 {
+		median = median.Add(costs[len(costs)/2-1]).Div64(2)
+	}
+	estimate := median.Mul64(numSectors)
+	min := estimate.MulRat(big.NewRat(4, 5))
+	max := estimate.MulRat(big.NewRat(6, 5))
+	if low := costs[len(costs)/5].Mul64(numSectors); low.Cmp(min) < 0 {
+		min = low
+	}
+	if high := costs[(len(costs)*4)/5].Mul64(numSectors); high.Cmp(max) > 0 {
+		max = high
+	}
+	return modules.RenterUploadCostEstimate{
+		Estimate: estimate,
+		Min:      min,
+		Max:      max,
+	}
+}
+
 // Upload instructs the renter to start tracking a file. The renter will
 // automatically upload and repair tracked files using a background loop.
 func (r *Renter) Upload(up modules.FileUploadParams) error {
@@ -106,6 +169,14 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 		return nil
 	}
 
+	// Stage the source on local disk in the background if upload staging is
+	// enabled. Until the staged copy is complete, the chunks are read from the
+	// source directly.
+	uid, source, size := entry.UID(), up.Source, uint64(sourceInfo.Size())
+	_ = r.tg.Launch(func() {
+		r.managedStageUpload(uid, source, size)
+	})
+
 	// Bubble the health of the SiaFile directory to ensure the health is
 	// updated with the new file
 	//
@@ -113,6 +184,16 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	// not want to block on this update.
 	_ = r.staticBubbleScheduler.callQueueBubble(dirSiaPath)
 
+	// If the renter is offline, queue the upload until connectivity returns
+	// instead of pushing its chunks to the upload heap.
+	if !r.g.Online() {
+		err = entry.Close()
+		if err != nil {
+			return errors.AddContext(err, "unable to close the new sia file")
+		}
+		return r.managedQueueOfflineUpload(up.SiaPath)
+	}
+
 	// Create nil maps for offline and goodForRenew to pass in to
 	// callBuildAndPushChunks. These maps are used to determine the health of
 	// the file and its chunks. Nil maps will result in the file and its chunks
@@ -128,3 +209,72 @@ func (r *Renter) Upload(up modules.FileUploadParams) error {
 	}
 	return nil
 }
+
+// managedQueueOfflineUpload adds a file that was uploaded while the renter was
+// offline to the set of offline uploads. Queueing the first upload launches a
+// thread that pushes the chunks of all the queued uploads to the upload heap
+// once the renter is online again.
+func (r *Renter) managedQueueOfflineUpload(siaPath modules.SiaPath) error {
+	r.uploadHeap.mu.Lock()
+	launch := len(r.uploadHeap.offlineUploads) == 0
+	r.uploadHeap.offlineUploads[siaPath] = struct{}{}
+	r.uploadHeap.mu.Unlock()
+	if !launch {
+		return nil
+	}
+	return r.tg.Launch(r.threadedUploadOfflineUploads)
+}
+
+// managedOfflineUploads returns a copy of the set of files that are waiting for
+// the renter to come online before their upload starts.
+func (r *Renter) managedOfflineUploads() map[modules.SiaPath]struct{} {
+	r.uploadHeap.mu.Lock()
+	defer r.uploadHeap.mu.Unlock()
+	offlineUploads := make(map[modules.SiaPath]struct{}, len(r.uploadHeap.offlineUploads))
+	for siaPath := range r.uploadHeap.offlineUploads {
+		offlineUploads[siaPath] = struct{}{}
+	}
+	return offlineUploads
+}
+
+// managedIsOfflineUpload returns whether the file at siaPath is waiting for the
+// renter to come online before its upload starts.
+func (r *Renter) managedIsOfflineUpload(siaPath modules.SiaPath) bool {
+	r.uploadHeap.mu.Lock()
+	defer r.uploadHeap.mu.Unlock()
+	_, exists := r.uploadHeap.offlineUploads[siaPath]
+	return exists
+}
+
+// threadedUploadOfflineUploads blocks until the renter is online and then
+// pushes the chunks of the files that were uploaded while the renter was
+// offline to the upload heap.
+func (r *Renter) threadedUploadOfflineUploads() {
+	if !r.managedBlockUntilOnline() {
+		return
+	}
+
+	// Grab the queued uploads and reset the set.
+	r.uploadHeap.mu.Lock()
+	offlineUploads := r.uploadHeap.offlineUploads
+	r.uploadHeap.offlineUploads = make(map[modules.SiaPath]struct{})
+	r.uploadHeap.mu.Unlock()
+
+	// Push the chunks of the queued files to the upload heap. Files are pushed
+	// one at a time since callBuildAndPushChunks expects all the files to be
+	// from the same directory.
+	nilMap := make(map[string]bool)
+	hosts := r.managedRefreshHostsAndWorkers()
+	for siaPath := range offlineUploads {
+		entry, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err != nil {
+			r.log.Printf("WARN: unable to open file %v that was uploaded while offline: %v", siaPath, err)
+			continue
+		}
+		r.callBuildAndPushChunks([]*filesystem.FileNode{entry}, hosts, targetUnstuckChunks, nilMap, nilMap)
+	}
+	select {
+	case r.uploadHeap.newUploads <- struct{}{}:
+	default:
+	}
+}
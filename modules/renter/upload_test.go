@@ -1,14 +1,35 @@
 package renter
 
 import (
+	"fmt"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
 )
 
+// offlineGateway is a gateway that can be switched offline.
+type offlineGateway struct {
+	atomicOffline uint64
+	modules.Gateway
+}
+
+// Online returns false if the gateway was switched offline.
+func (g *offlineGateway) Online() bool {
+	return atomic.LoadUint64(&g.atomicOffline) == 0 && g.Gateway.Online()
+}
+
 // TestRenterUploadDirectory verifies that the renter returns an error if a
 // directory is provided as the source of an upload.
 func TestRenterUploadDirectory(t *testing.T) {
@@ -48,3 +69,241 @@ func TestRenterUploadDirectory(t *testing.T) {
 		t.Fatal("expected ErrUploadDirectory, got", err)
 	}
 }
+
+// TestRenterUploadOffline verifies that an upload started while the renter is
+// offline is queued and completes once the renter is online again.
+func TestRenterUploadOffline(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a renter with a gateway that can be switched offline.
+	testDir := build.TempDir("renter", t.Name())
+	rt, err := newRenterTesterNoRenter(testDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &offlineGateway{Gateway: rt.gateway}
+	r, err := newRenterWithDependency(g, rt.cs, rt.wallet, rt.tpool, rt.mux, filepath.Join(testDir, modules.RenterDir), modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.addRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Form a contract with a host and wait for its worker.
+	err = r.hostContractor.SetAllowance(modules.DefaultAllowance)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := rt.addHost(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		_, err := rt.miner.AddBlock()
+		if err != nil {
+			return err
+		}
+		r.staticWorkerPool.callUpdate()
+		if len(r.staticWorkerPool.callWorkers()) != 1 {
+			return errors.New("worker not found")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a file on disk.
+	source := filepath.Join(testDir, "source")
+	err = ioutil.WriteFile(source, fastrand.Bytes(100), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Switch the gateway offline and upload the file.
+	atomic.StoreUint64(&g.atomicOffline, 1)
+	rsc, _ := modules.NewRSCode(1, 1)
+	up := modules.FileUploadParams{
+		Source:      source,
+		SiaPath:     modules.RandomSiaPath(),
+		ErasureCode: rsc,
+	}
+	err = r.Upload(up)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The upload should be queued.
+	fi, err := r.File(up.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !fi.QueuedOffline {
+		t.Fatal("file should be queued")
+	}
+	var listed bool
+	err = r.FileList(modules.RootSiaPath(), true, true, func(fi modules.FileInfo) {
+		listed = listed || (fi.SiaPath.Equals(up.SiaPath) && fi.QueuedOffline)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !listed {
+		t.Fatal("file should be listed as queued")
+	}
+	if r.uploadHeap.managedLen() != 0 {
+		t.Fatal("chunks shouldn't be pushed while offline", r.uploadHeap.managedLen())
+	}
+
+	// Switch the gateway back online. The upload should complete.
+	atomic.StoreUint64(&g.atomicOffline, 0)
+	err = build.Retry(600, 100*time.Millisecond, func() error {
+		fi, err := r.File(up.SiaPath)
+		if err != nil {
+			return err
+		}
+		if fi.QueuedOffline {
+			return errors.New("file still queued")
+		}
+		if fi.Redundancy < 1 {
+			return fmt.Errorf("redundancy should be at least 1 but was %v", fi.Redundancy)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestEstimateUploadCost tests estimating the cost of uploading a file.
+func TestEstimateUploadCost(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Without any price tables there is no estimate.
+	_, err = r.EstimateUploadCost(modules.SectorSize, 3)
+	if !errors.Contains(err, errNoUploadCostEstimate) {
+		t.Fatal("expected errNoUploadCostEstimate but got", err)
+	}
+
+	// Add workers with valid price tables. The write costs of the workers
+	// are 1, 2 and 6 SC.
+	var costs []types.Currency
+	for i, sc := range []uint64{1, 2, 6} {
+		pt := modules.RPCPriceTable{
+			WriteBaseCost: types.SiacoinPrecision.Mul64(sc),
+		}
+		w := &worker{staticHostPubKeyStr: fmt.Sprint("worker", i)}
+		w.staticSetPriceTable(&workerPriceTable{
+			staticPriceTable: pt,
+			staticExpiryTime: time.Now().Add(time.Hour),
+		})
+		r.staticWorkerPool.mu.Lock()
+		r.staticWorkerPool.workers[w.staticHostPubKeyStr] = w
+		r.staticWorkerPool.mu.Unlock()
+		costs = append(costs, modules.MDMWriteCost(&pt, modules.SectorSize))
+	}
+
+	// Invalid redundancy.
+	_, err = r.EstimateUploadCost(modules.SectorSize, 0.5)
+	if err == nil {
+		t.Fatal("expected error for redundancy < 1")
+	}
+	for _, redundancy := range []float64{math.NaN(), math.Inf(1), math.Inf(-1)} {
+		_, err = r.EstimateUploadCost(modules.SectorSize, redundancy)
+		if err == nil {
+			t.Fatalf("expected error for redundancy %v", redundancy)
+		}
+	}
+
+	// A file with a single chunk at a redundancy of 2 requires twice the
+	// number of data pieces in sectors. The estimate should be based on the
+	// median and the interval on the variance of the workers' prices.
+	chunkSize := uint64(modules.RenterDefaultDataPieces) * modules.SectorSize
+	numSectors := uint64(2 * modules.RenterDefaultDataPieces)
+	e, err := r.EstimateUploadCost(chunkSize, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := costs[1].Mul64(numSectors); !e.Estimate.Equals(expected) {
+		t.Fatalf("expected estimate %v but got %v", expected, e.Estimate)
+	}
+	if expected := costs[0].Mul64(numSectors); !e.Min.Equals(expected) {
+		t.Fatalf("expected min %v but got %v", expected, e.Min)
+	}
+	if expected := costs[2].Mul64(numSectors); !e.Max.Equals(expected) {
+		t.Fatalf("expected max %v but got %v", expected, e.Max)
+	}
+
+	// Without variance, the interval is ±20%.
+	same := []types.Currency{costs[1], costs[1], costs[1]}
+	e = estimateUploadCost(same, chunkSize, 2)
+	if expected := e.Estimate.MulRat(big.NewRat(4, 5)); !e.Min.Equals(expected) {
+		t.Fatalf("expected min %v but got %v", expected, e.Min)
+	}
+	if expected := e.Estimate.MulRat(big.NewRat(6, 5)); !e.Max.Equals(expected) {
+		t.Fatalf("expected max %v but got %v", expected, e.Max)
+	}
+
+	// The estimate should scale monotonically with the size.
+	prev, err := r.EstimateUploadCost(0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !prev.Estimate.IsZero() {
+		t.Fatal("expected zero estimate for an empty file", prev.Estimate)
+	}
+	for size := uint64(1); size <= 10*chunkSize; size += chunkSize / 2 {
+		e, err := r.EstimateUploadCost(size, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if e.Estimate.Cmp(prev.Estimate) < 0 || e.Min.Cmp(prev.Min) < 0 || e.Max.Cmp(prev.Max) < 0 {
+			t.Fatal("estimate decreased with a larger size", e, prev)
+		}
+		if e.Min.Cmp(e.Estimate) > 0 || e.Max.Cmp(e.Estimate) < 0 {
+			t.Fatal("estimate outside of its interval", e)
+		}
+		prev = e
+	}
+	// A file twice the size should cost twice as much.
+	e1, err := r.EstimateUploadCost(chunkSize, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e2, err := r.EstimateUploadCost(2*chunkSize, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e2.Estimate.Equals(e1.Estimate.Mul64(2)) {
+		t.Fatal("estimate didn't scale linearly", e1.Estimate, e2.Estimate)
+	}
+}
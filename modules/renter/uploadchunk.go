@@ -13,8 +13,13 @@ import (
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/filesystem"
 	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/persist"
 )
 
+// errChunkEntryAlreadyClosed is returned when closing the file entry of a chunk
+// that was already closed.
+var errChunkEntryAlreadyClosed = errors.New("file entry of chunk was already closed")
+
 // uploadChunkID is a unique identifier for each chunk in the renter.
 type uploadChunkID struct {
 	fileUID siafile.SiafileUID // Unique to each file.
@@ -41,6 +46,20 @@ type unfinishedUploadChunk struct {
 	staticPiecesNeeded     int    // number of pieces to achieve a 100% complete upload
 	stuck                  bool   // indicates if the chunk was marked as stuck during last repair
 	stuckRepair            bool   // indicates if the chunk was identified for repair by the stuck loop
+	repairAttempts         int    // number of consecutive failed repair attempts of the chunk
+	staticRepair           bool   // indicates if the chunk already had pieces on the network when it was built
+	recoveryMode           bool   // indicates if the chunk is repaired because a host returned data that failed verification
+
+	// staticConversion indicates that the chunk belongs to the siafile of an
+	// erasure code conversion. The logical data of such a chunk is fetched
+	// from the original file at staticConversionSource.
+	staticConversion       bool
+	staticConversionSource modules.SiaPath
+
+	// staticBadHosts contains the hosts which returned data for the chunk that
+	// failed verification. Their pieces don't count towards the redundancy of
+	// the chunk and they are not used to fetch the data for the repair.
+	staticBadHosts map[string]struct{}
 
 	staticMemoryManager *memoryManager
 
@@ -70,6 +89,7 @@ type unfinishedUploadChunk struct {
 
 	// Performance information.
 	chunkCreationTime        time.Time
+	enqueuedAt               time.Time // time the chunk was pushed onto the upload heap
 	chunkPoppedFromHeapTime  time.Time
 	chunkDistributionTime    time.Time
 	chunkFailedProcessTimes  []time.Time
@@ -110,6 +130,7 @@ type unfinishedUploadChunk struct {
 	//	+ the worker should release the memory for the completed piece
 	err              error
 	mu               sync.Mutex
+	entryClosed      bool                // whether the fileEntry of the chunk was closed.
 	pieceUsage       []bool              // 'true' if a piece is either uploaded, or a worker is attempting to upload that piece.
 	piecesCompleted  int                 // number of pieces that have been fully uploaded.
 	piecesRegistered int                 // number of pieces that are being uploaded, but aren't finished yet (may fail).
@@ -123,29 +144,54 @@ type unfinishedUploadChunk struct {
 	cancelWG sync.WaitGroup // WaitGroup to wait on after canceling the uploadchunk.
 }
 
+// managedClose closes the fileEntry of the chunk. Errors are extended with the
+// siafile and the chunk they belong to and logged to the provided logger if it
+// is not nil. Closing the fileEntry of a chunk more than once returns an error
+// instead of closing the fileEntry again.
+func (uc *unfinishedUploadChunk) managedClose(log *persist.Logger) error {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	return uc.closeEntry(log)
+}
+
+// closeEntry closes the fileEntry of the chunk. See managedClose.
+func (uc *unfinishedUploadChunk) closeEntry(log *persist.Logger) error {
+	var err error
+	if uc.entryClosed {
+		err = errChunkEntryAlreadyClosed
+	} else {
+		uc.entryClosed = true
+		err = uc.fileEntry.Close()
+	}
+	if err == nil {
+		return nil
+	}
+	err = errors.AddContext(err, fmt.Sprintf("unable to close file entry of %v for chunk %v", uc.staticSiaPath, uc.staticIndex))
+	if log != nil {
+		log.Println("WARN:", err)
+	}
+	return err
+}
+
 // managedSetStuckAndClose sets the unfinishedUploadChunk's stuck status and
-// closes the fileEntry.
-func (uc *unfinishedUploadChunk) managedSetStuckAndClose(setStuck bool) error {
+// closes the fileEntry. The errors of both operations are returned separately.
+func (uc *unfinishedUploadChunk) managedSetStuckAndClose(setStuck bool) (errStuck, errClose error) {
 	uc.mu.Lock()
 	defer uc.mu.Unlock()
 
 	// Update chunk stuck status and close file.
-	var errStuck error
 	if setStuck {
 		errStuck = uc.fileEntry.SetStuck(uc.staticIndex, uc.stuck)
+		if errStuck != nil {
+			errStuck = errors.AddContext(errStuck, fmt.Sprintf("unable to update stuck status of chunk %v of %v", uc.staticIndex, uc.staticSiaPath))
+		}
 	}
-	errClose := uc.fileEntry.Close()
+	errClose = uc.closeEntry(nil)
 
 	// Signal garbage collector to free memory.
 	uc.physicalChunkData = nil
 	uc.logicalChunkData = nil
-
-	// Return potential errors.
-	err := errors.Compose(errStuck, errClose)
-	if err != nil {
-		return fmt.Errorf("WARN: unable to update chunk stuck status for file and close it %v: %v", uc.fileEntry.SiaFilePath(), err)
-	}
-	return nil
+	return errStuck, errClose
 }
 
 // staticAvailable returns whether or not the chunk is available yet on the Sia
@@ -263,6 +309,12 @@ func padAndEncryptPiece(chunkIndex, pieceIndex uint64, logicalChunkData [][]byte
 // download to the renter's downloader, and then using the data that gets
 // returned.
 func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedUploadChunk) error {
+	// The pieces of a conversion chunk don't exist yet, its data is downloaded
+	// from the original file instead.
+	if chunk.staticConversion {
+		return r.managedDownloadConversionChunkData(chunk)
+	}
+
 	//  Determine what the download length should be. Normally it is just the
 	//  chunk size, but if this is the last chunk we need to download less
 	//  because the file is not that large.
@@ -298,6 +350,7 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedUploadChunk) e
 		priority:      0, // Repair downloads are completely de-prioritized.
 
 		staticMemoryManager:    chunk.staticMemoryManager, // Same memory manager as upload chunk
+		staticExcludedHosts:    chunk.staticBadHosts,
 		staticSpendingCategory: categoryRepairDownload,
 	})
 	if err != nil {
@@ -355,12 +408,16 @@ func (r *Renter) managedDownloadLogicalChunkData(chunk *unfinishedUploadChunk) e
 // threadedFetchAndRepairChunk will fetch the logical data for a chunk, create
 // the physical pieces for the chunk, and then distribute them.
 func (r *Renter) threadedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
+	defer r.staticRepairLimiter.callRelease()
 	err := r.tg.Add()
 	if err != nil {
 		return
 	}
 	defer r.tg.Done()
 
+	// Delay the repair for testing.
+	r.deps.Disrupt("slowFetchAndRepair")
+
 	// Calculate the amount of memory needed for erasure coding. This will need
 	// to be released if there's an error before erasure coding is complete.
 	erasureCodingMemory := chunk.fileEntry.PieceSize() * uint64(chunk.fileEntry.ErasureCode().MinPieces())
@@ -375,7 +432,9 @@ func (r *Renter) threadedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
 		}
 	}
 
-	// Fetch the logical data for the chunk.
+	// Fetch the logical data for the chunk. If the data of a chunk in recovery
+	// mode needs to be downloaded, the hosts which returned bad data for the
+	// chunk are excluded.
 	err = r.managedFetchLogicalChunkData(chunk)
 	if err != nil {
 		// Return the erasure coding memory. This is not handled by the cleanup
@@ -411,7 +470,7 @@ func (r *Renter) threadedFetchAndRepairChunk(chunk *unfinishedUploadChunk) {
 
 		// Mark chunk as stuck because the renter was unable to fetch the
 		// logical data.
-		err = chunk.fileEntry.SetStuck(chunk.staticIndex, true)
+		err = r.managedSetChunkStuck(chunk.fileEntry, chunk.staticIndex, true)
 		if err != nil {
 			r.repairLog.Printf("Error marking chunk %v of file %s as stuck: %v", chunk.staticIndex, chunk.staticSiaPath, err)
 		}
@@ -549,6 +608,24 @@ func (r *Renter) managedFetchLogicalChunkData(uc *unfinishedUploadChunk) error {
 		return nil
 	}
 
+	// Prefer the staged copy of the source if there is one.
+	if stagedPath, ok := r.staticUploadStaging.callStagedPath(uc.fileEntry.UID()); ok {
+		err := func() (err error) {
+			f, err := os.Open(stagedPath)
+			if err != nil {
+				return errors.AddContext(err, "unable to open staged file")
+			}
+			defer func() {
+				err = errors.Compose(err, f.Close())
+			}()
+			return uc.staticReadLogicalDataFromFile(f)
+		}()
+		if err == nil {
+			return nil
+		}
+		r.log.Printf("falling back to source for repair: fetch from staged file %v failed: %v", stagedPath, err)
+	}
+
 	// No source reader available. Check if there's potentially a local file. If
 	// there is no local file, fall back to doing a remote repair.
 	// disk.
@@ -574,17 +651,7 @@ func (r *Renter) managedFetchLogicalChunkData(uc *unfinishedUploadChunk) error {
 		defer func() {
 			err = errors.Compose(err, osFile.Close())
 		}()
-		sr := io.NewSectionReader(osFile, uc.offset, int64(uc.length))
-		dataPieces, _, err := readDataPieces(sr, uc.fileEntry.ErasureCode(), uc.fileEntry.PieceSize())
-		if err != nil {
-			return errors.AddContext(err, "unable to read the data from the local file")
-		}
-		uc.logicalChunkData, _ = uc.fileEntry.ErasureCode().EncodeShards(dataPieces)
-		err = uc.staticEncryptAndCheckIntegrity()
-		if err != nil {
-			return errors.AddContext(err, "local file failed the integrity check")
-		}
-		return nil
+		return uc.staticReadLogicalDataFromFile(osFile)
 	}()
 	if err != nil {
 		r.log.Printf("falling back to remote download for repair: fetch from local file %v failed: %v", uc.fileEntry.LocalPath(), err)
@@ -593,6 +660,22 @@ func (r *Renter) managedFetchLogicalChunkData(uc *unfinishedUploadChunk) error {
 	return nil
 }
 
+// staticReadLogicalDataFromFile reads the chunk's data from a local copy of the
+// file, erasure codes it and checks its integrity.
+func (uc *unfinishedUploadChunk) staticReadLogicalDataFromFile(f io.ReaderAt) error {
+	sr := io.NewSectionReader(f, uc.offset, int64(uc.length))
+	dataPieces, _, err := readDataPieces(sr, uc.fileEntry.ErasureCode(), uc.fileEntry.PieceSize())
+	if err != nil {
+		return errors.AddContext(err, "unable to read the data from the local file")
+	}
+	uc.logicalChunkData, _ = uc.fileEntry.ErasureCode().EncodeShards(dataPieces)
+	err = uc.staticEncryptAndCheckIntegrity()
+	if err != nil {
+		return errors.AddContext(err, "local file failed the integrity check")
+	}
+	return nil
+}
+
 // managedCleanUpUploadChunk will check the state of the chunk and perform any
 // cleanup required. This can include returning reserved memory and releasing
 // the chunk from the map of active chunks in the chunk heap.
@@ -698,12 +781,10 @@ func (r *Renter) managedCleanUpUploadChunk(uc *unfinishedUploadChunk) {
 			r.log.Print("managedCleanUpUploadChunk: failed to update file metadata", err)
 		}
 
-		// Close the file entry for the completed chunk unless disrupted.
+		// Close the file entry for the completed chunk unless disrupted. The
+		// error is logged by managedCloseEntry.
 		if !r.deps.Disrupt("disableCloseUploadEntry") {
-			err := uc.fileEntry.Close()
-			if err != nil {
-				r.log.Println("WARN: unable to close file entry for chunk", uc.fileEntry.SiaFilePath())
-			}
+			_ = r.managedCloseEntry(uc)
 		}
 		// Remove the chunk from the repairingChunks map
 		r.uploadHeap.managedMarkRepairDone(uc)
@@ -717,10 +798,7 @@ func (r *Renter) managedCleanUpUploadChunk(uc *unfinishedUploadChunk) {
 	}
 	// Make sure file is closed for canceled chunks when all workers are done
 	if canceled && workersRemaining == 0 && !chunkComplete {
-		err := uc.fileEntry.Close()
-		if err != nil {
-			r.log.Println("WARN: unable to close file entry for chunk", uc.fileEntry.SiaFilePath())
-		}
+		_ = r.managedCloseEntry(uc)
 	}
 	// Sanity check - all memory should be released if the chunk is complete.
 	if chunkComplete && totalMemoryReleased != uc.staticMemoryNeeded {
@@ -738,7 +816,18 @@ func (r *Renter) managedSetStuckAndClose(uc *unfinishedUploadChunk, setStuck boo
 		uc.mu.Unlock()
 	}
 
-	return uc.managedSetStuckAndClose(setStuck)
+	errStuck, errClose := uc.managedSetStuckAndClose(setStuck)
+	if errClose != nil {
+		r.managedTrackCloses(0, 1)
+	} else {
+		r.managedTrackCloses(1, 0)
+	}
+	err := errors.Compose(errStuck, errClose)
+	if setStuck {
+		r.staticUnfinishedChunkCache.callRemove(uc.id)
+		r.staticRepairMetrics.callAdd(time.Now(), modules.RepairMetrics{ChunksStuck: 1})
+	}
+	return err
 }
 
 // managedUpdateUploadChunkStuckStatus checks to see if the repair was
@@ -778,16 +867,67 @@ func (r *Renter) managedUpdateUploadChunkStuckStatus(uc *unfinishedUploadChunk)
 	// Log if the repair was unsuccessful
 	if !successfulRepair {
 		r.log.Debugln("WARN: repair unsuccessful, marking chunk", uc.id, "as stuck", float64(piecesCompleted)/float64(piecesNeeded))
+		r.staticRepairMetrics.callAdd(time.Now(), modules.RepairMetrics{ChunksStuck: 1})
 	} else {
 		r.log.Debugln("SUCCESS: repair successful, marking chunk as non-stuck:", uc.id)
+		r.staticRepairMetrics.callAdd(time.Now(), modules.RepairMetrics{ChunksRepaired: 1})
 	}
 	// Update chunk stuck status unless the dependency to skip this step is
-	// enabled.
-	if !r.deps.Disrupt("DontUpdateChunkStatus") {
-		if err := uc.fileEntry.SetStuck(index, !successfulRepair); err != nil {
+	// enabled. Every failed repair counts towards exactly one counter. A failed
+	// repair of the stuck loop counts towards the chunk's stuck repair attempts
+	// and might cause the chunk to be abandoned. A failed repair of the repair
+	// loop counts towards the chunk's repair failures which determine its
+	// repair backoff.
+	updateStatus := !r.deps.Disrupt("DontUpdateChunkStatus")
+	if updateStatus && !successfulRepair && stuckRepair {
+		maxStuckAttempts := r.managedMaxStuckRepairAttempts()
+		abandoned, err := uc.fileEntry.MarkStuckRepairFailed(index, maxStuckAttempts)
+		r.staticUnfinishedChunkCache.callRemove(uc.id)
+		if err != nil {
+			r.log.Printf("WARN: could not mark stuck repair of chunk %v as failed for file %v: %v", uc.id, uc.fileEntry.SiaFilePath(), err)
+		}
+		if abandoned {
+			r.log.Printf("WARN: chunk %v of file %v was abandoned after %v failed stuck repairs", uc.id, uc.fileEntry.SiaFilePath(), maxStuckAttempts)
+		}
+	} else if updateStatus {
+		if err := r.managedSetChunkStuck(uc.fileEntry, index, !successfulRepair); err != nil {
 			r.log.Printf("WARN: could not set chunk %v stuck status for file %v: %v", uc.id, uc.fileEntry.SiaFilePath(), err)
 		}
 	}
+	// Update the chunk's repair failures which determine its repair backoff.
+	if updateStatus && !successfulRepair && !stuckRepair {
+		uc.mu.Lock()
+		uc.repairAttempts++
+		uc.mu.Unlock()
+		if err := uc.fileEntry.MarkRepairFailed(index); err != nil {
+			r.log.Printf("WARN: could not mark repair of chunk %v as failed for file %v: %v", uc.id, uc.fileEntry.SiaFilePath(), err)
+		}
+	} else if updateStatus && successfulRepair {
+		if err := uc.fileEntry.ResetRepairFailures(index); err != nil {
+			r.log.Printf("WARN: could not reset repair failures of chunk %v for file %v: %v", uc.id, uc.fileEntry.SiaFilePath(), err)
+		}
+	}
+
+	// The chunk's metadata was updated which means that the cached metadata
+	// is outdated.
+	if updateStatus {
+		r.staticUnfinishedChunkCache.callRemove(uc.id)
+	}
+
+	// Remember the outcome of the stuck repair for the stuck loop's random
+	// selection.
+	if stuckRepair {
+		siaPath := r.staticFileSystem.FileSiaPath(uc.fileEntry)
+		r.staticStuckCursor.callRecordOutcome(siaPath, uc.fileEntry.NumStuckChunks(), successfulRepair, time.Now())
+	}
+
+	// The chunk might have been the last chunk of a conversion that needed to
+	// be uploaded.
+	if successfulRepair && uc.staticConversion {
+		if _, err := r.managedConversionChunkRepaired(uc.staticConversionSource, uc.staticIndex); err != nil {
+			r.log.Printf("WARN: unable to finish changing the erasure code of %v: %v", uc.staticConversionSource, err)
+		}
+	}
 
 	// Check to see if the chunk was stuck and now is successfully repaired by
 	// the stuck loop
@@ -0,0 +1,127 @@
+package renter
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+)
+
+var (
+	// unfinishedChunkCacheSize is the maximum number of chunks the
+	// unfinishedChunkCache holds on to.
+	unfinishedChunkCacheSize = build.Select(build.Var{
+		Dev:      1000,
+		Standard: 5000,
+		Testing:  100,
+	}).(int)
+)
+
+type (
+	// unfinishedChunkCache is a short-lived LRU cache of the siafile metadata
+	// that is read from disk when building an unfinished chunk. This avoids
+	// reading the same metadata over and over when multiple chunks of a file
+	// are processed within a single repair cycle.
+	//
+	// The cache doesn't hold the unfinishedUploadChunk itself since every
+	// unfinishedUploadChunk needs its own file entry and channels. Instead it
+	// holds the metadata the chunk is built from. Entries expire after
+	// workerCacheUpdateFrequency and are evicted whenever the metadata of a
+	// chunk is updated.
+	unfinishedChunkCache struct {
+		entries map[uploadChunkID]*list.Element
+		lru     *list.List
+
+		hits   uint64
+		misses uint64
+
+		staticMaxSize int
+		staticTTL     time.Duration
+		mu            sync.Mutex
+	}
+
+	// unfinishedChunkCacheEntry is a single chunk within the
+	// unfinishedChunkCache. The pieces must not be modified.
+	unfinishedChunkCacheEntry struct {
+		id             uploadChunkID
+		pieces         [][]siafile.Piece
+		repairFailures uint8
+		stuck          bool
+		added          time.Time
+	}
+)
+
+// newUnfinishedChunkCache creates a new unfinishedChunkCache.
+func newUnfinishedChunkCache(maxSize int, ttl time.Duration) *unfinishedChunkCache {
+	return &unfinishedChunkCache{
+		entries:       make(map[uploadChunkID]*list.Element),
+		lru:           list.New(),
+		staticMaxSize: maxSize,
+		staticTTL:     ttl,
+	}
+}
+
+// callAdd adds the metadata of a chunk to the cache. If the cache is full, the
+// least recently used entry is evicted.
+func (ucc *unfinishedChunkCache) callAdd(entry unfinishedChunkCacheEntry) {
+	ucc.mu.Lock()
+	defer ucc.mu.Unlock()
+	ucc.remove(entry.id)
+	entry.added = time.Now()
+	ucc.entries[entry.id] = ucc.lru.PushFront(&entry)
+	for ucc.lru.Len() > ucc.staticMaxSize {
+		ucc.remove(ucc.lru.Back().Value.(*unfinishedChunkCacheEntry).id)
+	}
+}
+
+// callGet returns the cached metadata of a chunk. Expired entries are removed
+// and count as a miss.
+func (ucc *unfinishedChunkCache) callGet(id uploadChunkID) (unfinishedChunkCacheEntry, bool) {
+	ucc.mu.Lock()
+	defer ucc.mu.Unlock()
+	elem, exists := ucc.entries[id]
+	if !exists {
+		ucc.misses++
+		return unfinishedChunkCacheEntry{}, false
+	}
+	entry := elem.Value.(*unfinishedChunkCacheEntry)
+	if time.Since(entry.added) >= ucc.staticTTL {
+		ucc.remove(id)
+		ucc.misses++
+		return unfinishedChunkCacheEntry{}, false
+	}
+	ucc.lru.MoveToFront(elem)
+	ucc.hits++
+	return *entry, true
+}
+
+// callRemove evicts a chunk from the cache.
+func (ucc *unfinishedChunkCache) callRemove(id uploadChunkID) {
+	ucc.mu.Lock()
+	defer ucc.mu.Unlock()
+	ucc.remove(id)
+}
+
+// callRemoveFile evicts all chunks of the file with the given UID from the
+// cache.
+func (ucc *unfinishedChunkCache) callRemoveFile(uid siafile.SiafileUID) {
+	ucc.mu.Lock()
+	defer ucc.mu.Unlock()
+	for id := range ucc.entries {
+		if id.fileUID == uid {
+			ucc.remove(id)
+		}
+	}
+}
+
+// remove evicts a chunk from the cache.
+func (ucc *unfinishedChunkCache) remove(id uploadChunkID) {
+	elem, exists := ucc.entries[id]
+	if !exists {
+		return
+	}
+	ucc.lru.Remove(elem)
+	delete(ucc.entries, id)
+}
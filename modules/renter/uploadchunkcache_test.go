@@ -0,0 +1,174 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
+)
+
+// TestUnfinishedChunkCache is a unit test for the unfinishedChunkCache.
+func TestUnfinishedChunkCache(t *testing.T) {
+	t.Parallel()
+
+	ttl := time.Second
+	ucc := newUnfinishedChunkCache(2, ttl)
+	id := func(index uint64) uploadChunkID {
+		return uploadChunkID{fileUID: siafile.SiafileUID("file"), index: index}
+	}
+
+	// Getting a chunk from an empty cache is a miss.
+	if _, exists := ucc.callGet(id(0)); exists {
+		t.Fatal("chunk shouldn't be cached")
+	}
+
+	// Add two chunks and get them.
+	ucc.callAdd(unfinishedChunkCacheEntry{id: id(0), stuck: true})
+	ucc.callAdd(unfinishedChunkCacheEntry{id: id(1), repairFailures: 1})
+	entry, exists := ucc.callGet(id(0))
+	if !exists || !entry.stuck {
+		t.Fatal("unexpected entry", entry, exists)
+	}
+	entry, exists = ucc.callGet(id(1))
+	if !exists || entry.repairFailures != 1 {
+		t.Fatal("unexpected entry", entry, exists)
+	}
+
+	// Adding a third chunk evicts the least recently used one.
+	ucc.callAdd(unfinishedChunkCacheEntry{id: id(2)})
+	if _, exists := ucc.callGet(id(0)); exists {
+		t.Fatal("chunk should have been evicted")
+	}
+	if _, exists := ucc.callGet(id(1)); !exists {
+		t.Fatal("chunk should be cached")
+	}
+
+	// Remove a chunk.
+	ucc.callRemove(id(1))
+	if _, exists := ucc.callGet(id(1)); exists {
+		t.Fatal("chunk should have been removed")
+	}
+
+	// Expired chunks are removed.
+	ucc.mu.Lock()
+	ucc.entries[id(2)].Value.(*unfinishedChunkCacheEntry).added = time.Now().Add(-ttl)
+	ucc.mu.Unlock()
+	if _, exists := ucc.callGet(id(2)); exists {
+		t.Fatal("chunk should have expired")
+	}
+	if len(ucc.entries) != 0 || ucc.lru.Len() != 0 {
+		t.Fatal("cache should be empty", len(ucc.entries), ucc.lru.Len())
+	}
+	if ucc.hits != 3 || ucc.misses != 4 {
+		t.Fatal("unexpected hits and misses", ucc.hits, ucc.misses)
+	}
+
+	// Removing a file evicts all of its chunks but not the chunks of other
+	// files.
+	other := uploadChunkID{fileUID: siafile.SiafileUID("other"), index: 0}
+	ucc.callAdd(unfinishedChunkCacheEntry{id: id(0)})
+	ucc.callAdd(unfinishedChunkCacheEntry{id: other})
+	ucc.callRemoveFile(siafile.SiafileUID("file"))
+	if _, exists := ucc.callGet(id(0)); exists {
+		t.Fatal("chunk should have been removed")
+	}
+	if _, exists := ucc.callGet(other); !exists {
+		t.Fatal("chunk of other file should be cached")
+	}
+}
+
+// TestBuildUnfinishedChunkCache verifies that building the same unfinished
+// chunk twice within the cache's ttl only reads the chunk's metadata from disk
+// once and that setting the chunk stuck evicts it from the cache.
+func TestBuildUnfinishedChunkCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a file with a single chunk.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 2)
+	siaPath, err := modules.NewSiaPath("unfinishedChunkCacheFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	hosts := make(map[string]struct{})
+	pks := make(map[string]types.SiaPublicKey)
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+
+	// buildChunk builds the chunk and closes its file entry again.
+	buildChunk := func() *unfinishedUploadChunk {
+		uuc, err := r.managedBuildUnfinishedChunk(f, 0, hosts, pks, memoryPriorityLow, offline, goodForRenew, nil, r.repairMemoryManager)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := uuc.fileEntry.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return uuc
+	}
+	cacheStats := func() (uint64, uint64) {
+		ucc := r.staticUnfinishedChunkCache
+		ucc.mu.Lock()
+		defer ucc.mu.Unlock()
+		return ucc.hits, ucc.misses
+	}
+
+	// Build the chunk twice. Only the first build should read from disk.
+	buildChunk()
+	uuc := buildChunk()
+	if hits, misses := cacheStats(); hits != 1 || misses != 1 {
+		t.Fatal("unexpected hits and misses", hits, misses)
+	}
+	if uuc.stuck {
+		t.Fatal("chunk shouldn't be stuck")
+	}
+
+	// Mark the chunk as stuck. The next build should read the updated
+	// metadata from disk.
+	err = r.managedSetChunkStuck(f, 0, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uuc = buildChunk()
+	if hits, misses := cacheStats(); hits != 1 || misses != 2 {
+		t.Fatal("unexpected hits and misses", hits, misses)
+	}
+	if !uuc.stuck {
+		t.Fatal("chunk should be stuck")
+	}
+}
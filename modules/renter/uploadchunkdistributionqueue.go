@@ -344,14 +344,14 @@ func managedSelectWorkersForUploading(uc *unfinishedUploadChunk, workers []*work
 	// viable candidates for receiving work.
 	var availableWorkers, busyWorkers, overloadedWorkers uint64
 	for _, w := range workers {
-		// Skip any worker that is on cooldown or is !GFU.
+		// Skip any worker that is on cooldown, is !GFU or is in standby.
 		cache := w.staticCache()
 		w.mu.Lock()
 		onCooldown, _ := w.onUploadCooldown()
 		numUnprocessedChunks := w.unprocessedChunks.Len()
 		w.mu.Unlock()
 		gfu := cache.staticContractUtility.GoodForUpload
-		if onCooldown || !gfu {
+		if onCooldown || !gfu || w.staticStandby() {
 			continue
 		}
 
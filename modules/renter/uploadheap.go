@@ -10,6 +10,7 @@ import (
 	"container/heap"
 	"context"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -25,6 +26,7 @@ import (
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/types"
 )
 
@@ -33,16 +35,32 @@ import (
 type repairTarget int
 
 // targetStuckChunks tells the repair loop to target stuck chunks for repair and
-// targetUnstuckChunks tells the repair loop to target unstuck chunks for repair
+// targetUnstuckChunks tells the repair loop to target unstuck chunks for repair.
+// targetRecoveryChunks is used for chunks whose downloaded data failed
+// verification.
 const (
 	targetError repairTarget = iota
 	targetStuckChunks
 	targetUnstuckChunks
 	targetBackupChunks
+	targetRecoveryChunks
 )
 
 type chunkType bool
 
+// pushFailureReason describes why a chunk couldn't be pushed for repair.
+type pushFailureReason string
+
+const (
+	// pushFailureError indicates that pushing the chunk failed with an error.
+	pushFailureError pushFailureReason = "error"
+
+	// pushFailureRejected indicates that the upload heap rejected the chunk,
+	// either because it is already being tracked by the heap or because the
+	// heap can't hold any more stuck chunks.
+	pushFailureRejected pushFailureReason = "rejected"
+)
+
 var (
 	// chunkTypeStreamChunk indicates that a chunk is being uploaded or repaired
 	// by a stream.
@@ -62,6 +80,10 @@ var (
 		Dev:      1 * time.Minute,
 		Testing:  100 * time.Millisecond,
 	}).(time.Duration)
+
+	// errInvalidHealthThreshold is returned if the health threshold for
+	// pruning the upload heap is invalid.
+	errInvalidHealthThreshold = errors.New("health threshold must be a non-negative number")
 )
 
 // uploadChunkHeap is a bunch of priority-sorted chunks that need to be either
@@ -86,9 +108,14 @@ func (uch uploadChunkHeap) Less(i, j int) bool {
 	//
 	//  4) Remote Chunks
 	//    - These are chunks of a siafile that do not have a local file to repair
-	//    from
+	//    from. This is data at risk.
+	//
+	//  5) Conversion Chunks
+	//    - These are chunks of a siafile that is created when changing the
+	//    erasure code of a file. The original file still holds the data so
+	//    they are less important than data at risk.
 	//
-	//  5) Worst Health Chunk
+	//  6) Worst Health Chunk
 	//    - The base priority of chunks in the heap is by the worst health
 
 	// Check for Priority chunks
@@ -126,11 +153,22 @@ func (uch uploadChunkHeap) Less(i, j int) bool {
 		return false
 	}
 
-	// Check for Remote Chunks
-	if !uch[i].onDisk && uch[j].onDisk {
+	// Check for Remote Chunks. Conversion chunks are never considered remote
+	// since their data is still available from the original file.
+	remoteI := !uch[i].onDisk && !uch[i].staticConversion
+	remoteJ := !uch[j].onDisk && !uch[j].staticConversion
+	if remoteI && !remoteJ {
 		return true
 	}
-	if uch[i].onDisk && !uch[j].onDisk {
+	if !remoteI && remoteJ {
+		return false
+	}
+
+	// Check for Conversion Chunks
+	if uch[i].staticConversion && !uch[j].staticConversion {
+		return true
+	}
+	if !uch[i].staticConversion && uch[j].staticConversion {
 		return false
 	}
 
@@ -173,14 +211,26 @@ func (uch *uploadChunkHeap) removeByID(uuc *unfinishedUploadChunk) {
 	*uch = old[:len(old)-1]
 }
 
+// peek returns the chunk at the top of the heap without removing it. nil is
+// returned if the heap is empty.
+func (uch *uploadChunkHeap) peek() *unfinishedUploadChunk {
+	if len(*uch) == 0 {
+		return nil
+	}
+	return (*uch)[0]
+}
+
 // reset clears the uploadChunkHeap and makes sure all the files belonging to
 // the chunks are closed
-func (uch *uploadChunkHeap) reset() (err error) {
+func (uch *uploadChunkHeap) reset() (failed uint64, err error) {
 	for _, c := range *uch {
-		err = errors.Compose(err, c.fileEntry.Close())
+		if errClose := c.managedClose(nil); errClose != nil {
+			err = errors.Compose(err, errClose)
+			failed++
+		}
 	}
 	*uch = uploadChunkHeap{}
-	return err
+	return failed, err
 }
 
 // uploadHeap contains a priority-sorted heap of all the chunks being uploaded
@@ -198,7 +248,27 @@ type uploadHeap struct {
 	stuckHeapChunks   map[uploadChunkID]*unfinishedUploadChunk
 	unstuckHeapChunks map[uploadChunkID]*unfinishedUploadChunk
 
+	// offlineUploads contains the files that were uploaded while the renter
+	// was offline. Their chunks are pushed to the heap once the renter is
+	// online again.
+	offlineUploads map[modules.SiaPath]struct{}
+
+	// failedPushes counts the chunks that couldn't be pushed for repair by
+	// the reason of the failure.
+	failedPushes map[pushFailureReason]uint64
+
+	// failedCloses counts the chunks whose file entries failed to close since
+	// the last successful close. Leaked entries prevent the affected files
+	// from being deleted or renamed.
+	failedCloses uint64
+
 	// Internal control channels
+	//
+	// backupNeeded is signaled by the snapshot code when there are backup
+	// chunks which need to be uploaded. Since the backups aren't part of the
+	// directory heap, the repair loop adds them to the upload heap and repairs
+	// them right away instead of scanning the whole filesystem first.
+	backupNeeded      chan struct{}
 	newUploads        chan struct{}
 	repairNeeded      chan struct{}
 	stuckChunkFound   chan struct{}
@@ -213,6 +283,85 @@ type uploadHeap struct {
 	mu sync.Mutex
 }
 
+// managedDrainStuckChunks removes all the stuck chunks from the upload heap and
+// closes their file entries. Chunks that are currently being repaired are not
+// affected. Once the stuck chunks are removed, the repair loop is signaled so
+// that it can re-evaluate the state of the renter's files.
+func (uh *uploadHeap) managedDrainStuckChunks() (err error) {
+	uh.mu.Lock()
+	// Remove the stuck chunks from the heap slice.
+	remaining := make(uploadChunkHeap, 0, len(uh.heap))
+	var removed []*unfinishedUploadChunk
+	for _, c := range uh.heap {
+		if _, stuck := uh.stuckHeapChunks[c.id]; !stuck {
+			remaining = append(remaining, c)
+			continue
+		}
+		removed = append(removed, c)
+	}
+	uh.heap = remaining
+	heap.Init(&uh.heap)
+
+	// Clear the map.
+	uh.stuckHeapChunks = make(map[uploadChunkID]*unfinishedUploadChunk)
+	uh.mu.Unlock()
+
+	// Close the file entries of the removed chunks.
+	err = uh.managedCloseChunks(removed)
+
+	// Signal that a repair is needed.
+	select {
+	case uh.repairNeeded <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// managedRemoveByFileUID removes all the chunks of the file with the given UID
+// from the heap and closes their file entries. Chunks that are currently being
+// repaired are not affected.
+func (uh *uploadHeap) managedRemoveByFileUID(uid siafile.SiafileUID) error {
+	uh.mu.Lock()
+	remaining := make(uploadChunkHeap, 0, len(uh.heap))
+	var removed []*unfinishedUploadChunk
+	for _, c := range uh.heap {
+		if c.id.fileUID != uid {
+			remaining = append(remaining, c)
+			continue
+		}
+		delete(uh.stuckHeapChunks, c.id)
+		delete(uh.unstuckHeapChunks, c.id)
+		removed = append(removed, c)
+	}
+	uh.heap = remaining
+	heap.Init(&uh.heap)
+	uh.mu.Unlock()
+	return uh.managedCloseChunks(removed)
+}
+
+// managedPrune removes all the chunks with a health below healthThreshold from
+// the heap and closes their file entries. Chunks that are currently being
+// repaired and chunks of streaming uploads are not affected. The number of
+// pruned chunks is returned.
+func (uh *uploadHeap) managedPrune(healthThreshold float64) (int, error) {
+	uh.mu.Lock()
+	remaining := make(uploadChunkHeap, 0, len(uh.heap))
+	var pruned []*unfinishedUploadChunk
+	for _, c := range uh.heap {
+		if c.health >= healthThreshold || c.sourceReader != nil {
+			remaining = append(remaining, c)
+			continue
+		}
+		delete(uh.stuckHeapChunks, c.id)
+		delete(uh.unstuckHeapChunks, c.id)
+		pruned = append(pruned, c)
+	}
+	uh.heap = remaining
+	heap.Init(&uh.heap)
+	uh.mu.Unlock()
+	return len(pruned), uh.managedCloseChunks(pruned)
+}
+
 // managedExists checks if a chunk currently exists in the upload heap. A chunk
 // exists in the upload heap if it exists in any of the heap's tracking maps
 func (uh *uploadHeap) managedExists(id uploadChunkID) bool {
@@ -224,6 +373,79 @@ func (uh *uploadHeap) managedExists(id uploadChunkID) bool {
 	return existsUnstuckHeap || existsRepairing || existsStuckHeap
 }
 
+// managedHasFileChunks checks if any chunk of the file with the given UID
+// currently exists in the upload heap or is being repaired.
+func (uh *uploadHeap) managedHasFileChunks(uid siafile.SiafileUID) bool {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	for _, chunks := range []map[uploadChunkID]*unfinishedUploadChunk{uh.unstuckHeapChunks, uh.repairingChunks, uh.stuckHeapChunks} {
+		for id := range chunks {
+			if id.fileUID == uid {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// managedFailedPushes returns a copy of the failed push counters of the heap.
+func (uh *uploadHeap) managedFailedPushes() map[pushFailureReason]uint64 {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	failedPushes := make(map[pushFailureReason]uint64, len(uh.failedPushes))
+	for reason, n := range uh.failedPushes {
+		failedPushes[reason] = n
+	}
+	return failedPushes
+}
+
+// managedTrackFailedPush increments the failed push counter for the given
+// reason.
+func (uh *uploadHeap) managedTrackFailedPush(reason pushFailureReason) {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	uh.failedPushes[reason]++
+}
+
+// managedFailedCloses returns the number of chunks whose file entries failed
+// to close.
+func (uh *uploadHeap) managedFailedCloses() uint64 {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	return uh.failedCloses
+}
+
+// managedTrackCloses updates the failed close counter with the number of
+// closed and failed file entries. Failures are added to the counter while a
+// successful close without failures resets it. It returns whether the counter
+// changed.
+func (uh *uploadHeap) managedTrackCloses(closed, failed uint64) bool {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	before := uh.failedCloses
+	if failed > 0 {
+		uh.failedCloses += failed
+	} else if closed > 0 {
+		uh.failedCloses = 0
+	}
+	return uh.failedCloses != before
+}
+
+// managedCloseChunks closes the file entries of chunks which were removed from
+// the heap and tracks the failures. Closing a chunk acquires the chunk's lock,
+// so the heap's lock must not be held while calling it.
+func (uh *uploadHeap) managedCloseChunks(chunks []*unfinishedUploadChunk) (err error) {
+	var failed uint64
+	for _, c := range chunks {
+		if errClose := c.managedClose(nil); errClose != nil {
+			err = errors.Compose(err, errClose)
+			failed++
+		}
+	}
+	uh.managedTrackCloses(uint64(len(chunks))-failed, failed)
+	return err
+}
+
 // managedIsPaused returns the boolean indicating whether or not the user
 // has paused the repairs and uploads
 func (uh *uploadHeap) managedIsPaused() bool {
@@ -245,6 +467,33 @@ func (uh *uploadHeap) managedLen() int {
 	return uhLen
 }
 
+// managedMaxPiecesNeeded returns the largest number of pieces needed by any of
+// the chunks in the heap or currently being repaired.
+func (uh *uploadHeap) managedMaxPiecesNeeded() int {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	var maxPieces int
+	for _, c := range uh.heap {
+		if c.staticPiecesNeeded > maxPieces {
+			maxPieces = c.staticPiecesNeeded
+		}
+	}
+	for _, c := range uh.repairingChunks {
+		if c.staticPiecesNeeded > maxPieces {
+			maxPieces = c.staticPiecesNeeded
+		}
+	}
+	return maxPieces
+}
+
+// managedPeek will return the chunk at the top of the heap without removing it
+// from the heap. nil is returned if the heap is empty.
+func (uh *uploadHeap) managedPeek() *unfinishedUploadChunk {
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	return uh.heap.peek()
+}
+
 // managedPauseStatus will return whether or not the uploadheap is paused and
 // the duration of the pause
 func (uh *uploadHeap) managedPauseStatus() (bool, time.Time) {
@@ -287,6 +536,47 @@ func (uh *uploadHeap) managedNumStuckChunks() (total int, random int) {
 	return total, random
 }
 
+// managedSnapshot returns a summary of the chunks in the heap and the chunks
+// which are currently being repaired sorted by their siapath and index.
+// siaPath is used to look up the siapaths of the chunks' files.
+func (uh *uploadHeap) managedSnapshot(siaPath func(*filesystem.FileNode) modules.SiaPath) (modules.UploadHeapSummary, []modules.RepairStatusChunk) {
+	uh.mu.Lock()
+	summary := modules.UploadHeapSummary{
+		Len:       len(uh.heap),
+		Stuck:     len(uh.stuckHeapChunks),
+		Unstuck:   len(uh.unstuckHeapChunks),
+		Repairing: len(uh.repairingChunks),
+	}
+	repairing := make([]*unfinishedUploadChunk, 0, len(uh.repairingChunks))
+	for _, uuc := range uh.repairingChunks {
+		repairing = append(repairing, uuc)
+	}
+	uh.mu.Unlock()
+
+	// Lock the chunks after releasing the heap's lock to avoid holding both
+	// locks at the same time.
+	chunks := make([]modules.RepairStatusChunk, 0, len(repairing))
+	for _, uuc := range repairing {
+		uuc.mu.Lock()
+		chunks = append(chunks, modules.RepairStatusChunk{
+			SiaPath:         siaPath(uuc.fileEntry),
+			Index:           uuc.staticIndex,
+			Health:          uuc.health,
+			Stuck:           uuc.stuck,
+			PiecesCompleted: uuc.piecesCompleted,
+			PiecesNeeded:    uuc.staticPiecesNeeded,
+		})
+		uuc.mu.Unlock()
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		if !chunks[i].SiaPath.Equals(chunks[j].SiaPath) {
+			return chunks[i].SiaPath.String() < chunks[j].SiaPath.String()
+		}
+		return chunks[i].Index < chunks[j].Index
+	})
+	return summary, chunks
+}
+
 // managedPause creates the pauseChan and initiates the pauseTimer for the
 // duration requested
 func (uh *uploadHeap) managedPause(duration time.Duration) {
@@ -325,6 +615,7 @@ func (uh *uploadHeap) managedPush(uuc *unfinishedUploadChunk, ct chunkType) bool
 	if uuc.chunkCreationTime.IsZero() {
 		uuc.chunkCreationTime = time.Now()
 	}
+	uuc.enqueuedAt = time.Now()
 	uuc.mu.Unlock()
 
 	// Check if chunk is in any of the heap maps
@@ -384,10 +675,12 @@ func (uh *uploadHeap) managedPop() (uc *unfinishedUploadChunk) {
 // managedReset will reset the slice and maps within the heap to free up memory.
 func (uh *uploadHeap) managedReset() error {
 	uh.mu.Lock()
-	defer uh.mu.Unlock()
 	uh.unstuckHeapChunks = make(map[uploadChunkID]*unfinishedUploadChunk)
 	uh.stuckHeapChunks = make(map[uploadChunkID]*unfinishedUploadChunk)
-	return uh.heap.reset()
+	chunks := uh.heap
+	uh.heap = uploadChunkHeap{}
+	uh.mu.Unlock()
+	return uh.managedCloseChunks(chunks)
 }
 
 // managedResume will close the pauseChan and stop the pauseTimer
@@ -414,6 +707,48 @@ func (uh *uploadHeap) managedResume() {
 	}
 }
 
+// managedRemoveUnprioritized removes the chunk with the given id from the
+// uploadHeap if it is waiting in the heap without priority. Chunks that are
+// already being repaired are left untouched.
+func (uh *uploadHeap) managedRemoveUnprioritized(id uploadChunkID) error {
+	uh.mu.Lock()
+	existing, exists := uh.unstuckHeapChunks[id]
+	if !exists {
+		existing, exists = uh.stuckHeapChunks[id]
+	}
+	if !exists || existing.staticPriority {
+		uh.mu.Unlock()
+		return nil
+	}
+	delete(uh.unstuckHeapChunks, id)
+	delete(uh.stuckHeapChunks, id)
+	uh.heap.removeByID(existing)
+	uh.mu.Unlock()
+	return uh.managedCloseChunks([]*unfinishedUploadChunk{existing})
+}
+
+// managedRemoveForRecovery removes the chunk with the given id from the
+// uploadHeap if it is waiting in the heap and isn't in recovery mode. Such a
+// chunk counts the piece of the host which returned bad data and would
+// prevent the recovery chunk from being pushed. Chunks that are already being
+// repaired are left untouched.
+func (uh *uploadHeap) managedRemoveForRecovery(id uploadChunkID) error {
+	uh.mu.Lock()
+	existing, exists := uh.unstuckHeapChunks[id]
+	if !exists {
+		existing, exists = uh.stuckHeapChunks[id]
+	}
+	if !exists || existing.recoveryMode {
+		uh.mu.Unlock()
+		return nil
+	}
+	delete(uh.unstuckHeapChunks, id)
+	delete(uh.stuckHeapChunks, id)
+	uh.heap.removeByID(existing)
+	uh.mu.Unlock()
+	return uh.managedCloseChunks([]*unfinishedUploadChunk{existing})
+}
+
 // managedTryUpdate will try and update the chunk in the uploadHeap associated
 // with a chunk id. If a chunk exists in the uploadHeap and needs to be updated
 // to the supplied chunk, the chunk that is currently in the heap will be
@@ -467,7 +802,7 @@ func (uh *uploadHeap) managedTryUpdate(uuc *unfinishedUploadChunk, ct chunkType)
 		delete(uh.stuckHeapChunks, existingUUC.id)
 		uh.heap.removeByID(existingUUC)
 		uh.mu.Unlock()
-		return existingUUC.fileEntry.Close()
+		return uh.managedCloseChunks([]*unfinishedUploadChunk{existingUUC})
 	}
 	uh.mu.Unlock()
 
@@ -499,6 +834,36 @@ func (r *Renter) PauseRepairsAndUploads(duration time.Duration) error {
 	return nil
 }
 
+// ClearStuckChunks removes all stuck chunks from the upload heap so that the
+// repair loop re-evaluates the renter's files from scratch.
+func (r *Renter) ClearStuckChunks() error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.uploadHeap.managedDrainStuckChunks()
+}
+
+// PruneUploadHeap removes all chunks with a health below healthThreshold from
+// the upload heap and returns the number of removed chunks. This allows for
+// focusing the repairs on the most degraded chunks. The pruned chunks are added
+// back to the heap the next time their directories are explored.
+func (r *Renter) PruneUploadHeap(healthThreshold float64) (int, error) {
+	if err := r.tg.Add(); err != nil {
+		return 0, err
+	}
+	defer r.tg.Done()
+	if math.IsNaN(healthThreshold) || healthThreshold < 0 {
+		return 0, errInvalidHealthThreshold
+	}
+	pruned, err := r.uploadHeap.managedPrune(healthThreshold)
+	if err != nil {
+		r.repairLog.Println("WARN: failed to close file entries of pruned chunks:", err)
+		r.managedUpdateFailedClosesAlert()
+	}
+	return pruned, err
+}
+
 // ResumeRepairsAndUploads resumes the renter's repairs and uploads
 func (r *Renter) ResumeRepairsAndUploads() error {
 	if err := r.tg.Add(); err != nil {
@@ -510,23 +875,35 @@ func (r *Renter) ResumeRepairsAndUploads() error {
 }
 
 // managedBuildUnfinishedChunk will pull out a single unfinished chunk of a file.
-func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIndex uint64, hosts map[string]struct{}, hostPublicKeys map[string]types.SiaPublicKey, priority bool, offline, goodForRenew map[string]bool, mm *memoryManager) (*unfinishedUploadChunk, error) {
+//
+// The expensiveHosts are the hosts which charge too much for uploads. They are
+// computed once by the caller for all the chunks it builds.
+func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIndex uint64, hosts map[string]struct{}, hostPublicKeys map[string]types.SiaPublicKey, priority bool, offline, goodForRenew map[string]bool, expensiveHosts map[string]struct{}, mm *memoryManager) (*unfinishedUploadChunk, error) {
+	// Fetch the chunk's metadata from the cache or read it from disk if the
+	// chunk was not processed recently.
+	id := uploadChunkID{
+		fileUID: entry.UID(),
+		index:   chunkIndex,
+	}
+	metadata, cached := r.staticUnfinishedChunkCache.callGet(id)
+	if !cached {
+		var err error
+		metadata, err = r.managedReadUnfinishedChunkMetadata(entry, id)
+		if err != nil {
+			return nil, err
+		}
+		r.staticUnfinishedChunkCache.callAdd(metadata)
+	}
+
 	// Copy entry
 	entryCopy := entry.Copy()
-	stuck, err := entry.StuckChunkByIndex(chunkIndex)
-	if err != nil {
-		r.log.Println("WARN: unable to get 'stuck' status:", err)
-		return nil, errors.AddContext(err, "unable to get 'stuck' status")
-	}
-	_, err = os.Stat(entryCopy.LocalPath())
+	_, err := os.Stat(entryCopy.LocalPath())
 	onDisk := err == nil
+	source, conversion := conversionSourceSiaPath(r.staticFileSystem.FileSiaPath(entry))
 	uuc := &unfinishedUploadChunk{
 		fileEntry: entryCopy,
 
-		id: uploadChunkID{
-			fileUID: entry.UID(),
-			index:   chunkIndex,
-		},
+		id: id,
 
 		length:         entry.ChunkSize(),
 		offset:         int64(chunkIndex * entry.ChunkSize()),
@@ -536,6 +913,9 @@ func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIn
 		staticIndex:   chunkIndex,
 		staticSiaPath: entryCopy.SiaFilePath(),
 
+		staticConversion:       conversion,
+		staticConversionSource: source,
+
 		staticMemoryManager: mm,
 
 		// staticMemoryNeeded has to also include the logical data, and also
@@ -547,7 +927,8 @@ func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIn
 		staticMemoryNeeded:  entry.PieceSize()*uint64(entry.ErasureCode().NumPieces()+entry.ErasureCode().MinPieces()) + uint64(entry.ErasureCode().NumPieces())*entry.MasterKey().Type().Overhead(),
 		staticMinimumPieces: entry.ErasureCode().MinPieces(),
 		staticPiecesNeeded:  entry.ErasureCode().NumPieces(),
-		stuck:               stuck,
+		stuck:               metadata.stuck,
+		repairAttempts:      int(metadata.repairFailures),
 
 		physicalChunkData:        make([][]byte, entry.ErasureCode().NumPieces()),
 		staticExpectedPieceRoots: make([]crypto.Hash, entry.ErasureCode().NumPieces()),
@@ -567,15 +948,7 @@ func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIn
 	// Iterate through the pieces of all chunks of the file and mark which
 	// hosts are already in use for a particular chunk. As you delete hosts
 	// from the 'unusedHosts' map, also increment the 'piecesCompleted' value.
-	pieces, err := entry.Pieces(chunkIndex)
-	if err != nil {
-		r.log.Println("failed to get pieces for building incomplete chunks", err)
-		if err := entry.SetStuck(chunkIndex, true); err != nil {
-			r.log.Printf("failed to set chunk %v stuck: %v", chunkIndex, err)
-		}
-		return nil, errors.AddContext(err, "error trying to get the pieces for the chunk")
-	}
-	for pieceIndex, pieceSet := range pieces {
+	for pieceIndex, pieceSet := range metadata.pieces {
 		for _, piece := range pieceSet {
 			// Determine whether this piece counts towards the redundancy.
 			// Several criteria must be met:
@@ -607,16 +980,122 @@ func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIn
 		// a local (and therefore potentially altered or corrupt) file.
 		if len(pieceSet) > 0 {
 			uuc.staticExpectedPieceRoots[pieceIndex] = pieceSet[0].MerkleRoot
+			uuc.staticRepair = true
 		}
 	}
+	// Hosts which charge more for uploads than the allowance permits would
+	// refuse the upload jobs anyway so they aren't candidates for new pieces.
+	// This happens after counting the completed pieces since pieces which are
+	// already stored on these hosts still count towards the redundancy.
+	for host := range expensiveHosts {
+		delete(uuc.unusedHosts, host)
+	}
 	// Now that we have calculated the completed pieces for the chunk we can
 	// calculate the health of the chunk to avoid a call to ChunkHealth
 	uuc.health = 1 - (float64(uuc.piecesCompleted-uuc.staticMinimumPieces) / float64(uuc.staticPiecesNeeded-uuc.staticMinimumPieces))
 	return uuc, nil
 }
 
+// managedReadUnfinishedChunkMetadata reads the metadata of a chunk that is
+// required to build an unfinished chunk from disk.
+func (r *Renter) managedReadUnfinishedChunkMetadata(entry *filesystem.FileNode, id uploadChunkID) (unfinishedChunkCacheEntry, error) {
+	stuck, err := entry.StuckChunkByIndex(id.index)
+	if err != nil {
+		r.log.Println("WARN: unable to get 'stuck' status:", err)
+		return unfinishedChunkCacheEntry{}, errors.AddContext(err, "unable to get 'stuck' status")
+	}
+	// The failed repairs are persisted in the siafile which allows for
+	// tracking the repair attempts of a chunk across rebuilds of the heap.
+	repairFailures, _, err := entry.RepairFailuresByIndex(id.index)
+	if err != nil {
+		r.log.Println("WARN: unable to get repair failures:", err)
+		return unfinishedChunkCacheEntry{}, errors.AddContext(err, "unable to get repair failures")
+	}
+	pieces, err := entry.Pieces(id.index)
+	if err != nil {
+		r.log.Println("failed to get pieces for building incomplete chunks", err)
+		if err := r.managedSetChunkStuck(entry, id.index, true); err != nil {
+			r.log.Printf("failed to set chunk %v stuck: %v", id.index, err)
+		}
+		return unfinishedChunkCacheEntry{}, errors.AddContext(err, "error trying to get the pieces for the chunk")
+	}
+	return unfinishedChunkCacheEntry{
+		id:             id,
+		pieces:         pieces,
+		repairFailures: repairFailures,
+		stuck:          stuck,
+	}, nil
+}
+
+// managedSetChunkStuck sets the stuck status of a chunk and evicts the chunk
+// from the unfinished chunk cache.
+func (r *Renter) managedSetChunkStuck(entry *filesystem.FileNode, chunkIndex uint64, stuck bool) error {
+	err := entry.SetStuck(chunkIndex, stuck)
+	r.staticUnfinishedChunkCache.callRemove(uploadChunkID{
+		fileUID: entry.UID(),
+		index:   chunkIndex,
+	})
+	return err
+}
+
+// managedSetAllChunksStuck sets the stuck status of all chunks of a file and
+// evicts the file's chunks from the unfinished chunk cache.
+func (r *Renter) managedSetAllChunksStuck(entry *filesystem.FileNode, stuck bool) error {
+	err := entry.SetAllStuck(stuck)
+	r.staticUnfinishedChunkCache.callRemoveFile(entry.UID())
+	return err
+}
+
+// repairBackoff returns the time a chunk is skipped by the repair loops after
+// the given number of consecutive failed repairs.
+func repairBackoff(failures uint8) time.Duration {
+	if failures == 0 {
+		return 0
+	}
+	backoff := repairFailureBackoff
+	for i := uint8(1); i < failures && backoff < maxRepairFailureBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxRepairFailureBackoff {
+		backoff = maxRepairFailureBackoff
+	}
+	return backoff
+}
+
+// managedRepairAttemptFailed records a failed repair attempt of a chunk that
+// couldn't be handed to the workers. Once the chunk reaches the maximum number
+// of repair attempts, it is marked as stuck. Stuck chunks only count failed
+// repairs towards their stuck repair attempts so they are ignored.
+func (r *Renter) managedRepairAttemptFailed(uuc *unfinishedUploadChunk) {
+	uuc.mu.Lock()
+	stuck := uuc.stuck
+	if !stuck {
+		uuc.repairAttempts++
+	}
+	attempts := uuc.repairAttempts
+	uuc.mu.Unlock()
+	if stuck {
+		return
+	}
+	err := uuc.fileEntry.MarkRepairFailed(uuc.staticIndex)
+	r.staticUnfinishedChunkCache.callRemove(uuc.id)
+	if err != nil {
+		r.repairLog.Printf("WARN: unable to mark repair of chunk %v of %s as failed: %v", uuc.staticIndex, uuc.staticSiaPath, err)
+	}
+	if attempts < r.managedMaxRepairAttempts() {
+		return
+	}
+	r.repairLog.Printf("Marking chunk %v of %s as stuck after %v failed repair attempts", uuc.staticIndex, uuc.staticSiaPath, attempts)
+	err = r.managedSetChunkStuck(uuc.fileEntry, uuc.staticIndex, true)
+	if err != nil {
+		r.repairLog.Printf("WARN: unable to mark chunk %v of %s as stuck: %v", uuc.staticIndex, uuc.staticSiaPath, err)
+	}
+}
+
 // managedBuildUnfinishedChunks will pull all of the unfinished chunks out of a
-// file.
+// file. If skipUnavailableLocal is set, chunks that can't be repaired because
+// their source file is unavailable and they can't be downloaded from the hosts
+// are skipped without updating their stuck status.
 //
 // NOTE: each unfinishedUploadChunk needs its own SiaFileSetEntry. This is due
 // to the SiaFiles being removed from memory. Since the renter does not keep the
@@ -624,7 +1103,7 @@ func (r *Renter) managedBuildUnfinishedChunk(entry *filesystem.FileNode, chunkIn
 // they are done and so cannot share a SiaFileSetEntry as the first chunk to
 // finish would then close the Entry and consequentially impact the remaining
 // chunks.
-func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts map[string]struct{}, target repairTarget, offline, goodForRenew map[string]bool, mm *memoryManager) []*unfinishedUploadChunk {
+func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts map[string]struct{}, target repairTarget, offline, goodForRenew map[string]bool, mm *memoryManager, skipUnavailableLocal bool) []*unfinishedUploadChunk {
 	// If we don't have enough workers for the file, don't repair it right now.
 	minPieces := entry.ErasureCode().MinPieces()
 	r.staticWorkerPool.mu.RLock()
@@ -643,7 +1122,7 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 			// There are not enough hosts in the allowance for the file to reach
 			// minimum redundancy. Mark all chunks as stuck
 			r.log.Printf("WARN: allownace had insufficient hosts for chunk to reach minimum redundancy, have %v need %v for file %v", allowance.Hosts, minPieces, entry.SiaFilePath())
-			if err := entry.SetAllStuck(true); err != nil {
+			if err := r.managedSetAllChunksStuck(entry, true); err != nil {
 				r.log.Println("WARN: unable to mark all chunks as stuck:", err)
 			}
 		}
@@ -651,7 +1130,8 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 	}
 
 	// Assemble chunk indexes, stuck Loop should only be adding stuck chunks and
-	// the repair loop should only be adding unstuck chunks
+	// the repair loop should only be adding unstuck chunks. Abandoned chunks
+	// are ignored by both.
 	var chunkIndexes []uint64
 	for i := uint64(0); i < entry.NumChunks(); i++ {
 		stuck, err := entry.StuckChunkByIndex(i)
@@ -659,6 +1139,14 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 			r.log.Debugln("failed to get 'stuck' status of entry:", err)
 			continue
 		}
+		abandoned, err := entry.AbandonedChunkByIndex(i)
+		if err != nil {
+			r.log.Debugln("failed to get 'abandoned' status of entry:", err)
+			continue
+		}
+		if abandoned {
+			continue
+		}
 		if (target == targetStuckChunks) == stuck {
 			chunkIndexes = append(chunkIndexes, i)
 		}
@@ -675,6 +1163,7 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 	for _, pk := range entry.HostPublicKeys() {
 		pks[string(pk.Key)] = pk
 	}
+	expensiveHosts := r.staticWorkerPool.callExpensiveUploadHosts()
 
 	// Assemble the set of chunks.
 	newUnfinishedChunks := make([]*unfinishedUploadChunk, 0, len(chunkIndexes))
@@ -685,7 +1174,7 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 		}
 
 		// Create unfinishedUploadChunk
-		chunk, err := r.managedBuildUnfinishedChunk(entry, uint64(index), hosts, pks, memoryPriorityLow, offline, goodForRenew, mm)
+		chunk, err := r.managedBuildUnfinishedChunk(entry, uint64(index), hosts, pks, memoryPriorityLow, offline, goodForRenew, expensiveHosts, mm)
 		if err != nil {
 			r.log.Debugln("Error when building an unfinished chunk:", err)
 			continue
@@ -698,7 +1187,8 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 	incompleteChunks := newUnfinishedChunks[:0]
 	for _, chunk := range newUnfinishedChunks {
 		// Check the chunk status. A chunk is repairable if it can be fully
-		// downloaded, or if the source file is available on disk. We also check
+		// downloaded, if the source file is available on disk or if it belongs
+		// to a conversion and can be fetched from the original file. We also check
 		// if the chunk needs repair, which is only true if more than a certain
 		// amount of redundancy is missing. We only repair above a certain
 		// threshold of missing redundancy to minimize the amount of repair work
@@ -709,13 +1199,36 @@ func (r *Renter) managedBuildUnfinishedChunks(entry *filesystem.FileNode, hosts
 		// accessed without error. If there is an error accessing the file then
 		// it is likely that we can not read the file in which case it can not
 		// be used for repair.
-		repairable := chunk.health <= 1 || chunk.onDisk
+		repairable := chunk.health <= 1 || chunk.onDisk || chunk.staticConversion
 		needsRepair := modules.NeedsRepair(chunk.health)
 
+		// Skip chunks that can't be repaired due to their source file being
+		// unavailable if requested. Their stuck status is left untouched.
+		if skipUnavailableLocal && needsRepair && !repairable {
+			if err := r.managedCloseEntry(chunk); err != nil {
+				r.log.Debugln("WARN: unable to close skipped chunk:", err)
+			}
+			continue
+		}
+
 		if r.deps.Disrupt("AddUnrepairableChunks") && needsRepair {
 			incompleteChunks = append(incompleteChunks, chunk)
 			continue
 		}
+		// Skip chunks that recently failed to be repaired until their backoff
+		// expires. Chunks that are below minimum redundancy are never
+		// deferred.
+		if needsRepair && chunk.health <= 1 {
+			failures, lastFailure, err := chunk.fileEntry.RepairFailuresByIndex(chunk.staticIndex)
+			if err != nil {
+				r.log.Debugln("failed to get repair failures of chunk:", err)
+			} else if failures > 0 && time.Since(lastFailure) < repairBackoff(failures) {
+				if err := r.managedSetStuckAndClose(chunk, false); err != nil {
+					r.log.Debugln("WARN: unable to close chunk in repair backoff:", err)
+				}
+				continue
+			}
+		}
 		// Add chunk to list of incompleteChunks if it is incomplete and
 		// repairable or if we are targeting stuck chunks
 		if needsRepair && (repairable || target == targetStuckChunks) {
@@ -775,6 +1288,17 @@ func (r *Renter) managedAddChunksToHeap(hosts map[string]struct{}) (*uniqueRefre
 	// heap is empty
 	offline, goodForRenew, _ := r.managedContractUtilityMaps()
 	consecutiveDirHeapFailures := 0
+	// Keep track of the directories that were pushed back onto the directory
+	// heap to only push each directory back once.
+	pushedBack := make(map[modules.SiaPath]struct{})
+	// Keep track of the directories that yielded to other directories. They
+	// are pushed back onto the directory heap once we are done.
+	var yielded []*directory
+	defer func() {
+		for _, d := range yielded {
+			r.directoryHeap.managedPush(d)
+		}
+	}()
 	for r.uploadHeap.managedLen() < maxUploadHeapChunks && r.directoryHeap.managedLen() > 0 {
 		select {
 		case <-r.tg.StopChan():
@@ -806,17 +1330,58 @@ func (r *Renter) managedAddChunksToHeap(hosts map[string]struct{}) (*uniqueRefre
 			return siaPaths, nil
 		}
 
-		// If the directory that was just popped does not need to be repaired then
-		// return
+		// If the directory that was just popped does not need to be repaired,
+		// make sure its health isn't stale. A bubble might have updated the
+		// directory's metadata since it was pushed onto the heap.
 		heapHealth, _ := dir.managedHeapHealth()
 		if !modules.NeedsRepair(heapHealth) {
+			md, err := r.managedDirectoryMetadata(dir.staticSiaPath)
+			if err != nil {
+				r.repairLog.Println("WARN: unable to refresh the health of the popped directory:", err)
+			} else {
+				dir.managedUpdateHealth(md)
+				heapHealth, _ = dir.managedHeapHealth()
+			}
+		}
+		if !modules.NeedsRepair(heapHealth) {
+			// A worse directory might have been pushed onto the heap after
+			// this directory was popped. Only return if that is not the case.
+			nextDirHealth, _ := r.directoryHeap.managedPeekHealth()
+			if modules.NeedsRepair(nextDirHealth) {
+				continue
+			}
 			r.repairLog.Debugln("no more chunks added to the upload heap because directory popped is healthy")
 			return siaPaths, nil
 		}
 
+		// If the directory has been popped too many times since the last reset
+		// of the directory heap, it yields to the other directories that need
+		// to be repaired. Otherwise a directory that keeps being re-added with
+		// a bad health could starve its siblings and subdirectories.
+		if r.directoryHeap.managedIncrementPopCount(dir.staticSiaPath) > maxDirectoryPopsPerPass {
+			nextDirHealth, _ := r.directoryHeap.managedPeekHealth()
+			if modules.NeedsRepair(nextDirHealth) {
+				r.repairLog.Debugf("directory %v yields to other directories", dir.staticSiaPath)
+				yielded = append(yielded, dir)
+				continue
+			}
+		}
+
 		// Add chunks from the directory to the uploadHeap.
 		r.managedBuildChunkHeap(dir.staticSiaPath, hosts, targetUnstuckChunks, offline, goodForRenew)
 
+		// If the health of the directory got materially worse while its chunks
+		// were added, e.g. because a bubble completed, push it back onto the
+		// directory heap so that it is reconsidered.
+		if _, exists := pushedBack[dir.staticSiaPath]; !exists {
+			pushed, err := r.managedPushBackDirectoryIfWorse(dir.staticSiaPath, heapHealth)
+			if err != nil {
+				r.repairLog.Println("WARN: unable to refresh the health of the repaired directory:", err)
+			} else if pushed {
+				pushedBack[dir.staticSiaPath] = struct{}{}
+			}
+		}
+
 		// Check to see if we are still adding chunks
 		heapLen := r.uploadHeap.managedLen()
 		if heapLen == prevHeapLen {
@@ -851,7 +1416,7 @@ func (r *Renter) managedBuildAndPushRandomChunk(siaPath modules.SiaPath, hosts m
 	offline, goodForRenew, _ := r.managedContractUtilityMaps()
 
 	// Build the unfinished stuck chunks from the file
-	unfinishedUploadChunks := r.managedBuildUnfinishedChunks(file, hosts, target, offline, goodForRenew, mm)
+	unfinishedUploadChunks := r.managedBuildUnfinishedChunks(file, hosts, target, offline, goodForRenew, mm, r.managedSkipUnavailableLocalFiles())
 
 	// Sanity check that there are stuck chunks
 	if len(unfinishedUploadChunks) == 0 {
@@ -867,21 +1432,169 @@ func (r *Renter) managedBuildAndPushRandomChunk(siaPath modules.SiaPath, hosts m
 	defer func() {
 		// Close the unused unfinishedUploadChunks
 		for _, chunk := range unfinishedUploadChunks {
-			allErrs = errors.Compose(allErrs, chunk.fileEntry.Close())
+			allErrs = errors.Compose(allErrs, r.managedCloseEntry(chunk))
 		}
 	}()
 
-	// Push chunk onto the uploadHeap
-	pushed, err := r.managedPushChunkForRepair(randChunk, chunkTypeLocalChunk)
+	// Push chunk onto the uploadHeap and remember the attempt.
+	pushed, err := r.managedPushOrClose(randChunk)
+	if pushed {
+		r.staticStuckCursor.callRecordAttempt(siaPath, file.NumStuckChunks(), time.Now())
+	}
+	return errors.Compose(allErrs, err)
+}
+
+// managedBuildRecoveryChunk builds a chunk for the targetRecoveryChunks repair
+// target. The badHost returned data for the chunk which failed verification.
+// That's why its piece doesn't count towards the chunk's redundancy and the
+// data for the repair needs to be sourced from at least MinPieces other hosts.
+func (r *Renter) managedBuildRecoveryChunk(entry *filesystem.FileNode, chunkIndex uint64, badHost string, hosts map[string]struct{}, offline, goodForRenew map[string]bool, mm *memoryManager) (*unfinishedUploadChunk, error) {
+	// Make sure the chunk can be recovered without the bad host.
+	pieces, err := entry.Pieces(chunkIndex)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to get pieces of chunk")
+	}
+	var piecesWithoutBadHost int
+	for _, pieceSet := range pieces {
+		for _, piece := range pieceSet {
+			if piece.HostPubKey.String() != badHost {
+				piecesWithoutBadHost++
+				break
+			}
+		}
+	}
+	minPieces := entry.ErasureCode().MinPieces()
+	if piecesWithoutBadHost < minPieces {
+		return nil, fmt.Errorf("only %v pieces are stored on other hosts than %v but %v are needed for recovery", piecesWithoutBadHost, badHost, minPieces)
+	}
+
+	// Build the chunk with the bad host being treated as offline. That way its
+	// piece doesn't count and the host can't receive a new piece.
+	offlineWithBadHost := make(map[string]bool, len(offline)+1)
+	for host, isOffline := range offline {
+		offlineWithBadHost[host] = isOffline
+	}
+	offlineWithBadHost[badHost] = true
+	pks := make(map[string]types.SiaPublicKey)
+	for _, pk := range entry.HostPublicKeys() {
+		pks[string(pk.Key)] = pk
+	}
+	chunk, err := r.managedBuildUnfinishedChunk(entry, chunkIndex, hosts, pks, memoryPriorityHigh, offlineWithBadHost, goodForRenew, r.staticWorkerPool.callExpensiveUploadHosts(), mm)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to build recovery chunk")
+	}
+	chunk.recoveryMode = true
+	chunk.staticBadHosts = map[string]struct{}{
+		badHost: {},
+	}
+	return chunk, nil
+}
+
+// managedBuildAndPushRecoveryChunk builds a recovery chunk for the chunk of
+// the file at siaPath with the given index and pushes it onto the upload heap.
+func (r *Renter) managedBuildAndPushRecoveryChunk(siaPath modules.SiaPath, chunkIndex uint64, badHost string) (err error) {
+	file, err := r.staticFileSystem.OpenSiaFile(siaPath)
 	if err != nil {
-		return errors.Compose(allErrs, err, randChunk.fileEntry.Close())
+		return errors.AddContext(err, "unable to open file")
+	}
+	defer func() {
+		err = errors.Compose(err, file.Close())
+	}()
+
+	hosts := r.managedRefreshHostsAndWorkers()
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+	chunk, err := r.managedBuildRecoveryChunk(file, chunkIndex, badHost, hosts, offline, goodForRenew, r.repairMemoryManager)
+	if err != nil {
+		return err
+	}
+	if removeErr := r.uploadHeap.managedRemoveForRecovery(chunk.id); removeErr != nil {
+		r.repairLog.Printf("WARN: unable to close replaced chunk %v of %s: %v", chunkIndex, siaPath, removeErr)
+	}
+	pushed, err := r.managedPushOrClose(chunk)
+	if err != nil {
+		return err
 	}
 	if !pushed {
-		// Chunk wasn't added to the heap. Close the file
-		r.log.Debugln("WARN: stuck chunk", randChunk.id, "wasn't added to heap")
-		allErrs = errors.Compose(allErrs, randChunk.fileEntry.Close())
+		return nil
+	}
+	r.repairLog.Printf("Added chunk %v of %s to the repair heap for recovery after host %v returned bad data", chunkIndex, siaPath, badHost)
+	select {
+	case r.uploadHeap.repairNeeded <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RepairFile immediately adds the chunks of the file at siaPath that need
+// repair to the upload heap instead of waiting for the repair loop to find
+// them. The chunks are prioritized over the chunks added by the repair loops.
+func (r *Renter) RepairFile(siaPath modules.SiaPath) (err error) {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+
+	file, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		return errors.AddContext(err, "unable to open file")
+	}
+	defer func() {
+		err = errors.Compose(err, file.Close())
+	}()
+
+	hosts := r.managedRefreshHostsAndWorkers()
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+	return r.managedRepairFile(file, hosts, offline, goodForRenew)
+}
+
+// managedRepairFile builds the chunks of a file that need repair, stuck or
+// not, and pushes them onto the upload heap with priority.
+func (r *Renter) managedRepairFile(file *filesystem.FileNode, hosts map[string]struct{}, offline, goodForRenew map[string]bool) (err error) {
+	siaPath := r.staticFileSystem.FileSiaPath(file)
+	var chunks []*unfinishedUploadChunk
+	for _, target := range []repairTarget{targetUnstuckChunks, targetStuckChunks} {
+		chunks = append(chunks, r.managedBuildUnfinishedChunks(file, hosts, target, offline, goodForRenew, r.repairMemoryManager, false)...)
+	}
+
+	// Prioritize the chunks and push them onto the heap. Chunks which are
+	// already in the heap without priority are replaced.
+	var pushed int
+	for _, chunk := range chunks {
+		chunk.staticPriority = true
+		if removeErr := r.uploadHeap.managedRemoveUnprioritized(chunk.id); removeErr != nil {
+			r.repairLog.Printf("WARN: unable to close replaced chunk %v of %s: %v", chunk.staticIndex, siaPath, removeErr)
+		}
+		ok, pushErr := r.managedPushOrClose(chunk)
+		if pushErr != nil {
+			err = errors.Compose(err, pushErr)
+			continue
+		}
+		if ok {
+			pushed++
+		}
+	}
+	if pushed == 0 {
+		return err
+	}
+	r.repairLog.Printf("Added %v chunks of %s to the repair heap for an immediate repair", pushed, siaPath)
+	select {
+	case r.uploadHeap.repairNeeded <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// threadedBuildAndPushRecoveryChunk calls managedBuildAndPushRecoveryChunk in
+// a separate thread to avoid blocking the worker which detected the bad data.
+func (r *Renter) threadedBuildAndPushRecoveryChunk(siaPath modules.SiaPath, chunkIndex uint64, badHost string) {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+	err := r.managedBuildAndPushRecoveryChunk(siaPath, chunkIndex, badHost)
+	if err != nil {
+		r.repairLog.Printf("WARN: unable to push chunk %v of %s for recovery: %v", chunkIndex, siaPath, err)
 	}
-	return allErrs
 }
 
 // callBuildAndPushChunks builds the unfinished upload chunks and adds them to
@@ -939,16 +1652,14 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 		}
 
 		// Build unfinished chunks from file and add them to the temp heap.
-		unfinishedUploadChunks := r.managedBuildUnfinishedChunks(file, hosts, target, offline, goodForRenew, r.repairMemoryManager)
+		unfinishedUploadChunks := r.managedBuildUnfinishedChunks(file, hosts, target, offline, goodForRenew, r.repairMemoryManager, r.managedSkipUnavailableLocalFiles())
+		r.staticRepairMetrics.callAdd(time.Now(), modules.RepairMetrics{ChunksDiscovered: uint64(len(unfinishedUploadChunks))})
 		for i := 0; i < len(unfinishedUploadChunks); i++ {
 			chunk := unfinishedUploadChunks[i]
 			// Skip adding this chunk if it is already in the upload heap.
 			if r.uploadHeap.managedExists(chunk.id) {
 				// Close the file entry before skipping the chunk.
-				err := chunk.fileEntry.Close()
-				if err != nil {
-					r.log.Println("Error closing file entry:", err)
-				}
+				_ = r.managedCloseEntry(chunk)
 				// The chunk is already in the heap, so it does not count as
 				// being ignored even though technically we are skipping it. Do
 				// not update the worst health vars based on this chunk.
@@ -956,11 +1667,7 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 			}
 			if wh.canSkip(chunk.health, chunk.onDisk) {
 				// Close the file entry before skipping the chunk.
-				err := chunk.fileEntry.Close()
-				if err != nil {
-					r.log.Println("Error closing file entry:", err)
-				}
-
+				_ = r.managedCloseEntry(chunk)
 				wh.updateWorstIgnoredHealth(chunk.health, chunk.onDisk)
 				continue
 			}
@@ -996,18 +1703,12 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 			chunk = heap.Pop(&tempChunkHeap).(*unfinishedUploadChunk)
 			// Close the file entry, since this chunk is popped, the reset of
 			// the heap won't catch this chunk.
-			err := chunk.fileEntry.Close()
-			if err != nil {
-				r.log.Println("Error closing file entry:", err)
-			}
+			_ = r.managedCloseEntry(chunk)
 			wh.updateWorstIgnoredHealth(chunk.health, chunk.onDisk)
 
 			// Reset the temp heap to throw out all of the chunks that we don't
 			// care about.
-			err = tempChunkHeap.reset()
-			if err != nil {
-				r.log.Println("WARN: error resetting the temporary upload heap:", err)
-			}
+			r.managedResetTempChunkHeap(&tempChunkHeap)
 			// Add all of the bad chunks we saved from earlier back into the
 			// temp heap.
 			for _, chunk := range chunksToKeep {
@@ -1023,25 +1724,17 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 	// the chunks from the temporary heap to the upload heap until either there
 	// are no more temporary chunks or until the upload heap is full.
 	for len(tempChunkHeap) > 0 && (r.uploadHeap.managedLen() < maxUploadHeapChunks || target == targetBackupChunks) {
-		// Add this chunk to the upload heap.
+		// Add this chunk to the upload heap. We don't track the health of a
+		// chunk that was rejected since the only reason it wouldn't be added
+		// to the heap is if it is already in the heap or is currently being
+		// repaired.
 		chunk := heap.Pop(&tempChunkHeap).(*unfinishedUploadChunk)
-		pushed, err := r.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
+		_, err := r.managedPushOrClose(chunk)
 		if err != nil {
-			r.repairLog.Println("WARN: Error pushing chunk for repair", err)
-			err = chunk.fileEntry.Close()
-			if err != nil {
-				r.repairLog.Println("Error closing file entry:", err)
-			}
-			return
-		}
-		if !pushed {
-			// We don't track the health of this chunk since the only reason it
-			// wouldn't be added to the heap is if it is already in the heap or
-			// is currently being repaired. Close the file.
-			err := chunk.fileEntry.Close()
-			if err != nil {
-				r.repairLog.Println("Error closing file entry:", err)
-			}
+			// The chunk still needs to be repaired, so it counts as ignored.
+			// Stop pushing chunks and ignore the remaining ones as well.
+			wh.updateWorstIgnoredHealth(chunk.health, chunk.onDisk)
+			break
 		}
 	}
 
@@ -1052,18 +1745,12 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 		chunk := heap.Pop(&tempChunkHeap).(*unfinishedUploadChunk)
 		// Close the file entry since it's no longer in the temp heap and
 		// therefore will not be caught by the call to reset().
-		err := chunk.fileEntry.Close()
-		if err != nil {
-			r.log.Println("Error closing file entry:", err)
-		}
+		_ = r.managedCloseEntry(chunk)
 		wh.updateWorstIgnoredHealth(chunk.health, chunk.onDisk)
 	}
 	// We are done with the temporary heap, reset it so the resources are closed
 	// and the memory is released.
-	err := tempChunkHeap.reset()
-	if err != nil {
-		r.log.Println("WARN: error resetting the temporary upload heap:", err)
-	}
+	r.managedResetTempChunkHeap(&tempChunkHeap)
 
 	// Check if we were adding backup chunks, if so return here as backups are
 	// not added to the directory heap
@@ -1106,7 +1793,9 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 	// already exist on the directory heap in an unexplored state, as it may
 	// have been added by another thread. When the directory is added under that
 	// race condition, the worst healths of all the directories will be used. We
-	// want to ensure that we don't shadow worse healths in subdirs.
+	// want to ensure that we don't shadow worse healths in subdirs, so the
+	// aggregate health also accounts for the aggregate health of the subdirs
+	// from the directory's metadata.
 	d := &directory{
 		aggregateHealth: wh.health,
 		explored:        true,
@@ -1119,6 +1808,13 @@ func (r *Renter) callBuildAndPushChunks(files []*filesystem.FileNode, hosts map[
 		d.aggregateRemoteHealth = wh.health
 		d.remoteHealth = wh.health
 	}
+	metadata, err := r.managedDirectoryMetadata(dirSiaPath)
+	if err != nil {
+		r.log.Println("WARN: unable to get directory metadata, pushing directory with worst ignored health:", err)
+	} else {
+		d.aggregateHealth = math.Max(d.aggregateHealth, metadata.AggregateHealth)
+		d.aggregateRemoteHealth = math.Max(d.aggregateRemoteHealth, metadata.AggregateRemoteHealth)
+	}
 	// Push the directory back onto the directory heap so that when the current
 	// upload heap is drained, the ignored chunks in this dir will be
 	// reconsidered.
@@ -1177,7 +1873,7 @@ func (r *Renter) managedBuildChunkHeap(dirSiaPath modules.SiaPath, hosts map[str
 		// information updated by bubble this cached health is accurate enough
 		// to use in order to determine if a file has any chunks that need
 		// repair
-		ignore := file.NumChunks() == file.NumStuckChunks() || !modules.NeedsRepair(file.Metadata().CachedHealth)
+		ignore := file.NumChunks() == file.NumStuckChunks()+file.NumAbandonedChunks() || !modules.NeedsRepair(file.Metadata().CachedHealth)
 		if target == targetUnstuckChunks && ignore {
 			err = file.Close()
 			if err != nil {
@@ -1236,6 +1932,8 @@ func (r *Renter) managedBuildChunkHeap(dirSiaPath modules.SiaPath, hosts map[str
 		r.callBuildAndPushChunks(files, hosts, target, offline, goodForRenew)
 	case targetStuckChunks:
 		r.log.Println("stuck repair target used incorrectly")
+	case targetRecoveryChunks:
+		r.log.Println("recovery repair target used incorrectly")
 	case targetUnstuckChunks:
 		r.log.Debugln("Attempting to add chunks to heap")
 		r.callBuildAndPushChunks(files, hosts, target, offline, goodForRenew)
@@ -1300,16 +1998,89 @@ func (r *Renter) managedPushChunkForRepair(uuc *unfinishedUploadChunk, ct chunkT
 	return pushed, nil
 }
 
+// managedPushOrClose pushes a local chunk for repair. If the chunk isn't
+// pushed, the failed push is counted and the chunk's file entry is closed. An
+// error is only returned if pushing the chunk failed, in which case it also
+// contains any error from closing the file entry. Close errors of rejected
+// chunks are logged but not returned.
+func (r *Renter) managedPushOrClose(uuc *unfinishedUploadChunk) (bool, error) {
+	pushed, err := r.managedPushChunkForRepair(uuc, chunkTypeLocalChunk)
+	if err != nil {
+		r.uploadHeap.managedTrackFailedPush(pushFailureError)
+		r.repairLog.Printf("WARN: unable to push chunk %v of %s for repair: %v", uuc.staticIndex, uuc.staticSiaPath, err)
+		return false, errors.Compose(err, r.managedCloseEntry(uuc))
+	}
+	if !pushed {
+		r.uploadHeap.managedTrackFailedPush(pushFailureRejected)
+		r.repairLog.Debugf("Chunk %v of %s wasn't added to the heap", uuc.staticIndex, uuc.staticSiaPath)
+		_ = r.managedCloseEntry(uuc)
+		return false, nil
+	}
+	return true, nil
+}
+
+// managedCloseEntry closes the file entry of a chunk which won't be repaired
+// and logs any error.
+func (r *Renter) managedCloseEntry(uuc *unfinishedUploadChunk) error {
+	err := uuc.managedClose(r.repairLog)
+	if err != nil {
+		r.managedTrackCloses(0, 1)
+	} else {
+		r.managedTrackCloses(1, 0)
+	}
+	return err
+}
+
+// managedTrackCloses updates the failed close counter of the upload heap and
+// the alert if the counter changed.
+func (r *Renter) managedTrackCloses(closed, failed uint64) {
+	if r.uploadHeap.managedTrackCloses(closed, failed) {
+		r.managedUpdateFailedClosesAlert()
+	}
+}
+
+// managedResetTempChunkHeap resets a temporary chunk heap, closing the file
+// entries of its chunks.
+func (r *Renter) managedResetTempChunkHeap(uch *uploadChunkHeap) {
+	n := uint64(len(*uch))
+	failed, err := uch.reset()
+	if err != nil {
+		r.repairLog.Println("WARN: error resetting the temporary upload heap:", err)
+	}
+	r.managedTrackCloses(n-failed, failed)
+}
+
+// managedUpdateFailedClosesAlert registers an alert if the file entries of any
+// chunks failed to close since the last successful close and unregisters it
+// otherwise. The alert is updated with the current number of failures.
+func (r *Renter) managedUpdateFailedClosesAlert() {
+	failed := r.uploadHeap.managedFailedCloses()
+	if failed == 0 {
+		r.staticAlerter.UnregisterAlert(modules.AlertIDRenterFailedFileCloses)
+		return
+	}
+	cause := fmt.Sprintf("the file entries of %v chunks failed to close", failed)
+	r.staticAlerter.RegisterAlert(modules.AlertIDRenterFailedFileCloses, AlertMSGFailedFileCloses, cause, modules.SeverityWarning)
+}
+
 // managedPrepareNextChunk takes the next chunk from the chunk heap and prepares
 // it for upload. Preparation includes blocking until enough memory is
 // available, fetching the logical data for the chunk (either from the disk or
 // from the network), erasure coding the logical data into the physical data,
 // and then finally passing the work onto the workers.
 func (r *Renter) managedPrepareNextChunk(uuc *unfinishedUploadChunk) error {
+	// Wait until the number of concurrently repaired chunks drops below the
+	// limit.
+	err := r.staticRepairLimiter.managedAcquire(r.managedMaxConcurrentRepairs, r.tg.StopChan())
+	if err != nil {
+		return err
+	}
 	// Grab the next chunk, loop until we have enough memory, update the amount
 	// of memory available, and then spin up a thread to asynchronously handle
-	// the rest of the chunk tasks.
+	// the rest of the chunk tasks. The thread releases the slot of the
+	// repair limiter once it is done.
 	if !uuc.staticMemoryManager.Request(context.Background(), uuc.staticMemoryNeeded, uuc.staticPriority) {
+		r.staticRepairLimiter.callRelease()
 		return errors.New("couldn't request memory")
 	}
 	go r.threadedFetchAndRepairChunk(uuc)
@@ -1336,13 +2107,119 @@ func (r *Renter) managedRefreshHostsAndWorkers() map[string]struct{} {
 	currentContracts := r.hostContractor.Contracts()
 	hosts := make(map[string]struct{})
 	for _, contract := range currentContracts {
-		hosts[contract.HostPublicKey.String()] = struct{}{}
+		// Skip the hosts of disabled workers. They can't receive any pieces
+		// and shouldn't be considered unused hosts for a chunk.
+		hostKey := contract.HostPublicKey.String()
+		if r.staticWorkerPool.callIsDisabled(hostKey) {
+			continue
+		}
+		hosts[hostKey] = struct{}{}
 	}
 	// Refresh the worker pool as well.
 	r.staticWorkerPool.callUpdate()
 	return hosts
 }
 
+// managedBackupsNeedingRepair walks the whole backup directory tree and returns
+// the siapaths of the snapshot siafiles that need to be repaired, ordered from
+// the most recent snapshot to the oldest one.
+func (r *Renter) managedBackupsNeedingRepair(offline, goodForRenew map[string]bool, contracts map[string]modules.RenterContract) ([]modules.SiaPath, error) {
+	var mu sync.Mutex
+	var infos []modules.FileInfo
+	flf := func(info modules.FileInfo) {
+		if !modules.NeedsRepair(info.Health) {
+			return
+		}
+		mu.Lock()
+		infos = append(infos, info)
+		mu.Unlock()
+	}
+	hostSubnets := r.managedContractHostSubnets(contracts)
+	err := r.staticFileSystem.List(modules.BackupFolder, true, offline, goodForRenew, hostSubnets, contracts, flf, func(modules.DirectoryInfo) {})
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to list backup directory")
+	}
+
+	// Look up the creation dates of the snapshots. Siafiles without a matching
+	// snapshot are repaired last.
+	creationDates := make(map[modules.SiaPath]types.Timestamp)
+	id := r.mu.RLock()
+	for _, ub := range r.persist.UploadedBackups {
+		sp, err := modules.BackupFolder.Join(ub.Name)
+		if err != nil {
+			continue
+		}
+		creationDates[sp] = ub.CreationDate
+	}
+	r.mu.RUnlock(id)
+
+	sort.Slice(infos, func(i, j int) bool {
+		return creationDates[infos[i].SiaPath] > creationDates[infos[j].SiaPath]
+	})
+	siaPaths := make([]modules.SiaPath, 0, len(infos))
+	for _, info := range infos {
+		siaPaths = append(siaPaths, info.SiaPath)
+	}
+	return siaPaths, nil
+}
+
+// managedAddBackupChunksToHeap adds the backup chunks that need to be repaired
+// to the upload heap. This needs to be handled separately because currently
+// the filesystem for storing system files and chunks such as those related to
+// snapshot backups is different from the siafileset that stores non-system
+// files and chunks. Only the chunks of the most recent snapshot which needs
+// repair are added, to make sure it becomes safe as soon as possible. The
+// older snapshots are resumed once it is uploaded, or right away if none of
+// the chunks of the newer one can be pushed to the heap. The number of added
+// chunks is returned.
+func (r *Renter) managedAddBackupChunksToHeap(hosts map[string]struct{}) int {
+	heapLen := r.uploadHeap.managedLen()
+	offline, goodForRenew, contracts := r.managedContractUtilityMaps()
+	siaPaths, err := r.managedBackupsNeedingRepair(offline, goodForRenew, contracts)
+	if err != nil {
+		r.repairLog.Println("WARN: unable to get the backups needing repair:", err)
+		return 0
+	}
+	var numBackupChunks int
+	for _, siaPath := range siaPaths {
+		file, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err != nil {
+			r.repairLog.Printf("WARN: unable to open backup %v: %v", siaPath, err)
+			continue
+		}
+		r.callBuildAndPushChunks([]*filesystem.FileNode{file}, hosts, targetBackupChunks, offline, goodForRenew)
+		// The snapshot is being repaired if any of its chunks made it into the
+		// heap, either now or during a previous call.
+		inProgress := r.uploadHeap.managedHasFileChunks(file.UID())
+		if err := file.Close(); err != nil {
+			r.log.Println("WARN: Could not close file:", file.SiaFilePath(), err)
+		}
+		numBackupChunks = r.uploadHeap.managedLen() - heapLen
+		if inProgress {
+			break
+		}
+	}
+	if numBackupChunks > 0 {
+		r.repairLog.Printf("Added %v backup chunks to the upload heap", numBackupChunks)
+	}
+	return numBackupChunks
+}
+
+// managedRepairBackupChunks adds the backup chunks that need to be repaired to
+// the upload heap and runs the repair loop on them without waiting for the
+// directory heap to be explored. The hosts and workers are refreshed first
+// since the repair loop might have been waiting for a long time.
+func (r *Renter) managedRepairBackupChunks() {
+	hosts := r.managedRefreshHostsAndWorkers()
+	if r.managedAddBackupChunksToHeap(hosts) == 0 {
+		return
+	}
+	r.staticWorkerPool.callUpdateMaxActiveWorkers(r.uploadHeap.managedLen(), r.uploadHeap.managedMaxPiecesNeeded())
+	if err := r.managedRepairLoop(); err != nil {
+		r.repairLog.Println("WARN: there was an error in the backup repair loop:", err)
+	}
+}
+
 // managedRepairLoop works through the uploadheap repairing chunks. The repair
 // loop will continue until the renter stops, there are no more chunks, or the
 // number of chunks in the uploadheap has dropped below the minUploadHeapSize
@@ -1393,7 +2270,17 @@ func (r *Renter) managedRepairLoop() error {
 			return nil
 		}
 		chunkPath := nextChunk.staticSiaPath
-		r.repairLog.Printf("Repairing chunk %v of %s, currently have %v out of %v pieces", nextChunk.staticIndex, chunkPath, nextChunk.piecesCompleted, nextChunk.staticPiecesNeeded)
+
+		// Skip the chunk if its file was deleted in the meantime. There is no
+		// need to request memory for it.
+		if nextChunk.fileEntry.Deleted() {
+			r.repairLog.Printf("Skipping chunk %v of %s since the file was deleted", nextChunk.staticIndex, chunkPath)
+			_ = r.managedCloseEntry(nextChunk)
+			// Remove the chunk from the repairingChunks map
+			r.uploadHeap.managedMarkRepairDone(nextChunk)
+			continue
+		}
+		r.repairLog.Printf("Repairing chunk %v of %s, currently have %v out of %v pieces, spent %v in the heap after %v failed repair attempts", nextChunk.staticIndex, chunkPath, nextChunk.piecesCompleted, nextChunk.staticPiecesNeeded, time.Since(nextChunk.enqueuedAt), nextChunk.repairAttempts)
 
 		// Make sure we have enough workers for this chunk to reach minimum
 		// redundancy.
@@ -1414,7 +2301,7 @@ func (r *Renter) managedRepairLoop() error {
 					// chunk to reach minimum redundancy. Log an error, set the
 					// chunk as stuck, and close the file
 					r.repairLog.Printf("Allowance has insufficient hosts for %s, have %v, need %v", chunkPath, allowance.Hosts, nextChunk.staticMinimumPieces)
-					err := nextChunk.fileEntry.SetStuck(nextChunk.staticIndex, true)
+					err := r.managedSetChunkStuck(nextChunk.fileEntry, nextChunk.staticIndex, true)
 					if err != nil {
 						r.repairLog.Printf("WARN: unable to mark chunk %v of %s as stuck: %v", nextChunk.staticIndex, chunkPath, err)
 					}
@@ -1423,8 +2310,11 @@ func (r *Renter) managedRepairLoop() error {
 
 			// There are enough hosts set in the allowance so this is a
 			// temporary issue with available workers, just ignore the chunk
-			// for now and close the file
-			nextChunk.fileEntry.Close()
+			// for now and close the file. The repair still counts as a failed
+			// attempt to prevent the chunk from cycling through the heap
+			// forever.
+			r.managedRepairAttemptFailed(nextChunk)
+			_ = r.managedCloseEntry(nextChunk)
 			// Remove the chunk from the repairingChunks map
 			r.uploadHeap.managedMarkRepairDone(nextChunk)
 			continue
@@ -1443,7 +2333,7 @@ func (r *Renter) managedRepairLoop() error {
 			// we will just close the chunk file entry instead of marking it as
 			// stuck
 			r.repairLog.Printf("WARN: error while preparing chunk %v from %s: %v", nextChunk.staticIndex, chunkPath, err)
-			nextChunk.fileEntry.Close()
+			_ = r.managedCloseEntry(nextChunk)
 			// Remove the chunk from the repairingChunks map
 			r.uploadHeap.managedMarkRepairDone(nextChunk)
 			continue
@@ -1484,7 +2374,14 @@ func (r *Renter) threadedUploadAndRepair() {
 		default:
 		}
 
+		// Update the alert for chunks that failed to close. Closes that fail
+		// while resetting the upload heap are only counted.
+		r.managedUpdateFailedClosesAlert()
+
 		// Wait until the contractor is synced.
+		if !r.cs.Synced() {
+			r.staticRepairLoopState.callSetPhase(modules.RepairLoopPhaseBlocked, "waiting for the consensus set to be synced")
+		}
 		if !r.managedBlockUntilSynced() {
 			// The renter shut down before the contract was synced.
 			return
@@ -1492,6 +2389,9 @@ func (r *Renter) threadedUploadAndRepair() {
 
 		// Wait until the renter is online to proceed. This function will return
 		// 'false' if the renter has shut down before being online.
+		if !r.g.Online() {
+			r.staticRepairLoopState.callSetPhase(modules.RepairLoopPhaseBlocked, "waiting for the renter to be online")
+		}
 		if !r.managedBlockUntilOnline() {
 			return
 		}
@@ -1499,6 +2399,7 @@ func (r *Renter) threadedUploadAndRepair() {
 		// Check if repair process has been paused
 		if r.uploadHeap.managedIsPaused() {
 			r.repairLog.Println("Repairs and Uploads have been paused")
+			r.staticRepairLoopState.callSetPhase(modules.RepairLoopPhasePaused, "")
 			// Block until the repair process is restarted
 			select {
 			case <-r.tg.StopChan():
@@ -1513,7 +2414,7 @@ func (r *Renter) threadedUploadAndRepair() {
 				r.repairLog.Println("WARN: there was an error resetting the upload heap:", err)
 			}
 			r.directoryHeap.managedReset()
-			err = r.managedPushUnexploredDirectory(modules.RootSiaPath())
+			err = r.managedInitDirectoryHeap()
 			if err != nil {
 				r.repairLog.Println("WARN: there was an error pushing an unexplored root directory onto the directory heap:", err)
 			}
@@ -1527,6 +2428,8 @@ func (r *Renter) threadedUploadAndRepair() {
 			continue
 		}
 
+		r.staticRepairLoopState.callSetPhase(modules.RepairLoopPhaseActive, "")
+
 		// Refresh the worker set.
 		hosts := r.managedRefreshHostsAndWorkers()
 
@@ -1535,30 +2438,24 @@ func (r *Renter) threadedUploadAndRepair() {
 		if time.Now().After(resetTime) {
 			resetTime = time.Now().Add(repairLoopResetFrequency)
 			r.directoryHeap.managedReset()
-			err = r.managedPushUnexploredDirectory(modules.RootSiaPath())
+			err = r.managedInitDirectoryHeap()
 			if err != nil {
 				r.repairLog.Println("WARN: error re-initializing the directory heap:", err)
 			}
 		}
 
-		// Add any chunks from the backup heap that need to be repaired. This
-		// needs to be handled separately because currently the filesystem for
-		// storing system files and chunks such as those related to snapshot
-		// backups is different from the siafileset that stores non-system files
-		// and chunks.
-		heapLen := r.uploadHeap.managedLen()
-		offline, goodForRenew, _ := r.managedContractUtilityMaps()
-		r.managedBuildChunkHeap(modules.BackupFolder, hosts, targetBackupChunks, offline, goodForRenew)
-		numBackupChunks := r.uploadHeap.managedLen() - heapLen
-		if numBackupChunks > 0 {
-			r.repairLog.Printf("Added %v backup chunks to the upload heap", numBackupChunks)
-		}
+		// Add any chunks from the backup heap that need to be repaired.
+		r.managedAddBackupChunksToHeap(hosts)
+
+		// Replace the files whose conversions are done. This also finishes
+		// conversions that were interrupted by a shutdown.
+		r.managedFinishConversions()
 
 		// Check if there is work to do. If the filesystem is healthy and the
 		// heap is empty, there is no work to do and the thread should block
 		// until there is work to do.
 		dirHeapHealth, _ := r.directoryHeap.managedPeekHealth()
-		if r.uploadHeap.managedLen() == 0 && !modules.NeedsRepair(dirHeapHealth) {
+		if r.uploadHeap.managedPeek() == nil && !modules.NeedsRepair(dirHeapHealth) {
 			// TODO: This has a tiny window where it might be dumping out chunks
 			// that need health, if the upload call is appending to the
 			// directory heap because there is a new upload.
@@ -1572,16 +2469,24 @@ func (r *Renter) threadedUploadAndRepair() {
 
 			// If the file system is healthy then block until there is a new
 			// upload or there is a repair that is needed.
+			r.staticRepairLoopState.callSetPhase(modules.RepairLoopPhaseSleeping, "")
 			select {
 			case <-r.uploadHeap.newUploads:
 				r.repairLog.Debugln("repair loop triggered by new upload channel")
 			case <-r.uploadHeap.repairNeeded:
 				r.repairLog.Debugln("repair loop triggered by repair needed channel")
+			case <-r.uploadHeap.backupNeeded:
+				// The rest of the filesystem is healthy so there is no need to
+				// rebuild the directory heap. Repair the backup chunks right
+				// away and go back to waiting afterwards.
+				r.repairLog.Debugln("repair loop triggered by backup needed channel")
+				r.managedRepairBackupChunks()
+				continue
 			case <-r.tg.StopChan():
 				return
 			}
 
-			err = r.managedPushUnexploredDirectory(modules.RootSiaPath())
+			err = r.managedInitDirectoryHeap()
 			if err != nil {
 				// If there is an error initializing the directory heap log
 				// the error. We don't want to sleep here as we were trigger
@@ -1596,7 +2501,9 @@ func (r *Renter) threadedUploadAndRepair() {
 		}
 
 		// Add chunks to heap.
+		r.directoryHeapMu.Lock()
 		dirSiaPaths, err := r.managedAddChunksToHeap(hosts)
+		r.directoryHeapMu.Unlock()
 		if err != nil {
 			// Log the error but don't sleep as there are potentially chunks in
 			// the heap from new uploads. If the heap is empty the next check
@@ -1616,6 +2523,8 @@ func (r *Renter) threadedUploadAndRepair() {
 		if uploadHeapLen > 0 {
 			r.repairLog.Printf("Executing an upload and repair cycle, uploadHeap has %v chunks in it", uploadHeapLen)
 		}
+		// Scale the number of active workers according to the heap depth.
+		r.staticWorkerPool.callUpdateMaxActiveWorkers(uploadHeapLen, r.uploadHeap.managedMaxPiecesNeeded())
 		err = r.managedRepairLoop()
 		if err != nil {
 			// If there was an error with the repair loop sleep for a little bit
@@ -1627,6 +2536,8 @@ func (r *Renter) threadedUploadAndRepair() {
 			case <-r.tg.StopChan():
 				return
 			}
+		} else {
+			r.staticRepairLoopState.callCompleteCycle(time.Now())
 		}
 
 		// Update the filesystem.
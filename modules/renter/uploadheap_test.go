@@ -4,20 +4,27 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
+	"unsafe"
 
 	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter/filesystem"
+	"go.sia.tech/siad/modules/renter/filesystem/siadir"
 	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
 )
 
 // TestUploadHeap tests the uploadheap subsystem
@@ -34,17 +41,34 @@ func TestUploadHeap(t *testing.T) {
 	t.Run("managedAddChunkToHeap", testManagedAddChunksToHeap)
 	t.Run("managedBuildChunkHeap", testManagedBuildChunkHeap)
 	t.Run("managedBuildUnfinishedChunks", testManagedBuildUnfinishedChunks)
+	t.Run("managedDrainStuckChunks", testManagedDrainStuckChunks)
+	t.Run("managedPeek", testManagedPeek)
+	t.Run("managedPrune", testManagedPrune)
 	t.Run("managedPushChunkForRepair", testManagedPushChunkForRepair)
+	t.Run("managedPushOrClose", testManagedPushOrClose)
+	t.Run("managedRemoveByFileUID", testManagedRemoveByFileUID)
 	t.Run("managedTryUpdate", testManagedTryUpdate)
 
 	// Specific condition unit tests
+	t.Run("AbandonedChunks", testAbandonedChunks)
 	t.Run("AddChunksToHeapPanic", testAddChunksToHeapPanic)
 	t.Run("AddDirectories", testAddDirectoryBackToHeap)
+	t.Run("BackupNeeded", testBackupNeeded)
+	t.Run("BackupOrder", testBackupChunksOrder)
+	t.Run("DoubleClose", testChunkDoubleClose)
+	t.Run("ExpensiveWorkers", testExpensiveWorkers)
 	t.Run("HeapMaps", testUploadHeapMaps)
 	t.Run("PauseChan", testUploadHeapPauseChan)
+	t.Run("RecoveryChunks", testRecoveryChunks)
 	t.Run("RemoteChunks", testAddRemoteChunksToHeap)
+	t.Run("RepairBackoff", testRepairBackoff)
+	t.Run("RepairFile", testRepairFile)
+	t.Run("MaxRepairAttempts", testMaxRepairAttempts)
+	t.Run("SkipUnavailableLocal", testSkipUnavailableLocal)
 
 	// Regression Tests
+	t.Run("Regression_DirectoryStarvation", testAddChunksToHeapDirectoryStarvation)
+	t.Run("Regression_StaleDirectoryHealth", testAddChunksToHeapStaleDirHealth)
 	t.Run("Regression_SwitchStuckStatus", testChunkSwitchStuckStatus)
 }
 
@@ -107,7 +131,7 @@ func testManagedBuildUnfinishedChunks(t *testing.T) {
 
 	// Call managedBuildUnfinishedChunks as not stuck loop, all un stuck chunks
 	// should be returned
-	uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager)
+	uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
 	if len(uucs) != int(f.NumChunks())-1 {
 		t.Fatalf("Incorrect number of chunks returned, expected %v got %v", int(f.NumChunks())-1, len(uucs))
 	}
@@ -119,7 +143,7 @@ func testManagedBuildUnfinishedChunks(t *testing.T) {
 
 	// Call managedBuildUnfinishedChunks as stuck loop, all stuck chunks should
 	// be returned
-	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager)
+	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
 	if len(uucs) != 1 {
 		t.Fatalf("Incorrect number of chunks returned, expected 1 got %v", len(uucs))
 	}
@@ -137,7 +161,7 @@ func testManagedBuildUnfinishedChunks(t *testing.T) {
 
 	// Call managedBuildUnfinishedChunks as not stuck loop, since the file is
 	// now not repairable it should return no chunks
-	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager)
+	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
 	if len(uucs) != 0 {
 		t.Fatalf("Incorrect number of chunks returned, expected 0 got %v", len(uucs))
 	}
@@ -146,7 +170,7 @@ func testManagedBuildUnfinishedChunks(t *testing.T) {
 	// returned because they should have been marked as stuck by the previous
 	// call and stuck chunks should still be returned if the file is not
 	// repairable
-	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager)
+	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
 	if len(uucs) != int(f.NumChunks()) {
 		t.Fatalf("Incorrect number of chunks returned, expected %v got %v", f.NumChunks(), len(uucs))
 	}
@@ -157,6 +181,189 @@ func testManagedBuildUnfinishedChunks(t *testing.T) {
 	}
 }
 
+// testManagedPeek verifies that managedPeek returns the top chunk of the heap
+// without removing it.
+func testManagedPeek(t *testing.T) {
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	uh := &rt.renter.uploadHeap
+
+	// Peeking an empty heap should return nil.
+	if uh.managedPeek() != nil {
+		t.Fatal("expected nil chunk from empty heap")
+	}
+
+	// Add chunks to the heap.
+	err = addChunksOfDifferentHealth(rt.renter, 3, false, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	numChunks := uh.managedLen()
+	if numChunks == 0 {
+		t.Fatal("expected chunks in the heap")
+	}
+
+	// Peeking shouldn't remove the chunk and should return the same chunk as
+	// the following pop.
+	for i := 0; i < numChunks; i++ {
+		peeked := uh.managedPeek()
+		if peeked == nil {
+			t.Fatal("expected chunk from non-empty heap")
+		}
+		if uh.managedPeek() != peeked {
+			t.Fatal("peeking twice should return the same chunk")
+		}
+		if uh.managedLen() != numChunks-i {
+			t.Fatalf("expected %v chunks in heap but got %v", numChunks-i, uh.managedLen())
+		}
+		popped := uh.managedPop()
+		if popped != peeked {
+			t.Fatal("peeked chunk doesn't match popped chunk")
+		}
+	}
+	if uh.managedPeek() != nil {
+		t.Fatal("expected nil chunk from empty heap")
+	}
+}
+
+// testRepairBackoff checks that chunks which failed to be repaired are skipped
+// until their backoff expires and that a successful repair resets their
+// failures.
+func testRepairBackoff(t *testing.T) {
+	// Check the backoff itself.
+	if repairBackoff(0) != 0 {
+		t.Fatal("no failures shouldn't result in a backoff", repairBackoff(0))
+	}
+	if repairBackoff(1) != repairFailureBackoff || repairBackoff(3) != 4*repairFailureBackoff {
+		t.Fatal("unexpected backoff", repairBackoff(1), repairBackoff(3))
+	}
+	if repairBackoff(math.MaxUint8) != maxRepairFailureBackoff {
+		t.Fatal("backoff should be capped", repairBackoff(math.MaxUint8))
+	}
+
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a file on disk with more than 1 chunk.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 2)
+	siaPath, err := modules.NewSiaPath("backoffFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rt.renter.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if f.NumChunks() <= 1 {
+		t.Fatalf("File created with not enough chunks for test, have %v need at least 2", f.NumChunks())
+	}
+
+	// Upload a single piece of every chunk to a good host. That way the
+	// chunks are at minimum redundancy and need repair.
+	hpk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	for i := uint64(0); i < f.NumChunks(); i++ {
+		if err := f.AddPiece(hpk, i, 0, crypto.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hosts := map[string]struct{}{hpk.String(): {}}
+	offline := map[string]bool{hpk.String(): false}
+	goodForRenew := map[string]bool{hpk.String(): true}
+
+	// Manually add workers to worker pool
+	rt.renter.staticWorkerPool.mu.Lock()
+	for i := 0; i < int(f.NumChunks()); i++ {
+		rt.renter.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	rt.renter.staticWorkerPool.mu.Unlock()
+
+	// buildChunks is a helper to build the unfinished chunks and close them
+	// again. It returns the chunk for index 0 if it was built.
+	buildChunks := func(target repairTarget) (int, *unfinishedUploadChunk) {
+		uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, target, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+		var first *unfinishedUploadChunk
+		for _, c := range uucs {
+			if c.id.index == 0 {
+				first = c
+			}
+			if err := c.fileEntry.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return len(uucs), first
+	}
+
+	// Fail the repair of the first chunk. It should be marked as stuck and
+	// have a failure.
+	n, uc := buildChunks(targetUnstuckChunks)
+	if n != int(f.NumChunks()) || uc == nil {
+		t.Fatalf("Incorrect number of chunks returned, expected %v got %v", f.NumChunks(), n)
+	}
+	rt.renter.managedUpdateUploadChunkStuckStatus(uc)
+	chunks, err := rt.renter.FileChunks(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chunks[0].Stuck || chunks[0].RepairFailures != 1 || chunks[0].LastRepairFailure.IsZero() {
+		t.Fatal("unexpected chunk info", chunks[0])
+	}
+
+	// The stuck loop shouldn't pick up the chunk until the backoff expired.
+	if n, _ := buildChunks(targetStuckChunks); n != 0 {
+		t.Fatalf("Incorrect number of chunks returned, expected 0 got %v", n)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		n, uc = buildChunks(targetStuckChunks)
+		if n != 1 || uc == nil {
+			return fmt.Errorf("Incorrect number of chunks returned, expected 1 got %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Repair the chunk successfully. That should reset the failures.
+	uc.piecesCompleted = uc.staticPiecesNeeded
+	rt.renter.managedUpdateUploadChunkStuckStatus(uc)
+	chunks, err = rt.renter.FileChunks(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chunks[0].Stuck || chunks[0].RepairFailures != 0 || !chunks[0].LastRepairFailure.IsZero() {
+		t.Fatal("unexpected chunk info", chunks[0])
+	}
+}
+
 // testManagedBuildChunkHeap probes managedBuildChunkHeap to make sure that the
 // correct chunks are being added to the heap
 func testManagedBuildChunkHeap(t *testing.T) {
@@ -638,6 +845,210 @@ func testAddRemoteChunksToHeap(t *testing.T) {
 	}
 }
 
+// testAddChunksToHeapDirectoryStarvation is a regression test that verifies
+// that a directory which keeps getting re-added to the directory heap with a
+// bad health can't starve its subdirectories.
+func testAddChunksToHeapDirectoryStarvation(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a two level directory tree. The parent contains a file with more
+	// chunks than fit into the upload heap. The child contains a file with a
+	// single chunk that has a better health than the chunks of the parent.
+	source, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentRSC, _ := modules.NewRSCode(1, 1)
+	childRSC, _ := modules.NewRSCode(1, 2)
+	parentFile, err := modules.NewSiaPath("parent/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	childFile, err := modules.NewSiaPath("parent/child/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(parentFile, source, parentRSC, crypto.GenerateSiaKey(crypto.RandomCipherType()), uint64(2*maxUploadHeapChunks)*modules.SectorSize, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(childFile, source, childRSC, crypto.GenerateSiaKey(crypto.RandomCipherType()), 100, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rt.renter.staticFileSystem.OpenSiaFile(childFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	childUID := f.UID()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bubble the directories to make sure the metadata is updated.
+	dirSiaPaths := rt.renter.newUniqueRefreshPaths()
+	for _, siaPath := range []modules.SiaPath{parentFile, childFile} {
+		dirSiaPath, err := siaPath.Dir()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = dirSiaPaths.callAdd(dirSiaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	dirSiaPaths.callRefreshAllBlocking()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		md, err := rt.renter.managedDirectoryMetadata(modules.RootSiaPath())
+		if err != nil {
+			return err
+		}
+		if !modules.NeedsRepair(md.AggregateHealth) {
+			return errors.New("root health not updated")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Manually add workers to worker pool and create host map
+	hosts := make(map[string]struct{})
+	rt.renter.staticWorkerPool.mu.Lock()
+	for i := 0; i < childRSC.NumPieces(); i++ {
+		rt.renter.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	rt.renter.staticWorkerPool.mu.Unlock()
+
+	// Make sure directory Heap is ready
+	err = rt.renter.managedPushUnexploredDirectory(modules.RootSiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Repeatedly add chunks to the upload heap without resetting the directory
+	// heap. The upload heap is drained in between to simulate repairs that
+	// don't improve the health of the parent's chunks. Eventually the chunk of
+	// the child should be added to the upload heap.
+	var found bool
+	for i := 0; i <= maxDirectoryPopsPerPass && !found; i++ {
+		_, err = rt.renter.managedAddChunksToHeap(hosts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rt.renter.uploadHeap.managedLen() > 0 {
+			chunk := rt.renter.uploadHeap.managedPop()
+			found = found || chunk.id.fileUID == childUID
+			rt.renter.uploadHeap.managedMarkRepairDone(chunk)
+			if err := chunk.fileEntry.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !found {
+		t.Fatal("chunk of the child directory was never added to the upload heap")
+	}
+}
+
+// testAddChunksToHeapStaleDirHealth is a regression test that verifies that
+// managedAddChunksToHeap doesn't skip a directory because of a stale health on
+// the directory heap.
+func testAddChunksToHeapStaleDirHealth(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a file in a sub directory.
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("dir/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(siaPath, source, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 100, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rt.renter.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uid := f.UID()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	dirSiaPath, err := siaPath.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bubble the directory to make sure its metadata reflects the unhealthy
+	// file.
+	dirSiaPaths := rt.renter.newUniqueRefreshPaths()
+	err = dirSiaPaths.callAdd(dirSiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirSiaPaths.callRefreshAllBlocking()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		md, err := rt.renter.managedDirectoryMetadata(dirSiaPath)
+		if err != nil {
+			return err
+		}
+		if !modules.NeedsRepair(md.AggregateHealth) {
+			return fmt.Errorf("directory health not updated, %v", md.AggregateHealth)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Manually add workers to worker pool and create host map
+	hosts := make(map[string]struct{})
+	rt.renter.staticWorkerPool.mu.Lock()
+	for i := 0; i < rsc.MinPieces(); i++ {
+		rt.renter.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	rt.renter.staticWorkerPool.mu.Unlock()
+
+	// Push the directory with stale, healthy metadata onto the directory heap.
+	rt.renter.directoryHeap.managedPushDirectory(dirSiaPath, siadir.Metadata{}, true)
+
+	// The chunk of the file should be added to the upload heap even though
+	// the directory looked healthy when it was popped.
+	_, err = rt.renter.managedAddChunksToHeap(hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.renter.uploadHeap.managedLen() != 1 {
+		t.Fatal("Expected 1 chunk in the upload heap, got", rt.renter.uploadHeap.managedLen())
+	}
+	chunk := rt.renter.uploadHeap.managedPop()
+	if chunk.id.fileUID != uid {
+		t.Fatal("unexpected chunk in the upload heap")
+	}
+}
+
 // testAddDirectoryBackToHeap ensures that when not all the chunks in a
 // directory are added to the uploadHeap that the directory is added back to the
 // directoryHeap with an updated Health
@@ -886,38 +1297,14 @@ func testUploadHeapMaps(t *testing.T) {
 	}
 }
 
-// testUploadHeapPauseChan makes sure that sequential calls to pause and resume
-// won't cause panics for closing a closed channel
-func testUploadHeapPauseChan(t *testing.T) {
-	// Initial UploadHeap with the pauseChan initialized such that the uploads
-	// and repairs are not paused
-	uh := uploadHeap{
-		pauseChan: make(chan struct{}),
-	}
-	close(uh.pauseChan)
-	if uh.managedIsPaused() {
-		t.Error("Repairs and Uploads should not be paused")
-	}
-
-	// Call resume on an initialized heap
-	uh.managedResume()
-
-	// Call Pause twice in a row
-	uh.managedPause(DefaultPauseDuration)
-	uh.managedPause(DefaultPauseDuration)
-	// Call Resume twice in a row
-	uh.managedResume()
-	uh.managedResume()
-}
-
-// testChunkSwitchStuckStatus is a regression test that confirms the upload heap
-// won't panic due to a chunk's stuck status changing while it is in the heap
-// and being added twice
-func testChunkSwitchStuckStatus(t *testing.T) {
-	// Create renter
-	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
-	if err != nil {
-		t.Fatal(err)
+// testManagedDrainStuckChunks verifies that managedDrainStuckChunks removes all
+// stuck chunks from the heap and its maps while leaving unstuck and repairing
+// chunks alone.
+func testManagedDrainStuckChunks(t *testing.T) {
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
 	}
 	defer func() {
 		if err := rt.Close(); err != nil {
@@ -925,63 +1312,104 @@ func testChunkSwitchStuckStatus(t *testing.T) {
 		}
 	}()
 
-	// Create minimum chunk
-	chunk := &unfinishedUploadChunk{
-		id: uploadChunkID{
-			fileUID: siafile.SiafileUID("chunk"),
-			index:   0,
-		},
-		staticMemoryManager: rt.renter.repairMemoryManager,
-	}
-	// push chunk to heap
-	pushed, err := rt.renter.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
+	// Add stuck and unstuck chunks to the heap.
+	numHeapChunks := uint64(10)
+	sf, err := rt.renter.newRenterTestFile()
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !pushed {
-		t.Fatal("unable to push chunk", chunk)
+	for i := uint64(0); i < numHeapChunks; i++ {
+		chunk := &unfinishedUploadChunk{
+			id: uploadChunkID{
+				fileUID: siafile.SiafileUID(fmt.Sprintf("chunk - %v", i)),
+				index:   i,
+			},
+			fileEntry:                 sf.Copy(),
+			stuck:                     i%2 == 0,
+			piecesCompleted:           1,
+			staticPiecesNeeded:        1,
+			staticAvailableChan:       make(chan struct{}),
+			staticUploadCompletedChan: make(chan struct{}),
+			staticMemoryManager:       rt.renter.repairMemoryManager,
+		}
+		pushed, err := rt.renter.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pushed {
+			t.Fatal("unable to push chunk", chunk)
+		}
 	}
-	if rt.renter.uploadHeap.managedLen() != 1 {
-		t.Error("Expected only 1 chunk in heap")
+	if err := sf.Close(); err != nil {
+		t.Fatal(err)
 	}
 
-	// Mark chunk as stuck and push again
-	//
-	// Regression check 1: previously this second push call would succeed and
-	// the length of the heap would be 2
-	chunk.stuck = true
-	pushed, err = rt.renter.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
-	if err != nil {
-		t.Fatal(err)
+	// Pop a chunk to make sure repairing chunks are not affected. Since stuck
+	// chunks are prioritized, this will be a stuck chunk.
+	popped := rt.renter.uploadHeap.managedPop()
+	if popped == nil {
+		t.Fatal("expected chunk to be popped")
 	}
-	if pushed {
-		t.Error("should not be able to push chunk again")
+
+	// Drain the stuck chunks. Drain the repairNeeded channel first so we can
+	// check that the drain signals it.
+	select {
+	case <-rt.renter.uploadHeap.repairNeeded:
+	default:
 	}
-	if rt.renter.uploadHeap.managedLen() != 1 {
-		t.Error("Expected only 1 chunk in heap")
+	if err := rt.renter.uploadHeap.managedDrainStuckChunks(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rt.renter.uploadHeap.repairNeeded:
+	default:
+		t.Fatal("repairNeeded should have been signaled")
 	}
 
-	// Pop the chunk
-	chunk = rt.renter.uploadHeap.managedPop()
-	if chunk == nil {
-		t.Fatal("Nil chunk popped")
+	// The stuck map should be empty and no stuck chunks should remain in the
+	// heap.
+	uh := &rt.renter.uploadHeap
+	uh.mu.Lock()
+	numStuck := len(uh.stuckHeapChunks)
+	numUnstuck := len(uh.unstuckHeapChunks)
+	numRepairing := len(uh.repairingChunks)
+	heapLen := uh.heap.Len()
+	for _, c := range uh.heap {
+		if c.stuck {
+			t.Error("stuck chunk found in heap after drain")
+		}
+	}
+	uh.mu.Unlock()
+	if numStuck != 0 {
+		t.Fatalf("Expected %v stuck chunks but found %v", 0, numStuck)
+	}
+	if numRepairing != 1 {
+		t.Fatalf("Expected %v repairing chunks but found %v", 1, numRepairing)
+	}
+	if numUnstuck != int(numHeapChunks/2) {
+		t.Fatalf("Expected %v unstuck chunks but found %v", numHeapChunks/2, numUnstuck)
+	}
+	if heapLen != numUnstuck {
+		t.Fatalf("Expected heap length %v to match unstuck map length %v", heapLen, numUnstuck)
 	}
 
-	// A second pop call should not panic
-	//
-	// Regression check 2: previously this would trigger the build.Critical that
-	// the popped chunk was already in the repair map
-	chunk = rt.renter.uploadHeap.managedPop()
-	if chunk != nil {
-		t.Fatal("Expected nil chunk")
+	// Resetting the heap closes the file entries of the remaining chunks. This
+	// would trigger a critical if a drained chunk was still in the heap since
+	// its file entry was already closed.
+	if err := uh.managedReset(); err != nil {
+		t.Fatal(err)
+	}
+	if err := popped.fileEntry.Close(); err != nil {
+		t.Fatal(err)
 	}
 }
 
-// testManagedPushChunkForRepair probes the Renter's managedPushChunkForRepair
-// method with the streamChunk type
-func testManagedPushChunkForRepair(t *testing.T) {
+// testManagedRemoveByFileUID tests that the chunks of deleted files are
+// removed from the upload heap and that the repair loop skips chunks of
+// deleted files.
+func testManagedRemoveByFileUID(t *testing.T) {
 	// Create renter
-	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencySkipPrepareNextChunk{})
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -990,273 +1418,1704 @@ func testManagedPushChunkForRepair(t *testing.T) {
 			t.Fatal(err)
 		}
 	}()
-	uh := &rt.renter.uploadHeap
+	r := rt.renter
+	uh := &r.uploadHeap
 
-	// Create a stream chunk
-	file, err := rt.renter.newRenterTestFile()
+	// Create 2 multi-chunk files. Their chunks need to fit in the heap at
+	// once.
+	source, err := rt.createZeroByteFileOnDisk()
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := file.Close(); err != nil {
+	rsc, _ := modules.NewRSCode(1, 1)
+	var siaPaths []modules.SiaPath
+	var uids []siafile.SiafileUID
+	var numChunks uint64
+	for i := 0; i < 2; i++ {
+		siaPath := modules.RandomSiaPath()
+		err = r.staticFileSystem.NewSiaFile(siaPath, source, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), modules.SectorSize+1, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
 			t.Fatal(err)
 		}
-	}()
-	var buf []byte
-	sr := NewStreamShard(bytes.NewReader(buf), buf)
-	streamChunk := &unfinishedUploadChunk{
-		id: uploadChunkID{
-			fileUID: "streamchunk",
-			index:   1,
-		},
-		fileEntry:           file.Copy(),
-		sourceReader:        sr,
-		piecesRegistered:    1, // This is so the chunk is viewed as incomplete
-		staticMemoryManager: rt.renter.repairMemoryManager,
-	}
-
-	// Define helper
-	pushAndVerify := func(chunk *unfinishedUploadChunk) {
-		// Adding chunk should be successful
-		pushed, err := rt.renter.managedPushChunkForRepair(chunk, chunkTypeStreamChunk)
+		f, err := r.staticFileSystem.OpenSiaFile(siaPath)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if !pushed {
-			t.Fatal("chunk should have been pushed to the repair map")
-		}
-
-		// Verify the chunk was added to the heap as expected
-		uh.mu.Lock()
-		_, stuckExists := uh.stuckHeapChunks[chunk.id]
-		_, unstuckExists := uh.unstuckHeapChunks[chunk.id]
-		repairChunk, repairExists := uh.repairingChunks[chunk.id]
-		uh.mu.Unlock()
-		if stuckExists || unstuckExists {
-			t.Fatal("chunk should not exist in stuck or unstuck maps")
-		}
-		if !repairExists {
-			t.Fatal("chunk should have been added to repair map")
+		if f.NumChunks() < 2 {
+			t.Fatal("file should have multiple chunks", f.NumChunks())
 		}
-
-		// Verify the chunk in the heap is the chunk pushed
-		if !reflect.DeepEqual(repairChunk, chunk) {
-			t.Log("chunk:", chunk)
-			t.Log("repairChunk:", repairChunk)
-			t.Fatal("chunk in repair map not equal to the chunk added")
+		numChunks = f.NumChunks()
+		uids = append(uids, f.UID())
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
 		}
+		siaPaths = append(siaPaths, siaPath)
+	}
 
-		// The underlying heap slice should be empty
-		length := uh.managedLen()
-		if length != 0 {
-			t.Fatal("Heap has non zero length:", length)
-		}
+	// Manually add workers to worker pool and add the chunks of both files to
+	// the heap.
+	hosts := make(map[string]struct{})
+	r.staticWorkerPool.mu.Lock()
+	for i := 0; i < int(numChunks); i++ {
+		r.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	r.staticWorkerPool.mu.Unlock()
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+	r.managedBuildChunkHeap(modules.RootSiaPath(), hosts, targetUnstuckChunks, offline, goodForRenew)
+	if uh.managedLen() != int(2*numChunks) {
+		t.Fatalf("Expected heap length of %v but got %v", 2*numChunks, uh.managedLen())
 	}
 
-	// Pushing the chunk to the repair map should succeed
-	pushAndVerify(streamChunk)
+	// Pop a chunk to make sure repairing chunks are not affected.
+	popped := uh.managedPop()
+	if popped == nil {
+		t.Fatal("expected chunk to be popped")
+	}
+	deleted, remaining := siaPaths[0], siaPaths[1]
+	remainingUID := uids[1]
+	if popped.id.fileUID != uids[0] {
+		deleted, remaining = siaPaths[1], siaPaths[0]
+		remainingUID = uids[0]
+	}
 
-	// Pushing again should fail
-	pushed, err := rt.renter.managedPushChunkForRepair(streamChunk, chunkTypeStreamChunk)
+	// Delete the file of the popped chunk. Its chunks should be removed from
+	// the heap.
+	err = r.DeleteFile(deleted)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if pushed {
-		t.Error("chunk should not be able to be added twice")
+	uh.mu.Lock()
+	heapLen := uh.heap.Len()
+	numUnstuck := len(uh.unstuckHeapChunks)
+	_, repairing := uh.repairingChunks[popped.id]
+	for _, c := range uh.heap {
+		if c.id.fileUID != remainingUID {
+			t.Error("chunk of deleted file found in heap")
+		}
 	}
-
-	// Clear the stream chunk from the repair map
-	uh.managedMarkRepairDone(streamChunk)
-
-	// Add a local chunk to the heap
-	localChunk := &unfinishedUploadChunk{
-		id:                  streamChunk.id,
-		fileEntry:           file.Copy(),
-		piecesRegistered:    1, // This is so the chunk is viewed as incomplete
-		staticMemoryManager: rt.renter.repairMemoryManager,
+	uh.mu.Unlock()
+	if heapLen != int(numChunks) || numUnstuck != int(numChunks) {
+		t.Fatalf("Expected %v chunks in the heap but got %v and %v", numChunks, heapLen, numUnstuck)
 	}
-	pushed, err = rt.renter.managedPushChunkForRepair(localChunk, chunkTypeLocalChunk)
+	if !repairing {
+		t.Fatal("repairing chunk shouldn't be affected")
+	}
+	if err := popped.fileEntry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	uh.managedMarkRepairDone(popped)
+
+	// Delete the other file without removing its chunks from the heap. The
+	// repair loop should skip its chunks and drain the heap.
+	err = r.staticFileSystem.DeleteFile(remaining)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if !pushed {
-		t.Fatal("local chunk not pushed")
+	err = r.managedRepairLoop()
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Pushing the stream chunk should clear the local chunk from both maps and be
-	// successful
-	pushAndVerify(streamChunk)
-
-	// Clear the stream chunk from the repair map
-	uh.managedMarkRepairDone(streamChunk)
-
-	// Add the local chunk directly to the repair map
 	uh.mu.Lock()
-	uh.repairingChunks[localChunk.id] = localChunk
+	heapLen = uh.heap.Len()
+	numUnstuck = len(uh.unstuckHeapChunks)
+	numRepairing := len(uh.repairingChunks)
 	uh.mu.Unlock()
-
-	// Pushing the stream chunk should replace the local chunk in the repair map
-	pushAndVerify(streamChunk)
+	if heapLen != 0 || numUnstuck != 0 || numRepairing != 0 {
+		t.Fatalf("Expected the heap to be drained but got %v chunks in the heap, %v unstuck chunks and %v repairing chunks", heapLen, numUnstuck, numRepairing)
+	}
 }
 
-// testManagedTryUpdate probes the managedTryUpdate method of the uploadHeap.
-func testManagedTryUpdate(t *testing.T) {
-	// Create renter and define shorter named helper for uploadHeap
-	rt, err := newRenterTester(t.Name())
+// testMaxRepairAttempts checks that a chunk which can't be repaired due to a
+// lack of workers is deferred by its repair backoff after every failed attempt
+// and marked as stuck after the max repair attempts.
+func testMaxRepairAttempts(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err := rt.renter.Close(); err != nil {
+		if err := rt.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
-	uh := &rt.renter.uploadHeap
-
-	// Define test cases
-	var buf []byte
-	sr := NewStreamShard(bytes.NewReader(buf), buf)
-	var tests = []struct {
-		name             string
-		ct               chunkType
-		existsUnstuck    bool // Indicates if there should be an existing chunk in the unstuck map
-		existsStuck      bool // Indicates if there should be an existing chunk in the stuck map
-		existsRepairing  bool // Indicates if there should be an existing chunk in the repair map
-		existingChunkSR  io.ReadCloser
-		newChunkSR       io.ReadCloser
-		existAfterUpdate bool // Indicates if tryUpdate will cancel and remove the chunk from the heap
-		pushAfterUpdate  bool // Indicates if the push to the heap should succeed after tryUpdate
-	}{
-		// Pushing a chunkTypeLocalChunk should always be a no-op regardless of the
-		// start of the chunk in the heap.
-		{"PushLocalChunk_EmptyHeap", chunkTypeLocalChunk, false, false, false, nil, nil, false, true},                // no chunk in heap
-		{"PushLocalChunk_UnstuckChunkNoSRInHeap", chunkTypeLocalChunk, true, false, false, nil, nil, true, false},    // chunk in unstuck map
-		{"PushLocalChunk_UnstuckChunkWithSRInHeap", chunkTypeLocalChunk, true, false, false, sr, nil, true, false},   // chunk in unstuck map with sourceReader
-		{"PushLocalChunk_StuckChunkNoSRInHeap", chunkTypeLocalChunk, false, true, false, nil, nil, true, false},      // chunk in stuck map
-		{"PushLocalChunk_StuckChunkWithSRInHeap", chunkTypeLocalChunk, false, true, false, sr, nil, true, false},     // chunk in stuck map with sourceReader
-		{"PushLocalChunk_RepairingChunkNoSRInHeap", chunkTypeLocalChunk, false, false, true, nil, nil, true, false},  // chunk in repair map
-		{"PushLocalChunk_RepairingChunkWithSRInHeap", chunkTypeLocalChunk, false, false, true, sr, nil, true, false}, // chunk in repair map with sourceReader
+	r := rt.renter
 
-		// Pushing a chunkTypeStreamChunk tests
-		{"PushStreamChunk_EmptyHeap", chunkTypeStreamChunk, false, false, false, nil, sr, false, true},                // no chunk in heap
-		{"PushStreamChunk_UnstuckChunkNoSRInHeap", chunkTypeStreamChunk, true, false, false, nil, sr, false, true},    // chunk in unstuck map
-		{"PushStreamChunk_UnstuckChunkWithSRInHeap", chunkTypeStreamChunk, true, false, false, sr, sr, true, true},    // chunk in unstuck map with sourceReader
-		{"PushStreamChunk_StuckChunkNoSRInHeap", chunkTypeStreamChunk, false, true, false, nil, sr, false, true},      // chunk in stuck map
-		{"PushStreamChunk_StuckChunkWithSRInHeap", chunkTypeStreamChunk, false, true, false, sr, sr, true, true},      // chunk in stuck map with sourceReader
-		{"PushStreamChunk_RepairingChunkNoSRInHeap", chunkTypeStreamChunk, false, false, true, nil, sr, false, true},  // chunk in repair map
-		{"PushStreamChunk_RepairingChunkWithSRInHeap", chunkTypeStreamChunk, false, false, true, sr, sr, true, false}, // chunk in repair map with sourceReader
+	// Set an allowance with enough hosts for the chunk to be repairable in
+	// theory. Without any workers the repair will fail every time.
+	err = r.hostContractor.SetAllowance(modules.DefaultAllowance)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Create a test file for the chunks
-	entry, err := rt.renter.newRenterTestFile()
+	maxAttempts := 3
+	settings, err := r.Settings()
 	if err != nil {
 		t.Fatal(err)
 	}
-	defer func() {
-		if err := entry.Close(); err != nil {
-			t.Fatal(err)
-		}
-	}()
-
-	// Run test cases
-	for i, test := range tests {
-		// Initialize chunks and heap based on test parameters
-		existingChunk := &unfinishedUploadChunk{
-			id: uploadChunkID{
-				fileUID: siafile.SiafileUID(test.name),
-				index:   uint64(i),
-			},
-			fileEntry:           entry.Copy(),
-			sourceReader:        test.existingChunkSR,
-			piecesRegistered:    1, // This is so the chunk is viewed as incomplete
-			staticMemoryManager: rt.renter.repairMemoryManager,
+	if settings.MaxRepairAttempts != DefaultMaxRepairAttempts {
+		t.Fatalf("expected max repair attempts to be %v but got %v", DefaultMaxRepairAttempts, settings.MaxRepairAttempts)
+	}
+	settings.MaxRepairAttempts = uint64(maxAttempts)
+	err = r.SetSettings(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Create a file with a single chunk.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 2)
+	siaPath, err := modules.NewSiaPath("maxRepairAttemptsFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
 		}
-		if test.existsUnstuck {
-			uh.unstuckHeapChunks[existingChunk.id] = existingChunk
+	}()
+
+	// Add a piece to a good host. That way the chunk is above minimum
+	// redundancy and its repair can be deferred by the backoff.
+	hpk := types.SiaPublicKey{
+		Algorithm: types.SignatureEd25519,
+		Key:       fastrand.Bytes(crypto.PublicKeySize),
+	}
+	err = f.AddPiece(hpk, 0, 0, crypto.Hash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := map[string]struct{}{hpk.String(): {}}
+	offline := map[string]bool{hpk.String(): false}
+	goodForRenew := map[string]bool{hpk.String(): true}
+
+	// buildChunks builds the unfinished chunks of the file like the repair
+	// loop does. A worker is added temporarily to get past the worker check
+	// of managedBuildUnfinishedChunks.
+	buildChunks := func() []*unfinishedUploadChunk {
+		r.staticWorkerPool.mu.Lock()
+		r.staticWorkerPool.workers["worker"] = &worker{}
+		r.staticWorkerPool.mu.Unlock()
+		defer func() {
+			r.staticWorkerPool.mu.Lock()
+			delete(r.staticWorkerPool.workers, "worker")
+			r.staticWorkerPool.mu.Unlock()
+		}()
+		return r.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, r.repairMemoryManager, false)
+	}
+
+	// Try to repair the chunk until it reaches the max repair attempts. It
+	// should only be marked as stuck after the last attempt.
+	for i := 1; i <= maxAttempts; i++ {
+		uucs := buildChunks()
+		if len(uucs) != 1 {
+			t.Fatalf("expected 1 chunk but got %v", len(uucs))
+		}
+		uuc := uucs[0]
+		if uuc.repairAttempts != i-1 {
+			t.Fatalf("expected %v repair attempts but got %v", i-1, uuc.repairAttempts)
+		}
+		pushed, err := r.managedPushChunkForRepair(uuc, chunkTypeLocalChunk)
+		if err != nil {
+			t.Fatal(err)
 		}
-		if test.existsStuck {
-			existingChunk.stuck = true
-			uh.stuckHeapChunks[existingChunk.id] = existingChunk
+		if !pushed {
+			t.Fatal("chunk wasn't pushed")
 		}
-		if test.existsRepairing {
-			uh.repairingChunks[existingChunk.id] = existingChunk
+		if uuc.enqueuedAt.IsZero() {
+			t.Fatal("enqueue time wasn't set")
 		}
-		newChunk := &unfinishedUploadChunk{
-			id:                  existingChunk.id,
-			sourceReader:        test.newChunkSR,
-			piecesRegistered:    1, // This is so the chunk is viewed as incomplete
-			staticMemoryManager: rt.renter.repairMemoryManager,
+		err = r.managedRepairLoop()
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		// Try and Update the Chunk in the Heap
-		err := uh.managedTryUpdate(newChunk, test.ct)
+		chunks, err := r.FileChunks(siaPath)
 		if err != nil {
-			t.Fatalf("Error with TryUpdate for test %v; err: %v", test.name, err)
+			t.Fatal(err)
 		}
-
-		// Check to see if the chunk is still in the heap
-		if test.existAfterUpdate != uh.managedExists(existingChunk.id) {
-			t.Errorf("Chunk should exist after update %v for test %v", test.existAfterUpdate, test.name)
+		if chunks[0].RepairFailures != uint8(i) {
+			t.Fatalf("expected %v repair failures but got %v", i, chunks[0].RepairFailures)
+		}
+		if stuck := chunks[0].Stuck; stuck != (i == maxAttempts) {
+			t.Fatalf("unexpected stuck status %v after %v attempts", stuck, i)
+		}
+		if i == maxAttempts {
+			break
 		}
 
-		// Push the new chunk onto the heap
-		if test.pushAfterUpdate != uh.managedPush(newChunk, test.ct) {
-			t.Errorf("Chunk should have been pushed %v for test %v", test.pushAfterUpdate, test.name)
+		// The chunk shouldn't be built again until its backoff expires.
+		if uucs := buildChunks(); len(uucs) != 0 {
+			t.Fatalf("expected chunk to be deferred after %v failures but got %v chunks", i, len(uucs))
 		}
+		time.Sleep(repairBackoff(uint8(i)))
+	}
+
+	// The max repair attempts can't exceed the persisted repair failures.
+	settings.MaxRepairAttempts = math.MaxUint8 + 1
+	if err := r.SetSettings(settings); err == nil {
+		t.Fatal("expected error for too many max repair attempts")
 	}
 }
 
-// testAddChunksToHeapPanic tests that the log.Severe is triggered if
-// there is an error getting a directory from the directory heap.
-func testAddChunksToHeapPanic(t *testing.T) {
+// testUploadHeapPauseChan makes sure that sequential calls to pause and resume
+// won't cause panics for closing a closed channel
+func testUploadHeapPauseChan(t *testing.T) {
+	// Initial UploadHeap with the pauseChan initialized such that the uploads
+	// and repairs are not paused
+	uh := uploadHeap{
+		pauseChan: make(chan struct{}),
+	}
+	close(uh.pauseChan)
+	if uh.managedIsPaused() {
+		t.Error("Repairs and Uploads should not be paused")
+	}
+
+	// Call resume on an initialized heap
+	uh.managedResume()
+
+	// Call Pause twice in a row
+	uh.managedPause(DefaultPauseDuration)
+	uh.managedPause(DefaultPauseDuration)
+	// Call Resume twice in a row
+	uh.managedResume()
+	uh.managedResume()
+}
+
+// testChunkSwitchStuckStatus is a regression test that confirms the upload heap
+// won't panic due to a chunk's stuck status changing while it is in the heap
+// and being added twice
+// testAbandonedChunks tests that a chunk is abandoned after failing too many
+// stuck repairs, that the stuck loop no longer picks it up and that it is
+// picked up again after resetting it.
+func testAbandonedChunks(t *testing.T) {
 	// Create Renter
 	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer func() {
-		if err := rt.renter.Close(); err != nil {
+		if err := rt.Close(); err != nil {
 			t.Fatal(err)
 		}
 	}()
 
-	// Add maxConsecutiveDirHeapFailures non existent directories to the
-	// directoryHeap
-	for i := 0; i <= maxConsecutiveDirHeapFailures; i++ {
-		rt.renter.directoryHeap.managedPush(&directory{
-			staticSiaPath: modules.RandomSiaPath(),
-		})
+	// Create file on disk
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Create file with more than 1 chunk and mark the first chunk at stuck
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("abandonedFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rt.renter.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
 	}
-
-	// Recover panic
 	defer func() {
-		if r := recover(); r == nil {
-			t.Fatal("expected panic")
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
 		}
 	}()
+	if f.NumChunks() <= 1 {
+		t.Fatalf("File created with not enough chunks for test, have %v need at least 2", f.NumChunks())
+	}
+	if err = f.SetStuck(uint64(0), true); err != nil {
+		t.Fatal(err)
+	}
 
-	// Call managedAddChunksToHeap
-	rt.renter.managedAddChunksToHeap(nil)
+	// Create maps to pass into methods
+	hosts := make(map[string]struct{})
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+
+	// Lower the number of stuck repair attempts.
+	settings, err := rt.renter.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.MaxStuckRepairAttempts != DefaultMaxStuckRepairAttempts {
+		t.Fatalf("expected max stuck repair attempts to be %v but got %v", DefaultMaxStuckRepairAttempts, settings.MaxStuckRepairAttempts)
+	}
+	settings.MaxStuckRepairAttempts = math.MaxUint8 + 1
+	if err := rt.renter.SetSettings(settings); err == nil {
+		t.Fatal("expected error for too many max stuck repair attempts")
+	}
+	maxStuckAttempts := 3
+	settings.MaxStuckRepairAttempts = uint64(maxStuckAttempts)
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+
+	// Manually add workers to worker pool
+	rt.renter.staticWorkerPool.mu.Lock()
+	for i := 0; i < int(f.NumChunks()); i++ {
+		rt.renter.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	rt.renter.staticWorkerPool.mu.Unlock()
+
+	// Fail the stuck repair of the chunk until it is abandoned. The chunk
+	// should be picked up by the stuck loop until then.
+	for i := 0; i < maxStuckAttempts; i++ {
+		uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+		if len(uucs) != 1 {
+			t.Fatalf("Incorrect number of chunks returned, expected 1 got %v", len(uucs))
+		}
+		uc := uucs[0]
+		uc.stuckRepair = true
+		rt.renter.managedUpdateUploadChunkStuckStatus(uc)
+		if err := uc.fileEntry.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f.NumStuckChunks() != 0 || f.NumAbandonedChunks() != 1 {
+		t.Fatal("chunk should be abandoned", f.NumStuckChunks(), f.NumAbandonedChunks())
+	}
+
+	// Neither the stuck loop nor the repair loop should pick up the chunk.
+	uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+	if len(uucs) != 0 {
+		t.Fatalf("Incorrect number of chunks returned, expected 0 got %v", len(uucs))
+	}
+	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+	for _, c := range uucs {
+		if c.id.index == 0 {
+			t.Fatal("abandoned chunk shouldn't be repaired")
+		}
+		if err := c.fileEntry.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The abandoned chunk should show up in the file info.
+	fi, err := rt.renter.File(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.NumAbandonedChunks != 1 {
+		t.Fatal("wrong number of abandoned chunks", fi.NumAbandonedChunks)
+	}
+
+	// Reset the abandoned chunks. The stuck loop should pick up the chunk
+	// again.
+	if err := rt.renter.ResetFileAbandoned(siaPath); err != nil {
+		t.Fatal(err)
+	}
+	if f.NumStuckChunks() != 1 || f.NumAbandonedChunks() != 0 {
+		t.Fatal("chunk should be stuck again", f.NumStuckChunks(), f.NumAbandonedChunks())
+	}
+	uucs = rt.renter.managedBuildUnfinishedChunks(f, hosts, targetStuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+	if len(uucs) != 1 {
+		t.Fatalf("Incorrect number of chunks returned, expected 1 got %v", len(uucs))
+	}
+	if err := uucs[0].fileEntry.Close(); err != nil {
+		t.Fatal(err)
+	}
 }
 
-// managedBlockUntilBubblesComplete is a helper that blocks until all pending
-// bubbles are complete
-func (rt *renterTester) managedBlockUntilBubblesComplete() {
-	bs := rt.renter.staticBubbleScheduler
-	for {
-		select {
-		case <-rt.renter.tg.StopChan():
-			return
-		default:
+// testRepairFile verifies that managedRepairFile adds all the chunks of a file
+// to the upload heap with priority.
+func testRepairFile(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
 		}
-		// Sleep to start the loop since most of the time bubble is called in a
-		// go routine so we want to give it time to start.
-		time.Sleep(100 * time.Millisecond)
-		bs.mu.Lock()
-		if len(bs.bubbleUpdates) == 0 {
-			bs.mu.Unlock()
-			return
+	}()
+
+	// Create file with more than 1 chunk and mark the first chunk as stuck.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("repairFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rt.renter.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
 		}
-		bs.mu.Unlock()
+	}()
+	if f.NumChunks() <= 1 {
+		t.Fatalf("File created with not enough chunks for test, have %v need at least 2", f.NumChunks())
+	}
+	if err = f.SetStuck(uint64(0), true); err != nil {
+		t.Fatal(err)
+	}
+
+	// Create maps to pass into methods
+	hosts := make(map[string]struct{})
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+
+	// Manually add workers to worker pool
+	rt.renter.staticWorkerPool.mu.Lock()
+	for i := 0; i < int(f.NumChunks()); i++ {
+		rt.renter.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	rt.renter.staticWorkerPool.mu.Unlock()
+
+	// Add an unstuck chunk to the heap without priority, the way the repair
+	// loop would.
+	uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+	if len(uucs) == 0 {
+		t.Fatal("expected unstuck chunks")
+	}
+	pushed, err := rt.renter.managedPushOrClose(uucs[0])
+	if err != nil || !pushed {
+		t.Fatal("unable to push chunk", pushed, err)
+	}
+	for _, c := range uucs[1:] {
+		if err := c.fileEntry.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Repair the file. All chunks, stuck or not, should be in the heap with
+	// priority.
+	err = rt.renter.managedRepairFile(f, hosts, offline, goodForRenew)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rt.renter.uploadHeap.managedLen() != int(f.NumChunks()) {
+		t.Fatalf("Expected heap length of %v but got %v", f.NumChunks(), rt.renter.uploadHeap.managedLen())
+	}
+	uh := &rt.renter.uploadHeap
+	uh.mu.Lock()
+	defer uh.mu.Unlock()
+	for i := uint64(0); i < f.NumChunks(); i++ {
+		id := uploadChunkID{fileUID: f.UID(), index: i}
+		c, exists := uh.unstuckHeapChunks[id]
+		if !exists {
+			c, exists = uh.stuckHeapChunks[id]
+		}
+		if !exists {
+			t.Fatalf("chunk %v not in heap", i)
+		}
+		if !c.staticPriority {
+			t.Fatalf("chunk %v should have priority", i)
+		}
+	}
+}
+
+func testChunkSwitchStuckStatus(t *testing.T) {
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create minimum chunk
+	chunk := &unfinishedUploadChunk{
+		id: uploadChunkID{
+			fileUID: siafile.SiafileUID("chunk"),
+			index:   0,
+		},
+		staticMemoryManager: rt.renter.repairMemoryManager,
+	}
+	// push chunk to heap
+	pushed, err := rt.renter.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pushed {
+		t.Fatal("unable to push chunk", chunk)
+	}
+	if rt.renter.uploadHeap.managedLen() != 1 {
+		t.Error("Expected only 1 chunk in heap")
+	}
+
+	// Mark chunk as stuck and push again
+	//
+	// Regression check 1: previously this second push call would succeed and
+	// the length of the heap would be 2
+	chunk.stuck = true
+	pushed, err = rt.renter.managedPushChunkForRepair(chunk, chunkTypeLocalChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed {
+		t.Error("should not be able to push chunk again")
+	}
+	if rt.renter.uploadHeap.managedLen() != 1 {
+		t.Error("Expected only 1 chunk in heap")
+	}
+
+	// Pop the chunk
+	chunk = rt.renter.uploadHeap.managedPop()
+	if chunk == nil {
+		t.Fatal("Nil chunk popped")
+	}
+
+	// A second pop call should not panic
+	//
+	// Regression check 2: previously this would trigger the build.Critical that
+	// the popped chunk was already in the repair map
+	chunk = rt.renter.uploadHeap.managedPop()
+	if chunk != nil {
+		t.Fatal("Expected nil chunk")
+	}
+}
+
+// testManagedPushChunkForRepair probes the Renter's managedPushChunkForRepair
+// method with the streamChunk type
+func testManagedPushChunkForRepair(t *testing.T) {
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencySkipPrepareNextChunk{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	uh := &rt.renter.uploadHeap
+
+	// Create a stream chunk
+	file, err := rt.renter.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	var buf []byte
+	sr := NewStreamShard(bytes.NewReader(buf), buf)
+	streamChunk := &unfinishedUploadChunk{
+		id: uploadChunkID{
+			fileUID: "streamchunk",
+			index:   1,
+		},
+		fileEntry:           file.Copy(),
+		sourceReader:        sr,
+		piecesRegistered:    1, // This is so the chunk is viewed as incomplete
+		staticMemoryManager: rt.renter.repairMemoryManager,
+	}
+
+	// Define helper
+	pushAndVerify := func(chunk *unfinishedUploadChunk) {
+		// Adding chunk should be successful
+		pushed, err := rt.renter.managedPushChunkForRepair(chunk, chunkTypeStreamChunk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pushed {
+			t.Fatal("chunk should have been pushed to the repair map")
+		}
+
+		// Verify the chunk was added to the heap as expected
+		uh.mu.Lock()
+		_, stuckExists := uh.stuckHeapChunks[chunk.id]
+		_, unstuckExists := uh.unstuckHeapChunks[chunk.id]
+		repairChunk, repairExists := uh.repairingChunks[chunk.id]
+		uh.mu.Unlock()
+		if stuckExists || unstuckExists {
+			t.Fatal("chunk should not exist in stuck or unstuck maps")
+		}
+		if !repairExists {
+			t.Fatal("chunk should have been added to repair map")
+		}
+
+		// Verify the chunk in the heap is the chunk pushed
+		if !reflect.DeepEqual(repairChunk, chunk) {
+			t.Log("chunk:", chunk)
+			t.Log("repairChunk:", repairChunk)
+			t.Fatal("chunk in repair map not equal to the chunk added")
+		}
+
+		// The underlying heap slice should be empty
+		length := uh.managedLen()
+		if length != 0 {
+			t.Fatal("Heap has non zero length:", length)
+		}
+	}
+
+	// Pushing the chunk to the repair map should succeed
+	pushAndVerify(streamChunk)
+
+	// Pushing again should fail
+	pushed, err := rt.renter.managedPushChunkForRepair(streamChunk, chunkTypeStreamChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pushed {
+		t.Error("chunk should not be able to be added twice")
+	}
+
+	// Clear the stream chunk from the repair map
+	uh.managedMarkRepairDone(streamChunk)
+
+	// Add a local chunk to the heap
+	localChunk := &unfinishedUploadChunk{
+		id:                  streamChunk.id,
+		fileEntry:           file.Copy(),
+		piecesRegistered:    1, // This is so the chunk is viewed as incomplete
+		staticMemoryManager: rt.renter.repairMemoryManager,
+	}
+	pushed, err = rt.renter.managedPushChunkForRepair(localChunk, chunkTypeLocalChunk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pushed {
+		t.Fatal("local chunk not pushed")
+	}
+
+	// Pushing the stream chunk should clear the local chunk from both maps and be
+	// successful
+	pushAndVerify(streamChunk)
+
+	// Clear the stream chunk from the repair map
+	uh.managedMarkRepairDone(streamChunk)
+
+	// Add the local chunk directly to the repair map
+	uh.mu.Lock()
+	uh.repairingChunks[localChunk.id] = localChunk
+	uh.mu.Unlock()
+
+	// Pushing the stream chunk should replace the local chunk in the repair map
+	pushAndVerify(streamChunk)
+}
+
+// testManagedPushOrClose verifies that managedPushOrClose pushes chunks onto
+// the heap and counts the chunks that were rejected.
+func testManagedPushOrClose(t *testing.T) {
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	uh := &rt.renter.uploadHeap
+
+	file, err := rt.renter.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	newChunk := func() *unfinishedUploadChunk {
+		return &unfinishedUploadChunk{
+			id: uploadChunkID{
+				fileUID: "pushorclose",
+				index:   1,
+			},
+			fileEntry:           file.Copy(),
+			staticMemoryManager: rt.renter.repairMemoryManager,
+		}
+	}
+
+	// Pushing a new chunk should succeed without any failed pushes.
+	pushed, err := rt.renter.managedPushOrClose(newChunk())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !pushed || !uh.managedExists(newChunk().id) {
+		t.Fatal("chunk should have been pushed")
+	}
+	if failedPushes := uh.managedFailedPushes(); len(failedPushes) != 0 {
+		t.Fatal("there shouldn't be any failed pushes", failedPushes)
+	}
+
+	// Pushing the same chunk again should be rejected and counted.
+	for i := 1; i <= 3; i++ {
+		pushed, err = rt.renter.managedPushOrClose(newChunk())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pushed {
+			t.Fatal("chunk shouldn't have been pushed")
+		}
+		failedPushes := uh.managedFailedPushes()
+		if failedPushes[pushFailureRejected] != uint64(i) || failedPushes[pushFailureError] != 0 {
+			t.Fatal("unexpected failed pushes", failedPushes)
+		}
+	}
+	if uh.managedLen() != 1 {
+		t.Fatal("expected a single chunk in the heap", uh.managedLen())
+	}
+
+	// The returned counters are a copy.
+	uh.managedFailedPushes()[pushFailureRejected] = 0
+	if uh.managedFailedPushes()[pushFailureRejected] != 3 {
+		t.Fatal("counters shouldn't have been modified")
+	}
+
+	// The counters are reported by the repair metrics.
+	metrics, err := rt.renter.RepairMetrics()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metrics.FailedPushes[string(pushFailureRejected)] != 3 || metrics.FailedPushes[string(pushFailureError)] != 0 {
+		t.Fatal("unexpected failed pushes in repair metrics", metrics.FailedPushes)
+	}
+
+	// Closing an entry shouldn't return an error.
+	err = rt.renter.managedCloseEntry(newChunk())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uh.managedReset(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testManagedTryUpdate probes the managedTryUpdate method of the uploadHeap.
+func testManagedTryUpdate(t *testing.T) {
+	// Create renter and define shorter named helper for uploadHeap
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.renter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	uh := &rt.renter.uploadHeap
+
+	// Define test cases
+	var buf []byte
+	sr := NewStreamShard(bytes.NewReader(buf), buf)
+	var tests = []struct {
+		name             string
+		ct               chunkType
+		existsUnstuck    bool // Indicates if there should be an existing chunk in the unstuck map
+		existsStuck      bool // Indicates if there should be an existing chunk in the stuck map
+		existsRepairing  bool // Indicates if there should be an existing chunk in the repair map
+		existingChunkSR  io.ReadCloser
+		newChunkSR       io.ReadCloser
+		existAfterUpdate bool // Indicates if tryUpdate will cancel and remove the chunk from the heap
+		pushAfterUpdate  bool // Indicates if the push to the heap should succeed after tryUpdate
+	}{
+		// Pushing a chunkTypeLocalChunk should always be a no-op regardless of the
+		// start of the chunk in the heap.
+		{"PushLocalChunk_EmptyHeap", chunkTypeLocalChunk, false, false, false, nil, nil, false, true},                // no chunk in heap
+		{"PushLocalChunk_UnstuckChunkNoSRInHeap", chunkTypeLocalChunk, true, false, false, nil, nil, true, false},    // chunk in unstuck map
+		{"PushLocalChunk_UnstuckChunkWithSRInHeap", chunkTypeLocalChunk, true, false, false, sr, nil, true, false},   // chunk in unstuck map with sourceReader
+		{"PushLocalChunk_StuckChunkNoSRInHeap", chunkTypeLocalChunk, false, true, false, nil, nil, true, false},      // chunk in stuck map
+		{"PushLocalChunk_StuckChunkWithSRInHeap", chunkTypeLocalChunk, false, true, false, sr, nil, true, false},     // chunk in stuck map with sourceReader
+		{"PushLocalChunk_RepairingChunkNoSRInHeap", chunkTypeLocalChunk, false, false, true, nil, nil, true, false},  // chunk in repair map
+		{"PushLocalChunk_RepairingChunkWithSRInHeap", chunkTypeLocalChunk, false, false, true, sr, nil, true, false}, // chunk in repair map with sourceReader
+
+		// Pushing a chunkTypeStreamChunk tests
+		{"PushStreamChunk_EmptyHeap", chunkTypeStreamChunk, false, false, false, nil, sr, false, true},                // no chunk in heap
+		{"PushStreamChunk_UnstuckChunkNoSRInHeap", chunkTypeStreamChunk, true, false, false, nil, sr, false, true},    // chunk in unstuck map
+		{"PushStreamChunk_UnstuckChunkWithSRInHeap", chunkTypeStreamChunk, true, false, false, sr, sr, true, true},    // chunk in unstuck map with sourceReader
+		{"PushStreamChunk_StuckChunkNoSRInHeap", chunkTypeStreamChunk, false, true, false, nil, sr, false, true},      // chunk in stuck map
+		{"PushStreamChunk_StuckChunkWithSRInHeap", chunkTypeStreamChunk, false, true, false, sr, sr, true, true},      // chunk in stuck map with sourceReader
+		{"PushStreamChunk_RepairingChunkNoSRInHeap", chunkTypeStreamChunk, false, false, true, nil, sr, false, true},  // chunk in repair map
+		{"PushStreamChunk_RepairingChunkWithSRInHeap", chunkTypeStreamChunk, false, false, true, sr, sr, true, false}, // chunk in repair map with sourceReader
+	}
+
+	// Create a test file for the chunks
+	entry, err := rt.renter.newRenterTestFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := entry.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Run test cases
+	for i, test := range tests {
+		// Initialize chunks and heap based on test parameters
+		existingChunk := &unfinishedUploadChunk{
+			id: uploadChunkID{
+				fileUID: siafile.SiafileUID(test.name),
+				index:   uint64(i),
+			},
+			fileEntry:           entry.Copy(),
+			sourceReader:        test.existingChunkSR,
+			piecesRegistered:    1, // This is so the chunk is viewed as incomplete
+			staticMemoryManager: rt.renter.repairMemoryManager,
+		}
+		if test.existsUnstuck {
+			uh.unstuckHeapChunks[existingChunk.id] = existingChunk
+		}
+		if test.existsStuck {
+			existingChunk.stuck = true
+			uh.stuckHeapChunks[existingChunk.id] = existingChunk
+		}
+		if test.existsRepairing {
+			uh.repairingChunks[existingChunk.id] = existingChunk
+		}
+		newChunk := &unfinishedUploadChunk{
+			id:                  existingChunk.id,
+			sourceReader:        test.newChunkSR,
+			piecesRegistered:    1, // This is so the chunk is viewed as incomplete
+			staticMemoryManager: rt.renter.repairMemoryManager,
+		}
+
+		// Try and Update the Chunk in the Heap
+		err := uh.managedTryUpdate(newChunk, test.ct)
+		if err != nil {
+			t.Fatalf("Error with TryUpdate for test %v; err: %v", test.name, err)
+		}
+
+		// Check to see if the chunk is still in the heap
+		if test.existAfterUpdate != uh.managedExists(existingChunk.id) {
+			t.Errorf("Chunk should exist after update %v for test %v", test.existAfterUpdate, test.name)
+		}
+
+		// Push the new chunk onto the heap
+		if test.pushAfterUpdate != uh.managedPush(newChunk, test.ct) {
+			t.Errorf("Chunk should have been pushed %v for test %v", test.pushAfterUpdate, test.name)
+		}
+	}
+}
+
+// testAddChunksToHeapPanic tests that the log.Severe is triggered if
+// there is an error getting a directory from the directory heap.
+func testAddChunksToHeapPanic(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.renter.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add maxConsecutiveDirHeapFailures non existent directories to the
+	// directoryHeap
+	for i := 0; i <= maxConsecutiveDirHeapFailures; i++ {
+		rt.renter.directoryHeap.managedPush(&directory{
+			staticSiaPath: modules.RandomSiaPath(),
+		})
+	}
+
+	// Recover panic
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	// Call managedAddChunksToHeap
+	rt.renter.managedAddChunksToHeap(nil)
+}
+
+// testBackupNeeded checks that signaling the backupNeeded channel causes the
+// repair loop to add the backup chunks to the upload heap and to repair them
+// right away, even though the rest of the filesystem is healthy.
+func testBackupNeeded(t *testing.T) {
+	// Create a renter with a single worker. The repair loop is started
+	// manually.
+	deps := &dependencies.DependencyDisableRepairAndHealthLoops{}
+	wt, err := newWorkerTesterCustomDependency(t.Name(), deps, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// Start the repair loop. Since the directory heap and upload heap are
+	// empty, it will block until it is signaled.
+	go r.threadedUploadAndRepair()
+	time.Sleep(time.Second)
+
+	// Create a backup file which needs to be repaired.
+	path := filepath.Join(wt.rt.dir, "backup")
+	if err := ioutil.WriteFile(path, fastrand.Bytes(10), persist.DefaultDiskPermissionsTest); err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 2)
+	siaPath, err := modules.BackupFolder.Join("backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repairAttempted := func() bool {
+		chunks, err := r.FileChunks(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return chunks[0].RepairFailures > 0 || chunks[0].Stuck || !chunks[0].LastRepairFailure.IsZero()
+	}
+	uploaded := func() bool {
+		f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			if err := f.Close(); err != nil {
+				t.Fatal(err)
+			}
+		}()
+		return f.Metadata().CachedUploadedBytes > 0
+	}
+
+	// The loop shouldn't notice the backup without being signaled.
+	time.Sleep(time.Second)
+	if repairAttempted() || uploaded() {
+		t.Fatal("backup chunk was repaired without a signal")
+	}
+
+	// Signal the loop. The backup chunk should be repaired right away.
+	// Whether the upload to the single host succeeds doesn't matter.
+	r.uploadHeap.backupNeeded <- struct{}{}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if !repairAttempted() && !uploaded() {
+			return errors.New("backup chunk wasn't repaired")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testBackupChunksOrder checks that the backup chunks of nested snapshots are
+// added to the heap, that the most recent snapshot is uploaded before the
+// older ones are resumed and that older snapshots are not blocked by a newer
+// one which can't be repaired.
+func testBackupChunksOrder(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create two snapshots that need to be repaired. The older one is nested
+	// in a sub directory of the backup folder.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 1)
+	var uids []siafile.SiafileUID
+	for i, name := range []string{"nested/older", "newer"} {
+		siaPath, err := modules.BackupFolder.Join(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		uids = append(uids, f.UID())
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+		meta := modules.UploadedBackup{
+			Name:         name,
+			CreationDate: types.Timestamp(i + 1),
+		}
+		fastrand.Read(meta.UID[:])
+		if err := r.managedSaveSnapshot(meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	olderUID, newerUID := uids[0], uids[1]
+
+	// Manually add workers to worker pool
+	r.staticWorkerPool.mu.Lock()
+	for i := 0; i < rsc.NumPieces(); i++ {
+		r.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	r.staticWorkerPool.mu.Unlock()
+
+	// heapUIDs returns the UIDs of the files with chunks in the heap.
+	heapUIDs := func() map[siafile.SiafileUID]struct{} {
+		r.uploadHeap.mu.Lock()
+		defer r.uploadHeap.mu.Unlock()
+		uids := make(map[siafile.SiafileUID]struct{})
+		for id := range r.uploadHeap.unstuckHeapChunks {
+			uids[id.fileUID] = struct{}{}
+		}
+		return uids
+	}
+
+	// Only the chunks of the newer snapshot should be added.
+	hosts := make(map[string]struct{})
+	if n := r.managedAddBackupChunksToHeap(hosts); n == 0 {
+		t.Fatal("no backup chunks were added")
+	}
+	uidsInHeap := heapUIDs()
+	if _, exists := uidsInHeap[newerUID]; !exists || len(uidsInHeap) != 1 {
+		t.Fatal("expected only the chunks of the newer snapshot in the heap", uidsInHeap)
+	}
+
+	// Adding the backup chunks again shouldn't add the older snapshot while
+	// the newer one is still being uploaded.
+	r.managedAddBackupChunksToHeap(hosts)
+	if uidsInHeap := heapUIDs(); len(uidsInHeap) != 1 {
+		t.Fatal("expected only the chunks of the newer snapshot in the heap", uidsInHeap)
+	}
+
+	// Finish the newer snapshot. Once it is uploaded, the snapshot siafile is
+	// deleted by the snapshot synchronization.
+	if err := r.uploadHeap.managedReset(); err != nil {
+		t.Fatal(err)
+	}
+	newerPath, err := modules.BackupFolder.Join("newer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.staticFileSystem.DeleteFile(newerPath); err != nil {
+		t.Fatal(err)
+	}
+
+	// The older snapshot should be resumed.
+	if n := r.managedAddBackupChunksToHeap(hosts); n == 0 {
+		t.Fatal("no backup chunks were added")
+	}
+	uidsInHeap = heapUIDs()
+	if _, exists := uidsInHeap[olderUID]; !exists || len(uidsInHeap) != 1 {
+		t.Fatal("expected only the chunks of the older snapshot in the heap", uidsInHeap)
+	}
+
+	// Create a new snapshot whose source is missing. None of its chunks can be
+	// pushed, so the older snapshot should still be repaired.
+	if err := r.uploadHeap.managedReset(); err != nil {
+		t.Fatal(err)
+	}
+	newestPath, err := modules.BackupFolder.Join("newest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(r.staticFileSystem.Root(), persist.RandomSuffix())
+	err = r.staticFileSystem.NewSiaFile(newestPath, missing, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := modules.UploadedBackup{
+		Name:         "newest",
+		CreationDate: types.Timestamp(3),
+	}
+	fastrand.Read(meta.UID[:])
+	if err := r.managedSaveSnapshot(meta); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.managedAddBackupChunksToHeap(hosts); n == 0 {
+		t.Fatal("no backup chunks were added")
+	}
+	uidsInHeap = heapUIDs()
+	if _, exists := uidsInHeap[olderUID]; !exists || len(uidsInHeap) != 1 {
+		t.Fatal("expected only the chunks of the older snapshot in the heap", uidsInHeap)
+	}
+}
+
+// testExpensiveWorkers checks that the hosts of workers which are too expensive
+// for uploads are not considered as candidates for uploading the pieces of an
+// unfinished chunk.
+func testExpensiveWorkers(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a file with a single chunk.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 2)
+	siaPath, err := modules.NewSiaPath("expensiveWorkersFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a cheap and an expensive worker as well as a worker without a
+	// cache to the worker pool.
+	allowance := modules.DefaultAllowance
+	allowance.MaxUploadBandwidthPrice = types.SiacoinPrecision
+	cheap := &worker{staticHostPubKeyStr: "cheap"}
+	cheap.atomicCache = unsafe.Pointer(&workerCache{
+		staticRenterAllowance: allowance,
+		staticUploadPrice:     allowance.MaxUploadBandwidthPrice,
+	})
+	expensive := &worker{staticHostPubKeyStr: "expensive"}
+	expensive.atomicCache = unsafe.Pointer(&workerCache{
+		staticRenterAllowance: allowance,
+		staticUploadPrice:     allowance.MaxUploadBandwidthPrice.Add64(1),
+	})
+	uncached := &worker{staticHostPubKeyStr: "uncached"}
+	r.staticWorkerPool.mu.Lock()
+	for _, w := range []*worker{cheap, expensive, uncached} {
+		r.staticWorkerPool.workers[w.staticHostPubKeyStr] = w
+	}
+	r.staticWorkerPool.mu.Unlock()
+
+	// Build the chunk. Only the expensive worker's host should be excluded.
+	hosts := map[string]struct{}{
+		"cheap":     {},
+		"expensive": {},
+		"uncached":  {},
+	}
+	pks := make(map[string]types.SiaPublicKey)
+	offline, goodForRenew, _ := r.managedContractUtilityMaps()
+	uuc, err := r.managedBuildUnfinishedChunk(f, 0, hosts, pks, memoryPriorityLow, offline, goodForRenew, r.staticWorkerPool.callExpensiveUploadHosts(), r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uuc.unusedHosts) != 2 {
+		t.Fatal("expected 2 unused hosts but got", len(uuc.unusedHosts))
+	}
+	if _, exists := uuc.unusedHosts["expensive"]; exists {
+		t.Fatal("expensive host is a candidate")
+	}
+
+	// Without a max price in the allowance no worker is too expensive.
+	allowance.MaxUploadBandwidthPrice = types.ZeroCurrency
+	expensive.atomicCache = unsafe.Pointer(&workerCache{
+		staticRenterAllowance: allowance,
+		staticUploadPrice:     types.SiacoinPrecision.Mul64(1e6),
+	})
+	uuc, err = r.managedBuildUnfinishedChunk(f, 0, hosts, pks, memoryPriorityLow, offline, goodForRenew, r.staticWorkerPool.callExpensiveUploadHosts(), r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uuc.unusedHosts) != 3 {
+		t.Fatal("expected 3 unused hosts but got", len(uuc.unusedHosts))
+	}
+}
+
+// managedBlockUntilBubblesComplete is a helper that blocks until all pending
+// bubbles are complete
+func (rt *renterTester) managedBlockUntilBubblesComplete() {
+	bs := rt.renter.staticBubbleScheduler
+	for {
+		select {
+		case <-rt.renter.tg.StopChan():
+			return
+		default:
+		}
+		// Sleep to start the loop since most of the time bubble is called in a
+		// go routine so we want to give it time to start.
+		time.Sleep(100 * time.Millisecond)
+		bs.mu.Lock()
+		if len(bs.bubbleUpdates) == 0 {
+			bs.mu.Unlock()
+			return
+		}
+		bs.mu.Unlock()
+	}
+}
+
+// testRecoveryChunks tests building chunks for the targetRecoveryChunks repair
+// target.
+func testRecoveryChunks(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a 1-of-3 file with a single chunk.
+	rsc, _ := modules.NewRSCode(1, 2)
+	siaPath, err := modules.NewSiaPath("recoveryFile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Upload the first piece to the bad host.
+	bad := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	good := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	other := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+	if err := f.AddPiece(bad, 0, 0, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	hosts := map[string]struct{}{
+		bad.String():   {},
+		good.String():  {},
+		other.String(): {},
+	}
+	offline := map[string]bool{
+		bad.String():   false,
+		good.String():  false,
+		other.String(): false,
+	}
+	goodForRenew := map[string]bool{
+		bad.String():   true,
+		good.String():  true,
+		other.String(): true,
+	}
+
+	// The chunk can't be recovered since the bad host is the only host with
+	// a piece.
+	_, err = r.managedBuildRecoveryChunk(f, 0, bad.String(), hosts, offline, goodForRenew, r.repairMemoryManager)
+	if err == nil || !strings.Contains(err.Error(), "needed for recovery") {
+		t.Fatal("expected error", err)
+	}
+
+	// Upload the second piece to the good host. Now the chunk can be
+	// recovered and only the good host's piece counts.
+	if err := f.AddPiece(good, 0, 1, crypto.Hash{}); err != nil {
+		t.Fatal(err)
+	}
+	uuc, err := r.managedBuildRecoveryChunk(f, 0, bad.String(), hosts, offline, goodForRenew, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uuc.recoveryMode {
+		t.Fatal("chunk is not in recovery mode")
+	}
+	if _, exists := uuc.staticBadHosts[bad.String()]; !exists || len(uuc.staticBadHosts) != 1 {
+		t.Fatal("wrong bad hosts", uuc.staticBadHosts)
+	}
+	if uuc.piecesCompleted != 1 || uuc.pieceUsage[0] || !uuc.pieceUsage[1] {
+		t.Fatal("wrong piece usage", uuc.piecesCompleted, uuc.pieceUsage)
+	}
+	if _, exists := uuc.unusedHosts[other.String()]; !exists || len(uuc.unusedHosts) != 1 {
+		t.Fatal("wrong unused hosts", uuc.unusedHosts)
+	}
+	if err := uuc.fileEntry.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without recovery both pieces count.
+	pks := make(map[string]types.SiaPublicKey)
+	uuc, err = r.managedBuildUnfinishedChunk(f, 0, hosts, pks, memoryPriorityLow, offline, goodForRenew, nil, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uuc.recoveryMode || uuc.piecesCompleted != 2 {
+		t.Fatal("wrong chunk", uuc.recoveryMode, uuc.piecesCompleted)
+	}
+	if err := uuc.fileEntry.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A recovery chunk replaces a chunk which is waiting in the heap without
+	// recovery mode.
+	uuc, err = r.managedBuildUnfinishedChunk(f, 0, hosts, pks, memoryPriorityLow, offline, goodForRenew, nil, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.uploadHeap.managedPush(uuc, chunkTypeLocalChunk) {
+		t.Fatal("chunk wasn't pushed")
+	}
+	recovery, err := r.managedBuildRecoveryChunk(f, 0, bad.String(), hosts, offline, goodForRenew, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.uploadHeap.managedRemoveForRecovery(recovery.id); err != nil {
+		t.Fatal(err)
+	}
+	if r.uploadHeap.managedExists(recovery.id) {
+		t.Fatal("chunk without recovery mode wasn't removed")
+	}
+	if !r.uploadHeap.managedPush(recovery, chunkTypeLocalChunk) {
+		t.Fatal("recovery chunk wasn't pushed")
+	}
+
+	// Another recovery chunk doesn't replace the existing one.
+	if err := r.uploadHeap.managedRemoveForRecovery(recovery.id); err != nil {
+		t.Fatal(err)
+	}
+	if !r.uploadHeap.managedExists(recovery.id) {
+		t.Fatal("recovery chunk was removed")
+	}
+	if err := r.uploadHeap.managedRemoveUnprioritized(recovery.id); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testSkipUnavailableLocal checks that chunks of files with an unavailable
+// source file are skipped without being marked as stuck if
+// skipUnavailableLocal is set.
+func testSkipUnavailableLocal(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The setting should be persisted by the renter.
+	settings, err := rt.renter.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings.SkipUnavailableLocalFiles || rt.renter.managedSkipUnavailableLocalFiles() {
+		t.Fatal("skipping unavailable local files should be disabled by default")
+	}
+	settings.SkipUnavailableLocalFiles = true
+	if err := rt.renter.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if !rt.renter.managedSkipUnavailableLocalFiles() {
+		t.Fatal("setting wasn't updated")
+	}
+
+	// Create a file with a source on disk and remove the source.
+	path, err := rt.createZeroByteFileOnDisk()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("missingSource")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rt.renter.staticFileSystem.NewSiaFile(siaPath, path, rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := rt.renter.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// Manually add workers to worker pool
+	rt.renter.staticWorkerPool.mu.Lock()
+	for i := 0; i < int(f.NumChunks()); i++ {
+		rt.renter.staticWorkerPool.workers[fmt.Sprint(i)] = &worker{}
+	}
+	rt.renter.staticWorkerPool.mu.Unlock()
+
+	hosts := make(map[string]struct{})
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+
+	// Skipping the chunks shouldn't return any chunks or mark them as stuck.
+	for _, target := range []repairTarget{targetUnstuckChunks, targetStuckChunks} {
+		uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, target, offline, goodForRenew, rt.renter.repairMemoryManager, true)
+		if len(uucs) != 0 {
+			t.Fatalf("Incorrect number of chunks returned, expected 0 got %v", len(uucs))
+		}
+		if f.NumStuckChunks() != 0 {
+			t.Fatalf("Expected no stuck chunks but got %v", f.NumStuckChunks())
+		}
+	}
+
+	// Without skipping the chunks, they are marked as stuck.
+	uucs := rt.renter.managedBuildUnfinishedChunks(f, hosts, targetUnstuckChunks, offline, goodForRenew, rt.renter.repairMemoryManager, false)
+	if len(uucs) != 0 {
+		t.Fatalf("Incorrect number of chunks returned, expected 0 got %v", len(uucs))
+	}
+	if f.NumStuckChunks() != f.NumChunks() {
+		t.Fatalf("Expected %v stuck chunks but got %v", f.NumChunks(), f.NumStuckChunks())
+	}
+}
+
+// testChunkDoubleClose checks that closing the file entry of a chunk twice is
+// detected, counted and reported by an alert until the next successful close.
+func testChunkDoubleClose(t *testing.T) {
+	// Create Renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Create a file and a chunk for it.
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("doubleClose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), 10e3, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	chunk, err := r.managedBuildUnfinishedChunk(f, 0, nil, nil, false, nil, nil, nil, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first close should succeed.
+	if err := r.managedCloseEntry(chunk); err != nil {
+		t.Fatal(err)
+	}
+	if failed := r.uploadHeap.managedFailedCloses(); failed != 0 {
+		t.Fatal("expected no failed closes but got", failed)
+	}
+
+	// The second close should be detected and the error should point to the
+	// file and chunk.
+	err = r.managedCloseEntry(chunk)
+	if !errors.Contains(err, errChunkEntryAlreadyClosed) {
+		t.Fatal("expected double close to be detected", err)
+	}
+	if !strings.Contains(err.Error(), siaPath.String()) {
+		t.Fatal("error doesn't contain the siapath", err)
+	}
+	if failed := r.uploadHeap.managedFailedCloses(); failed != 1 {
+		t.Fatal("expected 1 failed close but got", failed)
+	}
+
+	// An alert should have been registered.
+	_, _, warn, _ := r.staticAlerter.Alerts()
+	var found bool
+	for _, alert := range warn {
+		found = found || alert.Msg == AlertMSGFailedFileCloses
+	}
+	if !found {
+		t.Fatal("alert for failed closes wasn't registered")
+	}
+
+	// Resetting a heap with the closed chunk should count the failure as
+	// well.
+	var uch uploadChunkHeap
+	uch.Push(chunk)
+	failed, err := uch.reset()
+	if failed != 1 || !errors.Contains(err, errChunkEntryAlreadyClosed) {
+		t.Fatal("unexpected reset result", failed, err)
+	}
+
+	// A successful close should clear the counter and the alert.
+	chunk2, err := r.managedBuildUnfinishedChunk(f, 0, nil, nil, false, nil, nil, nil, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.managedCloseEntry(chunk2); err != nil {
+		t.Fatal(err)
+	}
+	if failed := r.uploadHeap.managedFailedCloses(); failed != 0 {
+		t.Fatal("expected the failed closes to be reset but got", failed)
+	}
+	_, _, warn, _ = r.staticAlerter.Alerts()
+	for _, alert := range warn {
+		if alert.Msg == AlertMSGFailedFileCloses {
+			t.Fatal("alert for failed closes wasn't unregistered")
+		}
+	}
+}
+
+// testManagedPrune verifies that managedPrune removes the chunks below the
+// health threshold from the heap and closes their file entries.
+func testManagedPrune(t *testing.T) {
+	// Create renter
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+	uh := &r.uploadHeap
+
+	// Create a file with multiple chunks.
+	rsc, _ := modules.NewRSCode(1, 1)
+	siaPath, err := modules.NewSiaPath("prune")
+	if err != nil {
+		t.Fatal(err)
+	}
+	numChunks := 6
+	err = r.staticFileSystem.NewSiaFile(siaPath, "", rsc, crypto.GenerateSiaKey(crypto.RandomCipherType()), uint64(numChunks)*modules.SectorSize, persist.DefaultDiskPermissionsTest, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := r.staticFileSystem.OpenSiaFile(siaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Push the chunks with alternating healths. Every other chunk is nearly
+	// healthy.
+	var chunks []*unfinishedUploadChunk
+	for i := 0; i < numChunks; i++ {
+		chunk, err := r.managedBuildUnfinishedChunk(f, uint64(i), nil, nil, false, nil, nil, nil, r.repairMemoryManager)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk.health = 0.5
+		if i%2 == 0 {
+			chunk.health = 0.01
+		}
+		if !uh.managedPush(chunk, chunkTypeLocalChunk) {
+			t.Fatal("chunk wasn't pushed")
+		}
+		chunks = append(chunks, chunk)
+	}
+	heapLen := uh.managedLen()
+	if heapLen != numChunks {
+		t.Fatalf("Expected heap length of %v but got %v", numChunks, heapLen)
+	}
+
+	// An invalid threshold should be rejected.
+	if _, err := r.PruneUploadHeap(-1); !errors.Contains(err, errInvalidHealthThreshold) {
+		t.Fatal("expected invalid threshold to be rejected", err)
+	}
+
+	// Prune the nearly healthy chunks.
+	pruned, err := r.PruneUploadHeap(0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != numChunks/2 {
+		t.Fatalf("Expected %v chunks to be pruned but got %v", numChunks/2, pruned)
+	}
+	if uh.managedLen() != heapLen-pruned {
+		t.Fatalf("Expected heap length of %v but got %v", heapLen-pruned, uh.managedLen())
+	}
+
+	// The pruned chunks should be closed and removed from the maps, the
+	// remaining chunks should still be open.
+	for _, chunk := range chunks {
+		chunk.mu.Lock()
+		closed := chunk.entryClosed
+		chunk.mu.Unlock()
+		pruned := chunk.health < 0.1
+		if closed != pruned {
+			t.Fatalf("chunk %v with health %v: expected closed to be %v", chunk.staticIndex, chunk.health, pruned)
+		}
+		if uh.managedExists(chunk.id) == pruned {
+			t.Fatalf("chunk %v with health %v: expected exists to be %v", chunk.staticIndex, chunk.health, !pruned)
+		}
+	}
+
+	// Pruning again shouldn't prune anything.
+	pruned, err = r.PruneUploadHeap(0.1)
+	if err != nil || pruned != 0 {
+		t.Fatal("nothing should have been pruned", pruned, err)
+	}
+
+	// Resetting the heap should close the remaining chunks without any
+	// chunk being closed twice.
+	if err := uh.managedReset(); err != nil {
+		t.Fatal(err)
+	}
+	for _, chunk := range chunks {
+		chunk.mu.Lock()
+		closed := chunk.entryClosed
+		chunk.mu.Unlock()
+		if !closed {
+			t.Fatal("file entry of chunk was leaked", chunk.staticIndex)
+		}
+	}
+	if failed := uh.managedFailedCloses(); failed != 0 {
+		t.Fatal("expected no failed closes but got", failed)
 	}
 }
@@ -0,0 +1,328 @@
+package renter
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+)
+
+const (
+	// uploadStagingTmpSuffix is the suffix of files in the staging directory
+	// which are still being copied.
+	uploadStagingTmpSuffix = ".tmp"
+
+	// uploadStagingCopyBufferSize is the size of the buffer used for copying
+	// a source file into the staging directory.
+	uploadStagingCopyBufferSize = 1 << 20 // 1 MiB
+)
+
+var (
+	// errUploadStagingDisabled is returned when trying to stage a file while
+	// upload staging is disabled.
+	errUploadStagingDisabled = errors.New("upload staging is disabled")
+
+	// errUploadStagingFull is returned when trying to stage a file that doesn't
+	// fit into the staging directory anymore.
+	errUploadStagingFull = errors.New("not enough space left in the upload staging directory")
+
+	// errUploadStagingRemoved is returned when a file is removed while it is
+	// being staged.
+	errUploadStagingRemoved = errors.New("file was removed while being staged")
+)
+
+// uploadStaging manages a local directory of staged copies of upload sources.
+// Uploading from a slow source, e.g. a network mount, stalls the erasure
+// coding of chunks. Staging the source on local disk first allows the repair
+// code to read the data from the staged copy instead.
+type uploadStaging struct {
+	// capacity is the maximum number of bytes that can be staged. A capacity
+	// of 0 disables staging.
+	capacity uint64
+
+	// used is the number of bytes currently reserved by staged and staging
+	// files.
+	used uint64
+
+	// staged contains the sizes of the files that were fully staged.
+	staged map[siafile.SiafileUID]uint64
+
+	// staging contains the sizes of the files that are currently being
+	// staged.
+	staging map[siafile.SiafileUID]uint64
+
+	staticDir string
+	mu        sync.Mutex
+}
+
+// newUploadStaging creates a new uploadStaging for the given directory. Files
+// which are already staged are picked up and leftovers of interrupted staging
+// operations are removed.
+func newUploadStaging(dir string, capacity uint64) (*uploadStaging, error) {
+	err := os.MkdirAll(dir, modules.DefaultDirPerm)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to create upload staging directory")
+	}
+	us := &uploadStaging{
+		capacity:  capacity,
+		staged:    make(map[siafile.SiafileUID]uint64),
+		staging:   make(map[siafile.SiafileUID]uint64),
+		staticDir: dir,
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.AddContext(err, "failed to read upload staging directory")
+	}
+	for _, fi := range fis {
+		if fi.IsDir() {
+			continue
+		}
+		if strings.HasSuffix(fi.Name(), uploadStagingTmpSuffix) {
+			err = os.Remove(filepath.Join(dir, fi.Name()))
+			if err != nil {
+				return nil, errors.AddContext(err, "failed to remove incomplete staged file")
+			}
+			continue
+		}
+		us.staged[siafile.SiafileUID(fi.Name())] = uint64(fi.Size())
+		us.used += uint64(fi.Size())
+	}
+	return us, nil
+}
+
+// callSetCapacity sets the maximum number of bytes that can be staged. Files
+// which are already staged are not affected by a lower capacity.
+func (us *uploadStaging) callSetCapacity(capacity uint64) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	us.capacity = capacity
+}
+
+// callStagedPath returns the path of the staged copy of the file with the
+// given uid if it was fully staged.
+func (us *uploadStaging) callStagedPath(uid siafile.SiafileUID) (string, bool) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	_, exists := us.staged[uid]
+	if !exists {
+		return "", false
+	}
+	return us.path(uid), true
+}
+
+// callStatus returns the current status of the upload staging.
+func (us *uploadStaging) callStatus() modules.UploadStagingStatus {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return modules.UploadStagingStatus{
+		Capacity: us.capacity,
+		NumFiles: uint64(len(us.staged)),
+		Used:     us.used,
+	}
+}
+
+// callStagedUIDs returns the uids of all the files that were fully staged.
+func (us *uploadStaging) callStagedUIDs() []siafile.SiafileUID {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	uids := make([]siafile.SiafileUID, 0, len(us.staged))
+	for uid := range us.staged {
+		uids = append(uids, uid)
+	}
+	return uids
+}
+
+// managedRemove removes the staged copy of the file with the given uid. It is
+// a no-op if the file isn't staged. If the file is still being staged, the
+// copy is discarded once it is complete.
+func (us *uploadStaging) managedRemove(uid siafile.SiafileUID) error {
+	us.mu.Lock()
+	delete(us.staging, uid)
+	size, exists := us.staged[uid]
+	if exists {
+		delete(us.staged, uid)
+		us.used -= size
+	}
+	us.mu.Unlock()
+	if !exists {
+		return nil
+	}
+	err := os.Remove(us.path(uid))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// managedStage copies size bytes from the provided reader into the staging
+// directory. The copy only becomes available through callStagedPath once it is
+// complete. If staging is disabled or the file doesn't fit into the staging
+// directory, an error is returned and the caller should fall back to reading
+// from the source directly.
+func (us *uploadStaging) managedStage(uid siafile.SiafileUID, r io.Reader, size uint64, stop <-chan struct{}) (err error) {
+	// Reserve the space.
+	us.mu.Lock()
+	if us.capacity == 0 {
+		us.mu.Unlock()
+		return errUploadStagingDisabled
+	}
+	_, staged := us.staged[uid]
+	_, staging := us.staging[uid]
+	if staged || staging {
+		us.mu.Unlock()
+		return errors.New("file is already staged")
+	}
+	if us.used+size > us.capacity {
+		us.mu.Unlock()
+		return errUploadStagingFull
+	}
+	us.used += size
+	us.staging[uid] = size
+	us.mu.Unlock()
+
+	// Release the reservation on failure and mark the file as staged on
+	// success. A file that was removed while being staged is discarded.
+	tmpPath := us.path(uid) + uploadStagingTmpSuffix
+	defer func() {
+		us.mu.Lock()
+		defer us.mu.Unlock()
+		_, staging := us.staging[uid]
+		delete(us.staging, uid)
+		if err == nil && !staging {
+			err = errors.Compose(errUploadStagingRemoved, os.RemoveAll(us.path(uid)))
+		}
+		if err != nil {
+			us.used -= size
+			err = errors.Compose(err, os.RemoveAll(tmpPath))
+			return
+		}
+		us.staged[uid] = size
+	}()
+
+	// Copy the data into a temporary file and rename it once it is complete.
+	f, err := os.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_TRUNC, modules.DefaultFilePerm)
+	if err != nil {
+		return errors.AddContext(err, "failed to create staging file")
+	}
+	err = copyStaged(f, io.LimitReader(r, int64(size)), size, stop)
+	if err == nil {
+		err = f.Sync()
+	}
+	err = errors.Compose(err, f.Close())
+	if err != nil {
+		return errors.AddContext(err, "failed to copy source into staging file")
+	}
+	return os.Rename(tmpPath, us.path(uid))
+}
+
+// path returns the path of the staged copy of the file with the given uid.
+func (us *uploadStaging) path(uid siafile.SiafileUID) string {
+	return filepath.Join(us.staticDir, string(uid))
+}
+
+// copyStaged copies size bytes from r to w. It aborts early if the stop
+// channel is closed.
+func copyStaged(w io.Writer, r io.Reader, size uint64, stop <-chan struct{}) error {
+	buf := make([]byte, uploadStagingCopyBufferSize)
+	var copied uint64
+	for copied < size {
+		select {
+		case <-stop:
+			return errors.New("staging was interrupted")
+		default:
+		}
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+			copied += uint64(n)
+		}
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+	}
+	if copied != size {
+		return errors.New("source is smaller than expected")
+	}
+	return nil
+}
+
+// managedStageUpload stages the source of a new upload if upload staging is
+// enabled. Failing to stage the source is not an error since the upload can
+// still read from the source directly.
+func (r *Renter) managedStageUpload(uid siafile.SiafileUID, source string, size uint64) {
+	if r.staticUploadStaging.callStatus().Capacity == 0 {
+		return
+	}
+	f, err := os.Open(source)
+	if err != nil {
+		r.log.Debugln("WARN: failed to open source for staging:", err)
+		return
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			r.log.Debugln("WARN: failed to close staged source:", err)
+		}
+	}()
+	err = r.staticUploadStaging.managedStage(uid, f, size, r.tg.StopChan())
+	if errors.Contains(err, errUploadStagingDisabled) || errors.Contains(err, errUploadStagingRemoved) {
+		return
+	}
+	if err != nil {
+		r.log.Debugf("Not staging %v, reading from source instead: %v", source, err)
+	}
+}
+
+// managedRemoveStagedUploads removes the staged copies of the files with the
+// given uids.
+func (r *Renter) managedRemoveStagedUploads(uids []siafile.SiafileUID) {
+	for _, uid := range uids {
+		if err := r.staticUploadStaging.managedRemove(uid); err != nil {
+			r.log.Printf("WARN: failed to remove staged copy of siafile with uid %v: %v", uid, err)
+		}
+	}
+}
+
+// threadedPruneUploadStaging removes the staged copies of files that no longer
+// exist. Those are left behind when the renter shuts down before the staged
+// copy of a deleted file is removed.
+func (r *Renter) threadedPruneUploadStaging() {
+	if err := r.tg.Add(); err != nil {
+		return
+	}
+	defer r.tg.Done()
+
+	// Grab the staged uids before listing the siafiles. Files which are
+	// staged afterwards belong to siafiles which already exist and are
+	// therefore never pruned by mistake.
+	staged := r.staticUploadStaging.callStagedUIDs()
+	if len(staged) == 0 {
+		return
+	}
+	uids, err := r.managedSiaFileUIDs(modules.RootSiaPath())
+	if err != nil {
+		r.log.Println("WARN: failed to list siafiles for pruning the upload staging directory:", err)
+		return
+	}
+	exists := make(map[siafile.SiafileUID]struct{}, len(uids))
+	for _, uid := range uids {
+		exists[uid] = struct{}{}
+	}
+	var orphaned []siafile.SiafileUID
+	for _, uid := range staged {
+		if _, ok := exists[uid]; !ok {
+			orphaned = append(orphaned, uid)
+		}
+	}
+	r.managedRemoveStagedUploads(orphaned)
+}
@@ -0,0 +1,338 @@
+package renter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+	"gitlab.com/NebulousLabs/fastrand"
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
+	"go.sia.tech/siad/persist"
+	"go.sia.tech/siad/siatest/dependencies"
+	"go.sia.tech/siad/types"
+)
+
+// slowReader is a reader which sleeps before every read to simulate a slow
+// upload source.
+type slowReader struct {
+	staticDelay time.Duration
+	io.Reader
+}
+
+// Read implements io.Reader.
+func (sr *slowReader) Read(b []byte) (int, error) {
+	time.Sleep(sr.staticDelay)
+	if len(b) > 100 {
+		b = b[:100]
+	}
+	return sr.Reader.Read(b)
+}
+
+// TestUploadStaging is a unit test for the uploadStaging.
+func TestUploadStaging(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	dir := build.TempDir("renter", t.Name())
+	us, err := newUploadStaging(dir, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fastrand.Bytes(1000)
+	uid := siafile.SiafileUID("file1")
+
+	// Staging is disabled by default.
+	err = us.managedStage(uid, bytes.NewReader(data), uint64(len(data)), nil)
+	if !errors.Contains(err, errUploadStagingDisabled) {
+		t.Fatal("expected staging to be disabled", err)
+	}
+
+	// A file that is too large shouldn't be staged.
+	us.callSetCapacity(uint64(len(data)) + 1)
+	err = us.managedStage(uid, bytes.NewReader(data), 2*uint64(len(data)), nil)
+	if !errors.Contains(err, errUploadStagingFull) {
+		t.Fatal("expected staging to be full", err)
+	}
+
+	// Stage a file from a slow reader. It shouldn't be available until it is
+	// fully staged.
+	done := make(chan error)
+	go func() {
+		sr := &slowReader{staticDelay: 10 * time.Millisecond, Reader: bytes.NewReader(data)}
+		done <- us.managedStage(uid, sr, uint64(len(data)), nil)
+	}()
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		if status := us.callStatus(); status.Used != uint64(len(data)) {
+			return errors.New("space wasn't reserved")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := us.callStagedPath(uid); ok {
+		t.Fatal("file shouldn't be available while staging")
+	}
+	if status := us.callStatus(); status.NumFiles != 0 {
+		t.Fatal("file shouldn't be staged yet", status)
+	}
+
+	// Another file shouldn't fit anymore.
+	err = us.managedStage(siafile.SiafileUID("file2"), bytes.NewReader(data), uint64(len(data)), nil)
+	if !errors.Contains(err, errUploadStagingFull) {
+		t.Fatal("expected staging to be full", err)
+	}
+
+	// Wait for the staging to finish.
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	path, ok := us.callStagedPath(uid)
+	if !ok {
+		t.Fatal("file should be staged")
+	}
+	staged, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(staged, data) {
+		t.Fatal("staged data doesn't match")
+	}
+	if status := us.callStatus(); status.NumFiles != 1 || status.Used != uint64(len(data)) {
+		t.Fatal("unexpected status", status)
+	}
+
+	// An interrupted staging should be cleaned up.
+	us.callSetCapacity(2*uint64(len(data)) + 1)
+	stop := make(chan struct{})
+	close(stop)
+	err = us.managedStage(siafile.SiafileUID("file2"), bytes.NewReader(data), uint64(len(data)), stop)
+	if err == nil {
+		t.Fatal("staging should fail")
+	}
+	if status := us.callStatus(); status.NumFiles != 1 || status.Used != uint64(len(data)) {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Leave a leftover temporary file and reload the staging. The staged file
+	// should be picked up and the leftover removed.
+	tmpPath := filepath.Join(dir, "file3"+uploadStagingTmpSuffix)
+	if err := ioutil.WriteFile(tmpPath, data, modules.DefaultFilePerm); err != nil {
+		t.Fatal(err)
+	}
+	us, err = newUploadStaging(dir, 2*uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status := us.callStatus(); status.NumFiles != 1 || status.Used != uint64(len(data)) {
+		t.Fatal("unexpected status", status)
+	}
+	if _, err := os.Stat(tmpPath); !os.IsNotExist(err) {
+		t.Fatal("leftover wasn't removed", err)
+	}
+
+	// Remove the staged file.
+	if err := us.managedRemove(uid); err != nil {
+		t.Fatal(err)
+	}
+	if status := us.callStatus(); status.NumFiles != 0 || status.Used != 0 {
+		t.Fatal("unexpected status", status)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("staged file wasn't removed", err)
+	}
+
+	// Removing a file while it is being staged discards the staged copy.
+	go func() {
+		sr := &slowReader{staticDelay: 10 * time.Millisecond, Reader: bytes.NewReader(data)}
+		done <- us.managedStage(uid, sr, uint64(len(data)), nil)
+	}()
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		if status := us.callStatus(); status.Used != uint64(len(data)) {
+			return errors.New("space wasn't reserved")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := us.managedRemove(uid); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; !errors.Contains(err, errUploadStagingRemoved) {
+		t.Fatal("expected staging to be discarded", err)
+	}
+	if status := us.callStatus(); status.NumFiles != 0 || status.Used != 0 {
+		t.Fatal("unexpected status", status)
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 0 {
+		t.Fatal("staging directory should be empty", len(fis))
+	}
+}
+
+// TestRenterUploadStaging tests that an upload is staged if staging is
+// enabled, that the repair reads from the staged copy and that the staged copy
+// is removed once the file is fully redundant or deleted.
+func TestRenterUploadStaging(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	rt, err := newRenterTesterWithDependency(t.Name(), &dependencies.DependencyDisableRepairAndHealthLoops{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := rt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := rt.renter
+
+	// Enable staging.
+	r.staticUploadStaging.callSetCapacity(modules.SectorSize)
+
+	// upload is a helper to upload a file.
+	data := fastrand.Bytes(1000)
+	upload := func() (string, modules.FileUploadParams) {
+		source := filepath.Join(r.staticFileSystem.Root(), persist.RandomSuffix())
+		if err := ioutil.WriteFile(source, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		siaPath, err := modules.RandomSiaPath().Join(persist.RandomSuffix())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rsc, _ := modules.NewRSCode(1, 1)
+		up := modules.FileUploadParams{
+			Source:      source,
+			SiaPath:     siaPath,
+			ErasureCode: rsc,
+		}
+		if err := r.Upload(up); err != nil {
+			t.Fatal(err)
+		}
+		return source, up
+	}
+	// checkStatus is a helper to check the staging status. Uploads are
+	// staged in the background.
+	checkStatus := func(numFiles uint64) {
+		err := build.Retry(100, 10*time.Millisecond, func() error {
+			status, err := r.UploadStagingStatus()
+			if err != nil {
+				return err
+			}
+			if status.NumFiles != numFiles || status.Used != numFiles*uint64(len(data)) || status.Capacity != modules.SectorSize {
+				return fmt.Errorf("unexpected status %v", status)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Upload a file. It should be staged.
+	source, up := upload()
+	checkStatus(1)
+
+	// Remove the source. The logical data should still be fetched from the
+	// staged copy.
+	if err := os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := r.staticFileSystem.OpenSiaFile(up.SiaPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := make(map[string]struct{})
+	pks := make(map[string]types.SiaPublicKey)
+	offline := make(map[string]bool)
+	goodForRenew := make(map[string]bool)
+	uc, err := r.managedBuildUnfinishedChunk(entry, 0, hosts, pks, memoryPriorityLow, offline, goodForRenew, nil, r.repairMemoryManager)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.managedFetchLogicalChunkData(uc); err != nil {
+		t.Fatal(err)
+	}
+	if len(uc.logicalChunkData) == 0 {
+		t.Fatal("no logical data was fetched")
+	}
+	if err := uc.fileEntry.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend that the file was fully uploaded. Updating its metadata should
+	// remove the staged copy.
+	if err := entry.SetAllStuck(false); err != nil {
+		t.Fatal(err)
+	}
+	for pieceIndex := uint64(0); pieceIndex < uint64(entry.ErasureCode().NumPieces()); pieceIndex++ {
+		hpk := types.SiaPublicKey{Algorithm: types.SignatureEd25519, Key: fastrand.Bytes(crypto.PublicKeySize)}
+		if err := entry.AddPiece(hpk, 0, pieceIndex, crypto.Hash{}); err != nil {
+			t.Fatal(err)
+		}
+		offline[hpk.String()] = false
+		goodForRenew[hpk.String()] = true
+	}
+	if err := r.managedUpdateFileMetadata(entry, offline, goodForRenew, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := entry.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(0)
+
+	// Upload another file. Deleting the file should remove the staged copy.
+	_, up = upload()
+	checkStatus(1)
+	if err := r.DeleteFile(up.SiaPath); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(0)
+
+	// Upload another file. Deleting its directory should remove the staged
+	// copy.
+	_, up = upload()
+	checkStatus(1)
+	dir, err := up.SiaPath.Dir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.DeleteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(0)
+
+	// Upload another file and delete it while the renter isn't watching. The
+	// staged copy should be pruned on startup.
+	_, up = upload()
+	checkStatus(1)
+	if err := r.staticFileSystem.DeleteFile(up.SiaPath); err != nil {
+		t.Fatal(err)
+	}
+	checkStatus(1)
+	r, err = rt.reloadRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.staticUploadStaging.callSetCapacity(modules.SectorSize)
+	checkStatus(0)
+}
@@ -235,6 +235,7 @@ func (r *Renter) callUploadStreamFromReader(up modules.FileUploadParams, reader
 
 	// Get the most recent workers.
 	hosts := r.managedRefreshHostsAndWorkers()
+	expensiveHosts := r.staticWorkerPool.callExpensiveUploadHosts()
 
 	// Check if we currently have enough workers for the specified redundancy.
 	minWorkers := fileNode.ErasureCode().MinPieces()
@@ -266,7 +267,7 @@ func (r *Renter) callUploadStreamFromReader(up modules.FileUploadParams, reader
 
 		// Start the chunk upload.
 		offline, goodForRenew, _ := r.managedContractUtilityMaps()
-		uuc, err := r.managedBuildUnfinishedChunk(fileNode, chunkIndex, hosts, pks, memoryPriorityHigh, offline, goodForRenew, r.userUploadMemoryManager)
+		uuc, err := r.managedBuildUnfinishedChunk(fileNode, chunkIndex, hosts, pks, memoryPriorityHigh, offline, goodForRenew, expensiveHosts, r.userUploadMemoryManager)
 		if err != nil {
 			return nil, errors.AddContext(err, "unable to fetch chunk for stream")
 		}
@@ -16,6 +16,7 @@ package renter
 import (
 	"container/list"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -35,6 +36,15 @@ const (
 	// host to support the registry.
 	minRegistryVersion = "1.5.1"
 
+	// minHasSectorsVersion defines the minimum version that is required for a
+	// host to support looking up multiple sectors with a single HasSectors
+	// instruction.
+	minHasSectorsVersion = "1.5.8"
+
+	// minPinPriceTableVersion defines the minimum version that is required for
+	// a host to support pinning price tables.
+	minPinPriceTableVersion = "1.5.9"
+
 	// registryCacheSize is the cache size used by a single worker for the
 	// registry cache.
 	registryCacheSize = 1 << 20 // 1 MiB
@@ -68,10 +78,14 @@ type (
 	worker struct {
 		// Atomics are used to minimize lock contention on the worker object.
 		atomicAccountBalanceCheckRunning uint64         // used for a sanity check
+		atomicBytesDownloaded            uint64         // bytes downloaded by the worker's read jobs
+		atomicBytesUploaded              uint64         // bytes uploaded by the worker's upload jobs
 		atomicCache                      unsafe.Pointer // points to a workerCache object
 		atomicCacheUpdating              uint64         // ensures only one cache update happens at a time
 		atomicPriceTable                 unsafe.Pointer // points to a workerPriceTable object
 		atomicPriceTableUpdateRunning    uint64         // used for a sanity check
+		atomicStandby                    uint64         // set by the worker pool if the worker shouldn't receive uploads
+		atomicSubscriptionLoopRunning    uint64         // ensures only one subscription loop runs at a time
 
 		// The host pub key also serves as an id for the worker, as there is
 		// only one worker per host.
@@ -95,6 +109,12 @@ type (
 		uploadRecentFailureErr    error         // What was the reason for the last failure?
 		uploadTerminated          bool          // Have we stopped uploading?
 
+		// Bandwidth variables. The speeds are exponentially weighted moving
+		// averages in bytes per second, updated after every piece upload and
+		// read job.
+		downloadSpeed float64
+		uploadSpeed   float64
+
 		// The staticAccount represent the renter's ephemeral account on the
 		// host. It keeps track of the available balance in the account, the
 		// worker has a refill mechanism that keeps the account balance filled
@@ -195,6 +215,12 @@ func (w *worker) staticKilled() bool {
 	}
 }
 
+// staticStandby returns whether the worker pool put the worker in standby,
+// meaning it shouldn't receive any new upload chunks.
+func (w *worker) staticStandby() bool {
+	return atomic.LoadUint64(&w.atomicStandby) == 1
+}
+
 // staticWake will wake the worker from sleeping. This should be called any time
 // that a job is queued or a job completes.
 func (w *worker) staticWake() {
@@ -239,8 +265,9 @@ func (r *Renter) newWorker(hostPubKey types.SiaPublicKey) (*worker, error) {
 		staticRegistryCache: newRegistryCache(registryCacheSize),
 
 		staticSubscriptionInfo: &subscriptionInfos{
-			subscriptions:  make(map[modules.RegistryEntryID]*subscription),
-			staticWakeChan: make(chan struct{}, 1),
+			subscriptions:    make(map[modules.RegistryEntryID]*subscription),
+			maxSubscriptions: r.managedMaxSubscriptionsPerWorker(),
+			staticWakeChan:   make(chan struct{}, 1),
 		},
 
 		// Initialize the read and write limits for the async worker tasks.
@@ -6,6 +6,7 @@ import (
 	"io"
 	"net"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -53,6 +54,10 @@ const (
 )
 
 var (
+	// errAccountNotFound is returned if the renter doesn't have an account
+	// with a host.
+	errAccountNotFound = errors.New("account not found")
+
 	// accountIdleCheckFrequency establishes how frequently the sync function
 	// should check whether the worker is idle. A relatively high frequency is
 	// okay, because this function only runs while the worker is frozen and
@@ -124,6 +129,13 @@ type (
 		// actions are downloads, registry reads, registry writes, etc.
 		spending spendingDetails
 
+		// prediction predicts the account's spending to size its refills.
+		prediction spendingPrediction
+
+		// firstSeen is the time the account was first persisted. It provides
+		// the time context for the balance drift.
+		firstSeen time.Time
+
 		// Error tracking.
 		recentErr         error
 		recentErrTime     time.Time
@@ -133,6 +145,16 @@ type (
 		// the host.
 		syncAt time.Time
 
+		// After an unclean shutdown the account's balance is unknown and reset
+		// to zero. 'pendingRecovery' indicates the balance still has to be
+		// restored to the balance reported by the host, 'recoveryRetry' is set
+		// after a successful price table update to retry a recovery that
+		// failed before. 'recoveredFromDirtyShutdown' indicates the balance
+		// was restored that way.
+		pendingRecovery            bool
+		recoveryRetry              bool
+		recoveredFromDirtyShutdown bool
+
 		// Variables to manage a race condition around account creation, where
 		// the account must be available in the data structure before it has
 		// been synced to disk successfully (to avoid holding a lock on the
@@ -175,6 +197,11 @@ type (
 	spendingCategory uint64
 )
 
+// isRepair returns true if the category belongs to repair traffic.
+func (category spendingCategory) isRepair() bool {
+	return category == categoryRepairDownload || category == categoryRepairUpload
+}
+
 // update will add the the spend of given amount to the appropriate field
 // depending on the given category
 func (s *spendingDetails) update(category spendingCategory, amount types.Currency) {
@@ -256,6 +283,14 @@ func (a *account) availableBalance() types.Currency {
 	return types.ZeroCurrency
 }
 
+// callNeedsToRecover returns whether or not the account's balance still needs
+// to be recovered after an unclean shutdown.
+func (a *account) callNeedsToRecover() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pendingRecovery
+}
+
 // callNeedsToSync returns whether or not the account needs to sync to the host.
 func (a *account) callNeedsToSync() bool {
 	a.mu.Lock()
@@ -372,6 +407,7 @@ func (a *account) managedCommitWithdrawal(category spendingCategory, withdrawal,
 
 		// only in case of success we track the spend and what it was spent on
 		a.trackSpending(category, withdrawal)
+		a.prediction.track(time.Now(), withdrawal)
 	}
 }
 
@@ -390,17 +426,8 @@ func (a *account) managedSyncBalance(balance types.Currency) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	// Determine how long to wait before attempting to sync again, and then
-	// update the syncAt time. There is significant randomness in the
-	// waiting because syncing with the host requires freezing up the
-	// worker. We do not want to freeze up a large number of workers at
-	// once, nor do we want to freeze them frequently.
-	defer func() {
-		randWait := fastrand.Intn(accountSyncRandWaitMilliseconds)
-		waitTime := time.Duration(randWait) * time.Millisecond
-		waitTime += accountSyncMinWaitTime
-		a.syncAt = time.Now().Add(waitTime)
-	}()
+	// Determine how long to wait before attempting to sync again.
+	defer a.scheduleSync()
 
 	// If our balance is equal to what the host communicated, we're done.
 	currBalance := a.availableBalance()
@@ -429,6 +456,42 @@ func (a *account) managedSyncBalance(balance types.Currency) {
 	}
 }
 
+// managedRecoverBalance restores the account's balance after an unclean
+// shutdown to the given balance, which was returned by the host, capped by the
+// given max balance. Other than managedSyncBalance this does not count towards
+// the drift, the balance was only unknown.
+func (a *account) managedRecoverBalance(balance, maxBalance types.Currency) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	defer a.scheduleSync()
+
+	if balance.Cmp(maxBalance) > 0 {
+		balance = maxBalance
+	}
+	a.resetBalance(balance)
+	a.pendingRecovery = false
+	a.recoveryRetry = false
+	a.recoveredFromDirtyShutdown = true
+
+	// Persist the account
+	err := a.persist()
+	if err != nil {
+		a.staticRenter.log.Printf("could not persist account, err: %v\n", err)
+	}
+}
+
+// scheduleSync determines how long to wait before attempting to sync again,
+// and then updates the syncAt time. There is significant randomness in the
+// waiting because syncing with the host requires freezing up the worker. We do
+// not want to freeze up a large number of workers at once, nor do we want to
+// freeze them frequently.
+func (a *account) scheduleSync() {
+	randWait := fastrand.Intn(accountSyncRandWaitMilliseconds)
+	waitTime := time.Duration(randWait) * time.Millisecond
+	waitTime += accountSyncMinWaitTime
+	a.syncAt = time.Now().Add(waitTime)
+}
+
 // managedStatus returns the status of the account
 func (a *account) managedStatus() modules.WorkerAccountStatus {
 	a.mu.Lock()
@@ -443,12 +506,53 @@ func (a *account) managedStatus() modules.WorkerAccountStatus {
 		AvailableBalance: a.availableBalance(),
 		NegativeBalance:  a.negativeBalance,
 
+		PredictedSpendingRate: a.prediction.rate,
+		RefillSize:            a.prediction.refillSize,
+		RefillTarget:          a.prediction.target,
+
 		RecentErr:         recentErrStr,
 		RecentErrTime:     a.recentErrTime,
 		RecentSuccessTime: a.recentSuccessTime,
 	}
 }
 
+// managedInfo returns information about the account's balance and spending.
+// All values are read under the account's lock so that they are consistent
+// with each other.
+func (a *account) managedInfo() modules.RenterAccount {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	driftPositive, driftNegative := a.persistence().BalanceDriftStats(time.Now())
+	return modules.RenterAccount{
+		AccountID: a.staticID.SPK().String(),
+		HostKey:   a.staticHostKey,
+
+		AvailableBalance:   a.availableBalance(),
+		Balance:            a.balance,
+		NegativeBalance:    a.negativeBalance,
+		PendingDeposits:    a.pendingDeposits,
+		PendingWithdrawals: a.pendingWithdrawals,
+
+		BalanceDriftNegativePerDay: driftNegative,
+		BalanceDriftPositivePerDay: driftPositive,
+		FirstSeen:                  a.firstSeen,
+
+		RecoveredFromDirtyShutdown: a.recoveredFromDirtyShutdown,
+
+		Spending: modules.RenterAccountSpending{
+			Downloads:         a.spending.downloads,
+			RegistryReads:     a.spending.registryReads,
+			RegistryWrites:    a.spending.registryWrites,
+			RepairDownloads:   a.spending.repairDownloads,
+			RepairUploads:     a.spending.repairUploads,
+			SnapshotDownloads: a.spending.snapshotDownloads,
+			SnapshotUploads:   a.spending.snapshotUploads,
+			Subscriptions:     a.spending.subscriptions,
+			Uploads:           a.spending.uploads,
+		},
+	}
+}
+
 // managedTrackDeposit keeps track of pending deposits by adding the given
 // amount to the 'pendingDeposits' field.
 func (a *account) managedTrackDeposit(amount types.Currency) {
@@ -494,6 +598,81 @@ func (a *account) trackSpending(category spendingCategory, amount types.Currency
 	}
 }
 
+// managedAccount returns the account for the given host if it exists and was
+// persisted successfully. Unlike managedOpenAccount it never creates a new
+// account.
+func (am *accountManager) managedAccount(hostKey types.SiaPublicKey) (*account, bool) {
+	am.mu.Lock()
+	acc, exists := am.accounts[hostKey.String()]
+	am.mu.Unlock()
+	if !exists {
+		return nil, false
+	}
+	// Skip accounts that are still being created.
+	select {
+	case <-acc.staticReady:
+	default:
+		return nil, false
+	}
+	return acc, acc.externActive
+}
+
+// managedAccounts returns all accounts that were persisted successfully.
+func (am *accountManager) managedAccounts() []*account {
+	am.mu.Lock()
+	accounts := make([]*account, 0, len(am.accounts))
+	for _, acc := range am.accounts {
+		accounts = append(accounts, acc)
+	}
+	am.mu.Unlock()
+
+	var active []*account
+	for _, acc := range accounts {
+		// Skip accounts that are still being created.
+		select {
+		case <-acc.staticReady:
+		default:
+			continue
+		}
+		if acc.externActive {
+			active = append(active, acc)
+		}
+	}
+	return active
+}
+
+// Account returns information about the renter's ephemeral account with the
+// host with the given key.
+func (r *Renter) Account(hostKey types.SiaPublicKey) (modules.RenterAccount, error) {
+	if err := r.tg.Add(); err != nil {
+		return modules.RenterAccount{}, err
+	}
+	defer r.tg.Done()
+	acc, exists := r.staticAccountManager.managedAccount(hostKey)
+	if !exists {
+		return modules.RenterAccount{}, errAccountNotFound
+	}
+	return acc.managedInfo(), nil
+}
+
+// Accounts returns information about all of the renter's ephemeral accounts,
+// sorted by host key.
+func (r *Renter) Accounts() ([]modules.RenterAccount, error) {
+	if err := r.tg.Add(); err != nil {
+		return nil, err
+	}
+	defer r.tg.Done()
+	accounts := r.staticAccountManager.managedAccounts()
+	infos := make([]modules.RenterAccount, 0, len(accounts))
+	for _, acc := range accounts {
+		infos = append(infos, acc.managedInfo())
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].HostKey.String() < infos[j].HostKey.String()
+	})
+	return infos, nil
+}
+
 // newWithdrawalMessage is a helper function that takes a set of parameters and
 // a returns a new WithdrawalMessage.
 func newWithdrawalMessage(id modules.AccountID, amount types.Currency, blockHeight types.BlockHeight) modules.WithdrawalMessage {
@@ -570,6 +749,12 @@ func (w *worker) externSyncAccountBalanceToHost() {
 		build.Critical("managedSyncAccountBalanceToHost is called on a worker with an account that has non-zero deltas, indicating in-progress jobs")
 	}
 
+	// Consume a pending recovery retry, if the balance check fails the
+	// recovery is retried after the next successful price table update.
+	w.staticAccount.mu.Lock()
+	w.staticAccount.recoveryRetry = false
+	w.staticAccount.mu.Unlock()
+
 	// Track the outcome of the account sync - this ensures a proper working of
 	// the maintenance cooldown mechanism.
 	balance, err := w.staticHostAccountBalance()
@@ -579,6 +764,13 @@ func (w *worker) externSyncAccountBalanceToHost() {
 		return
 	}
 
+	// If the account's balance was lost in an unclean shutdown, restore it to
+	// the host's version of our balance.
+	if w.staticAccount.callNeedsToRecover() {
+		w.managedRecoverAccountBalance(balance)
+		return
+	}
+
 	// Sync the account with the host's version of our balance. This will update
 	// our balance in case the host tells us we actually have more money, and it
 	// will keep track of drift in both directions.
@@ -589,6 +781,19 @@ func (w *worker) externSyncAccountBalanceToHost() {
 	// accordingly. Perform this check at startup and periodically.
 }
 
+// managedRecoverAccountBalance restores the balance of the worker's account
+// after an unclean shutdown to the given balance reported by the host. The
+// balance is capped by the host's max ephemeral account balance.
+func (w *worker) managedRecoverAccountBalance(balance types.Currency) {
+	maxBalance := modules.DefaultMaxEphemeralAccountBalance
+	host, ok, err := w.renter.hostDB.Host(w.staticHostPubKey)
+	if ok && err == nil {
+		maxBalance = host.MaxEphemeralAccountBalance
+	}
+	w.staticAccount.managedRecoverBalance(balance, maxBalance)
+	w.renter.log.Printf("Recovered account balance of %v on host %v after unclean shutdown", balance, w.staticHostPubKeyStr)
+}
+
 // managedNeedsToRefillAccount will check whether the worker's account needs to
 // be refilled. This function will return false if any conditions are met which
 // are likely to prevent the refill from being successful.
@@ -603,21 +808,29 @@ func (w *worker) managedNeedsToRefillAccount() bool {
 		return false
 	}
 
-	return w.staticAccount.managedNeedsToRefill(w.staticBalanceTarget.Div64(2))
+	return w.staticAccount.managedNeedsToRefill(w.managedRefillTarget().Div64(2))
 }
 
 // managedNeedsToSyncAccountBalanceToHost returns true if the renter needs to
 // sync the renter's account balance with the host's version of the account.
 func (w *worker) managedNeedsToSyncAccountBalanceToHost() bool {
-	// No need to sync the account if the worker's RHP3 is on cooldown.
-	if w.managedOnMaintenanceCooldown() {
-		return false
-	}
 	// No need to sync if the price table is not valid, as it would only
 	// result in failure anyway.
 	if !w.staticPriceTable().staticValid() {
 		return false
 	}
+	// A failed recovery is retried after every successful price table update,
+	// even if the worker is on cooldown.
+	w.staticAccount.mu.Lock()
+	retryRecovery := w.staticAccount.pendingRecovery && w.staticAccount.recoveryRetry
+	w.staticAccount.mu.Unlock()
+	if retryRecovery {
+		return true
+	}
+	// No need to sync the account if the worker's RHP3 is on cooldown.
+	if w.managedOnMaintenanceCooldown() {
+		return false
+	}
 
 	return w.staticAccount.callNeedsToSync()
 }
@@ -627,11 +840,16 @@ func (w *worker) managedRefillAccount() {
 	if w.renter.deps.Disrupt("DisableFunding") {
 		return // don't refill account
 	}
-	// The account balance dropped to below half the balance target, refill. Use
-	// the max expected balance when refilling to avoid exceeding any host
-	// maximums.
+	// The account balance dropped to below half the refill target, refill. The
+	// target covers the predicted spending of the account which batches many
+	// small refills into fewer large ones. Use the max expected balance when
+	// refilling to avoid exceeding any host maximums.
 	balance := w.staticAccount.managedMaxExpectedBalance()
-	amount := w.staticBalanceTarget.Sub(balance)
+	target := w.managedRefillTarget()
+	if balance.Cmp(target) >= 0 {
+		return // the target was lowered since the check
+	}
+	amount := target.Sub(balance)
 	pt := w.staticPriceTable().staticPriceTable
 
 	// If the target amount is larger than the remaining money, adjust the
@@ -666,6 +884,7 @@ func (w *worker) managedRefillAccount() {
 		if err == nil {
 			w.staticAccount.mu.Lock()
 			w.staticAccount.recentSuccessTime = time.Now()
+			w.staticAccount.prediction.refillSize = amount
 			w.staticAccount.mu.Unlock()
 			return
 		}
@@ -690,8 +909,9 @@ func (w *worker) managedRefillAccount() {
 		})
 	}()
 
-	// check the current price table for gouging errors
-	err = checkFundAccountGouging(w.staticPriceTable().staticPriceTable, w.staticCache().staticRenterAllowance, w.staticBalanceTarget)
+	// check the current price table for gouging errors, the account is
+	// refilled to the refill target so that's what the cost is based on
+	err = checkFundAccountGouging(pt, w.staticCache().staticRenterAllowance, target)
 	if err != nil {
 		return
 	}
@@ -60,9 +60,11 @@ func TestAccount(t *testing.T) {
 	t.Run("MinMaxExpectedBalance", testAccountMinAndMaxExpectedBalance)
 	t.Run("TrackSpending", testAccountTrackSpending)
 	t.Run("SyncBalance", testAccountSyncBalance)
+	t.Run("RecoverBalance", testAccountRecoverBalance)
 
 	t.Run("Creation", func(t *testing.T) { testAccountCreation(t, rt) })
 	t.Run("Tracking", func(t *testing.T) { testAccountTracking(t, rt) })
+	t.Run("Info", func(t *testing.T) { testAccountInfo(t, rt) })
 }
 
 // TestWorkerAccount verifies the functionality of the account related
@@ -89,6 +91,10 @@ func TestWorkerAccount(t *testing.T) {
 		testWorkerAccountHostAccountBalance(t, wt)
 	})
 
+	t.Run("RecoverAccountBalance", func(t *testing.T) {
+		testWorkerAccountRecoverAccountBalance(t, wt)
+	})
+
 	t.Run("SyncAccountBalanceToHostCritical", func(t *testing.T) {
 		testWorkerAccountSyncAccountBalanceToHostCritical(t, wt)
 	})
@@ -252,6 +258,61 @@ func testAccountSyncBalance(t *testing.T) {
 	}
 }
 
+// testAccountRecoverBalance is a small unit test that verifies the
+// functionality of the recover balance function using a mocked balance
+// response from the host.
+func testAccountRecoverBalance(t *testing.T) {
+	t.Parallel()
+
+	// create a mock of the accounts file
+	deps := modules.ProductionDependencies{}
+	f, err := deps.OpenFile(filepath.Join(t.TempDir(), accountsFilename), os.O_RDWR|os.O_CREATE, defaultFilePerm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = f.Close()
+		if err != nil {
+			t.Fatal("err")
+		}
+	}()
+
+	// mock the host's balance response
+	hostBalance := types.NewCurrency64(fastrand.Uint64n(100) + 10)
+
+	a := new(account)
+	a.staticFile = f
+	a.pendingRecovery = true
+	a.recoveryRetry = true
+	if !a.callNeedsToRecover() {
+		t.Fatal("account should need to recover")
+	}
+	a.managedRecoverBalance(hostBalance, hostBalance.Mul64(2))
+
+	if !a.balance.Equals(hostBalance) {
+		t.Fatal("unexpected balance after recovery", a.balance)
+	}
+	if a.callNeedsToRecover() || a.recoveryRetry {
+		t.Fatal("account shouldn't need to recover anymore")
+	}
+	if !a.recoveredFromDirtyShutdown {
+		t.Fatal("account should be marked as recovered")
+	}
+	if !a.balanceDriftPositive.IsZero() || !a.balanceDriftNegative.IsZero() {
+		t.Fatal("recovery shouldn't count towards the drift")
+	}
+	if a.syncAt == (time.Time{}) {
+		t.Fatal("unexpected sync at")
+	}
+
+	// verify the balance is capped by the max balance
+	a.pendingRecovery = true
+	a.managedRecoverBalance(hostBalance, hostBalance.Sub64(1))
+	if !a.balance.Equals(hostBalance.Sub64(1)) {
+		t.Fatal("balance should be capped", a.balance)
+	}
+}
+
 // testAccountTrackSpending is a small unit test that verifies the functionality
 // of the method 'trackSpending' on the account
 func testAccountTrackSpending(t *testing.T) {
@@ -442,6 +503,80 @@ func testAccountTracking(t *testing.T, rt *renterTester) {
 	}
 }
 
+// testAccountInfo verifies the renter's Account and Accounts methods return the
+// account details including any uncommitted deltas.
+func testAccountInfo(t *testing.T, rt *renterTester) {
+	r := rt.renter
+
+	// open some accounts with a random balance state
+	accounts, err := openRandomTestAccountsOnRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// verifyInfo is a helper that compares the info to the account
+	verifyInfo := func(acc *account, info modules.RenterAccount) {
+		t.Helper()
+		acc.mu.Lock()
+		defer acc.mu.Unlock()
+		if info.AccountID != acc.staticID.SPK().String() || !info.HostKey.Equals(acc.staticHostKey) {
+			t.Fatal("unexpected account identity")
+		}
+		if !info.AvailableBalance.Equals(acc.availableBalance()) ||
+			!info.Balance.Equals(acc.balance) ||
+			!info.NegativeBalance.Equals(acc.negativeBalance) ||
+			!info.PendingDeposits.Equals(acc.pendingDeposits) ||
+			!info.PendingWithdrawals.Equals(acc.pendingWithdrawals) {
+			t.Fatal("unexpected balance details", info)
+		}
+		if !info.Spending.Downloads.Equals(acc.spending.downloads) ||
+			!info.Spending.RegistryReads.Equals(acc.spending.registryReads) ||
+			!info.Spending.RegistryWrites.Equals(acc.spending.registryWrites) ||
+			!info.Spending.RepairDownloads.Equals(acc.spending.repairDownloads) ||
+			!info.Spending.RepairUploads.Equals(acc.spending.repairUploads) ||
+			!info.Spending.SnapshotDownloads.Equals(acc.spending.snapshotDownloads) ||
+			!info.Spending.SnapshotUploads.Equals(acc.spending.snapshotUploads) ||
+			!info.Spending.Subscriptions.Equals(acc.spending.subscriptions) ||
+			!info.Spending.Uploads.Equals(acc.spending.uploads) {
+			t.Fatal("unexpected spending details", info.Spending)
+		}
+	}
+
+	// verify the accounts are returned by Accounts
+	infos, err := r.Accounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoMap := make(map[string]modules.RenterAccount)
+	for _, info := range infos {
+		infoMap[info.HostKey.String()] = info
+	}
+	for _, acc := range accounts {
+		info, exists := infoMap[acc.staticHostKey.String()]
+		if !exists {
+			t.Fatal("account not returned by Accounts")
+		}
+		verifyInfo(acc, info)
+	}
+
+	// track a deposit on one of the accounts and verify Account reflects the
+	// uncommitted delta
+	acc := accounts[0]
+	acc.managedTrackDeposit(types.SiacoinPrecision)
+	info, err := r.Account(acc.staticHostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifyInfo(acc, info)
+
+	// verify an unknown host returns an error
+	hk, _ := newRandomHostKey()
+	_, err = r.Account(hk)
+	if !errors.Contains(err, errAccountNotFound) {
+		t.Fatalf("expected %v but got %v", errAccountNotFound, err)
+	}
+}
+
 // testAccountClosed verifies accounts can not be opened after the 'closed' flag
 // has been set to true by the save.
 func testAccountClosed(t *testing.T, closedRenter *Renter) {
@@ -526,6 +661,57 @@ func testWorkerAccountSyncAccountBalanceToHostCritical(t *testing.T, wt *workerT
 	w.externSyncAccountBalanceToHost()
 }
 
+// testWorkerAccountRecoverAccountBalance verifies that a worker recovers the
+// balance of an account that is pending recovery after the next successful
+// price table update, even if the worker is on cooldown.
+func testWorkerAccountRecoverAccountBalance(t *testing.T, wt *workerTester) {
+	w := wt.worker
+
+	// wait until the worker is done with its maintenance tasks - this basically
+	// ensures we have a working worker, with valid PT and funded EA
+	if err := build.Retry(100, 100*time.Millisecond, func() error {
+		if !w.managedMaintenanceSucceeded() {
+			return errors.New("worker not ready with maintenance")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// mark the account as pending recovery, simulating an earlier recovery
+	// attempt that failed because the host was unreachable
+	w.staticAccount.mu.Lock()
+	w.staticAccount.pendingRecovery = true
+	w.staticAccount.mu.Unlock()
+
+	// schedule a price table update and wake the worker
+	w.staticSchedulePriceTableUpdate(true)
+	w.staticWake()
+
+	// the worker should recover the account balance
+	err := build.Retry(100, 100*time.Millisecond, func() error {
+		if w.staticAccount.callNeedsToRecover() {
+			w.staticWake()
+			return errors.New("account balance not recovered")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := w.staticAccount.managedInfo()
+	if !info.RecoveredFromDirtyShutdown {
+		t.Fatal("account should be marked as recovered")
+	}
+	balance, err := w.staticHostAccountBalance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Balance.Cmp(balance) < 0 {
+		t.Fatal("unexpected balance after recovery", info.Balance, balance)
+	}
+}
+
 // testWorkerAccountSpendingDetails verifies that performing actions such as
 // downloading and reading, writing and subscribing to the registry properly
 // update the spending details in the worker account.
@@ -642,7 +828,7 @@ func testWorkerAccountSpendingDetails(t *testing.T, wt *workerTester) {
 		PubKey: spk,
 		Tweak:  rv.Tweak,
 	}
-	_, err = wt.Subscribe(context.Background(), req)
+	_, handle, err := wt.Subscribe(context.Background(), req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -656,7 +842,7 @@ func testWorkerAccountSpendingDetails(t *testing.T, wt *workerTester) {
 	}
 
 	// Unsubscribe
-	wt.Unsubscribe(req)
+	handle.Close()
 
 	// Stop the loop by shutting down the worker.
 	err = wt.staticTG.Stop()
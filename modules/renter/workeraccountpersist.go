@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"gitlab.com/NebulousLabs/encoding"
 	"gitlab.com/NebulousLabs/errors"
@@ -65,6 +66,11 @@ type (
 	accountManager struct {
 		accounts map[string]*account
 
+		// fileModTime is the modification time of the accounts file before it
+		// was opened. It is used as the first seen time of accounts that were
+		// persisted before that time was tracked.
+		fileModTime time.Time
+
 		// Utils. The file is global to all accounts, each account looks at a
 		// specific offset within the file.
 		mu           sync.Mutex
@@ -103,6 +109,18 @@ type (
 		SpendingSnapshotUploads   types.Currency
 		SpendingSubscriptions     types.Currency
 		SpendingUploads           types.Currency
+
+		// FirstSeen is the unix timestamp of the first time the account was
+		// persisted. It is stored as a timestamp since the encoding doesn't
+		// support time.Time. Accounts persisted before this field was added
+		// decode it as 0.
+		FirstSeen int64
+
+		// PendingRecovery and RecoveredFromDirtyShutdown persist the state of
+		// the balance recovery after an unclean shutdown. That way a recovery
+		// that failed before a clean shutdown is retried after a restart.
+		PendingRecovery            bool
+		RecoveredFromDirtyShutdown bool
 	}
 
 	// accountPersistenceV150 is how the account persistence struct looked
@@ -141,7 +159,21 @@ func (a *account) managedPersist() error {
 // persist will write the account to the given file at the account's offset,
 // without syncing the file.
 func (a *account) persist() error {
-	accountData := accountPersistence{
+	// set the first seen timestamp on the first write
+	if a.firstSeen.IsZero() {
+		a.firstSeen = time.Now()
+	}
+	_, err := a.staticFile.WriteAt(a.persistence().bytes(), a.staticOffset)
+	return errors.AddContext(err, "unable to write the account to disk")
+}
+
+// persistence returns the account's persistence object.
+func (a *account) persistence() accountPersistence {
+	var firstSeen int64
+	if !a.firstSeen.IsZero() {
+		firstSeen = a.firstSeen.Unix()
+	}
+	return accountPersistence{
 		AccountID: a.staticID,
 		HostKey:   a.staticHostKey,
 		SecretKey: a.staticSecretKey,
@@ -161,10 +193,26 @@ func (a *account) persist() error {
 		SpendingSnapshotUploads:   a.spending.snapshotUploads,
 		SpendingSubscriptions:     a.spending.subscriptions,
 		SpendingUploads:           a.spending.uploads,
+
+		FirstSeen: firstSeen,
+
+		PendingRecovery:            a.pendingRecovery,
+		RecoveredFromDirtyShutdown: a.recoveredFromDirtyShutdown,
 	}
+}
 
-	_, err := a.staticFile.WriteAt(accountData.bytes(), a.staticOffset)
-	return errors.AddContext(err, "unable to write the account to disk")
+// BalanceDriftStats returns the positive and negative balance drift of the
+// account per day since the account was first seen. Accounts that are younger
+// than a day return their total drift.
+func (ap accountPersistence) BalanceDriftStats(now time.Time) (positive, negative types.Currency) {
+	elapsed := uint64(now.Unix() - ap.FirstSeen)
+	day := uint64(24 * time.Hour / time.Second)
+	if ap.FirstSeen <= 0 || now.Unix() < ap.FirstSeen || elapsed < day {
+		elapsed = day
+	}
+	positive = ap.BalanceDriftPositive.Mul64(day).Div64(elapsed)
+	negative = ap.BalanceDriftNegative.Mul64(day).Div64(elapsed)
+	return
 }
 
 // bytes is a helper method on the persistence object that outputs the bytes to
@@ -261,7 +309,7 @@ func (am *accountManager) managedOpenAccount(hostKey types.SiaPublicKey) (acc *a
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to persist account")
 	}
-	err = acc.staticFile.Sync()
+	err = am.staticRenter.managedTimePersistWrite(acc.staticFile.Sync)
 	if err != nil {
 		return nil, errors.AddContext(err, "failed to sync accounts file")
 	}
@@ -300,7 +348,7 @@ func (am *accountManager) managedSaveAndClose() error {
 	// Sync the file before updating the header. We want to make sure that the
 	// accounts have been put into a clean and finalized state before writing an
 	// update to the metadata.
-	err := am.staticFile.Sync()
+	err := am.staticRenter.managedTimePersistWrite(am.staticFile.Sync)
 	if err != nil {
 		return errors.AddContext(err, "failed to sync accounts file")
 	}
@@ -401,9 +449,11 @@ func (am *accountManager) load() error {
 			continue
 		}
 
-		// reset the account balances after an unclean shutdown
+		// reset the account balances after an unclean shutdown, the workers
+		// will try to recover the balance from the host
 		if !clean {
 			acc.balance = types.ZeroCurrency
+			acc.pendingRecovery = true
 		}
 		am.accounts[acc.staticHostKey.String()] = acc
 	}
@@ -442,6 +492,13 @@ func (am *accountManager) openFile() (bool, error) {
 	}
 	am.staticFile = accountsFile
 
+	// Remember the modification time before the file is written to.
+	fi, err := am.staticFile.Stat()
+	if err != nil {
+		return false, errors.AddContext(err, "error reading account file info")
+	}
+	am.fileModTime = fi.ModTime()
+
 	// Read accounts metadata
 	metadata, err := readAccountsMetadata(am.staticFile)
 	if err != nil {
@@ -486,7 +543,7 @@ func (am *accountManager) openFile() (bool, error) {
 
 	// Sync the metadata to ensure the acounts will load as dirty before any
 	// accounts are created.
-	err = am.staticFile.Sync()
+	err = am.staticRenter.managedTimePersistWrite(am.staticFile.Sync)
 	if err != nil {
 		return false, errors.AddContext(err, "failed to sync accounts file")
 	}
@@ -520,7 +577,7 @@ func (am *accountManager) openAccountsFile(filename string) (modules.File, error
 			Version: metadataVersion,
 			Clean:   false,
 		}), 0)
-		err = errors.Compose(err, accountsFile.Sync())
+		err = errors.Compose(err, r.managedTimePersistWrite(accountsFile.Sync))
 		if err != nil {
 			return accountsFile, errors.AddContext(err, "error writing metadata to accounts file")
 		}
@@ -546,11 +603,23 @@ func (am *accountManager) readAccountAt(offset int64) (*account, error) {
 		return nil, errors.AddContext(err, "failed to load account bytes")
 	}
 
+	// accounts persisted before the first seen timestamp was added default to
+	// the modification time of the accounts file
+	firstSeen := am.fileModTime
+	if accountData.FirstSeen > 0 {
+		firstSeen = time.Unix(accountData.FirstSeen, 0)
+	}
+
 	acc := &account{
 		staticID:        accountData.AccountID,
 		staticHostKey:   accountData.HostKey,
 		staticSecretKey: accountData.SecretKey,
 
+		firstSeen: firstSeen,
+
+		pendingRecovery:            accountData.PendingRecovery,
+		recoveredFromDirtyShutdown: accountData.RecoveredFromDirtyShutdown,
+
 		// balance details
 		balance:              accountData.Balance,
 		balanceDriftPositive: accountData.BalanceDriftPositive,
@@ -595,7 +664,7 @@ func (am *accountManager) upgradeFromV150ToV156() error {
 	// read the accounts from the accounts file, but link them to the tmp file,
 	// when calling persist on the account it will write the account into the
 	// tmp file
-	accounts := compatV150ReadAccounts(r.log, am.staticFile, tmpFile)
+	accounts := compatV150ReadAccounts(r.log, am.staticFile, tmpFile, am.fileModTime)
 	for _, acc := range accounts {
 		if err := acc.managedPersist(); err != nil {
 			r.log.Println("failed to upgrade account from v150 to v156", err)
@@ -648,7 +717,7 @@ func (am *accountManager) upgradeFromV150ToV156_CopyAccountsFromFile(tmpFile mod
 	}
 
 	// sync the accounts file
-	err = am.staticFile.Sync()
+	err = r.managedTimePersistWrite(am.staticFile.Sync)
 	if err != nil {
 		return errors.AddContext(err, "failed to sync accounts file")
 	}
@@ -672,7 +741,7 @@ func (am *accountManager) updateMetadata(meta accountsMetadata) error {
 // compatV150ReadAccounts is a helper function that reads the accounts from the
 // accounts file assuming they are persisted using the v150 persistence object
 // and parameters. Extracted to keep the compat code clean.
-func compatV150ReadAccounts(log *persist.Logger, accountsFile modules.File, tmpFile modules.File) []*account {
+func compatV150ReadAccounts(log *persist.Logger, accountsFile modules.File, tmpFile modules.File, firstSeen time.Time) []*account {
 	// the offset needs to be the new accountsOffset
 	newOffset := int64(accountsOffset)
 
@@ -702,7 +771,8 @@ func compatV150ReadAccounts(log *persist.Logger, accountsFile modules.File, tmpF
 			staticHostKey:   accountDataV150.HostKey,
 			staticSecretKey: accountDataV150.SecretKey,
 
-			balance: accountDataV150.Balance,
+			balance:   accountDataV150.Balance,
+			firstSeen: firstSeen,
 
 			staticOffset: newOffset,
 			staticFile:   tmpFile,
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/encoding"
 	"gitlab.com/NebulousLabs/errors"
@@ -46,6 +47,11 @@ func newRandomAccountPersistence() accountPersistence {
 		SpendingSnapshotUploads:   randomBalance(1e2),
 		SpendingSubscriptions:     randomBalance(1e2),
 		SpendingUploads:           randomBalance(1e2),
+
+		FirstSeen: time.Now().Add(-time.Duration(fastrand.Intn(1e6)) * time.Second).Unix(),
+
+		PendingRecovery:            fastrand.Intn(2) == 0,
+		RecoveredFromDirtyShutdown: fastrand.Intn(2) == 0,
 	}
 }
 
@@ -101,11 +107,15 @@ func TestAccountSave(t *testing.T) {
 		if !account.staticID.SPK().Equals(reloaded.staticID.SPK()) {
 			t.Error("Unexpected account ID")
 		}
+		if account.firstSeen.Unix() != reloaded.firstSeen.Unix() {
+			t.Error("Unexpected first seen time", account.firstSeen, reloaded.firstSeen)
+		}
 	}
 }
 
-// TestAccountUncleanShutdown verifies that accounts are dropped if the accounts
-// persist file was not marked as 'clean' on shutdown.
+// TestAccountUncleanShutdown verifies that account balances are reset and
+// marked for recovery if the accounts persist file was not marked as 'clean' on
+// shutdown.
 func TestAccountUncleanShutdown(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -163,7 +173,7 @@ func TestAccountUncleanShutdown(t *testing.T) {
 	}
 
 	// verify the accounts were reloaded but the balances were cleared due to
-	// the unclean shutdown
+	// the unclean shutdown, and are pending recovery from the host
 	for _, account := range accounts {
 		reloaded, err := r.staticAccountManager.managedOpenAccount(account.staticHostKey)
 		if err != nil {
@@ -175,6 +185,98 @@ func TestAccountUncleanShutdown(t *testing.T) {
 		if !reloaded.balance.IsZero() {
 			t.Fatal("Unexpected reloaded account balance")
 		}
+		if !reloaded.callNeedsToRecover() {
+			t.Fatal("Expected reloaded account to be pending recovery")
+		}
+	}
+}
+
+// TestAccountRecoveryAfterCleanRestart verifies that an account which couldn't
+// recover its balance after an unclean shutdown, because the host was
+// unreachable, is still pending recovery after a clean restart and that the
+// outcome of the recovery is persisted.
+func TestAccountRecoveryAfterCleanRestart(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a renter tester
+	rt, err := newRenterTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := rt.Close()
+		if err != nil {
+			t.Log(err)
+		}
+	}()
+	r := rt.renter
+
+	// create a number accounts, there are no workers for their hosts which
+	// means the hosts are unreachable
+	accounts, err := openRandomTestAccountsOnRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reload the renter with a dependency that interrupts the accounts save
+	// on shutdown and reload it once more without it to trigger the unclean
+	// shutdown
+	deps := &dependencies.DependencyInterruptAccountSaveOnShutdown{}
+	r, err = rt.reloadRenterWithDependency(r, deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err = rt.reloadRenterWithDependency(r, modules.ProdDependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// reload the renter cleanly, the accounts should still be pending
+	// recovery
+	r, err = rt.reloadRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostBalance := types.NewCurrency64(fastrand.Uint64n(1e3) + 1)
+	for _, account := range accounts {
+		reloaded, err := r.staticAccountManager.managedOpenAccount(account.staticHostKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reloaded.balance.IsZero() {
+			t.Fatal("Unexpected reloaded account balance", reloaded.balance)
+		}
+		if !reloaded.callNeedsToRecover() {
+			t.Fatal("Expected reloaded account to be pending recovery")
+		}
+
+		// recover the balance using a mocked balance response of the host
+		reloaded.managedRecoverBalance(hostBalance, hostBalance.Mul64(2))
+	}
+
+	// reload the renter and verify the recovery was persisted
+	r, err = rt.reloadRenter(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range accounts {
+		reloaded, err := r.staticAccountManager.managedOpenAccount(account.staticHostKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reloaded.callNeedsToRecover() {
+			t.Fatal("Expected reloaded account to be recovered")
+		}
+		info := reloaded.managedInfo()
+		if !info.RecoveredFromDirtyShutdown {
+			t.Fatal("Expected reloaded account to be marked as recovered")
+		}
+		if !info.Balance.Equals(hostBalance) {
+			t.Fatal("Unexpected reloaded account balance", info.Balance, hostBalance)
+		}
 	}
 }
 
@@ -306,6 +408,10 @@ func testAccountCompatV150Basic(t *testing.T, rt *renterTester) {
 	if err != nil {
 		t.Fatal(err)
 	}
+	fi, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	// create a renter
 	r, err := newRenterWithDependency(rt.gateway, rt.cs, rt.wallet, rt.tpool, rt.mux, filepath.Join(testdir, modules.RenterDir), &modules.ProductionDependencies{})
@@ -323,10 +429,37 @@ func testAccountCompatV150Basic(t *testing.T, rt *renterTester) {
 	am := r.staticAccountManager
 	am.mu.Lock()
 	numAccounts := len(am.accounts)
+	accounts := make([]*account, 0, numAccounts)
+	for _, acc := range am.accounts {
+		accounts = append(accounts, acc)
+	}
 	am.mu.Unlock()
 	if numAccounts != 377 {
 		t.Fatal("unexpected amount of accounts", numAccounts)
 	}
+
+	// verify the spending fields that didn't exist in v150 were initialized
+	// to zero
+	for _, acc := range accounts {
+		acc.mu.Lock()
+		uploads := acc.spending.uploads
+		repairUploads := acc.spending.repairUploads
+		acc.mu.Unlock()
+		if !uploads.IsZero() || !repairUploads.IsZero() {
+			t.Fatal("expected upload spending to be zero", uploads, repairUploads)
+		}
+	}
+
+	// verify the first seen time defaults to the modification time of the
+	// accounts file
+	for _, acc := range accounts {
+		acc.mu.Lock()
+		firstSeen := acc.firstSeen
+		acc.mu.Unlock()
+		if firstSeen.Unix() != fi.ModTime().Unix() {
+			t.Fatal("unexpected first seen time", firstSeen, fi.ModTime())
+		}
+	}
 }
 
 // testAccountCompatV150_TmpFileExistsWithClean verifies the disaster recovery
@@ -467,6 +600,9 @@ func TestAccountPersistenceToAndFromBytes(t *testing.T) {
 		!ap.SpendingUploads.Equals(uMar.SpendingUploads) {
 		t.Fatal("Unexpected spending details")
 	}
+	if ap.FirstSeen != uMar.FirstSeen {
+		t.Fatal("Unexpected first seen time")
+	}
 
 	// corrupt the checksum of the account bytes
 	corruptedBytes := accountBytes
@@ -485,6 +621,46 @@ func TestAccountPersistenceToAndFromBytes(t *testing.T) {
 	}
 }
 
+// TestAccountPersistenceBalanceDriftStats is a unit test for the
+// BalanceDriftStats method on the accountPersistence object.
+func TestAccountPersistenceBalanceDriftStats(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	day := 24 * time.Hour
+	ap := accountPersistence{
+		BalanceDriftPositive: types.NewCurrency64(100),
+		BalanceDriftNegative: types.NewCurrency64(40),
+	}
+
+	tests := []struct {
+		firstSeen time.Time
+		positive  uint64
+		negative  uint64
+	}{
+		// unknown first seen time
+		{time.Time{}, 100, 40},
+		// less than a day
+		{now.Add(-time.Hour), 100, 40},
+		// exactly one day
+		{now.Add(-day), 100, 40},
+		// multiple days
+		{now.Add(-4 * day), 25, 10},
+		// first seen in the future
+		{now.Add(day), 100, 40},
+	}
+	for i, test := range tests {
+		ap.FirstSeen = 0
+		if !test.firstSeen.IsZero() {
+			ap.FirstSeen = test.firstSeen.Unix()
+		}
+		positive, negative := ap.BalanceDriftStats(now)
+		if !positive.Equals64(test.positive) || !negative.Equals64(test.negative) {
+			t.Errorf("%v: unexpected drift stats %v %v, expected %v %v", i, positive, negative, test.positive, test.negative)
+		}
+	}
+}
+
 // corruptAccountsFile will write random data to an accounts file at given path
 //
 // NOTE: this function assumes the accounts file holds at least 2 accounts
@@ -0,0 +1,126 @@
+package renter
+
+import (
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// refillPredictionResetFactor is the factor by which the spending rate
+	// measured over a window needs to differ from the predicted spending rate
+	// for the prediction to be reset instead of being averaged with the
+	// measured rate.
+	refillPredictionResetFactor = 4
+)
+
+var (
+	// refillPredictionWindow is the length of the window over which the
+	// spending of an account is measured to update the prediction of its
+	// future spending.
+	refillPredictionWindow = build.Select(build.Var{
+		Dev:      time.Minute,
+		Standard: 5 * time.Minute,
+		Testing:  5 * time.Second,
+	}).(time.Duration)
+
+	// refillPredictionHorizon is the period of time an account's refill target
+	// should cover given its predicted spending. A longer horizon results in
+	// fewer but larger refills.
+	refillPredictionHorizon = build.Select(build.Var{
+		Dev:      5 * time.Minute,
+		Standard: 30 * time.Minute,
+		Testing:  10 * time.Second,
+	}).(time.Duration)
+)
+
+// spendingPrediction predicts the spending of an account to size its refills.
+// The spending is measured over windows of refillPredictionWindow and the
+// measured rate of every window is averaged with the prediction. If the rate
+// changes drastically, the prediction is reset to the rate of the most recent
+// window instead.
+type spendingPrediction struct {
+	// windowStart is the start of the current window and windowSpent the
+	// amount spent since then.
+	windowStart time.Time
+	windowSpent types.Currency
+
+	// rate is the predicted spending in hastings per second.
+	rate types.Currency
+
+	// target is the most recently computed refill target and refillSize the
+	// amount of the most recent successful refill. They are kept for
+	// debugging purposes.
+	target     types.Currency
+	refillSize types.Currency
+}
+
+// track adds a withdrawal of the given amount at the given time to the current
+// window.
+func (sp *spendingPrediction) track(now time.Time, amount types.Currency) {
+	sp.update(now)
+	sp.windowSpent = sp.windowSpent.Add(amount)
+}
+
+// update closes the current window if it lasted for at least
+// refillPredictionWindow and updates the predicted rate with the spending of
+// the window. A window which is closed late, e.g. because the account was
+// idle, results in a lower rate which usually resets the prediction.
+func (sp *spendingPrediction) update(now time.Time) {
+	if sp.windowStart.IsZero() {
+		sp.windowStart = now
+		return
+	}
+	elapsed := now.Sub(sp.windowStart)
+	if elapsed < refillPredictionWindow {
+		return
+	}
+	rate := sp.windowSpent.Div64(uint64(elapsed / time.Second))
+	sp.windowStart = now
+	sp.windowSpent = types.ZeroCurrency
+
+	// Reset the prediction if the rate changed drastically, otherwise average
+	// it with the measured rate.
+	if sp.rate.IsZero() || rate.Cmp(sp.rate.Mul64(refillPredictionResetFactor)) > 0 || rate.Mul64(refillPredictionResetFactor).Cmp(sp.rate) < 0 {
+		sp.rate = rate
+		return
+	}
+	sp.rate = sp.rate.Add(rate).Div64(2)
+}
+
+// refillTarget returns the balance an account should be refilled to in order
+// to cover the predicted spending rate for the given horizon. The target is
+// never below minTarget and the predicted spending is capped by maxBalance, the
+// host's maximum account balance. If minTarget is zero, funding is disabled
+// and the target is zero as well.
+func refillTarget(rate types.Currency, horizon time.Duration, minTarget, maxBalance types.Currency) types.Currency {
+	if minTarget.IsZero() {
+		return types.ZeroCurrency
+	}
+	predicted := rate.Mul64(uint64(horizon / time.Second))
+	if !maxBalance.IsZero() && predicted.Cmp(maxBalance) > 0 {
+		predicted = maxBalance
+	}
+	if predicted.Cmp(minTarget) < 0 {
+		return minTarget
+	}
+	return predicted
+}
+
+// managedRefillTarget updates the spending prediction of the account and
+// returns the balance it should be refilled to.
+func (a *account) managedRefillTarget(minTarget, maxBalance types.Currency) types.Currency {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.prediction.update(time.Now())
+	a.prediction.target = refillTarget(a.prediction.rate, refillPredictionHorizon, minTarget, maxBalance)
+	return a.prediction.target
+}
+
+// managedRefillTarget returns the balance the worker's account should be
+// refilled to. The worker's balance target is used as the minimum.
+func (w *worker) managedRefillTarget() types.Currency {
+	maxBalance := w.staticCache().staticHostSettings.MaxEphemeralAccountBalance
+	return w.staticAccount.managedRefillTarget(w.staticBalanceTarget, maxBalance)
+}
@@ -0,0 +1,94 @@
+package renter
+
+import (
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/types"
+)
+
+// TestRefillTarget is a unit test for refillTarget.
+func TestRefillTarget(t *testing.T) {
+	t.Parallel()
+	sc := types.SiacoinPrecision
+	horizon := 100 * time.Second
+	tests := []struct {
+		name       string
+		rate       types.Currency
+		minTarget  types.Currency
+		maxBalance types.Currency
+		target     types.Currency
+	}{
+		{"no spending", types.ZeroCurrency, sc, sc.Mul64(10), sc},
+		{"low spending", sc.Div64(1000), sc, sc.Mul64(10), sc},
+		{"high spending", sc.Div64(20), sc, sc.Mul64(10), sc.Mul64(5)},
+		{"capped spending", sc, sc, sc.Mul64(10), sc.Mul64(10)},
+		{"no max balance", sc, sc, types.ZeroCurrency, sc.Mul64(100)},
+		{"max below min", sc, sc.Mul64(2), sc, sc.Mul64(2)},
+		{"funding disabled", sc, types.ZeroCurrency, sc.Mul64(10), types.ZeroCurrency},
+	}
+	for _, test := range tests {
+		target := refillTarget(test.rate, horizon, test.minTarget, test.maxBalance)
+		if !target.Equals(test.target) {
+			t.Errorf("%v: expected target %v but got %v", test.name, test.target, target)
+		}
+	}
+}
+
+// TestSpendingPrediction checks that the spending prediction follows several
+// spending patterns.
+func TestSpendingPrediction(t *testing.T) {
+	t.Parallel()
+	window := refillPredictionWindow
+	seconds := uint64(window / time.Second)
+
+	// spend spends the given amount per second for the given number of
+	// windows, one withdrawal per second, and closes the last window.
+	var sp spendingPrediction
+	now := time.Now()
+	spend := func(perSecond types.Currency, windows int) {
+		for i := uint64(0); i < uint64(windows)*seconds; i++ {
+			sp.track(now, perSecond)
+			now = now.Add(time.Second)
+		}
+		sp.update(now)
+	}
+	assertRate := func(rate types.Currency) {
+		t.Helper()
+		if !sp.rate.Equals(rate) {
+			t.Fatalf("expected rate %v but got %v", rate, sp.rate)
+		}
+	}
+
+	// Steady spending. After the first window the rate matches the spending.
+	rate := types.NewCurrency64(1000)
+	spend(rate, 1)
+	assertRate(rate)
+	spend(rate, 3)
+	assertRate(rate)
+
+	// A moderate increase is averaged with the prediction.
+	spend(rate.Mul64(2), 1)
+	assertRate(rate.Add(rate.Mul64(2)).Div64(2))
+
+	// A drastic increase resets the prediction.
+	burst := rate.Mul64(100)
+	spend(burst, 2)
+	assertRate(burst)
+
+	// A drastic decrease resets the prediction as well.
+	spend(rate, 2)
+	assertRate(rate)
+
+	// A window that is closed late because the account was idle resets the
+	// prediction.
+	sp.track(now, rate)
+	now = now.Add(100 * window)
+	sp.update(now)
+	assertRate(rate.Div64(100 * seconds))
+
+	// Windows without any spending reset the prediction to zero.
+	now = now.Add(window)
+	sp.update(now)
+	assertRate(types.ZeroCurrency)
+}
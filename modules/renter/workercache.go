@@ -25,13 +25,23 @@ type (
 	// must be static because this object is saved and loaded using
 	// atomic.Pointer.
 	workerCache struct {
-		staticBlockHeight     types.BlockHeight
-		staticContractID      types.FileContractID
-		staticContractUtility modules.ContractUtility
-		staticHostVersion     string
-		staticRenterAllowance modules.Allowance
-		staticHostMuxAddress  string
-		staticSynced          bool
+		staticBlockHeight      types.BlockHeight
+		staticContractID       types.FileContractID
+		staticContractUtility  modules.ContractUtility
+		staticDownloadPrice    types.Currency
+		staticHostSettings     modules.HostExternalSettings
+		staticHostVersion      string
+		staticPriceTableExpiry time.Time
+		staticRenterAllowance  modules.Allowance
+		staticHostMuxAddress   string
+		staticSynced           bool
+		staticUploadPrice      types.Currency
+
+		// staticGoodForSubscription indicates whether the worker should run
+		// the subscription loop. It requires a contract that is good for
+		// renew and doesn't expire soon as well as a host that supports
+		// subscriptions.
+		staticGoodForSubscription bool
 
 		staticLastUpdate time.Time
 	}
@@ -69,15 +79,41 @@ func (w *worker) managedUpdateCache() {
 		return
 	}
 
+	// Grab the expiry of the current price table. The bandwidth prices are
+	// taken from the price table as well if it is valid. Otherwise we fall
+	// back to the prices from the host's settings.
+	var ptExpiry time.Time
+	downloadPrice := host.DownloadBandwidthPrice
+	uploadPrice := host.UploadBandwidthPrice
+	if pt := w.staticPriceTable(); pt != nil {
+		ptExpiry = pt.staticExpiryTime
+		if pt.staticValid() {
+			downloadPrice = pt.staticPriceTable.DownloadBandwidthCost
+			uploadPrice = pt.staticPriceTable.UploadBandwidthCost
+		}
+	}
+
+	// Check whether the worker should maintain subscriptions.
+	blockHeight := w.renter.cs.Height()
+	goodForSubscription := renterContract.Utility.GoodForRenew &&
+		blockHeight+subscriptionContractExpiryBuffer < renterContract.EndHeight &&
+		build.VersionCmp(host.Version, minSubscriptionVersion) >= 0
+
 	// Create the cache object.
 	newCache := &workerCache{
-		staticBlockHeight:     w.renter.cs.Height(),
-		staticContractID:      renterContract.ID,
-		staticContractUtility: renterContract.Utility,
-		staticHostMuxAddress:  host.SiaMuxAddress(),
-		staticHostVersion:     host.Version,
-		staticRenterAllowance: w.renter.hostContractor.Allowance(),
-		staticSynced:          w.renter.cs.Synced(),
+		staticBlockHeight:      blockHeight,
+		staticContractID:       renterContract.ID,
+		staticContractUtility:  renterContract.Utility,
+		staticDownloadPrice:    downloadPrice,
+		staticHostMuxAddress:   host.SiaMuxAddress(),
+		staticHostSettings:     host.HostExternalSettings,
+		staticHostVersion:      host.Version,
+		staticPriceTableExpiry: ptExpiry,
+		staticRenterAllowance:  w.renter.hostContractor.Allowance(),
+		staticSynced:           w.renter.cs.Synced(),
+		staticUploadPrice:      uploadPrice,
+
+		staticGoodForSubscription: goodForSubscription,
 
 		staticLastUpdate: time.Now(),
 	}
@@ -95,6 +131,15 @@ func (w *worker) managedUpdateCache() {
 	w.renter.tg.AfterFunc(workerCacheUpdateFrequency, func() {
 		w.staticWake()
 	})
+
+	// Restart the subscription loop if it exited because the worker wasn't
+	// good for subscriptions.
+	if goodForSubscription && atomic.LoadUint64(&w.atomicSubscriptionLoopRunning) == 0 {
+		err := w.renter.tg.Launch(w.threadedSubscriptionLoop)
+		if err != nil {
+			w.renter.log.Debugln("managedUpdateCache: failed to launch subscription loop", err)
+		}
+	}
 }
 
 // newCache will initialize an unitialized cache on the worker.
@@ -126,6 +171,12 @@ func (w *worker) staticTryUpdateCache() {
 	}
 }
 
+// staticUploadTooExpensive returns whether the host's cached upload price
+// exceeds the max upload bandwidth price of the renter's allowance.
+func (wc *workerCache) staticUploadTooExpensive() bool {
+	return !withinMaxPrice(wc.staticUploadPrice, wc.staticRenterAllowance.MaxUploadBandwidthPrice)
+}
+
 // staticCache returns the current worker cache object.
 func (w *worker) staticCache() *workerCache {
 	ptr := atomic.LoadPointer(&w.atomicCache)
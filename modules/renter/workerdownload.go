@@ -137,6 +137,11 @@ func (w *worker) threadedPerformDownloadChunkJob(udc *unfinishedDownloadChunk) {
 	if err != nil {
 		w.renter.log.Debugln("worker failed to download sector:", err)
 		udc.managedUnregisterWorker(w)
+		// If the host returned bad data, the chunk needs to be recovered
+		// from the other hosts.
+		if errors.Contains(err, errProofVerificationFailed) {
+			go w.renter.threadedBuildAndPushRecoveryChunk(udc.renterFile.SiaPath(), udc.staticChunkIndex, w.staticHostPubKey.String())
+		}
 		return
 	}
 
@@ -137,8 +137,20 @@ func (j *jobHasSector) managedHasSector() ([]bool, error) {
 	// Create the program.
 	pt := w.staticPriceTable().staticPriceTable
 	pb := modules.NewProgramBuilder(&pt, 0) // 0 duration since HasSector doesn't depend on it.
-	for _, sector := range j.staticSectors {
-		pb.AddHasSectorInstruction(sector)
+	batch := build.VersionCmp(w.staticCache().staticHostVersion, minHasSectorsVersion) >= 0
+	if batch {
+		// Hosts which support it can look up all the sectors at once.
+		for start := 0; start < len(j.staticSectors); start += modules.MaxHasSectorsBatchSize {
+			end := start + modules.MaxHasSectorsBatchSize
+			if end > len(j.staticSectors) {
+				end = len(j.staticSectors)
+			}
+			pb.AddHasSectorsInstruction(j.staticSectors[start:end])
+		}
+	} else {
+		for _, sector := range j.staticSectors {
+			pb.AddHasSectorInstruction(sector)
+		}
 	}
 	program, programData := pb.Program()
 	cost, _, _ := pb.Cost(true)
@@ -149,7 +161,7 @@ func (j *jobHasSector) managedHasSector() ([]bool, error) {
 	cost = cost.Add(bandwidthCost)
 
 	// Execute the program and parse the responses.
-	hasSectors := make([]bool, 0, len(program))
+	hasSectors := make([]bool, 0, len(j.staticSectors))
 	var responses []programResponse
 	responses, _, err := w.managedExecuteProgram(program, programData, types.FileContractID{}, categoryDownload, cost)
 	if err != nil {
@@ -159,9 +171,15 @@ func (j *jobHasSector) managedHasSector() ([]bool, error) {
 		if resp.Error != nil {
 			return nil, errors.AddContext(resp.Error, "Output error")
 		}
-		hasSectors = append(hasSectors, resp.Output[0] == 1)
+		// A HasSectors instruction returns one byte per sector.
+		if !batch && len(resp.Output) != 1 {
+			return nil, errors.New("received invalid output length for has sector instruction")
+		}
+		for _, b := range resp.Output {
+			hasSectors = append(hasSectors, b == 1)
+		}
 	}
-	if len(responses) != len(program) {
+	if len(responses) != len(program) || len(hasSectors) != len(j.staticSectors) {
 		return nil, errors.New("received invalid number of responses but no error")
 	}
 	return hasSectors, nil
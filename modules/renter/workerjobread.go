@@ -135,6 +135,8 @@ func (j *jobRead) managedFinishExecute(readData []byte, readErr error, readJobTi
 		return
 	}
 	j.staticQueue.callReportSuccess()
+	w.staticAddBytesDownloaded(uint64(len(readData)))
+	w.managedAddDownloadSpeedSample(uint64(len(readData)), readJobTime)
 
 	// Job succeeded.
 	//
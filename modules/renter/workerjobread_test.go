@@ -132,3 +132,80 @@ func TestJobReadMetadata(t *testing.T) {
 		t.Fatal("unexpected")
 	}
 }
+
+// TestReadSectorRepairRateLimit verifies that repair reads are limited by the
+// renter's repair ratelimit while user downloads aren't.
+func TestReadSectorRepairRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// allow the worker some time to fetch a PT and fund its EA
+	err = build.Retry(600, 100*time.Millisecond, func() error {
+		if w.staticAccount.managedMinExpectedBalance().IsZero() {
+			return errors.New("account not funded yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// add sector data to the host
+	sectorData := fastrand.Bytes(int(modules.SectorSize))
+	sectorRoot := crypto.MerkleRoot(sectorData)
+	err = wt.host.AddSector(sectorRoot, sectorData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// read reads the sector a few times using the given category and returns
+	// the time it took.
+	read := func(category spendingCategory) time.Duration {
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			_, err := w.ReadSector(context.Background(), category, sectorRoot, 0, modules.SectorSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		return time.Since(start)
+	}
+
+	// Limit the repair download bandwidth to one sector per second.
+	err = setRateLimits(w.renter.staticRepairRL, int64(modules.SectorSize), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// User downloads are not affected.
+	if d := read(categoryDownload); d > time.Second {
+		t.Fatal("user downloads shouldn't be limited", d)
+	}
+
+	// Repair reads are limited.
+	if d := read(categoryRepairDownload); d < time.Second {
+		t.Fatal("repair reads should be limited", d)
+	}
+
+	// Remove the limit again.
+	err = setRateLimits(w.renter.staticRepairRL, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := read(categoryRepairDownload); d > time.Second {
+		t.Fatal("repair reads shouldn't be limited anymore", d)
+	}
+}
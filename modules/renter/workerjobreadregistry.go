@@ -44,6 +44,10 @@ type (
 		// worker's recent performance for jobReadRegistryQueue.
 		weightedJobTime float64
 
+		// staleResponses is the number of lookups for which the host returned
+		// a lower revision than another host or no entry at all.
+		staleResponses uint64
+
 		*jobGenericQueue
 	}
 
@@ -52,6 +56,7 @@ type (
 		staticSignedRegistryValue *modules.SignedRegistryValue
 		staticErr                 error
 		staticCompleteTime        time.Time
+		staticWorker              *worker
 	}
 )
 
@@ -162,6 +167,7 @@ func (j *jobReadRegistry) callDiscard(err error) {
 		response := &jobReadRegistryResponse{
 			staticErr:          errors.Extend(err, ErrJobDiscarded),
 			staticCompleteTime: time.Now(),
+			staticWorker:       w,
 		}
 		select {
 		case j.staticResponseChan <- response:
@@ -186,6 +192,7 @@ func (j *jobReadRegistry) callExecute() {
 				staticCompleteTime:        time.Now(),
 				staticSignedRegistryValue: srv,
 				staticErr:                 err,
+				staticWorker:              w,
 			}
 			select {
 			case j.staticResponseChan <- response:
@@ -243,6 +250,21 @@ func (j *jobReadRegistry) callExpectedBandwidth() (ul, dl uint64) {
 	return readRegistryJobExpectedBandwidth()
 }
 
+// callReportStale reports that the host returned a stale registry value.
+func (jq *jobReadRegistryQueue) callReportStale() {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	jq.staleResponses++
+}
+
+// callStaleResponses returns the number of stale registry values the host
+// returned.
+func (jq *jobReadRegistryQueue) callStaleResponses() uint64 {
+	jq.mu.Lock()
+	defer jq.mu.Unlock()
+	return jq.staleResponses
+}
+
 // initJobReadRegistryQueue will init the queue for the ReadRegistry jobs.
 func (w *worker) initJobReadRegistryQueue() {
 	// Sanity check that there is no existing job queue.
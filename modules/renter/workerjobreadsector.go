@@ -9,6 +9,10 @@ import (
 	"go.sia.tech/siad/modules"
 )
 
+// errProofVerificationFailed is returned by jobReadSector if the data returned
+// by the host doesn't match the requested sector.
+var errProofVerificationFailed = errors.New("proof verification failed")
+
 type (
 	// jobReadSector contains information about a readSector query.
 	jobReadSector struct {
@@ -56,7 +60,7 @@ func (j *jobReadSector) managedReadSector() ([]byte, error) {
 	proofStart := int(j.staticOffset) / crypto.SegmentSize
 	proofEnd := int(j.staticOffset+j.staticLength) / crypto.SegmentSize
 	if !crypto.VerifyRangeProof(data, proof, proofStart, proofEnd, j.staticSector) {
-		return nil, errors.New("proof verification failed")
+		return nil, errProofVerificationFailed
 	}
 	return data, nil
 }
@@ -2,7 +2,9 @@ package renter
 
 import (
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 
 	"gitlab.com/NebulousLabs/errors"
 
@@ -24,7 +26,26 @@ import (
 // cached in the worker pool, which will both improve performance and reduce the
 // calling complexity of the functions that currently need to pass this
 // information around.
+//
+// Not every worker in the pool necessarily receives upload work. The pool keeps
+// a soft cap on the number of active workers which scales with the depth of the
+// upload heap. Workers beyond the cap are kept in a warm standby, they stay
+// connected to their host and keep their cache and price table up to date, but
+// they are not handed any new upload chunks until the cap is raised again.
 type workerPool struct {
+	// maxActiveWorkers is the soft cap on the number of workers that receive
+	// upload work. It defaults to the number of workers in the pool.
+	//
+	// unusableWorkers contains the workers that couldn't upload the last time
+	// the cap was updated. They are the first to be put in standby.
+	maxActiveWorkers int
+	unusableWorkers  map[string]struct{}
+
+	// disabledWorkers contains the hosts for which the workers were disabled
+	// by the user. No workers are created for these hosts until they are
+	// enabled again.
+	disabledWorkers map[string]types.SiaPublicKey
+
 	workers map[string]*worker // The string is the host's public key.
 	mu      sync.RWMutex
 	renter  *Renter
@@ -58,6 +79,7 @@ func (wp *workerPool) callStatus() modules.WorkerPoolStatus {
 		statuss = append(statuss, status)
 	}
 	return modules.WorkerPoolStatus{
+		DisabledWorkers:          wp.callDisabledWorkers(),
 		NumWorkers:               len(wp.workers),
 		TotalDownloadCoolDown:    totalDownloadCoolDown,
 		TotalMaintenanceCoolDown: totalMaintenanceCoolDown,
@@ -66,6 +88,41 @@ func (wp *workerPool) callStatus() modules.WorkerPoolStatus {
 	}
 }
 
+// callDisabledWorkers returns the public keys of the hosts for which the
+// workers are disabled, sorted by their string representation.
+func (wp *workerPool) callDisabledWorkers() []types.SiaPublicKey {
+	wp.mu.RLock()
+	disabled := make([]types.SiaPublicKey, 0, len(wp.disabledWorkers))
+	for _, hostKey := range wp.disabledWorkers {
+		disabled = append(disabled, hostKey)
+	}
+	wp.mu.RUnlock()
+	sort.Slice(disabled, func(i, j int) bool {
+		return disabled[i].String() < disabled[j].String()
+	})
+	return disabled
+}
+
+// callIsDisabled returns true if the worker for the given host is disabled.
+func (wp *workerPool) callIsDisabled(hostKey string) bool {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	_, disabled := wp.disabledWorkers[hostKey]
+	return disabled
+}
+
+// callSetDisabled disables or enables the worker for the given host. The
+// worker pool needs to be updated for the change to take effect.
+func (wp *workerPool) callSetDisabled(hostKey types.SiaPublicKey, disabled bool) {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	if disabled {
+		wp.disabledWorkers[hostKey.String()] = hostKey
+	} else {
+		delete(wp.disabledWorkers, hostKey.String())
+	}
+}
+
 // callUpdate will grab the set of contracts from the contractor and update the
 // worker pool to match, creating new workers and killing existing workers as
 // necessary.
@@ -84,12 +141,21 @@ func (wp *workerPool) callUpdate() {
 	wp.mu.Lock()
 	defer wp.mu.Unlock()
 
-	// Add a worker for any contract that does not already have a worker.
+	// If the pool isn't scaled down, the cap on the active workers should keep
+	// tracking the number of workers in the pool.
+	unscaled := wp.maxActiveWorkers >= len(wp.workers)
+
+	// Add a worker for any contract that does not already have a worker and
+	// whose worker wasn't disabled.
 	for id, contract := range contractMap {
 		_, exists := wp.workers[id]
 		if exists {
 			continue
 		}
+		_, disabled := wp.disabledWorkers[id]
+		if disabled {
+			continue
+		}
 
 		// Create a new worker and add it to the map
 		w, err := wp.renter.newWorker(contract.HostPublicKey)
@@ -111,7 +177,8 @@ func (wp *workerPool) callUpdate() {
 		}
 	}
 
-	// Remove a worker for any worker that is not in the set of new contracts.
+	// Remove a worker for any worker that is not in the set of new contracts
+	// or that was disabled.
 	for id, worker := range wp.workers {
 		select {
 		case <-wp.renter.tg.StopChan():
@@ -121,13 +188,103 @@ func (wp *workerPool) callUpdate() {
 		default:
 		}
 		_, exists := contractMap[id]
-		if !exists {
+		_, disabled := wp.disabledWorkers[id]
+		if !exists || disabled {
 			delete(wp.workers, id)
 			// Kill the worker in a goroutine. This avoids locking issues, as
 			// wp.mu is currently locked.
 			go worker.managedKill()
 		}
 	}
+
+	// Update the cap and the set of standby workers.
+	if unscaled || wp.maxActiveWorkers > len(wp.workers) {
+		wp.maxActiveWorkers = len(wp.workers)
+	}
+	wp.updateStandby()
+}
+
+// callUpdateMaxActiveWorkers updates the soft cap on the number of active
+// workers given the current depth of the upload heap. If the heap holds more
+// than twice the minUploadHeapSize, all workers are activated. If it holds less
+// than half the minUploadHeapSize, the cap is halved, without dropping below
+// minActiveWorkers or the maxPiecesNeeded of the queued chunks. Otherwise the
+// queued chunks couldn't be fully distributed.
+func (wp *workerPool) callUpdateMaxActiveWorkers(heapLen, maxPiecesNeeded int) {
+	// Check which workers can't currently upload. This needs to happen before
+	// locking the pool since it requires acquiring the workers' locks.
+	unusable := make(map[string]struct{})
+	for _, w := range wp.callWorkers() {
+		cache := w.staticCache()
+		w.mu.Lock()
+		onCooldown, _ := w.onUploadCooldown()
+		w.mu.Unlock()
+		if onCooldown || cache == nil || !cache.staticContractUtility.GoodForUpload {
+			unusable[w.staticHostPubKeyStr] = struct{}{}
+		}
+	}
+
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+	wp.unusableWorkers = unusable
+
+	numWorkers := len(wp.workers)
+	if heapLen > 2*minUploadHeapSize {
+		wp.maxActiveWorkers = numWorkers
+	} else if 2*heapLen < minUploadHeapSize {
+		floor := minActiveWorkers
+		if maxPiecesNeeded > floor {
+			floor = maxPiecesNeeded
+		}
+		if floor > numWorkers {
+			floor = numWorkers
+		}
+		wp.maxActiveWorkers /= 2
+		if wp.maxActiveWorkers < floor {
+			wp.maxActiveWorkers = floor
+		}
+	}
+	if maxPiecesNeeded > wp.maxActiveWorkers {
+		wp.maxActiveWorkers = maxPiecesNeeded
+	}
+	if wp.maxActiveWorkers > numWorkers {
+		wp.maxActiveWorkers = numWorkers
+	}
+	wp.updateStandby()
+}
+
+// callMaxActiveWorkers returns the current soft cap on the number of active
+// workers.
+func (wp *workerPool) callMaxActiveWorkers() int {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	return wp.maxActiveWorkers
+}
+
+// updateStandby marks the workers beyond the cap as standby workers. Workers
+// which can't upload are put in standby first, the remaining workers are
+// sorted by their host's public key, that way the set of active workers stays
+// stable while the cap doesn't change.
+func (wp *workerPool) updateStandby() {
+	hostKeys := make([]string, 0, len(wp.workers))
+	for hostKey := range wp.workers {
+		hostKeys = append(hostKeys, hostKey)
+	}
+	sort.Slice(hostKeys, func(i, j int) bool {
+		_, unusableI := wp.unusableWorkers[hostKeys[i]]
+		_, unusableJ := wp.unusableWorkers[hostKeys[j]]
+		if unusableI != unusableJ {
+			return unusableJ
+		}
+		return hostKeys[i] < hostKeys[j]
+	})
+	for i, hostKey := range hostKeys {
+		var standby uint64
+		if i >= wp.maxActiveWorkers {
+			standby = 1
+		}
+		atomic.StoreUint64(&wp.workers[hostKey].atomicStandby, standby)
+	}
 }
 
 // Worker will return the worker associated with the provided public key.
@@ -158,6 +315,54 @@ func (r *Renter) WorkerPoolStatus() (modules.WorkerPoolStatus, error) {
 	return r.staticWorkerPool.callStatus(), nil
 }
 
+// DisableWorker disables the worker for the host with the given public key.
+// The worker is killed and won't be recreated until it is enabled again.
+// Neither the host's entry in the hostdb nor the contract with the host are
+// affected.
+func (r *Renter) DisableWorker(hostKey types.SiaPublicKey) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.managedSetWorkerDisabled(hostKey, true)
+}
+
+// EnableWorker enables a previously disabled worker for the host with the
+// given public key.
+func (r *Renter) EnableWorker(hostKey types.SiaPublicKey) error {
+	if err := r.tg.Add(); err != nil {
+		return err
+	}
+	defer r.tg.Done()
+	return r.managedSetWorkerDisabled(hostKey, false)
+}
+
+// managedSetWorkerDisabled persists whether the worker for the given host is
+// disabled and updates the worker pool accordingly.
+func (r *Renter) managedSetWorkerDisabled(hostKey types.SiaPublicKey, disabled bool) error {
+	id := r.mu.Lock()
+	var disabledWorkers []types.SiaPublicKey
+	for _, spk := range r.persist.DisabledWorkers {
+		if !spk.Equals(hostKey) {
+			disabledWorkers = append(disabledWorkers, spk)
+		}
+	}
+	if disabled {
+		disabledWorkers = append(disabledWorkers, hostKey)
+	}
+	r.persist.DisabledWorkers = disabledWorkers
+	err := r.saveSync()
+	r.mu.Unlock(id)
+	if err != nil {
+		return errors.AddContext(err, "failed to persist disabled workers")
+	}
+
+	// Update the worker pool to kill or recreate the worker.
+	r.staticWorkerPool.callSetDisabled(hostKey, disabled)
+	r.staticWorkerPool.callUpdate()
+	return nil
+}
+
 // callWorkers will safely grab the list of workers in the worker pool. This
 // function must be used instead of accessing the worker map directly in any
 // situation where the workers are being used as opposed to just counted,
@@ -172,6 +377,50 @@ func (wp *workerPool) callWorkers() []*worker {
 	return workers
 }
 
+// callSectorWriteCostsByHost returns the cost of writing a full sector for
+// every worker with a valid price table by the worker's host.
+func (wp *workerPool) callSectorWriteCostsByHost() map[string]types.Currency {
+	costs := make(map[string]types.Currency)
+	for _, w := range wp.callWorkers() {
+		pt := w.staticPriceTable()
+		if pt == nil || !pt.staticValid() {
+			continue
+		}
+		costs[w.staticHostPubKeyStr] = modules.MDMWriteCost(&pt.staticPriceTable, modules.SectorSize)
+	}
+	return costs
+}
+
+// callSectorWriteCosts returns the cost of writing a full sector for every
+// worker with a valid price table sorted in ascending order.
+func (wp *workerPool) callSectorWriteCosts() []types.Currency {
+	var costs []types.Currency
+	for _, w := range wp.callWorkers() {
+		pt := w.staticPriceTable()
+		if pt == nil || !pt.staticValid() {
+			continue
+		}
+		costs = append(costs, modules.MDMWriteCost(&pt.staticPriceTable, modules.SectorSize))
+	}
+	sort.Slice(costs, func(i, j int) bool {
+		return costs[i].Cmp(costs[j]) < 0
+	})
+	return costs
+}
+
+// callExpensiveUploadHosts returns the hosts of all workers whose cached upload
+// price exceeds the max upload bandwidth price of the allowance.
+func (wp *workerPool) callExpensiveUploadHosts() map[string]struct{} {
+	hosts := make(map[string]struct{})
+	for _, w := range wp.callWorkers() {
+		cache := w.staticCache()
+		if cache != nil && cache.staticUploadTooExpensive() {
+			hosts[w.staticHostPubKeyStr] = struct{}{}
+		}
+	}
+	return hosts
+}
+
 // callNumWorkers returns the number of workers in the worker pool.
 func (wp *workerPool) callNumWorkers() int {
 	wp.mu.Lock()
@@ -183,9 +432,15 @@ func (wp *workerPool) callNumWorkers() int {
 // newWorkerPool will initialize and return a worker pool.
 func (r *Renter) newWorkerPool() *workerPool {
 	wp := &workerPool{
-		workers: make(map[string]*worker),
-		renter:  r,
+		disabledWorkers: make(map[string]types.SiaPublicKey),
+		workers:         make(map[string]*worker),
+		renter:          r,
+	}
+	id := r.mu.RLock()
+	for _, hostKey := range r.persist.DisabledWorkers {
+		wp.disabledWorkers[hostKey.String()] = hostKey
 	}
+	r.mu.RUnlock(id)
 	wp.renter.tg.OnStop(func() error {
 		wp.mu.RLock()
 		for _, w := range wp.workers {
@@ -0,0 +1,241 @@
+package renter
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/persist"
+)
+
+// TestWorkerPoolMaxActiveWorkers tests that the soft cap on the number of
+// active workers responds to the depth of the upload heap with a single call
+// to callUpdateMaxActiveWorkers, which is what a single iteration of the repair
+// loop performs.
+func TestWorkerPoolMaxActiveWorkers(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker pool with a number of dummy workers.
+	numWorkers := 4 * minActiveWorkers
+	wp := &workerPool{
+		workers: make(map[string]*worker),
+	}
+	for i := 0; i < numWorkers; i++ {
+		w := &worker{
+			staticHostPubKeyStr: fmt.Sprint(i),
+		}
+		w.atomicCache = unsafe.Pointer(&workerCache{
+			staticContractUtility: modules.ContractUtility{GoodForUpload: true},
+		})
+		wp.workers[w.staticHostPubKeyStr] = w
+	}
+	wp.maxActiveWorkers = numWorkers
+
+	// Mark the worker that sorts first as !GFU.
+	unusable := wp.workers["0"]
+	unusable.atomicCache = unsafe.Pointer(new(workerCache))
+
+	// numStandby is a helper to count the workers in standby.
+	numStandby := func() int {
+		var n int
+		for _, w := range wp.workers {
+			if atomic.LoadUint64(&w.atomicStandby) == 1 {
+				n++
+			}
+		}
+		return n
+	}
+
+	// A heap within the thresholds shouldn't change anything.
+	wp.callUpdateMaxActiveWorkers(minUploadHeapSize, 0)
+	if wp.callMaxActiveWorkers() != numWorkers {
+		t.Fatal("cap shouldn't change", wp.callMaxActiveWorkers())
+	}
+
+	// An empty heap should halve the cap.
+	wp.callUpdateMaxActiveWorkers(0, 0)
+	if wp.callMaxActiveWorkers() != numWorkers/2 {
+		t.Fatal("cap should be halved", wp.callMaxActiveWorkers())
+	}
+	if numStandby() != numWorkers-numWorkers/2 {
+		t.Fatal("wrong number of standby workers", numStandby())
+	}
+	if atomic.LoadUint64(&unusable.atomicStandby) != 1 {
+		t.Fatal("unusable worker should be in standby first")
+	}
+
+	// The cap shouldn't drop below minActiveWorkers.
+	for i := 0; i < 10; i++ {
+		wp.callUpdateMaxActiveWorkers(0, 0)
+	}
+	if wp.callMaxActiveWorkers() != minActiveWorkers {
+		t.Fatal("cap should be at the minimum", wp.callMaxActiveWorkers())
+	}
+	if numStandby() != numWorkers-minActiveWorkers {
+		t.Fatal("wrong number of standby workers", numStandby())
+	}
+
+	// The cap shouldn't drop below the number of pieces needed by the queued
+	// chunks either.
+	wp.callUpdateMaxActiveWorkers(0, 2*minActiveWorkers)
+	if wp.callMaxActiveWorkers() != 2*minActiveWorkers {
+		t.Fatal("cap should be at the pieces needed", wp.callMaxActiveWorkers())
+	}
+	wp.callUpdateMaxActiveWorkers(0, 2*minActiveWorkers)
+	if wp.callMaxActiveWorkers() != 2*minActiveWorkers {
+		t.Fatal("cap should stay at the pieces needed", wp.callMaxActiveWorkers())
+	}
+	wp.callUpdateMaxActiveWorkers(0, 0)
+	if wp.callMaxActiveWorkers() != minActiveWorkers {
+		t.Fatal("cap should be back at the minimum", wp.callMaxActiveWorkers())
+	}
+
+	// A deep heap should activate all workers again.
+	wp.callUpdateMaxActiveWorkers(2*minUploadHeapSize+1, 0)
+	if wp.callMaxActiveWorkers() != numWorkers {
+		t.Fatal("cap should be back at the number of workers", wp.callMaxActiveWorkers())
+	}
+	if numStandby() != 0 {
+		t.Fatal("no workers should be in standby", numStandby())
+	}
+
+	// The cap can't exceed the number of workers.
+	wp.workers = map[string]*worker{"0": new(worker)}
+	wp.callUpdateMaxActiveWorkers(0, 0)
+	if wp.callMaxActiveWorkers() != 1 {
+		t.Fatal("cap should be capped at the number of workers", wp.callMaxActiveWorkers())
+	}
+}
+
+// TestWorkerPoolStandby tests that a worker picks up its standby state right
+// away instead of waiting for its next cache update.
+func TestWorkerPoolStandby(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	wp := wt.rt.renter.staticWorkerPool
+
+	// The worker shouldn't be in standby by default.
+	if wt.staticStandby() {
+		t.Fatal("worker shouldn't be in standby")
+	}
+
+	// Scale the pool down to zero active workers. The worker should be in
+	// standby immediately.
+	wp.mu.Lock()
+	wp.maxActiveWorkers = 0
+	wp.updateStandby()
+	wp.mu.Unlock()
+	if !wt.staticStandby() {
+		t.Fatal("worker should be in standby")
+	}
+
+	// Scale the pool back up. The worker should leave standby immediately.
+	wp.callUpdateMaxActiveWorkers(2*minUploadHeapSize+1, 0)
+	if wt.staticStandby() {
+		t.Fatal("worker shouldn't be in standby")
+	}
+}
+
+// TestWorkerPoolDisableWorker tests disabling and enabling a worker.
+func TestWorkerPoolDisableWorker(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+	wp := r.staticWorkerPool
+	hostKey := wt.staticHostPubKey
+
+	// Disable the worker.
+	err = r.DisableWorker(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker should be removed from the pool and killed.
+	err = build.Retry(100, 10*time.Millisecond, func() error {
+		if !wt.worker.staticKilled() {
+			return fmt.Errorf("worker should be killed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wp.callWorker(hostKey); err == nil {
+		t.Fatal("worker shouldn't be in the pool")
+	}
+
+	// The worker shouldn't be recreated by an update of the pool and the host
+	// shouldn't be considered for repairs.
+	hosts := r.managedRefreshHostsAndWorkers()
+	if _, exists := hosts[hostKey.String()]; exists {
+		t.Fatal("host of disabled worker shouldn't be returned")
+	}
+	if _, err := wp.callWorker(hostKey); err == nil {
+		t.Fatal("worker shouldn't be recreated")
+	}
+
+	// The worker should be reported as disabled and persisted.
+	wps, err := r.WorkerPoolStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wps.DisabledWorkers) != 1 || !wps.DisabledWorkers[0].Equals(hostKey) {
+		t.Fatal("unexpected disabled workers", wps.DisabledWorkers)
+	}
+	var p persistence
+	err = persist.LoadJSON(settingsMetadata, &p, filepath.Join(r.persistDir, PersistFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.DisabledWorkers) != 1 || !p.DisabledWorkers[0].Equals(hostKey) {
+		t.Fatal("unexpected persisted disabled workers", p.DisabledWorkers)
+	}
+
+	// Enable the worker again. It should be recreated.
+	err = r.EnableWorker(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wp.callWorker(hostKey); err != nil {
+		t.Fatal("worker should be recreated", err)
+	}
+	hosts = r.managedRefreshHostsAndWorkers()
+	if _, exists := hosts[hostKey.String()]; !exists {
+		t.Fatal("host of enabled worker should be returned")
+	}
+	if len(wp.callDisabledWorkers()) != 0 {
+		t.Fatal("no worker should be disabled")
+	}
+}
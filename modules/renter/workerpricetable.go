@@ -32,6 +32,20 @@ var (
 	// priceTableHostBlockHeightLeeWay,  of our own block height.
 	errHostBlockHeightNotWithinTolerance = errors.New("host blockheight is not within tolerance, host is unsynced")
 
+	// errPinPriceTableNotSupported is returned when trying to pin a price
+	// table on a host that doesn't support it.
+	errPinPriceTableNotSupported = errors.New("host doesn't support pinning price tables")
+
+	// maxHostClockSkew is the maximum amount of time the clock of a host is
+	// allowed to deviate from the renter's clock before the host is
+	// considered to have a skewed clock. Hosts with a skewed clock are avoided
+	// for time-sensitive operations like subscriptions.
+	maxHostClockSkew = build.Select(build.Var{
+		Standard: 2 * time.Minute,
+		Dev:      time.Minute,
+		Testing:  30 * time.Second,
+	}).(time.Duration)
+
 	// minAcceptedPriceTableValidity is the minimum price table validity
 	// the renter will accept.
 	minAcceptedPriceTableValidity = build.Select(build.Var{
@@ -94,6 +108,11 @@ type (
 		// staticRecentErrTime specifies the time at which the most recent
 		// occurred
 		staticRecentErrTime time.Time
+
+		// staticClockSkew is the difference between the time reported by the
+		// host in the price table and the renter's time at which the price
+		// table was received. A positive skew means the host's clock is ahead.
+		staticClockSkew time.Duration
 	}
 )
 
@@ -105,7 +124,7 @@ func (w *worker) managedNeedsToUpdatePriceTable() bool {
 		return false
 	}
 
-	return w.staticPriceTable().staticNeedsToUpdate()
+	return w.staticPriceTable().staticNeedsRefresh(time.Now())
 }
 
 // newPriceTable will initialize a price table for the worker.
@@ -165,16 +184,32 @@ func (wpt *workerPriceTable) staticValid() bool {
 }
 
 // staticValidFor is a helper that returns true if the price table is valid
-// for the provided duration.
-func (wpt *workerPriceTable) staticValidFor(duration time.Duration) bool {
-	minExpiry := time.Now().Add(duration)
+// for the provided duration starting at the provided time. The price table
+// needs to expire strictly after the end of the duration.
+func (wpt *workerPriceTable) staticValidFor(duration time.Duration, now time.Time) bool {
+	minExpiry := now.Add(duration)
 	return minExpiry.Before(wpt.staticExpiryTime)
 }
 
-// staticNeedsToUpdate returns whether or not the price table needs to be
-// updated.
-func (wpt *workerPriceTable) staticNeedsToUpdate() bool {
-	return time.Now().After(wpt.staticUpdateTime)
+// staticClockSkewed returns true if the clock skew between the renter and the
+// host exceeds maxHostClockSkew.
+func (wpt *workerPriceTable) staticClockSkewed() bool {
+	return absDuration(wpt.staticClockSkew) > maxHostClockSkew
+}
+
+// staticNeedsRefresh returns whether or not the price table needs to be
+// updated at the provided time. This is the case once the time is past the
+// price table's update time.
+func (wpt *workerPriceTable) staticNeedsRefresh(now time.Time) bool {
+	return now.After(wpt.staticUpdateTime)
+}
+
+// priceTableUpdateTime returns the time at which a price table that was
+// received at the provided time and is valid for the provided duration should
+// be updated. The price table is updated once half of its validity window has
+// passed to ensure it is updated before it expires.
+func priceTableUpdateTime(now time.Time, validity time.Duration) time.Time {
+	return now.Add((validity / 2).Truncate(time.Second))
 }
 
 // managedUpdatePriceTable performs the UpdatePriceTableRPC on the host.
@@ -182,8 +217,7 @@ func (w *worker) staticUpdatePriceTable() {
 	// Sanity check - This function runs on a fairly strict schedule, the
 	// control loop should not have called this function unless the price table
 	// is after its updateTime.
-	updateTime := w.staticPriceTable().staticUpdateTime
-	if time.Now().Before(updateTime) {
+	if !w.staticPriceTable().staticNeedsRefresh(time.Now()) {
 		w.renter.log.Critical("price table is being updated prematurely")
 	}
 	// Sanity check - only one price table update should be running at a time.
@@ -240,8 +274,12 @@ func (w *worker) staticUpdatePriceTable() {
 		// were completed successfully.
 		cd := w.managedTrackPriceTableUpdateErr(err)
 
-		// If there was no error, return.
+		// If there was no error, signal a pending account recovery to retry
+		// and return.
 		if err == nil {
+			w.staticAccount.mu.Lock()
+			w.staticAccount.recoveryRetry = w.staticAccount.pendingRecovery
+			w.staticAccount.mu.Unlock()
 			return
 		}
 
@@ -254,6 +292,7 @@ func (w *worker) staticUpdatePriceTable() {
 			staticUpdateTime:       cd,
 			staticRecentErr:        err,
 			staticRecentErrTime:    time.Now(),
+			staticClockSkew:        currentPT.staticClockSkew,
 		}
 		w.staticSetPriceTable(pt)
 
@@ -306,6 +345,17 @@ func (w *worker) staticUpdatePriceTable() {
 		return
 	}
 
+	// compute the clock skew between us and the host, we assume the host
+	// created the price table halfway through the round trip, older hosts
+	// don't report their time
+	var clockSkew time.Duration
+	if pt.HostTime != 0 {
+		clockSkew = time.Unix(pt.HostTime, 0).Sub(start.Add(elapsed / 2))
+		if absDuration(clockSkew) > maxHostClockSkew {
+			w.renter.log.Printf("WARN: clock of host %v is skewed by %v", w.staticHostPubKeyStr, clockSkew)
+		}
+	}
+
 	// check for gouging before paying
 	err = checkUpdatePriceTableGouging(pt, w.staticCache().staticRenterAllowance)
 	if err != nil {
@@ -352,13 +402,11 @@ func (w *worker) staticUpdatePriceTable() {
 		return
 	}
 
-	// Calculate the expiry time and set the update time to be half of the
-	// expiry window to ensure we update the PT before it expires
+	// Calculate the expiry time and the time at which the price table should
+	// be updated.
 	now := time.Now()
 	expiryTime := now.Add(pt.Validity)
-	expiryHalfTimeInS := (expiryTime.Unix() - now.Unix()) / 2
-	expiryHalfTime := time.Duration(expiryHalfTimeInS) * time.Second
-	newUpdateTime := time.Now().Add(expiryHalfTime)
+	newUpdateTime := priceTableUpdateTime(now, pt.Validity)
 
 	// Update the price table. We preserve the recent error even though there
 	// has not been an error for debugging purposes, if there has been an error
@@ -370,10 +418,114 @@ func (w *worker) staticUpdatePriceTable() {
 		staticLastForcedUpdate: currentPT.staticLastForcedUpdate,
 		staticRecentErr:        currentPT.staticRecentErr,
 		staticRecentErrTime:    currentPT.staticRecentErrTime,
+		staticClockSkew:        clockSkew,
 	}
 	w.staticSetPriceTable(wpt)
 }
 
+// managedPinPriceTable asks the host for a price table that is valid for at
+// least the provided validity and is only accepted for the given rpc. Unlike
+// the worker's regular price table, the pinned price table is not stored in
+// the worker but returned to the caller.
+func (w *worker) managedPinPriceTable(rpc types.Specifier, validity time.Duration) (_ *modules.RPCPriceTable, err error) {
+	// Check if the host supports pinning price tables.
+	if build.VersionCmp(w.staticCache().staticHostVersion, minPinPriceTableVersion) < 0 {
+		return nil, errors.AddContext(errPinPriceTableNotSupported, fmt.Sprintf("host %v", w.staticHostPubKeyStr))
+	}
+
+	// The cost of the pinned price table is checked against the current one.
+	currentPT := w.staticPriceTable().staticPriceTable
+
+	// Get a stream.
+	stream, err := w.staticNewStream()
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to create new stream")
+	}
+	defer func() {
+		err = errors.Compose(err, stream.Close())
+	}()
+
+	// write the specifier and request
+	err = modules.RPCWriteAll(stream, modules.RPCPinPriceTable, modules.RPCPinPriceTableRequest{
+		RPC:      rpc,
+		Validity: validity,
+	})
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to write pin price table request")
+	}
+
+	// receive the price table
+	var uptr modules.RPCUpdatePriceTableResponse
+	err = modules.RPCRead(stream, &uptr)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to read price table response")
+	}
+	var pt modules.RPCPriceTable
+	err = json.Unmarshal(uptr.PriceTableJSON, &pt)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to unmarshal price table")
+	}
+
+	// check the price table before paying
+	if pt.Validity < validity {
+		return nil, fmt.Errorf("pinned price table validity %v is lower than the requested validity %v", pt.Validity, validity)
+	}
+	err = checkPinnedPriceTableGouging(pt, currentPT, w.staticCache().staticRenterAllowance)
+	if err != nil {
+		return nil, errors.Compose(err, errors.AddContext(errPriceTableGouging, fmt.Sprintf("host %v", w.staticHostPubKeyStr)))
+	}
+	cache := w.staticCache()
+	if !hostBlockHeightWithinTolerance(cache.staticSynced, cache.staticBlockHeight, pt.HostBlockHeight) {
+		return nil, errors.AddContext(errHostBlockHeightNotWithinTolerance, fmt.Sprintf("renter height: %v synced: %v, host height: %v", cache.staticBlockHeight, cache.staticSynced, pt.HostBlockHeight))
+	}
+
+	// provide payment
+	details := contractor.PaymentDetails{
+		Host:          w.staticHostPubKey,
+		Amount:        pt.UpdatePriceTableCost,
+		RefundAccount: w.staticAccount.staticID,
+		SpendingDetails: modules.SpendingDetails{
+			MaintenanceSpending: modules.MaintenanceSpending{
+				UpdatePriceTableCost: pt.UpdatePriceTableCost,
+			},
+		},
+	}
+	err = w.renter.hostContractor.ProvidePayment(stream, &pt, details)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to provide payment")
+	}
+
+	// wait for the host to confirm that it tracked the price table
+	var tracked modules.RPCTrackedPriceTableResponse
+	err = modules.RPCRead(stream, &tracked)
+	if err != nil {
+		return nil, errors.AddContext(err, "unable to read tracked response")
+	}
+	return &pt, nil
+}
+
+// checkPinnedPriceTableGouging verifies that the cost of a pinned price table
+// is reasonable. Pinning a price table should never cost more than updating the
+// current price table for the whole validity of the pinned one.
+func checkPinnedPriceTableGouging(pt, currentPT modules.RPCPriceTable, allowance modules.Allowance) error {
+	err := checkUpdatePriceTableGouging(pt, allowance)
+	if err != nil {
+		return err
+	}
+	if currentPT.Validity == 0 {
+		return errors.New("no price table to compare the pinned price table to")
+	}
+	periods := uint64(pt.Validity / currentPT.Validity)
+	if pt.Validity%currentPT.Validity != 0 {
+		periods++
+	}
+	maxCost := currentPT.UpdatePriceTableCost.Mul64(periods)
+	if pt.UpdatePriceTableCost.Cmp(maxCost) > 0 {
+		return fmt.Errorf("pinned price table cost %v exceeds the cost of updating the price table for the same duration %v", pt.UpdatePriceTableCost, maxCost)
+	}
+	return nil
+}
+
 // checkUpdatePriceTableGouging verifies the cost of updating the price table is
 // reasonable, if deemed unreasonable we will reject it and this worker will be
 // put into cooldown.
@@ -425,3 +577,11 @@ func hostBlockHeightWithinTolerance(synced bool, renterBlockHeight, hostBlockHei
 	}
 	return true
 }
+
+// absDuration returns the absolute value of the given duration.
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -2,6 +2,7 @@ package renter
 
 import (
 	"bytes"
+	"context"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -91,6 +92,51 @@ func TestUpdatePriceTableHostHeightLeeway(t *testing.T) {
 	}
 }
 
+// TestPinPriceTableVersion verifies that the worker only tries to pin a price
+// table if the host's version supports it.
+func TestPinPriceTableVersion(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a new worker tester
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// pinning a price table should work on an up-to-date host
+	validity := modules.SubscriptionPeriod
+	pt, err := w.managedPinPriceTable(modules.RPCRegistrySubscription, validity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pt.Validity < validity {
+		t.Fatal("pinned price table isn't valid long enough", pt.Validity)
+	}
+
+	// prevent cache updates.
+	atomic.StoreUint64(&w.atomicCacheUpdating, 1)
+
+	// pretend the host runs a version without support for pinning
+	wc := *w.staticCache()
+	wc.staticHostVersion = "1.5.8"
+	atomic.StorePointer(&w.atomicCache, unsafe.Pointer(&wc))
+
+	_, err = w.managedPinPriceTable(modules.RPCRegistrySubscription, validity)
+	if !errors.Contains(err, errPinPriceTableNotSupported) {
+		t.Fatal("expected errPinPriceTableNotSupported", err)
+	}
+}
+
 // TestUpdatePriceTableGouging checks that the price table gouging is correctly
 // detecting price gouging from a host.
 func TestUpdatePriceTableGouging(t *testing.T) {
@@ -266,6 +312,56 @@ func TestSchedulePriceTableUpdate(t *testing.T) {
 	}
 }
 
+// TestUpdatePriceTableClockSkew verifies the worker detects when the clock of
+// the host is skewed and avoids the host for subscriptions.
+func TestUpdatePriceTableClockSkew(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// create a new worker tester with a host that reports a skewed time
+	wt, err := newWorkerTesterCustomDependency(t.Name(), modules.ProdDependencies, &dependencies.HostSkewedClock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// the skew should be detected and reported in the worker status
+	status := w.staticPriceTableStatus()
+	if !status.ClockSkewed {
+		t.Fatal("expected host clock to be skewed", status.ClockSkew)
+	}
+	if status.ClockSkew < time.Hour-maxHostClockSkew || status.ClockSkew > time.Hour+maxHostClockSkew {
+		t.Fatal("unexpected clock skew", status.ClockSkew)
+	}
+
+	// subscribing to the host should fail
+	_, spk, _ := randomRegistryValue()
+	req := modules.RPCRegistrySubscriptionRequest{PubKey: spk}
+	_, _, err = w.Subscribe(context.Background(), req)
+	if !errors.Contains(err, errHostClockSkewed) {
+		t.Fatal("expected subscription to be refused", err)
+	}
+
+	// a price table without skew shouldn't be considered skewed
+	wpt := *w.staticPriceTable()
+	wpt.staticClockSkew = maxHostClockSkew / 2
+	if wpt.staticClockSkewed() {
+		t.Fatal("price table shouldn't be considered skewed")
+	}
+	wpt.staticClockSkew = -2 * maxHostClockSkew
+	if !wpt.staticClockSkewed() {
+		t.Fatal("price table should be considered skewed")
+	}
+}
+
 // TestHostBlockHeightWithinTolerance is a unit test that covers the logic
 // contained within the hostBlockHeightWithinTolerance helper.
 func TestHostBlockHeightWithinTolerance(t *testing.T) {
@@ -298,6 +394,82 @@ func TestHostBlockHeightWithinTolerance(t *testing.T) {
 	}
 }
 
+// TestPriceTableValidFor is a unit test for staticValidFor.
+func TestPriceTableValidFor(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	duration := time.Minute
+	tests := []struct {
+		name   string
+		expiry time.Time
+		valid  bool
+	}{
+		{"expired", now.Add(-time.Second), false},
+		{"zero expiry", time.Time{}, false},
+		{"expires before the duration", now.Add(duration / 2), false},
+		{"expires at the boundary", now.Add(duration), false},
+		{"expires one nanosecond after the boundary", now.Add(duration + time.Nanosecond), true},
+		{"far in the future", now.Add(100 * duration), true},
+	}
+	for _, test := range tests {
+		wpt := &workerPriceTable{staticExpiryTime: test.expiry}
+		if wpt.staticValidFor(duration, now) != test.valid {
+			t.Errorf("%v: expected valid to be %v", test.name, test.valid)
+		}
+	}
+
+	// A duration of zero checks that the price table hasn't expired yet.
+	wpt := &workerPriceTable{staticExpiryTime: now.Add(time.Nanosecond)}
+	if !wpt.staticValidFor(0, now) {
+		t.Fatal("price table should be valid one nanosecond before expiry")
+	}
+	if wpt.staticValidFor(0, now.Add(time.Nanosecond)) {
+		t.Fatal("price table shouldn't be valid at expiry")
+	}
+}
+
+// TestPriceTableNeedsRefresh is a unit test for staticNeedsRefresh and
+// priceTableUpdateTime.
+func TestPriceTableNeedsRefresh(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	validity := 10 * time.Minute
+	updateTime := priceTableUpdateTime(now, validity)
+	if !updateTime.Equal(now.Add(validity / 2)) {
+		t.Fatal("price table should be updated halfway through its validity", updateTime.Sub(now))
+	}
+	// The update time is rounded down to the second.
+	if ut := priceTableUpdateTime(now, 11*time.Second+time.Millisecond); !ut.Equal(now.Add(5 * time.Second)) {
+		t.Fatal("wrong update time", ut.Sub(now))
+	}
+
+	wpt := &workerPriceTable{staticUpdateTime: updateTime}
+	tests := []struct {
+		name    string
+		now     time.Time
+		refresh bool
+	}{
+		{"received", now, false},
+		{"before update time", updateTime.Add(-time.Nanosecond), false},
+		{"at update time", updateTime, false},
+		{"after update time", updateTime.Add(time.Nanosecond), true},
+		{"after expiry", now.Add(validity), true},
+	}
+	for _, test := range tests {
+		if wpt.staticNeedsRefresh(test.now) != test.refresh {
+			t.Errorf("%v: expected refresh to be %v", test.name, test.refresh)
+		}
+	}
+
+	// A price table without an update time always needs to be refreshed.
+	wpt = &workerPriceTable{}
+	if !wpt.staticNeedsRefresh(now) {
+		t.Fatal("price table without update time should be refreshed")
+	}
+}
+
 // newDefaultPriceTable is a helper function that returns a price table with
 // default prices for all fields
 func newDefaultPriceTable() modules.RPCPriceTable {
@@ -74,6 +74,12 @@ func (w *worker) managedExecuteProgram(p modules.Program, data []byte, fcid type
 		}
 	}()
 
+	// Repair traffic is additionally limited by the renter's repair
+	// ratelimit.
+	if category.isRepair() {
+		stream = ratelimit.NewRLStream(stream, w.renter.staticRepairRL, w.renter.tg.StopChan())
+	}
+
 	// set the limit return var.
 	limit = stream.Limit()
 
@@ -160,6 +166,26 @@ func (w *worker) managedExecuteProgram(p modules.Program, data []byte, fcid type
 	return
 }
 
+// discardReadWriter is an io.ReadWriter which discards everything written to
+// it and never returns any data.
+type discardReadWriter struct{}
+
+// Read implements io.Reader.
+func (discardReadWriter) Read(_ []byte) (int, error) { return 0, io.EOF }
+
+// Write implements io.Writer.
+func (discardReadWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+// staticThrottleRepairUpload blocks until the renter's repair ratelimit allows
+// for the provided data to be uploaded. Uploads use editors and sessions which
+// are shared with user uploads, which is why repair uploads are throttled
+// before the data is sent instead of on the connection itself.
+func (w *worker) staticThrottleRepairUpload(data []byte) error {
+	rw := ratelimit.NewRLReadWriter(discardReadWriter{}, w.renter.staticRepairRL, w.renter.tg.StopChan())
+	_, err := rw.Write(data)
+	return err
+}
+
 // staticNewStream returns a new stream to the worker's host
 func (w *worker) staticNewStream() (siamux.Stream, error) {
 	// If disrupt is called we sleep for the specified 'defaultNewStreamTimeout'
@@ -1,9 +1,11 @@
 package renter
 
 import (
+	"sync/atomic"
 	"time"
 
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
 )
 
 // callStatus returns the status of the worker.
@@ -44,20 +46,25 @@ func (w *worker) callStatus() modules.WorkerStatus {
 		ContractID:      cache.staticContractID,
 		ContractUtility: cache.staticContractUtility,
 		HostPubKey:      w.staticHostPubKey,
+		Version:         cache.staticHostVersion,
 
 		// Download information
-		DownloadCoolDownError: downloadCoolDownErr,
-		DownloadCoolDownTime:  downloadCoolDownTime,
-		DownloadOnCoolDown:    downloadOnCoolDown,
-		DownloadQueueSize:     downloadQueueSize,
-		DownloadTerminated:    downloadTerminated,
+		BytesDownloaded:          atomic.LoadUint64(&w.atomicBytesDownloaded),
+		DownloadCoolDownError:    downloadCoolDownErr,
+		DownloadCoolDownTime:     downloadCoolDownTime,
+		DownloadOnCoolDown:       downloadOnCoolDown,
+		DownloadQueueSize:        downloadQueueSize,
+		DownloadSpeedBytesPerSec: w.downloadSpeed,
+		DownloadTerminated:       downloadTerminated,
 
 		// Upload information
-		UploadCoolDownError: uploadCoolDownErr,
-		UploadCoolDownTime:  uploadCoolDownTime,
-		UploadOnCoolDown:    uploadOnCoolDown,
-		UploadQueueSize:     w.unprocessedChunks.Len(),
-		UploadTerminated:    w.uploadTerminated,
+		BytesUploaded:          atomic.LoadUint64(&w.atomicBytesUploaded),
+		UploadCoolDownError:    uploadCoolDownErr,
+		UploadCoolDownTime:     uploadCoolDownTime,
+		UploadOnCoolDown:       uploadOnCoolDown,
+		UploadQueueSize:        w.unprocessedChunks.Len(),
+		UploadSpeedBytesPerSec: w.uploadSpeed,
+		UploadTerminated:       w.uploadTerminated,
 
 		// Job Queues
 		DownloadSnapshotJobQueueSize: int(w.staticJobDownloadSnapshotQueue.callStatus().size),
@@ -75,6 +82,9 @@ func (w *worker) callStatus() modules.WorkerStatus {
 		// Price Table Information
 		PriceTableStatus: w.staticPriceTableStatus(),
 
+		// Host Settings Information
+		HostSettingsStatus: cache.staticHostSettingsStatus(),
+
 		// Read Job Information
 		ReadJobsStatus: w.callReadJobStatus(),
 
@@ -86,6 +96,9 @@ func (w *worker) callStatus() modules.WorkerStatus {
 
 		// UpdateRegistry Job Information
 		UpdateRegistryJobsStatus: w.callUpdateRegistryJobsStatus(),
+
+		// Subscription Information
+		SubscriptionStatus: w.callSubscriptionStatus(),
 	}
 }
 
@@ -104,11 +117,44 @@ func (w *worker) staticPriceTableStatus() modules.WorkerPriceTableStatus {
 
 		Active: time.Now().Before(pt.staticExpiryTime),
 
+		ClockSkew:   pt.staticClockSkew,
+		ClockSkewed: pt.staticClockSkewed(),
+
 		RecentErr:     recentErrStr,
 		RecentErrTime: pt.staticRecentErrTime,
 	}
 }
 
+// staticHostSettingsStatus returns the status of the host settings and price
+// table expiry stored in the cache. A host is considered affordable if its
+// prices don't exceed the max prices of the cached allowance.
+func (wc *workerCache) staticHostSettingsStatus() modules.WorkerHostSettingsStatus {
+	hes := wc.staticHostSettings
+	allowance := wc.staticRenterAllowance
+	affordable := withinMaxPrice(hes.StoragePrice, allowance.MaxStoragePrice) &&
+		withinMaxPrice(hes.UploadBandwidthPrice, allowance.MaxUploadBandwidthPrice) &&
+		withinMaxPrice(hes.DownloadBandwidthPrice, allowance.MaxDownloadBandwidthPrice)
+
+	return modules.WorkerHostSettingsStatus{
+		DownloadBandwidthPrice: hes.DownloadBandwidthPrice,
+		MaxDuration:            hes.MaxDuration,
+		StoragePrice:           hes.StoragePrice,
+		UploadBandwidthPrice:   hes.UploadBandwidthPrice,
+
+		Affordable:       affordable,
+		PriceTableExpiry: wc.staticPriceTableExpiry,
+		StalePriceTable:  !time.Now().Before(wc.staticPriceTableExpiry),
+
+		LastUpdate: wc.staticLastUpdate,
+	}
+}
+
+// withinMaxPrice returns true if the price doesn't exceed the max price. A max
+// price of zero means that there is no limit.
+func withinMaxPrice(price, maxPrice types.Currency) bool {
+	return maxPrice.IsZero() || price.Cmp(maxPrice) <= 0
+}
+
 // callReadJobStatus returns the status of the read job queue
 func (w *worker) callReadJobStatus() modules.WorkerReadJobsStatus {
 	jrq := w.staticJobReadQueue
@@ -181,6 +227,7 @@ func callGenericWorkerJobStatus(queue *jobGenericQueue) modules.WorkerGenericJob
 func (w *worker) callReadRegistryJobsStatus() modules.WorkerReadRegistryJobStatus {
 	return modules.WorkerReadRegistryJobStatus{
 		WorkerGenericJobsStatus: callGenericWorkerJobStatus(w.staticJobReadRegistryQueue.jobGenericQueue),
+		StaleResponses:          w.staticJobReadRegistryQueue.callStaleResponses(),
 	}
 }
 
@@ -190,3 +237,36 @@ func (w *worker) callUpdateRegistryJobsStatus() modules.WorkerUpdateRegistryJobS
 		WorkerGenericJobsStatus: callGenericWorkerJobStatus(w.staticJobUpdateRegistryQueue.jobGenericQueue),
 	}
 }
+
+// callSubscriptionStatus returns the status of the worker's registry
+// subscription session.
+func (w *worker) callSubscriptionStatus() modules.WorkerSubscriptionStatus {
+	subInfo := w.staticSubscriptionInfo
+	subInfo.mu.Lock()
+	var status modules.WorkerSubscriptionStatus
+	for _, sub := range subInfo.subscriptions {
+		if sub.active() {
+			status.NumSubscriptions++
+		}
+		if sub.evicted && sub.refs > 0 {
+			status.NumEvicted++
+		}
+	}
+	status.MaxSubscriptions = uint64(subInfo.maxSubscriptions)
+	status.Backoff = time.Duration(atomic.LoadInt64(&subInfo.atomicBackoff))
+	session := subInfo.session
+	if session != nil {
+		status.Active = true
+		status.BudgetRemaining = session.staticBudget.Remaining()
+		status.TimeUntilDeadline = time.Until(session.deadline)
+		if session.funded.Cmp(status.BudgetRemaining) > 0 {
+			status.Spent = session.funded.Sub(status.BudgetRemaining)
+		}
+	}
+	if subInfo.recentErr != nil {
+		status.RecentErr = subInfo.recentErr.Error()
+		status.RecentErrTime = subInfo.recentErrTime
+	}
+	subInfo.mu.Unlock()
+	return status
+}
@@ -492,3 +492,210 @@ func TestWorkerRegistryJobStatus(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestWorkerHostSettingsStatus verifies that the host settings and price table
+// expiry in the worker's status are updated with the worker cache.
+func TestWorkerHostSettingsStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := wt.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	w := wt.worker
+
+	// checkStatus is a helper that verifies the status matches the given
+	// storage price and the expiry of the worker's current price table.
+	checkStatus := func(storagePrice types.Currency) error {
+		w.staticTryUpdateCache()
+		status := w.callStatus().HostSettingsStatus
+		if !status.StoragePrice.Equals(storagePrice) {
+			return fmt.Errorf("expected storage price %v but got %v", storagePrice, status.StoragePrice)
+		}
+		if expiry := w.staticPriceTable().staticExpiryTime; !status.PriceTableExpiry.Equal(expiry) {
+			return fmt.Errorf("expected price table expiry %v but got %v", expiry, status.PriceTableExpiry)
+		}
+		if status.StalePriceTable {
+			return errors.New("price table shouldn't be stale")
+		}
+		if !status.Affordable {
+			return errors.New("host should be affordable")
+		}
+		return nil
+	}
+
+	// The status should reflect the host's settings and the worker's price
+	// table.
+	storagePrice := wt.host.ExternalSettings().StoragePrice
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return checkStatus(storagePrice)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastUpdate := w.staticCache().staticLastUpdate
+
+	// Update the host's storage price and re-announce the host to have the
+	// hostdb rescan it.
+	is := wt.host.InternalSettings()
+	is.MinStoragePrice = is.MinStoragePrice.Mul64(2)
+	err = wt.host.SetInternalSettings(is)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wt.host.Announce(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = wt.rt.miner.AddBlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replace the price table with one that expires later.
+	wpt := w.staticPriceTable()
+	wptc := new(workerPriceTable)
+	wptc.staticExpiryTime = wpt.staticExpiryTime.Add(time.Hour)
+	wptc.staticUpdateTime = wpt.staticUpdateTime
+	wptc.staticPriceTable = wpt.staticPriceTable
+	w.staticSetPriceTable(wptc)
+
+	// The status should be updated after the next cache update.
+	storagePrice = wt.host.ExternalSettings().StoragePrice
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		return checkStatus(storagePrice)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := w.staticCache().staticLastUpdate.Sub(lastUpdate); elapsed < workerCacheUpdateFrequency {
+		t.Fatal("cache was updated too early", elapsed)
+	}
+}
+
+// TestWorkerSubscriptionStatus verifies the output of the worker's
+// subscription status.
+func TestWorkerSubscriptionStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Without subscriptions there is no active session.
+	status := wt.callStatus().SubscriptionStatus
+	if status.Active || status.NumSubscriptions != 0 || !status.Spent.IsZero() || status.RecentErr != "" {
+		t.Fatal("unexpected status", status)
+	}
+
+	// Subscribe to an entry.
+	rv, spk, _ := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, handle, err := wt.Subscribe(context.Background(), modules.RPCRegistrySubscriptionRequest{
+		PubKey: spk,
+		Tweak:  rv.Tweak,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The session should be active and paid for the subscription.
+	status = wt.callStatus().SubscriptionStatus
+	if !status.Active || status.NumSubscriptions != 1 {
+		t.Fatal("unexpected status", status)
+	}
+	if status.Spent.IsZero() || status.BudgetRemaining.IsZero() {
+		t.Fatal("unexpected funds", status.Spent, status.BudgetRemaining)
+	}
+	if !status.BudgetRemaining.Add(status.Spent).Equals(initialSubscriptionBudget) {
+		t.Fatal("spent and remaining budget don't add up to the initial budget")
+	}
+	if status.TimeUntilDeadline <= 0 || status.TimeUntilDeadline > modules.SubscriptionPeriod {
+		t.Fatal("unexpected time until deadline", status.TimeUntilDeadline)
+	}
+
+	// Close the handle. The session stays active without subscriptions.
+	handle.Close()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		status := wt.callStatus().SubscriptionStatus
+		if !status.Active || status.NumSubscriptions != 0 {
+			return fmt.Errorf("unexpected status %v", status)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSubscriptionSessionStatus is a unit test for the helpers which track
+// the status of a subscription session.
+func TestSubscriptionSessionStatus(t *testing.T) {
+	w := &worker{
+		staticSubscriptionInfo: &subscriptionInfos{
+			subscriptions: make(map[modules.RegistryEntryID]*subscription),
+		},
+	}
+	subInfo := w.staticSubscriptionInfo
+
+	// Start a session.
+	budget := modules.NewBudget(types.NewCurrency64(100))
+	deadline := time.Now().Add(time.Hour)
+	subInfo.managedStartSession(budget, deadline)
+
+	// Spend some money, refill it and extend the session.
+	budget.Withdraw(types.NewCurrency64(60))
+	budget.Deposit(types.NewCurrency64(50))
+	subInfo.managedSessionFunded(types.NewCurrency64(50))
+	budget.Withdraw(types.NewCurrency64(10))
+	subInfo.managedSessionExtended(deadline.Add(time.Hour))
+
+	status := w.callSubscriptionStatus()
+	if !status.Active {
+		t.Fatal("session should be active")
+	}
+	if !status.BudgetRemaining.Equals64(80) || !status.Spent.Equals64(70) {
+		t.Fatal("unexpected funds", status.BudgetRemaining, status.Spent)
+	}
+	if status.TimeUntilDeadline <= time.Hour {
+		t.Fatal("session wasn't extended", status.TimeUntilDeadline)
+	}
+
+	// End the session with an error.
+	subInfo.managedEndSession(errors.New("failure"))
+	status = w.callSubscriptionStatus()
+	if status.Active || !status.BudgetRemaining.IsZero() || !status.Spent.IsZero() || status.TimeUntilDeadline != 0 {
+		t.Fatal("session should be inactive", status)
+	}
+	if status.RecentErr != "failure" || status.RecentErrTime.IsZero() {
+		t.Fatal("unexpected error", status.RecentErr, status.RecentErrTime)
+	}
+
+	// Ending a session without an error keeps the previous one.
+	subInfo.managedStartSession(budget, deadline)
+	subInfo.managedEndSession(nil)
+	if status := w.callSubscriptionStatus(); status.RecentErr != "failure" {
+		t.Fatal("error shouldn't have been reset", status.RecentErr)
+	}
+}
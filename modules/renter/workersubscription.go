@@ -5,6 +5,7 @@ import (
 	"encoding/hex"
 	"fmt"
 	"io"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +16,7 @@ import (
 	"gitlab.com/NebulousLabs/siamux"
 	"gitlab.com/NebulousLabs/threadgroup"
 	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/types"
 )
@@ -22,18 +24,31 @@ import (
 // TODO: (f/u) cooldown testing
 
 var (
+	// errTooManySubscriptions is returned when a single call to Subscribe
+	// requests more entries than the worker keeps active subscriptions for.
+	errTooManySubscriptions = errors.New("number of requested subscriptions exceeds the worker's subscription limit")
+
+	// errHostClockSkewed is returned when trying to subscribe to a host whose
+	// clock is too far off from the renter's clock.
+	errHostClockSkewed = errors.New("host clock is skewed")
+
 	// initialSubscriptionBudget is the initial budget withdrawn for a
 	// subscription. After using up 50% of it, the worker refills the budget
 	// again to match the initial budget.
 	initialSubscriptionBudget = modules.DefaultMaxEphemeralAccountBalance.Div64(10) // 10% of the max
 
-	// subscriptionCooldownResetInterval is the time after which we consider an
-	// ongoing subscription to be healthy enough to reset the consecutive
+	// subscriptionBackoffMin is the backoff after the first failed
+	// subscription session. It doubles with every consecutive failure up to
+	// modules.SubscriptionPeriod.
+	subscriptionBackoffMin = time.Second
+
+	// subscriptionBackoffResetDuration is the time a subscription session
+	// needs to last to be considered healthy enough to reset the consecutive
 	// failures.
-	subscriptionCooldownResetInterval = build.Select(build.Var{
+	subscriptionBackoffResetDuration = build.Select(build.Var{
 		Testing:  time.Second * 5,
-		Dev:      time.Minute,
-		Standard: time.Hour,
+		Dev:      time.Second * 30,
+		Standard: time.Second * 30,
 	}).(time.Duration)
 
 	// subscriptionExtensionWindow is the time before the subscription period
@@ -60,6 +75,10 @@ var (
 	priceTableRetryInterval = time.Second
 )
 
+// subscriptionContractExpiryBuffer is the number of blocks before the end of
+// a worker's contract at which the worker stops running the subscription loop.
+const subscriptionContractExpiryBuffer types.BlockHeight = 144
+
 // minSubscriptionVersion is the min version required for a host to support the
 // subscription protocol.
 const minSubscriptionVersion = "1.5.5"
@@ -76,6 +95,11 @@ type (
 		// it currently has which it is not supposed to be subscribed to.
 		subscriptions map[modules.RegistryEntryID]*subscription
 
+		// maxSubscriptions is the maximum number of subscriptions that are
+		// kept active. Once there are more, the least recently used ones are
+		// evicted.
+		maxSubscriptions int
+
 		// staticWakeChan is a channel to tell the subscription loop that more
 		// work is available.
 		staticWakeChan chan struct{}
@@ -87,19 +111,51 @@ type (
 		cooldownUntil       time.Time
 		consecutiveFailures uint64
 
+		// atomicBackoff is the duration of the most recent cooldown. It's
+		// reset to 0 after a healthy session.
+		atomicBackoff int64
+
+		// session contains information about the active subscription session.
+		// It's nil if there is no active session.
+		session *subscriptionSession
+
+		// recentErr is the most recent error which interrupted a
+		// subscription session.
+		recentErr     error
+		recentErrTime time.Time
+
 		// utility fields
 		mu sync.Mutex
 	}
 
+	// subscriptionSession contains information about an active subscription
+	// session.
+	subscriptionSession struct {
+		// staticBudget is the budget of the session.
+		staticBudget *modules.RPCBudget
+
+		// staticStart is the time the session was started.
+		staticStart time.Time
+
+		// deadline is the time at which the session expires unless it is
+		// extended.
+		deadline time.Time
+
+		// funded is the total amount of money added to the budget of the
+		// session.
+		funded types.Currency
+	}
+
 	// subscription is a struct that provides additional information around a
 	// subscription.
 	subscription struct {
 		staticRequest *modules.RPCRegistrySubscriptionRequest
 
-		// subscribe indicates whether the subscription should be kept active.
-		// If it is 'true', the worker will try to resubscribe if the session is
+		// refs is the number of open subscription handles which reference
+		// the subscription. As long as it is not 0, the subscription should be
+		// kept active and the worker will try to resubscribe if the session is
 		// interrupted.
-		subscribe bool
+		refs uint64
 
 		// subscribed is closed as soon as the corresponding entry is subscribed
 		// to and indicates that the worker is actively listening for updates.
@@ -113,6 +169,27 @@ type (
 		// the host doesn't know the subscribed entry. If the host does know,
 		// the initial value should be set before closing 'subscribed'.
 		latestRV *modules.SignedRegistryValue
+
+		// lastUsed is the last time a notification for the entry arrived or
+		// its value was read from the registry subscription cache.
+		lastUsed time.Time
+
+		// evicted indicates that the subscription was evicted to stay within
+		// the worker's subscription limit. An evicted subscription is
+		// unsubscribed from even though it's still referenced and is
+		// subscribed to again as soon as its value is read.
+		evicted bool
+	}
+
+	// subscriptionHandle is returned by Subscribe and holds a reference to
+	// every subscription it was created for. Once all handles referencing a
+	// subscription are closed, the worker unsubscribes from the entry.
+	subscriptionHandle struct {
+		staticIDs    []modules.RegistryEntryID
+		staticWorker *worker
+
+		// closed is guarded by the mutex of the worker's subscriptionInfos.
+		closed bool
 	}
 
 	// notificationHandler is a helper type that contains some information
@@ -134,10 +211,16 @@ func newSubscription(request *modules.RPCRegistrySubscriptionRequest) *subscript
 	return &subscription{
 		staticRequest: request,
 		subscribed:    make(chan struct{}),
-		subscribe:     true,
+		lastUsed:      time.Now(),
 	}
 }
 
+// subscribe returns 'true' if the subscription is referenced by at least one
+// open handle and wasn't evicted and should therefore be kept active.
+func (sub *subscription) subscribe() bool {
+	return sub.refs > 0 && !sub.evicted
+}
+
 // active returns 'true' if the subscription is currently active. That means the
 // subscribed channel was closed after a successful subscription request.
 func (sub *subscription) active() bool {
@@ -204,7 +287,8 @@ func (nh *notificationHandler) managedHandleRegistryEntry(stream siamux.Stream,
 	// we are not interested in simply to have us pay for bandwidth.
 	subInfo.mu.Lock()
 	defer subInfo.mu.Unlock()
-	sub, exists := subInfo.subscriptions[modules.DeriveRegistryEntryID(sneu.PubKey, sneu.Entry.Tweak)]
+	sid := modules.DeriveRegistryEntryID(sneu.PubKey, sneu.Entry.Tweak)
+	sub, exists := subInfo.subscriptions[sid]
 	if !exists || (sub.latestRV != nil && sub.latestRV.Revision >= sneu.Entry.Revision) {
 		if exists && sub.latestRV != nil {
 			return fmt.Errorf("host sent an outdated revision %v >= %v", sub.latestRV.Revision, sneu.Entry.Revision)
@@ -214,6 +298,14 @@ func (nh *notificationHandler) managedHandleRegistryEntry(stream siamux.Stream,
 
 	// Update the subscription.
 	sub.latestRV = &sneu.Entry
+	sub.lastUsed = time.Now()
+
+	// Update the renter's cache and notify its subscribers if the
+	// subscription is active.
+	if sub.active() {
+		w.renter.staticRegistrySubscriptionCache.callUpdate(w.staticHostPubKeyStr, sid, sneu.Entry)
+		w.renter.staticRegistrySubscribers.callNotify(sid, sneu.Entry)
+	}
 	return nil
 }
 
@@ -292,12 +384,15 @@ func (nh *notificationHandler) managedHandleNotification(stream siamux.Stream, b
 	}
 }
 
-// managedClearSubscription replaces the channels of all subscriptions of the
-// subInfo. This unblocks anyone waiting for a subscription to be established.
-func (subInfo *subscriptionInfos) managedClearSubscriptions() {
+// managedClearSubscriptions replaces the channels of all subscriptions of the
+// worker. This unblocks anyone waiting for a subscription to be established.
+// Since the worker is no longer subscribed, the values are also invalidated in
+// the renter's registry subscription cache.
+func (w *worker) managedClearSubscriptions() {
+	subInfo := w.staticSubscriptionInfo
 	subInfo.mu.Lock()
 	defer subInfo.mu.Unlock()
-	for _, sub := range subInfo.subscriptions {
+	for sid, sub := range subInfo.subscriptions {
 		// Replace channels.
 		select {
 		case <-sub.subscribed:
@@ -305,23 +400,79 @@ func (subInfo *subscriptionInfos) managedClearSubscriptions() {
 			close(sub.subscribed)
 		}
 		sub.subscribed = make(chan struct{})
+		w.renter.staticRegistrySubscriptionCache.callRemoveSubscriber(w.staticHostPubKeyStr, sid)
 	}
 }
 
-// managedIncrementCooldown increments the subscription cooldown.
-func (subInfo *subscriptionInfos) managedIncrementCooldown() {
+// managedStartSession marks a new subscription session as active.
+func (subInfo *subscriptionInfos) managedStartSession(budget *modules.RPCBudget, deadline time.Time) {
 	subInfo.mu.Lock()
 	defer subInfo.mu.Unlock()
+	subInfo.session = &subscriptionSession{
+		staticBudget: budget,
+		staticStart:  time.Now(),
+		deadline:     deadline,
+		funded:       budget.Remaining(),
+	}
+}
 
-	// If the last cooldown ended a while ago, we reset the consecutive
-	// failures.
-	if time.Now().Sub(subInfo.cooldownUntil) > subscriptionCooldownResetInterval {
+// managedEndSession marks the active subscription session as inactive and
+// remembers the error that interrupted it. If the session lasted long enough,
+// the consecutive failures are reset.
+func (subInfo *subscriptionInfos) managedEndSession(err error) {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	if subInfo.session != nil && time.Since(subInfo.session.staticStart) >= subscriptionBackoffResetDuration {
 		subInfo.consecutiveFailures = 0
+		atomic.StoreInt64(&subInfo.atomicBackoff, 0)
+	}
+	subInfo.session = nil
+	if err != nil {
+		subInfo.recentErr = err
+		subInfo.recentErrTime = time.Now()
 	}
+}
 
-	// Increment the cooldown.
-	subInfo.cooldownUntil = cooldownUntil(subInfo.consecutiveFailures)
+// managedSessionFunded adds the funds to the active session's total funds.
+func (subInfo *subscriptionInfos) managedSessionFunded(funds types.Currency) {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	if subInfo.session != nil {
+		subInfo.session.funded = subInfo.session.funded.Add(funds)
+	}
+}
+
+// managedSessionExtended updates the deadline of the active session.
+func (subInfo *subscriptionInfos) managedSessionExtended(deadline time.Time) {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	if subInfo.session != nil {
+		subInfo.session.deadline = deadline
+	}
+}
+
+// managedIncrementCooldown increments the subscription cooldown.
+func (subInfo *subscriptionInfos) managedIncrementCooldown() {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	backoff := subscriptionBackoff(subInfo.consecutiveFailures)
+	subInfo.cooldownUntil = time.Now().Add(backoff)
 	subInfo.consecutiveFailures++
+	atomic.StoreInt64(&subInfo.atomicBackoff, int64(backoff))
+}
+
+// subscriptionBackoff returns the backoff after the given number of
+// consecutive failures. It starts at subscriptionBackoffMin and doubles for
+// every failure up to modules.SubscriptionPeriod.
+func subscriptionBackoff(consecutiveFailures uint64) time.Duration {
+	backoff := subscriptionBackoffMin
+	for i := uint64(0); i < consecutiveFailures && backoff < modules.SubscriptionPeriod; i++ {
+		backoff *= 2
+	}
+	if backoff > modules.SubscriptionPeriod {
+		backoff = modules.SubscriptionPeriod
+	}
+	return backoff
 }
 
 // managedOnCooldown returns whether the subscription cooldown is active and its
@@ -332,24 +483,120 @@ func (subInfo *subscriptionInfos) managedOnCooldown() (time.Duration, bool) {
 	return time.Until(subInfo.cooldownUntil), time.Now().Before(subInfo.cooldownUntil)
 }
 
+// managedMarkUsed updates the time the subscription for the entry with the
+// given id was last used. If the subscription was evicted, the subscription
+// loop is notified to subscribe to the entry again.
+func (subInfo *subscriptionInfos) managedMarkUsed(sid modules.RegistryEntryID) {
+	subInfo.mu.Lock()
+	sub, exists := subInfo.subscriptions[sid]
+	if !exists {
+		subInfo.mu.Unlock()
+		return
+	}
+	sub.lastUsed = time.Now()
+	evicted := sub.evicted
+	sub.evicted = false
+	subInfo.mu.Unlock()
+
+	// Notify the subscription loop of the change.
+	if evicted {
+		select {
+		case subInfo.staticWakeChan <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// managedSetMaxSubscriptions updates the maximum number of subscriptions that
+// are kept active and notifies the subscription loop of the change.
+func (subInfo *subscriptionInfos) managedSetMaxSubscriptions(max int) {
+	subInfo.mu.Lock()
+	subInfo.maxSubscriptions = max
+	subInfo.mu.Unlock()
+
+	select {
+	case subInfo.staticWakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// evictSubscriptions evicts the least recently used subscriptions until no
+// more than maxSubscriptions subscriptions are supposed to be active.
+// Subscriptions which are still being established and the ones in keep are
+// never evicted. The caller needs to hold the lock.
+func (subInfo *subscriptionInfos) evictSubscriptions(keep map[modules.RegistryEntryID]struct{}) {
+	if subInfo.maxSubscriptions <= 0 {
+		return // no limit
+	}
+	var n int
+	var candidates []*subscription
+	for sid, sub := range subInfo.subscriptions {
+		if !sub.subscribe() {
+			continue
+		}
+		n++
+		if _, exists := keep[sid]; exists || !sub.active() {
+			continue
+		}
+		candidates = append(candidates, sub)
+	}
+	if n <= subInfo.maxSubscriptions {
+		return
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed.Before(candidates[j].lastUsed)
+	})
+	for _, sub := range candidates {
+		if n <= subInfo.maxSubscriptions {
+			break
+		}
+		sub.evicted = true
+		n--
+	}
+}
+
+// managedNumSubscriptions returns the number of subscriptions the worker
+// keeps track of.
+func (subInfo *subscriptionInfos) managedNumSubscriptions() int {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	return len(subInfo.subscriptions)
+}
+
+// managedNumActiveSubscriptions returns the number of subscriptions which are
+// currently active.
+func (subInfo *subscriptionInfos) managedNumActiveSubscriptions() int {
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	var n int
+	for _, sub := range subInfo.subscriptions {
+		if sub.active() {
+			n++
+		}
+	}
+	return n
+}
+
 // managedSubscriptionDiff returns the difference between the desired
 // subscriptions and the active subscriptions. It also returns a slice of
 // channels which need to be closed when the corresponding desired subscription
-// was established.
+// was established. Subscriptions exceeding the subscription limit are evicted
+// before creating the diff.
 func (subInfo *subscriptionInfos) managedSubscriptionDiff() (toSubscribe, toUnsubscribe []modules.RPCRegistrySubscriptionRequest, subChans []chan struct{}) {
 	subInfo.mu.Lock()
 	defer subInfo.mu.Unlock()
+	subInfo.evictSubscriptions(nil)
 	for sid, sub := range subInfo.subscriptions {
-		if !sub.subscribe && !sub.active() {
+		if sub.refs == 0 && !sub.active() {
 			// Delete the subscription. We are neither supposed to subscribe
 			// to it nor are we subscribed to it.
 			delete(subInfo.subscriptions, sid)
 			// Close its channel.
 			close(sub.subscribed)
-		} else if sub.active() && !sub.subscribe {
+		} else if sub.active() && !sub.subscribe() {
 			// Unsubscribe from the entry.
 			toUnsubscribe = append(toUnsubscribe, *sub.staticRequest)
-		} else if !sub.active() && sub.subscribe {
+		} else if !sub.active() && sub.subscribe() {
 			// Subscribe and remember the channel to close it later.
 			toSubscribe = append(toSubscribe, *sub.staticRequest)
 			subChans = append(subChans, sub.subscribed)
@@ -398,12 +645,7 @@ func (w *worker) managedExtendSubscriptionPeriod(stream siamux.Stream, budget *m
 	}
 
 	// Count the number of active subscriptions.
-	var nSubs uint64
-	for _, sub := range subInfo.subscriptions {
-		if sub.active() {
-			nSubs++
-		}
-	}
+	nSubs := uint64(subInfo.managedNumActiveSubscriptions())
 
 	// Withdraw from budget.
 	if !budget.Withdraw(modules.MDMSubscriptionMemoryCost(newPT, nSubs)) {
@@ -439,6 +681,7 @@ func (w *worker) managedRefillSubscription(stream siamux.Stream, pt *modules.RPC
 	// that the withdrawal was successful.
 	budget.Deposit(fundAmt)
 	w.staticAccount.managedCommitWithdrawal(categorySubscription, fundAmt, types.ZeroCurrency, true)
+	w.staticSubscriptionInfo.managedSessionFunded(fundAmt)
 	return nil
 }
 
@@ -446,8 +689,6 @@ func (w *worker) managedRefillSubscription(stream siamux.Stream, pt *modules.RPC
 // that we would like to stop the subscription and resetting the subscription
 // related fields in the subscription info.
 func (w *worker) managedSubscriptionCleanup(stream siamux.Stream, subscriber string) (err error) {
-	subInfo := w.staticSubscriptionInfo
-
 	// Close the stream gracefully.
 	err = modules.RPCStopSubscription(stream)
 
@@ -463,7 +704,7 @@ func (w *worker) managedSubscriptionCleanup(stream siamux.Stream, subscriber str
 	err = errors.Compose(err, w.renter.staticMux.CloseListener(subscriber))
 
 	// Clear the active subscriptions at the end of this method.
-	subInfo.managedClearSubscriptions()
+	w.managedClearSubscriptions()
 	return err
 }
 
@@ -489,6 +730,7 @@ func (w *worker) managedUnsubscribeFromRVs(stream siamux.Stream, toUnsubscribe [
 			return err
 		}
 		sub.subscribed = make(chan struct{})
+		w.renter.staticRegistrySubscriptionCache.callRemoveSubscriber(w.staticHostPubKeyStr, sid)
 	}
 	return nil
 }
@@ -502,12 +744,16 @@ func (w *worker) managedSubscribeToRVs(stream siamux.Stream, toSubscribe []modul
 		return errors.AddContext(err, "failed to subscribe to registry values")
 	}
 	// Check that the initial values are not outdated and update the cache.
-	for _, rv := range rvs {
-		cachedRevision, exists := w.staticRegistryCache.Get(rv.PubKey, rv.Entry.Tweak)
-		if exists && rv.Entry.Revision < cachedRevision {
-			return fmt.Errorf("host returned an entry with revision %v which is smaller than cached revision %v for the same entry", rv.Entry.Revision, cachedRevision)
+	pubKeys := make(map[modules.RegistryEntryID]types.SiaPublicKey, len(toSubscribe))
+	for _, req := range toSubscribe {
+		pubKeys[modules.DeriveRegistryEntryID(req.PubKey, req.Tweak)] = req.PubKey
+	}
+	for sid, rv := range rvs {
+		cachedRevision, exists := w.staticRegistryCache.Get(pubKeys[sid], rv.Tweak)
+		if exists && rv.Revision < cachedRevision {
+			return fmt.Errorf("host returned an entry with revision %v which is smaller than cached revision %v for the same entry", rv.Revision, cachedRevision)
 		}
-		w.staticRegistryCache.Set(rv.PubKey, rv.Entry, false)
+		w.staticRegistryCache.Set(pubKeys[sid], rv, false)
 	}
 	// Withdraw from budget.
 	if !budget.Withdraw(modules.MDMSubscribeCost(pt, uint64(len(rvs)), uint64(len(toSubscribe)))) {
@@ -516,8 +762,11 @@ func (w *worker) managedSubscribeToRVs(stream siamux.Stream, toSubscribe []modul
 	// Update the subscriptions with the received values.
 	subInfo.mu.Lock()
 	defer subInfo.mu.Unlock()
-	for _, rv := range rvs {
-		subInfo.subscriptions[modules.DeriveRegistryEntryID(rv.PubKey, rv.Entry.Tweak)].latestRV = &rv.Entry
+	for sid, rv := range rvs {
+		rv := rv
+		subInfo.subscriptions[sid].latestRV = &rv
+		w.renter.staticRegistrySubscriptionCache.callUpdate(w.staticHostPubKeyStr, sid, rv)
+		w.renter.staticRegistrySubscribers.callNotify(sid, rv)
 	}
 	// Close the channels to signal that the subscription is done.
 	for _, c := range subChans {
@@ -565,6 +814,13 @@ func (w *worker) managedSubscriptionLoop(stream siamux.Stream, pt *modules.RPCPr
 	}
 
 	for {
+		// End the session if the worker is no longer good for
+		// subscriptions. The subscription loop will exit and be restarted
+		// by the cache update once the worker is good again.
+		if !w.staticCache().staticGoodForSubscription {
+			return nil
+		}
+
 		// If the budget is half empty, fund it.
 		if budget.Remaining().Cmp(expectedBudget.Div64(2)) < 0 {
 			err = w.managedRefillSubscription(stream, pt, expectedBudget, budget)
@@ -579,6 +835,7 @@ func (w *worker) managedSubscriptionLoop(stream siamux.Stream, pt *modules.RPCPr
 			if err != nil {
 				return err
 			}
+			w.staticSubscriptionInfo.managedSessionExtended(deadline)
 		}
 
 		// Create a diff between the active subscriptions and the desired
@@ -620,14 +877,27 @@ func (w *worker) managedSubscriptionLoop(stream siamux.Stream, pt *modules.RPCPr
 
 // managedPriceTableForSubscription will fetch a price table that is valid for
 // the provided duration. If the current price table of the worker isn't valid
-// for that long, it will change its update time to trigger an update.
+// for that long, it will try to pin a price table for the subscription. If that
+// fails, it will change its update time to trigger an update.
 func (w *worker) managedPriceTableForSubscription(duration time.Duration) *modules.RPCPriceTable {
+	// If the current price table isn't valid for long enough, pin a price
+	// table for the subscription instead of waiting for the next update. Hosts
+	// that don't support pinning fall back to waiting for the update.
+	pinSupported := build.VersionCmp(w.staticCache().staticHostVersion, minPinPriceTableVersion) >= 0
+	if pinSupported && !w.staticPriceTable().staticValidFor(duration, time.Now()) {
+		pt, err := w.managedPinPriceTable(modules.RPCRegistrySubscription, duration)
+		if err == nil {
+			return pt
+		}
+		w.renter.log.Debugf("managedPriceTableForSubscription: failed to pin price table for worker %v: %v", w.staticHostPubKeyStr, err)
+	}
+
 	for {
 		// Get most recent price table.
 		pt := w.staticPriceTable()
 
 		// If the price table is valid, return it.
-		if pt.staticValidFor(duration) {
+		if pt.staticValidFor(duration, time.Now()) {
 			return &pt.staticPriceTable
 		}
 
@@ -642,20 +912,23 @@ func (w *worker) managedPriceTableForSubscription(duration time.Duration) *modul
 		w.renter.log.Printf("managedPriceTableForSubscription: pt not ready yet for worker %v", w.staticHostPubKeyStr)
 
 		// Trigger an update by setting the update time to now and calling
-		// 'staticWake'.
-		newPT := *pt
-		newPT.staticUpdateTime = time.Time{}
-		oldPT := (*workerPriceTable)(atomic.SwapPointer(&w.atomicPriceTable, unsafe.Pointer(&newPT)))
-		w.staticWake()
-
-		// The old table's UID should be the same. Otherwise we just swapped out
-		// a new table and need to try again. This condition can be false when
-		// pricetable got updated between now and when we fetched it at the
-		// beginning of this iteration.
-		if oldPT.staticPriceTable.UID != pt.staticPriceTable.UID {
-			w.staticSetPriceTable(oldPT) // set back to the old one
-			continue
+		// 'staticWake'. If an update is already due, waking the worker is
+		// enough.
+		if !pt.staticNeedsRefresh(time.Now()) {
+			newPT := *pt
+			newPT.staticUpdateTime = time.Time{}
+			oldPT := (*workerPriceTable)(atomic.SwapPointer(&w.atomicPriceTable, unsafe.Pointer(&newPT)))
+
+			// The old table's UID should be the same. Otherwise we just
+			// swapped out a new table and need to try again. This condition
+			// can be false when pricetable got updated between now and when
+			// we fetched it at the beginning of this iteration.
+			if oldPT.staticPriceTable.UID != pt.staticPriceTable.UID {
+				w.staticSetPriceTable(oldPT) // set back to the old one
+				continue
+			}
 		}
+		w.staticWake()
 
 		// Wait a bit before checking again.
 		select {
@@ -689,17 +962,19 @@ func (w *worker) managedFundSubscription(stream siamux.Stream, pt *modules.RPCPr
 // threadedSubscriptionLoop is the main subscription loop. It opens a
 // subscription with the host and then calls managedSubscriptionLoop to keep the
 // subscription alive. If the subscription dies, threadedSubscriptionLoop will
-// start it again.
+// start it again. The loop exits if the worker isn't good for subscriptions and
+// is restarted by the worker's cache update once it is.
 func (w *worker) threadedSubscriptionLoop() {
 	if err := w.staticTG.Add(); err != nil {
 		return
 	}
 	defer w.staticTG.Done()
 
-	// No need to run loop if the host doesn't support it.
-	if build.VersionCmp(w.staticCache().staticHostVersion, minSubscriptionVersion) < 0 {
+	// Make sure only one loop is running at a time.
+	if !atomic.CompareAndSwapUint64(&w.atomicSubscriptionLoopRunning, 0, 1) {
 		return
 	}
+	defer atomic.StoreUint64(&w.atomicSubscriptionLoopRunning, 0)
 
 	// Disable loop if necessary.
 	if w.renter.deps.Disrupt("DisableSubscriptionLoop") {
@@ -711,7 +986,7 @@ func (w *worker) threadedSubscriptionLoop() {
 
 	for {
 		// Clear potential subscriptions before establishing a new loop.
-		subInfo.managedClearSubscriptions()
+		w.managedClearSubscriptions()
 
 		// Check for shutdown
 		select {
@@ -720,17 +995,24 @@ func (w *worker) threadedSubscriptionLoop() {
 		default:
 		}
 
+		// No need to run the loop if the host doesn't support it or the
+		// contract isn't good for renew or about to expire.
+		if !w.staticCache().staticGoodForSubscription {
+			return
+		}
+
 		// Nothing to do if there are no subscriptions.
-		subInfo.mu.Lock()
-		nSubs := len(subInfo.subscriptions)
-		subInfo.mu.Unlock()
-		if nSubs == 0 {
+		if subInfo.managedNumSubscriptions() == 0 {
 			select {
 			case <-subInfo.staticWakeChan:
 				// Wait for work
 			case <-w.staticTG.StopChan():
 				return // shutdown
 			}
+			// The worker might not be good for subscriptions anymore.
+			if !w.staticCache().staticGoodForSubscription {
+				return
+			}
 		}
 
 		// If the worker is on a cooldown, block until it is over before trying
@@ -771,13 +1053,20 @@ func (w *worker) threadedSubscriptionLoop() {
 
 			// Log error and increment cooldown.
 			w.renter.log.Printf("Worker %v: failed to begin subscription: %v", w.staticHostPubKeyStr, err)
+			subInfo.managedEndSession(errors.AddContext(err, "failed to begin subscription"))
 			subInfo.managedIncrementCooldown()
 			continue
 		}
 
 		// Run the subscription. The error is checked after closing the handler
 		// and the refund.
+		subInfo.managedStartSession(budget, deadline)
 		errSubscription := w.managedSubscriptionLoop(stream, pt, deadline, budget, initialBudget, subscriberStr)
+		if errors.Contains(errSubscription, threadgroup.ErrStopped) {
+			subInfo.managedEndSession(nil)
+		} else {
+			subInfo.managedEndSession(errSubscription)
+		}
 
 		// Commit the withdrawal now we know the refund.
 		refund := budget.Remaining()
@@ -788,7 +1077,7 @@ func (w *worker) threadedSubscriptionLoop() {
 		if errors.Contains(errSubscription, threadgroup.ErrStopped) {
 			return // shutdown
 		}
-		if err != nil {
+		if errSubscription != nil {
 			w.renter.log.Printf("Worker %v: subscription got interrupted: %v", w.staticHostPubKeyStr, errSubscription)
 			subInfo.managedIncrementCooldown()
 			continue
@@ -796,21 +1085,33 @@ func (w *worker) threadedSubscriptionLoop() {
 	}
 }
 
-// Unsubscribe marks the provided entries as not subscribed to and notifies the
-// worker of the change.
-func (w *worker) Unsubscribe(requests ...modules.RPCRegistrySubscriptionRequest) {
-	subInfo := w.staticSubscriptionInfo
+// managedMarkSubscriptionUsed marks the subscriptions of all workers for the
+// entry with the given id as used.
+func (r *Renter) managedMarkSubscriptionUsed(sid modules.RegistryEntryID) {
+	for _, w := range r.staticWorkerPool.callWorkers() {
+		w.staticSubscriptionInfo.managedMarkUsed(sid)
+	}
+}
+
+// Close releases the handle's references to its subscriptions and notifies the
+// worker of the change. Entries which are no longer referenced by any handle
+// are unsubscribed from. Closing a handle more than once is a no-op.
+func (h *subscriptionHandle) Close() {
+	subInfo := h.staticWorker.staticSubscriptionInfo
 
 	subInfo.mu.Lock()
 	defer subInfo.mu.Unlock()
-	for _, req := range requests {
-		sid := modules.DeriveRegistryEntryID(req.PubKey, req.Tweak)
+	if h.closed {
+		return // nothing to do
+	}
+	h.closed = true
+	for _, sid := range h.staticIDs {
 		sub, exists := subInfo.subscriptions[sid]
-		if !exists || !sub.subscribe {
-			continue // nothing to do
+		if !exists || sub.refs == 0 {
+			build.Critical("subscription handle references unknown subscription")
+			continue
 		}
-		// Mark the sub as no longer subscribed.
-		sub.subscribe = false
+		sub.refs--
 	}
 
 	// Notify the subscription loop of the changes.
@@ -822,12 +1123,35 @@ func (w *worker) Unsubscribe(requests ...modules.RPCRegistrySubscriptionRequest)
 
 // Subscribe marks the provided entries as subscribed and waits for the
 // subscription to be done, returning potential initial values returend by the
-// host.
-func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistrySubscriptionRequest) ([]modules.RPCRegistrySubscriptionNotificationEntryUpdate, error) {
+// host as well as a handle which needs to be closed to unsubscribe from the
+// entries again. Entries which are subscribed to by multiple callers stay
+// subscribed to until all of their handles are closed. Hosts with a skewed
+// clock are refused with errHostClockSkewed. If the worker exceeds its
+// subscription limit, the least recently used subscriptions of other callers
+// are evicted. They are subscribed to again once their values are read.
+func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistrySubscriptionRequest) (_ []modules.RPCRegistrySubscriptionNotificationEntryUpdate, _ *subscriptionHandle, err error) {
 	subInfo := w.staticSubscriptionInfo
 
-	// Add one subscription for every request that we are not yet subscribed to.
+	// Subscriptions rely on deadlines that assume the renter's and the host's
+	// clocks are roughly in sync. Avoid hosts with a skewed clock.
+	if w.staticPriceTable().staticClockSkewed() {
+		return nil, nil, errHostClockSkewed
+	}
+
+	// Add one subscription for every request that we are not yet subscribed to
+	// and reference all of them from a new handle.
+	handle := &subscriptionHandle{
+		staticWorker: w,
+	}
+	requested := make(map[modules.RegistryEntryID]struct{}, len(requests))
+	for _, req := range requests {
+		requested[modules.DeriveRegistryEntryID(req.PubKey, req.Tweak)] = struct{}{}
+	}
 	subInfo.mu.Lock()
+	if max := subInfo.maxSubscriptions; max > 0 && len(requested) > max {
+		subInfo.mu.Unlock()
+		return nil, nil, errors.AddContext(errTooManySubscriptions, fmt.Sprintf("%v > %v", len(requested), max))
+	}
 	var subs []*subscription
 	var subChans []chan struct{}
 	for i, req := range requests {
@@ -837,11 +1161,23 @@ func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistryS
 			sub = newSubscription(&requests[i])
 			subInfo.subscriptions[sid] = sub
 		}
+		sub.refs++
+		sub.lastUsed = time.Now()
+		sub.evicted = false
+		handle.staticIDs = append(handle.staticIDs, sid)
 		subs = append(subs, sub)
 		subChans = append(subChans, sub.subscribed)
 	}
+	subInfo.evictSubscriptions(requested)
 	subInfo.mu.Unlock()
 
+	// Release the references again if the subscription fails.
+	defer func() {
+		if err != nil {
+			handle.Close()
+		}
+	}()
+
 	// Notify the subscription loop of the changes.
 	select {
 	case subInfo.staticWakeChan <- struct{}{}:
@@ -853,9 +1189,9 @@ func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistryS
 		select {
 		case <-c:
 		case <-w.staticTG.StopChan():
-			return nil, threadgroup.ErrStopped // shutdown
+			return nil, nil, threadgroup.ErrStopped // shutdown
 		case <-ctx.Done():
-			return nil, errors.New("subscription timed out")
+			return nil, nil, errors.New("subscription timed out")
 		}
 	}
 
@@ -874,5 +1210,73 @@ func (w *worker) Subscribe(ctx context.Context, requests ...modules.RPCRegistryS
 			PubKey: sub.staticRequest.PubKey,
 		})
 	}
-	return notifications, nil
+	return notifications, handle, nil
+}
+
+// managedSubscribe adds a reference to the subscription for the entry with the
+// given public key and tweak and notifies the subscription loop. Unlike
+// Subscribe, it doesn't wait for the subscription to be established. Every
+// call needs to be matched by a call to managedUnsubscribe with the returned
+// id to release the reference again.
+func (w *worker) managedSubscribe(pk types.SiaPublicKey, tweak crypto.Hash) modules.RegistryEntryID {
+	subInfo := w.staticSubscriptionInfo
+	sid := modules.DeriveRegistryEntryID(pk, tweak)
+
+	subInfo.mu.Lock()
+	sub, exists := subInfo.subscriptions[sid]
+	if !exists {
+		sub = newSubscription(&modules.RPCRegistrySubscriptionRequest{
+			PubKey: types.SiaPublicKey{
+				Algorithm: pk.Algorithm,
+				Key:       append([]byte(nil), pk.Key...),
+			},
+			Tweak: tweak,
+		})
+		subInfo.subscriptions[sid] = sub
+	}
+	sub.refs++
+	sub.lastUsed = time.Now()
+	sub.evicted = false
+	subInfo.evictSubscriptions(map[modules.RegistryEntryID]struct{}{sid: {}})
+	subInfo.mu.Unlock()
+
+	// Notify the subscription loop of the change.
+	select {
+	case subInfo.staticWakeChan <- struct{}{}:
+	default:
+	}
+	return sid
+}
+
+// managedUnsubscribe releases a reference added by managedSubscribe. Once the
+// subscription is no longer referenced, the worker unsubscribes from the
+// entry.
+func (w *worker) managedUnsubscribe(sid modules.RegistryEntryID) {
+	subInfo := w.staticSubscriptionInfo
+
+	subInfo.mu.Lock()
+	sub, exists := subInfo.subscriptions[sid]
+	if !exists || sub.refs == 0 {
+		subInfo.mu.Unlock()
+		build.Critical("managedUnsubscribe called for unreferenced subscription")
+		return
+	}
+	sub.refs--
+	subInfo.mu.Unlock()
+
+	// Notify the subscription loop of the change.
+	select {
+	case subInfo.staticWakeChan <- struct{}{}:
+	default:
+	}
+}
+
+// managedIsSubscribed returns 'true' if the worker is currently subscribed to
+// the entry with the given id.
+func (w *worker) managedIsSubscribed(sid modules.RegistryEntryID) bool {
+	subInfo := w.staticSubscriptionInfo
+	subInfo.mu.Lock()
+	defer subInfo.mu.Unlock()
+	sub, exists := subInfo.subscriptions[sid]
+	return exists && sub.active()
 }
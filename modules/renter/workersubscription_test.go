@@ -4,11 +4,13 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"reflect"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 
 	"gitlab.com/NebulousLabs/errors"
 	"gitlab.com/NebulousLabs/fastrand"
@@ -17,6 +19,7 @@ import (
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/modules/host"
 	"go.sia.tech/siad/siatest/dependencies"
 	"go.sia.tech/siad/types"
 )
@@ -125,18 +128,12 @@ func TestSubscriptionHelpersWithWorker(t *testing.T) {
 	if len(initialValues) != 2 {
 		t.Fatal("wrong number of values", len(initialValues))
 	}
-	if !reflect.DeepEqual(initialValues[0].Entry, srv1) {
+	if !reflect.DeepEqual(initialValues[modules.DeriveRegistryEntryID(spk1, srv1.Tweak)], srv1) {
 		t.Fatal("wrong value")
 	}
-	if !reflect.DeepEqual(initialValues[1].Entry, srv3) {
+	if !reflect.DeepEqual(initialValues[modules.DeriveRegistryEntryID(spk3, srv3.Tweak)], srv3) {
 		t.Fatal("wrong value")
 	}
-	if !initialValues[0].PubKey.Equals(spk1) {
-		t.Fatal("wrong pubkey")
-	}
-	if !initialValues[1].PubKey.Equals(spk3) {
-		t.Fatal("wrong pubkey")
-	}
 
 	// Fund the budget a bit.
 	err = wt.managedFundSubscription(stream, pt, initialSubscriptionBudget.Div64(2))
@@ -253,6 +250,94 @@ func TestPriceTableForSubscription(t *testing.T) {
 	}
 }
 
+// TestSubscriptionPinnedPriceTable tests that a subscription survives the
+// expiry of the worker's regular price table by using pinned price tables.
+func TestSubscriptionPinnedPriceTable(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set a random entry on the host and subscribe to it.
+	rv, spk, sk := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := modules.RPCRegistrySubscriptionRequest{
+		PubKey: spk,
+		Tweak:  rv.Tweak,
+	}
+	_, _, err = wt.Subscribe(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Remember the subscription's channel. It is replaced if the subscription
+	// session is interrupted.
+	subInfo := wt.staticSubscriptionInfo
+	subInfo.mu.Lock()
+	sub := subInfo.subscriptions[modules.DeriveRegistryEntryID(spk, rv.Tweak)]
+	subscribed := sub.subscribed
+	subInfo.mu.Unlock()
+
+	// Expire the worker's price table and prevent the worker from updating
+	// it.
+	wpt := *wt.staticPriceTable()
+	wpt.staticExpiryTime = time.Now()
+	wpt.staticUpdateTime = time.Now().Add(time.Hour)
+	wt.staticSetPriceTable(&wpt)
+
+	// Wait for the subscription to be extended a few times.
+	time.Sleep(2 * modules.SubscriptionPeriod)
+
+	// The price table shouldn't have been updated and the subscription session
+	// shouldn't have been interrupted.
+	if wt.staticPriceTable().staticPriceTable.UID != wpt.staticPriceTable.UID {
+		t.Fatal("price table was updated")
+	}
+	subInfo.mu.Lock()
+	active := sub.active() && sub.subscribed == subscribed
+	subInfo.mu.Unlock()
+	if !active {
+		t.Fatal("subscription was interrupted")
+	}
+
+	// Update the entry on the host directly since the worker can't use its
+	// expired price table. The subscription should be notified.
+	rv.Revision++
+	rv = rv.Sign(sk)
+	_, err = wt.host.(*host.Host).RegistryUpdate(rv, spk, wt.staticCache().staticBlockHeight+100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		resps, handle, err := wt.Subscribe(context.Background(), req)
+		if err != nil {
+			return err
+		}
+		handle.Close()
+		if len(resps) != 1 || !reflect.DeepEqual(resps[0].Entry, rv) {
+			return errors.New("subscription wasn't notified")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 // TestSubscriptionLoop is a unit test for managedSubscriptionLoop. This
 // includes making sure that the loop will extend the subscription if necessary
 // and fund the budget if it runs low. It also tests that a subscription which
@@ -285,7 +370,7 @@ func TestSubscriptionLoop(t *testing.T) {
 
 	// The fresh price table should be valid for the subscription.
 	wpt := wt.staticPriceTable()
-	if !wpt.staticValidFor(modules.SubscriptionPeriod) {
+	if !wpt.staticValidFor(modules.SubscriptionPeriod, time.Now()) {
 		t.Fatal("price table not valid for long enough")
 	}
 	pt := &wpt.staticPriceTable
@@ -405,7 +490,7 @@ func TestSubscriptionLoop(t *testing.T) {
 			Tweak:  srv1.Tweak,
 		},
 		subscribed: make(chan struct{}),
-		subscribe:  true,
+		refs:       1,
 	}
 
 	srv2, spk2, _ := randomRegistryValue()
@@ -415,7 +500,7 @@ func TestSubscriptionLoop(t *testing.T) {
 			Tweak:  srv2.Tweak,
 		},
 		subscribed: make(chan struct{}),
-		subscribe:  true,
+		refs:       1,
 	}
 	subInfo.mu.Unlock()
 
@@ -467,10 +552,10 @@ func TestSubscriptionLoop(t *testing.T) {
 
 	// Remove the second subscription.
 	subInfo.mu.Lock()
-	subInfo.subscriptions[modules.DeriveRegistryEntryID(spk2, srv2.Tweak)].subscribe = false
+	subInfo.subscriptions[modules.DeriveRegistryEntryID(spk2, srv2.Tweak)].refs = 0
 
 	// Add a third subscription which should be removed automatically since
-	// "subscribe" is set to false from the beginning. Make sure the channel is
+	// it isn't referenced from the beginning. Make sure the channel is
 	// closed.
 	srv3, spk3, _ := randomRegistryValue()
 	sub3 := &subscription{
@@ -479,7 +564,6 @@ func TestSubscriptionLoop(t *testing.T) {
 			Tweak:  srv3.Tweak,
 		},
 		subscribed: make(chan struct{}),
-		subscribe:  false,
 	}
 	subInfo.subscriptions[modules.DeriveRegistryEntryID(spk2, srv2.Tweak)] = sub3
 	subInfo.mu.Unlock()
@@ -626,7 +710,7 @@ func TestSubscriptionNotifications(t *testing.T) {
 
 	// The fresh price table should be valid for the subscription.
 	wpt := wt.staticPriceTable()
-	if !wpt.staticValidFor(modules.SubscriptionPeriod) {
+	if !wpt.staticValidFor(modules.SubscriptionPeriod, time.Now()) {
 		t.Fatal("price table not valid for long enough")
 	}
 
@@ -660,7 +744,7 @@ func TestSubscriptionNotifications(t *testing.T) {
 	// Subscribe to both entries.
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
-	rvs, err := wt.Subscribe(ctx, []modules.RPCRegistrySubscriptionRequest{
+	rvs, handle, err := wt.Subscribe(ctx, []modules.RPCRegistrySubscriptionRequest{
 		{
 			PubKey: spk1,
 			Tweak:  rv1.Tweak,
@@ -704,13 +788,17 @@ func TestSubscriptionNotifications(t *testing.T) {
 	}
 	subInfo.mu.Unlock()
 
-	// Unsubscribe from rv1.
-	wt.Unsubscribe([]modules.RPCRegistrySubscriptionRequest{
-		{
-			PubKey: spk1,
-			Tweak:  rv1.Tweak,
-		},
-	}...)
+	// Subscribe to rv2 again and close the first handle to unsubscribe from
+	// rv1.
+	_, handle2, err := wt.Subscribe(ctx, modules.RPCRegistrySubscriptionRequest{
+		PubKey: spk2,
+		Tweak:  rv2.Tweak,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle2.Close()
+	handle.Close()
 
 	// The worker should eventually only have 1 subscription.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
@@ -1178,7 +1266,7 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 		PubKey: spk,
 		Tweak:  rv.Tweak,
 	}
-	resps, err := wt.Subscribe(context.Background(), req)
+	resps, handle, err := wt.Subscribe(context.Background(), req)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -1202,10 +1290,11 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 
 	// Do it again. This should return the same value. Since we are subscribed
 	// already this will not establish a new subscription.
-	resps, err = wt.Subscribe(context.Background(), req)
+	resps, handle2, err := wt.Subscribe(context.Background(), req)
 	if err != nil {
 		t.Fatal(err)
 	}
+	handle2.Close()
 	if len(resps) != 1 {
 		t.Fatal("invalid length", len(resps))
 	}
@@ -1226,10 +1315,11 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 
 	// Do it again. This should return the new value.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
-		resps, err = wt.Subscribe(context.Background(), req)
+		resps, handle3, err := wt.Subscribe(context.Background(), req)
 		if err != nil {
 			return err
 		}
+		handle3.Close()
 		if len(resps) != 1 {
 			return fmt.Errorf("invalid length %v", len(resps))
 		}
@@ -1246,7 +1336,7 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 	}
 
 	// Unsubscribe from the entry.
-	wt.Unsubscribe(req)
+	handle.Close()
 
 	// There should be 0 subscriptions.
 	err = build.Retry(100, 100*time.Millisecond, func() error {
@@ -1298,3 +1388,632 @@ func TestThreadedSubscriptionLoop(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestSubscriptionRefCounting tests that an entry which is subscribed to by two
+// callers stays subscribed to until both of them unsubscribed.
+func TestSubscriptionRefCounting(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set a random entry on the host.
+	rv, spk, sk := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscribe to the entry twice.
+	req := modules.RPCRegistrySubscriptionRequest{
+		PubKey: spk,
+		Tweak:  rv.Tweak,
+	}
+	_, handle1, err := wt.Subscribe(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, handle2, err := wt.Subscribe(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// There should be a single subscription with 2 references.
+	subInfo := wt.staticSubscriptionInfo
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+	subInfo.mu.Lock()
+	sub, exists := subInfo.subscriptions[sid]
+	if len(subInfo.subscriptions) != 1 || !exists || sub.refs != 2 {
+		subInfo.mu.Unlock()
+		t.Fatal("expected 1 subscription with 2 references")
+	}
+	subInfo.mu.Unlock()
+
+	// Close the first handle. Closing it twice shouldn't release the second
+	// reference.
+	handle1.Close()
+	handle1.Close()
+	subInfo.mu.Lock()
+	refs := sub.refs
+	subInfo.mu.Unlock()
+	if refs != 1 {
+		t.Fatal("expected 1 reference but got", refs)
+	}
+
+	// Update the entry on the host. The remaining subscriber should still
+	// receive the update.
+	rv.Revision++
+	rv = rv.Sign(sk)
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		subInfo.mu.Lock()
+		defer subInfo.mu.Unlock()
+		if !sub.active() {
+			return errors.New("subscription is no longer active")
+		}
+		if sub.latestRV == nil || !reflect.DeepEqual(*sub.latestRV, rv) {
+			return errors.New("subscription wasn't updated")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Close the second handle. The subscription should be removed.
+	handle2.Close()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		subInfo.mu.Lock()
+		defer subInfo.mu.Unlock()
+		if len(subInfo.subscriptions) != 0 {
+			return fmt.Errorf("expected 0 subscriptions but got %v", len(subInfo.subscriptions))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestSubscriptionRegistryCache tests that the values a worker receives through
+// a subscription are used to serve registry reads without contacting the host.
+func TestSubscriptionRegistryCache(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// Set a random entry on the host and subscribe to it.
+	rv, spk, sk := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, handle, err := wt.Subscribe(context.Background(), modules.RPCRegistrySubscriptionRequest{
+		PubKey: spk,
+		Tweak:  rv.Tweak,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The initial value should be cached.
+	sid := modules.DeriveRegistryEntryID(spk, rv.Tweak)
+	cachedRV, cached := r.staticRegistrySubscriptionCache.callGet(sid)
+	if !cached || !reflect.DeepEqual(cachedRV, rv) {
+		t.Fatal("initial value wasn't cached", cached)
+	}
+
+	// Update the entry. The notification should update the cache.
+	rv.Revision++
+	rv = rv.Sign(sk)
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		cachedRV, cached := r.staticRegistrySubscriptionCache.callGet(sid)
+		if !cached || !reflect.DeepEqual(cachedRV, rv) {
+			return errors.New("cache wasn't updated")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Put the worker's read registry queue on cooldown. That way the worker
+	// can't look up the entry on the host.
+	wt.staticJobReadRegistryQueue.mu.Lock()
+	wt.staticJobReadRegistryQueue.cooldownUntil = time.Now().Add(time.Hour)
+	wt.staticJobReadRegistryQueue.mu.Unlock()
+
+	// Reading the entry should still work since it is served from the cache.
+	readRV, err := r.ReadRegistry(spk, rv.Tweak, time.Second, modules.RegistryReadConsistencyFastest, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(readRV, rv) {
+		t.Fatal("wrong value returned")
+	}
+
+	// Unsubscribe. This should invalidate the cached value.
+	handle.Close()
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if _, cached := r.staticRegistrySubscriptionCache.callGet(sid); cached {
+			return errors.New("value wasn't invalidated")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reading the entry should fail now.
+	_, err = r.ReadRegistry(spk, rv.Tweak, time.Second, modules.RegistryReadConsistencyFastest, 0)
+	if !errors.Contains(err, modules.ErrNotEnoughWorkersInWorkerPool) {
+		t.Fatal("expected read to fail", err)
+	}
+}
+
+// TestEvictSubscriptions is a unit test for evictSubscriptions.
+func TestEvictSubscriptions(t *testing.T) {
+	t.Parallel()
+
+	// Create 5 active subscriptions, 1 pending subscription and 1 which is
+	// no longer referenced. The older subscriptions were used less recently.
+	subInfo := &subscriptionInfos{
+		subscriptions:    make(map[modules.RegistryEntryID]*subscription),
+		maxSubscriptions: 3,
+	}
+	var sids []modules.RegistryEntryID
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 7; i++ {
+		sub := newSubscription(&modules.RPCRegistrySubscriptionRequest{})
+		sub.lastUsed = start.Add(time.Duration(i) * time.Second)
+		if i != 5 {
+			close(sub.subscribed)
+		}
+		if i != 6 {
+			sub.refs = 1
+		}
+		var sid modules.RegistryEntryID
+		fastrand.Read(sid[:])
+		subInfo.subscriptions[sid] = sub
+		sids = append(sids, sid)
+	}
+
+	// The 3 least recently used active subscriptions which aren't kept should
+	// be evicted. The pending one and the unreferenced one are not.
+	keep := map[modules.RegistryEntryID]struct{}{
+		sids[0]: {},
+	}
+	subInfo.evictSubscriptions(keep)
+	for i, sid := range sids {
+		evicted := subInfo.subscriptions[sid].evicted
+		if evicted != (i == 1 || i == 2 || i == 3) {
+			t.Fatal("wrong eviction", i, evicted)
+		}
+	}
+
+	// The diff should contain the evicted subscriptions and the unreferenced
+	// one.
+	_, toUnsubscribe, _ := subInfo.managedSubscriptionDiff()
+	if len(toUnsubscribe) != 4 {
+		t.Fatal("wrong number of subscriptions to unsubscribe from", len(toUnsubscribe))
+	}
+
+	// Marking an evicted subscription as used restores it and updates the
+	// time it was last used.
+	subInfo.staticWakeChan = make(chan struct{}, 1)
+	before := time.Now()
+	subInfo.managedMarkUsed(sids[1])
+	sub := subInfo.subscriptions[sids[1]]
+	if sub.evicted || sub.lastUsed.Before(before) {
+		t.Fatal("subscription wasn't marked as used")
+	}
+	select {
+	case <-subInfo.staticWakeChan:
+	default:
+		t.Fatal("subscription loop wasn't notified")
+	}
+
+	// Without a limit nothing is evicted.
+	subInfo.maxSubscriptions = 0
+	for _, sub := range subInfo.subscriptions {
+		sub.evicted = false
+	}
+	subInfo.evictSubscriptions(nil)
+	for _, sub := range subInfo.subscriptions {
+		if sub.evicted {
+			t.Fatal("subscription shouldn't be evicted")
+		}
+	}
+}
+
+// TestSubscriptionEviction tests that a worker evicts the least recently used
+// subscriptions once it exceeds its subscription limit and that evicted
+// subscriptions are subscribed to again when they are read.
+func TestSubscriptionEviction(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := wt.rt.renter
+
+	// Limit the worker to a single subscription.
+	settings, err := r.Settings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.MaxSubscriptionsPerWorker = 1
+	if err := r.SetSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if status := wt.callStatus().SubscriptionStatus; status.MaxSubscriptions != 1 {
+		t.Fatal("limit wasn't applied", status.MaxSubscriptions)
+	}
+
+	// Set 2 entries on the host.
+	rv1, spk1, _ := randomRegistryValue()
+	rv2, spk2, _ := randomRegistryValue()
+	req1 := modules.RPCRegistrySubscriptionRequest{PubKey: spk1, Tweak: rv1.Tweak}
+	req2 := modules.RPCRegistrySubscriptionRequest{PubKey: spk2, Tweak: rv2.Tweak}
+	sid1 := modules.DeriveRegistryEntryID(spk1, rv1.Tweak)
+	sid2 := modules.DeriveRegistryEntryID(spk2, rv2.Tweak)
+	if err := wt.UpdateRegistry(context.Background(), spk1, rv1); err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.UpdateRegistry(context.Background(), spk2, rv2); err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscribing to both entries at once exceeds the limit.
+	_, _, err = wt.Subscribe(context.Background(), req1, req2)
+	if !errors.Contains(err, errTooManySubscriptions) {
+		t.Fatal("expected errTooManySubscriptions", err)
+	}
+
+	// Subscribe to them one after another. The first one should be evicted.
+	_, handle1, err := wt.Subscribe(context.Background(), req1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle1.Close()
+	_, handle2, err := wt.Subscribe(context.Background(), req2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer handle2.Close()
+
+	// expectSubscribed waits for the worker to be subscribed to the expected
+	// entry only.
+	subInfo := wt.staticSubscriptionInfo
+	expectSubscribed := func(subscribed, evicted modules.RegistryEntryID) {
+		t.Helper()
+		err := build.Retry(100, 100*time.Millisecond, func() error {
+			subInfo.mu.Lock()
+			active := subInfo.subscriptions[subscribed].active()
+			inactive := !subInfo.subscriptions[evicted].active() && subInfo.subscriptions[evicted].evicted
+			subInfo.mu.Unlock()
+			if !active || !inactive {
+				return fmt.Errorf("wrong subscriptions %v %v", active, inactive)
+			}
+			if _, cached := r.staticRegistrySubscriptionCache.callGet(subscribed); !cached {
+				return errors.New("subscribed value isn't cached")
+			}
+			if _, cached := r.staticRegistrySubscriptionCache.callGet(evicted); cached {
+				return errors.New("evicted value is still cached")
+			}
+			status := wt.callStatus().SubscriptionStatus
+			if status.NumSubscriptions != 1 || status.NumEvicted != 1 {
+				return fmt.Errorf("wrong status %v %v", status.NumSubscriptions, status.NumEvicted)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectSubscribed(sid2, sid1)
+
+	// Reading the evicted entry should still work and re-establish its
+	// subscription which evicts the other one.
+	readRV, err := r.ReadRegistry(spk1, rv1.Tweak, time.Second, modules.RegistryReadConsistencyFastest, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(readRV, rv1) {
+		t.Fatal("wrong value returned")
+	}
+	expectSubscribed(sid1, sid2)
+}
+
+// TestSubscriptionCounts is a unit test for managedNumSubscriptions and
+// managedNumActiveSubscriptions.
+func TestSubscriptionCounts(t *testing.T) {
+	t.Parallel()
+
+	// Create an active and a pending subscription.
+	subInfo := &subscriptionInfos{
+		subscriptions: make(map[modules.RegistryEntryID]*subscription),
+	}
+	_, spkActive, _ := randomRegistryValue()
+	_, spkPending, _ := randomRegistryValue()
+	active := newSubscription(&modules.RPCRegistrySubscriptionRequest{PubKey: spkActive, Tweak: crypto.Hash{1}})
+	close(active.subscribed)
+	pending := newSubscription(&modules.RPCRegistrySubscriptionRequest{PubKey: spkPending, Tweak: crypto.Hash{2}})
+	subInfo.subscriptions[modules.DeriveRegistryEntryID(spkActive, crypto.Hash{1})] = active
+	subInfo.subscriptions[modules.DeriveRegistryEntryID(spkPending, crypto.Hash{2})] = pending
+
+	// Both subscriptions are tracked but only one of them is active.
+	if n := subInfo.managedNumSubscriptions(); n != 2 {
+		t.Fatal("wrong number of subscriptions", n)
+	}
+	if n := subInfo.managedNumActiveSubscriptions(); n != 1 {
+		t.Fatal("wrong number of active subscriptions", n)
+	}
+}
+
+// TestManagedSubscribe tests that managedSubscribe and managedUnsubscribe
+// subscribe to and unsubscribe from entries without blocking.
+func TestManagedSubscribe(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Set a random entry on the host.
+	rv, spk, _ := randomRegistryValue()
+	err = wt.UpdateRegistry(context.Background(), spk, rv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Subscribe to the entry twice.
+	sid := wt.managedSubscribe(spk, rv.Tweak)
+	if sid != modules.DeriveRegistryEntryID(spk, rv.Tweak) {
+		t.Fatal("wrong subscription id")
+	}
+	if wt.managedSubscribe(spk, rv.Tweak) != sid {
+		t.Fatal("wrong subscription id")
+	}
+	subInfo := wt.staticSubscriptionInfo
+	subInfo.mu.Lock()
+	sub, exists := subInfo.subscriptions[sid]
+	if len(subInfo.subscriptions) != 1 || !exists || sub.refs != 2 {
+		subInfo.mu.Unlock()
+		t.Fatal("expected 1 subscription with 2 references")
+	}
+	subInfo.mu.Unlock()
+
+	// The subscription loop should subscribe to the entry.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if !wt.managedIsSubscribed(sid) {
+			return errors.New("not subscribed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Release the first reference. The worker should stay subscribed.
+	wt.managedUnsubscribe(sid)
+	time.Sleep(2 * subscriptionLoopInterval)
+	if !wt.managedIsSubscribed(sid) {
+		t.Fatal("worker should still be subscribed")
+	}
+
+	// Release the second reference. The subscription should be removed.
+	wt.managedUnsubscribe(sid)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if wt.managedIsSubscribed(sid) {
+			return errors.New("still subscribed")
+		}
+		subInfo.mu.Lock()
+		defer subInfo.mu.Unlock()
+		if len(subInfo.subscriptions) != 0 {
+			return fmt.Errorf("expected 0 subscriptions but got %v", len(subInfo.subscriptions))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown entries aren't subscribed to.
+	if wt.managedIsSubscribed(modules.RegistryEntryID{}) {
+		t.Fatal("unknown entry shouldn't be subscribed to")
+	}
+}
+
+// TestSubscriptionBackoff tests that the subscription backoff doubles with
+// every consecutive failure, is capped and is reset after a healthy session.
+func TestSubscriptionBackoff(t *testing.T) {
+	t.Parallel()
+
+	// Check the cap.
+	if subscriptionBackoff(0) != subscriptionBackoffMin {
+		t.Fatal("wrong initial backoff", subscriptionBackoff(0))
+	}
+	if subscriptionBackoff(2) != 4*subscriptionBackoffMin {
+		t.Fatal("backoff should double", subscriptionBackoff(2))
+	}
+	for _, failures := range []uint64{100, 1000, math.MaxUint64} {
+		if subscriptionBackoff(failures) != modules.SubscriptionPeriod {
+			t.Fatal("backoff should be capped", failures, subscriptionBackoff(failures))
+		}
+	}
+
+	subInfo := &subscriptionInfos{
+		subscriptions: make(map[modules.RegistryEntryID]*subscription),
+	}
+	backoff := func() time.Duration {
+		return time.Duration(atomic.LoadInt64(&subInfo.atomicBackoff))
+	}
+	fail := func() {
+		subInfo.managedStartSession(modules.NewBudget(types.ZeroCurrency), time.Now())
+		subInfo.managedEndSession(errors.New("failure"))
+		subInfo.managedIncrementCooldown()
+	}
+
+	// Fail a few times in a row.
+	for i := uint64(0); i < 3; i++ {
+		fail()
+		if backoff() != subscriptionBackoff(i) {
+			t.Fatal("wrong backoff", i, backoff())
+		}
+	}
+	if _, onCooldown := subInfo.managedOnCooldown(); !onCooldown {
+		t.Fatal("should be on cooldown")
+	}
+
+	// A session that lasted long enough resets the backoff.
+	subInfo.managedStartSession(modules.NewBudget(types.ZeroCurrency), time.Now())
+	subInfo.mu.Lock()
+	subInfo.session.staticStart = time.Now().Add(-subscriptionBackoffResetDuration)
+	subInfo.mu.Unlock()
+	subInfo.managedEndSession(errors.New("failure"))
+	if backoff() != 0 {
+		t.Fatal("backoff should be reset", backoff())
+	}
+	subInfo.managedIncrementCooldown()
+	if backoff() != subscriptionBackoffMin {
+		t.Fatal("wrong backoff after reset", backoff())
+	}
+}
+
+// TestThreadedSubscriptionLoopGoodForSubscription verifies that the
+// subscription loop exits once the worker isn't good for subscriptions and is
+// restarted by the cache update once it is good again.
+func TestThreadedSubscriptionLoopGoodForSubscription(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a worker.
+	wt, err := newWorkerTester(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := wt.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The worker's contract is good for renew and far from expiring so the
+	// loop should be running.
+	cache := wt.staticCache()
+	if !cache.staticGoodForSubscription {
+		t.Fatal("worker should be good for subscriptions")
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if atomic.LoadUint64(&wt.atomicSubscriptionLoopRunning) == 0 {
+			return errors.New("subscription loop isn't running")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mark the worker as not good for subscriptions and wake the loop. It
+	// should exit. The last update is set in the future to prevent the worker
+	// from updating the cache in the meantime.
+	newCache := *cache
+	newCache.staticGoodForSubscription = false
+	newCache.staticLastUpdate = time.Now().Add(time.Hour)
+	atomic.StorePointer(&wt.atomicCache, unsafe.Pointer(&newCache))
+	select {
+	case wt.staticSubscriptionInfo.staticWakeChan <- struct{}{}:
+	default:
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if atomic.LoadUint64(&wt.atomicSubscriptionLoopRunning) != 0 {
+			return errors.New("subscription loop is still running")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Starting the loop while the worker isn't good for subscriptions should
+	// exit right away.
+	done := make(chan struct{})
+	go func() {
+		wt.threadedSubscriptionLoop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("subscription loop didn't exit")
+	}
+
+	// Updating the cache should restart the loop.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		wt.managedUpdateCache()
+		if !wt.staticCache().staticGoodForSubscription {
+			return errors.New("worker isn't good for subscriptions")
+		}
+		if atomic.LoadUint64(&wt.atomicSubscriptionLoopRunning) == 0 {
+			return errors.New("subscription loop wasn't restarted")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
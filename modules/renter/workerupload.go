@@ -201,24 +201,39 @@ func (w *worker) managedPerformUploadChunkJob() {
 		return
 	}
 
+	// Repairs are throttled by the renter's repair ratelimit.
+	if uc.staticRepair {
+		err = w.staticThrottleRepairUpload(uc.physicalChunkData[pieceIndex])
+		if err != nil {
+			failureErr := errors.AddContext(err, "worker failed to wait for the repair ratelimit")
+			w.managedUploadFailed(uc, pieceIndex, failureErr)
+			return
+		}
+	}
+
 	// Perform the upload, and update the failure stats based on the success of
 	// the upload attempt.
 	//
 	// Ignore the error if it's a ErrMaxVirtualSectors coming from a pre-1.5.5
 	// host.
+	uploadStart := time.Now()
 	root, err := e.Upload(uc.physicalChunkData[pieceIndex])
+	uploadTime := time.Since(uploadStart)
 	ignoreErr := build.VersionCmp(hostSettings.Version, "1.5.5") < 0 && err != nil && strings.Contains(err.Error(), modules.ErrMaxVirtualSectors.Error())
 	if err != nil && !ignoreErr {
 		failureErr := fmt.Errorf("Worker failed to upload root %v via the editor: %v", root, err)
 		w.managedUploadFailed(uc, pieceIndex, failureErr)
 		return
 	}
+	w.staticAddBytesUploaded(uint64(len(uc.physicalChunkData[pieceIndex])))
+	w.managedAddUploadSpeedSample(uint64(len(uc.physicalChunkData[pieceIndex])), uploadTime)
 	w.mu.Lock()
 	w.uploadConsecutiveFailures = 0
 	w.mu.Unlock()
 
 	// Add piece to renterFile
 	err = uc.fileEntry.AddPiece(w.staticHostPubKey, uc.staticIndex, pieceIndex, root)
+	w.renter.staticUnfinishedChunkCache.callRemove(uc.id)
 	if err != nil {
 		failureErr := fmt.Errorf("Worker failed to add new piece to SiaFile: %v", err)
 		w.managedUploadFailed(uc, pieceIndex, failureErr)
@@ -239,6 +254,12 @@ func (w *worker) managedPerformUploadChunkJob() {
 	uc.chunkSuccessProcessTimes = append(uc.chunkSuccessProcessTimes, time.Now())
 	uc.mu.Unlock()
 	uc.staticMemoryManager.Return(uint64(releaseSize))
+	if uc.staticRepair {
+		w.renter.staticRepairStats.callAddBytes(modules.SectorSize)
+		w.renter.staticRepairMetrics.callAdd(time.Now(), modules.RepairMetrics{RepairBytesUploaded: uint64(releaseSize)})
+	} else {
+		w.renter.staticRepairMetrics.callAdd(time.Now(), modules.RepairMetrics{NewBytesUploaded: uint64(releaseSize)})
+	}
 	w.renter.managedCleanUpUploadChunk(uc)
 }
 
@@ -2,9 +2,11 @@ package modules
 
 import (
 	"encoding/json"
+	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"gitlab.com/NebulousLabs/fastrand"
 
@@ -163,6 +165,59 @@ func TestMaintenanceSpending_Sum(t *testing.T) {
 	}
 }
 
+// TestRenterContractMaxRoots is a small unit test for the MaxRoots and
+// Utilization methods on RenterContract.
+func TestRenterContractMaxRoots(t *testing.T) {
+	t.Parallel()
+
+	// Create a contract that holds 19 sectors and has enough host collateral
+	// left to back exactly one more sector for the remaining 10 blocks.
+	collateral := types.NewCurrency64(2)
+	sectorCollateral := collateral.Mul64(SectorSize).Mul64(10)
+	rc := RenterContract{
+		EndHeight: 20,
+		NumRoots:  19,
+		Transaction: types.Transaction{
+			FileContractRevisions: []types.FileContractRevision{{
+				NewFileSize: 19 * SectorSize,
+				NewMissedProofOutputs: []types.SiacoinOutput{
+					{Value: types.ZeroCurrency},
+					{Value: sectorCollateral},
+				},
+			}},
+		},
+	}
+	maxRoots := rc.MaxRoots(collateral, 10)
+	if maxRoots != 20 {
+		t.Fatalf("expected %v max roots but got %v", 20, maxRoots)
+	}
+	if u := rc.Utilization(maxRoots); u != 95 {
+		t.Fatalf("expected utilization of %v but got %v", 95, u)
+	}
+
+	// Add one more sector worth of collateral minus one hasting. The max roots
+	// shouldn't change.
+	rc.Transaction.FileContractRevisions[0].NewMissedProofOutputs[1].Value = sectorCollateral.Mul64(2).Sub64(1)
+	if maxRoots := rc.MaxRoots(collateral, 10); maxRoots != 20 {
+		t.Fatalf("expected %v max roots but got %v", 20, maxRoots)
+	}
+
+	// A contract that ended can't hold more roots than its size covers.
+	if maxRoots := rc.MaxRoots(collateral, 20); maxRoots != 19 {
+		t.Fatalf("expected %v max roots but got %v", 19, maxRoots)
+	}
+
+	// Hosts without collateral have no limit.
+	if maxRoots := rc.MaxRoots(types.ZeroCurrency, 10); maxRoots != math.MaxUint64 {
+		t.Fatalf("expected %v max roots but got %v", uint64(math.MaxUint64), maxRoots)
+	}
+
+	// Utilization of a contract without max roots is 0.
+	if u := rc.Utilization(0); u != 0 {
+		t.Fatalf("expected utilization of %v but got %v", 0, u)
+	}
+}
+
 // BenchmarkMerkleRootSetEncode clocks how fast large MerkleRootSets can be
 // encoded and written to disk.
 func BenchmarkMerkleRootSetEncode(b *testing.B) {
@@ -282,3 +337,23 @@ func BenchmarkSliceCryptoHashSave(b *testing.B) {
 		}
 	}
 }
+
+// TestRenterSyncStatusString tests the human readable representation of the
+// renter's sync status.
+func TestRenterSyncStatusString(t *testing.T) {
+	tests := []struct {
+		status   RenterSyncStatus
+		expected string
+	}{
+		{RenterSyncStatus{Synced: true, Progress: 100}, "synced"},
+		{RenterSyncStatus{Progress: 84}, "waiting for sync (84%)"},
+		{RenterSyncStatus{Progress: 84.2, ETA: 22 * time.Minute}, "waiting for sync (84%, ~22 min)"},
+		{RenterSyncStatus{Progress: 99.9, ETA: 10 * time.Second}, "waiting for sync (99%, <1 min)"},
+		{RenterSyncStatus{Progress: 10, ETA: 5 * time.Hour}, "waiting for sync (10%, ~5 h)"},
+	}
+	for _, test := range tests {
+		if s := test.status.String(); s != test.expected {
+			t.Errorf("expected %v but got %v", test.expected, s)
+		}
+	}
+}
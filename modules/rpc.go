@@ -76,6 +76,11 @@ type RPCPriceTable struct {
 	// to create valid withdrawal messages in case it is not synced yet.
 	HostBlockHeight types.BlockHeight `json:"hostblockheight"`
 
+	// HostTime is the unix timestamp of the host at the time the price table
+	// was created. This allows the renter to detect clock skew between itself
+	// and the host. Older hosts don't set this field.
+	HostTime int64 `json:"hosttime"`
+
 	// UpdatePriceTableCost refers to the cost of fetching a new price table
 	// from the host.
 	UpdatePriceTableCost types.Currency `json:"updatepricetablecost"`
@@ -164,6 +169,10 @@ type RPCPriceTable struct {
 	// contract.
 	MaxDuration types.BlockHeight `json:"maxduration"`
 
+	// MaxContractSize is the maximum size in bytes the host allows a single
+	// contract to grow to. 0 means that there is no limit.
+	MaxContractSize uint64 `json:"maxcontractsize"`
+
 	// WindowSize is the minimum time in blocks the host requests the
 	// renewWindow of a new contract to be.
 	WindowSize types.BlockHeight `json:"windowsize"`
@@ -180,6 +189,9 @@ var (
 	// RPCUpdatePriceTable specifier
 	RPCUpdatePriceTable = types.NewSpecifier("UpdatePriceTable")
 
+	// RPCPinPriceTable specifier
+	RPCPinPriceTable = types.NewSpecifier("PinPriceTable")
+
 	// RPCExecuteProgram specifier
 	RPCExecuteProgram = types.NewSpecifier("ExecuteProgram")
 
@@ -300,6 +312,14 @@ type (
 		PubKey types.SiaPublicKey
 	}
 
+	// RPCPinPriceTableRequest is the request sent by the renter to pin a price
+	// table for a longer validity. A pinned price table is only accepted by
+	// the host for the RPC it was pinned for.
+	RPCPinPriceTableRequest struct {
+		RPC      types.Specifier
+		Validity time.Duration
+	}
+
 	// RPCUpdatePriceTableResponse contains a JSON encoded RPC price table
 	RPCUpdatePriceTableResponse struct {
 		PriceTableJSON []byte
@@ -0,0 +1,84 @@
+package modules
+
+const (
+	// SelfTestCheckClock is the name of the self-test check that verifies
+	// that the system clock is sane.
+	SelfTestCheckClock = "clock"
+
+	// SelfTestCheckDiskSpace is the name of the self-test check that verifies
+	// that there is enough disk space for the requirements of the modules.
+	SelfTestCheckDiskSpace = "diskspace"
+
+	// SelfTestCheckPersistDir is the name of the self-test check that verifies
+	// that a persist directory is writable.
+	SelfTestCheckPersistDir = "persistdir"
+
+	// SelfTestCheckPort is the name of the self-test check that verifies that
+	// an address can be bound.
+	SelfTestCheckPort = "port"
+)
+
+const (
+	// SelfTestStatusFail means that the check failed and that siad is
+	// unlikely to work correctly until the issue is fixed.
+	SelfTestStatusFail = SelfTestStatus("fail")
+
+	// SelfTestStatusPass means that the check passed.
+	SelfTestStatusPass = SelfTestStatus("pass")
+
+	// SelfTestStatusWarn means that the check found a potential issue or that
+	// it couldn't be performed.
+	SelfTestStatusWarn = SelfTestStatus("warn")
+)
+
+type (
+	// SelfTestStatus is the outcome of a single self-test check.
+	SelfTestStatus string
+
+	// SelfTestRequirer is implemented by modules which have requirements
+	// towards their environment that the self-test should check in addition
+	// to the writability of their persist directory.
+	SelfTestRequirer interface {
+		SelfTestRequirements() SelfTestRequirements
+	}
+
+	// SelfTestRequirements describes what a module expects from its
+	// environment.
+	SelfTestRequirements struct {
+		// PersistDirs are directories which need to be writable.
+		PersistDirs []string
+
+		// DiskSpace is the disk space that needs to be available.
+		DiskSpace []DiskSpaceRequirement
+	}
+
+	// DiskSpaceRequirement is an amount of disk space which needs to be
+	// available on the filesystem of a directory.
+	DiskSpaceRequirement struct {
+		Dir    string
+		Bytes  uint64
+		Reason string
+	}
+
+	// SelfTestReport is the result of the self-test.
+	SelfTestReport struct {
+		// Passed is true if none of the checks failed.
+		Passed  bool             `json:"passed"`
+		Results []SelfTestResult `json:"results"`
+	}
+
+	// SelfTestResult is the result of a single check of the self-test.
+	SelfTestResult struct {
+		Check       string         `json:"check"`
+		Target      string         `json:"target"`
+		Status      SelfTestStatus `json:"status"`
+		Message     string         `json:"message"`
+		Remediation string         `json:"remediation,omitempty"`
+	}
+)
+
+// Merge adds the requirements of other to the requirements.
+func (str *SelfTestRequirements) Merge(other SelfTestRequirements) {
+	str.PersistDirs = append(str.PersistDirs, other.PersistDirs...)
+	str.DiskSpace = append(str.DiskSpace, other.DiskSpace...)
+}
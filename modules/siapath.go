@@ -49,6 +49,11 @@ var (
 	// siafiles are stored by default.
 	BackupFolder = NewGlobalSiaPath("/snapshots")
 
+	// ConversionsFolder is the Sia folder where the renter stores the siafiles
+	// of files whose erasure code is being changed until the conversion is
+	// complete.
+	ConversionsFolder = NewGlobalSiaPath("/conversions")
+
 	// HomeFolder is the Sia folder that is used to store all of the user
 	// accessible data.
 	HomeFolder = NewGlobalSiaPath("/home")
@@ -1,6 +1,9 @@
 package modules
 
 import (
+	"fmt"
+	"time"
+
 	"go.sia.tech/siad/crypto"
 )
 
@@ -12,6 +15,16 @@ const (
 	// StorageManagerDir is standard name used for the directory that contains
 	// all of the storage manager files.
 	StorageManagerDir = "storagemanager"
+
+	// MaxHasSectorsBatchSize is the maximum number of sectors which can be
+	// looked up with a single call to HasSectors.
+	MaxHasSectorsBatchSize = 10e3
+)
+
+var (
+	// ErrHasSectorsBatchTooLarge is returned by HasSectors if more than
+	// MaxHasSectorsBatchSize sectors are looked up at once.
+	ErrHasSectorsBatchTooLarge = fmt.Errorf("can't look up more than %v sectors at once", MaxHasSectorsBatchSize)
 )
 
 type (
@@ -34,6 +47,11 @@ type (
 		SuccessfulReads  uint64 `json:"successfulreads"`
 		SuccessfulWrites uint64 `json:"successfulwrites"`
 
+		// ReadOnly indicates that no new sectors are placed in the storage
+		// folder. Folders are marked read-only automatically if too many of
+		// their reads and writes fail.
+		ReadOnly bool `json:"readonly"`
+
 		// Certain operations on a storage folder can take a long time (Add,
 		// Remove, and Resize). The fields below indicate the progress of any
 		// long running operations that might be under way in the storage
@@ -42,6 +60,74 @@ type (
 		ProgressDenominator uint64
 	}
 
+	// StorageCapacity contains the storage accounting of the storage manager.
+	// RemainingRaw is the sum of the remaining capacity of all storage
+	// folders. RemainingAdjusted additionally subtracts the sectors that are
+	// queued to be added but haven't been assigned a slot in a storage folder
+	// yet. Sectors that are pending removal are not credited until the
+	// removal has been committed, which keeps the adjusted value
+	// conservative.
+	StorageCapacity struct {
+		Total             uint64 `json:"total"`             // bytes
+		RemainingRaw      uint64 `json:"remainingraw"`      // bytes
+		RemainingAdjusted uint64 `json:"remainingadjusted"` // bytes
+		PendingAdditions  uint64 `json:"pendingadditions"`  // sectors
+		PendingRemovals   uint64 `json:"pendingremovals"`   // sectors
+	}
+
+	// ReadCacheStatus contains information about the storage manager's
+	// in-memory sector read cache. The hits and misses can be used to size
+	// the cache.
+	ReadCacheStatus struct {
+		Capacity uint64 `json:"capacity"` // bytes
+		Size     uint64 `json:"size"`     // bytes
+		Hits     uint64 `json:"hits"`
+		Misses   uint64 `json:"misses"`
+	}
+
+	// StorageFolderStats contains the I/O statistics of a storage folder since
+	// the storage manager was started. SectorsRead and SectorsWritten are
+	// aggregated from the per-sector counters of the sectors currently stored
+	// in the folder.
+	StorageFolderStats struct {
+		Folder         string `json:"folder"`
+		SectorsRead    uint64 `json:"sectorsread"`
+		SectorsWritten uint64 `json:"sectorswritten"`
+		FailedReads    uint64 `json:"failedreads"`
+		FailedWrites   uint64 `json:"failedwrites"`
+	}
+
+	// OrphanedSectorsReport is the result of a single orphaned sector
+	// collection of the storage manager.
+	OrphanedSectorsReport struct {
+		// Flagged is the number of sectors which are currently flagged as
+		// orphaned.
+		Flagged uint64 `json:"flagged"`
+
+		// Deleted is the number of orphaned sectors which were deleted.
+		Deleted uint64 `json:"deleted"`
+	}
+
+	// SectorMetadataReport is the result of verifying the on-disk sector
+	// metadata of a storage folder.
+	SectorMetadataReport struct {
+		Folder string `json:"folder"`
+
+		// Unavailable indicates that the metadata of the folder couldn't be
+		// read.
+		Unavailable bool `json:"unavailable"`
+
+		// Checked is the number of entries which were verified.
+		Checked uint64 `json:"checked"`
+
+		// Corrupted is the number of entries with a bad checksum.
+		Corrupted uint64 `json:"corrupted"`
+
+		// Mismatched is the number of sectors whose entry doesn't match the
+		// sector location known to the storage manager.
+		Mismatched uint64 `json:"mismatched"`
+	}
+
 	// A StorageManager is responsible for managing storage folders and
 	// sectors. Sectors are the base unit of storage that gets moved between
 	// renters and hosts, and primarily is stored on the hosts.
@@ -62,6 +148,12 @@ type (
 		// a given root or not.
 		HasSector(crypto.Hash) bool
 
+		// HasSectors indicates for every provided root whether the contract
+		// manager stores the corresponding sector. All roots are looked up
+		// at once which is a lot faster than calling HasSector for each of
+		// them.
+		HasSectors([]crypto.Hash) ([]bool, error)
+
 		// AddSectorBatch is a performance optimization over AddSector when
 		// adding a bunch of virtual sectors. It is necessary because otherwise
 		// potentially thousands or even tens-of-thousands of fsync calls would
@@ -75,6 +167,17 @@ type (
 		// gracefully handle running out of storage unexpectedly.
 		AddStorageFolder(path string, size uint64) error
 
+		// CollectOrphanedSectors flags all sectors which are not referenced by
+		// the provided sector roots as orphaned. If deleteOrphans is true,
+		// sectors which remained orphaned for at least the provided delay are
+		// deleted.
+		CollectOrphanedSectors(referenced []crypto.Hash, deleteOrphans bool, delay time.Duration) (OrphanedSectorsReport, error)
+
+		// Capacity returns the total and remaining storage of the manager,
+		// accounting for sectors that are queued to be added or pending
+		// removal.
+		Capacity() StorageCapacity
+
 		// The storage manager needs to be able to shut down.
 		Close() error
 
@@ -86,6 +189,12 @@ type (
 		// requests to remove data.
 		DeleteSector(sectorRoot crypto.Hash) error
 
+		// MigrateStorageFolder moves all sectors of the storage folder at
+		// srcPath to the storage folder at dstPath. A sector is only removed
+		// from the source folder once it was written to the destination
+		// folder.
+		MigrateStorageFolder(srcPath, dstPath string) error
+
 		// ReadSector will read a sector from the storage manager, returning the
 		// bytes that match the input sector root.
 		ReadSector(sectorRoot crypto.Hash) ([]byte, error)
@@ -94,6 +203,16 @@ type (
 		// returning the bytes that match the input sector root.
 		ReadPartialSector(sectorRoot crypto.Hash, offset, length uint64) ([]byte, error)
 
+		// ReadPartialSectorWithProof will read a sector from the storage
+		// manager, returning the bytes that match the input sector root
+		// together with a merkle range proof. The range needs to be segment
+		// aligned.
+		ReadPartialSectorWithProof(sectorRoot crypto.Hash, offset, length uint64) ([]byte, []crypto.Hash, error)
+
+		// ReadCacheStatus returns the status of the in-memory sector read
+		// cache.
+		ReadCacheStatus() ReadCacheStatus
+
 		// RemoveSector will remove a sector from the storage manager. The
 		// height at which the sector expires should be provided, so that the
 		// auto-expiry information for that sector can be properly updated.
@@ -104,6 +223,10 @@ type (
 		// necessary when clearing out an entire contract from the host.
 		RemoveSectorBatch(sectorRoots []crypto.Hash) error
 
+		// SectorCount returns the number of unique sectors stored by the
+		// manager and the number of virtual sectors referencing them.
+		SectorCount() (physicalSectors, virtualSectors uint64)
+
 		// RemoveStorageFolder will remove a storage folder from the manager.
 		// All storage on the folder will be moved to other storage folders,
 		// meaning that no data will be lost. If the manager is unable to save
@@ -116,6 +239,10 @@ type (
 		// storage folder.
 		ResetStorageFolderHealth(index uint16) error
 
+		// SetStorageFolderReadOnly marks a storage folder as read-only or
+		// writable. No new sectors are placed in read-only storage folders.
+		SetStorageFolderReadOnly(index uint16, readOnly bool) error
+
 		// ResizeStorageFolder will grow or shrink a storage folder in the
 		// manager. The manager may not check that there is enough space
 		// on-disk to support growing the storage folder, but should gracefully
@@ -128,8 +255,21 @@ type (
 		// that data will be lost.
 		ResizeStorageFolder(index uint16, newSize uint64, force bool) error
 
+		// SetReadCacheSize sets the number of bytes the in-memory sector read
+		// cache may use. A size of 0 disables the cache.
+		SetReadCacheSize(size uint64)
+
 		// StorageFolders will return a list of storage folders tracked by the
 		// manager.
 		StorageFolders() []StorageFolderMetadata
+
+		// StorageFolderStats returns the I/O statistics of the storage
+		// folders tracked by the manager.
+		StorageFolderStats() []StorageFolderStats
+
+		// VerifySectorMetadata verifies the checksums of the on-disk sector
+		// metadata of all storage folders and compares it to the sector
+		// locations known to the manager.
+		VerifySectorMetadata() ([]SectorMetadataReport, error)
 	}
 )
@@ -127,10 +127,16 @@ type (
 		requiredUserAgent string
 		requiredPassword  string
 		Shutdown          func() error
+		SelfTest          func() modules.SelfTestReport
 		siadConfig        *modules.SiadConfig
 
 		staticStartTime time.Time
 
+		// staticStreamsClosed is closed by CloseStreams to interrupt the
+		// handlers which stream responses until the client disconnects.
+		staticStreamsClosed chan struct{}
+		closeStreamsOnce    sync.Once
+
 		staticDeps modules.Dependencies
 	}
 
@@ -218,8 +224,9 @@ func NewCustom(cfg *modules.SiadConfig, requiredUserAgent string, requiredPasswo
 		requiredPassword:  requiredPassword,
 		siadConfig:        cfg,
 
-		staticDeps:      deps,
-		staticStartTime: time.Now(),
+		staticDeps:          deps,
+		staticStartTime:     time.Now(),
+		staticStreamsClosed: make(chan struct{}),
 	}
 
 	// Register API handlers
@@ -228,6 +235,15 @@ func NewCustom(cfg *modules.SiadConfig, requiredUserAgent string, requiredPasswo
 	return api
 }
 
+// CloseStreams interrupts all handlers which stream their responses until the
+// client disconnects. It is registered as a shutdown hook of the http server
+// since the server waits for all active handlers to return on shutdown.
+func (api *API) CloseStreams() {
+	api.closeStreamsOnce.Do(func() {
+		close(api.staticStreamsClosed)
+	})
+}
+
 // UnrecognizedCallHandler handles calls to disabled/not-loaded modules.
 func (api *API) UnrecognizedCallHandler(w http.ResponseWriter, _ *http.Request) {
 	var errStr string
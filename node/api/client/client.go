@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -290,10 +291,18 @@ func (c *Client) postRawResponse(resource string, body io.Reader) (http.Header,
 // postRawResponseWithHeaders requests the specified resource and allows to pass
 // custom headers. The response, if provided, will be returned in a byte slice
 func (c *Client) postRawResponseWithHeaders(resource string, body io.Reader, headers http.Header) (http.Header, []byte, error) {
+	return c.postRawResponseWithContext(context.Background(), resource, body, headers)
+}
+
+// postRawResponseWithContext requests the specified resource with custom
+// headers. The request is cancelled when the provided context is done. The
+// response, if provided, will be returned in a byte slice
+func (c *Client) postRawResponseWithContext(ctx context.Context, resource string, body io.Reader, headers http.Header) (http.Header, []byte, error) {
 	req, err := c.NewRequest("POST", resource, body)
 	if err != nil {
 		return http.Header{}, nil, errors.AddContext(err, "failed to construct POST request")
 	}
+	req = req.WithContext(ctx)
 
 	// Decorate the headers on the request object
 	for k, v := range headers {
@@ -333,8 +342,15 @@ func (c *Client) postRawResponseWithHeaders(resource string, body io.Reader, hea
 // post makes a POST request to the resource at `resource`, using `data` as the
 // request body. The response, if provided, will be decoded into `obj`.
 func (c *Client) post(resource string, data string, obj interface{}) error {
+	return c.postWithContext(context.Background(), resource, data, obj)
+}
+
+// postWithContext is like post but cancels the request when the provided
+// context is done.
+func (c *Client) postWithContext(ctx context.Context, resource string, data string, obj interface{}) error {
 	// Request resource
-	_, body, err := c.postRawResponse(resource, strings.NewReader(data))
+	headers := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
+	_, body, err := c.postRawResponseWithContext(ctx, resource, strings.NewReader(data), headers)
 	if err != nil {
 		return err
 	}
@@ -24,6 +24,13 @@ func (c *Client) DaemonAlertsGet() (dag api.DaemonAlertsGet, err error) {
 	return
 }
 
+// DaemonSelfTestPost uses the /daemon/selftest endpoint to run the daemon's
+// self-test.
+func (c *Client) DaemonSelfTestPost() (dstp api.DaemonSelfTestPOST, err error) {
+	err = c.post("/daemon/selftest", "", &dstp)
+	return
+}
+
 // DaemonVersionGet requests the /daemon/version resource.
 func (c *Client) DaemonVersionGet() (dvg api.DaemonVersionGet, err error) {
 	err = c.get("/daemon/version", &dvg)
@@ -1,9 +1,13 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/url"
 	"strconv"
+	"time"
 
 	"gitlab.com/NebulousLabs/errors"
 
@@ -27,7 +31,19 @@ func (c *Client) GatewayBandwidthGet() (gbg api.GatewayBandwidthGET, err error)
 // GatewayConnectPost uses the /gateway/connect/:address endpoint to connect to
 // the gateway at address
 func (c *Client) GatewayConnectPost(address modules.NetAddress) (err error) {
-	err = c.post("/gateway/connect/"+string(address), "", nil)
+	return c.GatewayConnectPostWithContext(context.Background(), address)
+}
+
+// GatewayConnectPostWithContext uses the /gateway/connect/:address endpoint to
+// connect to the gateway at address. If the context has a deadline, siad is
+// asked to give up on the connection attempt once the deadline is reached.
+func (c *Client) GatewayConnectPostWithContext(ctx context.Context, address modules.NetAddress) (err error) {
+	resource := "/gateway/connect/" + string(address)
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout := math.Max(1, math.Ceil(time.Until(deadline).Seconds()))
+		resource += fmt.Sprintf("?timeout=%v", uint64(timeout))
+	}
+	err = c.postWithContext(ctx, resource, "", nil)
 	if err != nil && errors.Contains(err, ErrPeerExists) {
 		err = ErrPeerExists
 	}
@@ -49,6 +49,8 @@ const (
 	// HostParamMaxDownloadBatchSize is the maximum size of the download batch
 	// size in bytes.
 	HostParamMaxDownloadBatchSize = HostParam("maxdownloadbatchsize")
+	// HostParamMaxContractSize is the max size of a contract in bytes.
+	HostParamMaxContractSize = HostParam("maxcontractsize")
 	// HostParamMaxReviseBatchSize is the maximum size of the revise batch size.
 	HostParamMaxReviseBatchSize = HostParam("maxrevisebatchsize")
 	// HostParamNetAddress is the announced netaddress of the host.
@@ -68,6 +70,18 @@ const (
 	// HostParamCustomRegistryPath is the locataion of the host's registry on
 	// disk.
 	HostParamCustomRegistryPath = HostParam("customregistrypath")
+	// HostParamReadCacheSize is the number of bytes the host may use to cache
+	// recently read sectors in memory.
+	HostParamReadCacheSize = HostParam("readcachesize")
+	// HostParamOrphanedSectorDeletion enables the deletion of sectors which
+	// aren't referenced by any storage obligation.
+	HostParamOrphanedSectorDeletion = HostParam("orphanedsectordeletion")
+	// HostParamOrphanedSectorDeletionDelay is the number of seconds a sector
+	// needs to remain orphaned before it is deleted.
+	HostParamOrphanedSectorDeletionDelay = HostParam("orphanedsectordeletiondelay")
+	// HostParamMaxPinnedPriceTableValidity is the maximum number of seconds a
+	// price table pinned by a renter is valid for.
+	HostParamMaxPinnedPriceTableValidity = HostParam("maxpinnedpricetablevalidity")
 )
 
 // HostAnnouncePost uses the /host/announce endpoint to announce the host to
@@ -84,6 +98,32 @@ func (c *Client) HostAnnounceAddrPost(address modules.NetAddress) (err error) {
 	return
 }
 
+// HostAnnounceForcePost uses the /host/announce endpoint to announce the host
+// to the network even if the pre-announcement checks fail. If the address is
+// empty, the host's default address is announced.
+func (c *Client) HostAnnounceForcePost(address modules.NetAddress) (err error) {
+	values := url.Values{}
+	values.Set("force", "true")
+	if address != "" {
+		values.Set("netaddress", string(address))
+	}
+	err = c.post("/host/announce", values.Encode(), nil)
+	return
+}
+
+// HostAnnounceDryRunPost uses the /host/announce endpoint to perform the
+// pre-announcement checks for an address without announcing it. If the
+// address is empty, the host's default address is checked.
+func (c *Client) HostAnnounceDryRunPost(address modules.NetAddress) (checks modules.HostAnnouncementChecks, err error) {
+	values := url.Values{}
+	values.Set("dryrun", "true")
+	if address != "" {
+		values.Set("netaddress", string(address))
+	}
+	err = c.post("/host/announce", values.Encode(), &checks)
+	return
+}
+
 // HostContractInfoGet uses the /host/contracts endpoint to get information
 // about contracts on the host.
 func (c *Client) HostContractInfoGet() (cg api.ContractInfoGET, err error) {
@@ -123,6 +163,21 @@ func (c *Client) HostBandwidthGet() (gbg api.GatewayBandwidthGET, err error) {
 	return
 }
 
+// HostMDMGet requests the /host/mdm endpoint.
+func (c *Client) HostMDMGet() (hmg api.HostMDMGET, err error) {
+	err = c.get("/host/mdm", &hmg)
+	return
+}
+
+// HostRentersGet requests the /host/renters endpoint. If anonymize is set, the
+// renters' keys are replaced by a hash.
+func (c *Client) HostRentersGet(anonymize bool) (hrg api.HostRentersGET, err error) {
+	values := url.Values{}
+	values.Set("anonymize", fmt.Sprint(anonymize))
+	err = c.get("/host/renters?"+values.Encode(), &hrg)
+	return
+}
+
 // HostStorageFoldersAddPost uses the /host/storage/folders/add api endpoint to
 // add a storage folder to a host
 func (c *Client) HostStorageFoldersAddPost(path string, size uint64) (err error) {
@@ -143,6 +198,16 @@ func (c *Client) HostStorageFoldersRemovePost(path string, force bool) (err erro
 	return
 }
 
+// HostStorageFoldersReadOnlyPost uses the /host/storage/folders/readonly api
+// endpoint to mark a storage folder as read-only or writable.
+func (c *Client) HostStorageFoldersReadOnlyPost(path string, readOnly bool) (err error) {
+	values := url.Values{}
+	values.Set("path", path)
+	values.Set("readonly", strconv.FormatBool(readOnly))
+	err = c.post("/host/storage/folders/readonly", values.Encode(), nil)
+	return
+}
+
 // HostStorageFoldersResizePost uses the /host/storage/folders/resize api
 // endpoint to resize an existing storage folder.
 func (c *Client) HostStorageFoldersResizePost(path string, size uint64) (err error) {
@@ -153,12 +218,28 @@ func (c *Client) HostStorageFoldersResizePost(path string, size uint64) (err err
 	return
 }
 
+// HostStorageMigratePost uses the /host/storage/migrate api endpoint to move
+// all sectors of the storage folder at src to the storage folder at dst.
+func (c *Client) HostStorageMigratePost(src, dst string) (err error) {
+	values := url.Values{}
+	values.Set("source", src)
+	values.Set("destination", dst)
+	err = c.post("/host/storage/migrate", values.Encode(), nil)
+	return
+}
+
 // HostStorageGet requests the /host/storage endpoint.
 func (c *Client) HostStorageGet() (sg api.StorageGET, err error) {
 	err = c.get("/host/storage", &sg)
 	return
 }
 
+// HostStorageStatsGet requests the /host/storage/stats endpoint.
+func (c *Client) HostStorageStatsGet() (ssg api.StorageStatsGET, err error) {
+	err = c.get("/host/storage/stats", &ssg)
+	return
+}
+
 // HostStorageSectorsDeletePost uses the /host/storage/sectors/delete endpoint
 // to delete a sector from the host.
 func (c *Client) HostStorageSectorsDeletePost(root crypto.Hash) (err error) {
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
@@ -12,6 +13,7 @@ import (
 
 	"gitlab.com/NebulousLabs/errors"
 
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node/api"
 	"go.sia.tech/siad/types"
@@ -365,6 +367,41 @@ func (c *Client) RenterDownloadFullGet(siaPath modules.SiaPath, destination stri
 	return modules.DownloadID(h.Get("ID")), nil
 }
 
+// RenterDownloadGroupGet uses the /renter/download endpoint to download a full
+// file and adds the download to the provided download group.
+func (c *Client) RenterDownloadGroupGet(siaPath modules.SiaPath, destination, group string, async, root bool) (modules.DownloadID, error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("destination", destination)
+	values.Set("group", group)
+	values.Set("httpresp", fmt.Sprint(false))
+	values.Set("async", fmt.Sprint(async))
+	values.Set("root", fmt.Sprint(root))
+	h, _, err := c.getRawResponse(fmt.Sprintf("/renter/download/%s?%s", sp, values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	return modules.DownloadID(h.Get("ID")), nil
+}
+
+// RenterDownloadGroupsGet requests the /renter/downloadgroups resource.
+func (c *Client) RenterDownloadGroupsGet() (rdg api.RenterDownloadGroupsGET, err error) {
+	err = c.get("/renter/downloadgroups", &rdg)
+	return
+}
+
+// RenterDownloadGroupAwaitGet requests the /renter/downloadgroups/await
+// resource which blocks until the download group finished, any of its
+// downloads failed if failFast is set, or the timeout is reached.
+func (c *Client) RenterDownloadGroupAwaitGet(group string, failFast bool, timeout time.Duration) (dgi modules.DownloadGroupInfo, err error) {
+	values := url.Values{}
+	values.Set("group", group)
+	values.Set("failfast", fmt.Sprint(failFast))
+	values.Set("timeout", fmt.Sprint(uint64(timeout.Seconds())))
+	err = c.get("/renter/downloadgroups/await?"+values.Encode(), &dgi)
+	return
+}
+
 // RenterClearAllDownloadsPost requests the /renter/downloads/clear resource
 // with no parameters
 func (c *Client) RenterClearAllDownloadsPost() (err error) {
@@ -430,6 +467,32 @@ func (c *Client) RenterDownloadHTTPResponseGet(siaPath modules.SiaPath, offset,
 	return modules.DownloadID(h.Get("ID")), resp, nil
 }
 
+// RenterDownloadByRootGet uses the /renter/downloadbyroot endpoint to download
+// the range [offset, offset+length) of the sector with the given root.
+func (c *Client) RenterDownloadByRootGet(root crypto.Hash, offset, length uint64) ([]byte, error) {
+	values := url.Values{}
+	values.Set("offset", fmt.Sprint(offset))
+	values.Set("length", fmt.Sprint(length))
+	_, data, err := c.getRawResponse(fmt.Sprintf("/renter/downloadbyroot/%s?%s", root, values.Encode()))
+	return data, err
+}
+
+// RenterDownloadHTTPResponseToFileGet uses the /renter/download endpoint to
+// download a file, writing it to the destination on disk and returning its data
+// at the same time.
+func (c *Client) RenterDownloadHTTPResponseToFileGet(siaPath modules.SiaPath, destination string, root bool) (modules.DownloadID, []byte, error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("destination", destination)
+	values.Set("httpresp", fmt.Sprint(true))
+	values.Set("root", fmt.Sprint(root))
+	h, resp, err := c.getRawResponse(fmt.Sprintf("/renter/download/%s?%s", sp, values.Encode()))
+	if err != nil {
+		return "", nil, err
+	}
+	return modules.DownloadID(h.Get("ID")), resp, nil
+}
+
 // RenterFileRootGet uses the /renter/file/:siapath endpoint to query a file.
 // It passes the `root=true` flag to indicate an absolute path.
 func (c *Client) RenterFileRootGet(siaPath modules.SiaPath) (rf api.RenterFile, err error) {
@@ -445,6 +508,22 @@ func (c *Client) RenterFileGet(siaPath modules.SiaPath) (rf api.RenterFile, err
 	return
 }
 
+// RenterFileChunksGet uses the /renter/file/:siapath endpoint to query a file
+// including debugging information about its chunks.
+func (c *Client) RenterFileChunksGet(siaPath modules.SiaPath) (rf api.RenterFile, err error) {
+	sp := escapeSiaPath(siaPath)
+	err = c.get("/renter/file/"+sp+"?chunks=true", &rf)
+	return
+}
+
+// RenterFileRepairCostGet uses the /renter/file/:siapath endpoint to query a
+// file including the estimated cost of repairing it.
+func (c *Client) RenterFileRepairCostGet(siaPath modules.SiaPath) (rf api.RenterFile, err error) {
+	sp := escapeSiaPath(siaPath)
+	err = c.get("/renter/file/"+sp+"?repaircost=true", &rf)
+	return
+}
+
 // RenterFilesGet requests the /renter/files resource.
 func (c *Client) RenterFilesGet(cached bool) (rf api.RenterFiles, err error) {
 	err = c.get("/renter/files?cached="+fmt.Sprint(cached), &rf)
@@ -472,6 +551,105 @@ func (c *Client) RenterPostAllowance(allowance modules.Allowance) error {
 	return a.Send()
 }
 
+// RenterAccountsGet uses the /renter/accounts endpoint to get information
+// about the renter's ephemeral accounts.
+func (c *Client) RenterAccountsGet() (rag api.RenterAccountsGET, err error) {
+	err = c.get("/renter/accounts", &rag)
+	return
+}
+
+// RenterBandwidthGet uses the /renter/bandwidth endpoint to get the current
+// upload and download bandwidth of the renter's workers.
+func (c *Client) RenterBandwidthGet() (rb modules.RenterBandwidth, err error) {
+	err = c.get("/renter/bandwidth", &rb)
+	return
+}
+
+// RenterRepairMetricsGet uses the /renter/repairmetrics endpoint to get the
+// renter's repair metrics.
+func (c *Client) RenterRepairMetricsGet() (rrm modules.RenterRepairMetrics, err error) {
+	err = c.get("/renter/repairmetrics", &rrm)
+	return
+}
+
+// RenterRepairStatusGet uses the /renter/repairstatus endpoint to get the state
+// of the renter's repair loop.
+func (c *Client) RenterRepairStatusGet() (rrs modules.RenterRepairStatus, err error) {
+	err = c.get("/renter/repairstatus", &rrs)
+	return
+}
+
+// RenterRegistryGet uses the /renter/registry endpoint to look up a registry
+// entry on the renter's hosts. A quorum of 0 uses the renter's default.
+func (c *Client) RenterRegistryGet(spk types.SiaPublicKey, dataKey crypto.Hash, timeout time.Duration, consistency modules.RegistryReadConsistency, quorum float64) (rrg api.RenterRegistryGET, err error) {
+	values := url.Values{}
+	values.Set("publickey", spk.String())
+	values.Set("datakey", dataKey.String())
+	values.Set("timeout", fmt.Sprint(uint64(timeout.Seconds())))
+	values.Set("consistency", string(consistency))
+	if quorum != 0 {
+		values.Set("quorum", fmt.Sprint(quorum))
+	}
+	err = c.get("/renter/registry?"+values.Encode(), &rrg)
+	return
+}
+
+// RenterRegistrySubscription is a subscription to a registry entry created by
+// RenterRegistrySubscribeGet.
+type RenterRegistrySubscription struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// Next blocks until the next update of the registry entry is received.
+func (rs *RenterRegistrySubscription) Next() (rrg api.RenterRegistryGET, err error) {
+	err = rs.dec.Decode(&rrg)
+	return
+}
+
+// Close cancels the subscription.
+func (rs *RenterRegistrySubscription) Close() error {
+	return rs.body.Close()
+}
+
+// RenterRegistrySubscribeGet uses the /renter/registry/subscribe endpoint to
+// subscribe to updates of a registry entry. The subscription needs to be closed
+// by the caller.
+func (c *Client) RenterRegistrySubscribeGet(spk types.SiaPublicKey, dataKey crypto.Hash) (*RenterRegistrySubscription, error) {
+	values := url.Values{}
+	values.Set("publickey", spk.String())
+	values.Set("datakey", dataKey.String())
+	_, body, err := c.getReaderResponse("/renter/registry/subscribe?" + values.Encode())
+	if err != nil {
+		return nil, err
+	}
+	return &RenterRegistrySubscription{
+		body: body,
+		dec:  json.NewDecoder(body),
+	}, nil
+}
+
+// RenterDirHeapResetPost uses the /renter/dirheap/reset endpoint to force a
+// re-initialization of the renter's directory heap.
+func (c *Client) RenterDirHeapResetPost() (err error) {
+	err = c.post("/renter/dirheap/reset", "", nil)
+	return
+}
+
+// RenterDebugStuckCursorGet uses the /renter/debug/stuckcursor endpoint to get
+// the recent stuck repair attempts of the renter's stuck loop.
+func (c *Client) RenterDebugStuckCursorGet() (rsc api.RenterStuckCursorGET, err error) {
+	err = c.get("/renter/debug/stuckcursor", &rsc)
+	return
+}
+
+// RenterAccountGet uses the /renter/account/:hostpubkey endpoint to get
+// information about the renter's ephemeral account with the given host.
+func (c *Client) RenterAccountGet(hostKey types.SiaPublicKey) (ra modules.RenterAccount, err error) {
+	err = c.get("/renter/account/"+hostKey.String(), &ra)
+	return
+}
+
 // RenterAllowanceCancelPost uses the /renter/allowance/cancel endpoint to cancel
 // the allowance.
 func (c *Client) RenterAllowanceCancelPost() (err error) {
@@ -487,6 +665,14 @@ func (c *Client) RenterPricesGet(allowance modules.Allowance) (rpg api.RenterPri
 	return
 }
 
+// RenterPricesUploadCostGet requests the /renter/prices endpoint with a size and
+// redundancy to estimate the cost of uploading a file.
+func (c *Client) RenterPricesUploadCostGet(size uint64, redundancy float64) (rpg api.RenterPricesGET, err error) {
+	query := fmt.Sprintf("?size=%v&redundancy=%v", size, redundancy)
+	err = c.get("/renter/prices"+query, &rpg)
+	return
+}
+
 // RenterRateLimitPost uses the /renter endpoint to change the renter's bandwidth rate
 // limit.
 func (c *Client) RenterRateLimitPost(readBPS, writeBPS int64) (err error) {
@@ -525,6 +711,90 @@ func (c *Client) RenterSetCheckIPViolationPost(enabled bool) (err error) {
 	return
 }
 
+// RenterSetUploadStagingSizePost uses the /renter endpoint to set the maximum
+// number of bytes of upload sources the renter stages on local disk.
+func (c *Client) RenterSetUploadStagingSizePost(size uint64) (err error) {
+	values := url.Values{}
+	values.Set("uploadstagingsize", fmt.Sprint(size))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetMaxRepairAttemptsPost uses the /renter endpoint to set the number
+// of consecutive failed repair attempts after which a chunk is marked as stuck.
+func (c *Client) RenterSetMaxRepairAttemptsPost(attempts uint64) (err error) {
+	values := url.Values{}
+	values.Set("maxrepairattempts", fmt.Sprint(attempts))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetMaxStuckRepairAttemptsPost uses the /renter endpoint to set the
+// number of consecutive failed stuck repairs after which a chunk is abandoned.
+func (c *Client) RenterSetMaxStuckRepairAttemptsPost(attempts uint64) (err error) {
+	values := url.Values{}
+	values.Set("maxstuckrepairattempts", fmt.Sprint(attempts))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetMetadataBatchingPost uses the /renter endpoint to set the window
+// within which siafile metadata writes are batched and whether batching is
+// disabled.
+func (c *Client) RenterSetMetadataBatchingPost(window time.Duration, disable bool) (err error) {
+	values := url.Values{}
+	values.Set("metadatabatchwindow", fmt.Sprint(window.Milliseconds()))
+	values.Set("disablemetadatabatching", fmt.Sprint(disable))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetSkipUnavailableLocalFilesPost uses the /renter endpoint to set
+// whether chunks with unavailable source files are skipped by the repair code.
+func (c *Client) RenterSetSkipUnavailableLocalFilesPost(skip bool) (err error) {
+	values := url.Values{}
+	values.Set("skipunavailablelocalfiles", fmt.Sprint(skip))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetMaxConcurrentRepairsPost uses the /renter endpoint to set the
+// maximum number of chunks which are fetched and repaired concurrently.
+func (c *Client) RenterSetMaxConcurrentRepairsPost(max uint64) (err error) {
+	values := url.Values{}
+	values.Set("maxconcurrentrepairs", fmt.Sprint(max))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetMaxSubscriptionsPerWorkerPost uses the /renter endpoint to set the
+// maximum number of registry entries a worker keeps active subscriptions for.
+func (c *Client) RenterSetMaxSubscriptionsPerWorkerPost(max uint64) (err error) {
+	values := url.Values{}
+	values.Set("maxsubscriptionsperworker", fmt.Sprint(max))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterRepairRateLimitPost uses the /renter endpoint to set the bandwidth
+// limits for repairs.
+func (c *Client) RenterRepairRateLimitPost(readBPS, writeBPS int64) (err error) {
+	values := url.Values{}
+	values.Set("maxrepairdownloadspeed", strconv.FormatInt(readBPS, 10))
+	values.Set("maxrepairuploadspeed", strconv.FormatInt(writeBPS, 10))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
+// RenterSetDiskLatencyThrottlePost uses the /renter endpoint to set whether
+// the repairs are throttled while the renter's persist directory is slow.
+func (c *Client) RenterSetDiskLatencyThrottlePost(throttle bool) (err error) {
+	values := url.Values{}
+	values.Set("disklatencythrottle", fmt.Sprint(throttle))
+	err = c.post("/renter", values.Encode(), nil)
+	return
+}
+
 // RenterStreamGet uses the /renter/stream endpoint to download data as a
 // stream.
 func (c *Client) RenterStreamGet(siaPath modules.SiaPath, disableLocalFetch, root bool) (resp []byte, err error) {
@@ -557,6 +827,17 @@ func (c *Client) RenterSetRepairPathPost(siaPath modules.SiaPath, newPath string
 	return
 }
 
+// RenterSetLocalPathPost uses the /renter/file/:siapath/localpath endpoint to
+// set the local path of a file. The file at localPath must exist and have the
+// same size as the uploaded file. An empty localPath removes the local path.
+func (c *Client) RenterSetLocalPathPost(siaPath modules.SiaPath, localPath string) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("localpath", localPath)
+	err = c.post(fmt.Sprintf("/renter/file/%v/localpath", sp), values.Encode(), nil)
+	return
+}
+
 // RenterSetFileStuckPost sets the 'stuck' field of the siafile at siaPath to
 // stuck.
 func (c *Client) RenterSetFileStuckPost(siaPath modules.SiaPath, root, stuck bool) (err error) {
@@ -568,6 +849,39 @@ func (c *Client) RenterSetFileStuckPost(siaPath modules.SiaPath, root, stuck boo
 	return
 }
 
+// RenterRepairPost uses the /renter/repair endpoint to immediately repair the
+// file at siaPath.
+func (c *Client) RenterRepairPost(siaPath modules.SiaPath, root bool) (err error) {
+	values := url.Values{}
+	values.Set("siapath", siaPath.String())
+	values.Set("root", fmt.Sprint(root))
+	err = c.post("/renter/repair", values.Encode(), nil)
+	return
+}
+
+// RenterResetFileAbandonedPost marks the abandoned chunks of the siafile at
+// siaPath as stuck again.
+func (c *Client) RenterResetFileAbandonedPost(siaPath modules.SiaPath, root bool) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("abandoned", "false")
+	values.Set("root", fmt.Sprint(root))
+	err = c.post(fmt.Sprintf("/renter/file/%v", sp), values.Encode(), nil)
+	return
+}
+
+// RenterChangeErasureCodePost changes the erasure code of the siafile at
+// siaPath to the provided number of data and parity pieces.
+func (c *Client) RenterChangeErasureCodePost(siaPath modules.SiaPath, root bool, dataPieces, parityPieces uint64) (err error) {
+	sp := escapeSiaPath(siaPath)
+	values := url.Values{}
+	values.Set("datapieces", strconv.FormatUint(dataPieces, 10))
+	values.Set("paritypieces", strconv.FormatUint(parityPieces, 10))
+	values.Set("root", fmt.Sprint(root))
+	err = c.post(fmt.Sprintf("/renter/file/%v", sp), values.Encode(), nil)
+	return
+}
+
 // RenterUploadPost uses the /renter/upload endpoint to upload a file
 func (c *Client) RenterUploadPost(path string, siaPath modules.SiaPath, dataPieces, parityPieces uint64) (err error) {
 	return c.RenterUploadForcePost(path, siaPath, dataPieces, parityPieces, false)
@@ -620,6 +934,18 @@ func (c *Client) RenterUploadStreamRepairPost(r io.Reader, siaPath modules.SiaPa
 	return err
 }
 
+// RenterRepairStreamPost repairs a siafile using a stream of its content. Data
+// which doesn't match the previously uploaded pieces is rejected.
+func (c *Client) RenterRepairStreamPost(r io.Reader, siaPath modules.SiaPath) (rsp api.RenterRepairStreamPOST, err error) {
+	sp := escapeSiaPath(siaPath)
+	_, body, err := c.postRawResponse(fmt.Sprintf("/renter/repairstream/%s", sp), r)
+	if err != nil {
+		return api.RenterRepairStreamPOST{}, err
+	}
+	err = json.Unmarshal(body, &rsp)
+	return
+}
+
 // RenterDirCreatePost uses the /renter/dir/ endpoint to create a directory for the
 // renter
 func (c *Client) RenterDirCreatePost(siaPath modules.SiaPath) (err error) {
@@ -676,6 +1002,14 @@ func (c *Client) RenterDirGet(siaPath modules.SiaPath) (rd api.RenterDirectory,
 	return
 }
 
+// RenterDirHealthGet uses the /renter/dirhealth/ endpoint to query the health
+// summary of a directory
+func (c *Client) RenterDirHealthGet(siaPath modules.SiaPath) (rdh modules.RenterDirHealthSummary, err error) {
+	sp := escapeSiaPath(siaPath)
+	err = c.get(fmt.Sprintf("/renter/dirhealth/%s", sp), &rdh)
+	return
+}
+
 // RenterValidateSiaPathPost uses the /renter/validatesiapath endpoint to
 // validate a potential siapath
 //
@@ -733,6 +1067,23 @@ func (c *Client) RenterFuseUnmount(mount string) (err error) {
 	return
 }
 
+// RenterClearStuckPost uses the /renter/clearstuck endpoint to remove all stuck
+// chunks from the renter's upload heap.
+func (c *Client) RenterClearStuckPost() (err error) {
+	err = c.post("/renter/clearstuck", "", nil)
+	return
+}
+
+// RenterUploadHeapPrunePost uses the /renter/uploadheap/prune endpoint to
+// remove all chunks with a health below the threshold from the renter's upload
+// heap.
+func (c *Client) RenterUploadHeapPrunePost(threshold float64) (rup api.RenterUploadHeapPrunePOST, err error) {
+	values := url.Values{}
+	values.Set("threshold", fmt.Sprint(threshold))
+	err = c.post("/renter/uploadheap/prune", values.Encode(), &rup)
+	return
+}
+
 // RenterUploadsPausePost uses the /renter/uploads/pause endpoint to pause the
 // renter's uploads and repairs
 func (c *Client) RenterUploadsPausePost(duration time.Duration) (err error) {
@@ -763,6 +1114,24 @@ func (c *Client) RenterWorkersGet() (wps modules.WorkerPoolStatus, err error) {
 	return
 }
 
+// RenterWorkersDisablePost uses the /renter/workers/disable endpoint to
+// disable the worker for a host.
+func (c *Client) RenterWorkersDisablePost(hostKey types.SiaPublicKey) (err error) {
+	values := url.Values{}
+	values.Set("hostkey", hostKey.String())
+	err = c.post("/renter/workers/disable", values.Encode(), nil)
+	return
+}
+
+// RenterWorkersEnablePost uses the /renter/workers/enable endpoint to enable a
+// previously disabled worker for a host.
+func (c *Client) RenterWorkersEnablePost(hostKey types.SiaPublicKey) (err error) {
+	values := url.Values{}
+	values.Set("hostkey", hostKey.String())
+	err = c.post("/renter/workers/enable", values.Encode(), nil)
+	return
+}
+
 // RenterBubblePost uses the /renter/bubble endpoint to manually trigger an
 // update to the directories metadata.
 func (c *Client) RenterBubblePost(siaPath modules.SiaPath, force, recursive bool) (err error) {
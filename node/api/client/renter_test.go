@@ -1,11 +1,36 @@
 package client
 
 import (
+	"context"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"gitlab.com/NebulousLabs/fastrand"
+
+	"go.sia.tech/siad/crypto"
 	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/node/api"
+	"go.sia.tech/siad/types"
 )
 
+// subscribeRegistryRenter is a renter which delivers the updates sent on
+// updates to its registry subscribers.
+type subscribeRegistryRenter struct {
+	modules.Renter
+	updates   chan modules.SignedRegistryValue
+	cancelled chan struct{}
+}
+
+// SubscribeRegistry implements modules.Renter.
+func (r *subscribeRegistryRenter) SubscribeRegistry(types.SiaPublicKey, crypto.Hash) (<-chan modules.SignedRegistryValue, func(), error) {
+	var once sync.Once
+	return r.updates, func() { once.Do(func() { close(r.cancelled) }) }, nil
+}
+
 // TestEscapeSiaPath probes the escapeSiaPath function
 func TestEscapeSiaPath(t *testing.T) {
 	var tests = []struct {
@@ -55,3 +80,101 @@ func TestEscapeSiaPath(t *testing.T) {
 		}
 	}
 }
+
+// TestRenterRegistrySubscribeGet tests subscribing to a registry entry using
+// the /renter/registry/subscribe endpoint.
+func TestRenterRegistrySubscribeGet(t *testing.T) {
+	r := &subscribeRegistryRenter{
+		updates:   make(chan modules.SignedRegistryValue),
+		cancelled: make(chan struct{}),
+	}
+	server := httptest.NewServer(api.New(nil, "Sia-Agent", "", nil, nil, nil, nil, nil, nil, r, nil, nil))
+	defer server.Close()
+	c := New(Options{
+		Address:   strings.TrimPrefix(server.URL, "http://"),
+		UserAgent: "Sia-Agent",
+	})
+
+	sk, pk := crypto.GenerateKeyPair()
+	spk := types.Ed25519PublicKey(pk)
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+
+	// Subscribing with an invalid public key should fail.
+	if err := c.get("/renter/registry/subscribe?publickey=invalid&datakey="+tweak.String(), nil); err == nil || !strings.Contains(err.Error(), "unable to parse publickey") {
+		t.Fatal("expected subscription with invalid public key to fail", err)
+	}
+
+	// Subscribe to the entry.
+	sub, err := c.RenterRegistrySubscribeGet(spk, tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Every update should be streamed to the client right away.
+	for rev := uint64(0); rev < 3; rev++ {
+		rv := modules.NewRegistryValue(tweak, fastrand.Bytes(10), rev, modules.RegistryTypeWithoutPubkey).Sign(sk)
+		select {
+		case r.updates <- rv:
+		case <-time.After(10 * time.Second):
+			t.Fatal("update wasn't consumed by the handler")
+		}
+		update, err := sub.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if update.Data != hex.EncodeToString(rv.Data) || update.Revision != rv.Revision || update.Signature != hex.EncodeToString(rv.Signature[:]) || update.Type != rv.Type {
+			t.Fatal("wrong update", update)
+		}
+	}
+
+	// Closing the subscription should cancel it in the renter.
+	if err := sub.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.cancelled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("subscription wasn't cancelled")
+	}
+}
+
+// TestRenterRegistrySubscribeShutdown checks that shutting down the http
+// server doesn't block on an open registry subscription.
+func TestRenterRegistrySubscribeShutdown(t *testing.T) {
+	r := &subscribeRegistryRenter{
+		updates:   make(chan modules.SignedRegistryValue),
+		cancelled: make(chan struct{}),
+	}
+	a := api.New(nil, "Sia-Agent", "", nil, nil, nil, nil, nil, nil, r, nil, nil)
+	server := httptest.NewUnstartedServer(a)
+	server.Config.RegisterOnShutdown(a.CloseStreams)
+	server.Start()
+	defer server.Close()
+	c := New(Options{
+		Address:   strings.TrimPrefix(server.URL, "http://"),
+		UserAgent: "Sia-Agent",
+	})
+
+	_, pk := crypto.GenerateKeyPair()
+	var tweak crypto.Hash
+	fastrand.Read(tweak[:])
+	sub, err := c.RenterRegistrySubscribeGet(types.Ed25519PublicKey(pk), tweak)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	// Shut down the server. The handler should return and cancel the
+	// subscription.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Config.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-r.cancelled:
+	case <-time.After(10 * time.Second):
+		t.Fatal("subscription wasn't cancelled")
+	}
+}
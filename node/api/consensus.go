@@ -20,11 +20,12 @@ import (
 // to support idiomatic json encodings.
 type ConsensusGET struct {
 	// Consensus status values.
-	Synced       bool              `json:"synced"`
-	Height       types.BlockHeight `json:"height"`
-	CurrentBlock types.BlockID     `json:"currentblock"`
-	Target       types.Target      `json:"target"`
-	Difficulty   types.Currency    `json:"difficulty"`
+	Synced          bool              `json:"synced"`
+	Height          types.BlockHeight `json:"height"`
+	EstimatedHeight types.BlockHeight `json:"estimatedheight"`
+	CurrentBlock    types.BlockID     `json:"currentblock"`
+	Target          types.Target      `json:"target"`
+	Difficulty      types.Currency    `json:"difficulty"`
 
 	// Foundation unlock hashes.
 	FoundationPrimaryUnlockHash  types.UnlockHash `json:"foundationprimaryunlockhash"`
@@ -229,11 +230,12 @@ func consensusHandler(cs modules.ConsensusSet, w http.ResponseWriter, _ *http.Re
 	currentTarget, _ := cs.ChildTarget(cbid)
 	primary, failsafe := cs.FoundationUnlockHashes()
 	WriteJSON(w, ConsensusGET{
-		Synced:       cs.Synced(),
-		Height:       height,
-		CurrentBlock: cbid,
-		Target:       currentTarget,
-		Difficulty:   currentTarget.Difficulty(),
+		Synced:          cs.Synced(),
+		Height:          height,
+		EstimatedHeight: cs.EstimatedHeight(),
+		CurrentBlock:    cbid,
+		Target:          currentTarget,
+		Difficulty:      currentTarget.Difficulty(),
 
 		FoundationPrimaryUnlockHash:  primary,
 		FoundationFailsafeUnlockHash: failsafe,
@@ -74,6 +74,11 @@ type (
 		InfoAlerts     []modules.Alert `json:"infoalerts"`
 	}
 
+	// DaemonSelfTestPOST contains the report of the daemon's self-test.
+	DaemonSelfTestPOST struct {
+		modules.SelfTestReport
+	}
+
 	// DaemonVersionGet contains information about the running daemon's version.
 	DaemonVersionGet struct {
 		Version     string
@@ -488,6 +493,12 @@ func (api *API) daemonStopProfileHandlerPOST(w http.ResponseWriter, _ *http.Requ
 	WriteSuccess(w)
 }
 
+// daemonSelfTestHandlerPOST handles the API call that runs the daemon's
+// self-test. The self-test writes to disk so it requires the API password.
+func (api *API) daemonSelfTestHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, DaemonSelfTestPOST{api.SelfTest()})
+}
+
 // daemonVersionHandler handles the API call that requests the daemon's version.
 func (api *API) daemonVersionHandler(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	WriteJSON(w, DaemonVersion{Version: build.NodeVersion, GitRevision: build.GitRevision, BuildTime: build.BuildTime})
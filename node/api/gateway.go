@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -18,6 +19,8 @@ type (
 		Peers      []modules.Peer     `json:"peers"`
 		Online     bool               `json:"online"`
 
+		PeerStats []modules.PeerBandwidth `json:"peerstats"`
+
 		MaxDownloadSpeed int64 `json:"maxdownloadspeed"`
 		MaxUploadSpeed   int64 `json:"maxuploadspeed"`
 	}
@@ -86,7 +89,11 @@ func gatewayHandlerGET(gateway modules.Gateway, w http.ResponseWriter, _ *http.R
 	if peers == nil {
 		peers = make([]modules.Peer, 0)
 	}
-	WriteJSON(w, GatewayGET{gateway.Address(), peers, gateway.Online(), mds, mus})
+	peerStats := gateway.PeerStats()
+	if peerStats == nil {
+		peerStats = make([]modules.PeerBandwidth, 0)
+	}
+	WriteJSON(w, GatewayGET{gateway.Address(), peers, gateway.Online(), peerStats, mds, mus})
 }
 
 // gatewayHandlerPOST handles the API call changing gateway specific settings.
@@ -134,10 +141,39 @@ func gatewayBandwidthHandlerGET(gateway modules.Gateway, w http.ResponseWriter,
 	})
 }
 
-// gatewayConnectHandler handles the API call to add a peer to the gateway.
-func gatewayConnectHandler(gateway modules.Gateway, w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+// gatewayConnectHandler handles the API call to add a peer to the gateway. An
+// optional timeout in seconds can be provided after which the call gives up on
+// the connection attempt.
+func gatewayConnectHandler(gateway modules.Gateway, w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	addr := modules.NetAddress(ps.ByName("netaddress"))
-	err := gateway.ConnectManual(addr)
+
+	// Parse the timeout.
+	var timeout time.Duration
+	if timeoutStr := req.FormValue("timeout"); timeoutStr != "" {
+		timeoutInt, err := strconv.ParseUint(timeoutStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse timeout: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(timeoutInt) * time.Second
+	}
+
+	// Connect to the peer. If a timeout was provided, the connection attempt
+	// continues in the background after the timeout.
+	var err error
+	if timeout == 0 {
+		err = gateway.ConnectManual(addr)
+	} else {
+		errChan := make(chan error, 1)
+		go func() {
+			errChan <- gateway.ConnectManual(addr)
+		}()
+		select {
+		case err = <-errChan:
+		case <-time.After(timeout):
+			err = fmt.Errorf("unable to connect to %v within %v", addr, timeout)
+		}
+	}
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
 		return
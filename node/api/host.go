@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/julienschmidt/httprouter"
@@ -58,6 +59,10 @@ type (
 		PriceTable           modules.RPCPriceTable            `json:"pricetable"`
 		PublicKey            types.SiaPublicKey               `json:"publickey"`
 		WorkingStatus        modules.HostWorkingStatus        `json:"workingstatus"`
+
+		// CollateralBudgetUsage is the fraction of the collateral budget that
+		// is locked in storage obligations.
+		CollateralBudgetUsage float64 `json:"collateralbudgetusage"`
 	}
 
 	// HostEstimateScoreGET contains the information that is returned from a
@@ -71,7 +76,35 @@ type (
 	// to /host/storage - a bunch of information about the status of storage
 	// management on the host.
 	StorageGET struct {
-		Folders []modules.StorageFolderMetadata `json:"folders"`
+		Capacity        modules.StorageCapacity         `json:"capacity"`
+		Folders         []modules.StorageFolderMetadata `json:"folders"`
+		ReadCache       modules.ReadCacheStatus         `json:"readcache"`
+		OrphanedSectors modules.HostOrphanedSectors     `json:"orphanedsectors"`
+
+		// PhysicalSectors is the number of unique sectors stored by the host
+		// and VirtualSectors the number of virtual sectors referencing them.
+		PhysicalSectors uint64 `json:"physicalsectors"`
+		VirtualSectors  uint64 `json:"virtualsectors"`
+	}
+
+	// StorageStatsGET contains the information that is returned after a GET
+	// request to /host/storage/stats - the I/O statistics of the host's
+	// storage folders.
+	StorageStatsGET struct {
+		Folders []modules.StorageFolderStats `json:"folders"`
+	}
+
+	// HostMDMGET contains the programs which are currently executed by the
+	// host's MDM and the cumulative stats of the MDM.
+	HostMDMGET struct {
+		ActivePrograms []modules.MDMProgramInfo `json:"activeprograms"`
+		Stats          modules.MDMStats         `json:"stats"`
+	}
+
+	// HostRentersGET contains the amount of data stored and the bandwidth
+	// consumed by each renter of the host.
+	HostRentersGET struct {
+		Renters []modules.HostRenterUsage `json:"renters"`
 	}
 )
 
@@ -96,20 +129,35 @@ func RegisterRoutesHost(router *httprouter.Router, h modules.Host, deps modules.
 	router.GET("/host/bandwidth", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		hostBandwidthHandlerGET(h, w, req, ps)
 	})
+	router.GET("/host/mdm", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		hostMDMHandlerGET(h, w, req, ps)
+	})
+	router.GET("/host/renters", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		hostRentersHandlerGET(h, w, req, ps)
+	})
 
 	// Calls pertaining to the storage manager that the host uses.
 	router.GET("/host/storage", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageHandler(h, w, req, ps)
 	})
+	router.GET("/host/storage/stats", func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		storageStatsHandler(h, w, req, ps)
+	})
 	router.POST("/host/storage/folders/add", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageFoldersAddHandler(h, w, req, ps)
 	}, requiredPassword))
 	router.POST("/host/storage/folders/remove", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageFoldersRemoveHandler(h, w, req, ps)
 	}, requiredPassword))
+	router.POST("/host/storage/folders/readonly", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		storageFoldersReadOnlyHandler(h, w, req, ps)
+	}, requiredPassword))
 	router.POST("/host/storage/folders/resize", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageFoldersResizeHandler(h, w, req, ps)
 	}, requiredPassword))
+	router.POST("/host/storage/migrate", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		storageMigrateHandler(h, w, req, ps)
+	}, requiredPassword))
 	router.POST("/host/storage/sectors/delete/:merkleroot", RequirePassword(func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		storageSectorsDeleteHandler(h, w, req, ps)
 	}, requiredPassword))
@@ -179,6 +227,8 @@ func hostHandlerGET(host modules.Host, w http.ResponseWriter, deps modules.Depen
 		PriceTable:           pt,
 		PublicKey:            pk,
 		WorkingStatus:        ws,
+
+		CollateralBudgetUsage: host.CollateralBudgetUsage(),
 	}
 
 	if deps.Disrupt("TimeoutOnHostGET") {
@@ -203,6 +253,39 @@ func hostBandwidthHandlerGET(host modules.Host, w http.ResponseWriter, _ *http.R
 	})
 }
 
+// hostMDMHandlerGET handles GET requests to the /host/mdm API endpoint,
+// returning the programs the host's MDM is currently executing and the MDM's
+// cumulative stats.
+func hostMDMHandlerGET(host modules.Host, w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, HostMDMGET{
+		ActivePrograms: host.MDMActivePrograms(),
+		Stats:          host.MDMStats(),
+	})
+}
+
+// hostRentersHandlerGET handles GET requests to the /host/renters API
+// endpoint, returning the amount of data stored and the bandwidth consumed by
+// each renter.
+func hostRentersHandlerGET(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var anonymize bool
+	if a := req.FormValue("anonymize"); a != "" {
+		var err error
+		anonymize, err = scanBool(a)
+		if err != nil {
+			WriteError(w, Error{"unable to parse anonymize flag: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	renters, err := host.RenterUsage(anonymize)
+	if err != nil {
+		WriteError(w, Error{"failed to get renter usage: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, HostRentersGET{
+		Renters: renters,
+	})
+}
+
 // parseHostSettings a request's query strings and returns a
 // modules.HostInternalSettings configured with the request's query string
 // parameters.
@@ -233,6 +316,14 @@ func parseHostSettings(host modules.Host, req *http.Request) (modules.HostIntern
 		}
 		settings.MaxDuration = x
 	}
+	if req.FormValue("maxcontractsize") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxcontractsize"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxContractSize = x
+	}
 	if req.FormValue("maxrevisebatchsize") != "" {
 		var x uint64
 		_, err := fmt.Sscan(req.FormValue("maxrevisebatchsize"), &x)
@@ -366,6 +457,38 @@ func parseHostSettings(host modules.Host, req *http.Request) (modules.HostIntern
 	if req.FormValue("customregistrypath") != "" {
 		settings.CustomRegistryPath = req.FormValue("customregistrypath")
 	}
+	if req.FormValue("readcachesize") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("readcachesize"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.ReadCacheSize = x
+	}
+	if req.FormValue("orphanedsectordeletion") != "" {
+		var x bool
+		_, err := fmt.Sscan(req.FormValue("orphanedsectordeletion"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.OrphanedSectorDeletion = x
+	}
+	if req.FormValue("orphanedsectordeletiondelay") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("orphanedsectordeletiondelay"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.OrphanedSectorDeletionDelay = time.Duration(x) * time.Second
+	}
+	if req.FormValue("maxpinnedpricetablevalidity") != "" {
+		var x uint64
+		_, err := fmt.Sscan(req.FormValue("maxpinnedpricetablevalidity"), &x)
+		if err != nil {
+			return modules.HostInternalSettings{}, err
+		}
+		settings.MaxPinnedPriceTableValidity = time.Duration(x) * time.Second
+	}
 
 	// Validate the RPC, Sector Access, and Download Prices
 	minBaseRPCPrice := settings.MinBaseRPCPrice
@@ -461,11 +584,39 @@ func hostHandlerPOST(host modules.Host, w http.ResponseWriter, req *http.Request
 // hostAnnounceHandler handles the API call to get the host to announce itself
 // to the network.
 func hostAnnounceHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var force, dryRun bool
 	var err error
-	if addr := req.FormValue("netaddress"); addr != "" {
-		err = host.AnnounceAddress(modules.NetAddress(addr))
+	if f := req.FormValue("force"); f != "" {
+		force, err = scanBool(f)
+		if err != nil {
+			WriteError(w, Error{"unable to parse force flag: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if dr := req.FormValue("dryrun"); dr != "" {
+		dryRun, err = scanBool(dr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse dryrun flag: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	addr := modules.NetAddress(req.FormValue("netaddress"))
+
+	// On a dry run only the checks are performed and returned.
+	if dryRun {
+		checks, err := host.AnnouncementChecks(addr)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+		WriteJSON(w, checks)
+		return
+	}
+
+	if addr != "" {
+		err = host.AnnounceAddress(addr, force)
 	} else {
-		err = host.Announce()
+		err = host.Announce(force)
 	}
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
@@ -477,8 +628,21 @@ func hostAnnounceHandler(host modules.Host, w http.ResponseWriter, req *http.Req
 // storageHandler returns a bunch of information about storage management on
 // the host.
 func storageHandler(host modules.Host, w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	physical, virtual := host.SectorCount()
 	WriteJSON(w, StorageGET{
-		Folders: host.StorageFolders(),
+		Capacity:        host.Capacity(),
+		Folders:         host.StorageFolders(),
+		ReadCache:       host.ReadCacheStatus(),
+		OrphanedSectors: host.OrphanedSectors(),
+		PhysicalSectors: physical,
+		VirtualSectors:  virtual,
+	})
+}
+
+// storageStatsHandler returns the I/O statistics of the host's storage folders.
+func storageStatsHandler(host modules.Host, w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, StorageStatsGET{
+		Folders: host.StorageFolderStats(),
 	})
 }
 
@@ -553,6 +717,55 @@ func storageFoldersRemoveHandler(host modules.Host, w http.ResponseWriter, req *
 	WriteSuccess(w)
 }
 
+// storageFoldersReadOnlyHandler handles the API call to mark a storage folder
+// as read-only or writable.
+func storageFoldersReadOnlyHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	folderPath := req.FormValue("path")
+	if folderPath == "" {
+		WriteError(w, Error{"path parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	readOnly, err := strconv.ParseBool(req.FormValue("readonly"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse readonly: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	storageFolders := host.StorageFolders()
+	folderIndex, err := folderIndex(folderPath, storageFolders)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = host.SetStorageFolderReadOnly(uint16(folderIndex), readOnly)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// storageMigrateHandler moves all sectors of a storage folder to another
+// storage folder.
+func storageMigrateHandler(host modules.Host, w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	source := req.FormValue("source")
+	if source == "" {
+		WriteError(w, Error{"source parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	destination := req.FormValue("destination")
+	if destination == "" {
+		WriteError(w, Error{"destination parameter is required"}, http.StatusBadRequest)
+		return
+	}
+	err := host.MigrateStorageFolder(source, destination)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // storageSectorsDeleteHandler handles the call to delete a sector from the
 // storage manager.
 func storageSectorsDeleteHandler(host modules.Host, w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
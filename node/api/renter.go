@@ -1,6 +1,8 @@
 package api
 
 import (
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -23,6 +25,7 @@ import (
 	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/modules/renter"
 	"go.sia.tech/siad/modules/renter/contractor"
+	"go.sia.tech/siad/modules/renter/filesystem"
 	"go.sia.tech/siad/persist"
 	"go.sia.tech/siad/types"
 )
@@ -64,6 +67,10 @@ var (
 		Testing:  types.BlockHeight(1),
 	}).(types.BlockHeight)
 
+	// contractUtilizationWarningThreshold is the utilization percentage of a
+	// contract above which the API warns that the contract is nearly full.
+	contractUtilizationWarningThreshold = 95.0
+
 	// errNeedBothDataAndParityPieces is the error returned when only one of the
 	// erasure coding parameters is set
 	errNeedBothDataAndParityPieces = errors.New("must provide both the datapieces parameter and the paritypieces parameter if specifying erasure coding parameters")
@@ -78,6 +85,11 @@ var (
 )
 
 type (
+	// RenterAccountsGET contains the renter's ephemeral accounts.
+	RenterAccountsGET struct {
+		Accounts []modules.RenterAccount `json:"accounts"`
+	}
+
 	// RenterGET contains various renter metrics.
 	RenterGET struct {
 		Settings         modules.RenterSettings     `json:"settings"`
@@ -86,6 +98,33 @@ type (
 		NextPeriod       types.BlockHeight          `json:"nextperiod"`
 
 		MemoryStatus modules.MemoryStatus `json:"memorystatus"`
+
+		// AvgRepairRate is the average number of bytes per second the renter
+		// recently repaired.
+		AvgRepairRate uint64 `json:"avgrepairrate"`
+
+		// UploadStaging is the status of the renter's upload staging
+		// directory.
+		UploadStaging modules.UploadStagingStatus `json:"uploadstaging"`
+
+		// MetadataBatching contains information about the batching of siafile
+		// metadata writes.
+		MetadataBatching modules.MetadataBatchStats `json:"metadatabatching"`
+
+		// SyncStatus contains information about the consensus sync that some
+		// renter operations are waiting for.
+		SyncStatus modules.RenterSyncStatus `json:"syncstatus"`
+
+		// DiskLatency contains the latency measurements of the writes to the
+		// renter's persist directory.
+		DiskLatency modules.RenterDiskLatency `json:"disklatency"`
+	}
+
+	// RenterSyncError is the error body returned by renter endpoints that
+	// can't complete their operation until the consensus set is synced.
+	RenterSyncError struct {
+		Message    string                   `json:"message"`
+		SyncStatus modules.RenterSyncStatus `json:"syncstatus"`
 	}
 
 	// RenterContract represents a contract formed by the renter.
@@ -113,6 +152,12 @@ type (
 		MaintenanceSpending modules.MaintenanceSpending `json:"maintenancespending"`
 		// Address of the host the file contract was formed with.
 		NetAddress modules.NetAddress `json:"netaddress"`
+		// MaxRoots is the number of sector roots the contract can hold given
+		// its size and the collateral the host has left in the contract.
+		MaxRoots uint64 `json:"maxroots"`
+		// NumRoots is the number of sector roots the renter believes the
+		// contract holds.
+		NumRoots uint64 `json:"numroots"`
 		// Remaining funds left for the renter to spend on uploads & downloads.
 		RenterFunds types.Currency `json:"renterfunds"`
 		// Size of the file contract, which is typically equal to the number of
@@ -133,6 +178,11 @@ type (
 		TotalCost types.Currency `json:"totalcost"`
 		// Amount of contract funds that have been spent on uploads.
 		UploadSpending types.Currency `json:"uploadspending"`
+		// Utilization is the percentage of MaxRoots used by the contract.
+		Utilization float64 `json:"utilization"`
+		// UtilizationWarning is set if the contract's utilization is high
+		// enough for the contract to be considered nearly full.
+		UtilizationWarning string `json:"utilizationwarning,omitempty"`
 		// Signals if contract is good for uploading data
 		GoodForUpload bool `json:"goodforupload"`
 		// Signals if contract is good for a renewal
@@ -169,9 +219,22 @@ type (
 		Downloads []DownloadInfo `json:"downloads"`
 	}
 
+	// RenterDownloadGroupsGET contains the renter's download groups.
+	RenterDownloadGroupsGET struct {
+		Groups []modules.DownloadGroupInfo `json:"groups"`
+	}
+
 	// RenterFile lists the file queried.
 	RenterFile struct {
 		File modules.FileInfo `json:"file"`
+
+		// Chunks contains debugging information about the file's chunks. It
+		// is only set if requested.
+		Chunks []modules.ChunkInfo `json:"chunks,omitempty"`
+
+		// RepairCostEstimate is the estimated cost of uploading all of the
+		// file's missing pieces. It is only set if requested.
+		RepairCostEstimate *types.Currency `json:"repaircostestimate,omitempty"`
 	}
 
 	// RenterFiles lists the files known to the renter.
@@ -194,13 +257,30 @@ type (
 	RenterPricesGET struct {
 		modules.RenterPriceEstimation
 		modules.Allowance
+
+		// UploadCostEstimate is only set if a size was provided.
+		UploadCostEstimate *modules.RenterUploadCostEstimate `json:"uploadcostestimate,omitempty"`
 	}
+	// RenterRegistryGET contains a registry entry that was looked up on the
+	// renter's hosts.
+	RenterRegistryGET struct {
+		Data      string                    `json:"data"`
+		Revision  uint64                    `json:"revision"`
+		Signature string                    `json:"signature"`
+		Type      modules.RegistryEntryType `json:"type"`
+	}
+
 	// RenterRecoveryStatusGET returns information about potential contract
 	// recovery scans.
 	RenterRecoveryStatusGET struct {
 		ScanInProgress bool              `json:"scaninprogress"`
 		ScannedHeight  types.BlockHeight `json:"scannedheight"`
 	}
+	// RenterStuckCursorGET contains the recent stuck repair attempts of the
+	// renter's stuck loop.
+	RenterStuckCursorGET struct {
+		Entries []modules.StuckCursorEntry `json:"entries"`
+	}
 	// RenterShareASCII contains an ASCII-encoded .sia file.
 	RenterShareASCII struct {
 		ASCIIsia string `json:"asciisia"`
@@ -212,6 +292,10 @@ type (
 		CreationDate   types.Timestamp `json:"creationdate"`
 		Size           uint64          `json:"size"`
 		UploadProgress float64         `json:"uploadprogress"`
+
+		// RemainingChunks is the number of chunks of the backup which still
+		// need to be uploaded before the backup is safe.
+		RemainingChunks uint64 `json:"remainingchunks"`
 	}
 
 	// RenterBackupsGET lists the renter's uploaded backups, as well as the
@@ -222,6 +306,18 @@ type (
 		UnsyncedHosts []types.SiaPublicKey   `json:"unsyncedhosts"`
 	}
 
+	// RenterUploadHeapPrunePOST contains the number of chunks which were
+	// pruned from the upload heap.
+	RenterUploadHeapPrunePOST struct {
+		Pruned int `json:"pruned"`
+	}
+
+	// RenterRepairStreamPOST contains the outcome of repairing a file from a
+	// stream of its content.
+	RenterRepairStreamPOST struct {
+		modules.RepairStreamReport
+	}
+
 	// RenterUploadReadyGet lists the upload ready status of the renter
 	RenterUploadReadyGet struct {
 		// Ready indicates whether of not the renter is ready to successfully
@@ -241,8 +337,9 @@ type (
 	// DownloadInfo contains all client-facing information of a file.
 	DownloadInfo struct {
 		Destination     string          `json:"destination"`     // The destination of the download.
-		DestinationType string          `json:"destinationtype"` // Can be "file", "memory buffer", or "http stream".
+		DestinationType string          `json:"destinationtype"` // Can be "file", "memory buffer", "http stream" or "file and http stream".
 		Filesize        uint64          `json:"filesize"`        // DEPRECATED. Same as 'Length'.
+		Group           string          `json:"group"`           // The download group of the download.
 		Length          uint64          `json:"length"`          // The length requested for the download.
 		Offset          uint64          `json:"offset"`          // The offset within the siafile requested for the download.
 		SiaPath         modules.SiaPath `json:"siapath"`         // The siapath of the file used for the download.
@@ -254,6 +351,7 @@ type (
 		StartTime            time.Time `json:"starttime"`            // The time when the download was started.
 		StartTimeUnix        int64     `json:"starttimeunix"`        // The time when the download was started in unix format.
 		TotalDataTransferred uint64    `json:"totaldatatransferred"` // The total amount of data transferred, including negotiation, overdrive etc.
+		Warnings             []string  `json:"warnings"`             // Destinations that failed without failing the download.
 	}
 )
 
@@ -423,6 +521,8 @@ outer:
 			CreationDate:   b.CreationDate,
 			Size:           b.Size,
 			UploadProgress: b.UploadProgress,
+
+			RemainingChunks: b.RemainingChunks,
 		}
 	}
 	WriteJSON(w, RenterBackupsGET{
@@ -671,6 +771,21 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, _ *http.Request, _ httpr
 		WriteError(w, Error{"unable to get renter memory information: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
+	avgRepairRate, err := api.renter.AvgRepairRate()
+	if err != nil {
+		WriteError(w, Error{"unable to get renter repair rate: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	uploadStaging, err := api.renter.UploadStagingStatus()
+	if err != nil {
+		WriteError(w, Error{"unable to get renter upload staging status: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	metadataBatching, err := api.renter.MetadataBatchStats()
+	if err != nil {
+		WriteError(w, Error{"unable to get renter metadata batching stats: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
 	WriteJSON(w, RenterGET{
 		Settings:         settings,
 		FinancialMetrics: spending,
@@ -678,9 +793,36 @@ func (api *API) renterHandlerGET(w http.ResponseWriter, _ *http.Request, _ httpr
 		NextPeriod:       nextPeriod,
 
 		MemoryStatus: memoryStatus,
+
+		AvgRepairRate:    avgRepairRate,
+		UploadStaging:    uploadStaging,
+		MetadataBatching: metadataBatching,
+		SyncStatus:       api.renter.SyncStatus(),
+		DiskLatency:      api.renter.DiskLatency(),
 	})
 }
 
+// writeRenterError writes an error to the API caller. If the error was caused
+// by the renter waiting for the consensus set to sync, a
+// StatusServiceUnavailable is returned together with the renter's sync status
+// instead of the provided code.
+func (api *API) writeRenterError(w http.ResponseWriter, err error, code int) {
+	if !errors.Contains(err, modules.ErrRenterNotSynced) {
+		WriteError(w, Error{err.Error()}, code)
+		return
+	}
+	status := api.renter.SyncStatus()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	encodingErr := json.NewEncoder(w).Encode(RenterSyncError{
+		Message:    fmt.Sprintf("%v - %v", err, status),
+		SyncStatus: status,
+	})
+	if _, isJSONErr := encodingErr.(*json.SyntaxError); isJSONErr {
+		build.Critical("failed to encode API error response:", encodingErr)
+	}
+}
+
 // renterHandlerPOST handles the API call to set the Renter's settings. This API
 // call handles multiple settings and so each setting is optional on it's own.
 // Groups of settings, such as the allowance, have certain requirements if they
@@ -955,15 +1097,321 @@ func (api *API) renterHandlerPOST(w http.ResponseWriter, req *http.Request, _ ht
 		settings.IPViolationCheck = ipviolationcheck
 	}
 
+	// Scan the upload staging size. (optional parameter)
+	if s := req.FormValue("uploadstagingsize"); s != "" {
+		var stagingSize uint64
+		if _, err := fmt.Sscan(s, &stagingSize); err != nil {
+			WriteError(w, Error{"unable to parse uploadstagingsize: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.UploadStagingSize = stagingSize
+	}
+	// Scan the max repair attempts. (optional parameter)
+	if s := req.FormValue("maxrepairattempts"); s != "" {
+		var maxRepairAttempts uint64
+		if _, err := fmt.Sscan(s, &maxRepairAttempts); err != nil {
+			WriteError(w, Error{"unable to parse maxrepairattempts: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxRepairAttempts = maxRepairAttempts
+	}
+	// Scan the max stuck repair attempts. (optional parameter)
+	if s := req.FormValue("maxstuckrepairattempts"); s != "" {
+		var maxStuckRepairAttempts uint64
+		if _, err := fmt.Sscan(s, &maxStuckRepairAttempts); err != nil {
+			WriteError(w, Error{"unable to parse maxstuckrepairattempts: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxStuckRepairAttempts = maxStuckRepairAttempts
+	}
+	// Scan the metadata batch window in milliseconds. (optional parameter)
+	if s := req.FormValue("metadatabatchwindow"); s != "" {
+		var window uint64
+		if _, err := fmt.Sscan(s, &window); err != nil {
+			WriteError(w, Error{"unable to parse metadatabatchwindow: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MetadataBatchWindow = time.Duration(window) * time.Millisecond
+	}
+	// Scan whether to disable metadata batching. (optional parameter)
+	if s := req.FormValue("disablemetadatabatching"); s != "" {
+		disable, err := strconv.ParseBool(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse disablemetadatabatching: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.DisableMetadataBatching = disable
+	}
+	// Scan whether to skip chunks with unavailable source files. (optional
+	// parameter)
+	if s := req.FormValue("skipunavailablelocalfiles"); s != "" {
+		skip, err := strconv.ParseBool(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse skipunavailablelocalfiles: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.SkipUnavailableLocalFiles = skip
+	}
+	// Scan the max number of concurrent repairs. (optional parameter)
+	if s := req.FormValue("maxconcurrentrepairs"); s != "" {
+		var maxConcurrentRepairs uint64
+		if _, err := fmt.Sscan(s, &maxConcurrentRepairs); err != nil {
+			WriteError(w, Error{"unable to parse maxconcurrentrepairs: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxConcurrentRepairs = maxConcurrentRepairs
+	}
+	// Scan the max number of subscriptions per worker. (optional parameter)
+	if s := req.FormValue("maxsubscriptionsperworker"); s != "" {
+		var maxSubscriptions uint64
+		if _, err := fmt.Sscan(s, &maxSubscriptions); err != nil {
+			WriteError(w, Error{"unable to parse maxsubscriptionsperworker: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxSubscriptionsPerWorker = maxSubscriptions
+	}
+	// Scan the repair download speed limit. (optional parameter)
+	if d := req.FormValue("maxrepairdownloadspeed"); d != "" {
+		var downloadSpeed int64
+		if _, err := fmt.Sscan(d, &downloadSpeed); err != nil {
+			WriteError(w, Error{"unable to parse maxrepairdownloadspeed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxRepairDownloadSpeed = downloadSpeed
+	}
+	// Scan the repair upload speed limit. (optional parameter)
+	if u := req.FormValue("maxrepairuploadspeed"); u != "" {
+		var uploadSpeed int64
+		if _, err := fmt.Sscan(u, &uploadSpeed); err != nil {
+			WriteError(w, Error{"unable to parse maxrepairuploadspeed: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.MaxRepairUploadSpeed = uploadSpeed
+	}
+	// Scan whether to throttle repairs while the persist directory is slow.
+	// (optional parameter)
+	if s := req.FormValue("disklatencythrottle"); s != "" {
+		throttle, err := strconv.ParseBool(s)
+		if err != nil {
+			WriteError(w, Error{"unable to parse disklatencythrottle: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		settings.DiskLatencyThrottle = throttle
+	}
+
 	// Set the settings in the renter.
 	err = api.renter.SetSettings(settings)
 	if err != nil {
-		WriteError(w, Error{"unable to set renter settings: " + err.Error()}, http.StatusBadRequest)
+		api.writeRenterError(w, errors.AddContext(err, "unable to set renter settings"), http.StatusBadRequest)
 		return
 	}
 	WriteSuccess(w)
 }
 
+// renterAccountsHandlerGET handles the API call to get information about the
+// renter's ephemeral accounts.
+func (api *API) renterAccountsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	accounts, err := api.renter.Accounts()
+	if err != nil {
+		WriteError(w, Error{"unable to get accounts: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterAccountsGET{
+		Accounts: accounts,
+	})
+}
+
+// renterBandwidthHandlerGET handles the API call to get the current upload and
+// download bandwidth of the renter's workers.
+func (api *API) renterBandwidthHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	bandwidth, err := api.renter.Bandwidth()
+	if err != nil {
+		WriteError(w, Error{"unable to get bandwidth: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, bandwidth)
+}
+
+// renterRepairMetricsHandlerGET handles the API call to get the renter's repair
+// metrics.
+func (api *API) renterRepairMetricsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	metrics, err := api.renter.RepairMetrics()
+	if err != nil {
+		WriteError(w, Error{"unable to get repair metrics: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, metrics)
+}
+
+// renterRepairStatusHandlerGET handles the API call to get the state of the
+// renter's repair loop.
+func (api *API) renterRepairStatusHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	status, err := api.renter.RepairStatus()
+	if err != nil {
+		WriteError(w, Error{"unable to get repair status: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, status)
+}
+
+// renterRegistryHandlerGET handles the API call to look up a registry entry on
+// the renter's hosts.
+func (api *API) renterRegistryHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var spk types.SiaPublicKey
+	if err := spk.LoadString(req.FormValue("publickey")); err != nil {
+		WriteError(w, Error{"unable to parse publickey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var tweak crypto.Hash
+	if err := tweak.LoadString(req.FormValue("datakey")); err != nil {
+		WriteError(w, Error{"unable to parse datakey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	timeout := renter.MaxRegistryReadTimeout
+	if timeoutStr := req.FormValue("timeout"); timeoutStr != "" {
+		timeoutInt, err := strconv.ParseUint(timeoutStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse timeout: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(timeoutInt) * time.Second
+	}
+	if timeout == 0 || timeout > renter.MaxRegistryReadTimeout {
+		WriteError(w, Error{fmt.Sprintf("timeout needs to be between 1 and %v seconds", renter.MaxRegistryReadTimeout.Seconds())}, http.StatusBadRequest)
+		return
+	}
+	consistency, err := modules.ParseRegistryReadConsistency(req.FormValue("consistency"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse consistency: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var quorum float64
+	if quorumStr := req.FormValue("quorum"); quorumStr != "" {
+		quorum, err = strconv.ParseFloat(quorumStr, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse quorum: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if quorum <= 0 || quorum > 1 {
+			WriteError(w, Error{"quorum needs to be within (0, 1]"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	srv, err := api.renter.ReadRegistry(spk, tweak, timeout, consistency, quorum)
+	if errors.Contains(err, renter.ErrRegistryEntryNotFound) {
+		WriteError(w, Error{err.Error()}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"unable to read registry entry: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, RenterRegistryGET{
+		Data:      hex.EncodeToString(srv.Data),
+		Revision:  srv.Revision,
+		Signature: hex.EncodeToString(srv.Signature[:]),
+		Type:      srv.Type,
+	})
+}
+
+// renterRegistrySubscribeHandlerGET handles the API call to subscribe to
+// updates of a registry entry. The response is streamed and contains one JSON
+// encoded RenterRegistryGET object per line for every update until the client
+// closes the connection.
+func (api *API) renterRegistrySubscribeHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var spk types.SiaPublicKey
+	if err := spk.LoadString(req.FormValue("publickey")); err != nil {
+		WriteError(w, Error{"unable to parse publickey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	var tweak crypto.Hash
+	if err := tweak.LoadString(req.FormValue("datakey")); err != nil {
+		WriteError(w, Error{"unable to parse datakey: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		WriteError(w, Error{"streaming is not supported by the connection"}, http.StatusInternalServerError)
+		return
+	}
+	updates, cancel, err := api.renter.SubscribeRegistry(spk, tweak)
+	if err != nil {
+		WriteError(w, Error{"unable to subscribe to registry entry: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	defer cancel()
+
+	// Send the header right away to let the client know that the
+	// subscription was successful.
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-api.staticStreamsClosed:
+			return
+		case srv, ok := <-updates:
+			if !ok {
+				return
+			}
+			err := enc.Encode(RenterRegistryGET{
+				Data:      hex.EncodeToString(srv.Data),
+				Revision:  srv.Revision,
+				Signature: hex.EncodeToString(srv.Signature[:]),
+				Type:      srv.Type,
+			})
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// renterDirHeapResetHandlerPOST handles the API call to reset the renter's
+// directory heap.
+func (api *API) renterDirHeapResetHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	err := api.renter.ResetDirectoryHeap()
+	if err != nil {
+		WriteError(w, Error{"unable to reset directory heap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterDebugStuckCursorHandlerGET handles the API call to get the recent stuck
+// repair attempts the renter's stuck loop remembers.
+func (api *API) renterDebugStuckCursorHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	entries, err := api.renter.StuckCursor()
+	if err != nil {
+		WriteError(w, Error{"unable to get stuck cursor: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterStuckCursorGET{Entries: entries})
+}
+
+// renterAccountHandlerGET handles the API call to get information about the
+// renter's ephemeral account with a specific host.
+func (api *API) renterAccountHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
+	var hostKey types.SiaPublicKey
+	err := hostKey.LoadString(ps.ByName("hostpubkey"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse host key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	account, err := api.renter.Account(hostKey)
+	if err != nil {
+		WriteError(w, Error{"unable to get account: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, account)
+}
+
 // renterAllowanceCancelHandlerPOST handles the API call to cancel the Renter's
 // allowance
 func (api *API) renterAllowanceCancelHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
@@ -980,7 +1428,7 @@ func (api *API) renterAllowanceCancelHandlerPOST(w http.ResponseWriter, _ *http.
 	// Set the settings in the renter.
 	err = api.renter.SetSettings(settings)
 	if err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		api.writeRenterError(w, err, http.StatusBadRequest)
 		return
 	}
 	WriteSuccess(w)
@@ -1005,6 +1453,44 @@ func (api *API) renterCleanHandlerPOST(w http.ResponseWriter, _ *http.Request, _
 	WriteSuccess(w)
 }
 
+// renterRepairHandlerPOST handles the API call to immediately repair a file.
+func (api *API) renterRepairHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	root, err := scanBool(req.FormValue("root"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse root flag: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	siaPath, err := modules.NewSiaPath(req.FormValue("siapath"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse siapath: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if !root {
+		siaPath, err = rebaseInputSiaPath(siaPath)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	err = api.renter.RepairFile(siaPath)
+	if err != nil {
+		WriteError(w, Error{"unable to repair file: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterClearStuckHandlerPOST handles the API call to remove all stuck chunks
+// from the renter's upload heap.
+func (api *API) renterClearStuckHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	err := api.renter.ClearStuckChunks()
+	if err != nil {
+		WriteError(w, Error{"unable to clear stuck chunks: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
 // renterContractCancelHandler handles the API call to cancel a specific Renter contract.
 func (api *API) renterContractCancelHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
 	var fcid types.FileContractID
@@ -1097,6 +1583,16 @@ func (api *API) renterContractsHandler(w http.ResponseWriter, req *http.Request,
 	WriteJSON(w, contracts)
 }
 
+// setUtilization sets the max roots, utilization and utilization warning of the
+// contract given the host's collateral price.
+func (rc *RenterContract) setUtilization(c modules.RenterContract, collateral types.Currency, blockHeight types.BlockHeight) {
+	rc.MaxRoots = c.MaxRoots(collateral, blockHeight)
+	rc.Utilization = c.Utilization(rc.MaxRoots)
+	if rc.Utilization > contractUtilizationWarningThreshold {
+		rc.UtilizationWarning = fmt.Sprintf("contract utilization of %.2f%% exceeds %v%%, the contract is nearly full", rc.Utilization, contractUtilizationWarningThreshold)
+	}
+}
+
 // parseRenterContracts categorized the Renter's contracts from Contracts() and
 // OldContracts().
 func (api *API) parseRenterContracts(disabled, inactive, expired bool) RenterContracts {
@@ -1126,6 +1622,7 @@ func (api *API) parseRenterContracts(disabled, inactive, expired bool) RenterCon
 			NetAddress:                netAddress,
 			MaintenanceSpending:       c.MaintenanceSpending,
 			RenterFunds:               c.RenterFunds,
+			NumRoots:                  c.NumRoots,
 			Size:                      c.Size(),
 			StartHeight:               c.StartHeight,
 			StorageSpending:           c.StorageSpending,
@@ -1133,6 +1630,9 @@ func (api *API) parseRenterContracts(disabled, inactive, expired bool) RenterCon
 			TotalCost:                 c.TotalCost,
 			UploadSpending:            c.UploadSpending,
 		}
+		if exists {
+			contract.setUtilization(c, hdbe.Collateral, currentBlockHeight)
+		}
 
 		// Determine contract status
 		refreshed := api.renter.RefreshedContract(c.ID)
@@ -1191,6 +1691,7 @@ func (api *API) parseRenterContracts(disabled, inactive, expired bool) RenterCon
 			LastTransaction:           c.Transaction,
 			MaintenanceSpending:       c.MaintenanceSpending,
 			NetAddress:                netAddress,
+			NumRoots:                  c.NumRoots,
 			RenterFunds:               c.RenterFunds,
 			Size:                      size,
 			StartHeight:               c.StartHeight,
@@ -1199,6 +1700,9 @@ func (api *API) parseRenterContracts(disabled, inactive, expired bool) RenterCon
 			TotalCost:                 c.TotalCost,
 			UploadSpending:            c.UploadSpending,
 		}
+		if exists {
+			contract.setUtilization(c, hdbe.Collateral, currentBlockHeight)
+		}
 
 		// Determine contract status
 		refreshed := api.renter.RefreshedContract(c.ID)
@@ -1286,6 +1790,7 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, req *http.Request,
 			Destination:     di.Destination,
 			DestinationType: di.DestinationType,
 			Filesize:        di.Length,
+			Group:           di.Group,
 			Length:          di.Length,
 			Offset:          di.Offset,
 			SiaPath:         di.SiaPath,
@@ -1297,6 +1802,7 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, req *http.Request,
 			StartTime:            di.StartTime,
 			StartTimeUnix:        di.StartTimeUnix,
 			TotalDataTransferred: di.TotalDataTransferred,
+			Warnings:             di.Warnings,
 		})
 	}
 	WriteJSON(w, RenterDownloadQueue{
@@ -1304,6 +1810,97 @@ func (api *API) renterDownloadsHandler(w http.ResponseWriter, req *http.Request,
 	})
 }
 
+// renterDownloadGroupsHandlerGET handles the API call to
+// /renter/downloadgroups.
+func (api *API) renterDownloadGroupsHandlerGET(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	WriteJSON(w, RenterDownloadGroupsGET{
+		Groups: api.renter.DownloadGroups(),
+	})
+}
+
+// renterDownloadGroupsAwaitHandlerGET handles the API call to
+// /renter/downloadgroups/await. It blocks until the group finished, any of its
+// downloads failed if failfast is set, or the optional timeout in seconds is
+// reached.
+func (api *API) renterDownloadGroupsAwaitHandlerGET(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	group := req.FormValue("group")
+	if group == "" {
+		WriteError(w, Error{"group must be specified"}, http.StatusBadRequest)
+		return
+	}
+	var failFast bool
+	if failFastStr := req.FormValue("failfast"); failFastStr != "" {
+		var err error
+		failFast, err = scanBool(failFastStr)
+		if err != nil {
+			WriteError(w, Error{"unable to parse failfast: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	var timeout time.Duration
+	if timeoutStr := req.FormValue("timeout"); timeoutStr != "" {
+		timeoutInt, err := strconv.ParseUint(timeoutStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse timeout: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(timeoutInt) * time.Second
+	}
+	info, err := api.renter.AwaitDownloadGroup(group, failFast, timeout)
+	if errors.Contains(err, modules.ErrDownloadGroupNotFound) {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	} else if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, info)
+}
+
+// renterDownloadByRootHandlerGET handles the API call to download a sector, or
+// a range of it, by its merkle root from any host that stores it.
+func (api *API) renterDownloadByRootHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var root crypto.Hash
+	if err := root.LoadString(ps.ByName("root")); err != nil {
+		WriteError(w, Error{"unable to parse root: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	offset := uint64(0)
+	if offsetStr := req.FormValue("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.ParseUint(offsetStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse offset: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	length := modules.SectorSize - offset
+	if lengthStr := req.FormValue("length"); lengthStr != "" {
+		var err error
+		length, err = strconv.ParseUint(lengthStr, 10, 64)
+		if err != nil {
+			WriteError(w, Error{"unable to parse length: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	if offset >= modules.SectorSize || length == 0 || length > modules.SectorSize-offset {
+		WriteError(w, Error{fmt.Sprintf("offset and length need to describe a non-empty range within a sector of size %v", modules.SectorSize)}, http.StatusBadRequest)
+		return
+	}
+
+	data, err := api.renter.DownloadByRoot(root, offset, length)
+	if errors.Contains(err, renter.ErrRootNotFound) {
+		WriteError(w, Error{err.Error()}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"unable to download sector: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(data)
+}
+
 // renterDownloadByUIDHandlerGET handles the API call to /renter/downloadinfo.
 func (api *API) renterDownloadByUIDHandlerGET(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
 	uid := strings.TrimPrefix(ps.ByName("uid"), "/")
@@ -1322,6 +1919,7 @@ func (api *API) renterDownloadByUIDHandlerGET(w http.ResponseWriter, _ *http.Req
 		Destination:     di.Destination,
 		DestinationType: di.DestinationType,
 		Filesize:        di.Length,
+		Group:           di.Group,
 		Length:          di.Length,
 		Offset:          di.Offset,
 		SiaPath:         di.SiaPath,
@@ -1333,6 +1931,7 @@ func (api *API) renterDownloadByUIDHandlerGET(w http.ResponseWriter, _ *http.Req
 		StartTime:            di.StartTime,
 		StartTimeUnix:        di.StartTimeUnix,
 		TotalDataTransferred: di.TotalDataTransferred,
+		Warnings:             di.Warnings,
 	})
 }
 
@@ -1411,7 +2010,7 @@ func (api *API) renterFuseUnmountHandlerPOST(w http.ResponseWriter, req *http.Re
 // renterRecoveryScanHandlerPOST handles the API call to /renter/recoveryscan.
 func (api *API) renterRecoveryScanHandlerPOST(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
 	if err := api.renter.InitRecoveryScan(); err != nil {
-		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		api.writeRenterError(w, err, http.StatusBadRequest)
 		return
 	}
 	WriteSuccess(w)
@@ -1510,21 +2109,71 @@ func (api *API) renterFileHandlerGET(w http.ResponseWriter, req *http.Request, p
 		file = files[0]
 	}
 
+	// Fetch the chunk info if requested.
+	var chunks []modules.ChunkInfo
+	if req.FormValue("chunks") != "" {
+		includeChunks, err := scanBool(req.FormValue("chunks"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse chunks flag: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if includeChunks {
+			chunks, err = api.renter.FileChunks(siaPath)
+			if err != nil {
+				WriteError(w, Error{"unable to get chunk info: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	// Estimate the cost of repairing the file if requested. This requires
+	// going over all of the file's chunks so it is opt-in.
+	var repairCost *types.Currency
+	if req.FormValue("repaircost") != "" {
+		includeRepairCost, err := scanBool(req.FormValue("repaircost"))
+		if err != nil {
+			WriteError(w, Error{"unable to parse repaircost flag: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		if includeRepairCost {
+			cost, err := api.renter.FileRepairCostEstimate(siaPath)
+			if err != nil {
+				WriteError(w, Error{"unable to estimate repair cost: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+			repairCost = &cost
+		}
+	}
+
 	WriteJSON(w, RenterFile{
-		File: file,
+		File:               file,
+		Chunks:             chunks,
+		RepairCostEstimate: repairCost,
 	})
 }
 
-// renterFileHandler handles POST requests to the /renter/file/:siapath API endpoint.
+// renterFileHandler handles POST requests to the /renter/file/:siapath and
+// /renter/file/:siapath/localpath API endpoints.
 func (api *API) renterFileHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 	newTrackingPath := req.FormValue("trackingpath")
+	setTrackingPath := newTrackingPath != ""
+	siaPathStr := ps.ByName("siapath")
+	// The localpath endpoint can't be registered as its own route since it
+	// would clash with the siapath wildcard. Unlike the trackingpath, the
+	// localpath may be empty to remove the local path.
+	if _, ok := req.Form["localpath"]; ok && strings.HasSuffix(siaPathStr, "/localpath") {
+		siaPathStr = strings.TrimSuffix(siaPathStr, "/localpath")
+		newTrackingPath = req.FormValue("localpath")
+		setTrackingPath = true
+	}
 	stuck := req.FormValue("stuck")
+	abandoned := req.FormValue("abandoned")
 	root, err := scanBool(req.FormValue("root"))
 	if err != nil {
 		WriteError(w, Error{"unable to parse root flag: " + err.Error()}, http.StatusBadRequest)
 		return
 	}
-	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
+	siaPath, err := modules.NewSiaPath(siaPathStr)
 	if err != nil {
 		WriteError(w, Error{"unable to parse siapath: " + err.Error()}, http.StatusBadRequest)
 		return
@@ -1537,7 +2186,7 @@ func (api *API) renterFileHandlerPOST(w http.ResponseWriter, req *http.Request,
 		}
 	}
 	// Handle changing the tracking path of a file.
-	if newTrackingPath != "" {
+	if setTrackingPath {
 		if err := api.renter.SetFileTrackingPath(siaPath, newTrackingPath); err != nil {
 			WriteError(w, Error{fmt.Sprintf("unable set tracking path: %v", err)}, http.StatusBadRequest)
 			return
@@ -1555,6 +2204,34 @@ func (api *API) renterFileHandlerPOST(w http.ResponseWriter, req *http.Request,
 			return
 		}
 	}
+	// Handle resetting the abandoned chunks of a file.
+	if abandoned != "" {
+		a, err := strconv.ParseBool(abandoned)
+		if err != nil {
+			WriteError(w, Error{"unable to parse 'abandoned' arg"}, http.StatusBadRequest)
+			return
+		}
+		if a {
+			WriteError(w, Error{"chunks can't be abandoned manually, only 'abandoned=false' is supported"}, http.StatusBadRequest)
+			return
+		}
+		if err := api.renter.ResetFileAbandoned(siaPath); err != nil {
+			WriteError(w, Error{"failed to reset file 'abandoned' status: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+	// Handle changing the erasure code of a file.
+	ec, err := parseErasureCodingParameters(req.FormValue("datapieces"), req.FormValue("paritypieces"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse erasure code settings: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if ec != nil {
+		if err := api.renter.ChangeErasureCode(siaPath, ec); err != nil {
+			WriteError(w, Error{"failed to change the erasure code of the file: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
 	WriteSuccess(w)
 }
 
@@ -1665,6 +2342,30 @@ func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _
 		}
 	}
 
+	// Scan the size and redundancy of a file to estimate the upload cost for.
+	// (optional parameters)
+	var uploadEstimate *modules.RenterUploadCostEstimate
+	if s := req.FormValue("size"); s != "" {
+		var size uint64
+		if _, err := fmt.Sscan(s, &size); err != nil {
+			WriteError(w, Error{"unable to parse size: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		redundancy := float64(modules.RenterDefaultDataPieces+modules.RenterDefaultParityPieces) / float64(modules.RenterDefaultDataPieces)
+		if r := req.FormValue("redundancy"); r != "" {
+			if _, err := fmt.Sscan(r, &redundancy); err != nil {
+				WriteError(w, Error{"unable to parse redundancy: " + err.Error()}, http.StatusBadRequest)
+				return
+			}
+		}
+		e, err := api.renter.EstimateUploadCost(size, redundancy)
+		if err != nil {
+			WriteError(w, Error{"unable to estimate upload cost: " + err.Error()}, http.StatusBadRequest)
+			return
+		}
+		uploadEstimate = &e
+	}
+
 	estimate, a, err := api.renter.PriceEstimation(allowance)
 	if err != nil {
 		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
@@ -1673,6 +2374,7 @@ func (api *API) renterPricesHandler(w http.ResponseWriter, req *http.Request, _
 	WriteJSON(w, RenterPricesGET{
 		RenterPriceEstimation: estimate,
 		Allowance:             a,
+		UploadCostEstimate:    uploadEstimate,
 	})
 }
 
@@ -1809,6 +2511,9 @@ func parseDownloadParameters(w http.ResponseWriter, req *http.Request, ps httpro
 	// disk if available.
 	disablelocalfetchparam := req.FormValue("disablelocalfetch")
 
+	// The optional download group the download is added to.
+	group := req.FormValue("group")
+
 	// Parse the offset and length parameters.
 	var offset, length uint64
 	if len(offsetparam) > 0 {
@@ -1867,6 +2572,7 @@ func parseDownloadParameters(w http.ResponseWriter, req *http.Request, ps httpro
 		Destination:      destination,
 		DisableDiskFetch: disableLocalFetch,
 		Async:            async,
+		Group:            group,
 		Length:           length,
 		Offset:           offset,
 		SiaPath:          siaPath,
@@ -2005,6 +2711,29 @@ func (api *API) renterUploadReadyHandler(w http.ResponseWriter, req *http.Reques
 	})
 }
 
+// renterUploadHeapPruneHandlerPOST handles the API call to remove the chunks
+// below a health threshold from the upload heap.
+func (api *API) renterUploadHeapPruneHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	t := req.FormValue("threshold")
+	if t == "" {
+		WriteError(w, Error{"threshold must be specified"}, http.StatusBadRequest)
+		return
+	}
+	var threshold float64
+	if _, err := fmt.Sscan(t, &threshold); err != nil {
+		WriteError(w, Error{"unable to parse threshold: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	pruned, err := api.renter.PruneUploadHeap(threshold)
+	if err != nil {
+		WriteError(w, Error{"unable to prune upload heap: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteJSON(w, RenterUploadHeapPrunePOST{
+		Pruned: pruned,
+	})
+}
+
 // renterUploadsPauseHandler handles the api call to pause the renter's uploads,
 // this includes repairs
 func (api *API) renterUploadsPauseHandler(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
@@ -2105,6 +2834,27 @@ func (api *API) renterUploadStreamHandler(w http.ResponseWriter, req *http.Reque
 	WriteSuccess(w)
 }
 
+// renterRepairStreamHandler handles the API call to repair a file using its
+// content streamed in the request body.
+func (api *API) renterRepairStreamHandler(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	siaPath, err := modules.NewSiaPath(ps.ByName("siapath"))
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	siaPath, err = rebaseInputSiaPath(siaPath)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+	report, err := api.renter.RepairFromStream(siaPath, req.Body)
+	if err != nil {
+		WriteError(w, Error{"repair failed: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, RenterRepairStreamPOST{report})
+}
+
 // renterValidateSiaPathHandler handles the API call that validates a siapath
 func (api *API) renterValidateSiaPathHandler(w http.ResponseWriter, _ *http.Request, ps httprouter.Params) {
 	// Try and create a new siapath, this will validate the potential siapath
@@ -2188,6 +2938,50 @@ func (api *API) renterDirHandlerGET(w http.ResponseWriter, req *http.Request, ps
 	return
 }
 
+// renterDirHealthHandlerGET handles GET requests to /renter/dirhealth/:siapath
+// and returns a summary of the directory's health from its cached metadata.
+func (api *API) renterDirHealthHandlerGET(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	var siaPath modules.SiaPath
+	var err error
+
+	// Check whether the user is requesting the directory from the root path.
+	root, err := isCalledWithRootFlag(req)
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	str := ps.ByName("siapath")
+	if str == "" || str == "/" {
+		siaPath = modules.RootSiaPath()
+	} else {
+		siaPath, err = modules.NewSiaPath(str)
+	}
+	if err != nil {
+		WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if !root {
+		siaPath, err = rebaseInputSiaPath(siaPath)
+		if err != nil {
+			WriteError(w, Error{err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	summary, err := api.renter.DirHealthSummary(siaPath)
+	if errors.Contains(err, filesystem.ErrNotExist) {
+		WriteError(w, Error{err.Error()}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		WriteError(w, Error{"failed to get directory health: " + err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	WriteJSON(w, summary)
+}
+
 // renterDirHandlerPOST handles POST requests to /renter/dir/:siapath?action=<>
 // in order to create, delete, and rename a directory
 func (api *API) renterDirHandlerPOST(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
@@ -2301,3 +3095,37 @@ func (api *API) renterWorkersHandler(w http.ResponseWriter, _ *http.Request, _ h
 
 	WriteJSON(w, workerPoolStatus)
 }
+
+// renterWorkersDisableHandlerPOST handles the API call to disable the worker
+// for a host.
+func (api *API) renterWorkersDisableHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var hostKey types.SiaPublicKey
+	err := hostKey.LoadString(req.FormValue("hostkey"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse host key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.DisableWorker(hostKey)
+	if err != nil {
+		WriteError(w, Error{"unable to disable worker: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
+
+// renterWorkersEnableHandlerPOST handles the API call to enable a previously
+// disabled worker for a host.
+func (api *API) renterWorkersEnableHandlerPOST(w http.ResponseWriter, req *http.Request, _ httprouter.Params) {
+	var hostKey types.SiaPublicKey
+	err := hostKey.LoadString(req.FormValue("hostkey"))
+	if err != nil {
+		WriteError(w, Error{"unable to parse host key: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	err = api.renter.EnableWorker(hostKey)
+	if err != nil {
+		WriteError(w, Error{"unable to enable worker: " + err.Error()}, http.StatusBadRequest)
+		return
+	}
+	WriteSuccess(w)
+}
@@ -38,6 +38,10 @@ func (api *API) buildHTTPRoutes() {
 	router.GET("/daemon/constants", api.daemonConstantsHandler)
 	router.GET("/daemon/settings", api.daemonSettingsHandlerGET)
 	router.POST("/daemon/settings", api.daemonSettingsHandlerPOST)
+	// The self-test is only available once the node is loaded.
+	if api.SelfTest != nil {
+		router.POST("/daemon/selftest", RequirePassword(api.daemonSelfTestHandlerPOST, requiredPassword))
+	}
 	router.GET("/daemon/stack", api.daemonStackHandlerGET)
 	router.POST("/daemon/startprofile", api.daemonStartProfileHandlerPOST)
 	router.GET("/daemon/stop", RequirePassword(api.daemonStopHandler, requiredPassword))
@@ -80,15 +84,24 @@ func (api *API) buildHTTPRoutes() {
 	if api.renter != nil {
 		router.GET("/renter", api.renterHandlerGET)
 		router.POST("/renter", RequirePassword(api.renterHandlerPOST, requiredPassword))
+		router.GET("/renter/accounts", api.renterAccountsHandlerGET)
+		router.GET("/renter/account/:hostpubkey", api.renterAccountHandlerGET)
 		router.POST("/renter/allowance/cancel", RequirePassword(api.renterAllowanceCancelHandlerPOST, requiredPassword))
+		router.GET("/renter/bandwidth", api.renterBandwidthHandlerGET)
 		router.POST("/renter/bubble", api.renterBubbleHandlerPOST)
 		router.GET("/renter/backups", RequirePassword(api.renterBackupsHandlerGET, requiredPassword))
 		router.POST("/renter/backups/create", RequirePassword(api.renterBackupsCreateHandlerPOST, requiredPassword))
 		router.POST("/renter/backups/restore", RequirePassword(api.renterBackupsRestoreHandlerGET, requiredPassword))
 		router.POST("/renter/clean", RequirePassword(api.renterCleanHandlerPOST, requiredPassword))
+		router.POST("/renter/clearstuck", RequirePassword(api.renterClearStuckHandlerPOST, requiredPassword))
+		router.GET("/renter/debug/stuckcursor", api.renterDebugStuckCursorHandlerGET)
+		router.POST("/renter/dirheap/reset", RequirePassword(api.renterDirHeapResetHandlerPOST, requiredPassword))
 		router.POST("/renter/contract/cancel", RequirePassword(api.renterContractCancelHandler, requiredPassword))
 		router.GET("/renter/contracts", api.renterContractsHandler)
 		router.GET("/renter/contractorchurnstatus", api.renterContractorChurnStatus)
+		router.GET("/renter/downloadbyroot/:root", api.renterDownloadByRootHandlerGET)
+		router.GET("/renter/downloadgroups", api.renterDownloadGroupsHandlerGET)
+		router.GET("/renter/downloadgroups/await", api.renterDownloadGroupsAwaitHandlerGET)
 		router.GET("/renter/downloadinfo/*uid", api.renterDownloadByUIDHandlerGET)
 		router.GET("/renter/downloads", api.renterDownloadsHandler)
 		router.POST("/renter/downloads/clear", RequirePassword(api.renterClearDownloadsHandler, requiredPassword))
@@ -98,6 +111,11 @@ func (api *API) buildHTTPRoutes() {
 		router.GET("/renter/prices", api.renterPricesHandler)
 		router.POST("/renter/recoveryscan", RequirePassword(api.renterRecoveryScanHandlerPOST, requiredPassword))
 		router.GET("/renter/recoveryscan", api.renterRecoveryScanHandlerGET)
+		router.GET("/renter/registry", api.renterRegistryHandlerGET)
+		router.GET("/renter/registry/subscribe", api.renterRegistrySubscribeHandlerGET)
+		router.POST("/renter/repair", RequirePassword(api.renterRepairHandlerPOST, requiredPassword))
+		router.GET("/renter/repairmetrics", api.renterRepairMetricsHandlerGET)
+		router.GET("/renter/repairstatus", api.renterRepairStatusHandlerGET)
 		router.GET("/renter/fuse", api.renterFuseHandlerGET)
 		router.POST("/renter/fuse/mount", RequirePassword(api.renterFuseMountHandlerPOST, requiredPassword))
 		router.POST("/renter/fuse/unmount", RequirePassword(api.renterFuseUnmountHandlerPOST, requiredPassword))
@@ -110,15 +128,20 @@ func (api *API) buildHTTPRoutes() {
 		router.GET("/renter/stream/*siapath", api.renterStreamHandler)
 		router.POST("/renter/upload/*siapath", RequirePassword(api.renterUploadHandler, requiredPassword))
 		router.GET("/renter/uploadready", api.renterUploadReadyHandler)
+		router.POST("/renter/uploadheap/prune", RequirePassword(api.renterUploadHeapPruneHandlerPOST, requiredPassword))
 		router.POST("/renter/uploads/pause", RequirePassword(api.renterUploadsPauseHandler, requiredPassword))
 		router.POST("/renter/uploads/resume", RequirePassword(api.renterUploadsResumeHandler, requiredPassword))
 		router.POST("/renter/uploadstream/*siapath", RequirePassword(api.renterUploadStreamHandler, requiredPassword))
+		router.POST("/renter/repairstream/*siapath", RequirePassword(api.renterRepairStreamHandler, requiredPassword))
 		router.POST("/renter/validatesiapath/*siapath", RequirePassword(api.renterValidateSiaPathHandler, requiredPassword))
 		router.GET("/renter/workers", api.renterWorkersHandler)
+		router.POST("/renter/workers/disable", RequirePassword(api.renterWorkersDisableHandlerPOST, requiredPassword))
+		router.POST("/renter/workers/enable", RequirePassword(api.renterWorkersEnableHandlerPOST, requiredPassword))
 
 		// Directory endpoints
 		router.POST("/renter/dir/*siapath", RequirePassword(api.renterDirHandlerPOST, requiredPassword))
 		router.GET("/renter/dir/*siapath", api.renterDirHandlerGET)
+		router.GET("/renter/dirhealth/*siapath", api.renterDirHealthHandlerGET)
 
 		// HostDB endpoints.
 		router.GET("/hostdb", api.hostdbHandler)
@@ -152,8 +175,18 @@ func (api *API) buildHTTPRoutes() {
 	if err != nil {
 		build.Critical("marshalling error on object that should be safe to marshal:", err)
 	}
+	uaRouter := RequireUserAgent(router, requiredUserAgent)
+	timeoutRouter := http.TimeoutHandler(uaRouter, httpServerTimeout, string(jsonErr))
 	api.routerMu.Lock()
-	api.router = http.TimeoutHandler(RequireUserAgent(router, requiredUserAgent), httpServerTimeout, string(jsonErr))
+	api.router = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The timeout handler buffers the response, which doesn't work for
+		// long-lived streaming responses.
+		if isStreaming(req) {
+			uaRouter.ServeHTTP(w, req)
+			return
+		}
+		timeoutRouter.ServeHTTP(w, req)
+	})
 	api.routerMu.Unlock()
 	return
 }
@@ -189,6 +222,12 @@ func RequirePassword(h httprouter.Handle, password string) httprouter.Handle {
 	}
 }
 
+// isStreaming checks if a request is for an endpoint that streams its response
+// until the client closes the connection and therefore may bypass the timeout.
+func isStreaming(req *http.Request) bool {
+	return req.URL.Path == "/renter/registry/subscribe"
+}
+
 // isUnrestricted checks if a request may bypass the useragent check.
 func isUnrestricted(req *http.Request) bool {
 	return strings.HasPrefix(req.URL.Path, "/renter/stream/")
@@ -208,6 +208,10 @@ func NewAsync(APIaddr string, requiredUserAgent string, requiredPassword string,
 		// Set the shutdown method to allow the api to shutdown the server.
 		api.Shutdown = srv.Close
 
+		// Interrupt streaming handlers on shutdown. Otherwise Shutdown would
+		// wait for their clients to disconnect.
+		srv.apiServer.RegisterOnShutdown(api.CloseStreams)
+
 		// Spin up a goroutine that serves the API and closes srv.done when
 		// finished.
 		go func() {
@@ -236,6 +240,7 @@ func NewAsync(APIaddr string, requiredUserAgent string, requiredPassword string,
 
 		// Server wasn't shut down. Add node and replace modules.
 		srv.node = n
+		api.SelfTest = n.SelfTest
 		api.SetModules(n.Accounting, n.ConsensusSet, n.Explorer, n.Gateway, n.Host, n.Miner, n.Renter, n.TransactionPool, n.Wallet)
 		return srv, nil
 	}()
@@ -108,6 +108,10 @@ type NodeParams struct {
 	HostStorage uint64
 	RPCAddress  string
 
+	// HostVerifyMetadata forces a full verification of the host's on-disk
+	// sector metadata after the host is loaded.
+	HostVerifyMetadata bool
+
 	// Initialize node from existing seed.
 	PrimarySeed string
 
@@ -436,7 +440,18 @@ func New(params NodeParams, loadStartTime time.Time) (*Node, <-chan error) {
 		i++
 		printfRelease("(%d/%d) Loading host...\n", i, numModules)
 		host, err := host.NewCustomTestHost(hostDeps, smDeps, cs, g, tp, w, mux, params.HostAddress, filepath.Join(dir, modules.HostDir))
-		return host, err
+		if err != nil || !params.HostVerifyMetadata {
+			return host, err
+		}
+		printlnRelease("Verifying the sector metadata of the host...")
+		reports, err := host.VerifySectorMetadata()
+		if err != nil {
+			return nil, errors.Compose(errors.AddContext(err, "unable to verify the sector metadata"), host.Close())
+		}
+		for _, report := range reports {
+			printfRelease("%v: %v entries checked, %v corrupted, %v mismatched\n", report.Folder, report.Checked, report.Corrupted, report.Mismatched)
+		}
+		return host, nil
 	}()
 	if err != nil {
 		errChan <- errors.Extend(err, errors.New("unable to create host"))
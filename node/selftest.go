@@ -0,0 +1,298 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.com/NebulousLabs/errors"
+
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+const (
+	// selfTestClockMaxAhead is the amount of time the system clock may be
+	// ahead of the timestamp of the most recent block of a synced consensus
+	// set before the self-test warns about it.
+	selfTestClockMaxAhead = 12 * time.Hour
+)
+
+// SelfTestRequirements returns the persist directories of the modules that
+// would be created with the params. The requirements of the modules
+// themselves, like the space needed by the host's storage folders, are only
+// known once the modules are loaded.
+func (np NodeParams) SelfTestRequirements() modules.SelfTestRequirements {
+	dirs := []string{filepath.Join(np.Dir, modules.SiaMuxDir)}
+	for _, m := range []struct {
+		create bool
+		dir    string
+	}{
+		{np.CreateGateway, modules.GatewayDir},
+		{np.CreateConsensusSet, modules.ConsensusDir},
+		{np.CreateExplorer, modules.ExplorerDir},
+		{np.CreateTransactionPool, modules.TransactionPoolDir},
+		{np.CreateWallet, modules.WalletDir},
+		{np.CreateMiner, modules.MinerDir},
+		{np.CreateHost, modules.HostDir},
+		{np.CreateRenter, modules.RenterDir},
+		{np.CreateAccounting, modules.AccountingDir},
+	} {
+		if m.create {
+			dirs = append(dirs, filepath.Join(np.Dir, m.dir))
+		}
+	}
+	return modules.SelfTestRequirements{
+		PersistDirs: dirs,
+	}
+}
+
+// SelfTestAddresses returns the addresses the node would listen on with the
+// params.
+func (np NodeParams) SelfTestAddresses() []string {
+	addrs := []string{np.SiaMuxTCPAddress, np.SiaMuxWSAddress}
+	if np.CreateGateway {
+		addrs = append(addrs, np.RPCAddress)
+	}
+	if np.CreateHost {
+		addrs = append(addrs, np.HostAddress)
+	}
+	return addrs
+}
+
+// SelfTest runs the self-test against the node's modules. Ports aren't checked
+// since they are bound by the node itself.
+func (n *Node) SelfTest() modules.SelfTestReport {
+	reqs := modules.SelfTestRequirements{
+		PersistDirs: []string{filepath.Join(n.Dir, modules.SiaMuxDir)},
+	}
+	for _, m := range []struct {
+		module interface{}
+		dir    string
+	}{
+		{n.Gateway, modules.GatewayDir},
+		{n.ConsensusSet, modules.ConsensusDir},
+		{n.Explorer, modules.ExplorerDir},
+		{n.TransactionPool, modules.TransactionPoolDir},
+		{n.Wallet, modules.WalletDir},
+		{n.Miner, modules.MinerDir},
+		{n.Host, modules.HostDir},
+		{n.Renter, modules.RenterDir},
+		{n.Accounting, modules.AccountingDir},
+	} {
+		if m.module == nil {
+			continue
+		}
+		reqs.PersistDirs = append(reqs.PersistDirs, filepath.Join(n.Dir, m.dir))
+		if r, ok := m.module.(modules.SelfTestRequirer); ok {
+			reqs.Merge(r.SelfTestRequirements())
+		}
+	}
+	var tip types.Timestamp
+	var synced bool
+	if n.ConsensusSet != nil {
+		tip = n.ConsensusSet.CurrentBlock().Timestamp
+		// A consensus set without any blocks besides the genesis block
+		// considers itself synced if it has no peers.
+		synced = n.ConsensusSet.Synced() && n.ConsensusSet.Height() > 0
+	}
+	return SelfTest(reqs, nil, tip, synced)
+}
+
+// SelfTest checks the writability of the persist directories, the available
+// disk space, whether the addresses can be bound and the system clock. The
+// clock is compared to the timestamp of the most recent block if the consensus
+// set is synced.
+func SelfTest(reqs modules.SelfTestRequirements, addrs []string, tip types.Timestamp, synced bool) modules.SelfTestReport {
+	var results []modules.SelfTestResult
+	for _, dir := range reqs.PersistDirs {
+		results = append(results, checkPersistDir(dir))
+	}
+	results = append(results, checkDiskSpace(reqs.DiskSpace)...)
+	for _, addr := range addrs {
+		if addr == "" {
+			continue
+		}
+		results = append(results, checkPort(addr))
+	}
+	results = append(results, checkClock(types.CurrentTimestamp(), tip, synced))
+
+	report := modules.SelfTestReport{
+		Passed:  true,
+		Results: results,
+	}
+	for _, result := range results {
+		if result.Status == modules.SelfTestStatusFail {
+			report.Passed = false
+		}
+	}
+	return report
+}
+
+// existingAncestor returns dir or its closest ancestor which exists.
+func existingAncestor(dir string) (string, os.FileInfo, error) {
+	dir = filepath.Clean(dir)
+	for {
+		fi, err := os.Stat(dir)
+		if err == nil {
+			return dir, fi, nil
+		}
+		if !os.IsNotExist(err) {
+			return dir, nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil, err
+		}
+		dir = parent
+	}
+}
+
+// checkPersistDir checks that a persist directory, or the directory it would
+// be created in, is writable.
+func checkPersistDir(dir string) modules.SelfTestResult {
+	result := modules.SelfTestResult{
+		Check:  modules.SelfTestCheckPersistDir,
+		Target: dir,
+	}
+	fail := func(msg string) modules.SelfTestResult {
+		result.Status = modules.SelfTestStatusFail
+		result.Message = msg
+		result.Remediation = fmt.Sprintf("make sure that the user running siad owns %v and can write to it, e.g. with 'chown -R' and 'chmod u+rwx', or use a different directory with --sia-directory", dir)
+		return result
+	}
+	existing, fi, err := existingAncestor(dir)
+	if err != nil {
+		return fail(fmt.Sprintf("unable to access %v: %v", existing, err))
+	}
+	if !fi.IsDir() {
+		return fail(fmt.Sprintf("%v is not a directory", existing))
+	}
+	f, err := ioutil.TempFile(existing, ".siad-selftest-")
+	if err != nil {
+		return fail(fmt.Sprintf("unable to write to %v: %v", existing, err))
+	}
+	_, err = f.Write([]byte("siad self-test"))
+	err = errors.Compose(err, f.Close(), os.Remove(f.Name()))
+	if err != nil {
+		return fail(fmt.Sprintf("unable to write to %v: %v", existing, err))
+	}
+	result.Status = modules.SelfTestStatusPass
+	result.Message = "directory is writable"
+	if existing != dir {
+		result.Message = fmt.Sprintf("directory doesn't exist yet but can be created in %v", existing)
+	}
+	return result
+}
+
+// checkDiskSpace sums up the disk space requirements per filesystem and checks
+// them against the available disk space.
+func checkDiskSpace(reqs []modules.DiskSpaceRequirement) []modules.SelfTestResult {
+	type filesystem struct {
+		dir       string
+		available uint64
+		required  uint64
+		reasons   []string
+	}
+	var results []modules.SelfTestResult
+	var filesystems []*filesystem
+	byDevice := make(map[uint64]*filesystem)
+	for _, req := range reqs {
+		existing, _, err := existingAncestor(req.Dir)
+		var available, device uint64
+		if err == nil {
+			available, device, err = diskSpace(existing)
+		}
+		if err != nil {
+			results = append(results, modules.SelfTestResult{
+				Check:   modules.SelfTestCheckDiskSpace,
+				Target:  req.Dir,
+				Status:  modules.SelfTestStatusWarn,
+				Message: fmt.Sprintf("unable to determine the available disk space for %v: %v", req.Reason, err),
+			})
+			continue
+		}
+		fs, exists := byDevice[device]
+		if !exists {
+			fs = &filesystem{dir: req.Dir, available: available}
+			byDevice[device] = fs
+			filesystems = append(filesystems, fs)
+		}
+		fs.required += req.Bytes
+		fs.reasons = append(fs.reasons, req.Reason)
+	}
+	sort.Slice(filesystems, func(i, j int) bool {
+		return filesystems[i].dir < filesystems[j].dir
+	})
+	for _, fs := range filesystems {
+		result := modules.SelfTestResult{
+			Check:   modules.SelfTestCheckDiskSpace,
+			Target:  fs.dir,
+			Status:  modules.SelfTestStatusPass,
+			Message: fmt.Sprintf("%v required and %v available for %v", modules.FilesizeUnits(fs.required), modules.FilesizeUnits(fs.available), strings.Join(fs.reasons, ", ")),
+		}
+		if fs.required > fs.available {
+			result.Status = modules.SelfTestStatusFail
+			result.Remediation = fmt.Sprintf("free up %v on the filesystem of %v, or shrink the storage folders or the allowance's expected storage", modules.FilesizeUnits(fs.required-fs.available), fs.dir)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// checkPort checks that an address can be bound.
+func checkPort(addr string) modules.SelfTestResult {
+	result := modules.SelfTestResult{
+		Check:  modules.SelfTestCheckPort,
+		Target: addr,
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		result.Status = modules.SelfTestStatusFail
+		result.Message = fmt.Sprintf("unable to listen on %v: %v", addr, err)
+		result.Remediation = "stop the process which is using the port, e.g. another instance of siad, or configure a different address"
+		return result
+	}
+	if err := l.Close(); err != nil {
+		result.Status = modules.SelfTestStatusWarn
+		result.Message = fmt.Sprintf("unable to close listener on %v: %v", addr, err)
+		return result
+	}
+	result.Status = modules.SelfTestStatusPass
+	result.Message = "address can be bound"
+	return result
+}
+
+// checkClock checks that the system clock is after the genesis block and, if
+// the consensus set is synced, close to the timestamp of the most recent
+// block.
+func checkClock(now, tip types.Timestamp, synced bool) modules.SelfTestResult {
+	result := modules.SelfTestResult{
+		Check:       modules.SelfTestCheckClock,
+		Target:      "system clock",
+		Status:      modules.SelfTestStatusFail,
+		Remediation: "synchronize the system clock, e.g. by enabling NTP",
+	}
+	switch {
+	case now < types.GenesisTimestamp:
+		result.Message = fmt.Sprintf("system clock %v is before the genesis block", time.Unix(int64(now), 0))
+	case synced && tip > now+types.FutureThreshold:
+		result.Message = fmt.Sprintf("system clock is %v behind the most recent block", time.Duration(tip-now)*time.Second)
+	case synced && now > tip+types.Timestamp(selfTestClockMaxAhead.Seconds()):
+		result.Status = modules.SelfTestStatusWarn
+		result.Message = fmt.Sprintf("system clock is %v ahead of the most recent block", time.Duration(now-tip)*time.Second)
+	default:
+		result.Status = modules.SelfTestStatusPass
+		result.Remediation = ""
+		result.Message = "system clock is plausible"
+		if !synced {
+			result.Message += ", the clock wasn't compared to the blockchain since the consensus set isn't synced"
+		}
+	}
+	return result
+}
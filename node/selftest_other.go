@@ -0,0 +1,16 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package node
+
+import (
+	"errors"
+)
+
+// diskSpace returns the disk space available to unprivileged users on the
+// filesystem of dir and the id of the filesystem's device. It's only
+// implemented for Linux, macOS and Windows. On other platforms the self-test
+// only warns that the disk space couldn't be checked.
+func diskSpace(dir string) (available, device uint64, err error) {
+	return 0, 0, errors.New("checking the disk space is not supported on this platform")
+}
@@ -0,0 +1,164 @@
+package node
+
+import (
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.sia.tech/siad/build"
+	"go.sia.tech/siad/modules"
+	"go.sia.tech/siad/types"
+)
+
+// checkResult is a helper that checks the status of the only result of the
+// provided check in a report.
+func checkResult(t *testing.T, report modules.SelfTestReport, check string, status modules.SelfTestStatus) {
+	t.Helper()
+	var found []modules.SelfTestResult
+	for _, result := range report.Results {
+		if result.Check == check {
+			found = append(found, result)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("expected 1 %v result but got %v", check, len(found))
+	}
+	if found[0].Status != status {
+		t.Fatalf("expected %v check to %v but got %v: %v", check, status, found[0].Status, found[0].Message)
+	}
+	if status == modules.SelfTestStatusFail && found[0].Remediation == "" {
+		t.Fatal("failed check is missing a remediation hint")
+	}
+	if report.Passed != (status != modules.SelfTestStatusFail) {
+		t.Fatal("wrong passed flag", report.Passed)
+	}
+}
+
+// TestSelfTestPersistDir checks that the self-test detects persist
+// directories which can't be written to.
+func TestSelfTestPersistDir(t *testing.T) {
+	t.Parallel()
+	dir := build.TempDir("node", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// A directory which doesn't exist yet but can be created passes.
+	report := SelfTest(modules.SelfTestRequirements{PersistDirs: []string{filepath.Join(dir, "new", "dir")}}, nil, 0, false)
+	checkResult(t, report, modules.SelfTestCheckPersistDir, modules.SelfTestStatusPass)
+
+	// A file in place of the directory fails.
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte{1}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	report = SelfTest(modules.SelfTestRequirements{PersistDirs: []string{filepath.Join(file, "dir")}}, nil, 0, false)
+	checkResult(t, report, modules.SelfTestCheckPersistDir, modules.SelfTestStatusFail)
+
+	// A read-only directory fails. Root can write to it anyway.
+	if os.Geteuid() == 0 {
+		t.Log("skipping read-only directory check as root")
+		return
+	}
+	readOnly := filepath.Join(dir, "readonly")
+	if err := os.Mkdir(readOnly, 0500); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := os.Chmod(readOnly, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	report = SelfTest(modules.SelfTestRequirements{PersistDirs: []string{readOnly}}, nil, 0, false)
+	checkResult(t, report, modules.SelfTestCheckPersistDir, modules.SelfTestStatusFail)
+}
+
+// TestSelfTestDiskSpace checks that the self-test detects when the required
+// disk space exceeds the available disk space.
+func TestSelfTestDiskSpace(t *testing.T) {
+	t.Parallel()
+	dir := build.TempDir("node", t.Name())
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	// A small requirement passes.
+	reqs := modules.SelfTestRequirements{
+		DiskSpace: []modules.DiskSpaceRequirement{{Dir: dir, Bytes: modules.SectorSize, Reason: "test"}},
+	}
+	report := SelfTest(reqs, nil, 0, false)
+	checkResult(t, report, modules.SelfTestCheckDiskSpace, modules.SelfTestStatusPass)
+
+	// Requirements on the same filesystem are summed up into a single result.
+	reqs = modules.SelfTestRequirements{
+		DiskSpace: []modules.DiskSpaceRequirement{
+			{Dir: dir, Bytes: math.MaxUint64 / 2, Reason: "storage folder"},
+			{Dir: filepath.Join(dir, "doesntexist"), Bytes: math.MaxUint64 / 2, Reason: "renter metadata"},
+		},
+	}
+	report = SelfTest(reqs, nil, 0, false)
+	checkResult(t, report, modules.SelfTestCheckDiskSpace, modules.SelfTestStatusFail)
+}
+
+// TestSelfTestPort checks that the self-test detects addresses which are
+// already in use.
+func TestSelfTestPort(t *testing.T) {
+	t.Parallel()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+
+	// The address is in use.
+	report := SelfTest(modules.SelfTestRequirements{}, []string{addr}, 0, false)
+	checkResult(t, report, modules.SelfTestCheckPort, modules.SelfTestStatusFail)
+
+	// The address is free again.
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+	report = SelfTest(modules.SelfTestRequirements{}, []string{addr}, 0, false)
+	checkResult(t, report, modules.SelfTestCheckPort, modules.SelfTestStatusPass)
+}
+
+// TestCheckClock is a unit test for checkClock.
+func TestCheckClock(t *testing.T) {
+	t.Parallel()
+	now := types.CurrentTimestamp()
+	hour := types.Timestamp(time.Hour.Seconds())
+	tests := []struct {
+		now    types.Timestamp
+		tip    types.Timestamp
+		synced bool
+		status modules.SelfTestStatus
+	}{
+		// Not synced, only the genesis timestamp is checked.
+		{now, 0, false, modules.SelfTestStatusPass},
+		{types.GenesisTimestamp - 1, 0, false, modules.SelfTestStatusFail},
+		{now, now + 100*hour, false, modules.SelfTestStatusPass},
+
+		// Synced and close to the tip.
+		{now, now - hour, true, modules.SelfTestStatusPass},
+		{now, now + types.FutureThreshold, true, modules.SelfTestStatusPass},
+
+		// Clock behind the tip.
+		{now, now + types.FutureThreshold + 1, true, modules.SelfTestStatusFail},
+
+		// Clock far ahead of the tip.
+		{now, now - 13*hour, true, modules.SelfTestStatusWarn},
+	}
+	for i, test := range tests {
+		result := checkClock(test.now, test.tip, test.synced)
+		if result.Status != test.status {
+			t.Errorf("%v: expected %v but got %v: %v", i, test.status, result.Status, result.Message)
+		}
+		if result.Status == modules.SelfTestStatusFail && result.Remediation == "" {
+			t.Errorf("%v: missing remediation hint", i)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package node
+
+import (
+	"syscall"
+)
+
+// diskSpace returns the disk space available to unprivileged users on the
+// filesystem of dir and the id of the filesystem's device.
+func diskSpace(dir string) (available, device uint64, err error) {
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(dir, &fs); err != nil {
+		return 0, 0, err
+	}
+	var st syscall.Stat_t
+	if err := syscall.Stat(dir, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(fs.Bavail) * uint64(fs.Bsize), uint64(st.Dev), nil
+}
@@ -0,0 +1,26 @@
+package node
+
+import (
+	"golang.org/x/sys/windows"
+)
+
+// diskSpace returns the disk space available to the user on the volume of dir
+// and the serial number of the volume.
+func diskSpace(dir string) (available, device uint64, err error) {
+	path, err := windows.UTF16PtrFromString(dir)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := windows.GetDiskFreeSpaceEx(path, &available, nil, nil); err != nil {
+		return 0, 0, err
+	}
+	volume := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumePathName(path, &volume[0], uint32(len(volume))); err != nil {
+		return 0, 0, err
+	}
+	var serial uint32
+	if err := windows.GetVolumeInformation(&volume[0], nil, 0, &serial, nil, nil, nil, 0); err != nil {
+		return 0, 0, err
+	}
+	return available, uint64(serial), nil
+}
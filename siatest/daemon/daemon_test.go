@@ -12,6 +12,7 @@ import (
 
 	"go.sia.tech/siad/build"
 	"go.sia.tech/siad/crypto"
+	"go.sia.tech/siad/modules"
 	"go.sia.tech/siad/node"
 	"go.sia.tech/siad/node/api/client"
 	"go.sia.tech/siad/profile"
@@ -368,3 +369,57 @@ func TestDaemonProfile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+// TestDaemonSelfTest tests the /daemon/selftest endpoint.
+func TestDaemonSelfTest(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	testDir := daemonTestDir(t.Name())
+
+	// Create a new host with a storage folder.
+	testNode, err := siatest.NewCleanNode(node.Host(testDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err = testNode.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}()
+	if err := testNode.HostStorageFoldersAddPost(testNode.Dir, 1<<24); err != nil {
+		t.Fatal(err)
+	}
+
+	// The self-test requires the API password.
+	opts, err := client.DefaultOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.Address = testNode.Server.APIAddress()
+	opts.Password = hex.EncodeToString(fastrand.Bytes(16))
+	if _, err := client.New(opts).DaemonSelfTestPost(); err == nil {
+		t.Fatal("expected unauthenticated self-test to fail")
+	}
+
+	// Run the self-test.
+	dstp, err := testNode.DaemonSelfTestPost()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dstp.Passed {
+		t.Fatal("self-test failed", dstp.Results)
+	}
+
+	// The persist directory of every module and the disk space of the storage
+	// folder should have been checked. The ports are bound by the node and
+	// aren't checked.
+	checked := make(map[string]int)
+	for _, result := range dstp.Results {
+		checked[result.Check]++
+	}
+	if checked[modules.SelfTestCheckPersistDir] == 0 || checked[modules.SelfTestCheckDiskSpace] != 1 || checked[modules.SelfTestCheckClock] != 1 || checked[modules.SelfTestCheckPort] != 0 {
+		t.Fatal("unexpected checks", checked)
+	}
+}
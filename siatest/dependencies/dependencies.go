@@ -99,6 +99,12 @@ type (
 		modules.ProductionDependencies
 	}
 
+	// DependencyConsensusNotSynced prevents the consensus set from being
+	// marked as synced after startup.
+	DependencyConsensusNotSynced struct {
+		modules.ProductionDependencies
+	}
+
 	// DependencyDisableContractRecovery prevents recoverable contracts from
 	// being recovered in threadedContractMaintenance.
 	DependencyDisableContractRecovery struct {
@@ -458,6 +464,11 @@ func (d *DependencyDisableStreamClose) Disrupt(s string) bool {
 	return s == "DisableStreamClose"
 }
 
+// Disrupt returns true if the correct string is provided.
+func (d *DependencyConsensusNotSynced) Disrupt(s string) bool {
+	return s == "ConsensusNotSynced"
+}
+
 // Disrupt returns true if the correct string is provided.
 func (d *DependencySkipDeleteContractAfterRenewal) Disrupt(s string) bool {
 	return s == "SkipContractDeleteAfterRenew" || s == "DisableContractRecovery"
@@ -46,6 +46,29 @@ func (d *HostSlowDownload) Disrupt(s string) bool {
 	return s == "SlowDownload"
 }
 
+// HostSlowRegistryRead is a dependency injection for the host that will insert
+// a sleep on every registry read adding a latency to registry lookups.
+type HostSlowRegistryRead struct {
+	modules.ProductionDependencies
+}
+
+// Disrupt returns true if the correct string is provided.
+func (d *HostSlowRegistryRead) Disrupt(s string) bool {
+	return s == "SlowRegistryRead"
+}
+
+// HostSkewedClock is a dependency injection for the host that will make the
+// host report a time in its price tables that is an hour ahead of its actual
+// time.
+type HostSkewedClock struct {
+	modules.ProductionDependencies
+}
+
+// Disrupt returns true if the correct string is provided.
+func (d *HostSkewedClock) Disrupt(s string) bool {
+	return s == "SkewedClock"
+}
+
 // HostExpireEphemeralAccounts is a dependency injection for the host that will
 // expire ephemeral accounts as soon as they get pruned
 type HostExpireEphemeralAccounts struct {
@@ -2,6 +2,7 @@ package dependencies
 
 import (
 	"sync"
+	"time"
 
 	"go.sia.tech/siad/modules"
 )
@@ -145,6 +146,40 @@ func (d *DependencySkipPrepareNextChunk) Disrupt(s string) bool {
 	return s == "skipPrepareNextChunk"
 }
 
+// NewDependencySlowFetchAndRepair creates a dependency that delays the
+// fetching and repairing of every chunk by the provided duration.
+func NewDependencySlowFetchAndRepair(duration time.Duration) modules.Dependencies {
+	return newDependencyAddLatency("slowFetchAndRepair", duration)
+}
+
+// DependencyToggleSlowPersistWrites adds latency to the renter's writes to its
+// persist directory while enabled.
+type DependencyToggleSlowPersistWrites struct {
+	modules.ProductionDependencies
+	latency time.Duration
+	mu      sync.Mutex
+}
+
+// SetLatency sets the latency which is added to the writes. 0 disables the
+// dependency.
+func (d *DependencyToggleSlowPersistWrites) SetLatency(latency time.Duration) {
+	d.mu.Lock()
+	d.latency = latency
+	d.mu.Unlock()
+}
+
+// Disrupt sleeps for the set latency before a write to the persist directory.
+func (d *DependencyToggleSlowPersistWrites) Disrupt(s string) bool {
+	if s != "SlowPersistWrite" {
+		return false
+	}
+	d.mu.Lock()
+	latency := d.latency
+	d.mu.Unlock()
+	time.Sleep(latency)
+	return latency > 0
+}
+
 // DependencyDontUpdateStuckStatusOnCleanup will not set the chunk's stuck
 // status when cleaning up the upload chunk.
 type DependencyDontUpdateStuckStatusOnCleanup struct {
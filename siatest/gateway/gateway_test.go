@@ -1,7 +1,9 @@
 package gateway
 
 import (
+	"context"
 	"errors"
+	"net"
 	"testing"
 	"time"
 
@@ -72,6 +74,66 @@ func TestGatewayRatelimit(t *testing.T) {
 	}
 }
 
+// TestGatewayConnectTimeout makes sure that a connection attempt to an
+// unresponsive peer returns an error once the timeout is reached.
+func TestGatewayConnectTimeout(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+	testDir := gatewayTestDir(t.Name())
+
+	// Create a new server
+	testNode, err := siatest.NewCleanNode(node.Gateway(testDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := testNode.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Create a peer that accepts connections but never completes the
+	// handshake.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := l.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	// Connecting with a timeout of 1 second should fail after about a second.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	err = testNode.GatewayConnectPostWithContext(ctx, modules.NetAddress(l.Addr().String()))
+	if err == nil {
+		t.Fatal("connecting to an unresponsive peer should fail")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatal("connection attempt took too long", elapsed)
+	}
+}
+
 // TestGatewayBlocklist probes the gateway blocklist endpoints
 func TestGatewayBlocklist(t *testing.T) {
 	if testing.Short() {
@@ -286,6 +286,136 @@ func TestHostBandwidth(t *testing.T) {
 	}
 }
 
+// TestHostRenters confirms that the host attributes the data stored and the
+// bandwidth used by a renter to the renter.
+func TestHostRenters(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	gp := siatest.GroupParams{
+		Hosts:   2,
+		Renters: 1,
+		Miners:  1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(hostTestDir(t.Name()), gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Upload a file to both hosts.
+	renterNode := tg.Renters()[0]
+	if _, _, err := renterNode.UploadNewFileBlocking(100, 1, 1, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every host should report the renter's data and bandwidth.
+	for _, hostNode := range tg.Hosts() {
+		hrg, err := hostNode.HostRentersGet(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hrg.Renters) != 1 {
+			t.Fatal("expected 1 renter but got", len(hrg.Renters))
+		}
+		renter := hrg.Renters[0]
+		if renter.Contracts != 1 || renter.StoredBytes != modules.SectorSize {
+			t.Fatal("wrong storage", renter.Contracts, renter.StoredBytes)
+		}
+		if renter.BandwidthIn < modules.SectorSize || renter.BandwidthOut == 0 {
+			t.Fatal("wrong bandwidth", renter.BandwidthIn, renter.BandwidthOut)
+		}
+
+		// The anonymized key should be different.
+		hrg, err = hostNode.HostRentersGet(true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(hrg.Renters) != 1 || hrg.Renters[0].RenterKey == renter.RenterKey {
+			t.Fatal("key wasn't anonymized", hrg.Renters)
+		}
+	}
+}
+
+// TestHostRentersRenewal confirms that a renter is still reported as a single
+// renter after its contract with the host was renewed.
+func TestHostRentersRenewal(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	gp := siatest.GroupParams{
+		Hosts:   1,
+		Renters: 1,
+		Miners:  1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(hostTestDir(t.Name()), gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	renterNode, hostNode := tg.Renters()[0], tg.Hosts()[0]
+
+	// Get the renter's key before the renewal.
+	hrg, err := hostNode.HostRentersGet(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hrg.Renters) != 1 {
+		t.Fatal("expected 1 renter but got", len(hrg.Renters))
+	}
+	renterKey := hrg.Renters[0].RenterKey
+
+	// Renew the contract.
+	rc, err := renterNode.RenterContractsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rc.ActiveContracts) != 1 {
+		t.Fatal("expected 1 active contract but got", len(rc.ActiveContracts))
+	}
+	oldID := rc.ActiveContracts[0].ID
+	if err := siatest.RenewContractsByRenewWindow(renterNode, tg); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(200, 100*time.Millisecond, func() error {
+		rc, err := renterNode.RenterContractsGet()
+		if err != nil {
+			return err
+		}
+		if len(rc.ActiveContracts) != 1 || rc.ActiveContracts[0].ID == oldID {
+			return errors.New("contract wasn't renewed")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The host should still report a single renter with the same key.
+	hrg, err = hostNode.HostRentersGet(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hrg.Renters) != 1 {
+		t.Fatal("expected 1 renter but got", len(hrg.Renters))
+	}
+	if hrg.Renters[0].RenterKey != renterKey {
+		t.Fatal("renter key changed", hrg.Renters[0].RenterKey, renterKey)
+	}
+}
+
 // TestHostContracts confirms that the host contracts endpoint returns the expected values
 func TestHostContracts(t *testing.T) {
 	if testing.Short() {
@@ -281,6 +281,9 @@ func TestInterruptBackup(t *testing.T) {
 		if ubs.Backups[0].UploadProgress != 100 {
 			return fmt.Errorf("backup not uploaded, upload progress is %v", ubs.Backups[0].UploadProgress)
 		}
+		if ubs.Backups[0].RemainingChunks != 0 {
+			return fmt.Errorf("backup has %v remaining chunks", ubs.Backups[0].RemainingChunks)
+		}
 		return nil
 	})
 	if err != nil {
@@ -1,10 +1,12 @@
 package renter
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -26,6 +28,7 @@ import (
 	"go.sia.tech/siad/modules/renter"
 	"go.sia.tech/siad/modules/renter/contractor"
 	"go.sia.tech/siad/modules/renter/filesystem/siadir"
+	"go.sia.tech/siad/modules/renter/filesystem/siafile"
 	"go.sia.tech/siad/node"
 	"go.sia.tech/siad/node/api"
 	"go.sia.tech/siad/node/api/client"
@@ -53,6 +56,7 @@ func TestRenterOne(t *testing.T) {
 
 	// Specify subtests to run
 	subTests := []siatest.SubTest{
+		{Name: "TestDownloadHTTPResponseToFile", Test: testDownloadHTTPResponseToFile},
 		{Name: "TestDownloadMultipleLargeSectors", Test: testDownloadMultipleLargeSectors},
 		{Name: "TestLocalRepair", Test: testLocalRepair},
 		{Name: "TestClearDownloadHistory", Test: testClearDownloadHistory},
@@ -61,6 +65,9 @@ func TestRenterOne(t *testing.T) {
 		{Name: "TestPriceTablesUpdated", Test: testPriceTablesUpdated},
 		{Name: "TestFileAvailableAndRecoverable", Test: testFileAvailableAndRecoverable},
 		{Name: "TestReceivedFieldEqualsFileSize", Test: testReceivedFieldEqualsFileSize},
+		{Name: "TestRenterBandwidth", Test: testRenterBandwidth},
+		{Name: "TestRenterRepairMetrics", Test: testRenterRepairMetrics},
+		{Name: "TestDirHeapReset", Test: testDirHeapReset},
 	}
 
 	// Run tests
@@ -92,6 +99,8 @@ func TestRenterTwo(t *testing.T) {
 		{Name: "TestSiaFileTimestamps", Test: testSiafileTimestamps},
 		{Name: "TestZeroByteFile", Test: testZeroByteFile},
 		{Name: "TestUploadWithAndWithoutForceParameter", Test: testUploadWithAndWithoutForceParameter},
+		{Name: "TestDownloadByRoot", Test: testDownloadByRoot},
+		{Name: "TestWorkersNewHost", Test: testWorkersNewHost},
 	}
 
 	// Run tests
@@ -246,6 +255,7 @@ func TestRenterThree(t *testing.T) {
 		{Name: "TestAllowanceDefaultSet", Test: testAllowanceDefaultSet},
 		{Name: "TestSetFileStuck", Test: testSetFileStuck},
 		{Name: "TestCancelAsyncDownload", Test: testCancelAsyncDownload},
+		{Name: "TestDownloadGroup", Test: testDownloadGroup},
 		{Name: "TestUploadDownload", Test: testUploadDownload}, // Needs to be last as it impacts hosts
 	}
 
@@ -310,6 +320,228 @@ func testAllowanceDefaultSet(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testDownloadHTTPResponseToFile tests downloading a file to the http response
+// and to disk at the same time.
+func testDownloadHTTPResponseToFile(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Upload a file.
+	lf, rf, err := r.UploadNewFileBlocking(int(modules.SectorSize)+siatest.Fuzz(), 2, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Download it to the http response and to disk.
+	dst := filepath.Join(r.FilesDir().Path(), "tee.dat")
+	uid, data, err := r.RenterDownloadHTTPResponseToFileGet(rf.SiaPath(), dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both copies should match the uploaded file.
+	if err := lf.Equal(data); err != nil {
+		t.Fatal(err)
+	}
+	onDisk, err := ioutil.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Equal(onDisk); err != nil {
+		t.Fatal(err)
+	}
+
+	// The download info should report both destinations without warnings.
+	di, err := r.RenterDownloadInfoGet(uid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if di.DestinationType != "file and http stream" {
+		t.Fatal("unexpected destination type", di.DestinationType)
+	}
+	if len(di.Warnings) != 0 {
+		t.Fatal("unexpected warnings", di.Warnings)
+	}
+}
+
+// testRenterBandwidth tests that the renter tracks the bandwidth of uploads and
+// downloads.
+func testRenterBandwidth(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	before, err := r.RenterBandwidthGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload and download a file.
+	dataPieces, parityPieces := uint64(1), uint64(2)
+	fileSize := int(modules.SectorSize)
+	lf, rf, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = r.DownloadByStream(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RenterFileDeletePost(rf.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The totals should include the transferred pieces and the rates should be
+	// nonzero. Every rate is an average of samples which can't exceed the total
+	// bytes transferred within an update interval of 100ms.
+	minUploaded := (dataPieces + parityPieces) * modules.SectorSize
+	minDownloaded := uint64(fileSize)
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rb, err := r.RenterBandwidthGet()
+		if err != nil {
+			return err
+		}
+		if rb.TotalUploaded-before.TotalUploaded < minUploaded {
+			return fmt.Errorf("expected at least %v bytes uploaded but got %v", minUploaded, rb.TotalUploaded-before.TotalUploaded)
+		}
+		if rb.TotalDownloaded-before.TotalDownloaded < minDownloaded {
+			return fmt.Errorf("expected at least %v bytes downloaded but got %v", minDownloaded, rb.TotalDownloaded-before.TotalDownloaded)
+		}
+		if rb.UploadRate == 0 || rb.DownloadRate == 0 {
+			return fmt.Errorf("expected nonzero rates but got %v and %v", rb.UploadRate, rb.DownloadRate)
+		}
+		if rb.UploadRate > 10*rb.TotalUploaded || rb.DownloadRate > 10*rb.TotalDownloaded {
+			return fmt.Errorf("rates are too high: %+v", rb)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The workers should report the bandwidth as well.
+	rwg, err := r.RenterWorkersGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var workerUploaded, workerDownloaded uint64
+	for _, w := range rwg.Workers {
+		workerUploaded += w.BytesUploaded
+		workerDownloaded += w.BytesDownloaded
+	}
+	if workerUploaded < minUploaded || workerDownloaded < minDownloaded {
+		t.Fatalf("unexpected worker bandwidth %v %v", workerUploaded, workerDownloaded)
+	}
+	// Workers which transferred data should report a speed.
+	for _, w := range rwg.Workers {
+		if w.BytesUploaded > 0 && w.UploadSpeedBytesPerSec <= 0 {
+			t.Fatal("expected nonzero upload speed", w.HostPubKey)
+		}
+		if w.BytesDownloaded > 0 && w.DownloadSpeedBytesPerSec <= 0 {
+			t.Fatal("expected nonzero download speed", w.HostPubKey)
+		}
+	}
+}
+
+// testRenterRepairMetrics tests that the renter tracks the progress of new
+// uploads in its repair metrics.
+func testRenterRepairMetrics(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	before, err := r.RenterRepairMetricsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a new file.
+	dataPieces, parityPieces := uint64(1), uint64(2)
+	lf, rf, err := r.UploadNewFileBlocking(int(modules.SectorSize), dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.RenterFileDeletePost(rf.SiaPath()); err != nil {
+			t.Fatal(err)
+		}
+		if err := lf.Delete(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The chunk should be counted as repaired and its pieces as new bytes
+	// both in the totals and in the recent metrics.
+	minUploaded := (dataPieces + parityPieces) * modules.SectorSize
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rrm, err := r.RenterRepairMetricsGet()
+		if err != nil {
+			return err
+		}
+		if rrm.Total.NewBytesUploaded-before.Total.NewBytesUploaded < minUploaded {
+			return fmt.Errorf("expected at least %v new bytes uploaded but got %v", minUploaded, rrm.Total.NewBytesUploaded-before.Total.NewBytesUploaded)
+		}
+		if rrm.Total.ChunksRepaired <= before.Total.ChunksRepaired {
+			return fmt.Errorf("expected repaired chunks to increase from %v", before.Total.ChunksRepaired)
+		}
+		if rrm.Recent.NewBytesUploaded < minUploaded || rrm.Recent.ChunksRepaired == 0 {
+			return fmt.Errorf("unexpected recent metrics %+v", rrm.Recent)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testDirHeapReset tests that forcing a reset of the directory heap while
+// files are being repaired is tracked in the repair metrics and that the
+// repair loop recovers from it.
+func testDirHeapReset(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+	before, err := r.RenterRepairMetricsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Start uploading a few files and reset the directory heap while they are
+	// being uploaded.
+	var rfs []*siatest.RemoteFile
+	for i := 0; i < 3; i++ {
+		_, rf, err := r.UploadNewFile(int(2*modules.SectorSize), 1, 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rfs = append(rfs, rf)
+		if err := r.RenterDirHeapResetPost(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The resets should be visible in the repair metrics.
+	after, err := r.RenterRepairMetricsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dh := after.DirectoryHeap
+	if dh.ManualResets != before.DirectoryHeap.ManualResets+3 {
+		t.Fatalf("expected %v manual resets but got %v", before.DirectoryHeap.ManualResets+3, dh.ManualResets)
+	}
+	if dh.Resets < before.DirectoryHeap.Resets+3 || dh.Initializations < before.DirectoryHeap.Initializations+3 {
+		t.Fatal("resets and initializations weren't counted", dh)
+	}
+	if !dh.LastReset.After(before.DirectoryHeap.LastReset) || !dh.LastInitialization.After(before.DirectoryHeap.LastInitialization) {
+		t.Fatal("timestamps weren't updated", dh)
+	}
+
+	// The repair loop should recover and upload the files.
+	for _, rf := range rfs {
+		if err := r.WaitForUploadHealth(rf); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.RenterFileDeletePost(rf.SiaPath()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 // testReceivedFieldEqualsFileSize tests that the bug that caused finished
 // downloads to stall in the UI and siac is gone.
 func testReceivedFieldEqualsFileSize(t *testing.T, tg *siatest.TestGroup) {
@@ -1383,6 +1615,99 @@ func testCancelAsyncDownload(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testDownloadGroup tests awaiting a download group with one successful and
+// one cancelled download using both the fail-fast and the continue policy.
+func testDownloadGroup(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	renter := tg.Renters()[0]
+	// Upload two files, creating a piece for each host in the group
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	fileSize := 20 * modules.SectorSize
+	localFile1, remoteFile1, err := renter.UploadNewFileBlocking(int(fileSize), dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+	localFile2, remoteFile2, err := renter.UploadNewFileBlocking(int(fileSize), dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal("Failed to upload a file for testing: ", err)
+	}
+	// Delete the local files to force the downloads to use the hosts.
+	if err := errors.Compose(localFile1.Delete(), localFile2.Delete()); err != nil {
+		t.Fatal(err)
+	}
+	// Set a ratelimit that slows down the downloads enough for the second
+	// download to be cancelled before it completes.
+	if err := renter.RenterRateLimitPost(int64(5*modules.SectorSize), 0); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := renter.RenterRateLimitPost(0, 0); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	// Download both files asynchronously as part of the same group and cancel
+	// the second download.
+	group := "nightly"
+	dst1 := filepath.Join(renter.FilesDir().Path(), "group_download1.dat")
+	_, err = renter.RenterDownloadGroupGet(remoteFile1.SiaPath(), dst1, group, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst2 := filepath.Join(renter.FilesDir().Path(), "group_download2.dat")
+	cancelID, err := renter.RenterDownloadGroupGet(remoteFile2.SiaPath(), dst2, group, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterCancelDownloadPost(cancelID); err != nil {
+		t.Fatal(err)
+	}
+	// The download info should contain the group.
+	di, err := renter.RenterDownloadInfoGet(cancelID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if di.Group != group {
+		t.Fatalf("expected group %v but got %v", group, di.Group)
+	}
+	// Using the fail-fast policy, the group should be returned right away
+	// since the second download failed.
+	dgi, err := renter.RenterDownloadGroupAwaitGet(group, true, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dgi.FilesTotal != 2 || dgi.FilesFailed != 1 || dgi.Finished != (dgi.FilesCompleted == 1) {
+		t.Fatal("unexpected download group info", dgi)
+	}
+	if len(dgi.Errors) != 1 || !strings.Contains(dgi.Errors[0], modules.ErrDownloadCancelled.Error()) {
+		t.Fatal("unexpected errors", dgi.Errors)
+	}
+	// Using the continue policy, the group should be returned once the first
+	// download finished.
+	dgi, err = renter.RenterDownloadGroupAwaitGet(group, false, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dgi.Finished || dgi.FilesTotal != 2 || dgi.FilesFailed != 1 || dgi.FilesCompleted != 1 {
+		t.Fatal("unexpected download group info", dgi)
+	}
+	if dgi.BytesTotal != 2*fileSize || dgi.BytesReceived < fileSize {
+		t.Fatal("unexpected download group bytes", dgi.BytesReceived, dgi.BytesTotal)
+	}
+	// The group should be listed.
+	rdg, err := renter.RenterDownloadGroupsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, g := range rdg.Groups {
+		found = found || g.Name == group
+	}
+	if !found {
+		t.Fatal("group wasn't listed", rdg.Groups)
+	}
+}
+
 // testUploadDownload is a subtest that uses an existing TestGroup to test if
 // uploading and downloading a file works
 func testUploadDownload(t *testing.T, tg *siatest.TestGroup) {
@@ -3234,6 +3559,24 @@ func TestSetFileTrackingPath(t *testing.T) {
 	if err := renter.SetFileRepairPath(remoteFile, smallFile); err == nil {
 		t.Fatal("Changing repair path to a nonexistent file shouldn't work")
 	}
+
+	// The same checks apply to the /renter/localpath endpoint.
+	if err := renter.RenterSetLocalPathPost(remoteFile.SiaPath(), smallFile.Path()); err == nil {
+		t.Fatal("Changing local path to a nonexistent file shouldn't work")
+	}
+	if err := renter.RenterSetLocalPathPost(remoteFile.SiaPath(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := renter.RenterSetLocalPathPost(remoteFile.SiaPath(), localFile.Path()); err != nil {
+		t.Fatal(err)
+	}
+	rf, err := renter.RenterFileGet(remoteFile.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rf.File.LocalPath != localFile.Path() {
+		t.Fatalf("expected local path %v but got %v", localFile.Path(), rf.File.LocalPath)
+	}
 }
 
 // TestRenterFileContractIdentifier checks that the file contract's identifier
@@ -4178,78 +4521,192 @@ func TestOutOfStorageHandling(t *testing.T) {
 	}
 }
 
-// TestAsyncStartupRace queries some of the modules endpoints during an async
-// startup.
-func TestAsyncStartupRace(t *testing.T) {
+// TestContractFullHandling makes sure that the renter stops uploading to a host
+// once its contract reached the host's max contract size while still keeping
+// the contract around as goodForRenew.
+func TestContractFullHandling(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
 	}
 	t.Parallel()
 
+	// Create a group with 2 default hosts.
+	gp := siatest.GroupParams{
+		Hosts:  2,
+		Miners: 1,
+	}
 	testDir := renterTestDir(t.Name())
-	np := node.AllModules(testDir)
-	// Disable the async startup part of the modules.
-	deps := &dependencies.DependencyDisableAsyncStartup{}
-	np.ConsensusSetDeps = deps
-	np.ContractorDeps = deps
-	np.HostDBDeps = deps
-	np.RenterDeps = deps
-	// Disable the modules which aren't loaded async anyway.
-	np.CreateExplorer = false
-	np.CreateHost = false
-	np.CreateMiner = false
-	node, err := siatest.NewCleanNodeAsync(np)
+	tg, err := siatest.NewGroupFromTemplate(testDir, gp)
 	if err != nil {
 		t.Fatal(err)
 	}
-	// Call some endpoints a few times.
-	var wg sync.WaitGroup
-	for i := 0; i < 10; i++ {
-		// ConsensusSet
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, err := node.ConsensusGet()
-			if err != nil {
-				t.Error(err)
-			}
-		}()
-		// Contractor
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, err := node.RenterContractsGet()
-			if err != nil {
-				t.Error(err)
-			}
-		}()
-		// HostDB
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, err := node.HostDbAllGet()
-			if err != nil {
-				t.Error(err)
-				return
-			}
-			_, err = node.HostDbGet()
-			if err != nil {
-				t.Error(err)
-			}
-		}()
-		// Renter
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			_, err := node.RenterGet()
-			if err != nil {
-				t.Error(err)
-			}
-		}()
-		wg.Wait()
-	}
-}
-
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Add a host which only allows for a single sector per contract.
+	nodes, err := tg.AddNodes(node.Host(filepath.Join(testDir, "host")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := nodes[0]
+	err = host.HostModifySettingPost(client.HostParamMaxContractSize, modules.SectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hg, err := host.HostGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hg.ExternalSettings.MaxContractSize != modules.SectorSize {
+		t.Fatal("max contract size wasn't advertised", hg.ExternalSettings.MaxContractSize)
+	}
+	hpk, err := host.HostPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Add a renter which uses all 3 hosts.
+	renterTemplate := node.Renter(filepath.Join(testDir, "renter"))
+	allowance := siatest.DefaultAllowance
+	allowance.Hosts = 3
+	renterTemplate.Allowance = allowance
+	nodes, err = tg.AddNodes(renterTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	renter := nodes[0]
+
+	// Upload a file which fills up the contract with the host.
+	dataPieces, parityPieces := uint64(1), uint64(2)
+	_, _, err = renter.UploadNewFileBlocking(int(modules.SectorSize), dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Upload another file. The host should reject the upload.
+	_, _, err = renter.UploadNewFile(int(modules.SectorSize), dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Make sure the host's contract is no longer good for upload but still good
+	// for renew.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rcg, err := renter.RenterContractsGet()
+		if err != nil {
+			return err
+		}
+		if len(rcg.ActiveContracts) != 2 {
+			return fmt.Errorf("Expected 2 active contracts but got %v", len(rcg.ActiveContracts))
+		}
+		if len(rcg.PassiveContracts) != 1 {
+			return fmt.Errorf("Expected 1 passive contract but got %v", len(rcg.PassiveContracts))
+		}
+		if !rcg.PassiveContracts[0].HostPublicKey.Equals(hpk) {
+			return errors.New("Passive contract doesn't belong to the host")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The contract should still be passive after maintenance.
+	if err := tg.Miners()[0].MineBlock(); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rcg, err := renter.RenterContractsGet()
+		if err != nil {
+			return err
+		}
+		if len(rcg.PassiveContracts) != 1 || !rcg.PassiveContracts[0].HostPublicKey.Equals(hpk) {
+			return errors.New("contract with the host should be passive")
+		}
+		if rcg.PassiveContracts[0].Size != modules.SectorSize {
+			return fmt.Errorf("contract should contain a single sector but had size %v", rcg.PassiveContracts[0].Size)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestAsyncStartupRace queries some of the modules endpoints during an async
+// startup.
+func TestAsyncStartupRace(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	testDir := renterTestDir(t.Name())
+	np := node.AllModules(testDir)
+	// Disable the async startup part of the modules.
+	deps := &dependencies.DependencyDisableAsyncStartup{}
+	np.ConsensusSetDeps = deps
+	np.ContractorDeps = deps
+	np.HostDBDeps = deps
+	np.RenterDeps = deps
+	// Disable the modules which aren't loaded async anyway.
+	np.CreateExplorer = false
+	np.CreateHost = false
+	np.CreateMiner = false
+	node, err := siatest.NewCleanNodeAsync(np)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Call some endpoints a few times.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		// ConsensusSet
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := node.ConsensusGet()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		// Contractor
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := node.RenterContractsGet()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		// HostDB
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := node.HostDbAllGet()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, err = node.HostDbGet()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		// Renter
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := node.RenterGet()
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+		wg.Wait()
+	}
+}
+
 // testRenterPostCancelAllowance tests setting and cancelling the allowance
 // through the /renter POST endpoint
 func testRenterPostCancelAllowance(t *testing.T, tg *siatest.TestGroup) {
@@ -5016,7 +5473,8 @@ func TestWorkerSyncBalanceWithHost(t *testing.T) {
 }
 
 // TestRenterPricesVolatility verifies that the renter caches its price
-// estimation, and subsequent calls result in non-volatile results.
+// estimation, and subsequent calls result in non-volatile results. It also
+// checks that the upload cost estimate scales with the size of the file.
 func TestRenterPricesVolatility(t *testing.T) {
 	if testing.Short() {
 		t.SkipNow()
@@ -5079,6 +5537,33 @@ func TestRenterPricesVolatility(t *testing.T) {
 		t.Log("After:", string(afterJSON))
 		t.Fatal("expected renter price estimation to be constant")
 	}
+
+	// Estimate the upload cost of a file. The estimate should be available
+	// once the workers have fetched their price tables.
+	var small *modules.RenterUploadCostEstimate
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rpg, err := renter.RenterPricesUploadCostGet(modules.SectorSize, 2)
+		if err != nil {
+			return err
+		}
+		small = rpg.UploadCostEstimate
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if small == nil || small.Estimate.IsZero() {
+		t.Fatal("expected an upload cost estimate", small)
+	}
+	// A larger file should be more expensive.
+	size := 10 * uint64(modules.RenterDefaultDataPieces) * modules.SectorSize
+	rpg, err = renter.RenterPricesUploadCostGet(size, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rpg.UploadCostEstimate == nil || rpg.UploadCostEstimate.Estimate.Cmp(small.Estimate) <= 0 {
+		t.Fatal("expected a larger estimate for a larger file", rpg.UploadCostEstimate, small)
+	}
 }
 
 // TestRenterPricesVolatility verifies that the renter caches its price
@@ -5724,3 +6209,386 @@ func TestRenterBubble(t *testing.T) {
 		}
 	}
 }
+
+// TestFileAvailabilityColocatedHosts tests that a file whose pieces are stored
+// on hosts within the same subnet is only reported as available and resilient
+// while the IP violation check is disabled.
+func TestFileAvailabilityColocatedHosts(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a group with a few hosts.
+	testDir := renterTestDir(t.Name())
+	groupParams := siatest.GroupParams{
+		Hosts:  3,
+		Miners: 1,
+	}
+	tg, err := siatest.NewGroupFromTemplate(testDir, groupParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// Reannounce the hosts with custom hostnames which are resolved by the
+	// renter's custom resolver.
+	for i, host := range tg.Hosts() {
+		hg, err := host.HostGet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := modules.NetAddress(fmt.Sprintf("host%d.com:%s", i+1, hg.ExternalSettings.NetAddress.Port()))
+		if err := host.HostAnnounceForcePost(addr); err != nil {
+			t.Fatal(err)
+		}
+		if err := tg.Miners()[0].MineBlock(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Add a renter with a custom resolver which places all hosts within the
+	// same subnet. The allowance is set after disabling the IP violation check
+	// since the renter wouldn't form contracts with all hosts otherwise.
+	renterTemplate := node.Renter(testDir + "/renter")
+	renterTemplate.HostDBDeps = dependencies.NewDependencyCustomResolver(func(host string) ([]net.IP, error) {
+		switch host {
+		case "host1.com":
+			return []net.IP{{128, 0, 0, 1}}, nil
+		case "host2.com":
+			return []net.IP{{128, 0, 0, 2}}, nil
+		case "host3.com":
+			return []net.IP{{128, 0, 0, 3}}, nil
+		default:
+			return []net.IP{{127, 0, 0, 1}}, nil
+		}
+	})
+	renterTemplate.ContractorDeps = renterTemplate.HostDBDeps
+	renterTemplate.ContractSetDeps = renterTemplate.HostDBDeps
+	renterTemplate.SkipSetAllowance = true
+	nodes, err := tg.AddNodes(renterTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := nodes[0]
+	if err := r.RenterSetCheckIPViolationPost(false); err != nil {
+		t.Fatal(err)
+	}
+	if err := tg.SetRenterAllowance(r, siatest.DefaultAllowance); err != nil {
+		t.Fatal(err)
+	}
+
+	// Upload a file which requires 2 pieces for recovery. Since the subnets
+	// are ignored, it should be available and resilient once the metadata of
+	// the uploaded chunk was updated.
+	_, rf, err := r.UploadNewFileBlocking(int(modules.SectorSize), 2, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		file, err := r.File(rf)
+		if err != nil {
+			return err
+		}
+		if !file.Available || !file.Resilient {
+			return fmt.Errorf("file should be available and resilient %v %v", file.Available, file.Resilient)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enable the IP violation check. After the next bubble the file should
+	// neither be available nor resilient anymore since only a single piece is
+	// stored within a distinct subnet.
+	if err := r.RenterSetCheckIPViolationPost(true); err != nil {
+		t.Fatal(err)
+	}
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		if err := r.RenterBubblePost(modules.RootSiaPath(), true, true); err != nil {
+			return err
+		}
+		file, err := r.File(rf)
+		if err != nil {
+			return err
+		}
+		if file.Available || file.Resilient {
+			return fmt.Errorf("file shouldn't be available or resilient %v %v", file.Available, file.Resilient)
+		}
+		if file.Redundancy < 1 {
+			return fmt.Errorf("file should still have a redundancy of at least 1 but was %v", file.Redundancy)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestChangeErasureCode tests changing the erasure code of a file whose local
+// copy is gone across a restart of the renter.
+func TestChangeErasureCode(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a group with 3 hosts and a renter.
+	gp := siatest.GroupParams{
+		Hosts:   3,
+		Renters: 1,
+		Miners:  1,
+	}
+	testDir := renterTestDir(t.Name())
+	tg, err := siatest.NewGroupFromTemplate(testDir, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := tg.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	r := tg.Renters()[0]
+
+	// Upload a file with 2 chunks and delete the local copy to force the
+	// conversion to download the data.
+	lf, rf, err := r.UploadNewFileBlocking(int(2*modules.SectorSize), 1, 2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := lf.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := lf.Delete(); err != nil {
+		t.Fatal(err)
+	}
+	original, err := r.File(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change the erasure code and restart the renter right away.
+	err = r.RenterChangeErasureCodePost(rf.SiaPath(), false, 2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.RenterChangeErasureCodePost(rf.SiaPath(), false, 2, 1)
+	if err == nil || !strings.Contains(err.Error(), "already being changed") {
+		t.Fatal("expected conversion to be in progress", err)
+	}
+	if err := r.RestartNode(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for the conversion to finish. The new erasure code results in a
+	// redundancy of 1.5.
+	err = build.Retry(200, 100*time.Millisecond, func() error {
+		fi, err := r.File(rf)
+		if err != nil {
+			return err
+		}
+		if fi.Converting {
+			return fmt.Errorf("file still converting, progress %v", fi.ConversionProgress)
+		}
+		if fi.Redundancy != 1.5 {
+			return fmt.Errorf("expected redundancy 1.5 but got %v", fi.Redundancy)
+		}
+		// The file should still look like the original file.
+		if !fi.CreateTime.Equal(original.CreateTime) {
+			return fmt.Errorf("expected create time %v but got %v", original.CreateTime, fi.CreateTime)
+		}
+		// The conversions folder should be empty.
+		rd, err := r.RenterDirRootGet(modules.ConversionsFolder)
+		if err != nil {
+			return err
+		}
+		if n := rd.Directories[0].AggregateNumFiles; n != 0 {
+			return fmt.Errorf("expected no conversions but got %v", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The file should still be downloadable.
+	_, downloaded, err := r.DownloadByStream(rf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, downloaded) {
+		t.Fatal("downloaded data doesn't match the original data")
+	}
+}
+
+// TestRenterSyncStatus checks that a renter with an unsynced consensus set
+// reports its sync status and returns informative errors for operations that
+// are blocked until the consensus set is synced.
+func TestRenterSyncStatus(t *testing.T) {
+	if testing.Short() {
+		t.SkipNow()
+	}
+	t.Parallel()
+
+	// Create a renter that never finishes syncing.
+	testDir := renterTestDir(t.Name())
+	renterParams := node.Renter(testDir)
+	renterParams.ConsensusSetDeps = &dependencies.DependencyConsensusNotSynced{}
+	r, err := siatest.NewCleanNode(renterParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	// The consensus set should report an estimated height that is higher than
+	// the current height.
+	cg, err := r.ConsensusGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cg.Synced || cg.EstimatedHeight <= cg.Height {
+		t.Fatal("unexpected consensus state", cg.Synced, cg.Height, cg.EstimatedHeight)
+	}
+
+	// The renter should report the sync status.
+	rg, err := r.RenterGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss := rg.SyncStatus
+	if ss.Synced || ss.Height != cg.Height || ss.EstimatedHeight < cg.EstimatedHeight || ss.Progress >= 100 {
+		t.Fatal("unexpected sync status", ss)
+	}
+
+	// Setting an allowance and starting a recovery scan should fail with an
+	// error that contains the sync status.
+	err = r.RenterPostAllowance(modules.DefaultAllowance)
+	if err == nil || !strings.Contains(err.Error(), "waiting for sync (") {
+		t.Fatal("expected sync status in error", err)
+	}
+	err = r.RenterInitContractRecoveryScanPost()
+	if err == nil || !strings.Contains(err.Error(), "waiting for sync (") {
+		t.Fatal("expected sync status in error", err)
+	}
+}
+
+// testDownloadByRoot tests downloading sectors and ranges of sectors from the
+// hosts by their merkle root.
+func testDownloadByRoot(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Upload a file with a single data piece to get a sector on every host.
+	_, rf, err := r.UploadNewFileBlocking(int(modules.SectorSize/2), 1, uint64(len(tg.Hosts())-1), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Load the siafile to get the roots of its pieces.
+	fsRoot := filepath.Join(r.RenterDir(), modules.FileSystemRoot)
+	sp, err := modules.UserFolder.Join(rf.SiaPath().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sf, err := siafile.LoadSiaFileReadonly(sp.SiaFileSysPath(fsRoot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pieces, err := sf.Pieces(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pieces) == 0 || len(pieces[0]) == 0 {
+		t.Fatal("file has no pieces")
+	}
+	root := pieces[0][0].MerkleRoot
+
+	// Download the full sector.
+	sector, err := r.RenterDownloadByRootGet(root, 0, modules.SectorSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint64(len(sector)) != modules.SectorSize {
+		t.Fatal("wrong sector length", len(sector))
+	}
+	if crypto.MerkleRoot(sector) != root {
+		t.Fatal("downloaded sector doesn't match root")
+	}
+
+	// Download a range of the sector which isn't segment aligned.
+	offset, length := uint64(crypto.SegmentSize+1), uint64(3*crypto.SegmentSize)
+	data, err := r.RenterDownloadByRootGet(root, offset, length)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, sector[offset:offset+length]) {
+		t.Fatal("downloaded range doesn't match sector")
+	}
+
+	// Invalid ranges should be rejected.
+	_, err = r.RenterDownloadByRootGet(root, modules.SectorSize, 1)
+	if err == nil {
+		t.Fatal("download beyond the end of the sector should fail")
+	}
+
+	// Download a root that no host stores.
+	var unknownRoot crypto.Hash
+	fastrand.Read(unknownRoot[:])
+	_, err = r.RenterDownloadByRootGet(unknownRoot, 0, modules.SectorSize)
+	if err == nil || !strings.Contains(err.Error(), renter.ErrRootNotFound.Error()) {
+		t.Fatal("expected ErrRootNotFound but got", err)
+	}
+}
+
+// testWorkersNewHost tests that a worker for a new host shows up in the
+// renter's worker pool together with the host's version.
+func testWorkersNewHost(t *testing.T, tg *siatest.TestGroup) {
+	r := tg.Renters()[0]
+
+	// Add a new host.
+	nodes, err := tg.AddNodes(node.HostTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := nodes[0]
+	defer func() {
+		if err := tg.RemoveNode(host); err != nil {
+			t.Fatal(err)
+		}
+	}()
+	hpk, err := host.HostPublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker for the host should show up in the worker pool.
+	err = build.Retry(100, 100*time.Millisecond, func() error {
+		rwg, err := r.RenterWorkersGet()
+		if err != nil {
+			return err
+		}
+		for _, w := range rwg.Workers {
+			if !w.HostPubKey.Equals(hpk) {
+				continue
+			}
+			if w.Version != modules.RHPVersion {
+				return fmt.Errorf("expected version %v but got %v", modules.RHPVersion, w.Version)
+			}
+			return nil
+		}
+		return errors.New("worker for new host not found")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
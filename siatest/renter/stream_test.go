@@ -180,6 +180,7 @@ func TestRenterStream(t *testing.T) {
 	subTests := []siatest.SubTest{
 		{Name: "TestStreamLargeFile", Test: testStreamLargeFile},
 		{Name: "TestStreamRepair", Test: testStreamRepair},
+		{Name: "TestRepairStream", Test: testRepairStream},
 		{Name: "TestUploadStreaming", Test: testUploadStreaming},
 		{Name: "TestUploadStreamingWithBadDeps", Test: testUploadStreamingWithBadDeps},
 	}
@@ -291,6 +292,89 @@ func testStreamRepair(t *testing.T, tg *siatest.TestGroup) {
 	}
 }
 
+// testRepairStream tests that the repair stream endpoint repairs the chunks
+// that match the uploaded pieces and rejects the ones that don't.
+func testRepairStream(t *testing.T, tg *siatest.TestGroup) {
+	// Grab the first of the group's renters
+	r := tg.Renters()[0]
+
+	// Check that we have enough hosts for this test.
+	if len(tg.Hosts()) < 2 {
+		t.Fatal("This test requires at least 2 hosts")
+	}
+
+	// Upload a file with 3 chunks.
+	dataPieces := uint64(1)
+	parityPieces := uint64(len(tg.Hosts())) - dataPieces
+	chunkSize := int(siatest.ChunkSize(dataPieces, crypto.TypeDefaultRenter))
+	fileSize := 2*chunkSize + siatest.Fuzz() + 2
+	localFile, remoteFile, err := r.UploadNewFileBlocking(fileSize, dataPieces, parityPieces, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile(localFile.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Repairing a healthy file skips all chunks.
+	rsp, err := r.RenterRepairStreamPost(bytes.NewReader(b), remoteFile.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Repaired != 0 || rsp.Skipped != 3 || len(rsp.Rejected) != 0 || len(rsp.Failed) != 0 {
+		t.Fatalf("unexpected report %+v", rsp.RepairStreamReport)
+	}
+
+	// Move the file locally to make sure the repair loop can't find it.
+	if err := localFile.Move(); err != nil {
+		t.Fatal("failed to delete local file", err)
+	}
+
+	// Take down all of the hosts and check if redundancy decreases.
+	hostsRemoved := tg.Hosts()
+	if err := tg.RemoveNodeN(hostsRemoved...); err != nil {
+		t.Fatal("Failed to shutdown host", err)
+	}
+	if err := r.WaitForDecreasingRedundancy(remoteFile, 0); err != nil {
+		t.Fatal("Redundancy isn't decreasing", err)
+	}
+	// Bring up hosts to replace the ones that went offline.
+	_, err = tg.AddNodeN(node.HostTemplate, len(hostsRemoved))
+	if err != nil {
+		t.Fatal("Failed to replace hosts", err)
+	}
+
+	// Corrupt the data of the second chunk. Only that chunk should be
+	// rejected.
+	corruptB := append([]byte(nil), b...)
+	corruptB[chunkSize+fastrand.Intn(chunkSize)]++
+	rsp, err = r.RenterRepairStreamPost(bytes.NewReader(corruptB), remoteFile.SiaPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Repaired+uint64(len(rsp.Failed)) != 2 || len(rsp.Rejected) != 1 || rsp.Rejected[0] != 1 {
+		t.Fatalf("unexpected report %+v", rsp.RepairStreamReport)
+	}
+
+	// Repair the file with the correct data.
+	if _, err := r.RenterRepairStreamPost(bytes.NewReader(b), remoteFile.SiaPath()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WaitForUploadHealth(remoteFile); err != nil {
+		t.Fatal("File wasn't repaired", err)
+	}
+	// We should be able to download
+	if _, _, err := r.DownloadByStream(remoteFile); err != nil {
+		t.Fatal("Failed to download file", err)
+	}
+
+	// Streaming too little data fails.
+	if _, err := r.RenterRepairStreamPost(bytes.NewReader(b[:chunkSize]), remoteFile.SiaPath()); err == nil {
+		t.Fatal("expected repair with truncated data to fail")
+	}
+}
+
 // testUploadStreaming uploads random data using the upload streaming API.
 func testUploadStreaming(t *testing.T, tg *siatest.TestGroup) {
 	if len(tg.Renters()) == 0 {